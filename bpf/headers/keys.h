@@ -0,0 +1,33 @@
+#ifndef __KNTRL_KEYS_H__
+#define __KNTRL_KEYS_H__
+
+/*
+ * Map keys shared with userspace (pkg/ebpf/keys.go).
+ *
+ * The keys are in network byte order, the addresses and the ports are
+ * copied from the packet headers without conversion.
+ */
+
+/* ip4_key_t is the key of allowed_ip_map and deny_map */
+typedef __be32 ip4_key_t;
+
+/* port_key_t is the key of allowed_port_map (the address 0 matches any destination) and traffic_map */
+struct port_key_t {
+	__be32 addr;
+	__be16 port;
+	__u16 pad;
+} __attribute__((packed));
+
+/* lpm_ip4_key_t is the key of allowed_range_map, the prefix length is in host byte order */
+struct lpm_ip4_key_t {
+	__u32 prefixlen;
+	__be32 addr;
+};
+
+/* comm_key_t is the key of excluded_comm_map, the task name padded with zeros */
+typedef char comm_key_t[16];
+
+/* cgroup_key_t is the key of excluded_cgroup_map, the cgroup v2 id (inode of the cgroup directory) */
+typedef __u64 cgroup_key_t;
+
+#endif /* __KNTRL_KEYS_H__ */
@@ -10,31 +10,242 @@
 #include "headers/bpf_endian.h"
 #include "headers/bpf_tracing.h"
 #include "headers/dns.h"
+#include "headers/keys.h"
+
+#if defined(__TARGET_ARCH_arm64)
+/* vmlinux.h is generated on x86, arm64 kprobes read the registers via user_pt_regs */
+struct user_pt_regs {
+	__u64 regs[31];
+	__u64 sp;
+	__u64 pc;
+	__u64 pstate;
+};
+#endif
 
 #define AF_INET 2
+#define AF_INET6 10
+#define NAT64_PREFIX_LEN 12
 #define TASK_COMM_LEN 16
 #define MAX_ENTIRES 1024
 #define MAX_HOSTNAME_LEN 256
 #define MODE_ALLOW 1
+#define VERDICT_BLOCKED 0
+#define VERDICT_ALLOWED 1
+#define VERDICT_DELAYED 2
+#define SIGKILL 9
+#define MAX_PATH_LEN 128
+#define MAX_ARGS_LEN 128
+#define MAX_FILE_NAME_LEN 32
+#define SOCK_DGRAM 2
+#define MAX_SENSITIVE_FILES 64
+#define FMODE_READ 0x1
 
 #define ETH_P_IP	0x0800		/* Internet Protocol packet	*/
+#define MAX_CGROUP_LEVEL 8
+#define MAX_TRAFFIC_ENTRIES 16384
+#define MAX_RANGE_ENTRIES 16384
+#define MAX_CAPTURE_FLOWS 1024
 
 ///* Map for allowed IP addresses (hosts) from userspace */
 struct bpf_map_def SEC("maps") allowed_ip_map = {
 	.type = BPF_MAP_TYPE_HASH,
-	.key_size = sizeof(__u32),
+	.key_size = sizeof(ip4_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = MAX_ENTIRES,
+};
+
+///* Map for allowed (IP, port) pairs, the address 0 allows the port for any destination */
+struct bpf_map_def SEC("maps") allowed_port_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(struct port_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = MAX_ENTIRES,
+};
+
+///* Map for allowed IPv4 ranges from userspace (the GitHub meta ranges), longest prefix match */
+struct bpf_map_def SEC("maps") allowed_range_map = {
+	.type = BPF_MAP_TYPE_LPM_TRIE,
+	.key_size = sizeof(struct lpm_ip4_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = MAX_RANGE_ENTRIES,
+	.map_flags = BPF_F_NO_PREALLOC,
+};
+
+///* Map for denied IP addresses, blocked in all modes */
+struct bpf_map_def SEC("maps") deny_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(ip4_key_t),
 	.value_size = sizeof(__u32),
 	.max_entries = MAX_ENTIRES,
 };
 
+///* Map for the tarpit destinations, the value is the delay (ns) of their
+// packets. The packets are delayed by the fq qdisc (EDT) instead of dropped */
+struct bpf_map_def SEC("maps") tarpit_map = {
+	.type = BPF_MAP_TYPE_LRU_HASH,
+	.key_size = sizeof(ip4_key_t),
+	.value_size = sizeof(__u64),
+	.max_entries = MAX_ENTIRES,
+};
+
+///* Map for the kill on violation, the processes connecting to a blocked
+// destination are killed. self_pid is kntrl, it's never killed */
+struct kill_config_t {
+	__u32 enabled;
+	__u32 self_pid;
+};
+
+///* Map for kntrl itself, the events of its connections are not sent (unless
+// they are reported, --report-self). cgroup_id is the dedicated (container)
+// cgroup of kntrl, 0 if the cgroup is shared with the job */
+struct self_config_t {
+	__u32 pid;
+	__u32 pad;
+	__u64 cgroup_id;
+};
+
+struct bpf_map_def SEC("maps") self_map = {
+	.type = BPF_MAP_TYPE_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(struct self_config_t),
+	.max_entries = 1,
+};
+
+struct bpf_map_def SEC("maps") kill_config_map = {
+	.type = BPF_MAP_TYPE_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(struct kill_config_t),
+	.max_entries = 1,
+};
+
+///* Map for allowed hostnames from userspace, the key is the name with a dot
+// before each label (.github.com) padded with zeros. The addresses of the DNS
+// answers of a name, or of its subdomains, are added into allowed_ip_map */
 struct bpf_map_def SEC("maps") allowed_hosts_map = {
 	.type = BPF_MAP_TYPE_HASH,
-	//.key_size = sizeof(char) * MAX_HOSTNAME_LEN,
-	.key_size = 256,
+	.key_size = MAX_HOSTNAME_LEN,
 	.value_size = sizeof(__u32),
 	.max_entries = MAX_ENTIRES,
 };
 
+// hostname_t is the name of a DNS question followed by a key size of zeros,
+// the key of each parent domain is a suffix of the name
+struct hostname_t {
+	char name[MAX_HOSTNAME_LEN * 2];
+};
+
+///* Scratch space of the hostname lookups, the name doesn't fit on the stack twice */
+struct bpf_map_def SEC("maps") hostname_scratch_map = {
+	.type = BPF_MAP_TYPE_PERCPU_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(struct hostname_t),
+	.max_entries = 1,
+};
+
+///* Map for excluded task names, the sockets of the tasks are never blocked */
+struct bpf_map_def SEC("maps") excluded_comm_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(comm_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = 64,
+};
+
+///* Map for excluded cgroups, the descendant cgroups are excluded too */
+struct bpf_map_def SEC("maps") excluded_cgroup_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(cgroup_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = 64,
+};
+
+///* Maps for the enforcement scope: the events of the tasks out of the scope
+// cgroups (and their descendants) are not sent when the scope is enabled, the
+// host is in scope otherwise */
+struct bpf_map_def SEC("maps") scope_config_map = {
+	.type = BPF_MAP_TYPE_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(__u32),
+	.max_entries = 1,
+};
+
+struct bpf_map_def SEC("maps") scope_cgroup_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(cgroup_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = 64,
+};
+
+///* Maps for the filtered events: the task names, the processes (tgid) and the
+// destinations (address and port, the port 0 for any port) not reported. The
+// events of the connections allowed here are dropped, the others are sent to
+// userspace for the decision of the policy */
+struct bpf_map_def SEC("maps") filtered_comm_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(comm_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = 64,
+};
+
+struct bpf_map_def SEC("maps") filtered_pid_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(__u32),
+	.max_entries = 64,
+};
+
+struct bpf_map_def SEC("maps") ignored_dest_map = {
+	.type = BPF_MAP_TYPE_HASH,
+	.key_size = sizeof(struct port_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = 256,
+};
+
+///* Marks the sockets of the excluded tasks, cloned to the accepted sockets of a listener */
+struct {
+	__uint(type, BPF_MAP_TYPE_SK_STORAGE);
+	__uint(map_flags, BPF_F_NO_PREALLOC | BPF_F_CLONE);
+	__type(key, int);
+	__type(value, __u32);
+} excluded_sk_storage SEC(".maps");
+
+///* Traffic counters of the destinations, read by userspace when the report is written */
+struct traffic_t {
+	__u64 sent;
+	__u64 received;
+};
+
+struct bpf_map_def SEC("maps") traffic_map = {
+	.type = BPF_MAP_TYPE_LRU_HASH,
+	.key_size = sizeof(struct port_key_t),
+	.value_size = sizeof(struct traffic_t),
+	.max_entries = MAX_TRAFFIC_ENTRIES,
+};
+
+///* Map for the capture of the violating flows, packets is the number of the
+// packets captured per flow (0 disables the capture), snaplen is the maximum
+// length of a captured packet */
+struct capture_config_t {
+	__u32 packets;
+	__u32 snaplen;
+};
+
+struct bpf_map_def SEC("maps") capture_config_map = {
+	.type = BPF_MAP_TYPE_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(struct capture_config_t),
+	.max_entries = 1,
+};
+
+///* The remaining packets to capture of the violating flows, by destination.
+// The flows blocked by the kernel are added by the egress program, the other
+// violations (monitor mode, tarpit) by userspace */
+struct bpf_map_def SEC("maps") capture_flows_map = {
+	.type = BPF_MAP_TYPE_LRU_HASH,
+	.key_size = sizeof(struct port_key_t),
+	.value_size = sizeof(__u32),
+	.max_entries = MAX_CAPTURE_FLOWS,
+};
+
 ///* Map to pass mode to filter function */
 struct bpf_map_def SEC("maps") mode_map = {
 	.type = BPF_MAP_TYPE_HASH,
@@ -43,6 +254,23 @@ struct bpf_map_def SEC("maps") mode_map = {
 	.max_entries = 1,
 };
 
+// nat64_prefix_t is the /96 NAT64 prefix of the network (64:ff9b::/96 by default)
+struct nat64_prefix_t {
+    __u8 prefix[NAT64_PREFIX_LEN];
+    __u32 enabled;
+};
+
+///* Map of the NAT64 prefix, the IPv6 destinations of the prefix embed an IPv4
+// address (RFC 6052) and are handled as the IPv4 destinations */
+struct bpf_map_def SEC("maps") nat64_prefix_map = {
+	.type = BPF_MAP_TYPE_ARRAY,
+	.key_size = sizeof(__u32),
+	.value_size = sizeof(struct nat64_prefix_t),
+	.max_entries = 1,
+};
+
+// ipv4_event_t is the connection to an IPv4 destination, af is AF_INET6 for
+// the connection to a NAT64 address and daddr is the embedded IPv4 address
 struct ipv4_event_t {
     u64 ts_us;
     u32 pid;
@@ -51,6 +279,31 @@ struct ipv4_event_t {
     u8 proto;
     u32 daddr;
     u16 dport;
+    // cookie is the socket cookie, correlates the close event (fentry only)
+    u64 cookie;
+    // ppid is the parent of the process, the start of the parent chain
+    u32 ppid;
+    // uid, gid, cgroup_id and netns (the inode of the network namespace)
+    // attribute the connection to a user or a container of the runner
+    u32 uid;
+    u32 gid;
+    u64 cgroup_id;
+    u32 netns;
+    // verdict is the verdict of the cgroup program on the connection at the
+    // connect call (VERDICT_BLOCKED, VERDICT_ALLOWED or VERDICT_DELAYED)
+    u8 verdict;
+    // killed is 1 if the process is killed for the connection (kill_config_map)
+    u8 killed;
+} __attribute__((packed));
+
+// ipv4_close_event_t is the close of a TCP connection, state is the last
+// state before TCP_CLOSE (SYN_SENT for a failed connection)
+struct ipv4_close_event_t {
+    u64 ts_us;
+    u64 cookie;
+    u32 daddr;
+    u16 dport;
+    u8 state;
 } __attribute__((packed));
 
 struct {
@@ -61,6 +314,92 @@ struct {
     __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
 } ipv4_closed_events SEC(".maps");
 
+struct exec_event_t {
+    u64 ts_us;
+    u32 pid;
+    u32 ppid;
+    char task[TASK_COMM_LEN];
+    char path[MAX_PATH_LEN];
+    char args[MAX_ARGS_LEN];
+} __attribute__((packed));
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} exec_events SEC(".maps");
+
+// the name of a sensitive file (the key of the sensitive files map)
+struct file_name_t {
+    char name[MAX_FILE_NAME_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, MAX_SENSITIVE_FILES);
+    __type(key, struct file_name_t);
+    __type(value, __u8);
+} sensitive_files_map SEC(".maps");
+
+struct file_event_t {
+    u64 ts_us;
+    u32 pid;
+    u32 ppid;
+    char task[TASK_COMM_LEN];
+    char name[MAX_FILE_NAME_LEN];
+    char parent[MAX_FILE_NAME_LEN];
+} __attribute__((packed));
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} file_events SEC(".maps");
+
+// a listening socket (TCP) or a socket bound to a port (UDP), the IPv4
+// addresses are in the first 4 bytes of the address
+struct listen_event_t {
+    u64 ts_us;
+    u32 pid;
+    u32 ppid;
+    char task[TASK_COMM_LEN];
+    u8 addr[16];
+    u16 port;
+    u8 af;
+    u8 proto;
+} __attribute__((packed));
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} listen_events SEC(".maps");
+
+// a DNS response received by a process, the question (the name in the wire
+// format followed by the type and the class) is parsed in userspace
+struct dns_event_t {
+    u64 ts_us;
+    u32 pid;
+    char task[TASK_COMM_LEN];
+    u32 resolver;
+    u16 answers;
+    u8 rcode;
+    u8 question[MAX_DNS_NAME_LENGTH + 4];
+} __attribute__((packed));
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} dns_events SEC(".maps");
+
+// a captured packet of a violating flow, the packet (caplen bytes from the IP
+// header) follows the event in the sample
+struct capture_event_t {
+    u32 daddr;
+    u16 dport;
+    u8 proto;
+    u8 blocked;
+    u32 len;
+    u32 caplen;
+} __attribute__((packed));
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} capture_events SEC(".maps");
+
 
 static __always_inline int parse_dns_response(int ans_count, unsigned long offset) {
 	unsigned long new_offset = offset;
@@ -131,41 +470,318 @@ static __always_inline u16 __strlen(char *ptr) {
 }
 
 
-static __always_inline int __is_allowed_host(char *hostname) {
-	if (bpf_map_lookup_elem(&allowed_hosts_map, hostname) != NULL)
-		return 1;
+// __is_allowed_host checks the name of the DNS question (wire format, length
+// prefixed labels) and its parent domains against the allowed hosts:
+// api.github.com is allowed by .api.github.com, .github.com or .com
+static __always_inline int __is_allowed_host(const char *qname) {
+	__u32 zero = 0;
+	struct hostname_t *host = bpf_map_lookup_elem(&hostname_scratch_map, &zero);
+	if (!host) {
+		return 0;
+	}
+
+	// the label lengths become dots, the rest of the name is zeroed
+	int next = 0;
+	bool end = false;
+	for (int i = 0; i < MAX_HOSTNAME_LEN; i++) {
+		char c = end ? 0 : qname[i];
+		if (!end && i == next) {
+			if (c == 0) {
+				end = true;
+			} else {
+				next = i + 1 + (__u8)c;
+				c = '.';
+			}
+		}
+		host->name[i] = c;
+		host->name[i + MAX_HOSTNAME_LEN] = 0;
+	}
+	// a truncated name is not matched
+	if (!end) {
+		return 0;
+	}
+
+	for (int i = 0; i < MAX_HOSTNAME_LEN; i++) {
+		if (host->name[i] == 0) {
+			break;
+		}
+		if (host->name[i] == '.' && bpf_map_lookup_elem(&allowed_hosts_map, &host->name[i]) != NULL) {
+			return 1;
+		}
+	}
 
 	return 0;
 }
 
+// nat64_embedded returns the IPv4 address embedded in the IPv6 address of the
+// NAT64 prefix, 0 if the address is not of the prefix
+static __always_inline __u32 nat64_embedded(const __u8 *addr) {
+	__u32 key = 0;
+	struct nat64_prefix_t *nat64 = bpf_map_lookup_elem(&nat64_prefix_map, &key);
+	if (!nat64 || !nat64->enabled) {
+		return 0;
+	}
+
+	for (int i = 0; i < NAT64_PREFIX_LEN; i++) {
+		if (addr[i] != nat64->prefix[i]) {
+			return 0;
+		}
+	}
+
+	__u32 daddr = 0;
+	__builtin_memcpy(&daddr, addr + NAT64_PREFIX_LEN, sizeof(daddr));
+	return daddr;
+}
+
+// ipv4_mapped returns the IPv4 address of the IPv4-mapped IPv6 address
+// (::ffff:1.2.3.4), 0 if the address is not mapped
+static __always_inline __u32 ipv4_mapped(const __u8 *addr) {
+	for (int i = 0; i < 10; i++) {
+		if (addr[i]) {
+			return 0;
+		}
+	}
+	if (addr[10] != 0xff || addr[11] != 0xff) {
+		return 0;
+	}
+
+	__u32 daddr = 0;
+	__builtin_memcpy(&daddr, addr + 12, sizeof(daddr));
+	return daddr;
+}
+
+// connect_verdict returns the verdict of the cgroup program on the packets of
+// the connection, from the lists at the time of the connect call
+static __always_inline u8 connect_verdict(__u32 daddr, __u16 dport) {
+	if (bpf_map_lookup_elem(&deny_map, &daddr)) {
+		return VERDICT_BLOCKED;
+	}
+
+	__u32 key = 0;
+	__u32 *mode = bpf_map_lookup_elem(&mode_map, &key);
+	if (!mode || *mode != MODE_ALLOW) {
+		return VERDICT_ALLOWED;
+	}
+
+	struct lpm_ip4_key_t range = {.prefixlen = 32, .addr = daddr};
+	if (bpf_map_lookup_elem(&allowed_ip_map, &daddr) || bpf_map_lookup_elem(&allowed_range_map, &range)) {
+		return VERDICT_ALLOWED;
+	}
+
+	struct port_key_t port = {};
+	port.addr = daddr;
+	port.port = bpf_htons(dport);
+	if (bpf_map_lookup_elem(&allowed_port_map, &port)) {
+		return VERDICT_ALLOWED;
+	}
+	port.addr = 0;
+	if (bpf_map_lookup_elem(&allowed_port_map, &port)) {
+		return VERDICT_ALLOWED;
+	}
+
+	if (bpf_map_lookup_elem(&tarpit_map, &daddr)) {
+		return VERDICT_DELAYED;
+	}
+
+	return VERDICT_BLOCKED;
+}
+
+// kill_violation returns true if the process connecting to a blocked
+// destination is killed: the kill is enabled and the process is neither
+// kntrl nor excluded from the enforcement
+static __always_inline bool kill_violation(u32 pid) {
+	__u32 key = 0;
+	struct kill_config_t *cfg = bpf_map_lookup_elem(&kill_config_map, &key);
+	if (!cfg || !cfg->enabled || pid == cfg->self_pid) {
+		return false;
+	}
+
+	comm_key_t comm = {};
+	bpf_get_current_comm(&comm, sizeof(comm));
+	if (bpf_map_lookup_elem(&excluded_comm_map, &comm)) {
+		return false;
+	}
+
+	for (int level = 1; level <= MAX_CGROUP_LEVEL; level++) {
+		cgroup_key_t id = bpf_get_current_ancestor_cgroup_id(level);
+		if (!id) {
+			break;
+		}
+		if (bpf_map_lookup_elem(&excluded_cgroup_map, &id)) {
+			return false;
+		}
+	}
+
+	return true;
+}
+
+// is_self returns true if the current task is kntrl or runs in its dedicated
+// cgroup, the child processes of kntrl are matched in userspace
+static __always_inline bool is_self(u32 pid) {
+	__u32 key = 0;
+	struct self_config_t *cfg = bpf_map_lookup_elem(&self_map, &key);
+	if (!cfg || !cfg->pid) {
+		return false;
+	}
+
+	return pid == cfg->pid || (cfg->cgroup_id && bpf_get_current_cgroup_id() == cfg->cgroup_id);
+}
+
+// in_scope returns true if the current task is in the enforcement scope: a
+// scope cgroup or its descendant, any task if the scope is not enabled
+static __always_inline bool in_scope(void) {
+	__u32 key = 0;
+	__u32 *enabled = bpf_map_lookup_elem(&scope_config_map, &key);
+	if (!enabled || !*enabled) {
+		return true;
+	}
+
+	for (int level = 1; level <= MAX_CGROUP_LEVEL; level++) {
+		cgroup_key_t id = bpf_get_current_ancestor_cgroup_id(level);
+		if (!id) {
+			break;
+		}
+		if (bpf_map_lookup_elem(&scope_cgroup_map, &id)) {
+			return true;
+		}
+	}
+
+	return false;
+}
+
+// filtered returns true if the event of the connection is not reported
+static __always_inline bool filtered(u32 pid, __u32 daddr, __u16 dport) {
+	if (bpf_map_lookup_elem(&filtered_pid_map, &pid)) {
+		return true;
+	}
+
+	comm_key_t comm = {};
+	bpf_get_current_comm(&comm, sizeof(comm));
+	if (bpf_map_lookup_elem(&filtered_comm_map, &comm)) {
+		return true;
+	}
+
+	struct port_key_t key = {};
+	key.addr = daddr;
+	key.port = bpf_htons(dport);
+	if (bpf_map_lookup_elem(&ignored_dest_map, &key)) {
+		return true;
+	}
+	key.port = 0;
+
+	return bpf_map_lookup_elem(&ignored_dest_map, &key) != NULL;
+}
+
 static int __attribute__((always_inline)) handle_event(struct ipv4_event_t *evt4, struct sockaddr *address, uint8_t proto) {
 	u32 pid = bpf_get_current_pid_tgid() >> 32;
+	// the egress of kntrl (sinks, uploads, lookups) would fill the buffer with its own events
+	if (is_self(pid)) {
+		return 0;
+	}
+	// the rest of the host (the runner agent) is neither enforced nor reported
+	if (!in_scope()) {
+		return 0;
+	}
 	u16 address_family = 0;
+	u16 dport = 0;
 
 	bpf_probe_read(&address_family, sizeof(address_family), &address->sa_family);
 
-	// handle IP event only
+	// handle IP event only, the IPv6 destinations of the NAT64 prefix are
+	// reported as their embedded IPv4 address. The IPv4-mapped destinations of
+	// the dual-stack sockets are IPv4: the TCP connect is reported by
+	// tcp_v4_connect (called by tcp_v6_connect), the UDP connect here as
+	// __ip4_datagram_connect is not probed.
 	if (address_family == AF_INET) {
-		evt4->pid = pid;
-		evt4->af = address_family;
-		evt4->proto = proto;
-		evt4->ts_us = bpf_ktime_get_ns() / 1000;
-
 		struct sockaddr_in *daddr = (struct sockaddr_in *)address;
 		bpf_probe_read(&evt4->daddr, sizeof(evt4->daddr), &daddr->sin_addr.s_addr);
-
-		u16 dport = 0;
 	    	bpf_probe_read(&dport, sizeof(dport), &daddr->sin_port);
-		evt4->dport = bpf_ntohs(dport);
+	} else if (address_family == AF_INET6) {
+		struct sockaddr_in6 *daddr6 = (struct sockaddr_in6 *)address;
+		__u8 addr6[16] = {};
+		bpf_probe_read(&addr6, sizeof(addr6), &daddr6->sin6_addr);
+		evt4->daddr = nat64_embedded(addr6);
+		if (!evt4->daddr && proto == IPPROTO_UDP) {
+			evt4->daddr = ipv4_mapped(addr6);
+			if (evt4->daddr) {
+				address_family = AF_INET;
+			}
+		}
+		if (!evt4->daddr) {
+			return 0;
+		}
+	    	bpf_probe_read(&dport, sizeof(dport), &daddr6->sin6_port);
+	} else {
+		return 0;
+	}
 
-		bpf_get_current_comm(&evt4->task, TASK_COMM_LEN);
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+
+	evt4->pid = pid;
+	evt4->ppid = BPF_CORE_READ(task, real_parent, tgid);
+
+	u64 uid_gid = bpf_get_current_uid_gid();
+	evt4->uid = (u32)uid_gid;
+	evt4->gid = uid_gid >> 32;
+	evt4->cgroup_id = bpf_get_current_cgroup_id();
+	evt4->netns = BPF_CORE_READ(task, nsproxy, net_ns, ns.inum);
+	evt4->af = address_family;
+	evt4->proto = proto;
+	evt4->ts_us = bpf_ktime_get_ns() / 1000;
+	evt4->dport = bpf_ntohs(dport);
+	evt4->verdict = connect_verdict(evt4->daddr, evt4->dport);
+	if (evt4->verdict == VERDICT_BLOCKED && evt4->dport && kill_violation(pid)) {
+		bpf_send_signal(SIGKILL);
+		evt4->killed = 1;
+	}
+	// the blocked connections are sent for the decision of the policy
+	if (evt4->verdict == VERDICT_ALLOWED && filtered(pid, evt4->daddr, evt4->dport)) {
+		return 0;
+	}
 
-		if (evt4->dport != 0) {
-			return 1;
+	bpf_get_current_comm(&evt4->task, TASK_COMM_LEN);
+
+	return evt4->dport != 0;
+}
+
+// mark_excluded marks the socket if the current task is excluded, the cgroup_skb
+// program can't read the task name (the packets are not sent in task context)
+static __always_inline void mark_excluded(struct sock *sk) {
+	comm_key_t comm = {};
+	bpf_get_current_comm(&comm, sizeof(comm));
+	if (!bpf_map_lookup_elem(&excluded_comm_map, &comm)) {
+		return;
+	}
+
+	bpf_sk_storage_get(&excluded_sk_storage, sk, 0, BPF_SK_STORAGE_GET_F_CREATE);
+}
+
+// count_traffic adds the bytes to the counters of the destination of the socket,
+// the unconnected sockets (no destination address) are not counted
+static __always_inline void count_traffic(struct sock *sk, __u64 sent, __u64 received) {
+	if (BPF_CORE_READ(sk, __sk_common.skc_family) != AF_INET) {
+		return;
+	}
+
+	struct port_key_t key = {};
+	key.addr = BPF_CORE_READ(sk, __sk_common.skc_daddr);
+	key.port = BPF_CORE_READ(sk, __sk_common.skc_dport);
+	if (!key.addr) {
+		return;
+	}
+
+	struct traffic_t *traffic = bpf_map_lookup_elem(&traffic_map, &key);
+	if (!traffic) {
+		struct traffic_t zero = {};
+		bpf_map_update_elem(&traffic_map, &key, &zero, BPF_NOEXIST);
+		traffic = bpf_map_lookup_elem(&traffic_map, &key);
+		if (!traffic) {
+			return;
 		}
 	}
-		
-	return 0;
+
+	__sync_fetch_and_add(&traffic->sent, sent);
+	__sync_fetch_and_add(&traffic->received, received);
 }
 
 SEC("kprobe/skb_consume_udp")
@@ -232,15 +848,12 @@ int kprobe__skb_consume_udp(struct pt_regs *ctx) {
 				return ret;
 			}
 			
-			// TODO: check domain name (allowed hosts)
-			size_t len = __strlen(buff);
-
-			//__is_allowed_host(buff);
+			// the name is checked before __strlen replaces the label lengths
 			if (!__is_allowed_host(buff))
 			{
-				bpf_printk(" ||||| strcmp == 0 [%s] |||||| exiting...", buff);
 				return 0;
 			}
+			size_t len = __strlen(buff);
 
 			// read record type and class (queries)
 			uint32_t rc;
@@ -269,6 +882,65 @@ int kprobe__skb_consume_udp(struct pt_regs *ctx) {
 	return 0;
 }
 
+// the DNS query log: the question of each response is sent to userspace, the
+// names looked up without a connection are reported too (NXDOMAIN, the
+// exfiltration through the queries). It doesn't write the maps and it's
+// attached in passive mode too.
+SEC("kprobe/skb_consume_udp")
+int kprobe__skb_consume_udp_dns(struct pt_regs *ctx) {
+	struct sk_buff *skb = (struct sk_buff *)PT_REGS_PARM2(ctx);
+	if (!skb) {
+		return 0;
+	}
+
+	unsigned char *head = sk_buff_head(skb);
+	if (!head) {
+		return 0;
+	}
+
+	u16 net_head = sk_buff_network_header(skb);
+	if (!net_head) {
+		return 0;
+	}
+
+	struct iphdr iph = {};
+	if (bpf_probe_read(&iph, sizeof(iph), (struct iph *)(head + net_head))) {
+		return 0;
+	}
+	if (iph.version != 4 || iph.protocol != IPPROTO_UDP) {
+		return 0;
+	}
+
+	struct udphdr udph = {};
+	if (bpf_probe_read(&udph, sizeof(udph), (struct udph *)(head + net_head + sizeof(iph)))) {
+		return 0;
+	}
+	if (bpf_ntohs(udph.source) != 53) {
+		return 0;
+	}
+
+	struct dns_hdr dnsh = {};
+	if (bpf_probe_read(&dnsh, sizeof(dnsh), (struct dnsh *)(head + net_head + sizeof(iph) + sizeof(udph)))) {
+		return 0;
+	}
+	// a response to a standard query of a single question
+	if (dnsh.qr != 1 || dnsh.opcode != 0 || bpf_ntohs(dnsh.q_count) != 1) {
+		return 0;
+	}
+
+	struct dns_event_t evt = {};
+	evt.ts_us = bpf_ktime_get_ns() / 1000;
+	evt.pid = bpf_get_current_pid_tgid() >> 32;
+	bpf_get_current_comm(&evt.task, TASK_COMM_LEN);
+	evt.resolver = iph.saddr;
+	evt.answers = bpf_ntohs(dnsh.ans_count);
+	evt.rcode = dnsh.rcode;
+	bpf_probe_read(&evt.question, sizeof(evt.question), (char *)(head + net_head + sizeof(iph) + sizeof(udph) + sizeof(dnsh)));
+
+	bpf_perf_event_output(ctx, &dns_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
 
 SEC("kprobe/ip4_datagram_connect")
 int kprobe__ip4_datagram_connect(struct pt_regs *ctx) {
@@ -303,6 +975,174 @@ int kprobe__tcp_v4_connect(struct pt_regs *ctx) {
 	return 0;
 }
 
+// fentry variants of the connect probes are preferred on kernels with BTF
+// and trampoline support, the loader removes the kprobes in that case
+// (see pkg/ebpf/probe.go)
+SEC("fentry/tcp_v4_connect")
+int BPF_PROG(fentry__tcp_v4_connect, struct sock *sk, struct sockaddr *uaddr, int addr_len) {
+	if (!uaddr) {
+		return 0;
+	}
+	mark_excluded(sk);
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, uaddr, IPPROTO_TCP)) {
+	            evt4.cookie = bpf_get_socket_cookie(sk);
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+SEC("fentry/ip4_datagram_connect")
+int BPF_PROG(fentry__ip4_datagram_connect, struct sock *sk, struct sockaddr *uaddr, int addr_len) {
+	if (!uaddr) {
+		return 0;
+	}
+	mark_excluded(sk);
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, uaddr, IPPROTO_UDP)) {
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+// the IPv6 connect probes report the connections to the NAT64 addresses and
+// the UDP connections to the IPv4-mapped addresses only
+SEC("kprobe/tcp_v6_connect")
+int kprobe__tcp_v6_connect(struct pt_regs *ctx) {
+	struct sockaddr *address = (struct sockaddr *)PT_REGS_PARM2(ctx);
+	if (!address) {
+		return 0;
+	}
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, address, IPPROTO_TCP)) {
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+SEC("kprobe/ip6_datagram_connect")
+int kprobe__ip6_datagram_connect(struct pt_regs *ctx) {
+	struct sockaddr *address = (struct sockaddr *)PT_REGS_PARM2(ctx);
+	if (!address) {
+		return 0;
+	}
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, address, IPPROTO_UDP)) {
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+// the close of the IPv6 sockets is not traced, the events have no cookie
+SEC("fentry/tcp_v6_connect")
+int BPF_PROG(fentry__tcp_v6_connect, struct sock *sk, struct sockaddr *uaddr, int addr_len) {
+	if (!uaddr) {
+		return 0;
+	}
+	mark_excluded(sk);
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, uaddr, IPPROTO_TCP)) {
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+SEC("fentry/ip6_datagram_connect")
+int BPF_PROG(fentry__ip6_datagram_connect, struct sock *sk, struct sockaddr *uaddr, int addr_len) {
+	if (!uaddr) {
+		return 0;
+	}
+	mark_excluded(sk);
+
+	struct ipv4_event_t evt4 = {};
+	if (handle_event(&evt4, uaddr, IPPROTO_UDP)) {
+	            bpf_perf_event_output(ctx, &ipv4_events, BPF_F_CURRENT_CPU, &evt4, sizeof(evt4));
+	}
+
+	return 0;
+}
+
+// the sockets of the excluded tasks are marked on listen (the accepted sockets
+// inherit the mark) and on send (the sockets opened before kntrl started).
+// The marks require fentry support, there are no kprobe variants.
+SEC("fentry/inet_csk_listen_start")
+int BPF_PROG(fentry__inet_csk_listen_start, struct sock *sk) {
+	mark_excluded(sk);
+	return 0;
+}
+
+SEC("fentry/tcp_sendmsg")
+int BPF_PROG(fentry__tcp_sendmsg, struct sock *sk) {
+	mark_excluded(sk);
+	return 0;
+}
+
+SEC("fentry/udp_sendmsg")
+int BPF_PROG(fentry__udp_sendmsg, struct sock *sk) {
+	mark_excluded(sk);
+	return 0;
+}
+
+// the traffic counters, the bytes sent are the return values of the send
+// calls and the bytes received are the bytes copied to the user. As the
+// exclusion marks, the counters require fentry support.
+SEC("fexit/tcp_sendmsg")
+int BPF_PROG(fexit__tcp_sendmsg, struct sock *sk, struct msghdr *msg, size_t size, int ret) {
+	if (ret > 0) {
+		count_traffic(sk, ret, 0);
+	}
+	return 0;
+}
+
+SEC("fentry/tcp_cleanup_rbuf")
+int BPF_PROG(fentry__tcp_cleanup_rbuf, struct sock *sk, int copied) {
+	if (copied > 0) {
+		count_traffic(sk, 0, copied);
+	}
+	return 0;
+}
+
+SEC("fexit/udp_sendmsg")
+int BPF_PROG(fexit__udp_sendmsg, struct sock *sk, struct msghdr *msg, size_t len, int ret) {
+	if (ret > 0) {
+		count_traffic(sk, ret, 0);
+	}
+	return 0;
+}
+
+// the close events of the TCP connections, correlated with the connect
+// events by the socket cookie. The listeners have no destination address.
+SEC("fentry/tcp_set_state")
+int BPF_PROG(fentry__tcp_set_state, struct sock *sk, int state) {
+	if (state != BPF_TCP_CLOSE || BPF_CORE_READ(sk, __sk_common.skc_family) != AF_INET) {
+		return 0;
+	}
+
+	struct ipv4_close_event_t evt = {};
+	evt.daddr = BPF_CORE_READ(sk, __sk_common.skc_daddr);
+	if (!evt.daddr) {
+		return 0;
+	}
+	evt.ts_us = bpf_ktime_get_ns() / 1000;
+	evt.cookie = bpf_get_socket_cookie(sk);
+	evt.dport = bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+	evt.state = BPF_CORE_READ(sk, __sk_common.skc_state);
+
+	bpf_perf_event_output(ctx, &ipv4_closed_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
 SEC("tracepoint/sock/inet_sock_set_state")
 int inet_sock_set_state(void *ctx) {
   	struct trace_event_raw_inet_sock_set_state args = {};
@@ -337,31 +1177,346 @@ int inet_sock_set_state(void *ctx) {
 	return 0;
 }
 
-inline bool handle_pkt(struct __sk_buff *skb, bool egress) {
+// short-lived processes may exit before userspace can read /proc/<pid>,
+// so every exec is sent to userspace where it's kept in a ring cache
+SEC("tracepoint/sched/sched_process_exec")
+int tracepoint__sched_process_exec(struct trace_event_raw_sched_process_exec *ctx) {
+	struct exec_event_t evt = {};
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+
+	evt.ts_us = bpf_ktime_get_ns() / 1000;
+	evt.pid = bpf_get_current_pid_tgid() >> 32;
+	evt.ppid = BPF_CORE_READ(task, real_parent, tgid);
+	bpf_get_current_comm(&evt.task, TASK_COMM_LEN);
+
+	// filename is a dynamic array (__data_loc): lower 16 bits hold the offset
+	unsigned int filename_loc = ctx->__data_loc_filename & 0xFFFF;
+	bpf_probe_read_str(&evt.path, sizeof(evt.path), (void *)ctx + filename_loc);
+
+	// argv is stored as NULL separated strings in the user memory
+	unsigned long arg_start = BPF_CORE_READ(task, mm, arg_start);
+	unsigned long arg_end = BPF_CORE_READ(task, mm, arg_end);
+	unsigned long arg_len = arg_end - arg_start;
+	if (arg_len > MAX_ARGS_LEN) {
+		arg_len = MAX_ARGS_LEN;
+	}
+	bpf_probe_read_user(&evt.args, arg_len, (void *)arg_start);
+
+	bpf_perf_event_output(ctx, &exec_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
+static __always_inline void init_listen_event(struct listen_event_t *evt) {
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+
+	evt->ts_us = bpf_ktime_get_ns() / 1000;
+	evt->pid = bpf_get_current_pid_tgid() >> 32;
+	evt->ppid = BPF_CORE_READ(task, real_parent, tgid);
+	bpf_get_current_comm(&evt->task, TASK_COMM_LEN);
+}
+
+// the listening sockets, userspace reports the ones on a non-loopback address
+// (a reverse shell or a tunnel waiting for a connection)
+SEC("kprobe/inet_listen")
+int kprobe__inet_listen(struct pt_regs *ctx) {
+	struct socket *sock = (struct socket *)PT_REGS_PARM1(ctx);
+	if (!sock) {
+		return 0;
+	}
+	struct sock *sk = BPF_CORE_READ(sock, sk);
+	if (!sk) {
+		return 0;
+	}
+
+	struct listen_event_t evt = {};
+	evt.af = BPF_CORE_READ(sk, __sk_common.skc_family);
+	evt.proto = IPPROTO_TCP;
+	evt.port = BPF_CORE_READ(sk, __sk_common.skc_num);
+	if (evt.af == AF_INET) {
+		__u32 addr = BPF_CORE_READ(sk, __sk_common.skc_rcv_saddr);
+		__builtin_memcpy(&evt.addr, &addr, sizeof(addr));
+	} else if (evt.af == AF_INET6) {
+		BPF_CORE_READ_INTO(&evt.addr, sk, __sk_common.skc_v6_rcv_saddr.in6_u.u6_addr8);
+	} else {
+		return 0;
+	}
+	init_listen_event(&evt);
+
+	bpf_perf_event_output(ctx, &listen_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
+// the UDP sockets bound to a port, the UDP servers don't listen. The clients
+// are bound on the first send, not by bind. The address is copied to the
+// kernel by the bind call.
+SEC("kprobe/inet_bind")
+int kprobe__inet_bind(struct pt_regs *ctx) {
+	struct socket *sock = (struct socket *)PT_REGS_PARM1(ctx);
+	struct sockaddr_in *addr = (struct sockaddr_in *)PT_REGS_PARM2(ctx);
+	if (!sock || !addr || BPF_CORE_READ(sock, type) != SOCK_DGRAM) {
+		return 0;
+	}
+
+	struct listen_event_t evt = {};
+	evt.af = AF_INET;
+	evt.proto = IPPROTO_UDP;
+	evt.port = bpf_ntohs(BPF_CORE_READ(addr, sin_port));
+	if (!evt.port || BPF_CORE_READ(addr, sin_family) != AF_INET) {
+		return 0;
+	}
+	__u32 saddr = BPF_CORE_READ(addr, sin_addr.s_addr);
+	__builtin_memcpy(&evt.addr, &saddr, sizeof(saddr));
+	init_listen_event(&evt);
+
+	bpf_perf_event_output(ctx, &listen_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
+SEC("kprobe/inet6_bind")
+int kprobe__inet6_bind(struct pt_regs *ctx) {
+	struct socket *sock = (struct socket *)PT_REGS_PARM1(ctx);
+	struct sockaddr_in6 *addr = (struct sockaddr_in6 *)PT_REGS_PARM2(ctx);
+	if (!sock || !addr || BPF_CORE_READ(sock, type) != SOCK_DGRAM) {
+		return 0;
+	}
+
+	struct listen_event_t evt = {};
+	evt.af = AF_INET6;
+	evt.proto = IPPROTO_UDP;
+	evt.port = bpf_ntohs(BPF_CORE_READ(addr, sin6_port));
+	if (!evt.port || BPF_CORE_READ(addr, sin6_family) != AF_INET6) {
+		return 0;
+	}
+	BPF_CORE_READ_INTO(&evt.addr, addr, sin6_addr.in6_u.u6_addr8);
+	init_listen_event(&evt);
+
+	bpf_perf_event_output(ctx, &listen_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
+// the reads of the sensitive files (the credentials of the workspace), the
+// names are filtered in the kernel and the directories in userspace
+SEC("kprobe/security_file_open")
+int kprobe__security_file_open(struct pt_regs *ctx) {
+	struct file *file = (struct file *)PT_REGS_PARM1(ctx);
+	if (!file) {
+		return 0;
+	}
+
+	if (!(BPF_CORE_READ(file, f_mode) & FMODE_READ)) {
+		return 0;
+	}
+
+	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
+	struct file_name_t key = {};
+	bpf_probe_read_kernel_str(&key.name, sizeof(key.name), BPF_CORE_READ(dentry, d_name.name));
+	if (!bpf_map_lookup_elem(&sensitive_files_map, &key)) {
+		return 0;
+	}
+
+	struct file_event_t evt = {};
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+
+	evt.ts_us = bpf_ktime_get_ns() / 1000;
+	evt.pid = bpf_get_current_pid_tgid() >> 32;
+	evt.ppid = BPF_CORE_READ(task, real_parent, tgid);
+	bpf_get_current_comm(&evt.task, TASK_COMM_LEN);
+	__builtin_memcpy(&evt.name, &key.name, sizeof(evt.name));
+	bpf_probe_read_kernel_str(&evt.parent, sizeof(evt.parent), BPF_CORE_READ(dentry, d_parent, d_name.name));
+
+	bpf_perf_event_output(ctx, &file_events, BPF_F_CURRENT_CPU, &evt, sizeof(evt));
+
+	return 0;
+}
+
+// port_allowed returns true if the destination port is allowed for the address or any address,
+// l4_off is the offset of the TCP or UDP header
+static __always_inline bool port_allowed(struct __sk_buff *skb, __u32 daddr, __u8 proto, __u32 l4_off) {
+	if (proto != IPPROTO_TCP && proto != IPPROTO_UDP) {
+		return false;
+	}
+
+	// the destination port follows the source port in both TCP and UDP headers
+	__be16 dport = 0;
+	if (bpf_skb_load_bytes(skb, l4_off + sizeof(__be16), &dport, sizeof(dport)) < 0) {
+		return false;
+	}
+
+	struct port_key_t key = {};
+	key.addr = daddr;
+	key.port = dport;
+	if (bpf_map_lookup_elem(&allowed_port_map, &key)) {
+		return true;
+	}
+
+	key.addr = 0;
+	return bpf_map_lookup_elem(&allowed_port_map, &key) != NULL;
+}
+
+// excluded returns true if the socket is marked by mark_excluded
+// or the cgroup of the socket (or an ancestor) is excluded
+static __always_inline bool excluded(struct __sk_buff *skb) {
+	struct bpf_sock *sk = skb->sk;
+	if (sk) {
+		sk = bpf_sk_fullsock(sk);
+		if (sk && bpf_sk_storage_get(&excluded_sk_storage, sk, 0, 0)) {
+			return true;
+		}
+	}
+
+	for (int level = 1; level <= MAX_CGROUP_LEVEL; level++) {
+		cgroup_key_t id = bpf_skb_ancestor_cgroup_id(skb, level);
+		if (!id) {
+			break;
+		}
+		if (bpf_map_lookup_elem(&excluded_cgroup_map, &id)) {
+			return true;
+		}
+	}
+
+	return false;
+}
+
+// range_allowed returns true if the address is in an allowed range
+static __always_inline bool range_allowed(__u32 addr) {
+	struct lpm_ip4_key_t key = {.prefixlen = 32, .addr = addr};
+
+	return bpf_map_lookup_elem(&allowed_range_map, &key) != NULL;
+}
+
+// tarpit sets the earliest departure time of the packet, the fq qdisc holds
+// the packet until then
+static __always_inline void tarpit(struct __sk_buff *skb, __u64 delay_ns) {
+	__u64 tstamp = bpf_ktime_get_ns() + delay_ns;
+
+	if (skb->tstamp < tstamp) {
+		skb->tstamp = tstamp;
+	}
+}
+
+// verdict returns the verdict of the packet to the IPv4 destination, saddr is
+// 0 for the packets of the NAT64 destinations (the source is IPv6)
+static __always_inline bool verdict(struct __sk_buff *skb, __u32 saddr, __u32 daddr, __u8 proto, __u32 l4_off) {
 	bool block = true;
 
+	// the excluded services are never blocked, the deny list included
+	if (excluded(skb)) {
+		return true;
+	}
+
+	// the deny list overrides the mode and the allow list
+	if (bpf_map_lookup_elem(&deny_map, &daddr)) {
+		return false;
+	}
+
+	bool pass = (saddr && bpf_map_lookup_elem(&allowed_ip_map, &saddr)) || bpf_map_lookup_elem(&allowed_ip_map, &daddr) || range_allowed(daddr) || port_allowed(skb, daddr, proto, l4_off);
+
+	__u32 key = 0;
+	__u32 *mode;
+
+	mode = bpf_map_lookup_elem(&mode_map, &key);
+	if (mode) {
+		if (*mode == MODE_ALLOW) {
+			block = (*mode && pass);
+		}
+	}
+
+	// the tarpit destinations pass with a delay
+	if (!block) {
+		__u64 *delay_ns = bpf_map_lookup_elem(&tarpit_map, &daddr);
+		if (delay_ns) {
+			tarpit(skb, *delay_ns);
+			block = true;
+		}
+	}
+
+	// 0 block || 1 pass
+	return block;
+}
+
+// capture sends the packet of a violating flow to userspace: a blocked packet
+// starts the capture of its flow, the packets of the flow are captured until
+// the packets of capture_config_map are sent
+static __always_inline void capture(struct __sk_buff *skb, __u32 daddr, __u8 proto, __u32 l4_off, bool blocked) {
+	__u32 key = 0;
+	struct capture_config_t *cfg = bpf_map_lookup_elem(&capture_config_map, &key);
+	if (!cfg || !cfg->packets) {
+		return;
+	}
+
+	struct port_key_t flow = {};
+	flow.addr = daddr;
+	if (proto == IPPROTO_TCP || proto == IPPROTO_UDP) {
+		bpf_skb_load_bytes(skb, l4_off + sizeof(__be16), &flow.port, sizeof(flow.port));
+	}
+
+	__u32 *remaining = bpf_map_lookup_elem(&capture_flows_map, &flow);
+	if (!remaining) {
+		if (!blocked) {
+			return;
+		}
+		bpf_map_update_elem(&capture_flows_map, &flow, &cfg->packets, BPF_NOEXIST);
+		remaining = bpf_map_lookup_elem(&capture_flows_map, &flow);
+		if (!remaining) {
+			return;
+		}
+	}
+	if (!*remaining) {
+		return;
+	}
+	__sync_fetch_and_sub(remaining, 1);
+
+	__u64 caplen = skb->len;
+	if (caplen > cfg->snaplen) {
+		caplen = cfg->snaplen;
+	}
+
+	struct capture_event_t evt = {};
+	evt.daddr = daddr;
+	evt.dport = bpf_ntohs(flow.port);
+	evt.proto = proto;
+	evt.blocked = blocked;
+	evt.len = skb->len;
+	evt.caplen = caplen;
+
+	// the upper 32 bits of the flags are the bytes of the packet appended to the sample
+	bpf_perf_event_output(skb, &capture_events, BPF_F_CURRENT_CPU | (caplen << 32), &evt, sizeof(evt));
+}
+
+inline bool handle_pkt(struct __sk_buff *skb, bool egress) {
 	// INFO: ingress context is usually a kernel thread or a running task
 	struct iphdr iph;
 	// load packet header
 	bpf_skb_load_bytes(skb, 0, &iph, sizeof(struct iphdr));
 
-	// refactor
-	if (iph.version == 4){
-		bool pass = bpf_map_lookup_elem(&allowed_ip_map, &iph.saddr) || bpf_map_lookup_elem(&allowed_ip_map, &iph.daddr);
+	if (iph.version == 4) {
+		bool pass = verdict(skb, iph.saddr, iph.daddr, iph.protocol, iph.ihl * 4);
+		capture(skb, iph.daddr, iph.protocol, iph.ihl * 4, !pass);
+		return pass;
+	}
 
-		__u32 key = 0;
-		__u32 *mode;
+	// the IPv6 packets to the NAT64 prefix are handled as the packets to the
+	// embedded IPv4 address, the other IPv6 packets pass. The extension headers
+	// are not followed, the port rules don't match their packets.
+	if (iph.version == 6) {
+		struct ipv6hdr ip6h;
+		if (bpf_skb_load_bytes(skb, 0, &ip6h, sizeof(ip6h)) < 0) {
+			return true;
+		}
 
-		mode = bpf_map_lookup_elem(&mode_map, &key);
-		if (mode) {
-			if (*mode == MODE_ALLOW) {
-				block = (*mode && pass);
-			}
+		__u32 daddr = nat64_embedded(ip6h.daddr.in6_u.u6_addr8);
+		if (daddr) {
+			bool pass = verdict(skb, 0, daddr, ip6h.nexthdr, sizeof(ip6h));
+			capture(skb, daddr, ip6h.nexthdr, sizeof(ip6h), !pass);
+			return pass;
 		}
 	}
 
-	// 0 block || 1 pass
-	return block;
+	return true;
 }
 
 //
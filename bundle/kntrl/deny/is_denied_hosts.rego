@@ -0,0 +1,14 @@
+package kntrl.deny["is_denied_hosts"]
+
+import rego.v1
+
+policy if {
+        some host in input.domains
+        endswith(host, data.denied_hosts[_])
+}
+
+hits contains sprintf("denied-hosts=%s", [suffix]) if {
+        some host in input.domains
+        some suffix in data.denied_hosts
+        endswith(host, suffix)
+}
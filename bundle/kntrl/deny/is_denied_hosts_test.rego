@@ -0,0 +1,11 @@
+package kntrl.deny["is_denied_hosts_test"]
+
+import data.kntrl.deny["is_denied_hosts"] as rule
+
+test_denied_domain {
+	rule.policy with input as {"daddr":"104.20.67.143", "domains": ["pastebin.com"]} with data.denied_hosts as ["pastebin.com"]
+}
+
+test_not_denied_domain {
+	not rule.policy with input as {"daddr":"140.82.114.22", "domains": ["github.com"]} with data.denied_hosts as ["pastebin.com"]
+}
@@ -0,0 +1,11 @@
+package kntrl.deny["is_denied_ip"]
+
+import rego.v1
+
+policy if {
+        data.denied_ip_addr[_] == input.daddr
+}
+
+hits contains sprintf("denied-ips=%s", [input.daddr]) if {
+        data.denied_ip_addr[_] == input.daddr
+}
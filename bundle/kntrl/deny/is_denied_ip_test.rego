@@ -0,0 +1,11 @@
+package kntrl.deny["is_denied_ip_test"]
+
+import data.kntrl.deny["is_denied_ip"] as rule
+
+test_denied_ip {
+	rule.policy with input as {"daddr":"45.9.148.3", "domains": ["."]} with data.denied_ip_addr as ["45.9.148.3"]
+}
+
+test_not_denied_ip {
+	not rule.policy with input as {"daddr":"1.1.1.1", "domains": ["."]} with data.denied_ip_addr as ["45.9.148.3"]
+}
@@ -0,0 +1,13 @@
+package kntrl.deny["is_low_reputation"]
+
+import rego.v1
+
+policy if {
+        data.min_reputation > 0
+        input.reputation.score < data.min_reputation
+}
+
+hits contains sprintf("min-reputation=%d", [data.min_reputation]) if {
+        data.min_reputation > 0
+        input.reputation.score < data.min_reputation
+}
@@ -0,0 +1,19 @@
+package kntrl.deny["is_low_reputation_test"]
+
+import data.kntrl.deny["is_low_reputation"] as rule
+
+test_low_reputation {
+	rule.policy with input as {"daddr":"45.9.148.3", "reputation": {"score": 10}} with data.min_reputation as 30
+}
+
+test_reputation_above_threshold {
+	not rule.policy with input as {"daddr":"1.1.1.1", "reputation": {"score": 90}} with data.min_reputation as 30
+}
+
+test_no_reputation {
+	not rule.policy with input as {"daddr":"1.1.1.1"} with data.min_reputation as 30
+}
+
+test_threshold_disabled {
+	not rule.policy with input as {"daddr":"45.9.148.3", "reputation": {"score": 10}} with data.min_reputation as 0
+}
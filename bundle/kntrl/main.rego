@@ -4,7 +4,28 @@ import rego.v1
 
 default policy = false
 
+default denied = false
+
+# the deny list overrides the allow list and the process rules
+denied if data.kntrl.deny[_].policy
+
 #policy if data.kntrl.network[_].policy
 policy if {
+	not denied
+	not data.kntrl.process.denied
+	not data.kntrl.process.restricted
 	data.kntrl.network[_].policy
 }
+
+policy if {
+	not denied
+	not data.kntrl.process.denied
+	data.kntrl.process.allowed
+}
+
+# the rules matching the input, they are counted in the report
+hits contains hit if some hit in data.kntrl.network[_].hits
+
+hits contains hit if some hit in data.kntrl.deny[_].hits
+
+hits contains hit if some hit in data.kntrl.process.hits
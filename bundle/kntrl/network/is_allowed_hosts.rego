@@ -8,3 +8,9 @@ policy if {
         some host in hosts
         endswith(host, data.allowed_hosts[_])
 }
+
+hits contains sprintf("allowed-hosts=%s", [suffix]) if {
+        some host in input.domains
+        some suffix in data.allowed_hosts
+        endswith(host, suffix)
+}
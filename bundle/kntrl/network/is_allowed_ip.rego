@@ -6,3 +6,7 @@ policy if {
         ipaddr := input[_]
         data.allowed_ip_addr[_] == ipaddr
 }
+
+hits contains sprintf("allowed-ips=%s", [input.daddr]) if {
+        data.allowed_ip_addr[_] == input.daddr
+}
@@ -0,0 +1,44 @@
+package kntrl.network["is_allowed_port"]
+
+import rego.v1
+
+# any destination on the port
+policy if {
+        some rule in data.allowed_ports
+        rule.address == ""
+        rule.port == input.dport
+}
+
+policy if {
+        some rule in data.allowed_ports
+        rule.address == input.daddr
+        rule.port == input.dport
+}
+
+policy if {
+        some rule in data.allowed_ports
+        rule.address != ""
+        rule.port == input.dport
+        some host in input.domains
+        endswith(host, rule.address)
+}
+
+hits contains sprintf("allowed-ports=%d", [rule.port]) if {
+        some rule in data.allowed_ports
+        rule.address == ""
+        rule.port == input.dport
+}
+
+hits contains sprintf("allowed-ports=%s:%d", [rule.address, rule.port]) if {
+        some rule in data.allowed_ports
+        rule.address == input.daddr
+        rule.port == input.dport
+}
+
+hits contains sprintf("allowed-ports=%s:%d", [rule.address, rule.port]) if {
+        some rule in data.allowed_ports
+        rule.address != ""
+        rule.port == input.dport
+        some host in input.domains
+        endswith(host, rule.address)
+}
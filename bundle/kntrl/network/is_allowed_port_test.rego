@@ -0,0 +1,21 @@
+package kntrl.network["is_allowed_port_test"]
+
+import data.kntrl.network["is_allowed_port"] as rule
+
+rules := [{"address": "1.2.3.4", "port": 443}, {"address": "example.com", "port": 8443}, {"address": "", "port": 80}]
+
+test_allowed_ip_port {
+	rule.policy with input as {"daddr":"1.2.3.4", "dport": 443, "domains": ["."]} with data.allowed_ports as rules
+}
+
+test_not_allowed_ip_port {
+	not rule.policy with input as {"daddr":"1.2.3.4", "dport": 22, "domains": ["."]} with data.allowed_ports as rules
+}
+
+test_allowed_host_port {
+	rule.policy with input as {"daddr":"93.184.216.34", "dport": 8443, "domains": ["api.example.com"]} with data.allowed_ports as rules
+}
+
+test_allowed_any_port {
+	rule.policy with input as {"daddr":"5.6.7.8", "dport": 80, "domains": ["."]} with data.allowed_ports as rules
+}
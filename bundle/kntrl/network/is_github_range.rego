@@ -3,10 +3,15 @@ package kntrl.network["is_github_range"]
 import rego.v1
 import data.assets.github
 
-ranges := github.actions
+# the ranges fetched from the meta API take precedence over the bundled copy
+ranges := data.github_meta_ranges if {
+	count(data.github_meta_ranges) > 0
+} else := github.actions
 
 policy if {
         ipaddr := input[_]
 	net.cidr_contains(ranges[_], ipaddr)
 	data.allow_github_meta == true
 }
+
+hits contains "allow-github-meta" if policy
@@ -11,3 +11,9 @@ test_allow_github_meta {
 test_deny_allow_github_meta {
 	not rule.policy with input as {"daddr":"1.2.3.4", "domains": ["foo.bar"]}
 }
+
+# test the ranges of the meta API
+test_allow_github_meta_ranges {
+	rule.policy with input as {"daddr":"192.0.2.10", "domains": ["foo.bar"]} with data.github_meta_ranges as ["192.0.2.0/24"] with data.allow_github_meta as true
+	not rule.policy with input as {"daddr":"4.148.0.12", "domains": ["foo.bar"]} with data.github_meta_ranges as ["192.0.2.0/24"] with data.allow_github_meta as true
+}
@@ -8,3 +8,5 @@ policy if {
 	net.cidr_contains(local_ranges[_], ipaddr)
 	data.allow_local_ip_ranges == true
 }
+
+hits contains "allow-local-ranges" if policy
@@ -0,0 +1,55 @@
+package kntrl.process
+
+import rego.v1
+
+# rules are generated from the --process-policy flag
+# e.g. curl:allow:*.github.com => {"process": "curl", "action": "allow", "destination": "*.github.com"}
+rules := data.process_rules
+
+# process rules match the task name (comm), the full executable path
+# or the base name of the executable
+match_process(rule) if rule.process == input.task_name
+
+match_process(rule) if rule.process == input.exe
+
+match_process(rule) if endswith(input.exe, concat("", ["/", rule.process]))
+
+match_destination(rule) if {
+	some domain in input.domains
+	glob.match(rule.destination, null, domain)
+}
+
+match_destination(rule) if glob.match(rule.destination, null, input.daddr)
+
+match_destination(rule) if {
+	contains(rule.destination, "/")
+	net.cidr_contains(rule.destination, input.daddr)
+}
+
+# a process with an allow rule may only reach the allowed destinations
+restricted if {
+	some rule in rules
+	rule.action == "allow"
+	match_process(rule)
+}
+
+allowed if {
+	some rule in rules
+	rule.action == "allow"
+	match_process(rule)
+	match_destination(rule)
+}
+
+denied if {
+	some rule in rules
+	rule.action == "deny"
+	match_process(rule)
+	match_destination(rule)
+}
+
+# the matching rules are counted in the report
+hits contains sprintf("process-policy=%s:%s:%s", [rule.process, rule.action, rule.destination]) if {
+	some rule in rules
+	match_process(rule)
+	match_destination(rule)
+}
@@ -0,0 +1,30 @@
+package kntrl.process_test
+
+import data.kntrl.process
+
+rules := [
+	{"process": "curl", "action": "allow", "destination": "*.github.com"},
+	{"process": "wget", "action": "deny", "destination": "10.0.0.0/8"},
+]
+
+test_allowed_process {
+	process.allowed with input as {"task_name": "curl", "daddr": "140.82.114.22", "domains": ["lb-140-82-114-22-iad.github.com"]}
+		with data.process_rules as rules
+}
+
+test_restricted_process {
+	process.restricted with input as {"task_name": "curl", "daddr": "1.1.1.1", "domains": ["one.one.one.one"]}
+		with data.process_rules as rules
+	not process.allowed with input as {"task_name": "curl", "daddr": "1.1.1.1", "domains": ["one.one.one.one"]}
+		with data.process_rules as rules
+}
+
+test_process_by_exe {
+	process.allowed with input as {"task_name": "curl-wrapper", "exe": "/usr/bin/curl", "daddr": "140.82.114.22", "domains": ["api.github.com"]}
+		with data.process_rules as rules
+}
+
+test_denied_process {
+	process.denied with input as {"task_name": "wget", "daddr": "10.0.0.2", "domains": ["."]}
+		with data.process_rules as rules
+}
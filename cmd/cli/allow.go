@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/allow"
+	"github.com/spf13/cobra"
+)
+
+func initAllowCommand() *cobra.Command {
+	allowCMD := &cobra.Command{
+		Use:   "allow [<address|host>...]",
+		Short: "Manages the allow list of a running kntrl",
+		Long: `Manages the allow list of a running kntrl. With addresses or hosts, they are
+added into the allow list as with kntrl allow add.`,
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				_ = cmd.Help()
+				return
+			}
+			if err := allow.Add(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to update allow list")
+			}
+		},
+	}
+
+	exportCMD := &cobra.Command{
+		Use:   "export",
+		Short: "Writes the allow list of the running kntrl to stdout",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := allow.Export(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to export allow list")
+			}
+		},
+	}
+
+	importCMD := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Adds the entries of an exported allow list into the allow list of the running kntrl",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := allow.Import(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to import allow list")
+			}
+		},
+	}
+
+	addCMD := &cobra.Command{
+		Use:   "add <address|host>...",
+		Short: "Adds the addresses (address or address:port) and the hosts into the allow list of the running kntrl",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := allow.Add(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to update allow list")
+			}
+		},
+	}
+
+	removeCMD := &cobra.Command{
+		Use:   "remove <address|host>...",
+		Short: "Removes the addresses and the hosts added at runtime from the allow list of the running kntrl",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := allow.Remove(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to update allow list")
+			}
+		},
+	}
+
+	addAgentFlags(allowCMD.PersistentFlags(), "control socket of the running kntrl (--control-socket of kntrl run)")
+	allowCMD.AddCommand(exportCMD, importCMD, addCMD, removeCMD)
+
+	return allowCMD
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/handlers/bench"
+	"github.com/spf13/cobra"
+)
+
+func initBenchCommand() *cobra.Command {
+	benchCMD := &cobra.Command{
+		Use:   "bench",
+		Short: "Measures the overhead of kntrl with a synthetic connection load",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			if err := bench.Run(ctx, *cmd); err != nil {
+				qwe(exitCodeError, err, "failed to run benchmark")
+			}
+		},
+	}
+
+	benchCMD.Flags().Duration("duration", 10*time.Second, "duration of the load of each scenario")
+	benchCMD.Flags().Int("workers", 8, "number of the concurrent clients")
+	benchCMD.Flags().StringSlice("modes", []string{"monitor", "trace"}, "modes of kntrl compared with the baseline (monitor, trace)")
+
+	return benchCMD
+}
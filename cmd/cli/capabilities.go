@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/capabilities"
+	"github.com/spf13/cobra"
+)
+
+func initCapabilitiesCommand() *cobra.Command {
+	capabilitiesCMD := &cobra.Command{
+		Use:   "capabilities",
+		Short: "Shows the eBPF features and the modes of kntrl usable on this host",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := capabilities.Run(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to probe the capabilities")
+			}
+		},
+	}
+
+	return capabilitiesCMD
+}
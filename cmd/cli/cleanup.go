@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/cleanup"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/spf13/cobra"
+)
+
+func initCleanupCommand() *cobra.Command {
+	cleanupCMD := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Removes the control socket and the session state left by a killed kntrl",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cleanup.Run(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to clean up")
+			}
+		},
+	}
+
+	cleanupCMD.Flags().String("control-socket", control.DefaultSocket, "control socket of the stopped kntrl, kept if a kntrl listens on it")
+	cleanupCMD.Flags().String("state-file", "", "session state file of the stopped kntrl (--state-file of kntrl run)")
+	cleanupCMD.Flags().Bool("dry-run", false, "show the files to remove without removing them")
+
+	return cleanupCMD
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configEnvPrefix is the prefix of the environment variables of the flags,
+// KNTRL_ALLOWED_HOSTS is --allowed-hosts
+const configEnvPrefix = "KNTRL"
+
+// loadConfig sets the flags of the command not given on the command line
+// from the environment (KNTRL_<FLAG>) and from the configuration file of
+// --config, the keys of the file are the flag names. The command line takes
+// precedence over the environment, the environment over the file. The fixed
+// flags are not read, an unknown key of the file is an error.
+func loadConfig(cmd *cobra.Command, fixed ...string) error {
+	var v = viper.New()
+	v.SetEnvPrefix(configEnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	var flags = cmd.LocalFlags()
+	if path := cmd.Flag("config").Value.String(); path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		for _, key := range v.AllKeys() {
+			f := flags.Lookup(key)
+			if f == nil || f.Name == "config" || f.Name == "help" {
+				return fmt.Errorf("invalid config file [%s]: unknown key %q", path, key)
+			}
+			if slices.Contains(fixed, key) {
+				return fmt.Errorf("invalid config file [%s]: %q is not accepted by kntrl %s", path, key, cmd.Name())
+			}
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || configSkipped(f, fixed) {
+			return
+		}
+
+		_ = v.BindEnv(f.Name)
+		if !v.IsSet(f.Name) {
+			return
+		}
+
+		if setErr := flags.Set(f.Name, configValue(v.Get(f.Name))); setErr != nil {
+			err = fmt.Errorf("invalid config [%s]: %w", f.Name, setErr)
+		}
+	})
+
+	return err
+}
+
+// configSkipped returns true if the flag is not read from the configuration
+func configSkipped(f *pflag.Flag, fixed []string) bool {
+	return f.Name == "config" || f.Name == "help" || slices.Contains(fixed, f.Name)
+}
+
+// configValue returns the value of the flag, the lists are comma-separated
+// as on the command line
+func configValue(value any) string {
+	if list, ok := value.([]any); ok {
+		return strings.Join(cast.ToStringSlice(list), ",")
+	}
+
+	return cast.ToString(value)
+}
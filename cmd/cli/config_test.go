@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "kntrl.yaml")
+	var config = `mode: trace
+allowed-hosts:
+  - .github.com
+  - .npmjs.org
+sink:
+  - syslog://siem.example.com:514
+  - journald://
+max-connection-rate: 50
+fail-on-violation: true
+output-format: json
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KNTRL_MAX_CONNECTION_RATE", "100")
+	t.Setenv("KNTRL_OUTPUT_FILE_NAME", "/tmp/env.out")
+
+	cmd := initTracerCommand()
+	if err := cmd.ParseFlags([]string{"--config", path, "--output-format", "sarif"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(cmd); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"mode":          "trace",
+		"allowed-hosts": ".github.com,.npmjs.org",
+		// the environment takes precedence over the file
+		"max-connection-rate": "100",
+		"output-file-name":    "/tmp/env.out",
+		// the command line over both
+		"output-format":     "sarif",
+		"fail-on-violation": "true",
+	} {
+		if got := cmd.Flag(name).Value.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	if sinks, _ := cmd.Flags().GetStringSlice("sink"); len(sinks) != 2 || sinks[1] != "journald://" {
+		t.Errorf("unexpected sinks: %v", sinks)
+	}
+
+	// the mode of kntrl trace is fixed
+	cmd = initModeCommand("trace")
+	if err := cmd.ParseFlags([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(cmd, "mode"); err == nil {
+		t.Error("expected an error for the mode of kntrl trace")
+	}
+}
+
+func TestLoadConfig_UnknownKey(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "kntrl.yaml")
+	if err := os.WriteFile(path, []byte("allowed-host: .github.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := initTracerCommand()
+	if err := cmd.ParseFlags([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(cmd); err == nil {
+		t.Error("expected an error for the unknown key")
+	}
+}
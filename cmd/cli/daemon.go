@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"os/signal"
+	"syscall"
+
+	"github.com/kondukto-io/kntrl/internal/handlers/daemon"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func initEventsCommand() *cobra.Command {
+	eventsCMD := &cobra.Command{
+		Use:   "events",
+		Short: "Follows the live events of the running kntrl",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			if err := daemon.Events(ctx, *cmd); err != nil {
+				qwe(exitCodeError, err, "failed to follow events")
+			}
+		},
+	}
+
+	addAgentFlags(eventsCMD.Flags(), "control socket of the running kntrl")
+
+	return eventsCMD
+}
+
+func initReportCommand() *cobra.Command {
+	reportCMD := &cobra.Command{
+		Use:   "report",
+		Short: "Writes the report of the running kntrl, list/show/diff the past runs of the report store",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := daemon.Report(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to fetch report")
+			}
+		},
+	}
+
+	addAgentFlags(reportCMD.Flags(), "control socket of the running kntrl")
+	reportCMD.AddCommand(initReportListCommand())
+	reportCMD.AddCommand(initReportShowCommand())
+	reportCMD.AddCommand(initReportDiffCommand())
+
+	return reportCMD
+}
+
+// addAgentFlags adds the flags of the control API clients, the local control
+// socket or a remote agent (--control-addr of kntrl run on a Linux host)
+func addAgentFlags(flags *pflag.FlagSet, socketUsage string) {
+	flags.String("control-socket", control.DefaultSocket, socketUsage)
+	flags.String("agent", "", "address of a remote kntrl agent (https://host:9443), used instead of the control socket")
+	flags.String("agent-token", "", "token of the remote kntrl agent ($KNTRL_AGENT_TOKEN)")
+	flags.String("agent-ca-cert", "", "CA certificate file to verify the remote kntrl agent")
+}
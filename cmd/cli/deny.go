@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/deny"
+	"github.com/spf13/cobra"
+)
+
+func initDenyCommand() *cobra.Command {
+	denyCMD := &cobra.Command{
+		Use:   "deny <address|host>...",
+		Short: "Adds the addresses and the hosts into the deny list of a running kntrl",
+		Long: `Adds the IPv4 addresses and the hosts into the deny list of a running kntrl.
+The hosts are resolved by kntrl, the connections to the denied destinations are
+blocked in all modes, the deny list takes precedence over the allow list.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := deny.Deny(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to update deny list")
+			}
+		},
+	}
+
+	addAgentFlags(denyCMD.Flags(), "control socket of the running kntrl (--control-socket of kntrl run)")
+
+	return denyCMD
+}
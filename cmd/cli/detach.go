@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/detach"
+	"github.com/spf13/cobra"
+)
+
+func initDetachCommand() *cobra.Command {
+	detachCMD := &cobra.Command{
+		Use:   "detach",
+		Short: "Removes the enforcement pinned by kntrl run --pin",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := detach.Run(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to detach")
+			}
+		},
+	}
+
+	detachCMD.Flags().Bool("dry-run", false, "show the pinned objects to remove without removing them")
+
+	return detachCMD
+}
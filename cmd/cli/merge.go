@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/merge"
+	"github.com/spf13/cobra"
+)
+
+func initMergeCommand() *cobra.Command {
+	mergeCMD := &cobra.Command{
+		Use:   "merge [name=]report.json...",
+		Short: "Merges the reports of the jobs of a matrix build into one report",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := merge.Run(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to merge reports")
+			}
+		},
+	}
+
+	mergeCMD.Flags().String("output-file", "-", "merged report file, - is the standard output")
+	mergeCMD.Flags().String("output-format", "json", "output format: json || sarif")
+
+	return mergeCMD
+}
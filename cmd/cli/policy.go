@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/policy"
+	"github.com/spf13/cobra"
+)
+
+func initPolicyCommand() *cobra.Command {
+	policyCMD := &cobra.Command{
+		Use:   "policy",
+		Short: "Manages the policy files of kntrl",
+	}
+
+	checkCMD := &cobra.Command{
+		Use:   "check <file>",
+		Short: "Checks a policy file (--policy-file of kntrl run) and shows its rules",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := policy.Run(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "invalid policy file")
+			}
+		},
+	}
+
+	policyCMD.AddCommand(checkCMD)
+
+	return policyCMD
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/report"
+	"github.com/kondukto-io/kntrl/pkg/runstore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func initReportListCommand() *cobra.Command {
+	listCMD := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the runs of the report store",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := report.List(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to list runs")
+			}
+		},
+	}
+
+	listCMD.Flags().String("store", runstore.DefaultDir, "report store directory (--report-store of kntrl run)")
+
+	return listCMD
+}
+
+func initReportShowCommand() *cobra.Command {
+	showCMD := &cobra.Command{
+		Use:   "show [run]",
+		Short: "Writes the report of a past run, the last run by default",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withJSONFormat(cmd, "output-format")
+			if err := report.Show(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to show run")
+			}
+		},
+	}
+
+	addStoreFlags(showCMD.Flags())
+	showCMD.Flags().String("output-format", "table", "output format: table || json || sarif")
+	showCMD.Flags().Bool("verbose-report", false, "print the first connection event of each destination after the connections aggregated by destination and process")
+
+	return showCMD
+}
+
+func initReportDiffCommand() *cobra.Command {
+	diffCMD := &cobra.Command{
+		Use:   "diff [base-run] [head-run]",
+		Short: "Compares the destinations of two runs, the previous and the last run by default",
+		Args:  cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			withJSONFormat(cmd, "output-format")
+			if err := report.Diff(*cmd, args); err != nil {
+				qwe(exitCodeError, err, "failed to compare runs")
+			}
+		},
+	}
+
+	addStoreFlags(diffCMD.Flags())
+	diffCMD.Flags().String("output-format", "table", "output format: table || json")
+
+	return diffCMD
+}
+
+// addStoreFlags adds the flags of the report store and of the filters of the runs
+func addStoreFlags(flags *pflag.FlagSet) {
+	flags.String("store", runstore.DefaultDir, "report store directory (--report-store of kntrl run)")
+	flags.String("process", "", "only the connections of the process (task name or executable)")
+	flags.String("destination", "", "only the connections to the destination: address || network (CIDR) || domain (.domain for the subdomains), with an optional :port")
+}
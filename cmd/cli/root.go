@@ -4,21 +4,24 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/kondukto-io/kntrl/internal/core/domain"
 	"github.com/kondukto-io/kntrl/pkg/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	exitCodeSuccess = 0
-	exitCodeError   = 1
+	exitCodeSuccess   = 0
+	exitCodeError     = 1
+	exitCodeViolation = 2
 )
 
 var (
-	verbose   bool
-	version   string
-	commit    string
-	buildDate string
+	verbose    bool
+	jsonOutput bool
+	version    string
+	commit     string
+	buildDate  string
 )
 
 var rootCmd = cobra.Command{
@@ -32,11 +35,15 @@ var rootCmd = cobra.Command{
 		}
 
 		logger.SetLevel(logLevel)
+		if jsonOutput {
+			logger.SetJSON()
+		}
 	},
 }
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "more logs")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "machine-readable output: JSON on stdout and JSON logs on stderr")
 
 	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 }
@@ -47,6 +54,20 @@ func Execute(args []string) {
 	rootCmd.SetArgs(args)
 
 	rootCmd.AddCommand(initTracerCommand())
+	rootCmd.AddCommand(initModeCommand(domain.TracerModeMonitor))
+	rootCmd.AddCommand(initModeCommand(domain.TracerModeTrace))
+	rootCmd.AddCommand(initPolicyCommand())
+	rootCmd.AddCommand(initStatusCommand())
+	rootCmd.AddCommand(initCleanupCommand())
+	rootCmd.AddCommand(initDetachCommand())
+	rootCmd.AddCommand(initAllowCommand())
+	rootCmd.AddCommand(initDenyCommand())
+	rootCmd.AddCommand(initDaemonCommand())
+	rootCmd.AddCommand(initEventsCommand())
+	rootCmd.AddCommand(initReportCommand())
+	rootCmd.AddCommand(initBenchCommand())
+	rootCmd.AddCommand(initCapabilitiesCommand())
+	rootCmd.AddCommand(initMergeCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		qwe(exitCodeError, err, "failed to execute root command")
@@ -61,6 +82,14 @@ func versionFormatter(ver, commit, buildDate string) string {
 	return fmt.Sprintf("%s (build date: %s commit: %s)", ver, buildDate, commit)
 }
 
+// withJSONFormat sets the output format flag of the command to json with
+// --json, an output format given explicitly is kept
+func withJSONFormat(cmd *cobra.Command, name string) {
+	if jsonOutput && !cmd.Flags().Changed(name) {
+		_ = cmd.Flags().Set(name, "json")
+	}
+}
+
 // qwe quits with error. If there are messages, wraps error with message
 func qwe(code int, err error, messages ...string) {
 	for _, m := range messages {
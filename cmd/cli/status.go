@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"github.com/kondukto-io/kntrl/internal/handlers/status"
+	"github.com/spf13/cobra"
+)
+
+func initStatusCommand() *cobra.Command {
+	statusCMD := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the mode, the map entries, the attached programs and the events of the running kntrl",
+		Long: `Shows the mode, the allow and deny map entries, the attached programs, the
+event counters and the uptime of the running kntrl, read on its control socket.
+If no kntrl is running, the enforcement pinned by kntrl run --pin is read from
+the BPF filesystem. With --state-file, the allow list entries of the running
+session are shown with why they are allowed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			withJSONFormat(cmd, "output-format")
+			if err := status.Run(*cmd); err != nil {
+				qwe(exitCodeError, err, "failed to show status")
+			}
+		},
+	}
+
+	statusCMD.Flags().String("state-file", "", "show the allow list entries of the session state file of the running kntrl (--state-file of kntrl run)")
+	statusCMD.Flags().String("output-format", "table", "output format: table || json")
+	_ = statusCMD.Flags().MarkDeprecated("output-format", "use --json")
+	addAgentFlags(statusCMD.Flags(), "control socket of the running kntrl (--control-socket of kntrl run)")
+
+	return statusCMD
+}
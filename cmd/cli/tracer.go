@@ -1,30 +1,212 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/kondukto-io/kntrl/internal/handlers/tracer"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/docker"
+	"github.com/kondukto-io/kntrl/pkg/ghmeta"
+	"github.com/kondukto-io/kntrl/pkg/hygiene"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+	"github.com/kondukto-io/kntrl/pkg/runstore"
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
+	"github.com/kondukto-io/kntrl/pkg/verdictcache"
 	"github.com/spf13/cobra"
 )
 
 func initTracerCommand() *cobra.Command {
+	return newTracerCommand("run", "Starts the TCP/UDP tracer", "")
+}
+
+// initModeCommand returns the shorthand of kntrl run --mode=<mode>
+func initModeCommand(mode string) *cobra.Command {
+	return newTracerCommand(mode, fmt.Sprintf("Starts the TCP/UDP tracer in %s mode (kntrl run --mode %s)", mode, mode), mode)
+}
+
+// newTracerCommand returns a command running the tracer, the mode is fixed
+// and the mode flag is hidden if given
+func newTracerCommand(use, short, mode string) *cobra.Command {
 	tracerCMD := &cobra.Command{
-		Use:   "run",
-		Short: "Starts the TCP/UDP tracer",
+		Use:   use + " [-- command]",
+		Short: short,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := tracer.Run(*cmd); err != nil {
+			if mode != "" && cmd.Flags().Changed("mode") {
+				qwe(exitCodeError, fmt.Errorf("[mode] flag is not accepted by kntrl %s, use kntrl run --mode", mode))
+			}
+			var fixed []string
+			if mode != "" {
+				fixed = append(fixed, "mode")
+			}
+			if err := loadConfig(cmd, fixed...); err != nil {
+				qwe(exitCodeError, err)
+			}
+			withJSONFormat(cmd, "output-format")
+
+			// SIGHUP reloads the policy file if one is given
+			var signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT}
+			if cmd.Flag("policy-file").Value.String() == "" {
+				signals = append(signals, syscall.SIGHUP)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), signals...)
+			defer stop()
+
+			if err := tracer.Run(ctx, *cmd); err != nil {
+				var violationErr *tracer.ViolationError
+				if errors.As(err, &violationErr) {
+					qwe(exitCodeViolation, err)
+				}
+				var commandErr *tracer.CommandError
+				if errors.As(err, &commandErr) {
+					qwe(commandErr.ExitCode, err)
+				}
 				qwe(exitCodeError, err, "failed to run tracer")
 			}
 		},
 	}
 
-	tracerCMD.Flags().String("mode", "monitor", "trace || monitor")
-	tracerCMD.Flags().String("hosts", "", "enter ip or hostname (192.168.0.100, example.com, .github.com)")
+	addTracerFlags(tracerCMD, "")
+	if mode != "" {
+		var flag = tracerCMD.Flags().Lookup("mode")
+		_ = flag.Value.Set(mode)
+		flag.DefValue = mode
+		flag.Hidden = true
+	}
+
+	return tracerCMD
+}
+
+func initDaemonCommand() *cobra.Command {
+	daemonCMD := &cobra.Command{
+		Use:   "daemon",
+		Short: "Runs the tracer persistently with the control API (live events, report, allow list) on a unix socket",
+		Run: func(cmd *cobra.Command, args []string) {
+			// a daemon is not stopped by the hangup of the terminal
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+			defer stop()
+
+			if err := loadConfig(cmd); err != nil {
+				qwe(exitCodeError, err)
+			}
+			withJSONFormat(cmd, "output-format")
+			if err := tracer.RunDaemon(ctx, *cmd); err != nil {
+				qwe(exitCodeError, err, "failed to run daemon")
+			}
+		},
+	}
+
+	addTracerFlags(daemonCMD, control.DefaultSocket)
+
+	return daemonCMD
+}
+
+// addTracerFlags adds the flags of the tracer commands (run and daemon)
+func addTracerFlags(tracerCMD *cobra.Command, controlSocket string) {
+	tracerCMD.Flags().String("config", "", "configuration file (kntrl.yaml) of the flags, the keys are the flag names; the flags and $KNTRL_<FLAG> take precedence")
+	tracerCMD.Flags().String("mode", "monitor", "trace || monitor || tofu || simulate")
+	tracerCMD.Flags().Bool("passive", false, "observe the connections only, without attaching the enforcement programs or writing the maps (monitor mode)")
+	tracerCMD.Flags().String("hosts", "", "enter allowed IP addresses or hostnames (192.168.0.100, 2001:db8::1, example.com, .github.com)")
 	tracerCMD.Flags().Bool("allow-local-ranges", true, "allows access to local IP ranges")
 	tracerCMD.Flags().Bool("allow-github-meta", false, "allows access to GitHub meta IP ranges (https://api.github.com/meta)")
+	tracerCMD.Flags().String("github-meta-url", ghmeta.DefaultURL, "meta API of the GitHub meta IP ranges (https://HOSTNAME/api/v3/meta for GitHub Enterprise Server)")
+	tracerCMD.Flags().StringSlice("github-meta-sections", ghmeta.DefaultSections, "sections of the GitHub meta IP ranges (actions, web, api, git, packages, ...)")
+	tracerCMD.Flags().Duration("github-meta-interval", ghmeta.DefaultInterval, "refresh interval of the GitHub meta IP ranges (0 disables)")
+	tracerCMD.Flags().Bool("allow-metadata-endpoints", true, "allows access to the cloud metadata endpoints (169.254.169.254, 168.63.129.16), the connections to them are reported in the metadata category")
+	tracerCMD.Flags().Bool("no-default-allow", false, "don't allow the built-in defaults: the nameservers of /etc/resolv.conf, the loopback and the cloud metadata addresses, and the local IP ranges unless --allow-local-ranges is given")
 	tracerCMD.Flags().String("allowed-hosts", "", "enter allowed hostnames (example.com, .github.com)")
-	tracerCMD.MarkFlagRequired("allowed-hosts")
 	tracerCMD.Flags().String("allowed-ips", "", "enter allowed IP addresses")
-	tracerCMD.MarkFlagRequired("allowed-ips")
-	tracerCMD.Flags().StringP("output-file-name", "o", "/tmp/kntrl.out", "output file name")
+	tracerCMD.Flags().Duration("resolve-interval", 5*time.Minute, "interval of resolving the allowed hosts again, their new addresses are allowed before the first connection (0 disables)")
+	tracerCMD.Flags().StringSlice("allowed-ports", nil, "enter port rules as port, address:port or host:port (443, 1.2.3.4:443, api.example.com:8443)")
+	tracerCMD.Flags().String("denied-hosts", "", "enter denied hostnames, blocked in all modes (pastebin.com, .xmrpool.eu)")
+	tracerCMD.Flags().String("denied-ips", "", "enter denied IP addresses, blocked in all modes")
+	tracerCMD.Flags().String("cgroup-path", "", "scope the enforcement to the cgroup v2 path (system.slice/actions-runner.service), the whole host if empty")
+	tracerCMD.Flags().String("container-id", "", "scope the enforcement to the cgroup of the Docker or containerd container")
+	tracerCMD.Flags().Bool("pin", false, "pin the enforcement under /sys/fs/bpf/kntrl: it stays attached when kntrl stops and a restarted kntrl takes it over without an unfiltered window (kntrl detach removes it)")
+	tracerCMD.Flags().Bool("service-container", false, "run as a service container of the CI job: scope the enforcement to the job containers found through the Docker socket, and write the report, the events and the control socket to --shared-dir")
+	tracerCMD.Flags().String("docker-socket", docker.DefaultSocket, "Docker Engine API socket of the service container mode")
+	tracerCMD.Flags().String("shared-dir", "/kntrl", "directory of the volume shared with the job in the service container mode")
+	tracerCMD.Flags().StringSlice("exclude-comm", nil, "task names never blocked, the deny list included (sshd,chronyd)")
+	tracerCMD.Flags().StringSlice("exclude-cgroup", nil, "cgroup v2 paths never blocked, the deny list included (system.slice/sshd.service)")
+	tracerCMD.Flags().IntSlice("filter-pid", nil, "process ids of the connections not reported, they are enforced")
+	tracerCMD.Flags().StringSlice("filter-comm", nil, "task names of the connections not reported, they are enforced (systemd-resolve,chronyd)")
+	tracerCMD.Flags().StringSlice("ignore-dest", nil, "destinations not reported, they are enforced: address, CIDR or domain name with an optional port (169.254.169.254:80,.ubuntu.com)")
+	tracerCMD.Flags().StringSlice("process-policy", nil, "per-process rules as process:action:destination (curl:allow:*.github.com)")
+	tracerCMD.Flags().String("policy-file", "", "policy file (YAML) merged with the flags, reloaded on change or SIGHUP")
+	tracerCMD.Flags().Bool("repo-policy", false, "merge the repository policy (.kntrl.yaml) in the workspace, signed by a repo-policy-key")
+	tracerCMD.Flags().String("workspace", "", "checked-out repository (defaults to $GITHUB_WORKSPACE or $CI_PROJECT_DIR)")
+	tracerCMD.Flags().StringSlice("repo-policy-key", nil, "approved ed25519 public keys, the repository policy must be signed by one of them")
+	tracerCMD.Flags().StringSlice("repo-policy-scope", nil, "domains the repository policy may allow (example.com)")
+	tracerCMD.Flags().StringP("output-file-name", "o", "/tmp/kntrl.out", "output file name (- for stdout)")
+	tracerCMD.Flags().String("output-format", "table", "report output format: table || json || sarif || access-log")
+	tracerCMD.Flags().Bool("verbose-report", false, "print the first connection event of each destination after the connections aggregated by destination and process in the table report")
+	tracerCMD.Flags().StringSlice("output", nil, "write the report or the events to the output instead of --output-file-name, repeatable: table || json=kntrl.json || sarif=kntrl.sarif || access-log=access.log || syslog://host:514 || https://hooks.example.com/kntrl")
+	tracerCMD.Flags().String("stream-output", "", "write each event in real time to the file (- for stdout), separate from the report")
+	tracerCMD.Flags().String("stream-format", "jsonl", "live event stream format: jsonl")
+	tracerCMD.Flags().String("violation-webhook", "", "post the blocked and the unexpected connections as JSON to the URL (Slack, Teams or a custom endpoint)")
+	tracerCMD.Flags().String("report-store", "", "keep the events and the report of the run in the directory for kntrl report list/show/diff (e.g. "+runstore.DefaultDir+")")
+	tracerCMD.Flags().StringSlice("sink", nil, "send each event to the sink: syslog://host:514 || syslog+tcp://host:514 || journald:// || otlp://collector:4318 || https://hooks.example.com/kntrl (violation webhook)")
 
-	return tracerCMD
+	tracerCMD.Flags().Bool("hash-destinations", false, "replace the destination addresses and hostnames of the sinks, the event stream, the webhook, the metrics and the upload with salted hashes")
+	tracerCMD.Flags().String("hash-salt", "", "salt of the destination hashes, shared by the runners of the organization ($KNTRL_HASH_SALT)")
+
+	tracerCMD.Flags().String("resolver", "", "DNS server of the reverse lookups instead of /etc/resolv.conf: 10.0.0.2:53 || tcp://10.0.0.2:53 || tls://1.1.1.1:853 || https://dns.example.com/dns-query")
+	tracerCMD.Flags().String("enrichment-config", "", "enrichment pipeline configuration file (defaults to the rdns stage)")
+	tracerCMD.Flags().String("time-source", "system", "time source of the report timestamps: system || ntp://host[:port]")
+	tracerCMD.Flags().Bool("report-self", false, "report kntrl's own egress (tagged as self) instead of excluding it")
+	tracerCMD.Flags().Bool("sni", false, "sample the server name (SNI) of the outgoing TLS handshakes on port 443 for the hostname attribution")
+	tracerCMD.Flags().Bool("sandbox-observe", false, "observe the connections of the gVisor and Kata sandboxes at their network boundary, the host probes don't see them")
+	tracerCMD.Flags().Bool("http-host", false, "sample the Host header of the outgoing plaintext HTTP requests on port 80 for the hostname attribution")
+	tracerCMD.Flags().Bool("trace-context", false, "attach the W3C trace context (TRACEPARENT) of the process environment to the events")
+	tracerCMD.Flags().String("control-socket", controlSocket, "serve the control API (live events, report, allow list) on the unix socket ("+control.DefaultSocket+")")
+	tracerCMD.Flags().String("control-addr", "", "serve the control API on the TCP address (:9443) for the remote clients (--agent)")
+	tracerCMD.Flags().String("control-token", "", "bearer token of the control API on the TCP address ($KNTRL_CONTROL_TOKEN)")
+	tracerCMD.Flags().String("control-tls-cert", "", "TLS certificate file of the control API on the TCP address")
+	tracerCMD.Flags().String("control-tls-key", "", "TLS key file of the control API on the TCP address")
+	tracerCMD.Flags().String("nat64-prefix", nat64.Auto, "NAT64 prefix (/96) of the network, its IPv6 connections are handled as IPv4: auto (RFC 7050 discovery, 64:ff9b::/96 if not found) || none || <prefix>")
+	tracerCMD.Flags().String("map-overflow", ktracer.OverflowRejectNew, "strategy when the allow or deny map is full (reject-new, evict-lru or switch-to-monitor)")
+	tracerCMD.Flags().Int("min-reputation", 0, "block the destinations with a lower reputation score (0-100) in all modes, requires the reputation enrichment stage (0 disables)")
+	tracerCMD.Flags().String("block-action", ktracer.BlockActionDrop, "action on the connections out of the policy in trace mode: drop || tarpit (delay the packets instead of dropping them)")
+	tracerCMD.Flags().Bool("kill-on-violation", false, "kill the processes connecting to the destinations blocked by the kernel (trace mode)")
+	tracerCMD.Flags().Duration("tarpit-delay", ktracer.DefaultTarpitDelay, "delay of the packets of the tarpit destinations (at most 10s)")
+	tracerCMD.Flags().Bool("file-access", false, "trace the reads of the sensitive files and report the processes connecting after a read (credential read then egress)")
+	tracerCMD.Flags().StringSlice("sensitive-files", ktracer.DefaultSensitiveFiles, "files traced by --file-access as name or dir/name (.npmrc, .aws/credentials)")
+	tracerCMD.Flags().Duration("file-access-window", ktracer.DefaultFileAccessWindow, "time a read of a sensitive file is correlated with the connections of the process")
+	tracerCMD.Flags().Int("max-connection-rate", 0, "maximum connections to a destination per minute, the connections over it are reported as anomalies (0 disables)")
+	tracerCMD.Flags().Int("max-destinations", 0, "maximum destinations of a process, the connections to the other destinations are reported as anomalies (0 disables)")
+	tracerCMD.Flags().String("anomaly-action", ktracer.AnomalyActionReport, "action on the anomalies: report || block (deny the destination, except in passive mode)")
+	tracerCMD.Flags().Int("escalate-findings", 0, "number of the high-severity findings in the escalation window switching the monitor mode to the trace mode (0 disables)")
+	tracerCMD.Flags().Duration("escalate-window", ktracer.DefaultEscalateWindow, "window of the findings of the escalation")
+	tracerCMD.Flags().String("cert-fingerprints", "", "file of the certificates of the allowed TLS destinations over the runs, a public key not seen before is reported as a finding")
+	tracerCMD.Flags().String("capture-violations", "", "directory of the pcap files of the first packets of the violating flows (not available in passive mode)")
+	tracerCMD.Flags().Int("capture-packets", ktracer.DefaultCapturePackets, "number of the packets captured per violating flow")
+	tracerCMD.Flags().Duration("slow-threshold", 500*time.Millisecond, "latency of a slow component of the event pipeline (enrichment, policy, report), reported as a diagnostic")
+	tracerCMD.Flags().String("metrics-addr", "", "expose Prometheus metrics on the given address (:9090)")
+	tracerCMD.Flags().Duration("map-stats-interval", ktracer.DefaultMapStatsInterval, "interval of the samples of the eBPF map entries written in the metrics and the report (0 disables)")
+	tracerCMD.Flags().Bool("program-stats", false, "count the runs and the runtime of the eBPF programs in the map samples (Linux 5.8+, slows the programs down)")
+	tracerCMD.Flags().String("state-file", "", "persist the session state to resume the report after a restart")
+	tracerCMD.Flags().Duration("state-interval", 30*time.Second, "session state save interval")
+	tracerCMD.Flags().String("tofu-store", "/tmp/kntrl.tofu.json", "trusted destinations of the tofu mode")
+	tracerCMD.Flags().String("hygiene-store", "", "rule hit history file, the rules without a hit in the last runs are reported as stale")
+	tracerCMD.Flags().Int("hygiene-runs", hygiene.DefaultRuns, "number of the runs without a hit of a stale rule")
+	tracerCMD.Flags().String("verdict-cache", "", "file or http(s) URL of the verdict cache, the destinations allowed by their domain names are exported at the end and imported by the next job")
+	tracerCMD.Flags().Duration("verdict-cache-max-age", verdictcache.DefaultMaxAge, "lifetime of the cached verdicts")
+	tracerCMD.Flags().String("user", "", "user[:group] the command given after -- runs as (defaults to $SUDO_UID:$SUDO_GID), without the capabilities of kntrl")
+	tracerCMD.Flags().Duration("duration", 0, "stops kntrl after the duration (30m), the report is written as on a signal (0 runs until a signal)")
+	tracerCMD.Flags().Bool("fail-on-violation", false, "exit with code 2 if blocked egress events occur")
+	tracerCMD.Flags().Int("fail-threshold", 1, "number of blocked egress events to fail on (implies --fail-on-violation)")
+	tracerCMD.Flags().String("kondukto-url", "", "upload the report to the Kondukto platform when kntrl stops ($KONDUKTO_HOST)")
+	tracerCMD.Flags().String("kondukto-token", "", "Kondukto API token ($KONDUKTO_TOKEN)")
+	tracerCMD.Flags().String("project", "", "Kondukto project of the report ($KONDUKTO_PROJECT)")
+	tracerCMD.Flags().Bool("kondukto-insecure", false, "skip the TLS certificate verification of the Kondukto platform")
+	tracerCMD.Flags().String("kondukto-ca-cert", "", "CA certificate file to verify the Kondukto platform")
+	tracerCMD.Flags().Int("kondukto-retries", 3, "number of retries of a failed report upload")
+	tracerCMD.Flags().Bool("github-comment", false, "post the report summary as a comment of the pull request when kntrl stops (GitHub Actions)")
+	tracerCMD.Flags().String("github-token", "", "GitHub token of the pull request comment ($GITHUB_TOKEN)")
+	tracerCMD.Flags().String("session-id", "", "session id to resume (defaults to the CI job id)")
 }
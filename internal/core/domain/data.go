@@ -14,6 +14,70 @@ type Data struct {
 	// with Rego policies.
 	// You can find the full meta list here: https://api.github.com/meta.
 	AllowGithubMeta bool `json:"allow_github_meta"`
+	// The ranges fetched from the meta API, the ranges of the actions
+	// runners of the policy bundle are used if empty.
+	GithubMetaRanges []string `json:"github_meta_ranges,omitempty"`
 	// Allow local IP addresses.
 	AllowLocalIPRanges bool `json:"allow_local_ip_ranges"`
+	// Process rules restrict the destinations of the given processes.
+	ProcessRules []ProcessRule `json:"process_rules"`
+	// Port rules allow the destinations on the given ports only.
+	AllowedPorts []PortRule `json:"allowed_ports"`
+	// The denied hosts and IPs are blocked in all modes.
+	DeniedHosts []string `json:"denied_hosts"`
+	DeniedIPs   []net.IP `json:"denied_ip_addr"`
+	// The destinations with a lower reputation score are blocked in all modes, disabled if 0.
+	MinReputation int `json:"min_reputation,omitempty"`
+	// The provenance of the allowed IPs, not used by the policy.
+	AllowedSources []AllowEntry `json:"-"`
+}
+
+// ProcessRule represents a per-process policy rule (process:action:destination).
+// Process is matched against the task name (comm) or the executable path and
+// destination is a glob pattern of the domain name, an IP address or a CIDR.
+type ProcessRule struct {
+	Process     string `json:"process"`
+	Action      string `json:"action"`
+	Destination string `json:"destination"`
+}
+
+// PortRule represents a port-based egress rule (address:port or port).
+// Address is an IP address or a host suffix, empty for any destination.
+type PortRule struct {
+	Address string `json:"address"`
+	Port    uint16 `json:"port"`
+}
+
+const (
+	// ProcessRuleActionAllow is the allow action of the process rule
+	ProcessRuleActionAllow = "allow"
+
+	// ProcessRuleActionDeny is the deny action of the process rule
+	ProcessRuleActionDeny = "deny"
+)
+
+// RepoPolicy represents the repository level policy (.kntrl.yaml) in the workspace.
+// It is merged under the policy given by the flags, see the workspace package for
+// the restrictions.
+type RepoPolicy struct {
+	AllowedHosts     []string `yaml:"allowed-hosts"`
+	AllowedIPs       []string `yaml:"allowed-ips"`
+	AllowLocalRanges *bool    `yaml:"allow-local-ranges"`
+	AllowGithubMeta  *bool    `yaml:"allow-github-meta"`
+	ProcessPolicy    []string `yaml:"process-policy"`
+	// AllowMetadataEndpoints may disable the cloud metadata endpoints
+	AllowMetadataEndpoints *bool `yaml:"allow-metadata-endpoints"`
+}
+
+// PolicyFile represents the policy file given by --policy-file. It is merged
+// with the policy given by the flags and reloaded when the file changes.
+type PolicyFile struct {
+	// Hosts are the allowed IP addresses and hostnames (--hosts)
+	Hosts         []string `yaml:"hosts"`
+	AllowedHosts  []string `yaml:"allowed-hosts"`
+	AllowedIPs    []string `yaml:"allowed-ips"`
+	AllowedPorts  []string `yaml:"allowed-ports"`
+	DeniedHosts   []string `yaml:"denied-hosts"`
+	DeniedIPs     []string `yaml:"denied-ips"`
+	ProcessPolicy []string `yaml:"process-policy"`
 }
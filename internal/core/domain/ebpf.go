@@ -6,11 +6,80 @@ const EBPFCollectionMapMode = "mode_map"
 // EBPFCollectionMapAllowedIP is the allow list of the EBPF collection map
 const EBPFCollectionMapAllowedIP = "allowed_ip_map"
 
-// EBPFCollectionMapAllowedHost is the allow list of the EBPF collection map
-const EBPFCollectionMapAllowedHost = "allowed_host_map"
+// EBPFCollectionMapAllowedHost is the allowed hostnames of the EBPF collection map
+const EBPFCollectionMapAllowedHost = "allowed_hosts_map"
+
+// EBPFCollectionMapAllowedPort is the allow list of the (IP, port) pairs of the EBPF collection map
+const EBPFCollectionMapAllowedPort = "allowed_port_map"
+
+// EBPFCollectionMapAllowedRange is the allowed IPv4 ranges (longest prefix match) of the EBPF collection map
+const EBPFCollectionMapAllowedRange = "allowed_range_map"
+
+// EBPFCollectionMapDeny is the deny list of the EBPF collection map
+const EBPFCollectionMapDeny = "deny_map"
+
+// EBPFCollectionMapKillConfig is the kill on violation config of the EBPF collection map
+const EBPFCollectionMapKillConfig = "kill_config_map"
+
+// EBPFCollectionMapTarpit is the delayed (soft blocked) destinations of the EBPF collection map
+const EBPFCollectionMapTarpit = "tarpit_map"
 
 // EBPFCollectionMapIPV4Events is the IPv4 events of the EBPF collection map
 const EBPFCollectionMapIPV4Events = "ipv4_events"
 
 // EBPFCollectionMapIPV4ClosedEvents is the IPv4 closed events of the EBPF collection map
 const EBPFCollectionMapIPV4ClosedEvents = "ipv4_closed_events"
+
+// EBPFCollectionMapExecEvents is the process exec events of the EBPF collection map
+const EBPFCollectionMapExecEvents = "exec_events"
+
+// EBPFCollectionMapFileEvents is the sensitive file read events of the EBPF collection map
+const EBPFCollectionMapFileEvents = "file_events"
+
+// EBPFCollectionMapListenEvents is the listening socket events of the EBPF collection map
+const EBPFCollectionMapListenEvents = "listen_events"
+
+// EBPFCollectionMapDNSEvents is the DNS response events of the EBPF collection map
+const EBPFCollectionMapDNSEvents = "dns_events"
+
+// EBPFCollectionMapCaptureEvents is the captured packets of the violating flows of the EBPF collection map
+const EBPFCollectionMapCaptureEvents = "capture_events"
+
+// EBPFCollectionMapCaptureConfig is the capture of the violating flows config of the EBPF collection map
+const EBPFCollectionMapCaptureConfig = "capture_config_map"
+
+// EBPFCollectionMapCaptureFlows is the remaining packets to capture of the violating flows of the EBPF collection map
+const EBPFCollectionMapCaptureFlows = "capture_flows_map"
+
+// EBPFCollectionMapSensitiveFiles is the names of the sensitive files of the EBPF collection map
+const EBPFCollectionMapSensitiveFiles = "sensitive_files_map"
+
+// EBPFCollectionMapExcludedComm is the excluded task names of the EBPF collection map
+const EBPFCollectionMapExcludedComm = "excluded_comm_map"
+
+// EBPFCollectionMapSelf is kntrl itself of the EBPF collection map
+const EBPFCollectionMapSelf = "self_map"
+
+// EBPFCollectionMapScopeConfig is the enforcement scope config of the EBPF collection map
+const EBPFCollectionMapScopeConfig = "scope_config_map"
+
+// EBPFCollectionMapScopeCgroup is the cgroup ids of the enforcement scope of the EBPF collection map
+const EBPFCollectionMapScopeCgroup = "scope_cgroup_map"
+
+// EBPFCollectionMapFilteredComm is the filtered task names of the EBPF collection map
+const EBPFCollectionMapFilteredComm = "filtered_comm_map"
+
+// EBPFCollectionMapFilteredPID is the filtered process ids of the EBPF collection map
+const EBPFCollectionMapFilteredPID = "filtered_pid_map"
+
+// EBPFCollectionMapIgnoredDest is the ignored destinations of the EBPF collection map
+const EBPFCollectionMapIgnoredDest = "ignored_dest_map"
+
+// EBPFCollectionMapExcludedCgroup is the excluded cgroup ids of the EBPF collection map
+const EBPFCollectionMapExcludedCgroup = "excluded_cgroup_map"
+
+// EBPFCollectionMapNAT64Prefix is the NAT64 prefix of the EBPF collection map
+const EBPFCollectionMapNAT64Prefix = "nat64_prefix_map"
+
+// EBPFCollectionMapTraffic is the traffic counters of the destinations of the EBPF collection map
+const EBPFCollectionMapTraffic = "traffic_map"
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 // Event is a common event interface
 type Event struct {
 	TsUs  uint64   //
@@ -12,21 +14,514 @@ type Event struct {
 // IP4Event represents a socket connect event from AF_INET(4)
 type IP4Event struct {
 	Event
-	Daddr uint32 // Destination address
-	Dport uint16 // Destination port
+	Daddr [4]byte // Destination address (network byte order)
+	Dport uint16  // Destination port
 	// Saddr uint32
 	// Sport uint16
+	Cookie uint64 // socket cookie, 0 if the connect probe is a kprobe
+	Ppid   uint32 // parent process id
+	Uid    uint32 // user id of the process
+	Gid    uint32 // group id of the process
+	Cgroup uint64 // cgroup (v2) id of the process
+	NetNS  uint32 // inode of the network namespace of the process
+	// Verdict is the verdict of the kernel at the connect call, one of the
+	// KernelVerdict constants
+	Verdict uint8
+	// Killed is 1 if the process is killed for the connection (kill on violation)
+	Killed uint8
+}
+
+const (
+	// KernelVerdictBlocked is the verdict of the connection dropped by the kernel
+	KernelVerdictBlocked uint8 = iota
+	// KernelVerdictAllowed is the verdict of the connection passed by the kernel
+	KernelVerdictAllowed
+	// KernelVerdictDelayed is the verdict of the connection delayed by the kernel (tarpit)
+	KernelVerdictDelayed
+)
+
+// IP4CloseEvent represents the close of a TCP connection from AF_INET(4)
+type IP4CloseEvent struct {
+	TsUs   uint64  //
+	Cookie uint64  // socket cookie of the connect event
+	Daddr  [4]byte // Destination address (network byte order)
+	Dport  uint16  // Destination port
+	State  uint8   // the TCP state before the close
+}
+
+// ExecEvent represents a process execution event (sched_process_exec)
+type ExecEvent struct {
+	TsUs uint64    //
+	Pid  uint32    // process id
+	Ppid uint32    // parent process id
+	Task [16]byte  // task name
+	Path [128]byte // executable path
+	Args [128]byte // NULL separated arguments
+}
+
+// FileEvent represents a read of a sensitive file (security_file_open)
+type FileEvent struct {
+	TsUs   uint64   //
+	Pid    uint32   // process id
+	Ppid   uint32   // parent process id
+	Task   [16]byte // task name
+	Name   [32]byte // file name
+	Parent [32]byte // name of the parent directory
+}
+
+// ListenEvent represents a listening TCP socket (inet_listen) or a UDP socket
+// bound to a port (inet_bind)
+type ListenEvent struct {
+	TsUs  uint64   //
+	Pid   uint32   // process id
+	Ppid  uint32   // parent process id
+	Task  [16]byte // task name
+	Addr  [16]byte // local address, the IPv4 address in the first 4 bytes
+	Port  uint16   // local port
+	Af    uint8    // AF_INET or AF_INET6
+	Proto uint8    // IPPROTO_TCP or IPPROTO_UDP
+}
+
+// DNSEvent represents a DNS response received by a process (skb_consume_udp)
+type DNSEvent struct {
+	TsUs     uint64    //
+	Pid      uint32    // process id
+	Task     [16]byte  // task name
+	Resolver [4]byte   // source address of the response (network byte order)
+	Answers  uint16    // number of the answers
+	Rcode    uint8     // response code
+	Question [260]byte // the name (wire format), the type and the class
+}
+
+// CaptureEvent represents a captured packet of a violating flow, the packet
+// follows the event in the sample
+type CaptureEvent struct {
+	Daddr   [4]byte // destination address (network byte order)
+	Dport   uint16  // destination port, 0 for the protocols without ports
+	Proto   uint8   // protocol of the packet
+	Blocked uint8   // 1 if the packet is dropped by the kernel
+	Len     uint32  // length of the packet
+	CapLen  uint32  // length of the captured packet
 }
 
 // ReportEvent represents a report event
 type ReportEvent struct {
-	ProcessID          uint32   `json:"pid"`
+	ProcessID          uint32    `json:"pid"`
+	TaskName           string    `json:"task_name"`
+	Executable         string    `json:"exe,omitempty"`
+	Args               []string  `json:"args,omitempty"`
+	Protocol           string    `json:"proto"`
+	DestinationAddress string    `json:"daddr"`
+	DestinationPort    uint16    `json:"dport"`
+	Domains            []string  `json:"domains"`
+	Policy             string    `json:"policy"`
+	Verdict            string    `json:"verdict,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
+	// ServerName is the server name (SNI) of the TLS handshake or the Host
+	// header of the plaintext HTTP request to the destination
+	ServerName string `json:"server_name,omitempty"`
+	// Self is true if the connection is made by kntrl itself
+	Self bool `json:"self,omitempty"`
+	// Excluded is true if the process is excluded from the enforcement (--exclude-comm, --exclude-cgroup)
+	Excluded bool `json:"excluded,omitempty"`
+	// WouldBlock is true if the connection passed in a monitor mode would be
+	// blocked by the policy in the trace mode, or if the connection out of the
+	// process rules passed the kernel in the trace mode (the address is
+	// allowed for another process)
+	WouldBlock bool `json:"would_block,omitempty"`
+	// Sandbox is the runtime (gvisor, kata) of the sandbox of a connection observed
+	// at the network boundary of the sandbox, the connection is not enforced
+	Sandbox string `json:"sandbox,omitempty"`
+	// NAT64 is the IPv6 address of a connection through NAT64, the destination
+	// address is the IPv4 address embedded in it
+	NAT64 string `json:"nat64,omitempty"`
+	// TraceID and SpanID are the W3C trace context (TRACEPARENT) of the process
+	// environment, the span of the pipeline step that made the connection
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+	// BytesSent and BytesReceived are the traffic of the destination (of all the
+	// processes), counted until the report is written
+	BytesSent     uint64 `json:"bytes_sent,omitempty"`
+	BytesReceived uint64 `json:"bytes_received,omitempty"`
+	// Cookie is the socket cookie of a TCP connection, correlates the close event
+	Cookie uint64 `json:"-"`
+	// DurationMs and State are set when the TCP connection is closed, the
+	// state is the last TCP state before the close (SYN_SENT for a failed connection)
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	State      string `json:"state,omitempty"`
+	// Fields added by the enrichment stages
+	Container string            `json:"container,omitempty"`
+	Country   string            `json:"country,omitempty"`
+	ASN       uint32            `json:"asn,omitempty"`
+	ASOrg     string            `json:"as_org,omitempty"`
+	Category  string            `json:"category,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Shard is the name of the report of the event in a merged report
+	Shard string `json:"shard,omitempty"`
+	// Parents is the parent chain of the process, the closest parent first
+	// (npm, sh, Runner.Worker)
+	Parents []ProcessAncestor `json:"parents,omitempty"`
+	// Identity is the user and the namespaces of the process, attributes the
+	// connection on a runner shared by users or containers
+	Identity *ProcessIdentity `json:"identity,omitempty"`
+	// Reputation is the score of the destination (reputation stage)
+	Reputation *Reputation `json:"reputation,omitempty"`
+	// Killed is true if the process is killed by the kernel for the connection
+	// (--kill-on-violation)
+	Killed bool `json:"killed,omitempty"`
+	// CredentialReads are the sensitive files read by the process shortly
+	// before the connection (--file-access)
+	CredentialReads []string `json:"credential_reads,omitempty"`
+	// Denied is true if the destination is in the deny list or below the
+	// minimum reputation, blocked in all the modes but the passive mode
+	Denied bool `json:"denied,omitempty"`
+	// Anomaly is the FindingKind of the anomaly threshold exceeded by the
+	// connection (--max-connection-rate, --max-destinations)
+	Anomaly string `json:"anomaly,omitempty"`
+	// Capture is the pcap file of the packets of the connection, if it's a
+	// violation (--capture-violations)
+	Capture string `json:"capture,omitempty"`
+}
+
+// Reputation represents the reputation of a destination, the score is 100
+// minus the penalties of the signals (feed:<entry>, asn:AS<number>,
+// domain-age:<days>d, first-seen), at least 0
+type Reputation struct {
+	Score   int      `json:"score"`
+	Signals []string `json:"signals,omitempty"`
+}
+
+// ProcessIdentity represents the user and the namespaces of the process of an event
+type ProcessIdentity struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+	// User is the name of the user on the host, empty if unknown
+	User string `json:"user,omitempty"`
+	// CgroupID is the id (inode) of the cgroup v2 of the process
+	CgroupID uint64 `json:"cgroup_id"`
+	// NetNS is the inode of the network namespace of the process (net:[inode])
+	NetNS uint32 `json:"netns"`
+}
+
+// ProcessAncestor represents a parent process of the process of an event
+type ProcessAncestor struct {
+	ProcessID  uint32   `json:"pid"`
+	TaskName   string   `json:"task_name"`
+	Executable string   `json:"exe,omitempty"`
+	Args       []string `json:"args,omitempty"`
+}
+
+// Report represents the machine-readable final report
+type Report struct {
+	StartedAt   time.Time     `json:"started_at"`
+	FinishedAt  time.Time     `json:"finished_at"`
+	Mode        string        `json:"mode"`
+	Clock       *ClockInfo    `json:"clock,omitempty"`
+	Summary     ReportSummary `json:"summary"`
+	Events      []ReportEvent `json:"events"`
+	Allowed     []AllowEntry  `json:"allowed,omitempty"`
+	Diagnostics []Diagnostic  `json:"diagnostics,omitempty"`
+	Rules       []RuleHit     `json:"rules,omitempty"`
+	// Hygiene are the rules without a hit in the last runs, see StaleRule
+	Hygiene []StaleRule `json:"hygiene,omitempty"`
+	// Timeline are the connections of each minute, see TimeBucket
+	Timeline []TimeBucket `json:"timeline,omitempty"`
+	// Destinations are the connections aggregated by the destination and the
+	// process, see DestinationSummary
+	Destinations []DestinationSummary `json:"destinations,omitempty"`
+	// Shards are the reports of a merged report (kntrl merge)
+	Shards []ReportShard `json:"shards,omitempty"`
+	// Findings are the suspicious sequences of the run, see Finding
+	Findings []Finding `json:"findings,omitempty"`
+	// Listeners are the sockets listening on a non-loopback address, see Listener
+	Listeners []Listener `json:"listeners,omitempty"`
+	// DNS are the names looked up during the run, see DNSQuery
+	DNS []DNSQuery `json:"dns,omitempty"`
+	// Maps are the usage of the eBPF maps, see MapStats
+	Maps []MapStats `json:"maps,omitempty"`
+	// Programs are the runs of the eBPF programs, see ProgramStats
+	Programs []ProgramStats `json:"programs,omitempty"`
+	// Suggestions are the policy additions allowing the destinations blocked
+	// or out of the policy, see PolicySuggestion
+	Suggestions []PolicySuggestion `json:"suggestions,omitempty"`
+	// Escalation is the switch of the monitor mode to the trace mode, see Escalation
+	Escalation *Escalation `json:"escalation,omitempty"`
+}
+
+// DestinationSummary represents the connections of a process to a destination
+// over the run. The events of the report are the first connection of each
+// destination, the summaries count all of them.
+type DestinationSummary struct {
 	TaskName           string   `json:"task_name"`
+	Executable         string   `json:"exe,omitempty"`
 	Protocol           string   `json:"proto"`
 	DestinationAddress string   `json:"daddr"`
 	DestinationPort    uint16   `json:"dport"`
-	Domains            []string `json:"domains"`
-	Policy             string   `json:"policy"`
+	Domains            []string `json:"domains,omitempty"`
+	// Country and the autonomous system of the destination, added by the
+	// geoip and asn enrichment stages
+	Country string `json:"country,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+	// Connections is the number of the connections, Blocked and WouldBlock
+	// are the number of the ones blocked by the policy and of the ones the
+	// trace mode would block
+	Connections int `json:"connections"`
+	Blocked     int `json:"blocked"`
+	WouldBlock  int `json:"would_block,omitempty"`
+	// Verdicts are the number of the connections of each verdict of the
+	// kernel (ALLOWED, BLOCKED, DELAYED), Killed is the number of the
+	// connections the process of which was killed
+	Verdicts  map[string]int `json:"verdicts,omitempty"`
+	Killed    int            `json:"killed,omitempty"`
+	FirstSeen time.Time      `json:"first_seen"`
+	LastSeen  time.Time      `json:"last_seen"`
+	// Policy is the policy status of the last connection
+	Policy string `json:"policy"`
+}
+
+// Escalation represents the switch of the enforcement from the monitor mode
+// to the trace mode on the high-severity findings of a window
+type Escalation struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Findings are the high-severity findings of the window
+	Findings  []Finding `json:"findings"`
+	Timestamp time.Time `json:"ts"`
+}
+
+// PolicySuggestion represents an entry of the policy file allowing the
+// destinations of the connections blocked or out of the policy during the run
+type PolicySuggestion struct {
+	// Field is the field of the policy file (allowed-hosts, allowed-ips, allowed-ports)
+	Field string `json:"field"`
+	Value string `json:"value"`
+	// Range is the network (CIDR) of the addresses suggested together, the
+	// policy file takes the addresses only
+	Range       string   `json:"range,omitempty"`
+	Connections int      `json:"connections"`
+	Tasks       []string `json:"tasks"`
+	// Labels are inferred from the enrichment of the destinations (category,
+	// as_org, country)
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// DNSQuery represents the lookups of a name of a type through a resolver,
+// the names looked up without a connection are reported too
+type DNSQuery struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Resolver string `json:"resolver"`
+	// Rcode is the response code of the last response (NOERROR, NXDOMAIN, ...)
+	Rcode string `json:"rcode"`
+	// Answers is the number of the answers of the last response
+	Answers int `json:"answers"`
+	Count   int `json:"count"`
+	// Tasks are the names of the tasks looking up the name
+	Tasks     []string  `json:"tasks"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// MapStats represents the usage of an eBPF map over the run, sampled every
+// map stats interval
+type MapStats struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	MaxEntries uint32 `json:"max_entries"`
+	// Entries is the number of the entries of the last sample
+	Entries int `json:"entries"`
+	// Peak is the highest number of the entries of the samples
+	Peak int `json:"peak"`
+	// Pressure is the peak over the max entries (0-1), a map near 1 rejects
+	// or evicts the entries (see the map overflow strategy)
+	Pressure float64 `json:"pressure"`
+}
+
+// ProgramStats represents the runs of an eBPF program, each run looks up the
+// maps of the program. The kernel counts the runs if the stats are enabled.
+type ProgramStats struct {
+	Name string `json:"name"`
+	Runs uint64 `json:"runs"`
+	// RuntimeNs is the total runtime of the runs
+	RuntimeNs uint64 `json:"runtime_ns"`
+}
+
+// Listener represents a socket of a process listening on a non-loopback
+// address (TCP) or bound to a port of a non-loopback address (UDP)
+type Listener struct {
+	ProcessID  uint32 `json:"pid"`
+	TaskName   string `json:"task_name"`
+	Executable string `json:"exe,omitempty"`
+	Protocol   string `json:"proto"`
+	// Address is the local address, the unspecified address (0.0.0.0, ::) listens on all the interfaces
+	Address   string    `json:"addr"`
+	Port      uint16    `json:"port"`
+	Timestamp time.Time `json:"ts"`
+}
+
+// Finding represents a suspicious sequence of the events of a process, a
+// connection after a read of a sensitive file, the connections over an
+// anomaly threshold or a changed certificate of a destination
+type Finding struct {
+	// Kind is one of the FindingKind constants
+	Kind       string `json:"kind"`
+	ProcessID  uint32 `json:"pid"`
+	TaskName   string `json:"task_name"`
+	Executable string `json:"exe,omitempty"`
+	// Files are the sensitive files read before the connection
+	Files []string `json:"files,omitempty"`
+	// Detail is the threshold exceeded by an anomaly, the change of a certificate
+	Detail             string    `json:"detail,omitempty"`
+	DestinationAddress string    `json:"daddr"`
+	DestinationPort    uint16    `json:"dport"`
+	Domains            []string  `json:"domains,omitempty"`
+	Policy             string    `json:"policy"`
+	Timestamp          time.Time `json:"ts"`
+}
+
+// FindingKindCredentialEgress is the finding of a connection of a process
+// after it read a sensitive file (credential read then egress)
+const FindingKindCredentialEgress = "credential-read-egress"
+
+const (
+	// FindingKindConnectionRate is the anomaly of the connections to a
+	// destination over the maximum rate (beaconing, flooding)
+	FindingKindConnectionRate = "connection-rate"
+	// FindingKindDestinationFanout is the anomaly of a process connecting to
+	// more destinations than the maximum (scanning)
+	FindingKindDestinationFanout = "destination-fanout"
+)
+
+// FindingKindCertificateChange is the finding of an allowed TLS destination
+// presenting a public key not seen in the previous runs (MITM, hijack)
+const FindingKindCertificateChange = "certificate-change"
+
+// ReportShard represents a report of a merged report, a job of a matrix build
+type ReportShard struct {
+	Name       string        `json:"name"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Mode       string        `json:"mode"`
+	Summary    ReportSummary `json:"summary"`
+}
+
+// RunInfo represents a run kept in the report store (--report-store)
+type RunInfo struct {
+	ID         string        `json:"id"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Mode       string        `json:"mode,omitempty"`
+	Summary    ReportSummary `json:"summary"`
+	// Complete is false if the run was interrupted before its report was
+	// written, its report is rebuilt from the events
+	Complete bool `json:"complete"`
+}
+
+// RunDiff represents the destinations of a run (head) compared to a previous
+// run (base), a destination is its domain name (or address), its port and
+// its protocol
+type RunDiff struct {
+	Base    string         `json:"base"`
+	Head    string         `json:"head"`
+	Added   []RunDiffEntry `json:"added"`
+	Removed []RunDiffEntry `json:"removed"`
+	// Changed are the destinations of both runs with another policy
+	Changed []RunDiffEntry `json:"changed"`
+}
+
+// RunDiffEntry represents a destination of a RunDiff
+type RunDiffEntry struct {
+	Destination string   `json:"destination"`
+	Port        uint16   `json:"port"`
+	Protocol    string   `json:"proto"`
+	Addresses   []string `json:"addresses"`
+	Tasks       []string `json:"tasks"`
+	// Policy is the most restrictive policy of the connections of the
+	// destination (block, tarpit, pass), BasePolicy its policy in the base run
+	Policy     string `json:"policy"`
+	BasePolicy string `json:"base_policy,omitempty"`
+}
+
+// TimeBucket represents the connections of a minute of the run, all the
+// connections are counted (the events are reported once per destination)
+type TimeBucket struct {
+	// Start is the start of the minute (UTC)
+	Start time.Time `json:"start"`
+	Total int       `json:"total"`
+	Pass  int       `json:"pass"`
+	Block int       `json:"block"`
+	// WouldBlock are the connections that would be blocked in the trace mode
+	WouldBlock int `json:"would_block,omitempty"`
+}
+
+// RuleHit represents the connections matched by a policy rule
+type RuleHit struct {
+	// Rule is the flag and the value of the rule (allowed-hosts=.github.com)
+	Rule string `json:"rule"`
+	// Allowed and Denied are the matched connections by the policy decision,
+	// an allow rule matching a denied connection is overridden by another rule
+	Allowed int `json:"allowed"`
+	Denied  int `json:"denied"`
+}
+
+// Diagnostic represents a self-diagnostic finding of kntrl
+type Diagnostic struct {
+	// Kind is one of the DiagnosticKind constants
+	Kind string `json:"kind"`
+	// Component is the slow component (enrich/<stage>, policy, verdict, sink/<sink>),
+	// the full map or the sandbox (<runtime>/<pid>)
+	Component    string    `json:"component"`
+	Count        int       `json:"count"`
+	MaxLatencyMs int64     `json:"max_latency_ms,omitempty"`
+	ThresholdMs  int64     `json:"threshold_ms,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	// Action is the overflow action of a full map (evict-lru, reject-new, switch-to-monitor)
+	// or the observation of a sandbox (observe, none)
+	Action string `json:"action,omitempty"`
+}
+
+const (
+	// DiagnosticKindSlow is the finding of a component slower than the threshold
+	DiagnosticKindSlow = "slow"
+	// DiagnosticKindMapFull is the finding of a write to a full map
+	DiagnosticKindMapFull = "map-full"
+	// DiagnosticKindSandbox is the finding of a sandboxed runtime, the connections
+	// of its processes are not seen by the host probes
+	DiagnosticKindSandbox = "sandbox"
+)
+
+// ClockInfo represents the time source of the report timestamps
+type ClockInfo struct {
+	// Source is the time source (system or ntp://host)
+	Source string `json:"source"`
+	// OffsetNs is the offset of the system clock to the time source
+	OffsetNs int64 `json:"offset_ns"`
+	// RoundTripNs is the round trip delay of the time source query
+	RoundTripNs int64 `json:"round_trip_ns"`
+	// Synchronized is true if the kernel clock is disciplined by NTP
+	Synchronized bool `json:"synchronized"`
+	// MaxErrorUs is the maximum error of the kernel clock
+	MaxErrorUs int64 `json:"max_error_us"`
+	// MeasuredAt is the time of the measurement (time source)
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// ReportSummary represents the verdict counts of the report
+type ReportSummary struct {
+	Total int `json:"total"`
+	Pass  int `json:"pass"`
+	Block int `json:"block"`
+	// WouldBlock is the number of the events that would be blocked in the trace mode
+	WouldBlock int `json:"would_block,omitempty"`
+	// Tarpit is the number of the events delayed instead of blocked
+	Tarpit int `json:"tarpit,omitempty"`
+	// Categories are the event totals of the destination categories (category stage)
+	Categories map[string]int `json:"categories,omitempty"`
+	// BytesSent and BytesReceived are the traffic totals of the events
+	BytesSent     uint64 `json:"bytes_sent,omitempty"`
+	BytesReceived uint64 `json:"bytes_received,omitempty"`
 }
 
 const (
@@ -35,6 +530,36 @@ const (
 
 	// EventPolicyStatusBlock is the block status of the event
 	EventPolicyStatusBlock = "block"
+
+	// EventPolicyStatusTarpit is the status of the event delayed instead of blocked (soft block)
+	EventPolicyStatusTarpit = "tarpit"
+)
+
+const (
+	// EventVerdictAllowed is the verdict of the connection passed by the kernel
+	EventVerdictAllowed = "ALLOWED"
+
+	// EventVerdictBlocked is the verdict of the connection dropped by the kernel
+	EventVerdictBlocked = "BLOCKED"
+
+	// EventVerdictDelayed is the verdict of the connection delayed by the kernel (tarpit)
+	EventVerdictDelayed = "DELAYED"
+)
+
+const (
+	// DestinationCategoryPackageRegistry is the category of the package registries
+	DestinationCategoryPackageRegistry = "package-registry"
+	// DestinationCategoryCloudProvider is the category of the cloud providers and CDNs
+	DestinationCategoryCloudProvider = "cloud-provider"
+	// DestinationCategoryAnalytics is the category of the analytics and telemetry services
+	DestinationCategoryAnalytics = "analytics"
+	// DestinationCategoryAds is the category of the ad networks
+	DestinationCategoryAds = "ads"
+	// DestinationCategoryMetadata is the category of the cloud metadata endpoints,
+	// set for all the events (see parser.IsMetadataEndpoint)
+	DestinationCategoryMetadata = "metadata"
+	// DestinationCategoryUnknown is the category of the destinations not in the feed
+	DestinationCategoryUnknown = "unknown"
 )
 
 const (
@@ -42,3 +567,161 @@ const (
 	EventProtocolTCP = "tcp"
 	EventProtocolUDP = "udp"
 )
+
+// Session represents the in-progress state of a tracer session
+// that is persisted to resume the same report after a restart
+type Session struct {
+	ID         string        `json:"id"`
+	Mode       string        `json:"mode"`
+	StartedAt  time.Time     `json:"started_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	Events     []ReportEvent `json:"events"`
+	AllowedIPs []string      `json:"allowed_ips"`
+	Allowed    []AllowEntry  `json:"allowed,omitempty"`
+	Timeline   []TimeBucket  `json:"timeline,omitempty"`
+}
+
+// AllowEntry represents the provenance of an allow list entry,
+// why the destination is allowed in the kernel
+type AllowEntry struct {
+	// Address is the IP address, address:port for the port scoped entries
+	Address string `json:"address"`
+	// Source is one of the AllowSource constants
+	Source string `json:"source"`
+	// Rule is the flag, the host name or the domain names the entry is added for
+	Rule    string    `json:"rule,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// AllowList represents the exported allow list of a running kntrl
+type AllowList struct {
+	ExportedAt time.Time    `json:"exported_at"`
+	Entries    []AllowEntry `json:"entries"`
+}
+
+const (
+	// AllowSourceStatic is an address of the static policy (allowed-ips, allowed-ports, defaults)
+	AllowSourceStatic = "static"
+	// AllowSourceDNS is a resolved address of an allowed host
+	AllowSourceDNS = "dns"
+	// AllowSourcePolicy is an address allowed by the policy evaluation of an event
+	AllowSourcePolicy = "policy"
+	// AllowSourceTOFU is a trusted address of the TOFU mode
+	AllowSourceTOFU = "tofu"
+	// AllowSourceRuntime is a runtime exception (verdict hook or Tracer.Allow)
+	AllowSourceRuntime = "runtime"
+	// AllowSourceImport is an address imported from the allow list of another kntrl
+	AllowSourceImport = "import"
+	// AllowSourceCache is an address of the verdict cache of a previous job
+	AllowSourceCache = "cache"
+)
+
+// TrustStore represents the destinations recorded by the first run in TOFU mode
+type TrustStore struct {
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	Destinations []TrustedDestination `json:"destinations"`
+}
+
+// TrustedDestination represents a destination trusted on first use
+type TrustedDestination struct {
+	Protocol string   `json:"proto"`
+	Address  string   `json:"daddr"`
+	Port     uint16   `json:"dport"`
+	Domains  []string `json:"domains"`
+}
+
+// VerdictCache represents the destinations allowed by their domain names,
+// exported at the job end and imported by the next job
+type VerdictCache struct {
+	ExportedAt time.Time           `json:"exported_at"`
+	Entries    []VerdictCacheEntry `json:"entries"`
+}
+
+// VerdictCacheEntry represents a destination allowed by its domain names
+type VerdictCacheEntry struct {
+	Protocol  string    `json:"proto"`
+	Address   string    `json:"daddr"`
+	Port      uint16    `json:"dport"`
+	Domains   []string  `json:"domains"`
+	LearnedAt time.Time `json:"learned_at"`
+}
+
+// RuleHistory represents the hit history of the policy rules over the runs
+type RuleHistory struct {
+	UpdatedAt time.Time             `json:"updated_at"`
+	Rules     map[string]RuleRecord `json:"rules"`
+}
+
+// RuleRecord represents the hit history of a policy rule
+type RuleRecord struct {
+	// IdleRuns is the number of the consecutive runs without a hit
+	IdleRuns int       `json:"idle_runs"`
+	LastHit  time.Time `json:"last_hit,omitempty"`
+}
+
+// CertHistory represents the certificates of the TLS destinations observed over the runs
+type CertHistory struct {
+	UpdatedAt time.Time             `json:"updated_at"`
+	Hosts     map[string]CertRecord `json:"hosts"`
+}
+
+// CertRecord represents the certificates of a TLS destination (host:port)
+type CertRecord struct {
+	// Keys are the SHA-256 hashes of the public keys (SPKI) observed, the latest last
+	Keys []string `json:"spki_sha256"`
+	// Certificate is the latest certificate observed
+	Certificate Certificate `json:"certificate"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+}
+
+// Certificate represents the leaf certificate of a TLS server
+type Certificate struct {
+	// Fingerprint is the SHA-256 hash of the certificate
+	Fingerprint string `json:"sha256"`
+	// SPKI is the SHA-256 hash of the public key (subject public key info)
+	SPKI     string    `json:"spki_sha256"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// StaleRule represents a policy rule without a hit in the last runs,
+// a candidate to be removed from the policy
+type StaleRule struct {
+	Rule     string     `json:"rule"`
+	IdleRuns int        `json:"idle_runs"`
+	LastHit  *time.Time `json:"last_hit,omitempty"`
+}
+
+// EnrichmentConfig represents the enrichment pipeline configuration.
+// The stages run in the given order for each event.
+type EnrichmentConfig struct {
+	Stages []EnrichmentStage `yaml:"stages"`
+}
+
+// EnrichmentStage represents an enrichment stage of the pipeline
+type EnrichmentStage struct {
+	// Name is the built-in stage (rdns, geoip, asn, container, category, reputation) or exec
+	Name string `yaml:"name"`
+	// Command is the command of the exec stage, the event is written to stdin
+	// as JSON and the labels are read from stdout as a JSON object
+	Command []string `yaml:"command"`
+	// Timeout of the stage (e.g. 500ms)
+	Timeout string `yaml:"timeout"`
+	// Database is the ip2asn TSV database of the geoip and asn stages, the
+	// category feed (domain suffix,category lines) of the category stage or
+	// the threat feed of the reputation stage
+	Database string `yaml:"database"`
+	// Resolver is the DNS server of the reverse lookups of the rdns stage
+	// (10.0.0.2:53, tls://host:853, https://host/dns-query), the servers of
+	// /etc/resolv.conf if empty
+	Resolver string `yaml:"resolver"`
+	// RDAP is the RDAP service of the domain age lookups of the reputation
+	// stage, rdap.org if empty, "none" disables the lookups
+	RDAP string `yaml:"rdap"`
+	// Store is the file of the destinations seen by the reputation stage,
+	// the first contact isn't scored if empty
+	Store string `yaml:"store"`
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 const (
 	// TracerModeMonitor is the monitor mode
 	TracerModeMonitor = "monitor"
@@ -7,9 +9,72 @@ const (
 	// TracerModeTrace is the trace mode
 	TracerModeTrace = "trace"
 
+	// TracerModeTOFU is the trust-on-first-use mode, the first run records
+	// the destinations and the subsequent runs trace with the recorded ones
+	TracerModeTOFU = "tofu"
+
+	// TracerModePassive is the report mode of the passive monitor mode,
+	// the connections are observed without enforcement
+	TracerModePassive = "passive"
+
+	// TracerModeSimulate is the simulation mode, the policy of the trace mode
+	// is evaluated against the observed connections without enforcement
+	TracerModeSimulate = "simulate"
+
 	// TracerModeIndexMonitor is the index of the monitor mode
 	TracerModeIndexMonitor = 0
 
 	// TracerModeIndexTrace is the index of the trace mode
 	TracerModeIndexTrace = 1
 )
+
+const (
+	// StatusSourceDaemon is the status of a running kntrl read on its control API
+	StatusSourceDaemon = "daemon"
+
+	// StatusSourcePinned is the status of the enforcement pinned by kntrl run --pin,
+	// read from the BPF filesystem
+	StatusSourcePinned = "pinned"
+)
+
+// Status represents the state of a running kntrl or of its pinned enforcement (kntrl status)
+type Status struct {
+	// Source is where the status is read from, see StatusSourceDaemon and StatusSourcePinned
+	Source string `json:"source"`
+	Mode   string `json:"mode"`
+	// Enforcing is true if the connections out of the policy are blocked by the kernel
+	Enforcing bool `json:"enforcing"`
+	// Scope is the cgroup of the enforcement, "/" for the whole host
+	Scope  string `json:"scope,omitempty"`
+	Pinned bool   `json:"pinned"`
+	// StartedAt is the start of the run, the attach of the pinned enforcement
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// AllowEntries and DenyEntries are the entries of the allow maps (the
+	// addresses, the ranges and the hosts) and of the deny map in the kernel
+	AllowEntries int               `json:"allow_entries"`
+	DenyEntries  int               `json:"deny_entries"`
+	Programs     []AttachedProgram `json:"programs"`
+	// Events are the counters of the connection events, nil for the pinned enforcement
+	Events *StatusEvents `json:"events,omitempty"`
+}
+
+// AttachedProgram represents an eBPF program attached by kntrl
+type AttachedProgram struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Target is the hook of the program, the kernel function, the tracepoint or the cgroup
+	Target string `json:"target,omitempty"`
+}
+
+// StatusEvents represents the counters of the connection events of a running kntrl
+type StatusEvents struct {
+	// Handled is the number of the connection events evaluated and reported
+	Handled uint64 `json:"handled"`
+	// Lost is the number of the connection events lost because the perf buffer was full
+	Lost uint64 `json:"lost"`
+	// Pass, Block and WouldBlock are the verdicts of the reported destinations
+	Pass       int `json:"pass"`
+	Block      int `json:"block"`
+	WouldBlock int `json:"would_block,omitempty"`
+}
@@ -0,0 +1,68 @@
+package allow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Export writes the allow list of the running kntrl to stdout
+func Export(cmd cobra.Command) error {
+	list, err := control.Export(control.FlagsEndpoint(cmd.Flags()))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(list)
+}
+
+// Import adds the entries of the exported allow list file into the allow list of the running kntrl
+func Import(cmd cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("allow list file is required")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read allow list: %w", err)
+	}
+
+	var list domain.AllowList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse allow list [%s]: %w", args[0], err)
+	}
+
+	n, err := control.Import(control.FlagsEndpoint(cmd.Flags()), list)
+	if err != nil {
+		return err
+	}
+	logger.Log.Infof("imported %d of %d allow list entries", n, len(list.Entries))
+
+	return writeResult(cmd, Result{Added: n, Requested: len(list.Entries)})
+}
+
+// Result is the change of the allow list, written to stdout with --json
+type Result struct {
+	Added     int      `json:"added"`
+	Requested int      `json:"requested"`
+	Removed   []string `json:"removed,omitempty"`
+}
+
+// writeResult writes the result to stdout as JSON with --json
+func writeResult(cmd cobra.Command, result Result) error {
+	if asJSON, _ := cmd.Flags().GetBool("json"); !asJSON {
+		return nil
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
@@ -0,0 +1,73 @@
+package allow
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Add adds the addresses (address or address:port) and the hosts into the allow list of
+// the running kntrl, the hosts are resolved by kntrl and their DNS answers are allowed
+func Add(cmd cobra.Command, args []string) error {
+	var list domain.AllowList
+	for _, address := range args {
+		if err := checkAddress(address); err != nil {
+			return err
+		}
+		list.Entries = append(list.Entries, domain.AllowEntry{Address: address, Rule: "kntrl allow add"})
+	}
+
+	n, err := control.Import(control.FlagsEndpoint(cmd.Flags()), list)
+	if err != nil {
+		return err
+	}
+	logger.Log.Infof("added %d of %d destinations into the allow list", n, len(args))
+
+	return writeResult(cmd, Result{Added: n, Requested: len(args)})
+}
+
+// Remove removes the addresses from the allow list of the running kntrl
+func Remove(cmd cobra.Command, args []string) error {
+	var removed []string
+	for _, address := range args {
+		if err := control.Remove(control.FlagsEndpoint(cmd.Flags()), address); err != nil {
+			return fmt.Errorf("failed to remove [%s]: %w", address, err)
+		}
+		logger.Log.Infof("[%s] removed from the allow list", address)
+		removed = append(removed, address)
+	}
+
+	return writeResult(cmd, Result{Requested: len(args), Removed: removed})
+}
+
+// checkAddress checks an IPv4 address, address:port (*:port for any address) or a hostname
+func checkAddress(address string) error {
+	var host = address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		if h == "*" {
+			return nil
+		}
+		host = h
+	} else if isHostName(address) {
+		return nil
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(host)); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("not an IPv4 address: %s", address)
+	}
+
+	return nil
+}
+
+// isHostName returns true if the address is a hostname, not an IP address
+func isHostName(address string) bool {
+	var host = strings.TrimSpace(address)
+
+	return host != "" && net.ParseIP(host) == nil && !strings.ContainsAny(host, ":/ \t")
+}
@@ -0,0 +1,239 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/pkg/bench"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
+)
+
+const (
+	// drainTimeout is the maximum wait of the events queued when the load ends
+	drainTimeout  = 5 * time.Second
+	drainInterval = 100 * time.Millisecond
+)
+
+// scenario is the result of the load with or without a tracer
+type scenario struct {
+	name    string
+	result  bench.Result
+	stats   ktracer.Stats
+	elapsed time.Duration
+	tracer  bool
+}
+
+// Run generates the same connection load without kntrl and with kntrl in the
+// given modes, and prints the comparison of the throughput and the latency
+func Run(ctx context.Context, cmd cobra.Command) error {
+	if err := ebpfman.CheckPrivileges(true); err != nil {
+		return err
+	}
+
+	duration, err := cmd.Flags().GetDuration("duration")
+	if err != nil {
+		return err
+	}
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		return err
+	}
+	modes, err := cmd.Flags().GetStringSlice("modes")
+	if err != nil {
+		return err
+	}
+
+	// every connection is logged at the info level
+	if verbose, _ := cmd.Flags().GetBool("verbose"); !verbose {
+		logger.SetLevel("warn")
+	}
+
+	var cfg = bench.Config{Duration: duration, Workers: workers}
+
+	// the progress is written to stderr with the JSON result on stdout
+	asJSON, _ := cmd.Flags().GetBool("json")
+	var progress io.Writer = os.Stdout
+	if asJSON {
+		progress = os.Stderr
+	}
+
+	fmt.Fprintf(progress, "baseline: %d workers for %s\n", workers, duration)
+	baseline, err := bench.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	var scenarios = []scenario{{name: "baseline", result: baseline, elapsed: baseline.Duration}}
+
+	for _, mode := range modes {
+		fmt.Fprintf(progress, "%s: %d workers for %s\n", mode, workers, duration)
+		s, err := runTracer(ctx, strings.TrimSpace(mode), cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", mode, err)
+		}
+		scenarios = append(scenarios, s)
+	}
+
+	if asJSON {
+		return writeJSON(scenarios)
+	}
+	printTable(scenarios)
+
+	return nil
+}
+
+// runTracer generates the load with a tracer in the mode, the events of the
+// load are kntrl's own egress and reported as self
+func runTracer(ctx context.Context, mode string, cfg bench.Config) (scenario, error) {
+	dir, err := os.MkdirTemp("", "kntrl-bench")
+	if err != nil {
+		return scenario{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	t, err := ktracer.New(ktracer.Options{
+		Mode:           mode,
+		AllowedIPs:     []string{"127.0.0.1"},
+		ReportSelf:     true,
+		OutputFileName: filepath.Join(dir, "kntrl.out"),
+		OutputFormat:   reporter.FormatJSON,
+	})
+	if err != nil {
+		return scenario{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := t.Start(ctx); err != nil {
+		return scenario{}, err
+	}
+
+	var start = time.Now()
+	result, err := bench.Run(ctx, cfg)
+	if err != nil {
+		cancel()
+		_ = t.Wait()
+		return scenario{}, err
+	}
+	var stats = drain(t)
+	var elapsed = time.Since(start)
+
+	cancel()
+	if err := t.Wait(); err != nil {
+		return scenario{}, err
+	}
+
+	return scenario{name: mode, result: result, stats: stats, elapsed: elapsed, tracer: true}, nil
+}
+
+// drain waits until the queued events of the load are handled
+func drain(t *ktracer.Tracer) ktracer.Stats {
+	var stats = t.Stats()
+	var deadline = time.Now().Add(drainTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(drainInterval)
+
+		next := t.Stats()
+		if next == stats {
+			break
+		}
+		stats = next
+	}
+
+	return stats
+}
+
+func printTable(scenarios []scenario) {
+	var baseline = scenarios[0].result
+
+	data := pterm.TableData{
+		{"Scenario", "Connections/s", "p50", "p99", "p50 overhead", "p99 overhead", "Events/s", "Lost", "Errors"},
+	}
+
+	for _, s := range scenarios {
+		var row = []string{
+			s.name,
+			fmt.Sprintf("%.0f", s.result.Rate()),
+			s.result.P50.String(),
+			s.result.P99.String(),
+			"-", "-", "-", "-",
+			fmt.Sprint(s.result.Errors),
+		}
+
+		if s.tracer {
+			row[4] = overhead(s.result.P50, baseline.P50)
+			row[5] = overhead(s.result.P99, baseline.P99)
+			row[6] = fmt.Sprintf("%.0f", float64(s.stats.Handled)/s.elapsed.Seconds())
+			row[7] = lost(s.stats)
+		}
+		data = append(data, row)
+	}
+
+	fmt.Print("\n")
+	_ = pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// benchResult is a scenario written with --json, the latencies in nanoseconds
+type benchResult struct {
+	Scenario    string  `json:"scenario"`
+	Connections int     `json:"connections"`
+	Rate        float64 `json:"connections_per_second"`
+	P50         int64   `json:"p50_ns"`
+	P99         int64   `json:"p99_ns"`
+	Errors      int     `json:"errors"`
+	Events      uint64  `json:"events,omitempty"`
+	Lost        uint64  `json:"lost,omitempty"`
+}
+
+func writeJSON(scenarios []scenario) error {
+	var results = make([]benchResult, 0, len(scenarios))
+	for _, s := range scenarios {
+		results = append(results, benchResult{
+			Scenario:    s.name,
+			Connections: s.result.Connections,
+			Rate:        s.result.Rate(),
+			P50:         s.result.P50.Nanoseconds(),
+			P99:         s.result.P99.Nanoseconds(),
+			Errors:      s.result.Errors,
+			Events:      s.stats.Handled,
+			Lost:        s.stats.Lost,
+		})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(results)
+}
+
+// overhead returns the latency increase relative to the baseline
+func overhead(latency, baseline time.Duration) string {
+	if baseline <= 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%+.1f%%", (float64(latency)/float64(baseline)-1)*100)
+}
+
+// lost returns the lost events and the drop rate
+func lost(stats ktracer.Stats) string {
+	var total = stats.Handled + stats.Lost
+	if total == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%d (%.2f%%)", stats.Lost, float64(stats.Lost)/float64(total)*100)
+}
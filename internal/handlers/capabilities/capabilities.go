@@ -0,0 +1,63 @@
+package capabilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+)
+
+// Run prints the kernel features and the modes usable on the host
+func Run(cmd cobra.Command) error {
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return fmt.Errorf("failed to parse [json] flag: %w", err)
+	}
+
+	c := ebpfman.ProbeFeatures().Capabilities()
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+
+	fmt.Printf("kernel [%s] arch [%s] build [%s]\n\n", c.Kernel, c.KernelArch, c.BuildArch)
+
+	data := pterm.TableData{
+		{"Feature", "Supported", "Description", "Reason"},
+	}
+	for _, f := range c.Features {
+		data = append(data, []string{f.Name, strconv.FormatBool(f.Supported), f.Description, f.Reason})
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render(); err != nil {
+		return err
+	}
+
+	var modes = make([]string, 0, len(c.Modes))
+	for mode := range c.Modes {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	for _, mode := range modes {
+		fmt.Printf("mode [%s] usable: %t\n", mode, c.Modes[mode])
+	}
+	if len(c.Missing) > 0 {
+		fmt.Printf("\nmissing features of the enforcing modes:\n")
+	}
+	for _, missing := range c.Missing {
+		fmt.Printf("  - %s\n", missing)
+	}
+	if len(c.MissingCapabilities) > 0 {
+		fmt.Printf("\nmissing capabilities of kntrl: %s\n", strings.Join(c.MissingCapabilities, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// dialTimeout is the wait of a kntrl answering on the control socket
+const dialTimeout = time.Second
+
+// Result is the files removed and kept by the cleanup
+type Result struct {
+	Removed []string `json:"removed"`
+	Kept    []Kept   `json:"kept,omitempty"`
+}
+
+// Kept is a file kept by the cleanup
+type Kept struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Run removes the files left by a kntrl stopped without its cleanup (SIGKILL,
+// OOM): the control socket nobody listens on and the session state file. The
+// eBPF programs and maps are released by the kernel with the process, unless
+// they are pinned (kntrl detach). The result is written to stdout as JSON with --json.
+func Run(cmd cobra.Command) error {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	var result = Result{Removed: []string{}}
+	var remove = func(path string) error {
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove [%s]: %w", path, err)
+			}
+		}
+		result.Removed = append(result.Removed, path)
+		return nil
+	}
+
+	if socket := cmd.Flag("control-socket").Value.String(); exists(socket) {
+		if conn, err := net.DialTimeout("unix", socket, dialTimeout); err == nil {
+			conn.Close()
+			result.Kept = append(result.Kept, Kept{Path: socket, Reason: "kntrl is running"})
+		} else if err := remove(socket); err != nil {
+			return err
+		}
+	}
+
+	if stateFile := cmd.Flag("state-file").Value.String(); exists(stateFile) {
+		if err := remove(stateFile); err != nil {
+			return err
+		}
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		return json.NewEncoder(os.Stdout).Encode(result)
+	}
+
+	for _, path := range result.Removed {
+		logger.Log.Infof("removed [%s]", path)
+	}
+	for _, kept := range result.Kept {
+		logger.Log.Warnf("kept [%s]: %s", kept.Path, kept.Reason)
+	}
+	if len(result.Removed) == 0 && len(result.Kept) == 0 {
+		logger.Log.Info("nothing to clean up")
+	}
+
+	return nil
+}
+
+func exists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Lstat(path)
+
+	return err == nil
+}
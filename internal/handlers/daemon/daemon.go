@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Events writes the live events of the running kntrl to stdout as JSON lines until the context is cancelled
+func Events(ctx context.Context, cmd cobra.Command) error {
+	enc := json.NewEncoder(os.Stdout)
+
+	return control.Events(ctx, control.FlagsEndpoint(cmd.Flags()), func(e domain.ReportEvent) {
+		if err := enc.Encode(e); err != nil {
+			logger.Log.Errorf("failed to write event: %v", err)
+		}
+	})
+}
+
+// Report writes the report of the running kntrl to stdout
+func Report(cmd cobra.Command) error {
+	report, err := control.Report(control.FlagsEndpoint(cmd.Flags()))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(report)
+}
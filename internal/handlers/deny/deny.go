@@ -0,0 +1,59 @@
+package deny
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Result is the change of the deny list, written to stdout with --json
+type Result struct {
+	Denied    int `json:"denied"`
+	Requested int `json:"requested"`
+}
+
+// Deny adds the IPv4 addresses and the hosts into the deny list of the running kntrl,
+// the connections to them are blocked from then on in all modes
+func Deny(cmd cobra.Command, args []string) error {
+	for _, destination := range args {
+		if err := checkDestination(destination); err != nil {
+			return err
+		}
+	}
+
+	n, err := control.Deny(control.FlagsEndpoint(cmd.Flags()), args)
+	if err != nil {
+		return err
+	}
+	logger.Log.Infof("added %d of %d destinations into the deny list", n, len(args))
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); !asJSON {
+		return nil
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(Result{Denied: n, Requested: len(args)})
+}
+
+// checkDestination checks an IPv4 address or a hostname
+func checkDestination(destination string) error {
+	var d = strings.TrimSpace(destination)
+	if ip := net.ParseIP(d); ip != nil {
+		if ip.To4() == nil {
+			return fmt.Errorf("not an IPv4 address: %s", destination)
+		}
+		return nil
+	}
+
+	if d == "" || strings.ContainsAny(d, ":/ \t") {
+		return fmt.Errorf("not an IPv4 address or a hostname: %s", destination)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package detach
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
+)
+
+// Result is the pinned objects removed by the detach
+type Result struct {
+	Removed []string `json:"removed"`
+}
+
+// Run removes the enforcement pinned by kntrl run --pin, the result is
+// written to stdout as JSON with --json
+func Run(cmd cobra.Command) error {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	removed, err := ktracer.Detach(dryRun)
+	if err != nil {
+		return err
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		return json.NewEncoder(os.Stdout).Encode(Result{Removed: removed})
+	}
+
+	for _, path := range removed {
+		logger.Log.Infof("removed [%s]", path)
+	}
+	if len(removed) == 0 {
+		logger.Log.Info("no pinned enforcement")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package merge
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+)
+
+// Run merges the reports of the arguments into one report
+func Run(cmd cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("output-format")
+	if err != nil {
+		return err
+	}
+	if format != reporter.FormatJSON && format != reporter.FormatSARIF {
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	outputFile, err := cmd.Flags().GetString("output-file")
+	if err != nil {
+		return err
+	}
+
+	var shards = make([]reporter.Shard, 0, len(args))
+	for _, arg := range args {
+		shard, err := reporter.ReadShard(arg)
+		if err != nil {
+			return err
+		}
+		shards = append(shards, shard)
+	}
+
+	report, err := reporter.Merge(shards)
+	if err != nil {
+		return err
+	}
+
+	if err := write(outputFile, format, report); err != nil {
+		return err
+	}
+
+	logger.Log.Infof("merged %d reports: %d events, %d blocked", len(shards), report.Summary.Total, report.Summary.Block)
+
+	return nil
+}
+
+// write writes the report to the file, "-" is the standard output
+func write(path, format string, report domain.Report) error {
+	if path == "-" {
+		return reporter.WriteReport(os.Stdout, format, report)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if err := reporter.WriteReport(file, format, report); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write report to file: %s %w", path, err)
+	}
+
+	return file.Close()
+}
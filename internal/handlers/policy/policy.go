@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/parser"
+	"github.com/kondukto-io/kntrl/pkg/policy"
+)
+
+// Check is the result of the check of a policy file
+type Check struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Rules are the number of the entries of each field
+	Rules map[string]int `json:"rules,omitempty"`
+}
+
+// Run checks the policy file as kntrl run --policy-file reads it, the result
+// is written to stdout as JSON with --json. An invalid file is an error.
+func Run(cmd cobra.Command, args []string) error {
+	var result = Check{File: args[0]}
+
+	pf, err := check(args[0])
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Valid = true
+		result.Rules = map[string]int{
+			"hosts":          len(pf.Hosts),
+			"allowed-hosts":  len(pf.AllowedHosts),
+			"allowed-ips":    len(pf.AllowedIPs),
+			"allowed-ports":  len(pf.AllowedPorts),
+			"denied-hosts":   len(pf.DeniedHosts),
+			"denied-ips":     len(pf.DeniedIPs),
+			"process-policy": len(pf.ProcessPolicy),
+		}
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else if result.Valid {
+		printRules(pf)
+	}
+
+	return err
+}
+
+// check reads the policy file and parses its rules
+func check(path string) (*domain.PolicyFile, error) {
+	pf, err := policy.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// kntrl run skips the invalid addresses, the ranges included
+	for _, ip := range append(append([]string{}, pf.AllowedIPs...), pf.DeniedIPs...) {
+		if _, err := netip.ParseAddr(strings.TrimSpace(ip)); err != nil {
+			return nil, fmt.Errorf("invalid IP address [%s]", ip)
+		}
+	}
+	if _, err := parser.ParsePortRules(pf.AllowedPorts); err != nil {
+		return nil, err
+	}
+	if _, err := parser.ParseProcessRules(pf.ProcessPolicy); err != nil {
+		return nil, err
+	}
+
+	return pf, nil
+}
+
+func printRules(pf *domain.PolicyFile) {
+	data := pterm.TableData{
+		{"Field", "Entries"},
+	}
+
+	for _, field := range []struct {
+		name    string
+		entries []string
+	}{
+		{"hosts", pf.Hosts},
+		{"allowed-hosts", pf.AllowedHosts},
+		{"allowed-ips", pf.AllowedIPs},
+		{"allowed-ports", pf.AllowedPorts},
+		{"denied-hosts", pf.DeniedHosts},
+		{"denied-ips", pf.DeniedIPs},
+		{"process-policy", pf.ProcessPolicy},
+	} {
+		if len(field.entries) > 0 {
+			data = append(data, []string{field.name, strings.Join(field.entries, ", ")})
+		}
+	}
+
+	_ = pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
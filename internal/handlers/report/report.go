@@ -0,0 +1,193 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+	"github.com/kondukto-io/kntrl/pkg/runstore"
+)
+
+// formatTable is the output format of the tables, the default
+const formatTable = "table"
+
+// List writes the runs of the report store, as JSON with --json
+func List(cmd cobra.Command) error {
+	store, err := open(cmd)
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		return encode(runs)
+	}
+
+	data := pterm.TableData{
+		{"Run", "Started At", "Duration", "Mode", "Events", "Pass", "Block", "Would Block", "Complete"},
+	}
+	for _, run := range runs {
+		data = append(data, []string{
+			run.ID,
+			run.StartedAt.Format(time.RFC3339),
+			run.FinishedAt.Sub(run.StartedAt).Round(time.Second).String(),
+			run.Mode,
+			strconv.Itoa(run.Summary.Total),
+			strconv.Itoa(run.Summary.Pass),
+			strconv.Itoa(run.Summary.Block + run.Summary.Tarpit),
+			strconv.Itoa(run.Summary.WouldBlock),
+			strconv.FormatBool(run.Complete),
+		})
+	}
+
+	return pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// Show writes the report of a run of the report store (the last run without
+// an argument) filtered by the process and the destination
+func Show(cmd cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("output-format")
+	if err != nil {
+		return err
+	}
+	if format != formatTable && format != reporter.FormatJSON && format != reporter.FormatSARIF {
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	store, err := open(cmd)
+	if err != nil {
+		return err
+	}
+
+	var ref = runstore.Latest
+	if len(args) > 0 {
+		ref = args[0]
+	}
+	_, report, err := store.Load(ref)
+	if err != nil {
+		return err
+	}
+	report = reporter.FilterReport(report, cmd.Flag("process").Value.String(), cmd.Flag("destination").Value.String())
+
+	if format == formatTable {
+		verbose, err := cmd.Flags().GetBool("verbose-report")
+		if err != nil {
+			return err
+		}
+		reporter.PrintTable(report, verbose)
+		return nil
+	}
+
+	return reporter.WriteReport(os.Stdout, format, report)
+}
+
+// Diff writes the destinations added, removed and with another policy in a
+// run (the last run by default) since a previous run (the run before it by
+// default), filtered by the process and the destination
+func Diff(cmd cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("output-format")
+	if err != nil {
+		return err
+	}
+	if format != formatTable && format != reporter.FormatJSON {
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	store, err := open(cmd)
+	if err != nil {
+		return err
+	}
+
+	var baseRef, headRef = runstore.Previous, runstore.Latest
+	switch len(args) {
+	case 1:
+		baseRef = args[0]
+	case 2:
+		baseRef, headRef = args[0], args[1]
+	}
+
+	var process, destination = cmd.Flag("process").Value.String(), cmd.Flag("destination").Value.String()
+	baseID, base, err := store.Load(baseRef)
+	if err != nil {
+		return err
+	}
+	headID, head, err := store.Load(headRef)
+	if err != nil {
+		return err
+	}
+
+	var diff = reporter.DiffReports(
+		reporter.FilterReport(base, process, destination),
+		reporter.FilterReport(head, process, destination),
+	)
+	diff.Base, diff.Head = baseID, headID
+
+	if format == reporter.FormatJSON {
+		return encode(diff)
+	}
+
+	fmt.Printf("%s -> %s: %d added, %d removed, %d changed\n\n", baseID, headID, len(diff.Added), len(diff.Removed), len(diff.Changed))
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+		return nil
+	}
+
+	data := pterm.TableData{
+		{"Change", "Destination", "Port", "Proto", "Addresses", "Tasks", "Policy"},
+	}
+	for _, section := range []struct {
+		change  string
+		entries []domain.RunDiffEntry
+	}{
+		{"added", diff.Added},
+		{"removed", diff.Removed},
+		{"changed", diff.Changed},
+	} {
+		for _, e := range section.entries {
+			var policy = e.Policy
+			if e.BasePolicy != "" {
+				policy = e.BasePolicy + " -> " + e.Policy
+			}
+			data = append(data, []string{
+				section.change,
+				e.Destination,
+				strconv.Itoa(int(e.Port)),
+				e.Protocol,
+				strings.Join(e.Addresses, ", "),
+				strings.Join(e.Tasks, ", "),
+				policy,
+			})
+		}
+	}
+
+	return pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// open opens the report store of the flags, it must exist
+func open(cmd cobra.Command) (*runstore.Store, error) {
+	var dir = cmd.Flag("store").Value.String()
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to open report store: %w", err)
+	}
+
+	return runstore.Open(dir)
+}
+
+// encode writes the value to stdout as JSON
+func encode(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
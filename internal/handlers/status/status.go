@@ -0,0 +1,131 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+	"github.com/kondukto-io/kntrl/pkg/session"
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
+)
+
+// Run prints the status of the running kntrl, the enforcement pinned by
+// kntrl run --pin if no kntrl is running. With --state-file, it prints the
+// allow list entries of the running session with their provenance.
+func Run(cmd cobra.Command) error {
+	var format = cmd.Flag("output-format").Value.String()
+	if format != reporter.FormatJSON && format != reporter.FormatTable {
+		return fmt.Errorf("[output-format] flag is invalid: %s", format)
+	}
+
+	if stateFile := cmd.Flag("state-file").Value.String(); stateFile != "" {
+		return printSession(stateFile, format)
+	}
+
+	status, err := current(control.FlagsEndpoint(cmd.Flags()))
+	if err != nil {
+		return err
+	}
+
+	if format == reporter.FormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	return printStatus(status)
+}
+
+// current returns the status of the kntrl running on the endpoint, the status
+// of the pinned enforcement if there is no local control socket
+func current(e control.Endpoint) (*domain.Status, error) {
+	if e.URL == "" {
+		if _, err := os.Stat(e.Socket); errors.Is(err, os.ErrNotExist) {
+			status, err := ktracer.PinnedStatus()
+			if errors.Is(err, ktracer.ErrNotPinned) {
+				return nil, fmt.Errorf("no kntrl running on %s and no pinned enforcement", e.Socket)
+			}
+			return status, err
+		}
+	}
+
+	return control.Status(e)
+}
+
+// printStatus prints the status and the attached programs
+func printStatus(s *domain.Status) error {
+	var enforcing = "no"
+	if s.Enforcing {
+		enforcing = "yes"
+	}
+
+	data := pterm.TableData{
+		{"source", s.Source},
+		{"mode", s.Mode},
+		{"enforcing", enforcing},
+		{"pinned", fmt.Sprint(s.Pinned)},
+	}
+	if s.Scope != "" {
+		data = append(data, []string{"scope", s.Scope})
+	}
+	if !s.StartedAt.IsZero() {
+		var uptime = time.Duration(s.UptimeSeconds) * time.Second
+		data = append(data, []string{"uptime", fmt.Sprintf("%s (since %s)", uptime, s.StartedAt.Format(time.RFC3339))})
+	}
+	data = append(data,
+		[]string{"allow entries", fmt.Sprint(s.AllowEntries)},
+		[]string{"deny entries", fmt.Sprint(s.DenyEntries)},
+	)
+	if e := s.Events; e != nil {
+		data = append(data, []string{"events", fmt.Sprintf("handled %d, lost %d", e.Handled, e.Lost)})
+		data = append(data, []string{"destinations", fmt.Sprintf("pass %d, block %d, would block %d", e.Pass, e.Block, e.WouldBlock)})
+	}
+	if err := pterm.DefaultTable.WithData(data).Render(); err != nil {
+		return err
+	}
+
+	if len(s.Programs) == 0 {
+		fmt.Println("\nno attached programs")
+		return nil
+	}
+
+	fmt.Println()
+	programs := pterm.TableData{
+		{"Program", "Type", "Target"},
+	}
+	for _, p := range s.Programs {
+		programs = append(programs, []string{p.Name, p.Type, p.Target})
+	}
+
+	return pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(programs).Render()
+}
+
+// printSession prints the allow list entries of the session state file
+func printSession(stateFile, format string) error {
+	s, err := session.Load(stateFile)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("no session state in %s, is kntrl running with --state-file?", stateFile)
+	}
+
+	if format == reporter.FormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s.Allowed)
+	}
+
+	fmt.Printf("session [%s] mode [%s] updated at %s\n\n", s.ID, s.Mode, s.UpdatedAt.Format("2006-01-02 15:04:05"))
+	reporter.PrintAllowedTable(s.Allowed)
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// commandCgroupRoot is the parent cgroup of the cgroups of the commands
+	// run by kntrl (kntrl run -- <command>)
+	commandCgroupRoot = "/sys/fs/cgroup/kntrl"
+	// commandStopTimeout is the time given to the command to exit after
+	// SIGTERM when the tracer is stopped, it's killed then
+	commandStopTimeout = 10 * time.Second
+)
+
+// CommandError is returned by Run if the command run by kntrl fails, kntrl
+// exits with its exit code
+type CommandError struct {
+	Command  string
+	ExitCode int
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command [%s] exited with code %d", e.Command, e.ExitCode)
+}
+
+// commandArgs returns the command given after "--", nil without one
+func commandArgs(cmd *cobra.Command) ([]string, error) {
+	var args = cmd.Flags().Args()
+	var dash = cmd.ArgsLenAtDash()
+	if dash != 0 && len(args) > 0 {
+		return nil, fmt.Errorf("unexpected argument [%s], the command to run is given after --", args[0])
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	return args, nil
+}
+
+// credential is the user and the groups the command runs as
+type credential struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32
+}
+
+// commandCredential returns the credential of the command: the user of
+// --user (a name or an uid, with an optional :group), the user who ran sudo
+// (SUDO_UID and SUDO_GID) otherwise. It returns nil if the command runs as
+// the user of kntrl.
+func commandCredential(spec string, getenv func(string) string) (*credential, error) {
+	if spec == "" {
+		uid, gid := getenv("SUDO_UID"), getenv("SUDO_GID")
+		if uid == "" || uid == "0" {
+			return nil, nil
+		}
+		spec = uid
+		if gid != "" {
+			spec += ":" + gid
+		}
+	}
+
+	name, group, hasGroup := strings.Cut(spec, ":")
+	u, err := lookupUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred = &credential{}
+	if cred.UID, err = parseID(u.Uid); err != nil {
+		return nil, fmt.Errorf("invalid uid of the user [%s]: %w", name, err)
+	}
+
+	if !hasGroup {
+		group = u.Gid
+	}
+	if group == "" {
+		return nil, fmt.Errorf("unknown user [%s], the group is given as %s:<group>", name, name)
+	}
+	if cred.GID, err = lookupGroup(group); err != nil {
+		return nil, err
+	}
+
+	// the supplementary groups of a known user
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if gid, err := parseID(id); err == nil && gid != cred.GID {
+				cred.Groups = append(cred.Groups, gid)
+			}
+		}
+	}
+
+	return cred, nil
+}
+
+// lookupUser returns the user of the name or the uid, an unknown uid is
+// returned without a group
+func lookupUser(name string) (*user.User, error) {
+	if _, err := parseID(name); err == nil {
+		u, err := user.LookupId(name)
+		if err != nil {
+			return &user.User{Uid: name, Username: name}, nil
+		}
+		return u, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the user [%s]: %w", name, err)
+	}
+
+	return u, nil
+}
+
+// lookupGroup returns the gid of the group name or the gid
+func lookupGroup(group string) (uint32, error) {
+	if gid, err := parseID(group); err == nil {
+		return gid, nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the group [%s]: %w", group, err)
+	}
+
+	return parseID(g.Gid)
+}
+
+func parseID(id string) (uint32, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	return uint32(n), err
+}
+
+// newCommandCgroup creates the cgroup of the command
+func newCommandCgroup() (string, error) {
+	var dir = filepath.Join(commandCgroupRoot, fmt.Sprintf("run-%d", os.Getpid()))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create the cgroup of the command: %w", err)
+	}
+
+	return dir, nil
+}
+
+// removeCommandCgroup removes the cgroup of the command, it's kept while the
+// processes left by the command run
+func removeCommandCgroup(dir string) {
+	if err := os.Remove(dir); err != nil {
+		logger.Log.Warnf("failed to remove the cgroup of the command [%s]: %v", dir, err)
+	}
+}
+
+// runCommand runs the command in the cgroup until it exits with the standard
+// streams of kntrl, it's stopped (SIGTERM) when the context is cancelled. The
+// command runs as the user of the credential, without the capabilities of
+// kntrl: it can't change the enforcement it's subject to.
+func runCommand(ctx context.Context, args []string, cgroup string, cred *credential) error {
+	var c = exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Cancel = func() error {
+		return c.Process.Signal(syscall.SIGTERM)
+	}
+	c.WaitDelay = commandStopTimeout
+
+	closeCgroup, err := startInCgroup(c, cgroup)
+	if err != nil {
+		return err
+	}
+	defer closeCgroup()
+
+	if err := startCommand(c, cred); err != nil {
+		return fmt.Errorf("failed to start command [%s]: %w", args[0], err)
+	}
+	if cred != nil {
+		logger.Log.Infof("running the command [%s] in the cgroup [%s] as uid %d", strings.Join(args, " "), cgroup, cred.UID)
+	} else {
+		logger.Log.Infof("running the command [%s] in the cgroup [%s]", strings.Join(args, " "), cgroup)
+	}
+
+	err = c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &CommandError{Command: strings.Join(args, " "), ExitCode: exitCode(exitErr)}
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// startInCgroup sets the command to start in the cgroup (clone3 with
+// CLONE_INTO_CGROUP, linux 5.7), its processes are in the cgroup from their
+// first connection. The returned function closes the cgroup.
+func startInCgroup(c *exec.Cmd, cgroup string) (func(), error) {
+	dir, err := os.Open(cgroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the cgroup of the command: %w", err)
+	}
+
+	c.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(dir.Fd())}
+
+	return func() { _ = dir.Close() }, nil
+}
+
+// startCommand starts the command as the user of the credential. The command
+// doesn't get the capabilities of kntrl: the ambient capabilities are cleared,
+// and the bounding set is emptied if it runs as root. They are dropped on a
+// thread that is terminated once the command is started, the other threads
+// of kntrl keep them.
+func startCommand(c *exec.Cmd, cred *credential) error {
+	if cred != nil {
+		c.SysProcAttr.Credential = &syscall.Credential{Uid: cred.UID, Gid: cred.GID, Groups: cred.Groups}
+	}
+
+	var root = cred == nil && os.Geteuid() == 0
+	if root {
+		logger.Log.Warnf("the command runs as root without capabilities, use --user to run it as another user")
+	}
+
+	var errc = make(chan error, 1)
+	go func() {
+		// the thread is not unlocked, it exits with the goroutine
+		runtime.LockOSThread()
+
+		if err := dropCapabilities(root); err != nil {
+			errc <- err
+			return
+		}
+		errc <- c.Start()
+	}()
+
+	return <-errc
+}
+
+// dropCapabilities clears the ambient capabilities of the thread and empties
+// its bounding set with bounding, the capabilities of the thread are kept
+func dropCapabilities(bounding bool) error {
+	// the ambient capabilities are not supported before linux 4.3
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("failed to clear the ambient capabilities: %w", err)
+	}
+	if !bounding {
+		return nil
+	}
+
+	// the capabilities after the last one of the kernel are invalid
+	for capability := 0; ; capability++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to drop the capabilities of the command: %w", err)
+		}
+	}
+}
+
+// exitCode returns the exit code of the command, 128 + the signal number if
+// it was killed by a signal as the shells do
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return err.ExitCode()
+}
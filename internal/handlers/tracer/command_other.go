@@ -0,0 +1,26 @@
+//go:build !linux
+
+package tracer
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
+)
+
+// startInCgroup returns an error, the cgroups are supported on linux only
+func startInCgroup(*exec.Cmd, string) (func(), error) {
+	return nil, fmt.Errorf("%w: %s", ktracer.ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// startCommand starts the command
+func startCommand(c *exec.Cmd, _ *credential) error {
+	return c.Start()
+}
+
+// exitCode returns the exit code of the command
+func exitCode(err *exec.ExitError) int {
+	return err.ExitCode()
+}
@@ -0,0 +1,103 @@
+package tracer
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCommandArgs(t *testing.T) {
+	var tests = []struct {
+		name     string
+		args     []string
+		expected []string
+		err      bool
+	}{
+		{"no command", []string{"--mode", "trace"}, nil, false},
+		{"command", []string{"--mode", "trace", "--", "make", "build", "-j4"}, []string{"make", "build", "-j4"}, false},
+		{"flag of the command", []string{"--", "go", "test", "--mode", "trace"}, []string{"go", "test", "--mode", "trace"}, false},
+		{"empty command", []string{"--mode", "trace", "--"}, nil, false},
+		{"argument before the dash", []string{"make", "--", "build"}, nil, true},
+		{"argument without a dash", []string{"make"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		var cmd = &cobra.Command{Use: "run"}
+		cmd.Flags().String("mode", "", "")
+		if err := cmd.Flags().Parse(tt.args); err != nil {
+			t.Fatalf("%s: failed to parse the flags: %v", tt.name, err)
+		}
+
+		args, err := commandArgs(cmd)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(args, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, args)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		expected int
+	}{
+		{"exit code", "exit 3", 3},
+		{"killed by a signal", "kill -TERM $$", 143},
+		{"killed by SIGKILL", "kill -KILL $$", 137},
+	}
+
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("%s: expected an exit error, got %v", tt.name, err)
+		}
+		if code := exitCode(exitErr); code != tt.expected {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.expected, code)
+		}
+	}
+}
+
+func TestCommandCredential(t *testing.T) {
+	var tests = []struct {
+		name     string
+		spec     string
+		env      map[string]string
+		expected *credential
+		err      bool
+	}{
+		{name: "user of kntrl", expected: nil},
+		{name: "sudo by root", env: map[string]string{"SUDO_UID": "0", "SUDO_GID": "0"}, expected: nil},
+		{name: "sudo", env: map[string]string{"SUDO_UID": "54321", "SUDO_GID": "54322"}, expected: &credential{UID: 54321, GID: 54322}},
+		{name: "uid and gid", spec: "54321:54322", expected: &credential{UID: 54321, GID: 54322}},
+		{name: "user overrides sudo", spec: "54321:54322", env: map[string]string{"SUDO_UID": "1000", "SUDO_GID": "1000"}, expected: &credential{UID: 54321, GID: 54322}},
+		{name: "user name", spec: "root", expected: &credential{UID: 0, GID: 0}},
+		{name: "group name", spec: "54321:root", expected: &credential{UID: 54321, GID: 0}},
+		{name: "unknown uid without a group", spec: "54321", err: true},
+		{name: "unknown user", spec: "kntrl-no-such-user", err: true},
+		{name: "unknown group", spec: "54321:kntrl-no-such-group", err: true},
+	}
+
+	for _, tt := range tests {
+		cred, err := commandCredential(tt.spec, func(key string) string { return tt.env[key] })
+		if (err != nil) != tt.err {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		// the supplementary groups depend on the host
+		if cred != nil {
+			cred.Groups = nil
+		}
+		if !reflect.DeepEqual(cred, tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, cred)
+		}
+	}
+}
@@ -1,343 +1,697 @@
 package tracer
 
 import (
-	"bytes"
 	"context"
-	_ "embed"
-	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 
-	"github.com/cilium/ebpf"
-	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
-	"github.com/cilium/ebpf/rlimit"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"github.com/kondukto-io/kntrl/bundle"
 	"github.com/kondukto-io/kntrl/internal/core/domain"
-	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/enrich"
 	"github.com/kondukto-io/kntrl/pkg/logger"
-	"github.com/kondukto-io/kntrl/pkg/parser"
-	"github.com/kondukto-io/kntrl/pkg/policy"
 	"github.com/kondukto-io/kntrl/pkg/reporter"
+	ktracer "github.com/kondukto-io/kntrl/pkg/tracer"
 	"github.com/kondukto-io/kntrl/pkg/utils"
+	"github.com/kondukto-io/kntrl/pkg/workspace"
 )
 
-var (
-	//go:embed bpf_bpfel_x86.o
-	prog []byte
-)
-
-const (
-	rootCgroup = "/sys/fs/cgroup"
-	progName   = "kntrl"
-)
-
-func init() {
-	if !utils.IsRoot() {
-		logger.Log.Error("you need root privileges to run this program")
-		os.Exit(1)
-	}
+// ViolationError is returned by Run if the number of blocked events reaches the fail threshold
+type ViolationError struct {
+	Violations int
+	Threshold  int
 }
 
-// Run runs the tracer
-// $BPF_CLANG and $BPF_CFLAGS are set by the Makefile.
-//
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target=$GOARCH  -cc $BPF_CLANG -cflags $BPF_CFLAGS bpf ../../../bpf/sensor.network.bpf.c -- -I $BPF_HEADERS
-func Run(cmd cobra.Command) error {
-	var bundleFS = bundle.Bundle
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%d egress violation(s) detected, fail threshold is %d", e.Violations, e.Threshold)
+}
 
+// Run runs the tracer with the command flags until the context is cancelled
+func Run(ctx context.Context, cmd cobra.Command) error {
 	var tracerMode = cmd.Flag("mode").Value.String()
 	if tracerMode == "" {
 		return errors.New("[mode] flag is required")
 	}
 
-	if tracerMode != domain.TracerModeMonitor && tracerMode != domain.TracerModeTrace {
+	if tracerMode != domain.TracerModeMonitor && tracerMode != domain.TracerModeTrace && tracerMode != domain.TracerModeTOFU && tracerMode != domain.TracerModeSimulate {
 		return fmt.Errorf("[mode] flag is invalid: %s", tracerMode)
 	}
 
-	cmddata, err := parseFlags(&cmd)
-	if err != nil {
-		return fmt.Errorf("data json error: %w", err)
+	if format := cmd.Flag("output-format").Value.String(); !reporter.IsValidFormat(format) {
+		return fmt.Errorf("[output-format] flag is invalid: %s", format)
 	}
 
-	dataObj, err := json.Marshal(cmddata)
+	failOnViolation, err := cmd.Flags().GetBool("fail-on-violation")
+	if err != nil {
+		return err
+	}
+	failThreshold, err := cmd.Flags().GetInt("fail-threshold")
 	if err != nil {
-		return fmt.Errorf("error converting dataobj: %w", err)
+		return err
+	}
+	if failThreshold < 1 {
+		return fmt.Errorf("[fail-threshold] flag is invalid: %d", failThreshold)
+	}
+	// a threshold implies failing on violations
+	if cmd.Flags().Changed("fail-threshold") {
+		failOnViolation = true
 	}
 
-	p, err := policy.New(bundleFS, dataObj)
+	duration, err := cmd.Flags().GetDuration("duration")
 	if err != nil {
-		return fmt.Errorf("policy init error: %w", err)
+		return err
+	}
+	if duration < 0 {
+		return fmt.Errorf("[duration] flag is invalid: %s", duration)
 	}
 
-	p.AddQuery("data.kntrl.policy")
+	command, err := commandArgs(&cmd)
+	if err != nil {
+		return err
+	}
 
-	var ebpfClient = ebpfman.New()
-	if err := ebpfClient.Load(prog); err != nil {
-		return fmt.Errorf("failed to load ebpf program: %w", err)
+	var userFlag = cmd.Flag("user").Value.String()
+	if userFlag != "" && len(command) == 0 {
+		return errors.New("[user] flag is accepted with a command only (kntrl run --user <user> -- <command>)")
+	}
+	var cred *credential
+	if len(command) > 0 {
+		if cred, err = commandCredential(userFlag, os.Getenv); err != nil {
+			return fmt.Errorf("[user] flag is invalid: %w", err)
+		}
 	}
 
-	defer ebpfClient.Clean()
+	opts, err := parseFlags(&cmd)
+	if err != nil {
+		return fmt.Errorf("data json error: %w", err)
+	}
+	opts.Mode = tracerMode
 
-	switch tracerMode {
-	case domain.TracerModeTrace:
-		// set mode for filtering
-		modeMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapMode]
-		if err := modeMap.Put(uint32(0), uint32(domain.TracerModeIndexTrace)); err != nil {
-			logger.Log.Fatalf("failed to set mode: %v", err)
+	// the command runs in its own cgroup, the enforcement is scoped to it
+	if len(command) > 0 {
+		if opts.CgroupPath != "" || opts.ContainerID != "" || opts.ServiceContainer {
+			return errors.New("[cgroup-path], [container-id] and [service-container] flags are not accepted with a command, it runs in its own cgroup")
+		}
+		cgroup, err := newCommandCgroup()
+		if err != nil {
+			return err
 		}
+		defer removeCommandCgroup(cgroup)
+		opts.CgroupPath = cgroup
+	}
 
-	case domain.TracerModeMonitor:
-		// set mode for filtering
-		modeMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapMode]
-		if err := modeMap.Put(uint32(0), uint32(domain.TracerModeIndexMonitor)); err != nil {
-			logger.Log.Fatalf("failed to set mode: %v", err)
+	// the tracer stops as on a signal when the duration elapses
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	t, err := ktracer.New(*opts)
+	if err != nil {
+		if errors.Is(err, ktracer.ErrUnsupportedPlatform) {
+			return fmt.Errorf("%w, kntrl report, events and allow manage a kntrl running on a linux host with --agent", err)
 		}
+		return err
+	}
 
-	default:
-		return fmt.Errorf("invalid mode: %s", tracerMode)
+	// the tracer stops when the command exits
+	var stopTracer = func() {}
+	if len(command) > 0 {
+		ctx, stopTracer = context.WithCancel(ctx)
+		defer stopTracer()
 	}
 
-	allowedIPMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedIP]
-	{
-		for _, ipstr := range cmddata.AllowedIPs {
-			ip := ipstr.To4()
-			var ipUint32 uint32
-			if len(ip) > 16 {
-				ipUint32 = binary.LittleEndian.Uint32(ip[12:16])
-			} else {
-				ipUint32 = binary.LittleEndian.Uint32(ip)
+	if err := t.Start(ctx); err != nil {
+		return err
+	}
 
-			}
-			if err := allowedIPMap.Put(ipUint32, uint32(1)); err != nil {
-				logger.Log.Fatalf("failed to update allow ip (map): %v", err)
-			}
+	// SIGHUP reloads the policy file, it stops the tracer otherwise
+	if opts.PolicyFile != "" {
+		go reloadOnHangup(ctx, t)
+	}
+
+	var commandErr error
+	if len(command) > 0 {
+		commandErr = runCommand(ctx, command, opts.CgroupPath, cred)
+		stopTracer()
+	}
+
+	if err := t.Wait(); err != nil {
+		return err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Log.Infof("stopped after the duration of %s", duration)
+	}
+
+	// the exit code of a failed command takes precedence over the violations
+	if len(command) > 0 {
+		if violations := t.Report().Summary.Block; violations > 0 {
+			logger.Log.Warnf("%d egress violation(s) detected while running the command", violations)
+		}
+		if commandErr != nil {
+			return commandErr
+		}
+	}
+
+	if failOnViolation {
+		if violations := t.Report().Summary.Block; violations >= failThreshold {
+			return &ViolationError{Violations: violations, Threshold: failThreshold}
 		}
 	}
 
-	allowedHostMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedIP]
-	{
-		for _, hosts := range cmddata.AllowedHosts {
-			h := binary.LittleEndian.Uint32([]byte(hosts + "\x00"))
-			if err := allowedHostMap.Put(h, uint32(1)); err != nil {
-				logger.Log.Fatalf("failed to update allow host (map): %v", err)
+	return nil
+}
+
+// reloadOnHangup reloads the policy file of the tracer on SIGHUP until the context is cancelled
+func reloadOnHangup(ctx context.Context, t *ktracer.Tracer) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if err := t.Reload(); err != nil {
+				logger.Log.Errorf("%v", err)
 			}
 		}
+	}
+}
+
+// RunDaemon runs the tracer with the control API until the context is cancelled
+func RunDaemon(ctx context.Context, cmd cobra.Command) error {
+	if cmd.Flag("control-socket").Value.String() == "" {
+		return errors.New("[control-socket] flag is required")
+	}
+	if len(cmd.Flags().Args()) > 0 {
+		return errors.New("kntrl daemon doesn't run a command, use kntrl run -- <command>")
+	}
+
+	return Run(ctx, cmd)
+}
+
+// parseFlags returns the tracer options of the command flags
+// merged with the repository policy
+func parseFlags(cmd *cobra.Command) (*ktracer.Options, error) {
+	allowedHostsFlag := cmd.Flag("allowed-hosts")
+	allowedIPAddrFlag := cmd.Flag("allowed-ips")
+	hostsFlag := cmd.Flag("hosts")
+
+	passive, err := cmd.Flags().GetBool("passive")
+	if err != nil {
+		return nil, err
+	}
+
+	// the policy is not enforced in passive mode, it's evaluated in simulate mode
+	policyFile := cmd.Flag("policy-file").Value.String()
+	if (!passive || cmd.Flag("mode").Value.String() == domain.TracerModeSimulate) && allowedIPAddrFlag.Value.String() == "" && allowedHostsFlag.Value.String() == "" && hostsFlag.Value.String() == "" && policyFile == "" {
+		return nil, errors.New("no allowed hostname or IP addresses provided")
+	}
 
+	ghmeta, err := cmd.Flags().GetBool("allow-github-meta")
+	if err != nil {
+		return nil, err
+	}
+	verboseReport, err := cmd.Flags().GetBool("verbose-report")
+	if err != nil {
+		return nil, err
+	}
+	localranges, err := cmd.Flags().GetBool("allow-local-ranges")
+	if err != nil {
+		return nil, err
+	}
+	noDefaultAllow, err := cmd.Flags().GetBool("no-default-allow")
+	if err != nil {
+		return nil, err
+	}
+	// the local ranges are a built-in default unless given explicitly
+	if noDefaultAllow && !cmd.Flags().Changed("allow-local-ranges") {
+		localranges = false
 	}
 
-	ipv4EventMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapIPV4Events]
-	ipV4Events, err := perf.NewReader(ipv4EventMap, 4096)
+	processPolicy, err := cmd.Flags().GetStringSlice("process-policy")
 	if err != nil {
-		logger.Log.Fatalf("failed to read ipv4 events: %v", err)
+		return nil, err
 	}
 
-	defer ipV4Events.Close()
+	allowedPorts, err := cmd.Flags().GetStringSlice("allowed-ports")
+	if err != nil {
+		return nil, err
+	}
 
-	ipv4ClosedMap := ebpfClient.Collection.Maps[domain.EBPFCollectionMapIPV4ClosedEvents]
-	ipV4ClosedEvent, err := perf.NewReader(ipv4ClosedMap, 4096)
+	reportSelf, err := cmd.Flags().GetBool("report-self")
 	if err != nil {
-		logger.Log.Fatalf("failed to read ipv4 closed events: %v", err)
+		return nil, err
 	}
 
-	defer ipV4ClosedEvent.Close()
+	hygieneRuns, err := cmd.Flags().GetInt("hygiene-runs")
+	if err != nil {
+		return nil, err
+	}
 
-	// allocate memory
-	if err := rlimit.RemoveMemlock(); err != nil {
-		return err
+	serverNames, err := cmd.Flags().GetBool("sni")
+	if err != nil {
+		return nil, err
 	}
 
-	// loop and link
-	for name, spec := range ebpfClient.Spec.Programs {
-		prg := ebpfClient.Collection.Programs[name]
-		logger.Log.WithFields(
-			logrus.Fields{
-				"name":    name,
-				"program": prg,
-			}).Debug("loaded program(s):")
-
-		switch spec.Type {
-		case ebpf.Kprobe:
-			// link Krobe
-			logger.Log.Infof("linking Kprobe [%s]", utils.ParseProgramName(prg))
-			l, err := link.Kprobe(spec.AttachTo, prg, nil)
-			if err != nil {
-				return err
-			}
-			defer l.Close()
-
-		case ebpf.Tracing:
-			logger.Log.Infof("linking tracing [%s]", utils.ParseProgramName(prg))
-			l, err := link.AttachTracing(link.TracingOptions{
-				Program: prg,
-			})
-			if err != nil {
-				return err
-			}
-			defer l.Close()
+	httpHosts, err := cmd.Flags().GetBool("http-host")
+	if err != nil {
+		return nil, err
+	}
 
-		case ebpf.TracePoint:
-			logger.Log.Infof("linking tracepoint [%s]", utils.ParseProgramName(prg))
-			l, err := link.Tracepoint("sock", "inet_sock_set_state", prg, nil)
-			if err != nil {
-				return err
-			}
-			defer l.Close()
+	traceContext, err := cmd.Flags().GetBool("trace-context")
+	if err != nil {
+		return nil, err
+	}
 
-		case ebpf.CGroupSKB:
-			logger.Log.Infof("linking CGroupSKB [%s]", utils.ParseProgramName(prg))
-			cgroup, err := os.Open(rootCgroup)
-			if err != nil {
-				return err
-			}
-			l, err := link.AttachCgroup(link.CgroupOptions{
-				Path:    cgroup.Name(),
-				Attach:  ebpf.AttachCGroupInetEgress,
-				Program: prg,
-			})
-			if err != nil {
-				return err
-			}
-			defer l.Close()
-			defer cgroup.Close()
+	sinks, err := cmd.Flags().GetStringSlice("sink")
+	if err != nil {
+		return nil, err
+	}
 
-		default:
-			logger.Log.Warnf("ebpf program unrecognized: %v", prg)
-		}
+	outputs, err := cmd.Flags().GetStringSlice("output")
+	if err != nil {
+		return nil, err
 	}
 
-	sigs := make(chan os.Signal, 1)
-	done := make(chan bool, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGQUIT, syscall.SIGHUP)
+	sandboxObserve, err := cmd.Flags().GetBool("sandbox-observe")
+	if err != nil {
+		return nil, err
+	}
+
+	stateInterval, err := cmd.Flags().GetDuration("state-interval")
+	if err != nil {
+		return nil, err
+	}
+
+	slowThreshold, err := cmd.Flags().GetDuration("slow-threshold")
+	if err != nil {
+		return nil, err
+	}
 
-	// signal handler
-	go func() {
-		<-sigs
-		done <- true
+	excludeComms, err := cmd.Flags().GetStringSlice("exclude-comm")
+	if err != nil {
+		return nil, err
+	}
+	excludeCgroups, err := cmd.Flags().GetStringSlice("exclude-cgroup")
+	if err != nil {
+		return nil, err
+	}
+	filterPIDs, err := cmd.Flags().GetIntSlice("filter-pid")
+	if err != nil {
+		return nil, err
+	}
+	filterComms, err := cmd.Flags().GetStringSlice("filter-comm")
+	if err != nil {
+		return nil, err
+	}
+	ignoreDests, err := cmd.Flags().GetStringSlice("ignore-dest")
+	if err != nil {
+		return nil, err
+	}
 
-		if err := ipV4Events.Close(); err != nil {
-			logger.Log.Warnf("closing perf reader: %s", err)
+	var opts = &ktracer.Options{
+		Passive:          passive,
+		ExcludeComms:     excludeComms,
+		ExcludeCgroups:   excludeCgroups,
+		FilterPIDs:       filterPIDs,
+		FilterComms:      filterComms,
+		IgnoreDests:      ignoreDests,
+		CgroupPath:       cmd.Flag("cgroup-path").Value.String(),
+		ContainerID:      cmd.Flag("container-id").Value.String(),
+		AllowedHosts:     splitList(allowedHostsFlag.Value.String()),
+		AllowedIPs:       splitList(allowedIPAddrFlag.Value.String()),
+		AllowLocalRanges: localranges,
+		AllowGithubMeta:  ghmeta,
+		NoDefaultAllow:   noDefaultAllow,
+		ProcessRules:     processPolicy,
+		AllowedPorts:     allowedPorts,
+		DeniedHosts:      splitList(cmd.Flag("denied-hosts").Value.String()),
+		DeniedIPs:        splitList(cmd.Flag("denied-ips").Value.String()),
+		OutputFileName:   cmd.Flag("output-file-name").Value.String(),
+		OutputFormat:     cmd.Flag("output-format").Value.String(),
+		Outputs:          outputs,
+		VerboseReport:    verboseReport,
+		StreamOutput:     cmd.Flag("stream-output").Value.String(),
+		StreamFormat:     cmd.Flag("stream-format").Value.String(),
+		Sinks:            sinks,
+		ReportStore:      cmd.Flag("report-store").Value.String(),
+		ViolationWebhook: cmd.Flag("violation-webhook").Value.String(),
+		TimeSource:       cmd.Flag("time-source").Value.String(),
+		ReportSelf:       reportSelf,
+		SNI:              serverNames,
+		HTTPHost:         httpHosts,
+		TraceContext:     traceContext,
+		SandboxObserve:   sandboxObserve,
+		TOFUStore:        cmd.Flag("tofu-store").Value.String(),
+		HygieneStore:     cmd.Flag("hygiene-store").Value.String(),
+		HygieneRuns:      hygieneRuns,
+		StateFile:        cmd.Flag("state-file").Value.String(),
+		StateInterval:    stateInterval,
+		SessionID:        cmd.Flag("session-id").Value.String(),
+		MetricsAddr:      cmd.Flag("metrics-addr").Value.String(),
+		ControlSocket:    cmd.Flag("control-socket").Value.String(),
+		PolicyFile:       policyFile,
+		SlowThreshold:    slowThreshold,
+		MapOverflow:      cmd.Flag("map-overflow").Value.String(),
+		NAT64Prefix:      cmd.Flag("nat64-prefix").Value.String(),
+	}
+
+	if opts.ResolveInterval, err = cmd.Flags().GetDuration("resolve-interval"); err != nil {
+		return nil, err
+	}
+
+	if opts.MinReputation, err = cmd.Flags().GetInt("min-reputation"); err != nil {
+		return nil, err
+	}
+
+	if opts.KillOnViolation, err = cmd.Flags().GetBool("kill-on-violation"); err != nil {
+		return nil, err
+	}
+
+	opts.BlockAction = cmd.Flag("block-action").Value.String()
+	if opts.TarpitDelay, err = cmd.Flags().GetDuration("tarpit-delay"); err != nil {
+		return nil, err
+	}
+
+	if opts.FileAccess, err = cmd.Flags().GetBool("file-access"); err != nil {
+		return nil, err
+	}
+	if opts.SensitiveFiles, err = cmd.Flags().GetStringSlice("sensitive-files"); err != nil {
+		return nil, err
+	}
+	if opts.FileAccessWindow, err = cmd.Flags().GetDuration("file-access-window"); err != nil {
+		return nil, err
+	}
+
+	if opts.Pin, err = cmd.Flags().GetBool("pin"); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxConnectionRate, err = cmd.Flags().GetInt("max-connection-rate"); err != nil {
+		return nil, err
+	}
+	if opts.MaxDestinations, err = cmd.Flags().GetInt("max-destinations"); err != nil {
+		return nil, err
+	}
+	opts.AnomalyAction = cmd.Flag("anomaly-action").Value.String()
+
+	if opts.EscalateFindings, err = cmd.Flags().GetInt("escalate-findings"); err != nil {
+		return nil, err
+	}
+	if opts.EscalateWindow, err = cmd.Flags().GetDuration("escalate-window"); err != nil {
+		return nil, err
+	}
+
+	opts.CertFingerprints = cmd.Flag("cert-fingerprints").Value.String()
+	opts.CaptureViolations = cmd.Flag("capture-violations").Value.String()
+	if opts.CapturePackets, err = cmd.Flags().GetInt("capture-packets"); err != nil {
+		return nil, err
+	}
+
+	if opts.MapStatsInterval, err = cmd.Flags().GetDuration("map-stats-interval"); err != nil {
+		return nil, err
+	}
+	if opts.ProgramStats, err = cmd.Flags().GetBool("program-stats"); err != nil {
+		return nil, err
+	}
+
+	hashDestinations, err := cmd.Flags().GetBool("hash-destinations")
+	if err != nil {
+		return nil, err
+	}
+	if hashDestinations {
+		if opts.HashSalt = cmd.Flag("hash-salt").Value.String(); opts.HashSalt == "" {
+			opts.HashSalt = os.Getenv("KNTRL_HASH_SALT")
+		}
+		if opts.HashSalt == "" {
+			return nil, errors.New("[hash-salt] flag or $KNTRL_HASH_SALT is required with --hash-destinations")
+		}
+	}
+
+	if opts.ServiceContainer, err = cmd.Flags().GetBool("service-container"); err != nil {
+		return nil, err
+	}
+	if opts.ServiceContainer {
+		opts.DockerSocket = cmd.Flag("docker-socket").Value.String()
+		if err := sharedDirDefaults(cmd, opts); err != nil {
+			return nil, err
 		}
-	}()
+	}
 
-	var outputDir = cmd.Flag("output-file-name").Value.String()
+	allowMetadata, err := cmd.Flags().GetBool("allow-metadata-endpoints")
+	if err != nil {
+		return nil, err
+	}
+	opts.NoMetadataEndpoints = !allowMetadata
 
-	report := reporter.NewReporter(outputDir)
-	if report.Err != nil {
-		logger.Log.Fatalf("failed to read ipv4 closed events: %s", err)
+	opts.GithubMetaURL = cmd.Flag("github-meta-url").Value.String()
+	if opts.GithubMetaSections, err = cmd.Flags().GetStringSlice("github-meta-sections"); err != nil {
+		return nil, err
+	}
+	if opts.GithubMetaInterval, err = cmd.Flags().GetDuration("github-meta-interval"); err != nil {
+		return nil, err
 	}
 
-	// IPv4Events
-	for {
-		record, err := ipV4Events.Read()
-		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
-				goto EXIT
-			}
-			logger.Log.Errorf("failed to read perf event: %v", err)
-			continue
+	if opts.VerdictCache = cmd.Flag("verdict-cache").Value.String(); opts.VerdictCache != "" {
+		if opts.VerdictCacheMaxAge, err = cmd.Flags().GetDuration("verdict-cache-max-age"); err != nil {
+			return nil, err
 		}
+	}
 
-		var event domain.IP4Event
-		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-			logger.Log.Printf("failed to parse perf event: %b", err)
-			continue
+	var enrichmentConfig = cmd.Flag("enrichment-config").Value.String()
+	var resolver = cmd.Flag("resolver").Value.String()
+	if enrichmentConfig != "" || resolver != "" {
+		var cfg = enrich.DefaultConfig()
+		if enrichmentConfig != "" {
+			if cfg, err = enrich.LoadConfig(enrichmentConfig); err != nil {
+				return nil, err
+			}
 		}
+		// the resolver of the configuration file takes precedence
+		enrich.SetResolver(&cfg, resolver)
 
-		domainAddress := utils.IntToIP(event.Daddr)
-		domainNames, err := utils.LookupAndTrim(domainAddress)
+		opts.Enrichment, err = enrich.New(cfg)
 		if err != nil {
-			logger.Log.Debugf("failed to lookup domain: [%s] %v", domainAddress.String(), err)
-			domainNames = append(domainNames, ".")
+			return nil, err
 		}
+		logger.Log.Infof("enrichment pipeline: %v", opts.Enrichment.Names())
+	}
+
+	upload, err := uploadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts.Upload = upload
 
-		// evaluate policy
-		var policyStatus = domain.EventPolicyStatusPass
-		taskname := utils.TrimNullBytes(event.Task)
-		if taskname == progName {
-			continue
+	if opts.GitHubComment, err = githubCommentConfig(cmd); err != nil {
+		return nil, err
+	}
+
+	if addr := cmd.Flag("control-addr").Value.String(); addr != "" {
+		var token = cmd.Flag("control-token").Value.String()
+		if token == "" {
+			token = os.Getenv("KNTRL_CONTROL_TOKEN")
 		}
 
-		protocol := utils.GetProtocol(event.Proto)
+		opts.ControlRemote = &control.RemoteConfig{
+			Addr:    addr,
+			Token:   token,
+			TLSCert: cmd.Flag("control-tls-cert").Value.String(),
+			TLSKey:  cmd.Flag("control-tls-key").Value.String(),
+		}
+	}
 
-		var reportEvent = domain.ReportEvent{
-			ProcessID:          event.Pid,
-			TaskName:           taskname,
-			Protocol:           protocol,
-			DestinationAddress: utils.IntToIP(event.Daddr).String(),
-			DestinationPort:    event.Dport,
-			Domains:            domainNames,
-			Policy:             policyStatus,
+	// --hosts takes both the addresses (of both families) and the hostnames
+	hosts, err := utils.ParseHosts(hostsFlag.Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("[hosts] flag is invalid: %w", err)
+	}
+	for _, h := range hosts {
+		if h.Kind == utils.HostName {
+			opts.AllowedHosts = append(opts.AllowedHosts, h.Name)
+		} else {
+			opts.AllowedIPs = append(opts.AllowedIPs, h.String())
 		}
+	}
 
-		// policy logic
-		if tracerMode != domain.TracerModeMonitor {
-			result, err := p.EvalEvent(context.Background(), reportEvent)
-			if err != nil {
-				logger.Log.Debugf("policy eval failed: %v", err)
+	repoPolicy, err := cmd.Flags().GetBool("repo-policy")
+	if err != nil {
+		return nil, err
+	}
+	if repoPolicy {
+		rp, err := readRepoPolicy(cmd, workspace.Guardrails{
+			AllowLocalRanges:       localranges,
+			AllowGithubMeta:        ghmeta,
+			AllowMetadataEndpoints: !opts.NoMetadataEndpoints,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if rp != nil {
+			opts.AllowedHosts = append(opts.AllowedHosts, rp.AllowedHosts...)
+			opts.AllowedIPs = append(opts.AllowedIPs, rp.AllowedIPs...)
+			if rp.AllowLocalRanges != nil {
+				opts.AllowLocalRanges = *rp.AllowLocalRanges
 			}
-			if result {
-				policyStatus = domain.EventPolicyStatusPass
-				if err := allowedIPMap.Put(event.Daddr, uint32(1)); err != nil {
-					logger.Log.Fatalf("failed to update allow list (map): %v", err)
-				}
-				logger.Log.Infof("ip [%d] added into allowed list", event.Daddr)
-
-			} else {
-				policyStatus = domain.EventPolicyStatusBlock
+			if rp.AllowGithubMeta != nil {
+				opts.AllowGithubMeta = *rp.AllowGithubMeta
 			}
-			reportEvent.Policy = policyStatus
+			if rp.AllowMetadataEndpoints != nil {
+				opts.NoMetadataEndpoints = !*rp.AllowMetadataEndpoints
+			}
+			opts.ProcessRules = append(opts.ProcessRules, rp.ProcessPolicy...)
 		}
+	}
+
+	return opts, nil
+}
 
-		// report
-		report.WriteEvent(reportEvent)
+// sharedDirDefaults writes the report, the live events and the control socket
+// to the shared directory of the service container mode, unless given by the
+// flags. The job reads the events and the report with kntrl report while
+// kntrl runs, the final report is written when the service container stops.
+func sharedDirDefaults(cmd *cobra.Command, opts *ktracer.Options) error {
+	var dir = cmd.Flag("shared-dir").Value.String()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create shared directory: %w", err)
+	}
 
-		logger.Log.Infof("[%d]%s -> %s:%d (%s) [%s]| %s",
-			event.Pid,
-			taskname,
-			utils.IntToIP(event.Daddr),
-			event.Dport,
-			domainNames,
-			protocol,
-			policyStatus,
-		)
+	if !cmd.Flags().Changed("output-file-name") {
+		opts.OutputFileName = filepath.Join(dir, "kntrl.out")
+	}
+	if !cmd.Flags().Changed("stream-output") {
+		opts.StreamOutput = filepath.Join(dir, "events.jsonl")
+	}
+	if !cmd.Flags().Changed("control-socket") {
+		opts.ControlSocket = filepath.Join(dir, "kntrl.sock")
 	}
+	logger.Log.Infof("the report, the events and the control socket are in the shared directory [%s]", dir)
 
-EXIT:
-	<-done
-	report.PrintReportTable()
-	report.Close()
 	return nil
 }
 
-func parseFlags(cmd *cobra.Command) (*domain.Data, error) {
-	allowedHostsFlag := cmd.Flag("allowed-hosts")
-	allowedIPAddrFlag := cmd.Flag("allowed-ips")
+// uploadConfig returns the report upload configuration of the flags, the unset
+// flags fall back to the environment. It returns nil if no Kondukto url is given.
+func uploadConfig(cmd *cobra.Command) (*reporter.UploadConfig, error) {
+	var flagOrEnv = func(flag, env string) string {
+		if v := cmd.Flag(flag).Value.String(); v != "" {
+			return v
+		}
+		return os.Getenv(env)
+	}
 
-	if allowedIPAddrFlag.Value.String() == "" && allowedHostsFlag.Value.String() == "" {
-		return nil, errors.New("no allowed hostname or IP addresses provided")
+	var cfg = reporter.UploadConfig{
+		URL:     flagOrEnv("kondukto-url", "KONDUKTO_HOST"),
+		Token:   flagOrEnv("kondukto-token", "KONDUKTO_TOKEN"),
+		Project: flagOrEnv("project", "KONDUKTO_PROJECT"),
+		CACert:  cmd.Flag("kondukto-ca-cert").Value.String(),
+	}
+	if cfg.URL == "" {
+		return nil, nil
 	}
 
-	ghmeta, err := cmd.Flags().GetBool("allow-github-meta")
+	insecure, err := cmd.Flags().GetBool("kondukto-insecure")
 	if err != nil {
 		return nil, err
 	}
-	localranges, err := cmd.Flags().GetBool("allow-local-ranges")
+	cfg.Insecure = insecure
+
+	if cfg.Retries, err = cmd.Flags().GetInt("kondukto-retries"); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// githubCommentConfig returns the pull request comment configuration of the
+// GitHub Actions environment. It returns nil if --github-comment is not set.
+func githubCommentConfig(cmd *cobra.Command) (*reporter.GitHubConfig, error) {
+	comment, err := cmd.Flags().GetBool("github-comment")
 	if err != nil {
 		return nil, err
 	}
+	if !comment {
+		return nil, nil
+	}
+
+	cfg, err := reporter.GitHubConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if token := cmd.Flag("github-token").Value.String(); token != "" {
+		cfg.Token = token
+	}
+
+	return &cfg, nil
+}
+
+// splitList splits the comma separated flag value
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// readRepoPolicy reads the repository policy (.kntrl.yaml) in the workspace and
+// returns the entries allowed by the guardrails. It returns nil if there's no policy file.
+func readRepoPolicy(cmd *cobra.Command, guardrails workspace.Guardrails) (*domain.RepoPolicy, error) {
+	var dir = cmd.Flag("workspace").Value.String()
+	if dir == "" {
+		dir = workspace.DefaultDir()
+	}
+
+	keyFiles, err := cmd.Flags().GetStringSlice("repo-policy-key")
+	if err != nil {
+		return nil, err
+	}
+	// the policy must be signed, otherwise anyone with write access to the
+	// repository could allow their own endpoints
+	if len(keyFiles) == 0 {
+		return nil, errors.New("[repo-policy] flag requires at least one [repo-policy-key]")
+	}
+	keys, err := workspace.LoadKeys(keyFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	rp, path, err := workspace.Discover(dir, keys)
+	if errors.Is(err, workspace.ErrUnsigned) {
+		// an unsigned policy is ignored, the run continues with the organization policy
+		logger.Log.Errorf("repository policy [%s] is ignored: %v", path, err)
+		return nil, nil
+	}
+	if err != nil || rp == nil {
+		return nil, err
+	}
+
+	scope, err := cmd.Flags().GetStringSlice("repo-policy-scope")
+	if err != nil {
+		return nil, err
+	}
+	guardrails.Scope = scope
+
+	accepted, rejected := workspace.Restrict(rp, guardrails)
+	for _, reason := range rejected {
+		logger.Log.Warnf("repository policy [%s] entry rejected: %s", path, reason)
+	}
+
+	logger.Log.Infof("repository policy [%s] loaded with %d host(s) and %d ip(s)", path, len(accepted.AllowedHosts), len(accepted.AllowedIPs))
 
-	return parser.ToDataJson(
-		allowedHostsFlag.Value.String(),
-		allowedIPAddrFlag.Value.String(),
-		ghmeta,
-		localranges,
-	), nil
+	return &accepted, nil
 }
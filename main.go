@@ -2,10 +2,18 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/kondukto-io/kntrl/cmd/cli"
 )
 
 func main() {
-	cli.Execute(os.Args[1:])
+	var args = os.Args[1:]
+	// the service containers of GitHub Actions take no command, the
+	// arguments of the image entrypoint are given by the environment
+	if len(args) == 0 {
+		args = strings.Fields(os.Getenv("KNTRL_ARGS"))
+	}
+
+	cli.Execute(args)
 }
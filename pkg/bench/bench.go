@@ -0,0 +1,188 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDuration = 10 * time.Second
+	defaultWorkers  = 8
+	dialTimeout     = 2 * time.Second
+)
+
+// Config is the configuration of the synthetic connection load
+type Config struct {
+	// Duration is the duration of the load (default 10s)
+	Duration time.Duration
+	// Workers is the number of the concurrent clients (default 8)
+	Workers int
+}
+
+// Result is the throughput and the latency of the load
+type Result struct {
+	Connections int
+	Errors      int
+	Duration    time.Duration
+	// P50 and P99 are the latencies of a connection (connect, a round trip of
+	// a byte and close)
+	P50 time.Duration
+	P99 time.Duration
+}
+
+// Rate returns the connections per second
+func (r Result) Rate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Connections) / r.Duration.Seconds()
+}
+
+// Run generates the connection load in a new network namespace, the clients
+// connect to an echo server on the loopback interface of the namespace. The
+// traffic doesn't leave the namespace but it's seen by the probes and the
+// cgroup programs of a running kntrl as any other connection.
+func Run(ctx context.Context, cfg Config) (Result, error) {
+	if cfg.Duration <= 0 {
+		cfg.Duration = defaultDuration
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+
+	ns, err := newNetns()
+	if err != nil {
+		return Result{}, err
+	}
+	defer ns.Close()
+
+	listener, err := ns.listen()
+	if err != nil {
+		return Result{}, err
+	}
+	defer listener.Close()
+	go echo(listener)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errCount  int
+		enterErr  error
+		wg        sync.WaitGroup
+		start     = time.Now()
+	)
+
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// the thread is not unlocked, it's discarded when the worker returns
+			if err := ns.enter(); err != nil {
+				mu.Lock()
+				enterErr = err
+				mu.Unlock()
+				return
+			}
+
+			var local []time.Duration
+			var failed int
+			for ctx.Err() == nil {
+				latency, err := roundTrip(listener.Addr().String())
+				if err != nil {
+					failed++
+					continue
+				}
+				local = append(local, latency)
+			}
+
+			mu.Lock()
+			latencies = append(latencies, local...)
+			errCount += failed
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if enterErr != nil {
+		return Result{}, enterErr
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	return Result{
+		Connections: len(latencies),
+		Errors:      errCount,
+		Duration:    time.Since(start),
+		P50:         percentile(latencies, 0.50),
+		P99:         percentile(latencies, 0.99),
+	}, nil
+}
+
+// roundTrip connects to the echo server, sends a byte and reads it back
+func roundTrip(addr string) (time.Duration, error) {
+	var start = time.Now()
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return 0, err
+	}
+
+	var b = []byte{'k'}
+	if _, err := conn.Write(b); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+// echo writes back the first byte of each connection until the listener is closed
+func echo(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		go func() {
+			defer conn.Close()
+
+			var b = make([]byte, 1)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				return
+			}
+			_, _ = conn.Write(b)
+		}()
+	}
+}
+
+// percentile returns the percentile of the sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	var i = int(float64(len(sorted)-1) * p)
+
+	return sorted[i]
+}
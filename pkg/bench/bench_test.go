@@ -0,0 +1,40 @@
+package bench
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted = []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	if got := percentile(sorted, 0.50); got != 5 {
+		t.Errorf("expected p50 5, got %d", got)
+	}
+	if got := percentile(sorted, 0.99); got != 9 {
+		t.Errorf("expected p99 9, got %d", got)
+	}
+	if got := percentile(nil, 0.99); got != 0 {
+		t.Errorf("expected 0 without latencies, got %d", got)
+	}
+
+	if rate := (Result{Connections: 500, Duration: 2 * time.Second}).Rate(); rate != 250 {
+		t.Errorf("expected 250 connections/s, got %f", rate)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("network namespaces require root privileges")
+	}
+
+	res, err := Run(context.Background(), Config{Duration: 200 * time.Millisecond, Workers: 2})
+	if err != nil {
+		t.Skipf("network namespaces are not available: %v", err)
+	}
+	if res.Connections == 0 || res.P50 == 0 || res.P99 < res.P50 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,115 @@
+package bench
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// netns is a network namespace with the loopback interface up
+type netns struct {
+	fd int
+}
+
+// newNetns creates a network namespace, the namespace is kept by the
+// file descriptor after the creating thread is discarded
+func newNetns() (*netns, error) {
+	type result struct {
+		fd  int
+		err error
+	}
+	var ch = make(chan result, 1)
+
+	go func() {
+		// the thread is not unlocked, it's discarded when the goroutine returns
+		runtime.LockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			ch <- result{err: fmt.Errorf("failed to create network namespace: %w", err)}
+			return
+		}
+		if err := loopbackUp(); err != nil {
+			ch <- result{err: err}
+			return
+		}
+
+		fd, err := unix.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			ch <- result{err: fmt.Errorf("failed to open network namespace: %w", err)}
+			return
+		}
+		ch <- result{fd: fd}
+	}()
+
+	r := <-ch
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &netns{fd: r.fd}, nil
+}
+
+// enter locks the calling goroutine to its thread and moves the thread into
+// the namespace, the sockets created by the goroutine belong to the namespace
+func (n *netns) enter() error {
+	runtime.LockOSThread()
+	if err := unix.Setns(n.fd, unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("failed to enter network namespace: %w", err)
+	}
+
+	return nil
+}
+
+// listen listens on a free port of the loopback interface of the namespace
+func (n *netns) listen() (net.Listener, error) {
+	type result struct {
+		listener net.Listener
+		err      error
+	}
+	var ch = make(chan result, 1)
+
+	go func() {
+		if err := n.enter(); err != nil {
+			ch <- result{err: err}
+			return
+		}
+
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		ch <- result{listener, err}
+	}()
+
+	r := <-ch
+
+	return r.listener, r.err
+}
+
+// Close releases the namespace, it's removed by the kernel when the last socket is closed
+func (n *netns) Close() error {
+	return unix.Close(n.fd)
+}
+
+// loopbackUp brings the loopback interface of the current network namespace up
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to read loopback flags: %w", err)
+	}
+
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to bring loopback up: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package bench
+
+import (
+	"errors"
+	"net"
+)
+
+// netns is not supported on this platform
+type netns struct{}
+
+func newNetns() (*netns, error) {
+	return nil, errors.New("the benchmark requires linux network namespaces")
+}
+
+func (n *netns) enter() error {
+	return nil
+}
+
+func (n *netns) listen() (net.Listener, error) {
+	return nil, errors.New("the benchmark requires linux network namespaces")
+}
+
+// Close does nothing on this platform
+func (n *netns) Close() error {
+	return nil
+}
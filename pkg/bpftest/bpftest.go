@@ -0,0 +1,178 @@
+// Package bpftest runs the eBPF programs of kntrl with BPF_PROG_TEST_RUN on
+// crafted packets. The verdict logic of the egress program is verified in CI
+// without attaching it to a cgroup or sending any traffic.
+package bpftest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+	"github.com/kondukto-io/kntrl/pkg/tracer"
+)
+
+// RequireEnv makes the missing requirements (the capabilities, the generated eBPF object,
+// the kernel support of the test runs) fail the tests instead of skipping
+// them, it is set in CI
+const RequireEnv = "KNTRL_BPF_TEST"
+
+// modes are the mode indexes of the mode map
+var modes = map[string]uint32{
+	domain.TracerModeMonitor: domain.TracerModeIndexMonitor,
+	domain.TracerModeTrace:   domain.TracerModeIndexTrace,
+}
+
+// objects are the egress program and the maps of its verdict, the other
+// programs of the object are not loaded
+type objects struct {
+	Egress         *ebpf.Program `ebpf:"egress"`
+	ModeMap        *ebpf.Map     `ebpf:"mode_map"`
+	AllowedIPMap   *ebpf.Map     `ebpf:"allowed_ip_map"`
+	AllowedPortMap *ebpf.Map     `ebpf:"allowed_port_map"`
+	DenyMap        *ebpf.Map     `ebpf:"deny_map"`
+	NAT64PrefixMap *ebpf.Map     `ebpf:"nat64_prefix_map"`
+}
+
+// Harness is the egress program loaded with empty allow and deny lists in the monitor mode
+type Harness struct {
+	objs objects
+}
+
+// New loads the egress program of the embedded object
+func New() (*Harness, error) {
+	spec, err := tracer.Spec()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ebpf spec (make generate): %w", err)
+	}
+
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, err
+	}
+
+	var h = &Harness{}
+	if err := spec.LoadAndAssign(&h.objs, nil); err != nil {
+		return nil, fmt.Errorf("failed to load egress program: %w", err)
+	}
+
+	return h, nil
+}
+
+// Require returns the harness of the test, the test is skipped if the
+// requirements are missing unless RequireEnv is set
+func Require(t testing.TB) *Harness {
+	t.Helper()
+
+	var required = os.Getenv(RequireEnv) != ""
+	if err := ebpfman.CheckPrivileges(true); err != nil {
+		if required {
+			t.Fatal(err)
+		}
+		t.Skip(err)
+	}
+
+	h, err := New()
+	if err != nil {
+		if required {
+			t.Fatal(err)
+		}
+		t.Skip(err)
+	}
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+// SetMode sets the mode of the program (domain.TracerModeMonitor or domain.TracerModeTrace)
+func (h *Harness) SetMode(mode string) error {
+	index, ok := modes[mode]
+	if !ok {
+		return fmt.Errorf("invalid mode: %s", mode)
+	}
+
+	return h.objs.ModeMap.Put(uint32(0), index)
+}
+
+// Allow adds the address into the allow list
+func (h *Harness) Allow(ip string) error {
+	key, err := ipv4Key(ip)
+	if err != nil {
+		return err
+	}
+
+	return h.objs.AllowedIPMap.Put(key, uint32(1))
+}
+
+// AllowPort adds the address:port into the allowed ports, the empty address matches any destination
+func (h *Harness) AllowPort(ip string, port uint16) error {
+	var key = ebpfman.PortKey{Port: port}
+	if ip != "" {
+		addr, err := ipv4Key(ip)
+		if err != nil {
+			return err
+		}
+		key.Addr = addr
+	}
+
+	return h.objs.AllowedPortMap.Put(key, uint32(1))
+}
+
+// Deny adds the address into the deny list
+func (h *Harness) Deny(ip string) error {
+	key, err := ipv4Key(ip)
+	if err != nil {
+		return err
+	}
+
+	return h.objs.DenyMap.Put(key, uint32(1))
+}
+
+// SetNAT64Prefix sets the /96 NAT64 prefix, the IPv6 packets to the prefix are
+// evaluated as the packets to the embedded IPv4 address
+func (h *Harness) SetNAT64Prefix(prefix string) error {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil || !p.Addr().Is6() || p.Bits() != 96 {
+		return fmt.Errorf("not an IPv6 /96 prefix: %s", prefix)
+	}
+
+	return h.objs.NAT64PrefixMap.Put(uint32(0), ebpfman.NAT64Prefix{Prefix: nat64.Bytes(p), Enabled: 1})
+}
+
+// Egress runs the egress program on the packet and returns true if it passes
+func (h *Harness) Egress(packet []byte) (bool, error) {
+	ret, err := h.objs.Egress.Run(&ebpf.RunOptions{Data: packet})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			return false, fmt.Errorf("the kernel doesn't support the test runs of the cgroup_skb programs: %w", err)
+		}
+		return false, err
+	}
+
+	return ret == 1, nil
+}
+
+// Close releases the program and the maps
+func (h *Harness) Close() {
+	for _, closer := range []interface{ Close() error }{
+		h.objs.Egress, h.objs.ModeMap, h.objs.AllowedIPMap, h.objs.AllowedPortMap, h.objs.DenyMap, h.objs.NAT64PrefixMap,
+	} {
+		_ = closer.Close()
+	}
+}
+
+func ipv4Key(ip string) (ebpfman.IPv4Key, error) {
+	key, ok := ebpfman.NewIPv4Key(net.ParseIP(ip))
+	if !ok {
+		return key, fmt.Errorf("not an IPv4 address: %s", ip)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,100 @@
+package bpftest
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	source    = "10.0.0.2"
+	source6   = "fd00::2"
+	allowed   = "140.82.114.22"
+	denied    = "198.51.100.7"
+	unknown   = "203.0.113.9"
+	portOnly  = "192.0.2.10"
+	anyPort   = 8443
+	scopedTLS = 443
+)
+
+func TestEgress(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		packet []byte
+		pass   bool
+	}{
+		{"monitor passes an unknown destination", domain.TracerModeMonitor, IPv4(source, unknown, syscall.IPPROTO_TCP, 443), true},
+		{"monitor blocks a denied destination", domain.TracerModeMonitor, IPv4(source, denied, syscall.IPPROTO_TCP, 443), false},
+		{"trace passes an allowed destination", domain.TracerModeTrace, IPv4(source, allowed, syscall.IPPROTO_TCP, 443), true},
+		{"trace passes an allowed destination on any port", domain.TracerModeTrace, IPv4(source, allowed, syscall.IPPROTO_UDP, 53), true},
+		{"trace blocks an unknown destination", domain.TracerModeTrace, IPv4(source, unknown, syscall.IPPROTO_TCP, 443), false},
+		{"trace blocks a denied destination", domain.TracerModeTrace, IPv4(source, denied, syscall.IPPROTO_TCP, 443), false},
+		{"trace passes the allowed port of an address", domain.TracerModeTrace, IPv4(source, portOnly, syscall.IPPROTO_TCP, scopedTLS), true},
+		{"trace blocks another port of the address", domain.TracerModeTrace, IPv4(source, portOnly, syscall.IPPROTO_TCP, 80), false},
+		{"trace passes a port allowed for any address", domain.TracerModeTrace, IPv4(source, unknown, syscall.IPPROTO_UDP, anyPort), true},
+		// a dual-stack socket connecting to ::ffff:<addr> sends IPv4 packets,
+		// the mapped destinations are matched as IPv4 addresses
+		{"trace blocks the v4-mapped unknown destination", domain.TracerModeTrace, IPv4(source, "::ffff:"+unknown, syscall.IPPROTO_TCP, 443), false},
+		{"trace passes the v4-mapped allowed destination", domain.TracerModeTrace, IPv4(source, "::ffff:"+allowed, syscall.IPPROTO_TCP, 443), true},
+		// the IPv6 packets to the NAT64 prefix are evaluated as the packets to the embedded address
+		{"trace passes the NAT64 allowed destination", domain.TracerModeTrace, IPv6(source6, "64:ff9b::"+allowed, syscall.IPPROTO_TCP, 443), true},
+		{"trace blocks the NAT64 unknown destination", domain.TracerModeTrace, IPv6(source6, "64:ff9b::"+unknown, syscall.IPPROTO_TCP, 443), false},
+		{"monitor blocks the NAT64 denied destination", domain.TracerModeMonitor, IPv6(source6, "64:ff9b::"+denied, syscall.IPPROTO_TCP, 443), false},
+		{"trace passes the allowed port of the NAT64 address", domain.TracerModeTrace, IPv6(source6, "64:ff9b::"+portOnly, syscall.IPPROTO_TCP, scopedTLS), true},
+		// the other IPv6 packets are not evaluated by the program
+		{"trace passes IPv6", domain.TracerModeTrace, IPv6(source6, "2001:db8::1", syscall.IPPROTO_TCP, 443), true},
+	}
+
+	var h = Require(t)
+	if err := h.Allow(allowed); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Allow(denied); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Deny(denied); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AllowPort(portOnly, scopedTLS); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AllowPort("", anyPort); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetNAT64Prefix("64:ff9b::/96"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := h.SetMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			pass, err := h.Egress(tt.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pass != tt.pass {
+				t.Errorf("expected pass=%v, got %v", tt.pass, pass)
+			}
+		})
+	}
+}
+
+func TestIPv4(t *testing.T) {
+	var packet = IPv4(source, allowed, syscall.IPPROTO_TCP, 443)
+
+	if len(packet) != ethHeaderLen+ipv4HeaderLen+tcpHeaderLen {
+		t.Fatalf("unexpected packet length: %d", len(packet))
+	}
+	// the checksum of a valid header (checksum included) is zero
+	if sum := checksum(packet[ethHeaderLen : ethHeaderLen+ipv4HeaderLen]); sum != 0 {
+		t.Errorf("invalid IPv4 header checksum: %#x", sum)
+	}
+	if packet[ethHeaderLen+16] != 140 || packet[ethHeaderLen+ipv4HeaderLen+2] != 1 || packet[ethHeaderLen+ipv4HeaderLen+3] != 187 {
+		t.Errorf("unexpected destination: % x", packet[ethHeaderLen:])
+	}
+}
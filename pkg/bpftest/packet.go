@@ -0,0 +1,94 @@
+package bpftest
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+const (
+	ethHeaderLen  = 14
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	tcpHeaderLen  = 20
+	udpHeaderLen  = 8
+	// the EtherTypes of the frames, syscall.ETH_P_IP and syscall.ETH_P_IPV6
+	// are defined on linux only
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+	// sourcePort is the source port of the crafted packets
+	sourcePort = 40000
+)
+
+// IPv4 returns the ethernet frame of an IPv4 TCP SYN (or UDP datagram) to
+// daddr:dport, the cgroup_skb program sees the packet from the IP header
+func IPv4(saddr, daddr string, proto uint8, dport uint16) []byte {
+	var transport = transportHeader(proto, dport)
+	var packet = make([]byte, ethHeaderLen+ipv4HeaderLen+len(transport))
+
+	binary.BigEndian.PutUint16(packet[12:], etherTypeIPv4)
+
+	var ip = packet[ethHeaderLen:]
+	ip[0] = 4<<4 | ipv4HeaderLen/4
+	binary.BigEndian.PutUint16(ip[2:], uint16(ipv4HeaderLen+len(transport)))
+	ip[8] = 64 // ttl
+	ip[9] = proto
+	copy(ip[12:16], net.ParseIP(saddr).To4())
+	copy(ip[16:20], net.ParseIP(daddr).To4())
+	binary.BigEndian.PutUint16(ip[10:], checksum(ip[:ipv4HeaderLen]))
+	copy(ip[ipv4HeaderLen:], transport)
+
+	return packet
+}
+
+// IPv6 returns the ethernet frame of an IPv6 TCP SYN (or UDP datagram) to daddr:dport
+func IPv6(saddr, daddr string, proto uint8, dport uint16) []byte {
+	var transport = transportHeader(proto, dport)
+	var packet = make([]byte, ethHeaderLen+ipv6HeaderLen+len(transport))
+
+	binary.BigEndian.PutUint16(packet[12:], etherTypeIPv6)
+
+	var ip = packet[ethHeaderLen:]
+	ip[0] = 6 << 4
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(transport)))
+	ip[6] = proto
+	ip[7] = 64 // hop limit
+	copy(ip[8:24], net.ParseIP(saddr).To16())
+	copy(ip[24:40], net.ParseIP(daddr).To16())
+	copy(ip[ipv6HeaderLen:], transport)
+
+	return packet
+}
+
+// transportHeader returns the TCP header of a SYN or the UDP header
+func transportHeader(proto uint8, dport uint16) []byte {
+	if proto == syscall.IPPROTO_UDP {
+		var udp = make([]byte, udpHeaderLen)
+		binary.BigEndian.PutUint16(udp[0:], sourcePort)
+		binary.BigEndian.PutUint16(udp[2:], dport)
+		binary.BigEndian.PutUint16(udp[4:], udpHeaderLen)
+		return udp
+	}
+
+	var tcp = make([]byte, tcpHeaderLen)
+	binary.BigEndian.PutUint16(tcp[0:], sourcePort)
+	binary.BigEndian.PutUint16(tcp[2:], dport)
+	tcp[12] = tcpHeaderLen / 4 << 4
+	tcp[13] = 0x02 // SYN
+	binary.BigEndian.PutUint16(tcp[14:], 64240)
+
+	return tcp
+}
+
+// checksum returns the internet checksum of the IPv4 header
+func checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,148 @@
+// Package certpin records the certificates of the TLS destinations over the
+// runs. A destination presenting a public key not seen before is a sign of a
+// MITM proxy or of a hijack of its infrastructure.
+package certpin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// maxKeys is the number of the public keys kept per destination, the load
+// balanced destinations and the key rotations have several keys
+const maxKeys = 8
+
+// Store keeps the certificates of the TLS destinations over the runs
+type Store struct {
+	path    string
+	mu      sync.Mutex
+	history domain.CertHistory
+}
+
+// Load reads the certificate file. An empty history is returned if the file doesn't exist.
+func Load(path string) (*Store, error) {
+	var s = &Store{
+		path:    path,
+		history: domain.CertHistory{Hosts: make(map[string]domain.CertRecord)},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal certificates: %w", err)
+	}
+	if s.history.Hosts == nil {
+		s.history.Hosts = make(map[string]domain.CertRecord)
+	}
+
+	return s, nil
+}
+
+// Observe records the certificate of the destination (host:port). It returns
+// the previous record and true if the public key is not known for a
+// destination seen before.
+func (s *Store) Observe(dest string, cert domain.Certificate, now time.Time) (domain.CertRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, seen := s.history.Hosts[dest]
+
+	var record = previous
+	if !seen {
+		record.FirstSeen = now
+	}
+	var changed = seen && !slices.Contains(previous.Keys, cert.SPKI)
+
+	record.Keys = slices.DeleteFunc(slices.Clone(previous.Keys), func(k string) bool { return k == cert.SPKI })
+	record.Keys = append(record.Keys, cert.SPKI)
+	if len(record.Keys) > maxKeys {
+		record.Keys = record.Keys[len(record.Keys)-maxKeys:]
+	}
+	record.Certificate = cert
+	record.LastSeen = now
+	s.history.Hosts[dest] = record
+
+	return previous, changed
+}
+
+// Save persists the history
+func (s *Store) Save(now time.Time) error {
+	s.mu.Lock()
+	s.history.UpdatedAt = now
+	data, err := json.MarshalIndent(s.history, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificates: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create certificates directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write certificates: %w", err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Fingerprint returns the hashes and the names of the certificate
+func Fingerprint(cert *x509.Certificate) domain.Certificate {
+	var fingerprint = sha256.Sum256(cert.Raw)
+	var spki = sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return domain.Certificate{
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		SPKI:        hex.EncodeToString(spki[:]),
+		Subject:     cert.Subject.CommonName,
+		Issuer:      cert.Issuer.CommonName,
+		NotAfter:    cert.NotAfter,
+	}
+}
+
+// Probe connects to the address and returns the leaf certificate presented
+// for the server name. The certificate is recorded, not verified: a
+// certificate of an untrusted issuer is what a MITM proxy presents.
+func Probe(ctx context.Context, addr string, port uint16, serverName string) (domain.Certificate, error) {
+	var dialer = tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(int(port))))
+	if err != nil {
+		return domain.Certificate{}, err
+	}
+	defer conn.Close()
+
+	var certs = conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return domain.Certificate{}, errors.New("no certificate presented")
+	}
+
+	return Fingerprint(certs[0]), nil
+}
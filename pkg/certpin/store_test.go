@@ -0,0 +1,111 @@
+package certpin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestStore(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "certs.json")
+	var now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var runs = []struct {
+		spki    string
+		changed bool
+	}{
+		{"key-a", false},
+		{"key-a", false},
+		{"key-b", true},
+		// a known key of a load balanced destination
+		{"key-a", false},
+	}
+
+	for i, run := range runs {
+		store, err := Load(path)
+		if err != nil {
+			t.Fatalf("failed to load the certificates: %v", err)
+		}
+
+		previous, changed := store.Observe("github.com:443", certificate(run.spki), now.Add(time.Duration(i)*time.Hour))
+		if changed != run.changed {
+			t.Errorf("[%d] expected changed %v, got %v", i, run.changed, changed)
+		}
+		if changed && previous.Certificate.SPKI != "key-a" {
+			t.Errorf("[%d] expected the previous certificate, got %+v", i, previous.Certificate)
+		}
+
+		if err := store.Save(now); err != nil {
+			t.Fatalf("failed to save the certificates: %v", err)
+		}
+	}
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := store.history.Hosts["github.com:443"]
+	if len(record.Keys) != 2 || record.Keys[1] != "key-a" {
+		t.Errorf("expected the keys with the latest last, got %v", record.Keys)
+	}
+	if !record.FirstSeen.Equal(now) || !record.LastSeen.Equal(now.Add(3*time.Hour)) {
+		t.Errorf("unexpected record times: %s %s", record.FirstSeen, record.LastSeen)
+	}
+}
+
+func TestStoreMaxKeys(t *testing.T) {
+	store, err := Load(filepath.Join(t.TempDir(), "certs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxKeys+2; i++ {
+		store.Observe("example.com:443", certificate("key-"+strconv.Itoa(i)), time.Now())
+	}
+
+	record := store.history.Hosts["example.com:443"]
+	if len(record.Keys) != maxKeys || record.Keys[0] != "key-2" {
+		t.Errorf("expected the latest %d keys, got %v", maxKeys, record.Keys)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cert, err := Probe(ctx, u.Hostname(), uint16(port), "example.com")
+	if err != nil {
+		t.Fatalf("failed to probe the server: %v", err)
+	}
+
+	var expected = Fingerprint(server.Certificate())
+	if cert != expected {
+		t.Errorf("expected %+v, got %+v", expected, cert)
+	}
+	if len(cert.SPKI) != 64 || len(cert.Fingerprint) != 64 {
+		t.Errorf("expected the hex encoded SHA-256 hashes, got %+v", cert)
+	}
+}
+
+func certificate(spki string) domain.Certificate {
+	return domain.Certificate{Fingerprint: "fp-" + spki, SPKI: spki, Subject: "github.com", Issuer: "CA"}
+}
@@ -0,0 +1,19 @@
+package clock
+
+import "syscall"
+
+// clock state returned by adjtimex
+const timeError = 5
+
+// kernelSyncStatus returns whether the kernel clock is disciplined by NTP
+// and the maximum error estimate in microseconds
+func kernelSyncStatus() (bool, int64) {
+	var tx syscall.Timex
+
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return false, 0
+	}
+
+	return state != timeError, int64(tx.Maxerror)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package clock
+
+// kernelSyncStatus is not supported on this platform
+func kernelSyncStatus() (bool, int64) {
+	return false, 0
+}
@@ -0,0 +1,84 @@
+package clock
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	// SourceSystem uses the system wall clock at the start of the run
+	SourceSystem = "system"
+
+	// SourceNTP uses an NTP server (ntp://host[:port]) at the start of the run
+	SourceNTP = "ntp"
+
+	ntpDefaultPort = "123"
+	ntpTimeout     = 5 * time.Second
+)
+
+// Clock is a monotonic clock anchored to a trusted time source.
+// The wall time is read once at the start and the monotonic clock is used
+// afterwards, so the timestamps are not affected by clock steps during the run.
+type Clock struct {
+	base  time.Time
+	start time.Time
+	info  domain.ClockInfo
+}
+
+// New returns a new clock for the given time source (system or ntp://host[:port])
+func New(source string) (*Clock, error) {
+	if source == "" || source == SourceSystem {
+		return newClock(SourceSystem, 0, 0), nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != SourceNTP || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid time source: %s", source)
+	}
+
+	var port = u.Port()
+	if port == "" {
+		port = ntpDefaultPort
+	}
+
+	offset, rtt, err := QueryNTP(net.JoinHostPort(u.Hostname(), port), ntpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time source [%s]: %w", source, err)
+	}
+
+	return newClock(source, offset, rtt), nil
+}
+
+func newClock(source string, offset, rtt time.Duration) *Clock {
+	// time.Now carries the monotonic reading, the base does not
+	start := time.Now()
+
+	var c = &Clock{
+		base:  start.Add(offset).Round(0),
+		start: start,
+		info: domain.ClockInfo{
+			Source:      source,
+			OffsetNs:    offset.Nanoseconds(),
+			RoundTripNs: rtt.Nanoseconds(),
+			MeasuredAt:  start.Add(offset).UTC(),
+		},
+	}
+
+	c.info.Synchronized, c.info.MaxErrorUs = kernelSyncStatus()
+
+	return c
+}
+
+// Now returns the current time of the time source
+func (c *Clock) Now() time.Time {
+	return c.base.Add(time.Since(c.start))
+}
+
+// Info returns the clock details to be recorded in the report header
+func (c *Clock) Info() domain.ClockInfo {
+	return c.info
+}
@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	ntpPacketSize = 48
+	// seconds between 1900-01-01 (NTP epoch) and 1970-01-01 (unix epoch)
+	ntpEpochOffset = 2208988800
+	// LI = 0 (no warning), VN = 4, Mode = 3 (client)
+	ntpClientHeader = 0x23
+	ntpModeServer   = 4
+	ntpLeapAlarm    = 3
+)
+
+// QueryNTP queries the NTP server with a single SNTP request (RFC 4330)
+// and returns the clock offset of the local clock and the round trip delay
+func QueryNTP(addr string, timeout time.Duration) (time.Duration, time.Duration, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, 0, err
+	}
+
+	var req = make([]byte, ntpPacketSize)
+	req[0] = ntpClientHeader
+
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, 0, fmt.Errorf("failed to send ntp request: %w", err)
+	}
+
+	var resp = make([]byte, ntpPacketSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read ntp response: %w", err)
+	}
+	t4 := time.Now()
+
+	if n < ntpPacketSize {
+		return 0, 0, errors.New("short ntp response")
+	}
+
+	if leap, mode := resp[0]>>6, resp[0]&0x07; mode != ntpModeServer || leap == ntpLeapAlarm {
+		return 0, 0, fmt.Errorf("invalid ntp response (leap: %d mode: %d)", leap, mode)
+	}
+
+	// stratum 0 is a kiss-o'-death packet
+	if resp[1] == 0 {
+		return 0, 0, errors.New("ntp server sent kiss-o'-death")
+	}
+
+	t2 := ntpTime(resp[32:40]) // receive timestamp
+	t3 := ntpTime(resp[40:48]) // transmit timestamp
+
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	rtt := t4.Sub(t1) - t3.Sub(t2)
+
+	return offset, rtt, nil
+}
+
+// ntpTime converts the 64-bit NTP timestamp to time.Time
+func ntpTime(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (uint64(frac) * 1e9) >> 32
+
+	return time.Unix(int64(sec)-ntpEpochOffset, int64(nsec))
+}
+
+// putNTPTime writes the time as a 64-bit NTP timestamp
+func putNTPTime(b []byte, t time.Time) {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+
+	binary.BigEndian.PutUint32(b[0:4], uint32(sec))
+	binary.BigEndian.PutUint32(b[4:8], uint32(frac))
+}
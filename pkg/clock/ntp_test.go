@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNTPTime(t *testing.T) {
+	var b = make([]byte, 8)
+	var now = time.Date(2024, 3, 1, 10, 0, 0, 500000000, time.UTC)
+
+	putNTPTime(b, now)
+	if got := ntpTime(b); got.Sub(now).Abs() > time.Microsecond {
+		t.Errorf("expected %s, got %s", now, got)
+	}
+}
+
+func TestQueryNTP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("failed to listen udp: %v", err)
+	}
+	defer conn.Close()
+
+	// the server clock is one minute ahead
+	const skew = time.Minute
+	go func() {
+		var req = make([]byte, ntpPacketSize)
+		_, addr, err := conn.ReadFrom(req)
+		if err != nil {
+			return
+		}
+
+		var resp = make([]byte, ntpPacketSize)
+		resp[0] = 0x24 // LI = 0, VN = 4, Mode = 4 (server)
+		resp[1] = 2    // stratum
+		putNTPTime(resp[32:40], time.Now().Add(skew))
+		putNTPTime(resp[40:48], time.Now().Add(skew))
+		conn.WriteTo(resp, addr)
+	}()
+
+	offset, _, err := QueryNTP(conn.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("failed to query ntp: %v", err)
+	}
+
+	if (offset - skew).Abs() > 100*time.Millisecond {
+		t.Errorf("expected offset ~%s, got %s", skew, offset)
+	}
+}
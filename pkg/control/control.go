@@ -0,0 +1,641 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// DefaultSocket is the default control socket of a running kntrl
+const DefaultSocket = "/run/kntrl.sock"
+
+// maxBodySize is the maximum size of an imported allow list
+const maxBodySize = 16 << 20
+
+// Handler is the running tracer served on the control socket
+type Handler interface {
+	// Allowed returns the allow list entries
+	Allowed() []domain.AllowEntry
+	// Import adds the entries into the allow list and returns the number of added entries
+	Import(entries []domain.AllowEntry) (int, error)
+	// Remove removes the address from the allow list
+	Remove(address string) error
+	// Deny adds the addresses and the hosts into the deny list and returns the number of added entries
+	Deny(destinations []string) (int, error)
+	// Report returns the report of the events so far
+	Report() domain.Report
+	// Subscribe returns the live events and the function to end the subscription
+	Subscribe() (<-chan domain.ReportEvent, func())
+	// Status returns the mode, the map entries, the attached programs and the
+	// event counters of the tracer
+	Status() domain.Status
+}
+
+// ImportResult is the response of an import
+type ImportResult struct {
+	Imported int `json:"imported"`
+}
+
+// DenyRequest is the request of a deny, the addresses and the hosts to deny
+type DenyRequest struct {
+	Destinations []string `json:"destinations"`
+}
+
+// DenyResult is the response of a deny
+type DenyResult struct {
+	Denied int `json:"denied"`
+}
+
+// Serve serves the control API on the unix socket, the socket is accessible by root only.
+// The server is shut down when the context is done.
+//
+//	GET    /allow                 the allow list (domain.AllowList)
+//	POST   /allow                 adds the entries of an allow list
+//	DELETE /allow?address=1.2.3.4 removes an address from the allow list
+//	POST   /deny                  adds the destinations of a deny request into the deny list
+//	GET    /report                the report of the events so far
+//	GET    /status                the status of the tracer (domain.Status)
+//	GET    /events                the live events as JSON lines
+func Serve(ctx context.Context, path string, h Handler) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	// a stale socket of a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+
+	serve(ctx, listener, newMux(h), path, func() { _ = os.Remove(path) })
+
+	return nil
+}
+
+// RemoteConfig is the configuration of the control API served to the remote
+// clients (kntrl on another platform, the tooling embedding pkg/control)
+type RemoteConfig struct {
+	// Addr is the TCP address of the control API (:9443)
+	Addr string
+	// Token is the bearer token of the requests
+	Token string
+	// TLSCert and TLSKey are the certificate files of the server, the API is served
+	// without TLS if not set
+	TLSCert string
+	TLSKey  string
+}
+
+// Validate checks the required fields
+func (c RemoteConfig) Validate() error {
+	if c.Addr == "" {
+		return errors.New("control address is required")
+	}
+	if c.Token == "" {
+		return errors.New("control token is required to serve the control API on a TCP address")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("both the control TLS certificate and the key are required")
+	}
+
+	return nil
+}
+
+// ServeRemote serves the control API (see Serve) on the TCP address, the requests
+// are authenticated by the bearer token. The server is shut down when the context is done.
+func ServeRemote(ctx context.Context, cfg RemoteConfig, h Handler) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control address: %w", err)
+	}
+
+	if cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to load control TLS certificate: %w", err)
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+	} else {
+		logger.Log.Warnf("the control API on %s is served without TLS, the token is sent in clear text", cfg.Addr)
+	}
+
+	serve(ctx, listener, authorize(cfg.Token, newMux(h)), listener.Addr().String(), func() {})
+
+	return nil
+}
+
+// authorize rejects the requests without the bearer token
+func authorize(token string, next http.Handler) http.Handler {
+	var expected = []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serve serves the handler on the listener until the context is done
+func serve(ctx context.Context, listener net.Listener, handler http.Handler, name string, cleanup func()) {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+		cleanup()
+	}()
+
+	go func() {
+		logger.Log.Infof("serving control API on %s", name)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Errorf("failed to serve control API: %v", err)
+		}
+	}()
+}
+
+// newMux returns the handler of the control API
+func newMux(h Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/allow", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, domain.AllowList{
+				ExportedAt: time.Now(),
+				Entries:    h.Allowed(),
+			})
+
+		case http.MethodPost:
+			var list domain.AllowList
+			if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&list); err != nil {
+				http.Error(w, fmt.Sprintf("invalid allow list: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			n, err := h.Import(list.Entries)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, ImportResult{Imported: n})
+
+		case http.MethodDelete:
+			var address = r.URL.Query().Get("address")
+			if address == "" {
+				http.Error(w, "address is required", http.StatusBadRequest)
+				return
+			}
+
+			if err := h.Remove(address); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/deny", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req DenyRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid deny request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(req.Destinations) == 0 {
+			http.Error(w, "destinations are required", http.StatusBadRequest)
+			return
+		}
+
+		n, err := h.Deny(req.Destinations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, DenyResult{Denied: n})
+	})
+
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, h.Report())
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, h.Status())
+	})
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		events, cancel := h.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := enc.Encode(e); err != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	return mux
+}
+
+// Endpoint is the control API of a running kntrl, the local control socket
+// or a remote agent (see ServeRemote)
+type Endpoint struct {
+	// Socket is the control socket of a local kntrl
+	Socket string
+	// URL is the address of a remote agent (https://10.0.0.5:9443), the socket is not used if set
+	URL string
+	// Token is the bearer token of the remote agent
+	Token string
+	// CACert is the CA certificate file to verify the remote agent
+	CACert string
+}
+
+// FlagsEndpoint returns the endpoint of the agent flags of the clients
+// (control-socket, agent, agent-token and agent-ca-cert), the token defaults
+// to $KNTRL_AGENT_TOKEN
+func FlagsEndpoint(flags *pflag.FlagSet) Endpoint {
+	var value = func(name string) string {
+		if f := flags.Lookup(name); f != nil {
+			return f.Value.String()
+		}
+		return ""
+	}
+
+	var token = value("agent-token")
+	if token == "" {
+		token = os.Getenv("KNTRL_AGENT_TOKEN")
+	}
+
+	return Endpoint{
+		Socket: value("control-socket"),
+		URL:    value("agent"),
+		Token:  token,
+		CACert: value("agent-ca-cert"),
+	}
+}
+
+// SocketEndpoint returns the endpoint of the local control socket
+func SocketEndpoint(path string) Endpoint {
+	return Endpoint{Socket: path}
+}
+
+// String returns the address of the endpoint
+func (e Endpoint) String() string {
+	if e.URL != "" {
+		return e.URL
+	}
+
+	return e.Socket
+}
+
+// client returns the http client and the base url of the endpoint
+func (e Endpoint) client() (*http.Client, string, error) {
+	if e.URL == "" {
+		return &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", e.Socket)
+				},
+			},
+		}, "http://kntrl", nil
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid agent url: %s", e.URL)
+	}
+
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	if e.CACert != "" {
+		pem, err := os.ReadFile(e.CACert)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read agent CA certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, "", fmt.Errorf("no certificate found in %s", e.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &bearerTransport{token: e.Token, next: transport},
+	}, strings.TrimSuffix(e.URL, "/"), nil
+}
+
+// bearerTransport adds the token of the remote agent to the requests
+type bearerTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.next.RoundTrip(req)
+}
+
+// Export returns the allow list of the kntrl running on the endpoint
+func Export(e Endpoint) (*domain.AllowList, error) {
+	c, base, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Get(base + "/allow")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var list domain.AllowList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return &list, nil
+}
+
+// Remove removes the address from the allow list of the kntrl running on the endpoint
+func Remove(e Endpoint, address string) error {
+	c, base, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, base+"/allow?address="+url.QueryEscape(address), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return checkResponse(resp)
+}
+
+// Report returns the report of the kntrl running on the endpoint
+func Report(e Endpoint) (*domain.Report, error) {
+	c, base, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Get(base + "/report")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var report domain.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return &report, nil
+}
+
+// Status returns the status of the kntrl running on the endpoint
+func Status(e Endpoint) (*domain.Status, error) {
+	c, base, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Get(base + "/status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var status domain.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return &status, nil
+}
+
+// Events calls fn for each live event of the kntrl running on the endpoint
+// until the context is done or kntrl stops
+func Events(ctx context.Context, e Endpoint, fn func(domain.ReportEvent)) error {
+	c, base, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/events", nil)
+	if err != nil {
+		return err
+	}
+
+	// the stream has no deadline
+	c.Timeout = 0
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e domain.ReportEvent
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		fn(e)
+	}
+}
+
+// Import adds the entries into the allow list of the kntrl running on the endpoint
+func Import(e Endpoint, list domain.AllowList) (int, error) {
+	c, base, err := e.client()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Post(base+"/allow", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return 0, err
+	}
+
+	var result ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return result.Imported, nil
+}
+
+// Deny adds the addresses and the hosts into the deny list of the kntrl running on the endpoint
+func Deny(e Endpoint, destinations []string) (int, error) {
+	c, base, err := e.client()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(DenyRequest{Destinations: destinations})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Post(base+"/deny", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to kntrl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return 0, err
+	}
+
+	var result DenyResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("invalid response: %w", err)
+	}
+
+	return result.Denied, nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("kntrl returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Debugf("failed to write control response: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+type fakeHandler struct {
+	mu      sync.Mutex
+	entries []domain.AllowEntry
+	denied  []string
+	events  chan domain.ReportEvent
+}
+
+func (f *fakeHandler) Allowed() []domain.AllowEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]domain.AllowEntry(nil), f.entries...)
+}
+
+func (f *fakeHandler) Import(entries []domain.AllowEntry) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries = append(f.entries, entries...)
+	return len(entries), nil
+}
+
+func (f *fakeHandler) Remove(address string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, e := range f.entries {
+		if e.Address == address {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("not allowed: %s", address)
+}
+
+func (f *fakeHandler) Deny(destinations []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, d := range destinations {
+		if d == "invalid" {
+			return 0, fmt.Errorf("invalid destination: %s", d)
+		}
+	}
+	f.denied = append(f.denied, destinations...)
+	return len(destinations), nil
+}
+
+func (f *fakeHandler) Report() domain.Report {
+	return domain.Report{Mode: domain.TracerModeTrace, Summary: domain.ReportSummary{Total: 1}}
+}
+
+func (f *fakeHandler) Subscribe() (<-chan domain.ReportEvent, func()) {
+	return f.events, func() {}
+}
+
+func (f *fakeHandler) Status() domain.Status {
+	return domain.Status{
+		Source:       domain.StatusSourceDaemon,
+		Mode:         domain.TracerModeTrace,
+		Enforcing:    true,
+		AllowEntries: len(f.Allowed()),
+		Events:       &domain.StatusEvents{Handled: 3},
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var socket = filepath.Join(t.TempDir(), "kntrl.sock")
+	var h = &fakeHandler{entries: []domain.AllowEntry{
+		{Address: "140.82.114.22", Source: domain.AllowSourcePolicy, Rule: "lb-140-82-114-22-iad.github.com", AddedAt: time.Now()},
+	}}
+
+	if err := Serve(ctx, socket, h); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+
+	list, err := Export(SocketEndpoint(socket))
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].Address != "140.82.114.22" {
+		t.Errorf("unexpected exported entries: %+v", list.Entries)
+	}
+
+	n, err := Import(SocketEndpoint(socket), domain.AllowList{Entries: []domain.AllowEntry{{Address: "1.2.3.4:443"}}})
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if n != 1 || len(h.Allowed()) != 2 {
+		t.Errorf("expected 1 imported entry, got %d: %+v", n, h.Allowed())
+	}
+}
+
+func TestReportStatusEventsRemove(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var socket = filepath.Join(t.TempDir(), "kntrl.sock")
+	var h = &fakeHandler{
+		entries: []domain.AllowEntry{{Address: "1.1.1.1"}},
+		events:  make(chan domain.ReportEvent, 1),
+	}
+
+	if err := Serve(ctx, socket, h); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+
+	report, err := Report(SocketEndpoint(socket))
+	if err != nil || report.Mode != domain.TracerModeTrace || report.Summary.Total != 1 {
+		t.Errorf("unexpected report: %+v %v", report, err)
+	}
+
+	status, err := Status(SocketEndpoint(socket))
+	if err != nil || !status.Enforcing || status.AllowEntries != 1 || status.Events == nil || status.Events.Handled != 3 {
+		t.Errorf("unexpected status: %+v %v", status, err)
+	}
+
+	h.events <- domain.ReportEvent{DestinationAddress: "1.1.1.1", DestinationPort: 443}
+	close(h.events)
+
+	var events []domain.ReportEvent
+	if err := Events(ctx, SocketEndpoint(socket), func(e domain.ReportEvent) { events = append(events, e) }); err != nil {
+		t.Fatalf("failed to stream events: %v", err)
+	}
+	if len(events) != 1 || events[0].DestinationPort != 443 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if err := Remove(SocketEndpoint(socket), "1.1.1.1"); err != nil {
+		t.Errorf("failed to remove: %v", err)
+	}
+	if err := Remove(SocketEndpoint(socket), "1.1.1.1"); err == nil {
+		t.Errorf("expected an error for an address not allowed")
+	}
+}
+
+func TestDeny(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var socket = filepath.Join(t.TempDir(), "kntrl.sock")
+	var h = &fakeHandler{}
+
+	if err := Serve(ctx, socket, h); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+
+	n, err := Deny(SocketEndpoint(socket), []string{"1.2.3.4", "example.com"})
+	if err != nil || n != 2 {
+		t.Fatalf("unexpected deny result: %d %v", n, err)
+	}
+	if len(h.denied) != 2 || h.denied[1] != "example.com" {
+		t.Errorf("unexpected denied destinations: %v", h.denied)
+	}
+
+	if _, err := Deny(SocketEndpoint(socket), []string{"invalid"}); err == nil {
+		t.Errorf("expected an error for an invalid destination")
+	}
+	if _, err := Deny(SocketEndpoint(socket), nil); err == nil {
+		t.Errorf("expected an error without destinations")
+	}
+}
+
+func TestServeRemote(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a free port of the loopback address
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addr = l.Addr().String()
+	l.Close()
+
+	if err := ServeRemote(ctx, RemoteConfig{Addr: addr}, &fakeHandler{}); err == nil {
+		t.Fatal("expected an error without a token")
+	}
+
+	var h = &fakeHandler{entries: []domain.AllowEntry{{Address: "1.1.1.1"}}}
+	if err := ServeRemote(ctx, RemoteConfig{Addr: addr, Token: "secret"}, h); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+
+	var agent = Endpoint{URL: "http://" + addr, Token: "secret"}
+	var report *domain.Report
+	// the listener is served in the background
+	for i := 0; i < 50; i++ {
+		if report, err = Report(agent); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || report.Summary.Total != 1 {
+		t.Fatalf("unexpected report: %+v %v", report, err)
+	}
+
+	if _, err := Import(agent, domain.AllowList{Entries: []domain.AllowEntry{{Address: "2.2.2.2"}}}); err != nil {
+		t.Errorf("failed to import: %v", err)
+	}
+	if len(h.Allowed()) != 2 {
+		t.Errorf("unexpected entries: %+v", h.Allowed())
+	}
+
+	agent.Token = "wrong"
+	if _, err := Export(agent); err == nil {
+		t.Error("expected an error with a wrong token")
+	}
+}
+
+func TestFlagsEndpoint(t *testing.T) {
+	t.Setenv("KNTRL_AGENT_TOKEN", "env-token")
+
+	var flags = pflag.NewFlagSet("status", pflag.ContinueOnError)
+	flags.String("control-socket", DefaultSocket, "")
+	flags.String("agent", "", "")
+	flags.String("agent-token", "", "")
+	flags.String("agent-ca-cert", "", "")
+
+	if e := FlagsEndpoint(flags); e != (Endpoint{Socket: DefaultSocket, Token: "env-token"}) {
+		t.Errorf("unexpected endpoint of the defaults: %+v", e)
+	}
+
+	if err := flags.Parse([]string{"--agent", "https://10.0.0.5:9443", "--agent-token", "flag-token", "--agent-ca-cert", "ca.pem"}); err != nil {
+		t.Fatal(err)
+	}
+	var expected = Endpoint{Socket: DefaultSocket, URL: "https://10.0.0.5:9443", Token: "flag-token", CACert: "ca.pem"}
+	if e := FlagsEndpoint(flags); e != expected {
+		t.Errorf("expected %+v, got %+v", expected, e)
+	}
+}
@@ -0,0 +1,176 @@
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+)
+
+// DefaultThreshold is the latency of a slow component if not configured
+const DefaultThreshold = 500 * time.Millisecond
+
+// warnInterval is the minimum interval of the warnings of a component
+const warnInterval = time.Minute
+
+// Watchdog detects the slow components of the event pipeline (enrichment stages,
+// policy evaluation, sinks). The events are handled synchronously, a slow
+// component delays the events and the perf buffers drop the events in the meantime.
+// It also records the writes to the full maps of the allow and deny lists and
+// the sandboxed runtimes.
+// A nil Watchdog is a no-op.
+type Watchdog struct {
+	threshold time.Duration
+
+	mu       sync.Mutex
+	findings map[string]*domain.Diagnostic
+	warnedAt map[string]time.Time
+}
+
+// NewWatchdog returns a new watchdog with the given threshold, DefaultThreshold if zero
+func NewWatchdog(threshold time.Duration) *Watchdog {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Watchdog{
+		threshold: threshold,
+		findings:  make(map[string]*domain.Diagnostic),
+		warnedAt:  make(map[string]time.Time),
+	}
+}
+
+// Observe records the latency of the component
+func (w *Watchdog) Observe(component string, latency time.Duration) {
+	if w == nil || latency < w.threshold {
+		return
+	}
+
+	var now = time.Now()
+	metrics.ObserveSlowOperation(component)
+
+	w.mu.Lock()
+	f, ok := w.findings[component]
+	if !ok {
+		f = &domain.Diagnostic{
+			Kind:        domain.DiagnosticKindSlow,
+			Component:   component,
+			ThresholdMs: w.threshold.Milliseconds(),
+			FirstSeen:   now,
+		}
+		w.findings[component] = f
+	}
+	f.Count++
+	f.LastSeen = now
+	if ms := latency.Milliseconds(); ms > f.MaxLatencyMs {
+		f.MaxLatencyMs = ms
+	}
+
+	var warn = now.Sub(w.warnedAt[component]) >= warnInterval
+	if warn {
+		w.warnedAt[component] = now
+	}
+	var count = f.Count
+	w.mu.Unlock()
+
+	if warn {
+		logger.Log.WithFields(logrus.Fields{
+			"component": component,
+			"latency":   latency.String(),
+			"threshold": w.threshold.String(),
+			"count":     count,
+		}).Warn("slow component in the event pipeline, events may be dropped")
+	}
+}
+
+// MapFull records a write to the full map and the overflow action applied
+func (w *Watchdog) MapFull(mapName string, maxEntries uint32, action string) {
+	if w == nil {
+		return
+	}
+
+	var now = time.Now()
+	var key = domain.DiagnosticKindMapFull + "/" + mapName
+	metrics.ObserveMapOverflow(mapName, action)
+
+	w.mu.Lock()
+	f, ok := w.findings[key]
+	if !ok {
+		f = &domain.Diagnostic{
+			Kind:      domain.DiagnosticKindMapFull,
+			Component: mapName,
+			FirstSeen: now,
+		}
+		w.findings[key] = f
+	}
+	f.Count++
+	f.LastSeen = now
+	f.Action = action
+
+	var warn = now.Sub(w.warnedAt[key]) >= warnInterval
+	if warn {
+		w.warnedAt[key] = now
+	}
+	var count = f.Count
+	w.mu.Unlock()
+
+	if warn {
+		logger.Log.WithFields(logrus.Fields{
+			"map":         mapName,
+			"max_entries": maxEntries,
+			"action":      action,
+			"count":       count,
+		}).Warn("the eBPF map is full")
+	}
+}
+
+// Sandbox records a sandboxed runtime and the observation of its connections
+func (w *Watchdog) Sandbox(runtime string, pid uint32, action string) {
+	if w == nil {
+		return
+	}
+
+	var now = time.Now()
+	var component = fmt.Sprintf("%s/%d", runtime, pid)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.findings[domain.DiagnosticKindSandbox+"/"+component] = &domain.Diagnostic{
+		Kind:      domain.DiagnosticKindSandbox,
+		Component: component,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+		Action:    action,
+	}
+}
+
+// Findings returns the findings of the slow components, the full maps and the sandboxes
+func (w *Watchdog) Findings() []domain.Diagnostic {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var findings = make([]domain.Diagnostic, 0, len(w.findings))
+	for _, f := range w.findings {
+		findings = append(findings, *f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Kind != findings[j].Kind {
+			return findings[i].Kind < findings[j].Kind
+		}
+		return findings[i].Component < findings[j].Component
+	})
+
+	return findings
+}
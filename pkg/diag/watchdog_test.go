@@ -0,0 +1,72 @@
+package diag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestWatchdog(t *testing.T) {
+	w := NewWatchdog(100 * time.Millisecond)
+
+	w.Observe("enrich/rdns", 2*time.Second)
+	w.Observe("enrich/rdns", 300*time.Millisecond)
+	w.Observe("policy", time.Millisecond)
+
+	findings := w.Findings()
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", findings)
+	}
+
+	f := findings[0]
+	if f.Kind != domain.DiagnosticKindSlow || f.Component != "enrich/rdns" || f.Count != 2 || f.MaxLatencyMs != 2000 || f.ThresholdMs != 100 {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+}
+
+func TestWatchdog_Nil(t *testing.T) {
+	var w *Watchdog
+	w.Observe("policy", time.Hour)
+
+	if findings := w.Findings(); findings != nil {
+		t.Errorf("expected no findings, got %+v", findings)
+	}
+}
+
+func TestWatchdog_MapFull(t *testing.T) {
+	w := NewWatchdog(100 * time.Millisecond)
+
+	w.Observe("policy", time.Second)
+	w.MapFull(domain.EBPFCollectionMapAllowedIP, 1024, "evict-lru")
+	w.MapFull(domain.EBPFCollectionMapAllowedIP, 1024, "evict-lru")
+
+	findings := w.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+
+	f := findings[0]
+	if f.Kind != domain.DiagnosticKindMapFull || f.Component != domain.EBPFCollectionMapAllowedIP || f.Count != 2 || f.Action != "evict-lru" {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+	if findings[1].Kind != domain.DiagnosticKindSlow {
+		t.Errorf("expected the slow finding after the map finding, got %+v", findings[1])
+	}
+}
+
+func TestWatchdog_Sandbox(t *testing.T) {
+	w := NewWatchdog(100 * time.Millisecond)
+
+	w.Sandbox("gvisor", 1234, "observe")
+
+	findings := w.Findings()
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", findings)
+	}
+
+	f := findings[0]
+	if f.Kind != domain.DiagnosticKindSandbox || f.Component != "gvisor/1234" || f.Count != 1 || f.Action != "observe" {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+}
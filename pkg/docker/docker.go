@@ -0,0 +1,177 @@
+// Package docker finds the containers of a CI job through the Docker Engine
+// API. kntrl runs as a service container of the job (a privileged sidecar), the
+// job containers are the containers on the networks of its own container.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+const (
+	// DefaultSocket is the Docker Engine API socket, mounted into the service container
+	DefaultSocket = "/var/run/docker.sock"
+
+	httpTimeout = 10 * time.Second
+	// maxSize is the size limit of a response
+	maxSize = 16 << 20
+)
+
+// defaultNetworks are the networks shared by all the containers of the host
+var defaultNetworks = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// containerID matches the container id in the cgroup path
+// (docker-<id>.scope, /docker/<id>)
+var containerID = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// Container represents a running container
+type Container struct {
+	ID   string
+	Name string
+	// Networks are the names of the networks of the container
+	Networks []string
+}
+
+// Client is the client of the Docker Engine API on a unix socket
+type Client struct {
+	socket string
+	client *http.Client
+}
+
+// NewClient returns the client of the API on the socket, DefaultSocket if empty
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+
+	var transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &Client{
+		socket: socket,
+		client: &http.Client{Transport: transport, Timeout: httpTimeout},
+	}
+}
+
+// Containers returns the running containers
+func (c *Client) Containers(ctx context.Context) ([]Container, error) {
+	// the host of the URL is not used, the requests go to the socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers [%s]: %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list containers [%s]: %s", c.socket, resp.Status)
+	}
+
+	var list []struct {
+		ID              string   `json:"Id"`
+		Names           []string `json:"Names"`
+		NetworkSettings struct {
+			Networks map[string]json.RawMessage `json:"Networks"`
+		} `json:"NetworkSettings"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSize)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to list containers [%s]: %w", c.socket, err)
+	}
+
+	var containers = make([]Container, 0, len(list))
+	for _, item := range list {
+		var container = Container{ID: item.ID}
+		if len(item.Names) > 0 {
+			container.Name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		for network := range item.NetworkSettings.Networks {
+			container.Networks = append(container.Networks, network)
+		}
+		sort.Strings(container.Networks)
+		containers = append(containers, container)
+	}
+
+	return containers, nil
+}
+
+// SelfID returns the id of the container of kntrl, from its cgroup (a host
+// cgroup namespace) or its hostname (the short id by default)
+func SelfID() (string, error) {
+	if cgroup, err := process.CgroupOf(uint32(os.Getpid())); err == nil {
+		if id := containerID.FindString(cgroup); id != "" {
+			return id, nil
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	if len(hostname) < 12 || strings.Trim(hostname, "0123456789abcdef") != "" {
+		return "", errors.New("kntrl is not running in a container: no container id in its cgroup or hostname")
+	}
+
+	return hostname, nil
+}
+
+// JobContainers returns the containers sharing a network with the container
+// of the id (a prefix of the full id), the container itself is not included.
+// The default networks (bridge, host) are shared by the unrelated containers
+// too, the job network of GitHub Actions is a user-defined network.
+func JobContainers(containers []Container, selfID string) ([]Container, error) {
+	var self *Container
+	for i := range containers {
+		if strings.HasPrefix(containers[i].ID, selfID) {
+			self = &containers[i]
+			break
+		}
+	}
+	if self == nil {
+		return nil, fmt.Errorf("container [%s] not found", selfID)
+	}
+
+	var networks = make(map[string]bool)
+	for _, network := range self.Networks {
+		if !defaultNetworks[network] {
+			networks[network] = true
+		}
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("container [%s] is not on a job network", self.Name)
+	}
+
+	var job []Container
+	for _, c := range containers {
+		if c.ID == self.ID {
+			continue
+		}
+		for _, network := range c.Networks {
+			if networks[network] {
+				job = append(job, c)
+				break
+			}
+		}
+	}
+
+	return job, nil
+}
@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testContainers = `[
+  {"Id": "aaaa000000000000000000000000000000000000000000000000000000000001", "Names": ["/kntrl"], "NetworkSettings": {"Networks": {"github_network_1f2e": {}, "bridge": {}}}},
+  {"Id": "bbbb000000000000000000000000000000000000000000000000000000000002", "Names": ["/job"], "NetworkSettings": {"Networks": {"github_network_1f2e": {}}}},
+  {"Id": "cccc000000000000000000000000000000000000000000000000000000000003", "Names": ["/redis"], "NetworkSettings": {"Networks": {"github_network_1f2e": {}}}},
+  {"Id": "dddd000000000000000000000000000000000000000000000000000000000004", "Names": ["/other"], "NetworkSettings": {"Networks": {"bridge": {}}}}
+]`
+
+func TestClient_Containers(t *testing.T) {
+	var socket = filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testContainers))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	containers, err := NewClient(socket).Containers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(containers) != 4 {
+		t.Fatalf("expected 4 containers, got %d", len(containers))
+	}
+	if want := (Container{ID: "aaaa000000000000000000000000000000000000000000000000000000000001", Name: "kntrl", Networks: []string{"bridge", "github_network_1f2e"}}); !reflect.DeepEqual(containers[0], want) {
+		t.Errorf("expected %+v, got %+v", want, containers[0])
+	}
+}
+
+func TestJobContainers(t *testing.T) {
+	var containers = []Container{
+		{ID: "aaaa01", Name: "kntrl", Networks: []string{"bridge", "github_network_1f2e"}},
+		{ID: "bbbb02", Name: "job", Networks: []string{"github_network_1f2e"}},
+		{ID: "cccc03", Name: "redis", Networks: []string{"github_network_1f2e"}},
+		{ID: "dddd04", Name: "other", Networks: []string{"bridge"}},
+	}
+
+	job, err := JobContainers(containers, "aaaa")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, c := range job {
+		names = append(names, c.Name)
+	}
+	if want := []string{"job", "redis"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+
+	if _, err := JobContainers(containers, "dddd"); err == nil {
+		t.Errorf("expected an error for a container without a job network")
+	}
+	if _, err := JobContainers(containers, "eeee"); err == nil {
+		t.Errorf("expected an error for an unknown container")
+	}
+}
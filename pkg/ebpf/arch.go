@@ -0,0 +1,33 @@
+package ebpfman
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// machines maps the kernel machine names (uname -m) to GOARCH
+var machines = map[string]string{
+	"x86_64":  "amd64",
+	"i386":    "386",
+	"i686":    "386",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+}
+
+// KernelArch returns the architecture of the running kernel as GOARCH.
+// It returns an empty string if the architecture is unknown.
+func KernelArch() string {
+	return machines[kernelMachine()]
+}
+
+// CheckArch checks that the embedded object matches the kernel architecture.
+// The object is selected by GOARCH at build time, an amd64 build running
+// under emulation (qemu, rosetta) on an arm64 kernel would read the wrong registers.
+func CheckArch() error {
+	arch := KernelArch()
+	if arch == "" || arch == runtime.GOARCH {
+		return nil
+	}
+
+	return fmt.Errorf("kntrl is built for %s but the kernel is %s, use the %s build", runtime.GOARCH, arch, arch)
+}
@@ -0,0 +1,37 @@
+package ebpfman
+
+import "syscall"
+
+// kernelMachine returns the machine name of the running kernel
+func kernelMachine() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return ""
+	}
+
+	return utsString(uts.Machine[:])
+}
+
+// KernelRelease returns the release of the running kernel (uname -r)
+func KernelRelease() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return ""
+	}
+
+	return utsString(uts.Release[:])
+}
+
+// utsString returns the string of a null terminated utsname field, the
+// type of the fields is int8 or uint8 by the architecture
+func utsString[T int8 | uint8](field []T) string {
+	var b = make([]byte, 0, len(field))
+	for _, c := range field {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+
+	return string(b)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ebpfman
+
+// kernelMachine is not supported on this platform
+func kernelMachine() string {
+	return ""
+}
+
+// KernelRelease is not supported on this platform
+func KernelRelease() string {
+	return ""
+}
@@ -0,0 +1,16 @@
+package ebpfman
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCheckArch(t *testing.T) {
+	if arch := KernelArch(); arch != "" && arch != runtime.GOARCH {
+		t.Skipf("running under emulation on %s", arch)
+	}
+
+	if err := CheckArch(); err != nil {
+		t.Errorf("expected the kernel to match the build: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package ebpfman
+
+import "runtime"
+
+// Capability is a kernel feature of the support matrix
+type Capability struct {
+	// Name is the stable identifier of the feature
+	Name        string `json:"name"`
+	Supported   bool   `json:"supported"`
+	Description string `json:"description"`
+	// Reason is why the feature is not supported
+	Reason string `json:"reason,omitempty"`
+}
+
+// Capabilities is the support matrix of the host for the fleet inventory tools
+type Capabilities struct {
+	Kernel     string       `json:"kernel"`
+	KernelArch string       `json:"kernel_arch"`
+	BuildArch  string       `json:"build_arch"`
+	Features   []Capability `json:"features"`
+	// Modes are the usable modes of kntrl run (trace, monitor, tofu, simulate and passive)
+	Modes map[string]bool `json:"modes"`
+	// Missing are the missing features of the enforcing modes
+	Missing []string `json:"missing,omitempty"`
+	// MissingCapabilities are the Linux capabilities of the enforcing modes
+	// kntrl is missing, the features are probed with its capabilities
+	MissingCapabilities []string `json:"missing_capabilities,omitempty"`
+}
+
+// Capabilities returns the support matrix of the features and the modes
+func (f Features) Capabilities() Capabilities {
+	var c = Capabilities{
+		Kernel:     KernelRelease(),
+		KernelArch: KernelArch(),
+		BuildArch:  runtime.GOARCH,
+	}
+
+	for _, feature := range []struct {
+		name, probe, description string
+		ok                       bool
+	}{
+		{"btf", "BTF (CONFIG_DEBUG_INFO_BTF)", "CO-RE relocations, required", f.BTF},
+		{"kprobe", "kprobe programs", "connect probes, required without fentry", f.Kprobe},
+		{"fentry", "fentry programs", "connect probes and traffic counters", f.Tracing},
+		{"tracepoint", "tracepoint programs", "exec and socket state probes, required", f.Tracepoint},
+		{"cgroup_skb", "cgroup_skb programs", "enforcement, required except in passive mode", f.CGroupSKB},
+		{"cgroup2", "cgroup v2", "attachment of the enforcement, required except in passive mode", f.CGroup2},
+		{"perf_event_array", "perf event array maps", "event channel, required", f.PerfEventArray},
+		{"ringbuf", "ring buffer maps", "not used yet", f.RingBuf},
+		{"lsm", "LSM programs", "not used yet", f.LSM},
+		{"ipv6", "IPv6 programs", "IPv6 connect probes (NAT64)", f.IPv6},
+	} {
+		var capability = Capability{Name: feature.name, Supported: feature.ok, Description: feature.description}
+		if !feature.ok {
+			capability.Reason = f.reasons[feature.probe]
+		}
+		c.Features = append(c.Features, capability)
+	}
+
+	var archErr = CheckArch()
+	var arch = archErr == nil
+	c.Missing = f.missing(false)
+	if !f.CGroup2 {
+		c.Missing = append(c.Missing, "cgroup v2: "+f.reasons["cgroup v2"])
+	}
+	var enforce = arch && len(c.Missing) == 0
+	if !arch {
+		c.Missing = append(c.Missing, archErr.Error())
+	}
+	c.MissingCapabilities, _ = MissingPrivileges(true)
+	var passive = arch && len(f.missing(true)) == 0
+	c.Modes = map[string]bool{
+		"trace":    enforce,
+		"monitor":  enforce,
+		"tofu":     enforce,
+		"passive":  passive,
+		"simulate": passive,
+	}
+
+	return c
+}
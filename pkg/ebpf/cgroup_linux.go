@@ -0,0 +1,41 @@
+package ebpfman
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// CgroupID returns the cgroup v2 id of the cgroup directory (the key of excluded_cgroup_map),
+// the id is the inode number of the directory in host byte order
+func CgroupID(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("not a cgroup directory: %s", path)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("failed to read the inode of %s", path)
+	}
+
+	return stat.Ino, nil
+}
+
+// cgroup2 checks that the cgroup v2 hierarchy is mounted on the path
+func cgroup2(path string) error {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return err
+	}
+	if fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return fmt.Errorf("%s is not a cgroup v2 mount (legacy or hybrid hierarchy)", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package ebpfman
+
+import "errors"
+
+// CgroupID is not supported on this platform
+func CgroupID(path string) (uint64, error) {
+	return 0, errors.New("cgroup ids are supported on linux only")
+}
+
+// cgroup2 is not supported on this platform
+func cgroup2(path string) error {
+	return errors.New("cgroups are supported on linux only")
+}
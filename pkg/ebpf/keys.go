@@ -0,0 +1,258 @@
+package ebpfman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// The map keys are in network byte order (big endian), the layout of the
+// addresses and the ports in the packet headers. The kernel side of the keys
+// is defined in bpf/headers/keys.h, a key is stored as in bpftool output:
+//
+//	1.2.3.4     -> key: 01 02 03 04
+//	1.2.3.4:443 -> key: 01 02 03 04 01 bb 00 00
+
+// IPv4KeySize is the size of an IPv4 key (ip4_key_t)
+const IPv4KeySize = 4
+
+// PortKeySize is the size of a port key (struct port_key_t)
+const PortKeySize = 8
+
+// IPv4Key is the key of the IP address maps (allowed_ip_map, deny_map),
+// an IPv4 address in network byte order.
+type IPv4Key [IPv4KeySize]byte
+
+// NewIPv4Key returns the key of the IPv4 address, it returns false if the address is not IPv4
+func NewIPv4Key(ip net.IP) (IPv4Key, bool) {
+	var key IPv4Key
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return key, false
+	}
+	copy(key[:], ip4)
+
+	return key, true
+}
+
+// IP returns the IPv4 address of the key
+func (k IPv4Key) IP() net.IP {
+	return net.IPv4(k[0], k[1], k[2], k[3]).To4()
+}
+
+// String returns the IPv4 address of the key in dotted decimal notation
+func (k IPv4Key) String() string {
+	return k.IP().String()
+}
+
+// MarshalBinary encodes the key in network byte order
+func (k IPv4Key) MarshalBinary() ([]byte, error) {
+	return k[:], nil
+}
+
+// UnmarshalBinary decodes the key in network byte order
+func (k *IPv4Key) UnmarshalBinary(data []byte) error {
+	if len(data) != IPv4KeySize {
+		return fmt.Errorf("invalid IPv4 key size %d, expected %d", len(data), IPv4KeySize)
+	}
+	copy(k[:], data)
+
+	return nil
+}
+
+// NAT64Prefix is the value of the NAT64 prefix map (struct nat64_prefix_t),
+// the first 96 bits of the prefix
+type NAT64Prefix struct {
+	Prefix  [12]byte
+	Enabled uint32
+}
+
+// KillConfig is the value of the kill config map (struct kill_config_t)
+type KillConfig struct {
+	Enabled uint32
+	SelfPid uint32
+}
+
+// SelfConfig is the value of the self map (struct self_config_t), CgroupID is
+// the dedicated cgroup of kntrl, 0 if it's shared
+type SelfConfig struct {
+	Pid      uint32
+	_        uint32
+	CgroupID uint64
+}
+
+// CaptureConfig is the value of the capture config map (struct capture_config_t)
+type CaptureConfig struct {
+	Packets uint32
+	Snaplen uint32
+}
+
+// PortKey is the key of the allowed port map, the zero address matches any destination
+type PortKey struct {
+	Addr IPv4Key
+	Port uint16
+}
+
+// MarshalBinary encodes the key as the address and the port in network byte order
+// followed by two bytes of padding
+func (k PortKey) MarshalBinary() ([]byte, error) {
+	var data = make([]byte, PortKeySize)
+	copy(data, k.Addr[:])
+	binary.BigEndian.PutUint16(data[IPv4KeySize:], k.Port)
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes the key as the address and the port in network byte order
+func (k *PortKey) UnmarshalBinary(data []byte) error {
+	if len(data) != PortKeySize {
+		return fmt.Errorf("invalid port key size %d, expected %d", len(data), PortKeySize)
+	}
+	copy(k.Addr[:], data[:IPv4KeySize])
+	k.Port = binary.BigEndian.Uint16(data[IPv4KeySize:])
+
+	return nil
+}
+
+// String returns the key as address:port
+func (k PortKey) String() string {
+	return fmt.Sprintf("%s:%d", k.Addr, k.Port)
+}
+
+// Traffic is the value of traffic_map (struct traffic_t), the bytes sent and
+// received to the destination of the PortKey. The counters are in host byte order.
+type Traffic struct {
+	Sent     uint64
+	Received uint64
+}
+
+// HostKeySize is the size of a hostname key (MAX_HOSTNAME_LEN)
+const HostKeySize = 256
+
+// HostKey is the key of allowed_hosts_map, the hostname with a dot before each
+// label as the kernel reads the question of a DNS response, padded with zeros.
+// A key matches the name and its subdomains:
+//
+//	github.com, .github.com, *.github.com -> .github.com
+type HostKey [HostKeySize]byte
+
+// NewHostKey returns the key of the hostname, it returns false if the
+// hostname is empty, an IP address or too long
+func NewHostKey(host string) (HostKey, bool) {
+	var key HostKey
+
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "*"), "."), ".")
+	// the terminating zero of the name is a part of the key
+	if host == "" || net.ParseIP(host) != nil || len(host)+2 > HostKeySize {
+		return key, false
+	}
+	key[0] = '.'
+	copy(key[1:], host)
+
+	return key, true
+}
+
+// String returns the hostname of the key
+func (k HostKey) String() string {
+	return strings.TrimPrefix(string(bytes.TrimRight(k[:], "\x00")), ".")
+}
+
+// RangeKeySize is the size of a range key (struct lpm_ip4_key_t)
+const RangeKeySize = 8
+
+// RangeKey is the key of allowed_range_map, a longest prefix match trie: the
+// prefix length in host byte order followed by the IPv4 address
+//
+//	4.148.0.0/16 -> key: 10 00 00 00 04 94 00 00 (little endian host)
+type RangeKey struct {
+	PrefixLen uint32
+	Addr      IPv4Key
+}
+
+// NewRangeKey returns the key of the range, it returns false if the range is
+// not IPv4. The IPv4-mapped IPv6 ranges are IPv4.
+func NewRangeKey(prefix netip.Prefix) (RangeKey, bool) {
+	prefix = utils.UnmapPrefix(prefix)
+	if !prefix.IsValid() || !prefix.Addr().Is4() {
+		return RangeKey{}, false
+	}
+	prefix = prefix.Masked()
+
+	return RangeKey{PrefixLen: uint32(prefix.Bits()), Addr: prefix.Addr().As4()}, true
+}
+
+// MarshalBinary encodes the prefix length in host byte order and the address in network byte order
+func (k RangeKey) MarshalBinary() ([]byte, error) {
+	var data = make([]byte, RangeKeySize)
+	binary.NativeEndian.PutUint32(data, k.PrefixLen)
+	copy(data[4:], k.Addr[:])
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes the prefix length in host byte order and the address in network byte order
+func (k *RangeKey) UnmarshalBinary(data []byte) error {
+	if len(data) != RangeKeySize {
+		return fmt.Errorf("invalid range key size %d, expected %d", len(data), RangeKeySize)
+	}
+	k.PrefixLen = binary.NativeEndian.Uint32(data)
+	copy(k.Addr[:], data[4:])
+
+	return nil
+}
+
+// String returns the range of the key in CIDR notation
+func (k RangeKey) String() string {
+	return fmt.Sprintf("%s/%d", k.Addr, k.PrefixLen)
+}
+
+// CommKeySize is the size of a task name key (comm_key_t), TASK_COMM_LEN of the kernel
+const CommKeySize = 16
+
+// CommKey is the key of excluded_comm_map, the task name padded with zeros
+type CommKey [CommKeySize]byte
+
+// NewCommKey returns the key of the task name, the kernel truncates
+// the task names to 15 characters (the last byte is the terminating zero)
+func NewCommKey(comm string) CommKey {
+	var key CommKey
+	copy(key[:CommKeySize-1], comm)
+
+	return key
+}
+
+// String returns the task name of the key
+func (k CommKey) String() string {
+	if i := bytes.IndexByte(k[:], 0); i >= 0 {
+		return string(k[:i])
+	}
+
+	return string(k[:])
+}
+
+// FileNameKeySize is the size of a file name key (MAX_FILE_NAME_LEN)
+const FileNameKeySize = 32
+
+// FileNameKey is the key of sensitive_files_map (struct file_name_t), the
+// name of the file padded with zeros
+type FileNameKey [FileNameKeySize]byte
+
+// NewFileNameKey returns the key of the file name, it returns false if the
+// name is empty or too long (the kernel reads the names up to the key size)
+func NewFileNameKey(name string) (FileNameKey, bool) {
+	var key FileNameKey
+
+	// the terminating zero of the name is a part of the key
+	if name == "" || len(name)+1 > FileNameKeySize {
+		return key, false
+	}
+	copy(key[:], name)
+
+	return key, true
+}
@@ -0,0 +1,144 @@
+package ebpfman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIPv4Key(t *testing.T) {
+	key, ok := NewIPv4Key(net.ParseIP("1.2.3.4"))
+	if !ok {
+		t.Fatal("expected an IPv4 key")
+	}
+
+	data, err := key.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 4}; !bytes.Equal(data, want) {
+		t.Errorf("expected the key in network byte order %x, got %x", want, data)
+	}
+
+	var decoded IPv4Key
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != key || decoded.String() != "1.2.3.4" {
+		t.Errorf("expected %s, got %s", key, decoded)
+	}
+
+	if _, ok := NewIPv4Key(net.ParseIP("2001:db8::1")); ok {
+		t.Errorf("expected no key of an IPv6 address")
+	}
+	if err := decoded.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Errorf("expected an error for an invalid key size")
+	}
+}
+
+func TestPortKey(t *testing.T) {
+	addr, _ := NewIPv4Key(net.ParseIP("1.2.3.4"))
+	key := PortKey{Addr: addr, Port: 443}
+
+	data, err := key.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 4, 0x01, 0xbb, 0, 0}; !bytes.Equal(data, want) {
+		t.Errorf("expected the key in network byte order %x, got %x", want, data)
+	}
+
+	var decoded PortKey
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != key || decoded.String() != "1.2.3.4:443" {
+		t.Errorf("expected %s, got %s", key, decoded)
+	}
+}
+
+func TestRangeKey(t *testing.T) {
+	// the host bits of the range are masked
+	key, ok := NewRangeKey(netip.MustParsePrefix("4.148.1.2/16"))
+	if !ok {
+		t.Fatal("expected a range key")
+	}
+
+	data, err := key.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefixLen := binary.NativeEndian.Uint32(data); prefixLen != 16 || !bytes.Equal(data[4:], []byte{4, 148, 0, 0}) {
+		t.Errorf("expected the prefix length and the address in network byte order, got %x", data)
+	}
+
+	var decoded RangeKey
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != key || decoded.String() != "4.148.0.0/16" {
+		t.Errorf("expected %s, got %s", key, decoded)
+	}
+
+	// the IPv4-mapped IPv6 range is the IPv4 range
+	if mapped, ok := NewRangeKey(netip.MustParsePrefix("::ffff:4.148.0.0/112")); !ok || mapped != key {
+		t.Errorf("expected %s of the IPv4-mapped range, got %s", key, mapped)
+	}
+
+	if _, ok := NewRangeKey(netip.MustParsePrefix("2a0a:a440::/29")); ok {
+		t.Errorf("expected no key of an IPv6 range")
+	}
+	if err := decoded.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Errorf("expected an error for an invalid key size")
+	}
+}
+
+func TestCommKey(t *testing.T) {
+	key := NewCommKey("sshd")
+	if want := append([]byte("sshd"), make([]byte, CommKeySize-4)...); !bytes.Equal(key[:], want) {
+		t.Errorf("expected the task name padded with zeros %x, got %x", want, key[:])
+	}
+
+	// the last byte is the terminating zero
+	if key = NewCommKey("systemd-timesyncd"); key.String() != "systemd-timesyn" {
+		t.Errorf("expected the truncated task name, got %s", key)
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	// the subdomains are matched by the kernel, the forms have the same key
+	for _, host := range []string{"github.com", ".github.com", "*.github.com", "GitHub.com."} {
+		key, ok := NewHostKey(host)
+		if !ok {
+			t.Fatalf("[%s] expected a key", host)
+		}
+		if want := append([]byte(".github.com"), make([]byte, HostKeySize-11)...); !bytes.Equal(key[:], want) {
+			t.Errorf("[%s] expected the name with a leading dot padded with zeros, got %q", host, bytes.TrimRight(key[:], "\x00"))
+		}
+		if key.String() != "github.com" {
+			t.Errorf("[%s] expected github.com, got %s", host, key)
+		}
+	}
+
+	for _, host := range []string{"", ".", "1.1.1.1", "2001:db8::1", strings.Repeat("a", HostKeySize)} {
+		if _, ok := NewHostKey(host); ok {
+			t.Errorf("[%s] expected no key", host)
+		}
+	}
+}
+
+func TestSelfConfig(t *testing.T) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, SelfConfig{Pid: 42, CgroupID: 7}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// struct self_config_t: the pid, the padding and the cgroup id
+	var want = []byte{42, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected %v, got %v", want, buf.Bytes())
+	}
+}
@@ -5,26 +5,37 @@ import (
 	"fmt"
 
 	"github.com/cilium/ebpf"
-	"github.com/kondukto-io/kntrl/pkg/logger"
 )
 
 // Load loads the EBPF collection
 // func (e *EBPF) Load(collection string) error {
 func (e *EBPF) Load(collection []byte) error {
-	var err error
-
 	rd := bytes.NewReader(collection)
 
-	e.Spec, err = ebpf.LoadCollectionSpecFromReader(rd)
+	spec, err := ebpf.LoadCollectionSpecFromReader(rd)
 	if err != nil {
-		logger.Log.Fatalf("failed to loading collection spec: %v", err)
-		return fmt.Errorf("failed to loading collection spec: %v", err)
+		return fmt.Errorf("failed to load collection spec: %w", err)
 	}
 
-	e.Collection, err = ebpf.NewCollection(e.Spec)
+	return e.LoadSpec(spec)
+}
+
+// LoadSpec loads the EBPF collection from the given spec
+// (e.g. the spec embedded by the bpf2go generated bindings)
+func (e *EBPF) LoadSpec(spec *ebpf.CollectionSpec) error {
+	return e.LoadSpecWithMaps(spec, nil)
+}
+
+// LoadSpecWithMaps loads the EBPF collection from the given spec, the maps of
+// the replacements (e.g. the pinned maps of a previous run) are used instead
+// of new maps. The replacements must be compatible with their map spec.
+func (e *EBPF) LoadSpecWithMaps(spec *ebpf.CollectionSpec, replacements map[string]*ebpf.Map) error {
+	var err error
+
+	e.Spec = spec
+	e.Collection, err = ebpf.NewCollectionWithOptions(e.Spec, ebpf.CollectionOptions{MapReplacements: replacements})
 	if err != nil {
-		logger.Log.Fatalf("failed to create a new collection: %v", err)
-		return fmt.Errorf("failed to create a new collection: %v", err)
+		return fmt.Errorf("failed to create a new collection: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,10 @@
+package ebpfman
+
+import "testing"
+
+func TestLoadInvalidCollection(t *testing.T) {
+	// an invalid object is returned as an error, the process is not exited
+	if err := New().Load([]byte("not an ELF object")); err == nil {
+		t.Fatal("expected an error for an invalid collection")
+	}
+}
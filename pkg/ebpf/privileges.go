@@ -0,0 +1,119 @@
+package ebpfman
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The bits of the capabilities in the capability sets (linux/capability.h)
+const (
+	capNetAdmin    = 12
+	capSysAdmin    = 21
+	capSysResource = 24
+	capPerfmon     = 38
+	capBPF         = 39
+)
+
+// privilege is a capability required by kntrl
+type privilege struct {
+	name string
+	bit  uint
+	// sysAdmin is true if CAP_SYS_ADMIN grants the capability, the kernels
+	// before 5.8 have neither CAP_BPF nor CAP_PERFMON
+	sysAdmin bool
+	// enforcement is true if the capability is required by the enforcement only
+	enforcement bool
+}
+
+// privileges are the capabilities of kntrl: loading the programs and the maps
+// (CAP_BPF), the probes and the perf buffers (CAP_PERFMON), attaching the
+// cgroup programs (CAP_NET_ADMIN) and raising the locked memory limit of the
+// maps (CAP_SYS_RESOURCE)
+var privileges = []privilege{
+	{name: "CAP_BPF", bit: capBPF, sysAdmin: true},
+	{name: "CAP_PERFMON", bit: capPerfmon, sysAdmin: true},
+	{name: "CAP_NET_ADMIN", bit: capNetAdmin, enforcement: true},
+	{name: "CAP_SYS_RESOURCE", bit: capSysResource},
+}
+
+// CheckPrivileges returns an error naming the capabilities kntrl is missing,
+// CAP_NET_ADMIN is required by the enforcement only
+func CheckPrivileges(enforcement bool) error {
+	missing, err := MissingPrivileges(enforcement)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("missing capabilities %s: run as root or grant them (setcap %s+ep kntrl)", strings.Join(missing, ", "), setcapList(enforcement))
+}
+
+// MissingPrivileges returns the capabilities kntrl requires that are not in
+// its effective set
+func MissingPrivileges(enforcement bool) ([]string, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the capabilities: %w", err)
+	}
+	defer file.Close()
+
+	var effective uint64
+	var found bool
+	var scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			if effective, err = strconv.ParseUint(strings.TrimSpace(value), 16, 64); err != nil {
+				return nil, fmt.Errorf("failed to parse the capabilities: %w", err)
+			}
+			found = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the capabilities: %w", err)
+	}
+	if !found {
+		return nil, errors.New("failed to read the capabilities: no effective set")
+	}
+
+	return missingPrivileges(effective, enforcement), nil
+}
+
+// missingPrivileges returns the capabilities not in the effective set
+func missingPrivileges(effective uint64, enforcement bool) []string {
+	var has = func(bit uint) bool {
+		return effective&(1<<bit) != 0
+	}
+
+	var missing []string
+	for _, p := range privileges {
+		if p.enforcement && !enforcement {
+			continue
+		}
+		if has(p.bit) || (p.sysAdmin && has(capSysAdmin)) {
+			continue
+		}
+		missing = append(missing, p.name)
+	}
+
+	return missing
+}
+
+// setcapList returns the capabilities of kntrl in the setcap syntax
+func setcapList(enforcement bool) string {
+	var names []string
+	for _, p := range privileges {
+		if p.enforcement && !enforcement {
+			continue
+		}
+		names = append(names, strings.ToLower(p.name))
+	}
+
+	return strings.Join(names, ",")
+}
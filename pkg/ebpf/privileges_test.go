@@ -0,0 +1,35 @@
+package ebpfman
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingPrivileges(t *testing.T) {
+	for name, tc := range map[string]struct {
+		effective   uint64
+		enforcement bool
+		want        []string
+	}{
+		"root":            {effective: 0x1ffffffffff, enforcement: true},
+		"root before 5.8": {effective: 0x3fffffffff, enforcement: true},
+		"none":            {effective: 0, enforcement: true, want: []string{"CAP_BPF", "CAP_PERFMON", "CAP_NET_ADMIN", "CAP_SYS_RESOURCE"}},
+		"passive":         {effective: 1<<capBPF | 1<<capPerfmon | 1<<capSysResource},
+		"enforcement":     {effective: 1<<capBPF | 1<<capPerfmon | 1<<capSysResource, enforcement: true, want: []string{"CAP_NET_ADMIN"}},
+		"sys admin":       {effective: 1<<capSysAdmin | 1<<capNetAdmin, enforcement: true, want: []string{"CAP_SYS_RESOURCE"}},
+		"granted":         {effective: 1<<capBPF | 1<<capPerfmon | 1<<capNetAdmin | 1<<capSysResource, enforcement: true},
+	} {
+		if got := missingPrivileges(tc.effective, tc.enforcement); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestSetcapList(t *testing.T) {
+	if got := setcapList(true); got != "cap_bpf,cap_perfmon,cap_net_admin,cap_sys_resource" {
+		t.Errorf("unexpected setcap list: %s", got)
+	}
+	if got := setcapList(false); got != "cap_bpf,cap_perfmon,cap_sys_resource" {
+		t.Errorf("unexpected setcap list: %s", got)
+	}
+}
@@ -0,0 +1,240 @@
+package ebpfman
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+)
+
+// Features represents the kernel features used by the eBPF programs
+type Features struct {
+	// BTF is required for CO-RE relocations and fentry programs
+	BTF            bool
+	Kprobe         bool
+	Tracing        bool
+	Tracepoint     bool
+	CGroupSKB      bool
+	PerfEventArray bool
+	// RingBuf, LSM and IPv6 are not required, they are reported by kntrl capabilities
+	RingBuf bool
+	LSM     bool
+	// CGroup2 is the unified cgroup hierarchy the cgroup_skb programs are attached to
+	CGroup2 bool
+	// IPv6 is the support of the IPv6 connect probes (tcp_v6_connect)
+	IPv6 bool
+	// reasons of the unsupported features
+	reasons map[string]string
+}
+
+// ProbeFeatures probes the running kernel for the features used by the eBPF programs
+func ProbeFeatures() Features {
+	var f = Features{reasons: make(map[string]string)}
+
+	f.BTF = f.probe("BTF (CONFIG_DEBUG_INFO_BTF)", func() error {
+		_, err := btf.LoadKernelSpec()
+		return err
+	})
+	f.Kprobe = f.probe("kprobe programs", func() error { return features.HaveProgramType(ebpf.Kprobe) })
+	f.Tracing = f.probe("fentry programs", func() error { return features.HaveProgramType(ebpf.Tracing) })
+	f.Tracepoint = f.probe("tracepoint programs", func() error { return features.HaveProgramType(ebpf.TracePoint) })
+	f.CGroupSKB = f.probe("cgroup_skb programs", func() error { return features.HaveProgramType(ebpf.CGroupSKB) })
+	f.PerfEventArray = f.probe("perf event array maps", func() error { return features.HaveMapType(ebpf.PerfEventArray) })
+	f.RingBuf = f.probe("ring buffer maps", func() error { return features.HaveMapType(ebpf.RingBuf) })
+	f.LSM = f.probe("LSM programs", func() error {
+		if err := features.HaveProgramType(ebpf.LSM); err != nil {
+			return err
+		}
+		return activeLSM(lsmFile, "bpf")
+	})
+	f.CGroup2 = f.probe("cgroup v2", func() error { return cgroup2(cgroupRoot) })
+	f.IPv6 = f.probe("IPv6 programs", func() error { return kernelSymbol(kallsymsFile, "tcp_v6_connect") })
+
+	return f
+}
+
+const (
+	// lsmFile lists the active LSMs, bpf must be in the list for the LSM programs
+	lsmFile      = "/sys/kernel/security/lsm"
+	kallsymsFile = "/proc/kallsyms"
+	cgroupRoot   = "/sys/fs/cgroup"
+)
+
+// activeLSM checks that the LSM is enabled (lsm= boot parameter)
+func activeLSM(path, name string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, lsm := range strings.Split(strings.TrimSpace(string(b)), ",") {
+		if lsm == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the %s LSM is not enabled (lsm= boot parameter)", name)
+}
+
+// kernelSymbol checks that the kernel function is known, the module of the
+// symbol is loaded
+func kernelSymbol(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address type name [module]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[2] == name {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%s is not found, the kernel is built without IPv6", name)
+}
+
+func (f Features) probe(name string, fn func() error) bool {
+	err := fn()
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		f.reasons[name] = "not supported by the kernel"
+	} else {
+		f.reasons[name] = err.Error()
+	}
+
+	return false
+}
+
+// Missing returns the required features that are not supported by the kernel.
+// Either fentry or kprobe programs are required for the connect probes.
+func (f Features) Missing() []string {
+	return f.missing(false)
+}
+
+// missing returns the missing features, the cgroup_skb programs
+// are not required in passive mode (see PassivePrograms)
+func (f Features) missing(passive bool) []string {
+	var required = []struct {
+		name string
+		ok   bool
+	}{
+		{"BTF (CONFIG_DEBUG_INFO_BTF)", f.BTF},
+		{"tracepoint programs", f.Tracepoint},
+		{"cgroup_skb programs", f.CGroupSKB || passive},
+		{"perf event array maps", f.PerfEventArray},
+	}
+
+	var missing []string
+	for _, r := range required {
+		if !r.ok {
+			missing = append(missing, fmt.Sprintf("%s: %s", r.name, f.reasons[r.name]))
+		}
+	}
+
+	if !f.Kprobe && !f.Tracing {
+		missing = append(missing, fmt.Sprintf("kprobe programs: %s", f.reasons["kprobe programs"]))
+	}
+
+	return missing
+}
+
+// Require returns an error listing the missing kernel features
+func (f Features) Require() error {
+	return requireFeatures(f.missing(false))
+}
+
+// RequirePassive returns an error listing the missing kernel features of the passive mode
+func (f Features) RequirePassive() error {
+	return requireFeatures(f.missing(true))
+}
+
+func requireFeatures(missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the running kernel is missing the required eBPF features:\n  - %s", strings.Join(missing, "\n  - "))
+}
+
+// SelectPrograms removes the programs that won't be attached on the running kernel.
+// fentry programs are preferred and the kprobes attached to the same function are removed,
+// on kernels without fentry support the kprobes are used instead.
+func SelectPrograms(spec *ebpf.CollectionSpec, f Features) {
+	var useTracing = f.Tracing && f.BTF
+
+	var fentries = make(map[string]bool)
+	for _, p := range spec.Programs {
+		if p.Type == ebpf.Tracing && p.AttachType == ebpf.AttachTraceFEntry {
+			fentries[p.AttachTo] = true
+		}
+	}
+
+	for name, p := range spec.Programs {
+		switch {
+		case useTracing && p.Type == ebpf.Kprobe && fentries[p.AttachTo]:
+			delete(spec.Programs, name)
+		case !useTracing && p.Type == ebpf.Tracing:
+			delete(spec.Programs, name)
+		}
+	}
+}
+
+// mapWriters are the attach points of the observation programs that write the
+// maps (DNS answers, established connections, the sockets of the excluded tasks)
+var mapWriters = map[string]bool{
+	"skb_consume_udp":          true,
+	"sock/inet_sock_set_state": true,
+	"inet_csk_listen_start":    true,
+	"tcp_sendmsg":              true,
+	"udp_sendmsg":              true,
+}
+
+// observers are the programs attached to the map writer functions that don't
+// write the allow list maps: the traffic counters and the DNS query log
+var observers = map[string]bool{
+	"fexit__tcp_sendmsg":          true,
+	"fexit__udp_sendmsg":          true,
+	"kprobe__skb_consume_udp_dns": true,
+}
+
+// PassivePrograms removes the programs that may affect the traffic: the cgroup_skb
+// programs enforcing the policy and the programs writing the allow list maps.
+// The remaining programs only observe the connections, the executions and the
+// DNS queries, and count the traffic.
+func PassivePrograms(spec *ebpf.CollectionSpec) {
+	for name, p := range spec.Programs {
+		if p.Type == ebpf.CGroupSKB || (mapWriters[p.AttachTo] && !observers[name]) {
+			delete(spec.Programs, name)
+		}
+	}
+}
+
+// fileAccessPrograms are the programs tracing the reads of the sensitive files
+var fileAccessPrograms = map[string]bool{
+	"kprobe__security_file_open": true,
+}
+
+// RemoveFileAccessPrograms removes the programs tracing the reads of the
+// sensitive files, they are attached only if the file access is traced
+func RemoveFileAccessPrograms(spec *ebpf.CollectionSpec) {
+	for name := range spec.Programs {
+		if fileAccessPrograms[name] {
+			delete(spec.Programs, name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package ebpfman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func testSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"kprobe__tcp_v4_connect":  {Type: ebpf.Kprobe, AttachTo: "tcp_v4_connect"},
+			"fentry__tcp_v4_connect":  {Type: ebpf.Tracing, AttachType: ebpf.AttachTraceFEntry, AttachTo: "tcp_v4_connect"},
+			"kprobe__skb_consume_udp": {Type: ebpf.Kprobe, AttachTo: "skb_consume_udp"},
+			"egress":                  {Type: ebpf.CGroupSKB},
+		},
+	}
+}
+
+func TestSelectPrograms(t *testing.T) {
+	spec := testSpec()
+	SelectPrograms(spec, Features{BTF: true, Kprobe: true, Tracing: true})
+
+	if _, ok := spec.Programs["kprobe__tcp_v4_connect"]; ok {
+		t.Errorf("expected the kprobe to be replaced by the fentry program")
+	}
+	if _, ok := spec.Programs["kprobe__skb_consume_udp"]; !ok {
+		t.Errorf("expected the kprobe without a fentry variant to be kept")
+	}
+
+	spec = testSpec()
+	SelectPrograms(spec, Features{BTF: true, Kprobe: true})
+
+	if _, ok := spec.Programs["fentry__tcp_v4_connect"]; ok {
+		t.Errorf("expected the fentry program to be removed")
+	}
+	if _, ok := spec.Programs["kprobe__tcp_v4_connect"]; !ok {
+		t.Errorf("expected the kprobe to be kept")
+	}
+}
+
+func TestPassivePrograms(t *testing.T) {
+	spec := testSpec()
+	spec.Programs["inet_sock_set_state"] = &ebpf.ProgramSpec{Type: ebpf.TracePoint, AttachTo: "sock/inet_sock_set_state"}
+	spec.Programs["sched_process_exec"] = &ebpf.ProgramSpec{Type: ebpf.TracePoint, AttachTo: "sched/sched_process_exec"}
+	spec.Programs["fentry__tcp_sendmsg"] = &ebpf.ProgramSpec{Type: ebpf.Tracing, AttachType: ebpf.AttachTraceFEntry, AttachTo: "tcp_sendmsg"}
+	spec.Programs["fexit__tcp_sendmsg"] = &ebpf.ProgramSpec{Type: ebpf.Tracing, AttachType: ebpf.AttachTraceFExit, AttachTo: "tcp_sendmsg"}
+	spec.Programs["kprobe__skb_consume_udp_dns"] = &ebpf.ProgramSpec{Type: ebpf.Kprobe, AttachTo: "skb_consume_udp"}
+	PassivePrograms(spec)
+
+	for _, name := range []string{"egress", "kprobe__skb_consume_udp", "inet_sock_set_state", "fentry__tcp_sendmsg"} {
+		if _, ok := spec.Programs[name]; ok {
+			t.Errorf("expected [%s] to be removed", name)
+		}
+	}
+	// the traffic counters and the DNS query log are kept
+	for _, name := range []string{"kprobe__tcp_v4_connect", "fentry__tcp_v4_connect", "sched_process_exec", "fexit__tcp_sendmsg", "kprobe__skb_consume_udp_dns"} {
+		if _, ok := spec.Programs[name]; !ok {
+			t.Errorf("expected [%s] to be kept", name)
+		}
+	}
+}
+
+func TestFeatures_Missing(t *testing.T) {
+	var f = Features{BTF: true, Tracepoint: true, CGroupSKB: true, PerfEventArray: true, Tracing: true}
+	if missing := f.Missing(); len(missing) != 0 {
+		t.Errorf("expected no missing features, got %v", missing)
+	}
+
+	// cgroup_skb programs are not attached in passive mode
+	f.CGroupSKB = false
+	if err := f.RequirePassive(); err != nil {
+		t.Errorf("expected no missing features in passive mode, got %v", err)
+	}
+	if err := f.Require(); err == nil {
+		t.Errorf("expected cgroup_skb programs to be required")
+	}
+
+	f = Features{reasons: map[string]string{"BTF (CONFIG_DEBUG_INFO_BTF)": "not supported by the kernel"}}
+	if err := f.Require(); err == nil {
+		t.Errorf("expected an error listing the missing features")
+	}
+}
+
+func TestFeatures_Capabilities(t *testing.T) {
+	var f = Features{BTF: true, Kprobe: true, Tracepoint: true, CGroupSKB: true, PerfEventArray: true, CGroup2: true, reasons: map[string]string{}}
+	c := f.Capabilities()
+	if CheckArch() == nil && (!c.Modes["trace"] || !c.Modes["passive"] || len(c.Missing) != 0) {
+		t.Errorf("expected all the modes to be usable, got %v %v", c.Modes, c.Missing)
+	}
+	if len(c.Features) != 10 {
+		t.Errorf("expected 10 features, got %d", len(c.Features))
+	}
+
+	// the cgroup_skb programs are attached to the cgroup v2 root
+	f.CGroup2 = false
+	f.reasons["cgroup v2"] = "/sys/fs/cgroup is not a cgroup v2 mount"
+	c = f.Capabilities()
+	if c.Modes["trace"] || c.Modes["monitor"] || c.Modes["tofu"] {
+		t.Errorf("expected the enforcing modes to be unusable, got %v", c.Modes)
+	}
+	if CheckArch() == nil && (!c.Modes["passive"] || !c.Modes["simulate"]) {
+		t.Errorf("expected the passive and the simulate modes to be usable")
+	}
+	for _, capability := range c.Features {
+		if capability.Name == "cgroup2" && (capability.Supported || capability.Reason == "") {
+			t.Errorf("expected the reason of the unsupported cgroup2, got %+v", capability)
+		}
+	}
+}
+
+func TestKernelSymbol(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "kallsyms")
+	var kallsyms = "ffffffff81000000 T _stext\nffffffffc0a01230 t tcp_v6_connect\t[ipv6]\n"
+	if err := os.WriteFile(path, []byte(kallsyms), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kernelSymbol(path, "tcp_v6_connect"); err != nil {
+		t.Errorf("expected the symbol of the module, got %v", err)
+	}
+	if err := kernelSymbol(path, "tcp_v4_connect"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestActiveLSM(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "lsm")
+	if err := os.WriteFile(path, []byte("lockdown,capability,yama,apparmor\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := activeLSM(path, "bpf"); err == nil {
+		t.Errorf("expected the bpf LSM to be inactive")
+	}
+
+	if err := os.WriteFile(path, []byte("lockdown,capability,yama,bpf\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := activeLSM(path, "bpf"); err != nil {
+		t.Errorf("expected the bpf LSM to be active, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// StageCategory adds the category of the destination (package registry, cloud provider, analytics, ads)
+const StageCategory = "category"
+
+// defaultFeed is the built-in category feed
+//
+//go:embed categories.csv
+var defaultFeed string
+
+// category adds the category of the destination from the domain names,
+// the destinations without a matching domain are unknown. It runs after the rdns stage.
+type category struct {
+	// suffixes are the domain suffixes of the categories
+	suffixes map[string]string
+}
+
+func newCategory(cfg domain.EnrichmentStage) (Stage, error) {
+	var s = category{suffixes: make(map[string]string)}
+	if err := s.load(strings.NewReader(defaultFeed)); err != nil {
+		return nil, fmt.Errorf("built-in category feed: %w", err)
+	}
+
+	// the entries of the feed override the built-in entries
+	if cfg.Database != "" {
+		file, err := os.Open(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if err := s.load(file); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Database, err)
+		}
+	}
+
+	return s, nil
+}
+
+// load reads the feed, the lines of domain suffix,category
+func (s category) load(r io.Reader) error {
+	var scanner = bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var text = strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		suffix, name, ok := strings.Cut(text, ",")
+		suffix = strings.Trim(strings.ToLower(strings.TrimSpace(suffix)), ".")
+		name = strings.TrimSpace(name)
+		if !ok || suffix == "" || name == "" {
+			return fmt.Errorf("invalid category entry at line %d: %s", line, text)
+		}
+		s.suffixes[suffix] = name
+	}
+
+	return scanner.Err()
+}
+
+func (category) Name() string { return StageCategory }
+
+func (s category) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	event.Category = domain.DestinationCategoryUnknown
+
+	var longest int
+	for _, name := range event.Domains {
+		name = strings.Trim(strings.ToLower(name), ".")
+		// the suffixes of the domain, from the domain itself to the top level domain
+		for suffix := name; suffix != ""; {
+			if c, ok := s.suffixes[suffix]; ok && len(suffix) > longest {
+				event.Category, longest = c, len(suffix)
+			}
+
+			_, rest, ok := strings.Cut(suffix, ".")
+			if !ok {
+				break
+			}
+			suffix = rest
+		}
+	}
+
+	return nil
+}
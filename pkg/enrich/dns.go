@@ -0,0 +1,206 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dnsPort = "53"
+	dotPort = "853"
+	// dohMediaType is the media type of the DNS messages of DoH (RFC 8484)
+	dohMediaType = "application/dns-message"
+	// dohMaxResponse is the size limit of a DoH response
+	dohMaxResponse = 64 * 1024
+)
+
+// NewNetResolver returns the resolver of the lookups through the server instead
+// of the servers of /etc/resolv.conf. An empty server is the system resolver.
+//
+//	10.0.0.2[:53]                          plain DNS (UDP, TCP on truncation)
+//	udp://10.0.0.2[:53], tcp://10.0.0.2[:53]
+//	tls://1.1.1.1[:853]?server_name=one.one.one.one  DNS over TLS (RFC 7858)
+//	https://dns.example.com/dns-query      DNS over HTTPS (RFC 8484)
+func NewNetResolver(server string) (*net.Resolver, error) {
+	if server == "" {
+		return net.DefaultResolver, nil
+	}
+
+	if !strings.Contains(server, "://") {
+		server = "udp://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid resolver [%s]", server)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "udp":
+		return dialResolver(func(ctx context.Context, network string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, hostPort(u, dnsPort))
+		}), nil
+	case "tcp":
+		return dialResolver(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", hostPort(u, dnsPort))
+		}), nil
+	case "tls":
+		var serverName = u.Query().Get("server_name")
+		if serverName == "" {
+			serverName = u.Hostname()
+		}
+		var config = &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+		return dialResolver(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d = tls.Dialer{Config: config}
+			return d.DialContext(ctx, "tcp", hostPort(u, dotPort))
+		}), nil
+	case "https":
+		return dohResolver(u.String(), &http.Client{}), nil
+	}
+
+	return nil, fmt.Errorf("invalid resolver [%s]: unsupported scheme %q", server, u.Scheme)
+}
+
+// dialResolver returns a resolver of the pure Go client dialing the server,
+// the stream connections (TCP, TLS) carry the length prefixed messages
+func dialResolver(dial func(ctx context.Context, network string) (net.Conn, error)) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network)
+		},
+	}
+}
+
+// dohResolver returns a resolver posting the DNS messages to the DoH endpoint
+func dohResolver(endpoint string, client *http.Client) *net.Resolver {
+	return dialResolver(func(ctx context.Context, _ string) (net.Conn, error) {
+		return &dohConn{ctx: ctx, endpoint: endpoint, client: client}, nil
+	})
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// dohConn is the stream connection of the Go resolver to a DoH endpoint, each
+// length prefixed query written is posted and its response is read back
+type dohConn struct {
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+
+	mu       sync.Mutex
+	query    bytes.Buffer
+	response bytes.Buffer
+	deadline time.Time
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.query.Write(b)
+	for c.query.Len() >= 2 {
+		var size = int(binary.BigEndian.Uint16(c.query.Bytes()))
+		if c.query.Len() < 2+size {
+			break
+		}
+		msg := c.query.Next(2 + size)[2:]
+
+		resp, err := c.exchange(msg)
+		if err != nil {
+			return 0, err
+		}
+		if len(resp) > 0xffff {
+			return 0, errors.New("doh response is too large")
+		}
+
+		var prefix [2]byte
+		binary.BigEndian.PutUint16(prefix[:], uint16(len(resp)))
+		c.response.Write(prefix[:])
+		c.response.Write(resp)
+	}
+
+	return len(b), nil
+}
+
+// exchange posts the query and returns the response message
+func (c *dohConn) exchange(msg []byte) ([]byte, error) {
+	var ctx, cancel = c.ctx, context.CancelFunc(func() {})
+	if !c.deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+	}
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh server returned %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, dohMaxResponse))
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.response.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return c.response.Read(b)
+}
+
+func (c *dohConn) Close() error { return nil }
+
+func (c *dohConn) LocalAddr() net.Addr { return dohAddr(c.endpoint) }
+
+func (c *dohConn) RemoteAddr() net.Addr { return dohAddr(c.endpoint) }
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(time.Time) error { return nil }
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// dohAddr is the address of the DoH endpoint
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+
+func (a dohAddr) String() string { return string(a) }
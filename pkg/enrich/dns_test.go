@@ -0,0 +1,146 @@
+package enrich
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewNetResolver(t *testing.T) {
+	if r, err := NewNetResolver(""); err != nil || r != net.DefaultResolver {
+		t.Errorf("expected the system resolver, got %v %v", r, err)
+	}
+
+	for _, server := range []string{"10.0.0.2:53", "10.0.0.2", "tcp://10.0.0.2", "tls://1.1.1.1?server_name=one.one.one.one", "https://1.1.1.1/dns-query"} {
+		if _, err := NewNetResolver(server); err != nil {
+			t.Errorf("[%s] unexpected error: %v", server, err)
+		}
+	}
+
+	for _, server := range []string{"quic://10.0.0.2", "udp://", "https:///dns-query"} {
+		if _, err := NewNetResolver(server); err == nil {
+			t.Errorf("[%s] expected an error", server)
+		}
+	}
+}
+
+func TestNetResolver_Lookup(t *testing.T) {
+	var expected = []string{"lb-140-82-114-22.github.com."}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go func() {
+		var b = make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(ptrResponse(b[:n], expected[0]), addr)
+		}
+	}()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			var prefix [2]byte
+			if _, err := io.ReadFull(conn, prefix[:]); err == nil {
+				var query = make([]byte, binary.BigEndian.Uint16(prefix[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					conn.Write(framed(ptrResponse(query, expected[0])))
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohMediaType {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(ptrResponse(query, expected[0]))
+	}))
+	defer doh.Close()
+
+	udpResolver, err := NewNetResolver(udp.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpResolver, err := NewNetResolver("tcp://" + tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resolvers = map[string]*net.Resolver{
+		"udp":   udpResolver,
+		"tcp":   tcpResolver,
+		"https": dohResolver(doh.URL, doh.Client()),
+	}
+	for name, r := range resolvers {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		names, err := r.LookupAddr(ctx, "140.82.114.22")
+		cancel()
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("[%s] expected %v, got %v", name, expected, names)
+		}
+	}
+}
+
+// ptrResponse returns the response of the query with a PTR record of the name
+func ptrResponse(query []byte, name string) []byte {
+	// the question follows the header, its name ends with the root label
+	var end = 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 1 + 4
+
+	var resp = make([]byte, 12, 512)
+	copy(resp, query[:2])
+	binary.BigEndian.PutUint16(resp[2:], 0x8180) // response, recursion desired and available
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	resp = append(resp, query[12:end]...)
+
+	var rdata []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		rdata = append(rdata, byte(len(label)))
+		rdata = append(rdata, label...)
+	}
+	rdata = append(rdata, 0)
+
+	// the answer points to the name of the question
+	resp = append(resp, 0xc0, 12, 0, 12, 0, 1, 0, 0, 0x0e, 0x10)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+
+	return append(resp, rdata...)
+}
+
+func framed(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...)
+}
@@ -0,0 +1,140 @@
+package enrich
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ipRecord is the country and the autonomous system of an address range
+type ipRecord struct {
+	start   uint32
+	end     uint32
+	ASN     uint32
+	Country string
+	Org     string
+}
+
+// ipDatabase is the database of the geoip and asn stages, the ip2asn TSV
+// file or a MaxMind DB (.mmdb)
+type ipDatabase interface {
+	// Lookup returns the record of the address
+	Lookup(ip net.IP) (ipRecord, bool)
+}
+
+// ip2asn is the IPv4 database of https://iptoasn.com (ip2asn-v4.tsv),
+// the tab separated lines of range_start, range_end, AS_number, country_code, AS_description
+type ip2asn struct {
+	ranges []ipRecord
+}
+
+var (
+	databasesMu sync.Mutex
+	// databases are shared by the geoip and asn stages
+	databases = map[string]ipDatabase{}
+)
+
+// openDatabase opens the database of the path, a MaxMind DB if the file has
+// the .mmdb extension and the ip2asn TSV file otherwise
+func openDatabase(path string) (ipDatabase, error) {
+	if path == "" {
+		return nil, errors.New("database is required")
+	}
+
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	if db, ok := databases[path]; ok {
+		return db, nil
+	}
+
+	var db ipDatabase
+	var err error
+	if strings.EqualFold(filepath.Ext(path), mmdbExt) {
+		db, err = loadMMDB(path)
+	} else {
+		db, err = loadIP2ASN(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	databases[path] = db
+
+	return db, nil
+}
+
+func loadIP2ASN(path string) (*ip2asn, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer file.Close()
+
+	var db = &ip2asn{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			continue
+		}
+
+		start, end := ipv4ToUint(net.ParseIP(fields[0])), ipv4ToUint(net.ParseIP(fields[1]))
+		number, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AS number at line %d: %w", line, err)
+		}
+
+		// unrouted ranges
+		if number == 0 {
+			continue
+		}
+
+		db.ranges = append(db.ranges, ipRecord{
+			start:   start,
+			end:     end,
+			ASN:     uint32(number),
+			Country: fields[3],
+			Org:     fields[4],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start < db.ranges[j].start })
+
+	return db, nil
+}
+
+// Lookup returns the range of the IPv4 address
+func (db *ip2asn) Lookup(ip net.IP) (ipRecord, bool) {
+	if ip.To4() == nil {
+		return ipRecord{}, false
+	}
+
+	n := ipv4ToUint(ip)
+	i := sort.Search(len(db.ranges), func(i int) bool { return db.ranges[i].start > n }) - 1
+	if i < 0 || n > db.ranges[i].end {
+		return ipRecord{}, false
+	}
+
+	return db.ranges[i], true
+}
+
+func ipv4ToUint(ip net.IP) uint32 {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(ip4)
+}
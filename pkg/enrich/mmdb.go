@@ -0,0 +1,68 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// mmdbExt is the extension of the MaxMind DB files
+const mmdbExt = ".mmdb"
+
+// mmdb is a MaxMind DB: GeoLite2-Country, GeoLite2-City and GeoLite2-ASN
+// (https://dev.maxmind.com/geoip/geolite2-free-geolocation-data) or the
+// compatible DB-IP databases. The IPv6 addresses are looked up too.
+type mmdb struct {
+	reader *maxminddb.Reader
+}
+
+// mmdbRecord is the country and the autonomous system of a record, the
+// country databases have no autonomous system and the ASN databases no country
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	// RegisteredCountry is the country of the owner of the network, the
+	// records of the anycast networks have no country
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN uint32 `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+func loadMMDB(path string) (*mmdb, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &mmdb{reader: reader}, nil
+}
+
+// Lookup returns the record of the address
+func (db *mmdb) Lookup(ip net.IP) (ipRecord, bool) {
+	if ip == nil {
+		return ipRecord{}, false
+	}
+
+	var record mmdbRecord
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		// the IPv6 addresses in an IPv4 database
+		logger.Log.Debugf("failed to look up [%s]: %v", ip, err)
+		return ipRecord{}, false
+	}
+
+	var country = record.Country.ISOCode
+	if country == "" {
+		country = record.RegisteredCountry.ISOCode
+	}
+	if country == "" && record.ASN == 0 {
+		return ipRecord{}, false
+	}
+
+	return ipRecord{ASN: record.ASN, Country: country, Org: record.Org}, true
+}
@@ -0,0 +1,119 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// mmdbString encodes the string of the MaxMind DB data section, the strings
+// of 29 bytes and more have their size in the next byte
+func mmdbString(s string) []byte {
+	if len(s) >= 29 {
+		return append([]byte{0x40 | 29, byte(len(s) - 29)}, s...)
+	}
+
+	return append([]byte{0x40 | byte(len(s))}, s...)
+}
+
+// mmdbUint32 encodes the uint32 of the MaxMind DB data section
+func mmdbUint32(v uint32) []byte {
+	return []byte{0xC4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// mmdbMap encodes the map of the MaxMind DB data section, the values are
+// encoded in the order of the keys
+func mmdbMap(keys []string, values ...[]byte) []byte {
+	var buf = []byte{0xE0 | byte(len(keys))}
+	for i, key := range keys {
+		buf = append(buf, mmdbString(key)...)
+		buf = append(buf, values[i]...)
+	}
+
+	return buf
+}
+
+// testMMDB returns an IPv4 MaxMind DB with the record of the network
+// 140.82.112.0/20 only, in the format of the GeoLite2 databases
+func testMMDB() []byte {
+	var network = []byte{140, 82, 112, 0}
+	const prefix = 20
+	const nodes = prefix
+
+	var record = mmdbMap(
+		[]string{"country", "autonomous_system_number", "autonomous_system_organization"},
+		mmdbMap([]string{"iso_code"}, mmdbString("US")),
+		mmdbUint32(36459),
+		mmdbString("GITHUB"),
+	)
+
+	// a node per bit of the prefix: the record of the bit points to the next
+	// node, the last one to the data, the other record is empty
+	var tree bytes.Buffer
+	for i := 0; i < nodes; i++ {
+		var next = uint32(i + 1)
+		if i == nodes-1 {
+			next = nodes + 16
+		}
+		var records = [2]uint32{nodes, nodes}
+		records[network[i/8]>>(7-i%8)&1] = next
+		for _, r := range records {
+			tree.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree.Bytes())
+	buf.Write(make([]byte, 16))
+	buf.Write(record)
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	buf.Write(mmdbMap(
+		[]string{"node_count", "record_size", "ip_version", "database_type", "binary_format_major_version"},
+		mmdbUint32(nodes),
+		[]byte{0xA1, 24},
+		[]byte{0xA1, 4},
+		mmdbString("Test"),
+		[]byte{0xA1, 2},
+	))
+
+	return buf.Bytes()
+}
+
+func TestMMDB(t *testing.T) {
+	var database = filepath.Join(t.TempDir(), "GeoLite2-Test.mmdb")
+	if err := os.WriteFile(database, testMMDB(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(domain.EnrichmentConfig{Stages: []domain.EnrichmentStage{
+		{Name: StageASN, Database: database},
+		{Name: StageGeoIP, Database: database},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+
+	var tests = []struct {
+		address string
+		country string
+		asn     uint32
+		org     string
+	}{
+		{address: "140.82.114.22", country: "US", asn: 36459, org: "GITHUB"},
+		{address: "140.82.128.1"},
+		{address: "2606:4700::1111"},
+	}
+
+	for _, tt := range tests {
+		var event = domain.ReportEvent{DestinationAddress: tt.address}
+		p.Run(context.Background(), &event)
+
+		if event.Country != tt.country || event.ASN != tt.asn || event.ASOrg != tt.org {
+			t.Errorf("[%s] expected %s AS%d %s, got %+v", tt.address, tt.country, tt.asn, tt.org, event)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// defaultTimeout is the timeout of a stage if not configured
+const defaultTimeout = 2 * time.Second
+
+// Stage enriches the events
+type Stage interface {
+	Name() string
+	Enrich(ctx context.Context, event *domain.ReportEvent) error
+}
+
+// Prefetcher is a stage that prepares the enrichment of an address in the
+// background (the reverse lookup of the rdns stage), done is called when it's ready
+type Prefetcher interface {
+	Prefetch(addr string, done func())
+}
+
+// Warmer is a stage caching the enrichment of an address known in advance
+// (the domain names of the verdict cache of a previous job)
+type Warmer interface {
+	Warm(addr string, names []string)
+}
+
+// Factory returns a new stage of the given configuration
+type Factory func(cfg domain.EnrichmentStage) (Stage, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register registers a stage factory with the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = factory
+}
+
+// Stages returns the names of the registered stages
+func Stages() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var names = make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func init() {
+	Register(StageRDNS, newRDNS)
+	Register(StageContainer, newContainer)
+	Register(StageGeoIP, newGeoIP)
+	Register(StageASN, newASN)
+	Register(StageExec, newExec)
+	Register(StageCategory, newCategory)
+	Register(StageReputation, newReputation)
+}
+
+// Pipeline runs the stages in order
+type Pipeline struct {
+	stages   []Stage
+	timeouts []time.Duration
+	// prefetchers are the stages preparing the enrichment in the background
+	prefetchers []Prefetcher
+	observe     func(component string, latency time.Duration)
+}
+
+// Default returns the default pipeline (rdns, category)
+func Default() *Pipeline {
+	p, _ := New(DefaultConfig())
+
+	return p
+}
+
+// DefaultConfig returns the configuration of the default pipeline
+func DefaultConfig() domain.EnrichmentConfig {
+	return domain.EnrichmentConfig{
+		Stages: []domain.EnrichmentStage{{Name: StageRDNS}, {Name: StageCategory}},
+	}
+}
+
+// SetResolver sets the resolver of the rdns stages of the configuration
+// without a resolver
+func SetResolver(cfg *domain.EnrichmentConfig, resolver string) {
+	for i, stage := range cfg.Stages {
+		if stage.Name == StageRDNS && stage.Resolver == "" {
+			cfg.Stages[i].Resolver = resolver
+		}
+	}
+}
+
+// LoadConfig reads the enrichment configuration file
+func LoadConfig(path string) (domain.EnrichmentConfig, error) {
+	var cfg domain.EnrichmentConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read enrichment config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse enrichment config [%s]: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// New returns the pipeline of the configured stages
+func New(cfg domain.EnrichmentConfig) (*Pipeline, error) {
+	var p = &Pipeline{}
+
+	for _, sc := range cfg.Stages {
+		registryMu.RLock()
+		factory, ok := registry[sc.Name]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown enrichment stage [%s], available stages: %v", sc.Name, Stages())
+		}
+
+		timeout, err := stageTimeout(sc)
+		if err != nil {
+			return nil, err
+		}
+
+		stage, err := factory(sc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the enrichment stage [%s]: %w", sc.Name, err)
+		}
+
+		p.stages = append(p.stages, stage)
+		p.timeouts = append(p.timeouts, timeout)
+		if prefetcher, ok := stage.(Prefetcher); ok {
+			p.prefetchers = append(p.prefetchers, prefetcher)
+		}
+	}
+
+	return p, nil
+}
+
+// stageTimeout returns the configured timeout of the stage, defaultTimeout if not set
+func stageTimeout(cfg domain.EnrichmentStage) (time.Duration, error) {
+	if cfg.Timeout == "" {
+		return defaultTimeout, nil
+	}
+
+	d, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout of the enrichment stage [%s]: %w", cfg.Name, err)
+	}
+
+	return d, nil
+}
+
+// Names returns the names of the stages in order
+func (p *Pipeline) Names() []string {
+	var names []string
+	for _, s := range p.stages {
+		names = append(names, s.Name())
+	}
+
+	return names
+}
+
+// SetObserver sets the observer of the stage latencies, the component is enrich/<stage>
+func (p *Pipeline) SetObserver(observe func(component string, latency time.Duration)) {
+	p.observe = observe
+}
+
+// Prefetch prepares the enrichment of the address in the background and calls
+// done when the event of the address can run through the pipeline without
+// waiting. done is called right away if no stage prefetches.
+func (p *Pipeline) Prefetch(addr string, done func()) {
+	if len(p.prefetchers) == 0 {
+		done()
+		return
+	}
+
+	var remaining atomic.Int32
+	remaining.Store(int32(len(p.prefetchers)))
+	for _, prefetcher := range p.prefetchers {
+		prefetcher.Prefetch(addr, func() {
+			if remaining.Add(-1) == 0 {
+				done()
+			}
+		})
+	}
+}
+
+// Warm caches the domain names of the address in the stages
+func (p *Pipeline) Warm(addr string, names []string) {
+	for _, stage := range p.stages {
+		if warmer, ok := stage.(Warmer); ok {
+			warmer.Warm(addr, names)
+		}
+	}
+}
+
+// Run runs the stages in order. A failing stage doesn't stop the pipeline.
+func (p *Pipeline) Run(ctx context.Context, event *domain.ReportEvent) {
+	for i, stage := range p.stages {
+		var start = time.Now()
+		sctx, cancel := context.WithTimeout(ctx, p.timeouts[i])
+		if err := stage.Enrich(sctx, event); err != nil {
+			logger.Log.Debugf("enrichment stage [%s] failed: %v", stage.Name(), err)
+		}
+		cancel()
+
+		if p.observe != nil {
+			p.observe("enrich/"+stage.Name(), time.Since(start))
+		}
+	}
+}
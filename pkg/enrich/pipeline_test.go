@@ -0,0 +1,152 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const testDatabase = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"140.82.112.0\t140.82.127.255\t36459\tUS\tGITHUB\n"
+
+const testConfig = `
+stages:
+  - name: asn
+    database: %s
+  - name: geoip
+    database: %s
+  - name: exec
+    command: ["sh", "-c", "echo '{\"team\": \"platform\"}'"]
+    timeout: 5s
+`
+
+func TestPipeline(t *testing.T) {
+	var dir = t.TempDir()
+	var database = filepath.Join(dir, "ip2asn-v4.tsv")
+	if err := os.WriteFile(database, []byte(testDatabase), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var config = filepath.Join(dir, "enrichment.yaml")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(testConfig, database, database)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(config)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+
+	if want := []string{StageASN, StageGeoIP, StageExec}; !reflect.DeepEqual(p.Names(), want) {
+		t.Errorf("expected stages %v, got %v", want, p.Names())
+	}
+
+	var event = domain.ReportEvent{DestinationAddress: "140.82.114.22"}
+	p.Run(context.Background(), &event)
+
+	if event.ASN != 36459 || event.ASOrg != "GITHUB" || event.Country != "US" {
+		t.Errorf("unexpected asn/geoip enrichment: %+v", event)
+	}
+	if event.Labels["team"] != "platform" {
+		t.Errorf("expected the exec stage label, got %v", event.Labels)
+	}
+
+	event = domain.ReportEvent{DestinationAddress: "1.0.2.1"}
+	p.Run(context.Background(), &event)
+	if event.ASN != 0 || event.Country != "" {
+		t.Errorf("expected no enrichment of an unrouted address: %+v", event)
+	}
+}
+
+func TestCategory(t *testing.T) {
+	var feed = filepath.Join(t.TempDir(), "categories.csv")
+	if err := os.WriteFile(feed, []byte("# internal\nartifacts.example.com,package-registry\nexample.com,internal\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(domain.EnrichmentConfig{Stages: []domain.EnrichmentStage{{Name: StageCategory, Database: feed}}})
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+
+	var tests = []struct {
+		domains  []string
+		expected string
+	}{
+		{[]string{"registry.npmjs.org"}, domain.DestinationCategoryPackageRegistry},
+		{[]string{"ec2-3-5-140-2.compute-1.amazonaws.com."}, domain.DestinationCategoryCloudProvider},
+		{[]string{"stats.g.doubleclick.net"}, domain.DestinationCategoryAds},
+		// the longest suffix wins
+		{[]string{"www.example.com", "eu.artifacts.example.com"}, domain.DestinationCategoryPackageRegistry},
+		{[]string{"api.example.com"}, "internal"},
+		{[]string{"."}, domain.DestinationCategoryUnknown},
+		{nil, domain.DestinationCategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		var event = domain.ReportEvent{Domains: tt.domains}
+		p.Run(context.Background(), &event)
+		if event.Category != tt.expected {
+			t.Errorf("%v: expected category %s, got %s", tt.domains, tt.expected, event.Category)
+		}
+	}
+
+	var invalid = filepath.Join(t.TempDir(), "invalid.csv")
+	if err := os.WriteFile(invalid, []byte("example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(domain.EnrichmentConfig{Stages: []domain.EnrichmentStage{{Name: StageCategory, Database: invalid}}}); err == nil {
+		t.Error("expected an error for an invalid feed")
+	}
+}
+
+func TestNew_UnknownStage(t *testing.T) {
+	_, err := New(domain.EnrichmentConfig{Stages: []domain.EnrichmentStage{{Name: "whois"}}})
+	if err == nil {
+		t.Errorf("expected an error for an unknown stage")
+	}
+}
+
+type staticStage struct{ label string }
+
+func (s staticStage) Name() string { return "static" }
+
+func (s staticStage) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	event.Labels = map[string]string{"static": s.label}
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("static", func(cfg domain.EnrichmentStage) (Stage, error) {
+		return staticStage{label: cfg.Database}, nil
+	})
+
+	p, err := New(domain.EnrichmentConfig{Stages: []domain.EnrichmentStage{{Name: "static", Database: "ok"}}})
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+
+	var observed []string
+	p.SetObserver(func(component string, _ time.Duration) { observed = append(observed, component) })
+
+	var event domain.ReportEvent
+	p.Run(context.Background(), &event)
+	if event.Labels["static"] != "ok" {
+		t.Errorf("expected the registered stage to run, got %v", event.Labels)
+	}
+	if len(observed) != 1 || observed[0] != "enrich/static" {
+		t.Errorf("expected the stage latency to be observed, got %v", observed)
+	}
+}
@@ -0,0 +1,362 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// StageReputation scores the destination from the threat feed, the ASN, the
+// age of the domain and the first contact
+const StageReputation = "reputation"
+
+const (
+	// defaultRDAP is the RDAP bootstrap service, it redirects to the RDAP server of the TLD
+	defaultRDAP = "https://rdap.org"
+
+	maxReputation = 100
+	// the penalties of the signals
+	penaltyFeed      = 60
+	penaltyASN       = 30
+	penaltyNewDomain = 30
+	penaltyYoung     = 10
+	penaltyFirstSeen = 10
+
+	// newDomainAge and youngDomainAge are the ages of the recently registered domains
+	newDomainAge   = 30 * 24 * time.Hour
+	youngDomainAge = 365 * 24 * time.Hour
+
+	// rdapMaxLabels is the number of the labels of the longest registered
+	// domain looked up (example.co.uk)
+	rdapMaxLabels = 3
+	// rdapMaxSize is the size limit of an RDAP response
+	rdapMaxSize = 1 << 20
+)
+
+// errRDAPNotFound is returned by the RDAP lookup of a name that isn't registered
+var errRDAPNotFound = errors.New("domain not found")
+
+// reputation scores the destination: 100 minus the penalties of the signals,
+// the score is at least 0. It runs after the rdns and the asn stages.
+type reputation struct {
+	// feed is the threat feed, see loadFeed
+	feed *threatFeed
+	// rdap is the base URL of the RDAP service, the age lookups are disabled if empty
+	rdap   string
+	client *http.Client
+	// seen are the destinations of the previous runs, appended to the store
+	seen *seenStore
+	now  func() time.Time
+
+	mu sync.Mutex
+	// registered are the registration times of the domains, zero if unknown
+	registered map[string]time.Time
+}
+
+func newReputation(cfg domain.EnrichmentStage) (Stage, error) {
+	var s = &reputation{
+		feed:       &threatFeed{},
+		rdap:       defaultRDAP,
+		client:     &http.Client{},
+		now:        time.Now,
+		registered: make(map[string]time.Time),
+	}
+
+	if cfg.Database != "" {
+		file, err := os.Open(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if s.feed, err = loadFeed(file); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Database, err)
+		}
+	}
+
+	switch cfg.RDAP {
+	case "":
+	case "none":
+		s.rdap = ""
+	default:
+		s.rdap = strings.TrimSuffix(cfg.RDAP, "/")
+	}
+
+	if cfg.Store != "" {
+		seen, err := openSeenStore(cfg.Store)
+		if err != nil {
+			return nil, err
+		}
+		s.seen = seen
+	}
+
+	return s, nil
+}
+
+func (*reputation) Name() string { return StageReputation }
+
+func (s *reputation) Enrich(ctx context.Context, event *domain.ReportEvent) error {
+	var score = maxReputation
+	var signals []string
+	penalize := func(penalty int, signal string) {
+		score -= penalty
+		signals = append(signals, signal)
+	}
+
+	var name = domainName(event)
+	if entry, ok := s.feed.match(event.DestinationAddress, name); ok {
+		penalize(penaltyFeed, "feed:"+entry)
+	}
+	if event.ASN != 0 && s.feed.asns[event.ASN] {
+		penalize(penaltyASN, fmt.Sprintf("asn:AS%d", event.ASN))
+	}
+
+	var err error
+	if name != "" && s.rdap != "" {
+		var registered time.Time
+		if registered, err = s.registeredAt(ctx, name); !registered.IsZero() {
+			switch age := s.now().Sub(registered); {
+			case age < newDomainAge:
+				penalize(penaltyNewDomain, fmt.Sprintf("domain-age:%dd", int(age.Hours()/24)))
+			case age < youngDomainAge:
+				penalize(penaltyYoung, fmt.Sprintf("domain-age:%dd", int(age.Hours()/24)))
+			}
+		}
+	}
+
+	if s.seen != nil {
+		var key = name
+		if key == "" {
+			key = event.DestinationAddress
+		}
+		if s.seen.add(key) {
+			penalize(penaltyFirstSeen, "first-seen")
+		}
+	}
+
+	event.Reputation = &domain.Reputation{Score: max(score, 0), Signals: signals}
+
+	return err
+}
+
+// registeredAt returns the registration time of the registered domain of the
+// name, zero if unknown. The lookups are cached, the failed ones too.
+func (s *reputation) registeredAt(ctx context.Context, name string) (time.Time, error) {
+	var labels = strings.Split(name, ".")
+	if len(labels) < 2 {
+		return time.Time{}, nil
+	}
+
+	// the registered domain is the shortest suffix known by RDAP (github.com
+	// of api.github.com, example.co.uk of www.example.co.uk)
+	for n := 2; n <= rdapMaxLabels && n <= len(labels); n++ {
+		var candidate = strings.Join(labels[len(labels)-n:], ".")
+
+		s.mu.Lock()
+		registered, ok := s.registered[candidate]
+		s.mu.Unlock()
+		if ok {
+			if registered.IsZero() {
+				continue
+			}
+			return registered, nil
+		}
+
+		registered, err := s.lookup(ctx, candidate)
+
+		s.mu.Lock()
+		s.registered[candidate] = registered
+		s.mu.Unlock()
+
+		switch {
+		case err == nil:
+			return registered, nil
+		case !errors.Is(err, errRDAPNotFound):
+			return time.Time{}, err
+		}
+	}
+
+	return time.Time{}, nil
+}
+
+// lookup returns the registration time of the domain from the RDAP service
+func (s *reputation) lookup(ctx context.Context, name string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.rdap+"/domain/"+name, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("rdap [%s]: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return time.Time{}, errRDAPNotFound
+	default:
+		return time.Time{}, fmt.Errorf("rdap [%s]: %s", name, resp.Status)
+	}
+
+	var body struct {
+		Events []struct {
+			Action string    `json:"eventAction"`
+			Date   time.Time `json:"eventDate"`
+		} `json:"events"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, rdapMaxSize)).Decode(&body); err != nil {
+		return time.Time{}, fmt.Errorf("rdap [%s]: %w", name, err)
+	}
+
+	for _, e := range body.Events {
+		if e.Action == "registration" {
+			return e.Date, nil
+		}
+	}
+
+	return time.Time{}, errRDAPNotFound
+}
+
+// domainName returns the first domain name of the event in lowercase without
+// the trailing dot, empty if unknown
+func domainName(event *domain.ReportEvent) string {
+	for _, name := range event.Domains {
+		if name = strings.Trim(strings.ToLower(name), "."); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// threatFeed is a list of the malicious destinations: the addresses, the
+// ranges, the domain suffixes and the autonomous systems
+type threatFeed struct {
+	addresses map[string]bool
+	ranges    []*net.IPNet
+	suffixes  map[string]bool
+	asns      map[uint32]bool
+}
+
+// loadFeed reads the feed, a line per entry: an IP address, a CIDR, a domain
+// suffix or an autonomous system (AS14061). The lines starting with # are comments.
+func loadFeed(r io.Reader) (*threatFeed, error) {
+	var feed = &threatFeed{
+		addresses: make(map[string]bool),
+		suffixes:  make(map[string]bool),
+		asns:      make(map[uint32]bool),
+	}
+
+	var scanner = bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var text = strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if ip := net.ParseIP(text); ip != nil {
+			feed.addresses[ip.String()] = true
+			continue
+		}
+		if _, ipnet, err := net.ParseCIDR(text); err == nil {
+			feed.ranges = append(feed.ranges, ipnet)
+			continue
+		}
+		if number, ok := strings.CutPrefix(strings.ToUpper(text), "AS"); ok {
+			if asn, err := strconv.ParseUint(number, 10, 32); err == nil {
+				feed.asns[uint32(asn)] = true
+				continue
+			}
+		}
+
+		var suffix = strings.Trim(strings.ToLower(text), ".")
+		if suffix == "" || strings.ContainsAny(suffix, " ,/") {
+			return nil, fmt.Errorf("invalid feed entry at line %d: %s", line, text)
+		}
+		feed.suffixes[suffix] = true
+	}
+
+	return feed, scanner.Err()
+}
+
+// match returns the entry of the feed matching the address or the domain name
+func (f *threatFeed) match(addr, name string) (string, bool) {
+	if ip := net.ParseIP(addr); ip != nil {
+		if f.addresses[ip.String()] {
+			return ip.String(), true
+		}
+		for _, r := range f.ranges {
+			if r.Contains(ip) {
+				return r.String(), true
+			}
+		}
+	}
+
+	for name != "" {
+		if f.suffixes[name] {
+			return name, true
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+
+	return "", false
+}
+
+// seenStore is the destinations of the previous runs, a line per destination.
+// The new destinations are appended to the file.
+type seenStore struct {
+	mu   sync.Mutex
+	file *os.File
+	seen map[string]bool
+}
+
+func openSeenStore(path string) (*seenStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the reputation store: %w", err)
+	}
+
+	var store = &seenStore{file: file, seen: make(map[string]bool)}
+	var scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			store.seen[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read the reputation store: %w", err)
+	}
+
+	return store, nil
+}
+
+// add adds the destination, returns true if it's seen for the first time
+func (s *seenStore) add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	// a failed write only repeats the signal on the next run
+	_, _ = fmt.Fprintln(s.file, key)
+
+	return true
+}
@@ -0,0 +1,97 @@
+package enrich
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const testFeed = "# test feed\n45.9.148.3\n185.220.100.0/22\nbad.example\nAS14061\n"
+
+func TestReputation(t *testing.T) {
+	var now = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var lookups int
+	var rdap = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		switch r.URL.Path {
+		case "/domain/github.com":
+			w.Write([]byte(`{"events":[{"eventAction":"registration","eventDate":"2007-10-09T18:20:50Z"}]}`))
+		case "/domain/fresh.dev":
+			w.Write([]byte(`{"events":[{"eventAction":"registration","eventDate":"2026-02-20T00:00:00Z"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer rdap.Close()
+
+	var dir = t.TempDir()
+	var feed = filepath.Join(dir, "feed.txt")
+	if err := os.WriteFile(feed, []byte(testFeed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var store = filepath.Join(dir, "seen.txt")
+	if err := os.WriteFile(store, []byte("api.github.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stage, err := newReputation(domain.EnrichmentStage{Name: StageReputation, Database: feed, RDAP: rdap.URL, Store: store})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stage.(*reputation).now = func() time.Time { return now }
+
+	var tests = []struct {
+		event   domain.ReportEvent
+		score   int
+		signals []string
+	}{
+		{domain.ReportEvent{DestinationAddress: "140.82.114.22", Domains: []string{"api.github.com."}}, 100, nil},
+		{domain.ReportEvent{DestinationAddress: "45.9.148.3", Domains: []string{"."}}, 30, []string{"feed:45.9.148.3", "first-seen"}},
+		{domain.ReportEvent{DestinationAddress: "185.220.101.7", ASN: 14061}, 0, []string{"feed:185.220.100.0/22", "asn:AS14061", "first-seen"}},
+		{domain.ReportEvent{DestinationAddress: "10.1.1.1", Domains: []string{"cdn.fresh.dev"}}, 60, []string{"domain-age:9d", "first-seen"}},
+		{domain.ReportEvent{DestinationAddress: "10.1.1.2", Domains: []string{"x.bad.example"}}, 30, []string{"feed:bad.example", "first-seen"}},
+		// seen on the first event
+		{domain.ReportEvent{DestinationAddress: "10.1.1.3", Domains: []string{"cdn.fresh.dev"}}, 70, []string{"domain-age:9d"}},
+	}
+
+	for _, tt := range tests {
+		var event = tt.event
+		if err := stage.Enrich(context.Background(), &event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if event.Reputation == nil || event.Reputation.Score != tt.score || !reflect.DeepEqual(event.Reputation.Signals, tt.signals) {
+			t.Errorf("%s: expected score %d %v, got %+v", event.DestinationAddress, tt.score, tt.signals, event.Reputation)
+		}
+	}
+
+	// github.com, fresh.dev, bad.example and x.bad.example (not found)
+	if lookups != 4 {
+		t.Errorf("expected the lookups to be cached, got %d lookups", lookups)
+	}
+
+	data, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "api.github.com\n45.9.148.3\n185.220.101.7\ncdn.fresh.dev\nx.bad.example\n"; string(data) != want {
+		t.Errorf("unexpected store %q", data)
+	}
+}
+
+func TestLoadFeed_Invalid(t *testing.T) {
+	var feed = filepath.Join(t.TempDir(), "feed.txt")
+	if err := os.WriteFile(feed, []byte("bad entry, with spaces\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newReputation(domain.EnrichmentStage{Name: StageReputation, Database: feed}); err == nil {
+		t.Error("expected an error for an invalid feed")
+	}
+}
@@ -0,0 +1,172 @@
+package enrich
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// resolverWorkers is the number of the concurrent reverse lookups
+	resolverWorkers = 8
+	// resolverMaxPending is the number of the addresses waiting for a lookup,
+	// the events of the other addresses are not delayed above
+	resolverMaxPending = 1024
+	resolverCacheSize  = 4096
+	// resolverTTL is the lifetime of the resolved names, the reverse lookups
+	// don't return the TTL of the records
+	resolverTTL = 10 * time.Minute
+	// resolverNegativeTTL is the lifetime of a failed lookup, the addresses
+	// without a PTR record are not looked up for each connection
+	resolverNegativeTTL = time.Minute
+)
+
+// LookupFunc resolves the domain names of an address (net.Resolver.LookupAddr)
+type LookupFunc func(ctx context.Context, addr string) ([]string, error)
+
+// Resolver resolves the domain names of the addresses in the background with a
+// pool of workers. The names are kept in an LRU cache, the failed lookups too
+// for a shorter time. The concurrent lookups of an address are merged.
+type Resolver struct {
+	lookup  LookupFunc
+	timeout time.Duration
+	workers chan struct{}
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// pending are the callbacks of the addresses being looked up
+	pending map[string][]func()
+	now     func() time.Time
+}
+
+type resolverEntry struct {
+	addr    string
+	names   []string
+	expires time.Time
+}
+
+// NewResolver returns a resolver of the lookup function, each lookup has the timeout
+func NewResolver(lookup LookupFunc, timeout time.Duration) *Resolver {
+	return &Resolver{
+		lookup:  lookup,
+		timeout: timeout,
+		workers: make(chan struct{}, resolverWorkers),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string][]func()),
+		now:     time.Now,
+	}
+}
+
+// Cached returns the cached names of the address, the names of a failed
+// lookup are empty. It's false if the address is not resolved yet.
+func (r *Resolver) Cached(addr string) ([]string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, ok := r.entries[addr]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*resolverEntry)
+	if r.now().After(entry.expires) {
+		r.lru.Remove(elem)
+		delete(r.entries, addr)
+		return nil, false
+	}
+	r.lru.MoveToFront(elem)
+
+	return entry.names, true
+}
+
+// Resolve looks up the address in the background and calls done when the
+// names are cached. done is called right away if the address is cached, or
+// if too many addresses are waiting; it may be nil.
+func (r *Resolver) Resolve(addr string, done func()) {
+	if _, ok := r.Cached(addr); ok {
+		if done != nil {
+			done()
+		}
+		return
+	}
+
+	r.mu.Lock()
+	waiters, inflight := r.pending[addr]
+	if !inflight && len(r.pending) >= resolverMaxPending {
+		r.mu.Unlock()
+		if done != nil {
+			done()
+		}
+		return
+	}
+	if done != nil {
+		waiters = append(waiters, done)
+	}
+	r.pending[addr] = waiters
+	r.mu.Unlock()
+
+	if !inflight {
+		go r.resolve(addr)
+	}
+}
+
+// Warm caches the names of the address unless it's cached, the names are
+// looked up again once they expire
+func (r *Resolver) Warm(addr string, names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[addr]; ok {
+		return
+	}
+	r.store(addr, append([]string(nil), names...), resolverTTL)
+}
+
+// resolve looks up the address on a worker and calls the waiting callbacks in order
+func (r *Resolver) resolve(addr string) {
+	r.workers <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	names, err := r.lookup(ctx, addr)
+	cancel()
+	<-r.workers
+
+	var ttl = resolverTTL
+	if err != nil {
+		names, ttl = nil, resolverNegativeTTL
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ".")
+	}
+
+	r.mu.Lock()
+	r.store(addr, names, ttl)
+	var waiters = r.pending[addr]
+	delete(r.pending, addr)
+	r.mu.Unlock()
+
+	for _, done := range waiters {
+		done()
+	}
+}
+
+// store caches the names of the address, must be called with the lock held
+func (r *Resolver) store(addr string, names []string, ttl time.Duration) {
+	var entry = &resolverEntry{addr: addr, names: names, expires: r.now().Add(ttl)}
+
+	if elem, ok := r.entries[addr]; ok {
+		elem.Value = entry
+		r.lru.MoveToFront(elem)
+		return
+	}
+
+	r.entries[addr] = r.lru.PushFront(entry)
+	if r.lru.Len() > resolverCacheSize {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*resolverEntry).addr)
+	}
+}
@@ -0,0 +1,149 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestResolver(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+	)
+	r := NewResolver(func(_ context.Context, addr string) ([]string, error) {
+		calls.Add(1)
+		<-release
+		if addr == "10.0.0.1" {
+			return nil, errors.New("no PTR record")
+		}
+		return []string{"lb-140-82-114-22.github.com."}, nil
+	}, time.Second)
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	// the concurrent lookups of an address are merged, the callbacks are called in order
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		i := i
+		r.Resolve("140.82.114.22", func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+	if _, ok := r.Cached("140.82.114.22"); ok {
+		t.Errorf("expected the address not to be resolved before the lookup")
+	}
+
+	wg.Add(1)
+	r.Resolve("10.0.0.1", wg.Done)
+
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 lookups, got %d", calls.Load())
+	}
+	if !reflect.DeepEqual(order, []int{0, 1, 2}) {
+		t.Errorf("expected the callbacks in order, got %v", order)
+	}
+
+	names, ok := r.Cached("140.82.114.22")
+	if !ok || !reflect.DeepEqual(names, []string{"lb-140-82-114-22.github.com"}) {
+		t.Errorf("expected the cached names, got %v %v", names, ok)
+	}
+
+	// a failed lookup is cached without names until the negative ttl
+	if names, ok := r.Cached("10.0.0.1"); !ok || len(names) != 0 {
+		t.Errorf("expected the negative cache entry, got %v %v", names, ok)
+	}
+
+	// the cached address doesn't wait
+	var called bool
+	r.Resolve("140.82.114.22", func() { called = true })
+	if !called || calls.Load() != 2 {
+		t.Errorf("expected the cached address to be resolved right away")
+	}
+
+	var now = time.Now()
+	r.now = func() time.Time { return now.Add(resolverNegativeTTL + time.Second) }
+	if _, ok := r.Cached("10.0.0.1"); ok {
+		t.Errorf("expected the negative cache entry to expire")
+	}
+	if _, ok := r.Cached("140.82.114.22"); !ok {
+		t.Errorf("expected the resolved names to be kept")
+	}
+}
+
+func TestResolver_Evict(t *testing.T) {
+	r := NewResolver(nil, time.Second)
+
+	r.mu.Lock()
+	for i := 0; i <= resolverCacheSize; i++ {
+		r.store(fmt.Sprintf("10.0.%d.%d", i/256, i%256), nil, resolverTTL)
+	}
+	r.mu.Unlock()
+
+	if _, ok := r.Cached("10.0.0.0"); ok {
+		t.Errorf("expected the least recently used address to be evicted")
+	}
+	if _, ok := r.Cached(fmt.Sprintf("10.0.%d.%d", resolverCacheSize/256, resolverCacheSize%256)); !ok {
+		t.Errorf("expected the last address to be cached")
+	}
+}
+
+func TestPipeline_Prefetch(t *testing.T) {
+	var stage = &rdns{resolver: NewResolver(func(context.Context, string) ([]string, error) {
+		return []string{"github.com."}, nil
+	}, time.Second)}
+	var p = &Pipeline{stages: []Stage{stage}, timeouts: []time.Duration{time.Second}, prefetchers: []Prefetcher{stage}}
+
+	// the event of an address not resolved yet gets the placeholder
+	var event = domain.ReportEvent{DestinationAddress: "140.82.114.22"}
+	p.Run(context.Background(), &event)
+	if !reflect.DeepEqual(event.Domains, []string{"."}) {
+		t.Errorf("expected the placeholder, got %v", event.Domains)
+	}
+
+	var done = make(chan struct{})
+	p.Prefetch("140.82.114.22", func() { close(done) })
+	<-done
+
+	event = domain.ReportEvent{DestinationAddress: "140.82.114.22"}
+	p.Run(context.Background(), &event)
+	if !reflect.DeepEqual(event.Domains, []string{"github.com"}) {
+		t.Errorf("expected the prefetched names, got %v", event.Domains)
+	}
+}
+
+func TestResolver_Warm(t *testing.T) {
+	r := NewResolver(func(context.Context, string) ([]string, error) {
+		return []string{"lb-140-82-114-22.github.com."}, nil
+	}, time.Second)
+
+	r.Warm("140.82.114.22", []string{"github.com"})
+	if names, ok := r.Cached("140.82.114.22"); !ok || !reflect.DeepEqual(names, []string{"github.com"}) {
+		t.Errorf("expected the warmed names, got %v %v", names, ok)
+	}
+
+	// the resolved names are not overwritten
+	var done = make(chan struct{})
+	r.Resolve("10.0.0.1", func() { close(done) })
+	<-done
+	r.Warm("10.0.0.1", []string{"example.com"})
+	if names, _ := r.Cached("10.0.0.1"); !reflect.DeepEqual(names, []string{"lb-140-82-114-22.github.com"}) {
+		t.Errorf("expected the resolved names, got %v", names)
+	}
+}
@@ -0,0 +1,213 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"slices"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+const (
+	// StageRDNS resolves the domain names of the destination (reverse DNS)
+	StageRDNS = "rdns"
+	// StageContainer adds the container id of the process
+	StageContainer = "container"
+	// StageGeoIP adds the country of the destination
+	StageGeoIP = "geoip"
+	// StageASN adds the autonomous system of the destination
+	StageASN = "asn"
+	// StageExec runs an external command
+	StageExec = "exec"
+)
+
+// errNotResolved is the error of an address without a resolved name yet
+var errNotResolved = errors.New("the address is not resolved yet")
+
+// rdns resolves the domain names of the destination. The names are resolved
+// in the background before the event runs through the pipeline (see
+// Pipeline.Prefetch), the stage reads the cache and never blocks.
+type rdns struct {
+	resolver *Resolver
+}
+
+func newRDNS(cfg domain.EnrichmentStage) (Stage, error) {
+	timeout, err := stageTimeout(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := NewNetResolver(cfg.Resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rdns{resolver: NewResolver(resolver.LookupAddr, timeout)}, nil
+}
+
+func (s *rdns) Name() string { return StageRDNS }
+
+// Prefetch resolves the address in the background, done is called when the name is cached
+func (s *rdns) Prefetch(addr string, done func()) {
+	s.resolver.Resolve(addr, done)
+}
+
+// Warm caches the names of the address known by a previous job
+func (s *rdns) Warm(addr string, names []string) {
+	s.resolver.Warm(addr, names)
+}
+
+func (s *rdns) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	names, ok := s.resolver.Cached(event.DestinationAddress)
+	if !ok {
+		// the address is resolved for the next events
+		s.resolver.Resolve(event.DestinationAddress, nil)
+	}
+
+	if len(names) == 0 {
+		// the domain placeholder of the failed lookups, unless the
+		// domain name is known from the TLS server name
+		if len(event.Domains) == 0 {
+			event.Domains = append(event.Domains, ".")
+		}
+		if !ok {
+			return errNotResolved
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if !slices.Contains(event.Domains, name) {
+			event.Domains = append(event.Domains, name)
+		}
+	}
+
+	return nil
+}
+
+// containerID matches the container id in the cgroup path
+// (docker-<id>.scope, /docker/<id>, cri-containerd-<id>.scope)
+var containerID = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// container adds the container id of the process
+type container struct{}
+
+func newContainer(domain.EnrichmentStage) (Stage, error) {
+	return container{}, nil
+}
+
+func (container) Name() string { return StageContainer }
+
+func (container) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	cgroup, err := process.CgroupOf(event.ProcessID)
+	if err != nil {
+		return err
+	}
+
+	if id := containerID.FindString(cgroup); id != "" {
+		event.Container = id[:12]
+	}
+
+	return nil
+}
+
+// geoip adds the country of the destination
+type geoip struct {
+	db ipDatabase
+}
+
+func newGeoIP(cfg domain.EnrichmentStage) (Stage, error) {
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return geoip{db: db}, nil
+}
+
+func (geoip) Name() string { return StageGeoIP }
+
+func (s geoip) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	if r, ok := s.db.Lookup(net.ParseIP(event.DestinationAddress)); ok {
+		event.Country = r.Country
+	}
+
+	return nil
+}
+
+// asn adds the autonomous system of the destination
+type asn struct {
+	db ipDatabase
+}
+
+func newASN(cfg domain.EnrichmentStage) (Stage, error) {
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn{db: db}, nil
+}
+
+func (asn) Name() string { return StageASN }
+
+func (s asn) Enrich(_ context.Context, event *domain.ReportEvent) error {
+	if r, ok := s.db.Lookup(net.ParseIP(event.DestinationAddress)); ok {
+		event.ASN = r.ASN
+		event.ASOrg = r.Org
+	}
+
+	return nil
+}
+
+// execStage runs an external command, the event is written to stdin as JSON
+// and the labels are read from stdout as a JSON object
+type execStage struct {
+	command []string
+}
+
+func newExec(cfg domain.EnrichmentStage) (Stage, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("command is required")
+	}
+
+	return execStage{command: cfg.Command}, nil
+}
+
+func (execStage) Name() string { return StageExec }
+
+func (s execStage) Enrich(ctx context.Context, event *domain.ReportEvent) error {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", s.command[0], err)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &labels); err != nil {
+		return fmt.Errorf("%s: invalid output: %w", s.command[0], err)
+	}
+
+	if len(labels) > 0 && event.Labels == nil {
+		event.Labels = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		event.Labels[k] = v
+	}
+
+	return nil
+}
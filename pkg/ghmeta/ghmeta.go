@@ -0,0 +1,149 @@
+// Package ghmeta fetches the IP ranges of the GitHub services from the meta
+// API (https://api.github.com/meta). The ranges change over time, the copy of
+// the policy bundle (bundle/assets/github/data.json) is the fallback.
+package ghmeta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/bundle"
+)
+
+const (
+	// DefaultURL is the meta API of github.com, GitHub Enterprise Server
+	// serves it at https://HOSTNAME/api/v3/meta
+	DefaultURL = "https://api.github.com/meta"
+	// DefaultInterval is the refresh interval of the ranges
+	DefaultInterval = time.Hour
+	// TokenEnv is the token of the meta API requests, the unauthenticated
+	// requests are rate limited
+	TokenEnv = "GITHUB_TOKEN"
+
+	bundledPath = "assets/github/data.json"
+	httpTimeout = 10 * time.Second
+	// maxSize is the size limit of a meta document
+	maxSize = 8 << 20
+)
+
+// DefaultSections are the sections of the ranges of the runners (actions) and
+// of the web and API endpoints of github.com
+var DefaultSections = []string{"actions", "web", "api"}
+
+// Fetcher downloads the ranges of the sections of the meta API
+type Fetcher struct {
+	url      string
+	sections []string
+	token    string
+	client   *http.Client
+}
+
+// NewFetcher returns the fetcher of the sections of the meta API at the URL,
+// DefaultURL and DefaultSections if empty
+func NewFetcher(url string, sections []string) *Fetcher {
+	if url == "" {
+		url = DefaultURL
+	}
+	if len(sections) == 0 {
+		sections = DefaultSections
+	}
+
+	return &Fetcher{
+		url:      url,
+		sections: sections,
+		token:    os.Getenv(TokenEnv),
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// Fetch downloads the meta document and returns the ranges of the sections
+func (f *Fetcher) Fetch(ctx context.Context) ([]netip.Prefix, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch github meta: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch github meta: %s", resp.Status)
+	}
+
+	doc, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch github meta: %w", err)
+	}
+
+	return Ranges(doc, f.sections)
+}
+
+// Bundled returns the ranges of the sections of the copy of the policy bundle
+func (f *Fetcher) Bundled() ([]netip.Prefix, error) {
+	doc, err := bundle.Bundle.ReadFile(bundledPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return Ranges(doc, f.sections)
+}
+
+// Ranges returns the sorted ranges of the sections of the meta document, an
+// unknown section is an error. The sections of other values (ssh_keys) are
+// not ranges.
+func Ranges(doc []byte, sections []string) ([]netip.Prefix, error) {
+	var meta map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return nil, fmt.Errorf("invalid github meta: %w", err)
+	}
+
+	var seen = make(map[netip.Prefix]bool)
+	var ranges []netip.Prefix
+	for _, section := range sections {
+		section = strings.TrimSpace(section)
+		raw, ok := meta[section]
+		if !ok {
+			return nil, fmt.Errorf("invalid github meta: no section [%s]", section)
+		}
+
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("invalid github meta: section [%s] is not a list of ranges", section)
+		}
+
+		for _, value := range values {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid github meta: section [%s]: %w", section, err)
+			}
+			if prefix = prefix.Masked(); !seen[prefix] {
+				seen[prefix] = true
+				ranges = append(ranges, prefix)
+			}
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		if c := ranges[i].Addr().Compare(ranges[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return ranges[i].Bits() < ranges[j].Bits()
+	})
+
+	return ranges, nil
+}
@@ -0,0 +1,76 @@
+package ghmeta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+const testMeta = `{
+  "verifiable_password_authentication": false,
+  "ssh_keys": ["ssh-ed25519 AAAA"],
+  "web": ["140.82.112.0/20", "2a0a:a440::/29"],
+  "api": ["140.82.112.0/20", "192.30.252.0/22"],
+  "actions": ["4.148.0.0/16", "4.148.1.2/16"]
+}`
+
+func TestRanges(t *testing.T) {
+	ranges, err := Ranges([]byte(testMeta), []string{"actions", "web", "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ranges are masked, sorted and deduplicated
+	var expected = []netip.Prefix{
+		netip.MustParsePrefix("4.148.0.0/16"),
+		netip.MustParsePrefix("140.82.112.0/20"),
+		netip.MustParsePrefix("192.30.252.0/22"),
+		netip.MustParsePrefix("2a0a:a440::/29"),
+	}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("expected %v, got %v", expected, ranges)
+	}
+
+	for _, sections := range [][]string{{"hooks"}, {"ssh_keys"}, {"verifiable_password_authentication"}} {
+		if _, err := Ranges([]byte(testMeta), sections); err == nil {
+			t.Errorf("%v: expected an error", sections)
+		}
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(testMeta))
+	}))
+	defer server.Close()
+
+	t.Setenv(TokenEnv, "token")
+	ranges, err := NewFetcher(server.URL, []string{"api"}).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 || authorization != "Bearer token" {
+		t.Errorf("unexpected ranges %v, authorization %q", ranges, authorization)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limit exceeded", http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	var fetcher = NewFetcher(failing.URL, nil)
+	if _, err := fetcher.Fetch(context.Background()); err == nil {
+		t.Error("expected an error")
+	}
+
+	// the copy of the policy bundle is the fallback
+	bundled, err := fetcher.Bundled()
+	if err != nil || len(bundled) == 0 {
+		t.Errorf("expected the bundled ranges, got %d %v", len(bundled), err)
+	}
+}
@@ -0,0 +1,114 @@
+package hygiene
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// DefaultRuns is the number of the runs without a hit of a stale rule
+const DefaultRuns = 5
+
+// Store keeps the hit history of the policy rules over the runs
+type Store struct {
+	path    string
+	history domain.RuleHistory
+}
+
+// Load reads the history file. An empty history is returned if the file doesn't exist.
+func Load(path string) (*Store, error) {
+	var s = &Store{
+		path:    path,
+		history: domain.RuleHistory{Rules: make(map[string]domain.RuleRecord)},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read rule history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule history: %w", err)
+	}
+	if s.history.Rules == nil {
+		s.history.Rules = make(map[string]domain.RuleRecord)
+	}
+
+	return s, nil
+}
+
+// Update records the rule hits of a run. The idle runs of the rules without
+// a hit are incremented, the rules no longer in the policy are forgotten.
+func (s *Store) Update(hits []domain.RuleHit, now time.Time) {
+	var rules = make(map[string]domain.RuleRecord, len(hits))
+	for _, hit := range hits {
+		var record = s.history.Rules[hit.Rule]
+		if hit.Allowed+hit.Denied > 0 {
+			record.IdleRuns = 0
+			record.LastHit = now
+		} else {
+			record.IdleRuns++
+		}
+		rules[hit.Rule] = record
+	}
+
+	s.history.Rules = rules
+	s.history.UpdatedAt = now
+}
+
+// Stale returns the rules without a hit in the last runs, the longest idle first
+func (s *Store) Stale(runs int) []domain.StaleRule {
+	if runs <= 0 {
+		runs = DefaultRuns
+	}
+
+	var stale []domain.StaleRule
+	for rule, record := range s.history.Rules {
+		if record.IdleRuns < runs {
+			continue
+		}
+
+		var entry = domain.StaleRule{Rule: rule, IdleRuns: record.IdleRuns}
+		if !record.LastHit.IsZero() {
+			lastHit := record.LastHit
+			entry.LastHit = &lastHit
+		}
+		stale = append(stale, entry)
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].IdleRuns != stale[j].IdleRuns {
+			return stale[i].IdleRuns > stale[j].IdleRuns
+		}
+		return stale[i].Rule < stale[j].Rule
+	})
+
+	return stale
+}
+
+// Save persists the history
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s.history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule history: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create rule history directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rule history: %w", err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
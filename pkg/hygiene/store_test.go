@@ -0,0 +1,59 @@
+package hygiene
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestStore(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "rules.json")
+	var now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for run := 0; run < 3; run++ {
+		store, err := Load(path)
+		if err != nil {
+			t.Fatalf("failed to load the history: %v", err)
+		}
+
+		var hits = []domain.RuleHit{
+			{Rule: "allowed-hosts=.github.com", Allowed: 3},
+			{Rule: "allowed-ips=1.1.1.1"},
+			{Rule: "denied-hosts=pastebin.com"},
+		}
+		if run == 0 {
+			hits[2].Denied = 1
+		}
+		// a rule removed from the policy
+		if run < 2 {
+			hits = append(hits, domain.RuleHit{Rule: "allowed-ports=22"})
+		}
+
+		store.Update(hits, now.Add(time.Duration(run)*time.Hour))
+		if err := store.Save(); err != nil {
+			t.Fatalf("failed to save the history: %v", err)
+		}
+	}
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load the history: %v", err)
+	}
+
+	stale := store.Stale(2)
+	if len(stale) != 2 {
+		t.Fatalf("expected 2 stale rules, got %+v", stale)
+	}
+	if stale[0].Rule != "allowed-ips=1.1.1.1" || stale[0].IdleRuns != 3 || stale[0].LastHit != nil {
+		t.Errorf("unexpected stale rule: %+v", stale[0])
+	}
+	if stale[1].Rule != "denied-hosts=pastebin.com" || stale[1].IdleRuns != 2 || !stale[1].LastHit.Equal(now) {
+		t.Errorf("unexpected stale rule: %+v", stale[1])
+	}
+
+	if len(store.Stale(0)) != 0 {
+		t.Error("expected no stale rule with the default runs")
+	}
+}
@@ -24,3 +24,8 @@ func SetLevel(level string) {
 
 	Log.SetLevel(l)
 }
+
+// SetJSON writes the logs as JSON lines
+func SetJSON() {
+	Log.Formatter = &logrus.JSONFormatter{}
+}
@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const namespace = "kntrl"
+
+const (
+	// maxDestinations caps the destination series, the CDN and the ephemeral
+	// addresses of a long-running daemon would grow them without bound
+	maxDestinations = 1000
+	// otherDestination is the destination of the connections over the cap
+	otherDestination = "other"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// EventsTotal is the number of events read from the perf buffers
+	EventsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Number of connection events seen.",
+	})
+
+	// ConnectionsTotal is the number of connections by policy status (pass, block)
+	ConnectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connections_total",
+		Help:      "Number of connections by policy status.",
+	}, []string{"policy"})
+
+	// DestinationConnectionsTotal is the number of connections per destination
+	// (the domain, or the address if unresolved), up to maxDestinations
+	DestinationConnectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "destination_connections_total",
+		Help:      "Number of connections per destination.",
+	}, []string{"destination", "dport", "proto", "policy"})
+
+	destinations = newDestinationSet(maxDestinations)
+
+	// MapEntries is the number of entries in the eBPF maps
+	MapEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_entries",
+		Help:      "Number of entries in the eBPF maps.",
+	}, []string{"map"})
+
+	// MapMaxEntries is the capacity of the eBPF maps
+	MapMaxEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_max_entries",
+		Help:      "Maximum number of entries of the eBPF maps.",
+	}, []string{"map"})
+
+	// MapPressure is the ratio of the entries to the max entries of the eBPF maps
+	MapPressure = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_pressure",
+		Help:      "Ratio of the entries to the maximum entries of the eBPF maps.",
+	}, []string{"map"})
+
+	// ProgramRuns is the number of runs of the eBPF programs, counted by the kernel
+	ProgramRuns = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "program_runs",
+		Help:      "Number of runs of the eBPF programs (--program-stats).",
+	}, []string{"program"})
+
+	// ProgramRuntimeSeconds is the total runtime of the eBPF programs
+	ProgramRuntimeSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "program_runtime_seconds",
+		Help:      "Total runtime of the eBPF programs (--program-stats).",
+	}, []string{"program"})
+
+	// DNSQueriesTotal is the number of the DNS responses received by the processes
+	DNSQueriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dns_queries_total",
+		Help:      "Number of DNS queries answered by query type and response code.",
+	}, []string{"qtype", "rcode"})
+
+	// AnomaliesTotal is the number of the connections over the anomaly thresholds
+	AnomaliesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "anomalies_total",
+		Help:      "Number of connections over the anomaly thresholds by kind.",
+	}, []string{"kind"})
+
+	// SlowOperationsTotal is the number of operations slower than the threshold by component
+	SlowOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slow_operations_total",
+		Help:      "Number of operations of the event pipeline slower than the threshold.",
+	}, []string{"component"})
+
+	// MapOverflowTotal is the number of writes to a full eBPF map by the overflow action
+	MapOverflowTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "map_overflow_total",
+		Help:      "Number of writes to a full eBPF map by the overflow action.",
+	}, []string{"map", "action"})
+
+	// PerfLostSamplesTotal is the number of samples dropped by the perf buffers
+	PerfLostSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "perf_lost_samples_total",
+		Help:      "Number of samples lost because the perf buffer was full.",
+	}, []string{"map"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EventsTotal,
+		ConnectionsTotal,
+		DestinationConnectionsTotal,
+		DNSQueriesTotal,
+		AnomaliesTotal,
+		MapEntries,
+		MapMaxEntries,
+		MapPressure,
+		ProgramRuns,
+		ProgramRuntimeSeconds,
+		MapOverflowTotal,
+		PerfLostSamplesTotal,
+		SlowOperationsTotal,
+	)
+}
+
+// Serve exposes the metrics on the given address (/metrics)
+// The server is shut down when the context is done.
+func Serve(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		logger.Log.Infof("serving metrics on %s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Errorf("failed to serve metrics: %v", err)
+		}
+	}()
+}
+
+// ObserveEvent updates the counters of the reported event
+func ObserveEvent(e domain.ReportEvent) {
+	EventsTotal.Inc()
+	ConnectionsTotal.WithLabelValues(e.Policy).Inc()
+	DestinationConnectionsTotal.WithLabelValues(
+		destinations.label(destinationOf(e)),
+		strconv.Itoa(int(e.DestinationPort)),
+		e.Protocol,
+		e.Policy,
+	).Inc()
+}
+
+// destinationOf returns the domain of the destination, the address if it's
+// not resolved. The addresses of a domain are counted as one destination.
+func destinationOf(e domain.ReportEvent) string {
+	if len(e.Domains) > 0 {
+		return e.Domains[0]
+	}
+
+	return e.DestinationAddress
+}
+
+// destinationSet is the set of the destinations with a series, the
+// destinations over the cap are counted as otherDestination
+type destinationSet struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newDestinationSet(size int) *destinationSet {
+	return &destinationSet{max: size, seen: make(map[string]struct{})}
+}
+
+// label returns the destination label of the destination
+func (s *destinationSet) label(destination string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[destination]; ok {
+		return destination
+	}
+	if len(s.seen) >= s.max {
+		return otherDestination
+	}
+	s.seen[destination] = struct{}{}
+
+	return destination
+}
+
+// ObserveLostSamples adds the lost samples of the perf buffer
+func ObserveLostSamples(mapName string, lost uint64) {
+	if lost == 0 {
+		return
+	}
+
+	PerfLostSamplesTotal.WithLabelValues(mapName).Add(float64(lost))
+}
+
+// ObserveMapOverflow increments the writes to the full map
+func ObserveMapOverflow(mapName, action string) {
+	MapOverflowTotal.WithLabelValues(mapName, action).Inc()
+}
+
+// ObserveSlowOperation increments the slow operations of the component
+func ObserveSlowOperation(component string) {
+	SlowOperationsTotal.WithLabelValues(component).Inc()
+}
+
+// ObserveDNSQuery increments the DNS queries of the type and the response code
+func ObserveDNSQuery(qtype, rcode string) {
+	DNSQueriesTotal.WithLabelValues(qtype, rcode).Inc()
+}
+
+// ObserveAnomaly counts a connection over an anomaly threshold
+func ObserveAnomaly(kind string) {
+	AnomaliesTotal.WithLabelValues(kind).Inc()
+}
+
+// ObserveMap sets the entry gauges of the map
+func ObserveMap(mapName string, entries int, maxEntries uint32) {
+	MapEntries.WithLabelValues(mapName).Set(float64(entries))
+	MapMaxEntries.WithLabelValues(mapName).Set(float64(maxEntries))
+	if maxEntries > 0 {
+		MapPressure.WithLabelValues(mapName).Set(float64(entries) / float64(maxEntries))
+	}
+}
+
+// ObserveProgram sets the run gauges of the program
+func ObserveProgram(program string, runs uint64, runtime time.Duration) {
+	ProgramRuns.WithLabelValues(program).Set(float64(runs))
+	ProgramRuntimeSeconds.WithLabelValues(program).Set(runtime.Seconds())
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestDestinationSet(t *testing.T) {
+	var set = newDestinationSet(2)
+
+	for destination, want := range map[string]string{
+		"api.github.com": "api.github.com",
+		"140.82.114.22":  "140.82.114.22",
+	} {
+		if got := set.label(destination); got != want {
+			t.Errorf("%s: expected %s, got %s", destination, want, got)
+		}
+	}
+
+	// over the cap, the seen destinations keep their series
+	if got := set.label("registry.npmjs.org"); got != otherDestination {
+		t.Errorf("expected the destination over the cap to be %s, got %s", otherDestination, got)
+	}
+	if got := set.label("api.github.com"); got != "api.github.com" {
+		t.Errorf("expected the seen destination to keep its label, got %s", got)
+	}
+}
+
+func TestDestinationOf(t *testing.T) {
+	var tests = []struct {
+		event    domain.ReportEvent
+		expected string
+	}{
+		{domain.ReportEvent{DestinationAddress: "140.82.114.22", Domains: []string{"api.github.com", "github.com"}}, "api.github.com"},
+		{domain.ReportEvent{DestinationAddress: "140.82.114.22"}, "140.82.114.22"},
+	}
+
+	for _, tt := range tests {
+		if got := destinationOf(tt.event); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
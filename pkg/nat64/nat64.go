@@ -0,0 +1,105 @@
+// Package nat64 handles the IPv4-embedded IPv6 addresses of the NAT64/DNS64
+// networks (RFC 6052). The connections of an IPv6-only host to an IPv4 server
+// go to an address of the NAT64 prefix, the last 32 bits of the address are
+// the IPv4 address of the server.
+package nat64
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+const (
+	// Auto detects the prefix of the network (RFC 7050), the well-known prefix if not found
+	Auto = "auto"
+	// Disabled disables the handling of the NAT64 addresses
+	Disabled = "none"
+
+	// prefixBits is the length of the supported prefixes, the IPv4 address is the last 32 bits
+	prefixBits = 96
+	// discoveryName is the IPv4-only name of the prefix discovery, its AAAA
+	// records are synthesized by the DNS64 resolvers
+	discoveryName = "ipv4only.arpa"
+)
+
+// WellKnownPrefix is the well-known NAT64 prefix (64:ff9b::/96)
+var WellKnownPrefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// discoveryAddrs are the IPv4 addresses of ipv4only.arpa
+var discoveryAddrs = []netip.Addr{netip.MustParseAddr("192.0.0.170"), netip.MustParseAddr("192.0.0.171")}
+
+// Parse parses the prefix of the --nat64-prefix flag, Auto detects the prefix
+// of the network. It returns the zero prefix for Disabled.
+func Parse(ctx context.Context, value string) (netip.Prefix, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", Auto:
+		if prefix, ok := Discover(ctx); ok {
+			return prefix, nil
+		}
+		return WellKnownPrefix, nil
+	case Disabled:
+		return netip.Prefix{}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil || !prefix.Addr().Is6() || prefix.Addr().Is4In6() || prefix.Bits() != prefixBits {
+		return netip.Prefix{}, fmt.Errorf("invalid nat64 prefix [%s]: an IPv6 /96 prefix is expected", value)
+	}
+
+	return prefix.Masked(), nil
+}
+
+// Discover detects the NAT64 prefix of the network by the AAAA records of
+// ipv4only.arpa synthesized by the DNS64 resolver (RFC 7050)
+func Discover(ctx context.Context) (netip.Prefix, bool) {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip6", discoveryName)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	for _, addr := range addrs {
+		if !addr.Is6() || addr.Is4In6() {
+			continue
+		}
+		prefix := netip.PrefixFrom(addr, prefixBits).Masked()
+		for _, known := range discoveryAddrs {
+			if embedded, ok := Extract(prefix, addr); ok && embedded == known {
+				return prefix, true
+			}
+		}
+	}
+
+	return netip.Prefix{}, false
+}
+
+// Extract returns the IPv4 address embedded in the address of the prefix
+func Extract(prefix netip.Prefix, addr netip.Addr) (netip.Addr, bool) {
+	if !prefix.IsValid() || !addr.Is6() || !prefix.Contains(addr) {
+		return netip.Addr{}, false
+	}
+
+	var b = addr.As16()
+
+	return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+}
+
+// Embed returns the address of the prefix embedding the IPv4 address
+func Embed(prefix netip.Prefix, ip4 netip.Addr) netip.Addr {
+	var b = prefix.Addr().As16()
+	var v4 = ip4.As4()
+	copy(b[12:], v4[:])
+
+	return netip.AddrFrom16(b)
+}
+
+// Bytes returns the first 96 bits of the prefix, the key of the kernel map
+func Bytes(prefix netip.Prefix) [12]byte {
+	var b = prefix.Addr().As16()
+	var head [12]byte
+	copy(head[:], b[:12])
+
+	return head
+}
@@ -0,0 +1,54 @@
+package nat64
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var testCases = map[string]struct {
+		expected netip.Prefix
+		valid    bool
+	}{
+		"64:ff9b::/96":       {WellKnownPrefix, true},
+		"2001:db8:64::/96":   {netip.MustParsePrefix("2001:db8:64::/96"), true},
+		"2001:db8:64::1/96":  {netip.MustParsePrefix("2001:db8:64::/96"), true},
+		"none":               {netip.Prefix{}, true},
+		"2001:db8:64::/64":   {},
+		"10.0.0.0/8":         {},
+		"::ffff:10.0.0.0/96": {},
+		"not a prefix":       {},
+	}
+
+	for value, tc := range testCases {
+		prefix, err := Parse(context.Background(), value)
+		if (err == nil) != tc.valid || prefix != tc.expected {
+			t.Errorf("[%s] expected %v (valid %v), got %v %v", value, tc.expected, tc.valid, prefix, err)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var prefix = netip.MustParsePrefix("2001:db8:64::/96")
+	var v4 = netip.MustParseAddr("140.82.114.22")
+
+	addr := Embed(prefix, v4)
+	if addr.String() != "2001:db8:64::8c52:7216" {
+		t.Errorf("unexpected embedded address: %s", addr)
+	}
+
+	if got, ok := Extract(prefix, addr); !ok || got != v4 {
+		t.Errorf("expected %s, got %s %v", v4, got, ok)
+	}
+	if got, ok := Extract(WellKnownPrefix, addr); ok {
+		t.Errorf("expected no address out of the prefix, got %s", got)
+	}
+	if got, ok := Extract(netip.Prefix{}, addr); ok {
+		t.Errorf("expected no address of the disabled prefix, got %s", got)
+	}
+
+	if b := Bytes(WellKnownPrefix); b != [12]byte{0x00, 0x64, 0xff, 0x9b} {
+		t.Errorf("unexpected prefix bytes: %x", b)
+	}
+}
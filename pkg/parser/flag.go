@@ -2,11 +2,16 @@ package parser
 
 import (
 	"bufio"
+	"fmt"
 	"net"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+	"github.com/kondukto-io/kntrl/pkg/utils"
 )
 
 const (
@@ -15,38 +20,201 @@ const (
 	azureMeta     = "168.63.129.16"
 )
 
-func ToDataJson(allowed_hosts, allowed_ips string, ghrange, localrange bool) *domain.Data {
-	hosts, ips := getDNSServers()
+// metadataEndpoints are the instance metadata services of the cloud providers,
+// the credentials of the instance are a common target. linkLocal and azureMeta
+// are allowed by default.
+var metadataEndpoints = []netip.Addr{
+	netip.MustParseAddr(linkLocal),         // AWS, GCP, Azure, OpenStack, DigitalOcean
+	netip.MustParseAddr(azureMeta),         // Azure WireServer
+	netip.MustParseAddr("169.254.170.2"),   // AWS ECS task metadata
+	netip.MustParseAddr("fd00:ec2::254"),   // AWS IPv6
+	netip.MustParseAddr("100.100.100.200"), // Alibaba Cloud
+	netip.MustParseAddr("192.0.0.192"),     // Oracle Cloud (legacy)
+}
+
+// IsMetadataEndpoint returns true if the address is an instance metadata service
+func IsMetadataEndpoint(address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, endpoint := range metadataEndpoints {
+		if addr == endpoint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ToDataJson returns the policy data of the allowed hosts and IPs. With defaults,
+// the nameservers and the loopback are allowed too, and the cloud metadata
+// endpoints with metadata.
+func ToDataJson(allowed_hosts, allowed_ips string, ghrange, localrange, defaults, metadata bool) *domain.Data {
+	var hosts []string
+	var ips []net.IP
+	if defaults {
+		hosts, ips = getDNSServers()
+	}
+	sources := allowSources(ips, domain.AllowSourceStatic, "nameserver")
+
 	hosts = append(hosts, parseAllowedHosts(allowed_hosts)...)
-	ips = append(ips, parseAllowedIPAddr(allowed_ips)...)
-	ips = append(ips, host2ip(hosts)...)
+
+	allowedIPs := parseIPAddr(allowed_ips)
+	ips = append(ips, allowedIPs...)
+	sources = append(sources, allowSources(allowedIPs, domain.AllowSourceStatic, "allowed-ips")...)
+	if defaults {
+		loopback := []net.IP{net.ParseIP(localLoopback).To4()}
+		ips = append(ips, loopback...)
+		sources = append(sources, allowSources(loopback, domain.AllowSourceStatic, "default")...)
+	}
+	if defaults && metadata {
+		endpoints := metadataIPs()
+		ips = append(ips, endpoints...)
+		sources = append(sources, allowSources(endpoints, domain.AllowSourceStatic, "allow-metadata-endpoints")...)
+	}
+
+	for _, host := range hosts {
+		resolved := host2ip([]string{host})
+		ips = append(ips, resolved...)
+		sources = append(sources, allowSources(resolved, domain.AllowSourceDNS, host)...)
+	}
 
 	return &domain.Data{
 		AllowedHosts:       hosts,
 		AllowedIPs:         ips,
 		AllowGithubMeta:    ghrange,
 		AllowLocalIPRanges: localrange,
+		AllowedSources:     sources,
 	}
 }
 
-func parseAllowedIPAddr(ips string) (iplist []net.IP) {
-	for _, ip := range strings.Split(ips, ",") {
-		if i := net.ParseIP(ip); i == nil {
+// allowSources returns the provenance of the allowed IPs
+func allowSources(ips []net.IP, source, rule string) []domain.AllowEntry {
+	var entries = make([]domain.AllowEntry, 0, len(ips))
+	for _, ip := range ips {
+		entries = append(entries, domain.AllowEntry{
+			Address: ip.String(),
+			Source:  source,
+			Rule:    rule,
+		})
+	}
+
+	return entries
+}
+
+// ToDenyList returns the denied hosts and IPs, the IPs of the hosts are resolved
+func ToDenyList(denied_hosts, denied_ips string) ([]string, []net.IP) {
+	hosts := parseAllowedHosts(denied_hosts)
+
+	var ips []net.IP
+	for _, ip := range strings.Split(denied_ips, ",") {
+		if i := net.ParseIP(strings.TrimSpace(ip)); i != nil {
+			ips = append(ips, normalizeIP(i))
+		}
+	}
+	ips = append(ips, host2ip(hosts)...)
+
+	return hosts, ips
+}
+
+// ParsePortRules parses the port rules in the form of port, address:port or host:port
+// e.g. 443, 1.2.3.4:443, api.example.com:8443 or [2001:db8::1]:443
+func ParsePortRules(rules []string) ([]domain.PortRule, error) {
+	var pr []domain.PortRule
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
 			continue
-		} else {
-			iplist = append(iplist, i.To4())
 		}
+
+		var address, port = "", rule
+		if strings.Contains(rule, ":") {
+			var err error
+			address, port, err = net.SplitHostPort(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port rule [%s]: %w", rule, err)
+			}
+		}
+
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || p == 0 {
+			return nil, fmt.Errorf("invalid port rule [%s], expected port, address:port or host:port", rule)
+		}
+
+		if ip := net.ParseIP(address); ip != nil {
+			address = normalizeIP(ip).String()
+		}
+
+		pr = append(pr, domain.PortRule{
+			Address: address,
+			Port:    uint16(p),
+		})
 	}
 
-	iplist = append(iplist,
-		net.ParseIP(localLoopback).To4(),
-		net.ParseIP(linkLocal).To4(),
-		net.ParseIP(azureMeta).To4(),
-	)
+	return pr, nil
+}
+
+// ParseProcessRules parses the process rules in the form of process:action:destination
+// e.g. curl:allow:*.github.com or /usr/bin/wget:deny:*
+func ParseProcessRules(rules []string) ([]domain.ProcessRule, error) {
+	var pr []domain.ProcessRule
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		// destination may contain a colon (IPv6), split the first two fields only
+		parts := strings.SplitN(rule, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid process rule [%s], expected process:action:destination", rule)
+		}
+
+		if parts[1] != domain.ProcessRuleActionAllow && parts[1] != domain.ProcessRuleActionDeny {
+			return nil, fmt.Errorf("invalid process rule action [%s], expected allow or deny", parts[1])
+		}
+
+		pr = append(pr, domain.ProcessRule{
+			Process:     parts[0],
+			Action:      parts[1],
+			Destination: normalizeDestination(parts[2]),
+		})
+	}
+
+	return pr, nil
+}
+
+func parseAllowedIPAddr(ips string) []net.IP {
+	return append(parseIPAddr(ips), defaultIPs()...)
+}
+
+// parseIPAddr parses the comma separated IP addresses
+func parseIPAddr(ips string) (iplist []net.IP) {
+	for _, ip := range strings.Split(ips, ",") {
+		if i := net.ParseIP(strings.TrimSpace(ip)); i != nil {
+			iplist = append(iplist, normalizeIP(i))
+		}
+	}
 
 	return iplist
 }
 
+// defaultIPs returns the addresses allowed by default (loopback and cloud metadata)
+func defaultIPs() []net.IP {
+	return append([]net.IP{net.ParseIP(localLoopback).To4()}, metadataIPs()...)
+}
+
+// metadataIPs returns the cloud metadata endpoints allowed by default
+func metadataIPs() []net.IP {
+	return []net.IP{
+		net.ParseIP(linkLocal).To4(),
+		net.ParseIP(azureMeta).To4(),
+	}
+}
+
 func parseAllowedHosts(hosts string) (hl []string) {
 	for _, host := range strings.Split(hosts, ",") {
 		if parts := strings.Split(host, "."); len(parts) > 1 {
@@ -57,17 +225,45 @@ func parseAllowedHosts(hosts string) (hl []string) {
 	return
 }
 
-// find a better solution
+// normalizeIP returns the 4-byte form of the IPv4 addresses, the addresses of
+// the well-known NAT64 prefix are translated to the embedded IPv4 address.
+// IPv6 addresses are kept for the policy, the kernel allow and deny lists are IPv4 only.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	if addr, ok := netip.AddrFromSlice(ip); ok {
+		if ip4, ok := nat64.Extract(nat64.WellKnownPrefix, addr); ok {
+			return net.IP(ip4.AsSlice())
+		}
+	}
+
+	return ip
+}
+
+// normalizeDestination returns the IPv4 address or network of an IPv4-mapped
+// IPv6 destination, the events are of the IPv4 address
+func normalizeDestination(destination string) string {
+	if addr, err := netip.ParseAddr(destination); err == nil && addr.Is4In6() {
+		return addr.Unmap().String()
+	}
+	if prefix, err := netip.ParsePrefix(destination); err == nil && prefix.Addr().Is4In6() {
+		return utils.UnmapPrefix(prefix).String()
+	}
+
+	return destination
+}
+
+// host2ip resolves the A and AAAA records of the hosts, the subdomain
+// patterns (.github.com, *.github.com) resolve the domain itself
 func host2ip(hosts []string) (ipl []net.IP) {
 	for _, h := range hosts {
-		ip, err := net.LookupIP(h)
+		ip, err := net.LookupIP(strings.TrimPrefix(strings.TrimPrefix(h, "*"), "."))
 		if err != nil {
 			continue
 		}
 		for _, v := range ip {
-			if ipv4 := v.To4(); ipv4 != nil {
-				ipl = append(ipl, ipv4)
-			}
+			ipl = append(ipl, normalizeIP(v))
 		}
 	}
 	return
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestParseAllowedIPAddr_DualStack(t *testing.T) {
+	ips := parseAllowedIPAddr("192.168.0.100, 2001:db8::1,invalid")
+
+	var want = []net.IP{
+		net.ParseIP("192.168.0.100").To4(),
+		net.ParseIP("2001:db8::1"),
+	}
+
+	for i, ip := range want {
+		if !ips[i].Equal(ip) || len(ips[i]) != len(ip) {
+			t.Errorf("expected %v (%d bytes), got %v (%d bytes)", ip, len(ip), ips[i], len(ips[i]))
+		}
+	}
+
+	// the default addresses (loopback, metadata) follow the given addresses
+	if len(ips) != len(want)+3 {
+		t.Errorf("expected %d addresses, got %d: %v", len(want)+3, len(ips), ips)
+	}
+}
+
+func TestToDenyList(t *testing.T) {
+	hosts, ips := ToDenyList("", "45.9.148.3,2001:db8::2")
+
+	if len(hosts) != 0 {
+		t.Errorf("expected no hosts, got %v", hosts)
+	}
+	if len(ips) != 2 || len(ips[0]) != net.IPv4len || len(ips[1]) != net.IPv6len {
+		t.Errorf("unexpected denied ips: %v", ips)
+	}
+}
+
+func TestToDenyList_NAT64(t *testing.T) {
+	// the address of the well-known NAT64 prefix is the embedded IPv4 address
+	_, ips := ToDenyList("", "64:ff9b::2d09:9403")
+
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("45.9.148.3")) || len(ips[0]) != net.IPv4len {
+		t.Errorf("expected the embedded IPv4 address, got %v", ips)
+	}
+}
+
+func TestToDenyList_IPv4Mapped(t *testing.T) {
+	_, ips := ToDenyList("", "::ffff:45.9.148.3")
+
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("45.9.148.3")) || len(ips[0]) != net.IPv4len {
+		t.Errorf("expected the IPv4 address, got %v", ips)
+	}
+}
+
+func TestParsePortRules(t *testing.T) {
+	rules, err := ParsePortRules([]string{"443", "1.2.3.4:8080", "api.example.com:8443", "[2001:db8::1]:22"})
+	if err != nil {
+		t.Fatalf("failed to parse port rules: %v", err)
+	}
+
+	var want = []domain.PortRule{
+		{Port: 443},
+		{Address: "1.2.3.4", Port: 8080},
+		{Address: "api.example.com", Port: 8443},
+		{Address: "2001:db8::1", Port: 22},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("expected %v, got %v", want, rules)
+	}
+
+	for _, rule := range []string{"0", "1.2.3.4:https", "1.2.3.4:70000", "1.2.3.4"} {
+		if _, err := ParsePortRules([]string{rule}); err == nil {
+			t.Errorf("expected an error for the port rule [%s]", rule)
+		}
+	}
+}
+
+func TestParseProcessRules_IPv4Mapped(t *testing.T) {
+	rules, err := ParseProcessRules([]string{"curl:deny:::ffff:10.0.0.5", "nc:deny:::ffff:10.0.0.0/104", "wget:allow:2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("failed to parse process rules: %v", err)
+	}
+
+	var want = []string{"10.0.0.5", "10.0.0.0/8", "2001:db8::/32"}
+	for i, rule := range rules {
+		if rule.Destination != want[i] {
+			t.Errorf("expected the destination %s, got %s", want[i], rule.Destination)
+		}
+	}
+}
+
+func TestIsMetadataEndpoint(t *testing.T) {
+	for _, addr := range []string{"169.254.169.254", "168.63.129.16", "fd00:ec2::254", "::ffff:169.254.169.254"} {
+		if !IsMetadataEndpoint(addr) {
+			t.Errorf("[%s] expected a metadata endpoint", addr)
+		}
+	}
+	for _, addr := range []string{"169.254.169.253", "1.1.1.1", "", "metadata.google.internal"} {
+		if IsMetadataEndpoint(addr) {
+			t.Errorf("[%s] expected no metadata endpoint", addr)
+		}
+	}
+}
+
+func TestToDataJson_AllowedSources(t *testing.T) {
+	data := ToDataJson("", "1.1.1.1", false, false, true, true)
+
+	var sources = make(map[string]domain.AllowEntry)
+	for _, s := range data.AllowedSources {
+		sources[s.Address] = s
+	}
+
+	if s := sources["1.1.1.1"]; s.Source != domain.AllowSourceStatic || s.Rule != "allowed-ips" {
+		t.Errorf("unexpected provenance of the allowed ip: %+v", s)
+	}
+	if s := sources[localLoopback]; s.Source != domain.AllowSourceStatic || s.Rule != "default" {
+		t.Errorf("unexpected provenance of the default ip: %+v", s)
+	}
+	if s := sources[linkLocal]; s.Source != domain.AllowSourceStatic || s.Rule != "allow-metadata-endpoints" {
+		t.Errorf("unexpected provenance of the metadata endpoint: %+v", s)
+	}
+
+	// the metadata endpoints are not allowed with --allow-metadata-endpoints=false
+	data = ToDataJson("", "1.1.1.1", false, false, true, false)
+	for _, ip := range data.AllowedIPs {
+		if IsMetadataEndpoint(ip.String()) {
+			t.Errorf("unexpected metadata endpoint %s", ip)
+		}
+	}
+
+	// the built-in defaults are not allowed with --no-default-allow
+	data = ToDataJson("", "1.1.1.1", false, false, false, true)
+	if len(data.AllowedIPs) != 1 || !data.AllowedIPs[0].Equal(net.ParseIP("1.1.1.1")) || len(data.AllowedSources) != 1 {
+		t.Errorf("expected the allowed ip only, got %v %+v", data.AllowedIPs, data.AllowedSources)
+	}
+}
@@ -0,0 +1,74 @@
+// Package pcap writes the packets in the pcap file format of libpcap, read by
+// tcpdump and Wireshark. The packets are raw IP packets (LINKTYPE_RAW), the
+// captures of the cgroup programs have no link layer header.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	// magic is the magic number of the files with the timestamps in microseconds
+	magic = 0xa1b2c3d4
+	// versionMajor and versionMinor are the version of the file format
+	versionMajor = 2
+	versionMinor = 4
+
+	// LinkTypeRaw is the link type of the raw IPv4 and IPv6 packets
+	LinkTypeRaw = 101
+
+	// HeaderSize is the size of the file header
+	HeaderSize = 24
+	// recordHeaderSize is the size of the header of a packet
+	recordHeaderSize = 16
+)
+
+// Writer writes the packets of a pcap file, the file header is written by
+// WriteHeader once per file
+type Writer struct {
+	w       io.Writer
+	snaplen uint32
+}
+
+// NewWriter returns a writer of the packets up to snaplen bytes
+func NewWriter(w io.Writer, snaplen uint32) *Writer {
+	return &Writer{w: w, snaplen: snaplen}
+}
+
+// WriteHeader writes the file header
+func (w *Writer) WriteHeader() error {
+	var header = make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint32(header[0:], magic)
+	binary.LittleEndian.PutUint16(header[4:], versionMajor)
+	binary.LittleEndian.PutUint16(header[6:], versionMinor)
+	// the timezone offset and the timestamp accuracy are always 0
+	binary.LittleEndian.PutUint32(header[16:], w.snaplen)
+	binary.LittleEndian.PutUint32(header[20:], LinkTypeRaw)
+
+	_, err := w.w.Write(header)
+	return err
+}
+
+// WritePacket writes the captured bytes of a packet of the length, the
+// captured bytes over the snaplen are truncated
+func (w *Writer) WritePacket(ts time.Time, data []byte, length int) error {
+	if len(data) > int(w.snaplen) {
+		data = data[:w.snaplen]
+	}
+	if length < len(data) {
+		return errors.New("packet length is less than the captured bytes")
+	}
+
+	var record = make([]byte, recordHeaderSize, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(record[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(ts.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(length))
+	record = append(record, data...)
+
+	_, err := w.w.Write(record)
+	return err
+}
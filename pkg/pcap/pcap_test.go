@@ -0,0 +1,63 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestWriteHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, 2048).WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	var b = buf.Bytes()
+	if len(b) != HeaderSize {
+		t.Fatalf("expected %d bytes, got %d", HeaderSize, len(b))
+	}
+	if binary.LittleEndian.Uint32(b) != magic {
+		t.Errorf("unexpected magic: %x", b[:4])
+	}
+	if v := binary.LittleEndian.Uint16(b[4:]); v != versionMajor {
+		t.Errorf("unexpected major version: %d", v)
+	}
+	if v := binary.LittleEndian.Uint32(b[16:]); v != 2048 {
+		t.Errorf("unexpected snaplen: %d", v)
+	}
+	if v := binary.LittleEndian.Uint32(b[20:]); v != LinkTypeRaw {
+		t.Errorf("unexpected link type: %d", v)
+	}
+}
+
+func TestWritePacket(t *testing.T) {
+	var buf bytes.Buffer
+	var w = NewWriter(&buf, 4)
+	var ts = time.Unix(1700000000, 123456789)
+
+	if err := w.WritePacket(ts, []byte{0x45, 0, 0, 60, 1, 2}, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	var b = buf.Bytes()
+	if len(b) != recordHeaderSize+4 {
+		t.Fatalf("expected a truncated packet of %d bytes, got %d", recordHeaderSize+4, len(b))
+	}
+	if v := binary.LittleEndian.Uint32(b); v != 1700000000 {
+		t.Errorf("unexpected seconds: %d", v)
+	}
+	if v := binary.LittleEndian.Uint32(b[4:]); v != 123456 {
+		t.Errorf("unexpected microseconds: %d", v)
+	}
+	if caplen, length := binary.LittleEndian.Uint32(b[8:]), binary.LittleEndian.Uint32(b[12:]); caplen != 4 || length != 60 {
+		t.Errorf("unexpected lengths: %d/%d", caplen, length)
+	}
+	if !bytes.Equal(b[recordHeaderSize:], []byte{0x45, 0, 0, 60}) {
+		t.Errorf("unexpected packet: %x", b[recordHeaderSize:])
+	}
+
+	if err := w.WritePacket(ts, []byte{1, 2, 3}, 2); err == nil {
+		t.Error("expected an error for a length less than the captured bytes")
+	}
+}
@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// LoadFile reads the policy file (YAML), the unknown fields are rejected.
+// An empty file is an empty policy.
+func LoadFile(path string) (*domain.PolicyFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	defer f.Close()
+
+	var pf domain.PolicyFile
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&pf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file [%s]: %w", path, err)
+	}
+
+	return &pf, nil
+}
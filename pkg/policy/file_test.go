@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	var dir = t.TempDir()
+
+	var path = filepath.Join(dir, "policy.yaml")
+	if err := os.WriteFile(path, []byte("allowed-hosts:\n  - .github.com\nallowed-ips: [1.1.1.1]\ndenied-ips: [6.6.6.6]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pf, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pf.AllowedHosts) != 1 || pf.AllowedIPs[0] != "1.1.1.1" || pf.DeniedIPs[0] != "6.6.6.6" {
+		t.Errorf("unexpected policy: %+v", pf)
+	}
+
+	var empty = filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(empty); err != nil {
+		t.Errorf("expected an empty policy, got %v", err)
+	}
+
+	var unknown = filepath.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(unknown, []byte("allow-hosts: [example.com]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(unknown); err == nil {
+		t.Error("expected an error for the unknown field")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	files "io/fs"
+	"sort"
 	"strings"
 
 	"github.com/kondukto-io/kntrl/internal/core/domain"
@@ -75,37 +76,90 @@ func (p *Policy) AddQuery(query string) {
 // the input is the value that has been generated by eBPF sensors
 // func (p *Policy) Eval(ctx context.Context, input []byte) (bool, error) {
 func (p *Policy) Eval(ctx context.Context, input map[string]interface{}) (bool, error) {
+	value, err := p.eval(ctx, input)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected result type of rego query: %T", value)
+	}
+
+	return result, nil
+}
+
+func (p *Policy) EvalEvent(ctx context.Context, event domain.ReportEvent) (bool, error) {
+	input, err := eventInput(event)
+	if err != nil {
+		return false, err
+	}
+
+	return p.Eval(ctx, input)
+}
+
+// EvalSet evaluates a query of a set of strings, e.g. the matching rules
+// of data.kntrl.hits. The result is sorted.
+func (p *Policy) EvalSet(ctx context.Context, input map[string]interface{}) ([]string, error) {
+	value, err := p.eval(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type of rego query: %T", value)
+	}
+
+	var result = make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// EvalEventSet evaluates the set query with the event as the input
+func (p *Policy) EvalEventSet(ctx context.Context, event domain.ReportEvent) ([]string, error) {
+	input, err := eventInput(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.EvalSet(ctx, input)
+}
+
+func (p *Policy) eval(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	query, err := rego.New(p.regoArgs...).PrepareForEval(ctx)
 	if err != nil {
-		return false, fmt.Errorf("failed to prepare rego query: %w", err)
+		return nil, fmt.Errorf("failed to prepare rego query: %w", err)
 	}
 
 	result, err := query.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
-		return false, fmt.Errorf("failed to eval rego query: %w", err)
+		return nil, fmt.Errorf("failed to eval rego query: %w", err)
 	}
 
 	if len(result) == 0 ||
 		len(result[0].Expressions) == 0 ||
 		result[0].Expressions[0].Value == nil {
-		return false, fmt.Errorf("failed to get result from rego query")
+		return nil, fmt.Errorf("failed to get result from rego query")
 	}
 
-	return result[0].Expressions[0].Value.(bool), nil
+	return result[0].Expressions[0].Value, nil
 }
 
-func (p *Policy) EvalEvent(ctx context.Context, event domain.ReportEvent) (bool, error) {
+// eventInput returns the event as the input document of the policy
+func eventInput(event domain.ReportEvent) (map[string]interface{}, error) {
 	data, err := json.Marshal(event)
 	if err != nil {
-		return false, err
-	}
-
-	outmap, err := unmarshal(data)
-	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return p.Eval(ctx, outmap)
+	return unmarshal(data)
 }
 
 func unmarshal(data []byte) (dataJson map[string]interface{}, err error) {
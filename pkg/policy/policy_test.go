@@ -2,6 +2,7 @@ package policy
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/kondukto-io/kntrl/bundle"
@@ -38,6 +39,41 @@ var testCases = map[string]struct {
 		[]byte(`{"pid":1636,"task_name":".NET ThreadPool","proto":"tcp","daddr":"20.102.39.57","dport":443,"domains":["."]}`),
 		true,
 	},
+	"allow_process": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "process_rules": [{"process": "curl", "action": "allow", "destination": "*.github.com"}]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "140.82.114.22","dport": 443,"domains": ["lb-140-82-114-22-iad.github.com"]}`),
+		true,
+	},
+	"restrict_process": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "process_rules": [{"process": "curl", "action": "allow", "destination": "*.github.com"}]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "1.1.1.1","dport": 443,"domains": ["one.one.one.one"]}`),
+		false,
+	},
+	"deny_process": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "process_rules": [{"process": "/usr/bin/wget", "action": "deny", "destination": "*"}]}`),
+		[]byte(`{"pid": 2806,"task_name": "wget","exe": "/usr/bin/wget","proto": "tcp","daddr": "1.1.1.1","dport": 443,"domains": ["."]}`),
+		false,
+	},
+	"allow_ip_port": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "allowed_ports": [{"address": "1.2.3.4", "port": 443}]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "1.2.3.4","dport": 443,"domains": ["."]}`),
+		true,
+	},
+	"restrict_ip_port": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "allowed_ports": [{"address": "1.2.3.4", "port": 443}]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "1.2.3.4","dport": 8080,"domains": ["."]}`),
+		false,
+	},
+	"deny_ip_addr": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "denied_ip_addr": ["1.1.1.1"]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "1.1.1.1","dport": 443,"domains": ["one.one.one.one"]}`),
+		false,
+	},
+	"deny_host": {
+		[]byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_github_meta": false, "allow_local_ip_ranges": false, "denied_hosts": ["paste.foo.com"]}`),
+		[]byte(`{"pid": 2806,"task_name": "curl","proto": "tcp","daddr": "104.20.67.143","dport": 443,"domains": ["paste.foo.com"]}`),
+		false,
+	},
 }
 
 func TestPolicyRaw(t *testing.T) {
@@ -65,3 +101,86 @@ func TestPolicyRaw(t *testing.T) {
 		}
 	}
 }
+
+func TestPolicyDenied(t *testing.T) {
+	var data = []byte(`{"allowed_hosts":["foo.com"], "allowed_ip_addr":["1.1.1.1"], "denied_hosts": ["pastebin.com"], "denied_ip_addr": ["45.9.148.3"]}`)
+
+	var tests = map[string]struct {
+		input    []byte
+		expected bool
+	}{
+		"denied_host": {[]byte(`{"daddr": "104.20.67.143", "domains": ["pastebin.com"]}`), true},
+		"denied_ip":   {[]byte(`{"daddr": "45.9.148.3", "domains": ["."]}`), true},
+		"not_denied":  {[]byte(`{"daddr": "1.1.1.1", "domains": ["one.one.one.one"]}`), false},
+	}
+
+	for name, test := range tests {
+		p, err := New(bundle.Bundle, data)
+		if err != nil {
+			t.Fatalf("[%s] policy init error: %v", name, err)
+		}
+		p.AddQuery("data.kntrl.denied")
+
+		var inputjson map[string]interface{}
+		if err := util.Unmarshal(test.input, &inputjson); err != nil {
+			t.Fatalf("[%s] unmarshal error: %v", name, err)
+		}
+
+		result, err := p.Eval(context.Background(), inputjson)
+		if err != nil {
+			t.Errorf("[%s] eval error: %v", name, err)
+		}
+
+		if result != test.expected {
+			t.Errorf("[%s] expected denied '%v', got %v", name, test.expected, result)
+		}
+	}
+}
+
+func TestPolicyHits(t *testing.T) {
+	var data = []byte(`{"allowed_hosts":[".github.com", "foo.com"], "allowed_ip_addr":["1.1.1.1"], "allow_local_ip_ranges": true, "allowed_ports": [{"address": "", "port": 22}, {"address": "github.com", "port": 443}], "denied_hosts": ["pastebin.com"], "process_rules": [{"process": "curl", "action": "allow", "destination": "*.github.com"}]}`)
+
+	var tests = map[string]struct {
+		input    []byte
+		expected []string
+	}{
+		"host_port_process": {
+			[]byte(`{"task_name": "curl", "daddr": "140.82.114.22", "dport": 443, "domains": ["api.github.com"]}`),
+			[]string{"allowed-hosts=.github.com", "allowed-ports=github.com:443", "process-policy=curl:allow:*.github.com"},
+		},
+		"ip_any_port": {
+			[]byte(`{"task_name": "ssh", "daddr": "1.1.1.1", "dport": 22, "domains": ["."]}`),
+			[]string{"allowed-ips=1.1.1.1", "allowed-ports=22"},
+		},
+		"local_denied": {
+			[]byte(`{"task_name": "wget", "daddr": "10.0.0.1", "dport": 80, "domains": ["pastebin.com"]}`),
+			[]string{"allow-local-ranges", "denied-hosts=pastebin.com"},
+		},
+		"no_hits": {
+			[]byte(`{"task_name": "wget", "daddr": "6.6.6.6", "dport": 80, "domains": ["."]}`),
+			[]string{},
+		},
+	}
+
+	for name, test := range tests {
+		p, err := New(bundle.Bundle, data)
+		if err != nil {
+			t.Fatalf("[%s] policy init error: %v", name, err)
+		}
+		p.AddQuery("data.kntrl.hits")
+
+		var inputjson map[string]interface{}
+		if err := util.Unmarshal(test.input, &inputjson); err != nil {
+			t.Fatalf("[%s] unmarshal error: %v", name, err)
+		}
+
+		hits, err := p.EvalSet(context.Background(), inputjson)
+		if err != nil {
+			t.Fatalf("[%s] eval error: %v", name, err)
+		}
+
+		if !slices.Equal(hits, test.expected) {
+			t.Errorf("[%s] expected hits %v, got %v", name, test.expected, hits)
+		}
+	}
+}
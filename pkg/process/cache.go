@@ -0,0 +1,115 @@
+package process
+
+import (
+	"sync"
+)
+
+// Info holds the details of an executed process
+type Info struct {
+	Pid  uint32
+	PPid uint32
+	Comm string
+	Path string
+	Args []string
+	// TraceParent is the W3C trace context of the process environment read on
+	// the exec, empty if not read
+	TraceParent string
+}
+
+// Cache is a fixed size ring of recently executed processes.
+// Processes that exec, connect and exit within milliseconds are gone
+// before /proc can be read, the ring keeps them around for enrichment.
+type Cache struct {
+	mu    sync.RWMutex
+	ring  []Info
+	next  int
+	index map[uint32]int
+}
+
+// NewCache returns a new cache that holds the last "size" exec events
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Cache{
+		ring:  make([]Info, size),
+		index: make(map[uint32]int, size),
+	}
+}
+
+// Add stores the process info, overwriting the oldest entry when the ring is full
+func (c *Cache) Add(info Info) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the slot is reused, drop the index of the evicted process
+	// unless the pid has been seen again in a newer slot
+	old := c.ring[c.next]
+	if i, ok := c.index[old.Pid]; ok && i == c.next {
+		delete(c.index, old.Pid)
+	}
+
+	c.ring[c.next] = info
+	c.index[info.Pid] = c.next
+	c.next = (c.next + 1) % len(c.ring)
+}
+
+// Get returns the latest exec info of the given pid
+func (c *Cache) Get(pid uint32) (Info, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.index[pid]
+	if !ok {
+		return Info{}, false
+	}
+
+	return c.ring[i], true
+}
+
+// Lookup returns the process info from the cache and falls back to /proc
+// for the processes that were started before the tracer. The info read from
+// /proc is cached, the parents of the connecting processes are looked up for
+// each connection.
+func (c *Cache) Lookup(pid uint32) (Info, bool) {
+	if info, ok := c.Get(pid); ok {
+		return info, true
+	}
+
+	info, err := FromProc(pid)
+	if err != nil {
+		return Info{}, false
+	}
+	c.Add(info)
+
+	return info, true
+}
+
+// Ancestors returns the parent chain of the process from its parent ppid (0
+// if unknown) up to the process below init, at most maxAncestorDepth
+// processes. The chain ends at the first parent gone from the cache and /proc.
+func (c *Cache) Ancestors(pid, ppid uint32) []Info {
+	if ppid == 0 {
+		var err error
+		if ppid, err = parentOf(pid, c); err != nil {
+			return nil
+		}
+	}
+
+	var ancestors []Info
+	for p := ppid; p > 1 && len(ancestors) < maxAncestorDepth; {
+		info, ok := c.Lookup(p)
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, info)
+
+		if info.PPid == 0 {
+			break
+		}
+		p = info.PPid
+	}
+
+	return ancestors
+}
@@ -0,0 +1,75 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+
+	cache.Add(Info{Pid: 1, Path: "/usr/bin/curl"})
+	cache.Add(Info{Pid: 2, Path: "/usr/bin/wget"})
+
+	if info, ok := cache.Get(1); !ok || info.Path != "/usr/bin/curl" {
+		t.Errorf("expected pid 1 to be cached, got %v", info)
+	}
+
+	// evicts pid 1
+	cache.Add(Info{Pid: 3, Path: "/usr/bin/nc"})
+	if _, ok := cache.Get(1); ok {
+		t.Errorf("expected pid 1 to be evicted")
+	}
+
+	// pid 2 exec'ed again, the eviction of the old slot must keep the new one
+	cache.Add(Info{Pid: 2, Path: "/usr/bin/node"})
+	cache.Add(Info{Pid: 4, Path: "/usr/bin/git"})
+	if info, ok := cache.Get(2); !ok || info.Path != "/usr/bin/node" {
+		t.Errorf("expected pid 2 to be /usr/bin/node, got %v", info)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	var testCases = map[string][]string{
+		"curl\x00-s\x00https://kondukto.io\x00": {"curl", "-s", "https://kondukto.io"},
+		"node\x00\x00":                          {"node"},
+		"":                                      nil,
+	}
+
+	for input, expected := range testCases {
+		if got := SplitArgs([]byte(input)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("[%q] expected %v, got %v", input, expected, got)
+		}
+	}
+}
+
+func TestCache_Ancestors(t *testing.T) {
+	// the pids are above pid_max, they are not read from /proc
+	cache := NewCache(8)
+	cache.Add(Info{Pid: 5000001, PPid: 1, Comm: "Runner.Worker"})
+	cache.Add(Info{Pid: 5000002, PPid: 5000001, Comm: "bash"})
+	cache.Add(Info{Pid: 5000003, PPid: 5000002, Comm: "npm"})
+	cache.Add(Info{Pid: 5000004, PPid: 5000003, Comm: "node"})
+
+	var comms = func(ancestors []Info) []string {
+		var names []string
+		for _, a := range ancestors {
+			names = append(names, a.Comm)
+		}
+		return names
+	}
+
+	if got, want := comms(cache.Ancestors(5000004, 5000003)), []string{"npm", "bash", "Runner.Worker"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// the parent of the event is unknown, it's the parent of the cached process
+	if got, want := comms(cache.Ancestors(5000004, 0)), []string{"npm", "bash", "Runner.Worker"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// the chain ends at the first parent gone
+	if got := cache.Ancestors(5000009, 5000008); len(got) != 0 {
+		t.Errorf("expected no ancestors, got %v", got)
+	}
+}
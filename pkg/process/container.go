@@ -0,0 +1,56 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// containerScope matches the cgroup directory name of a container:
+// docker-<id>.scope, cri-containerd-<id>.scope, crio-<id>.scope, libpod-<id>.scope
+// (systemd cgroup driver) or <id> (cgroupfs driver, /docker/<id>, /default/<id>)
+var containerScope = regexp.MustCompile(`^(?:(?:docker|cri-containerd|crio|libpod)-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// ContainerCgroup returns the cgroup v2 path of the container (relative to the root
+// cgroup), the id may be a prefix of the full id (docker ps prints 12 characters)
+func ContainerCgroup(root, id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return "", errors.New("container id is required")
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the cgroups of the exited processes are removed while walking
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		m := containerScope.FindStringSubmatch(d.Name())
+		if m == nil || !strings.HasPrefix(m[1], id) {
+			return nil
+		}
+
+		matches = append(matches, "/"+strings.TrimPrefix(path, filepath.Clean(root)+"/"))
+		// the cgroups of the container (init.scope, nested containers) are not matched
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no cgroup found for container [%s]", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("container id [%s] is ambiguous: %v", id, matches)
+	}
+}
@@ -0,0 +1,47 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainerCgroup(t *testing.T) {
+	var root = t.TempDir()
+	var dockerID = strings.Repeat("ab", 32)
+	var containerdID = strings.Repeat("cd", 32)
+
+	for _, dir := range []string{
+		"system.slice/docker-" + dockerID + ".scope/init.scope",
+		"kubepods.slice/kubepods-pod1.slice/cri-containerd-" + containerdID + ".scope",
+		"system.slice/sshd.service",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		id       string
+		expected string
+	}{
+		{dockerID[:12], "/system.slice/docker-" + dockerID + ".scope"},
+		{containerdID, "/kubepods.slice/kubepods-pod1.slice/cri-containerd-" + containerdID + ".scope"},
+	}
+
+	for _, tt := range tests {
+		path, err := ContainerCgroup(root, tt.id)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.id, err)
+			continue
+		}
+		if path != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.id, tt.expected, path)
+		}
+	}
+
+	if _, err := ContainerCgroup(root, "ef12"); err == nil {
+		t.Error("expected an error for an unknown container")
+	}
+}
@@ -0,0 +1,74 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const procDir = "/proc"
+
+// MaxArgsLen is the size of the arguments of the exec events (MAX_ARGS_LEN of
+// the probe), the arguments read from /proc are truncated to it too
+const MaxArgsLen = 128
+
+// FromProc reads the process info from /proc/<pid>
+func FromProc(pid uint32) (Info, error) {
+	var base = fmt.Sprintf("%s/%d", procDir, pid)
+
+	path, err := ExePath(pid)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read exe link: %w", err)
+	}
+
+	var info = Info{
+		Pid:  pid,
+		Path: path,
+	}
+
+	if ppid, err := parentOf(pid, nil); err == nil {
+		info.PPid = ppid
+	}
+
+	if comm, err := os.ReadFile(base + "/comm"); err == nil {
+		info.Comm = strings.TrimSpace(string(comm))
+	}
+
+	if cmdline, err := os.ReadFile(base + "/cmdline"); err == nil {
+		if len(cmdline) > MaxArgsLen {
+			cmdline = cmdline[:MaxArgsLen]
+		}
+		info.Args = SplitArgs(cmdline)
+	}
+
+	return info, nil
+}
+
+// ExePath returns the executable of the process, the path of the exec event
+// is the path given to execve and may be relative (./node, bin/npm)
+func ExePath(pid uint32) (string, error) {
+	return os.Readlink(fmt.Sprintf("%s/%d/exe", procDir, pid))
+}
+
+// SplitArgs splits NULL separated arguments (as in /proc/<pid>/cmdline)
+func SplitArgs(b []byte) []string {
+	var args []string
+	for _, arg := range bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0}) {
+		if len(arg) == 0 {
+			continue
+		}
+		args = append(args, string(arg))
+	}
+
+	return args
+}
+
+// CString returns the string until the first NULL byte
+func CString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+
+	return string(b)
+}
@@ -0,0 +1,153 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxAncestorDepth limits the parent chain walk
+const maxAncestorDepth = 8
+
+// container runtimes that run kntrl in a dedicated cgroup
+var containerCgroupMarkers = []string{"docker", "containerd", "kubepods", "libpod", "crio"}
+
+// Self identifies the processes of kntrl itself. Sinks (webhooks, uploads,
+// DNS lookups) generate egress that must not pollute the report.
+type Self struct {
+	pid uint32
+	// cgroup is set only if kntrl runs in a dedicated (container) cgroup,
+	// otherwise the cgroup is shared with the rest of the job
+	cgroup string
+	// exempt is the cgroup of the processes kntrl starts but doesn't own (the
+	// wrapped command of kntrl run), they are not kntrl as its children
+	exempt string
+}
+
+// NewSelf returns the identity of the running kntrl process
+func NewSelf() *Self {
+	var self = &Self{pid: uint32(os.Getpid())}
+
+	if cgroup, err := CgroupOf(self.pid); err == nil && isContainerCgroup(cgroup) {
+		self.cgroup = cgroup
+	}
+
+	return self
+}
+
+// Pid returns the pid of the kntrl process
+func (s *Self) Pid() uint32 {
+	return s.pid
+}
+
+// Cgroup returns the dedicated cgroup of kntrl, empty if the cgroup is shared
+func (s *Self) Cgroup() string {
+	return s.cgroup
+}
+
+// Exempt sets the cgroup of the processes kntrl starts but doesn't own, its
+// processes and their children are not kntrl. It's ignored if kntrl itself
+// runs in the cgroup.
+func (s *Self) Exempt(cgroup string) {
+	if cgroup == "" || cgroup == "/" {
+		return
+	}
+
+	if own, err := CgroupOf(s.pid); err == nil && inCgroup(own, cgroup) {
+		return
+	}
+	s.exempt = cgroup
+}
+
+// Is returns true if the process is kntrl, a child of kntrl or
+// runs in the dedicated cgroup of kntrl
+func (s *Self) Is(pid uint32, cache *Cache) bool {
+	return s.is(pid, cache, CgroupOf)
+}
+
+func (s *Self) is(pid uint32, cache *Cache, cgroupOf func(uint32) (string, error)) bool {
+	if s.exempt != "" {
+		if cgroup, err := cgroupOf(pid); err == nil && inCgroup(cgroup, s.exempt) {
+			return false
+		}
+	}
+
+	for i, p := 0, pid; i < maxAncestorDepth && p > 1; i++ {
+		if p == s.pid {
+			return true
+		}
+
+		ppid, err := parentOf(p, cache)
+		if err != nil {
+			break
+		}
+		p = ppid
+	}
+
+	if s.cgroup == "" {
+		return false
+	}
+
+	cgroup, err := cgroupOf(pid)
+	return err == nil && cgroup == s.cgroup
+}
+
+// inCgroup returns true if the cgroup is the parent cgroup or one of its children
+func inCgroup(cgroup, parent string) bool {
+	return cgroup == parent || strings.HasPrefix(cgroup, parent+"/")
+}
+
+func parentOf(pid uint32, cache *Cache) (uint32, error) {
+	if cache != nil {
+		if info, ok := cache.Get(pid); ok && info.PPid != 0 {
+			return info.PPid, nil
+		}
+	}
+
+	file, err := os.Open(fmt.Sprintf("%s/%d/status", procDir, pid))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "PPid:"); ok {
+			ppid, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+			return uint32(ppid), err
+		}
+	}
+
+	return 0, fmt.Errorf("ppid not found: %d", pid)
+}
+
+// CgroupOf returns the cgroup v2 path of the process (/proc/<pid>/cgroup)
+func CgroupOf(pid uint32) (string, error) {
+	file, err := os.Open(fmt.Sprintf("%s/%d/cgroup", procDir, pid))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// cgroup v2 unified hierarchy: 0::/system.slice/docker-<id>.scope
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("cgroup v2 path not found: %d", pid)
+}
+
+func isContainerCgroup(cgroup string) bool {
+	for _, marker := range containerCgroupMarkers {
+		if strings.Contains(cgroup, marker) {
+			return true
+		}
+	}
+
+	return false
+}
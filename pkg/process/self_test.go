@@ -0,0 +1,61 @@
+package process
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelf_Is(t *testing.T) {
+	// 100 is kntrl, 200 the command it runs in the command cgroup, 300 a
+	// child of the command and 400 a sink child of kntrl
+	var cache = NewCache(8)
+	cache.Add(Info{Pid: 200, PPid: 100})
+	cache.Add(Info{Pid: 300, PPid: 200})
+	cache.Add(Info{Pid: 400, PPid: 100})
+
+	var cgroups = map[uint32]string{
+		100: "/",
+		200: "/kntrl/cmd-1",
+		300: "/kntrl/cmd-1/nested",
+		400: "/",
+	}
+	cgroupOf := func(pid uint32) (string, error) {
+		if cgroup, ok := cgroups[pid]; ok {
+			return cgroup, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	var self = &Self{pid: 100}
+	for _, pid := range []uint32{100, 200, 300, 400} {
+		if !self.is(pid, cache, cgroupOf) {
+			t.Errorf("pid %d: expected to be self without an exempt cgroup", pid)
+		}
+	}
+
+	self.exempt = "/kntrl/cmd-1"
+	var expected = map[uint32]bool{100: true, 200: false, 300: false, 400: true}
+	for pid, want := range expected {
+		if got := self.is(pid, cache, cgroupOf); got != want {
+			t.Errorf("pid %d: expected self %t, got %t", pid, want, got)
+		}
+	}
+}
+
+func TestInCgroup(t *testing.T) {
+	var tests = []struct {
+		cgroup, parent string
+		expected       bool
+	}{
+		{"/kntrl/cmd-1", "/kntrl/cmd-1", true},
+		{"/kntrl/cmd-1/nested", "/kntrl/cmd-1", true},
+		{"/kntrl/cmd-10", "/kntrl/cmd-1", false},
+		{"/", "/kntrl/cmd-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := inCgroup(tt.cgroup, tt.parent); got != tt.expected {
+			t.Errorf("%s in %s: expected %t, got %t", tt.cgroup, tt.parent, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TraceParentEnv is the environment variable of the W3C trace context,
+// set by the CI systems and the OpenTelemetry instrumented tools for the child processes
+const TraceParentEnv = "TRACEPARENT"
+
+// TraceContext is the trace and the span id of the W3C trace context of a process
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// ParseTraceParent parses the traceparent header value
+// (00-<32 hex trace id>-<16 hex span id>-<2 hex flags>)
+func ParseTraceParent(value string) (TraceContext, bool) {
+	var parts = strings.Split(strings.TrimSpace(value), "-")
+	// the future versions may append fields
+	if len(parts) < 4 {
+		return TraceContext{}, false
+	}
+
+	var version, traceID, spanID, flags = parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return TraceContext{}, false
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceContext{}, false
+	}
+	if !isHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+	if !isHex(flags, 2) {
+		return TraceContext{}, false
+	}
+
+	return TraceContext{TraceID: traceID, SpanID: spanID}, true
+}
+
+// TraceParentOf reads the traceparent of the process environment (/proc/<pid>/environ)
+func TraceParentOf(pid uint32) (string, error) {
+	environ, err := os.ReadFile(fmt.Sprintf("%s/%d/environ", procDir, pid))
+	if err != nil {
+		return "", err
+	}
+
+	var prefix = []byte(TraceParentEnv + "=")
+	for _, env := range bytes.Split(environ, []byte{0}) {
+		if value, ok := bytes.CutPrefix(env, prefix); ok {
+			return string(value), nil
+		}
+	}
+
+	return "", nil
+}
+
+// TraceContextOf returns the trace context of the process. The trace context
+// of an exited process is the one read on its exec, else the one of the
+// closest ancestor as the environment is inherited.
+func TraceContextOf(pid uint32, cache *Cache) (TraceContext, bool) {
+	for i, p := 0, pid; i < maxAncestorDepth && p > 1; i++ {
+		if info, ok := cache.Get(p); ok && info.TraceParent != "" {
+			return ParseTraceParent(info.TraceParent)
+		}
+
+		traceParent, err := TraceParentOf(p)
+		if err == nil {
+			// the environment is readable, the process doesn't propagate a trace context
+			return ParseTraceParent(traceParent)
+		}
+
+		ppid, err := parentOf(p, cache)
+		if err != nil {
+			break
+		}
+		p = ppid
+	}
+
+	return TraceContext{}, false
+}
+
+// isHex returns true if s is n lowercase hex digits
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
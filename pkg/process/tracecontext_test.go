@@ -0,0 +1,52 @@
+package process
+
+import (
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	var testCases = map[string]struct {
+		expected TraceContext
+		ok       bool
+	}{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": {
+			TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}, true,
+		},
+		// a future version may append fields
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra": {
+			TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}, true,
+		},
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra": {},
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       {},
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01":       {},
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01":       {},
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01":       {},
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":          {},
+		"": {},
+	}
+
+	for input, tc := range testCases {
+		got, ok := ParseTraceParent(input)
+		if ok != tc.ok || got != tc.expected {
+			t.Errorf("[%q] expected %v %v, got %v %v", input, tc.expected, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestTraceContextOf(t *testing.T) {
+	// the pids are above the pid_max, the processes have exited
+	var cache = NewCache(4)
+	cache.Add(Info{Pid: 4194400, PPid: 4194401, Comm: "curl"})
+	cache.Add(Info{Pid: 4194401, PPid: 1, Comm: "bash", TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+	cache.Add(Info{Pid: 4194402, PPid: 1, Comm: "wget"})
+
+	// the trace context of the parent is inherited
+	tc, ok := TraceContextOf(4194400, cache)
+	if !ok || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected the trace context of the parent, got %v %v", tc, ok)
+	}
+
+	if tc, ok := TraceContextOf(4194402, cache); ok {
+		t.Errorf("expected no trace context, got %v", tc)
+	}
+}
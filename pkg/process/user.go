@@ -0,0 +1,38 @@
+package process
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// Users resolves the user ids to the user names of the host, the names are
+// cached (unknown ids too, the lookup reads /etc/passwd each time)
+type Users struct {
+	mu    sync.Mutex
+	names map[uint32]string
+}
+
+// NewUsers returns an empty user name cache
+func NewUsers() *Users {
+	return &Users{names: make(map[uint32]string)}
+}
+
+// Name returns the name of the user id, empty if the id has no user on the
+// host (the users of a container image)
+func (u *Users) Name(uid uint32) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if name, ok := u.names[uid]; ok {
+		return name
+	}
+
+	var name string
+	if usr, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+		name = usr.Username
+	}
+	u.names[uid] = name
+
+	return name
+}
@@ -0,0 +1,18 @@
+package process
+
+import "testing"
+
+func TestUsers_Name(t *testing.T) {
+	var users = NewUsers()
+	if name := users.Name(0); name != "root" {
+		t.Errorf("expected root, got %q", name)
+	}
+
+	// an id without a user on the host
+	if name := users.Name(4294967294); name != "" {
+		t.Errorf("expected an empty name, got %q", name)
+	}
+	if _, ok := users.names[4294967294]; !ok {
+		t.Errorf("expected the unknown id to be cached")
+	}
+}
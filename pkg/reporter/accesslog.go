@@ -0,0 +1,42 @@
+package reporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// accessLogTimeFormat is the common log format time layout
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// formatAccessLog formats the event as an access log line:
+// [time] pid "comm" daddr:dport domain verdict bytes duration
+// Unknown values are written as "-" like the common log format does.
+func formatAccessLog(e domain.ReportEvent) string {
+	var domainName = "-"
+	if len(e.Domains) > 0 && e.Domains[0] != "" && e.Domains[0] != "." {
+		domainName = e.Domains[0]
+	}
+
+	return fmt.Sprintf("[%s] %d %s %s:%d %s %s %s %s",
+		e.Timestamp.Format(accessLogTimeFormat),
+		e.ProcessID,
+		strconv.Quote(e.TaskName),
+		e.DestinationAddress,
+		e.DestinationPort,
+		domainName,
+		orDash(e.Policy),
+		"-", // bytes
+		"-", // duration
+	)
+}
+
+func orDash(s string) string {
+	if s = strings.TrimSpace(s); s == "" {
+		return "-"
+	}
+
+	return s
+}
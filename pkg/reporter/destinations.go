@@ -0,0 +1,245 @@
+package reporter
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// destinationKey is the key of the aggregated connections
+type destinationKey struct {
+	address string
+	port    uint16
+	process string
+}
+
+// destinationTable aggregates the connections by the destination and the process
+type destinationTable map[destinationKey]*domain.DestinationSummary
+
+// add adds the connections of the summary, the policy is the one of the last connection
+func (d destinationTable) add(s domain.DestinationSummary) {
+	var key = destinationKey{address: s.DestinationAddress, port: s.DestinationPort, process: s.TaskName}
+
+	current, ok := d[key]
+	if !ok {
+		// the verdicts of the summaries of the reports are not modified
+		s.Verdicts = maps.Clone(s.Verdicts)
+		d[key] = &s
+		return
+	}
+
+	current.Connections += s.Connections
+	current.Blocked += s.Blocked
+	current.WouldBlock += s.WouldBlock
+	current.Killed += s.Killed
+	for verdict, n := range s.Verdicts {
+		if current.Verdicts == nil {
+			current.Verdicts = make(map[string]int)
+		}
+		current.Verdicts[verdict] += n
+	}
+	if s.FirstSeen.Before(current.FirstSeen) {
+		current.FirstSeen = s.FirstSeen
+	}
+	if !s.LastSeen.Before(current.LastSeen) {
+		current.LastSeen = s.LastSeen
+		current.Policy = s.Policy
+	}
+	if len(current.Domains) == 0 {
+		current.Domains = s.Domains
+	}
+	if current.Country == "" {
+		current.Country = s.Country
+	}
+	if current.ASN == 0 {
+		current.ASN, current.ASOrg = s.ASN, s.ASOrg
+	}
+}
+
+// list returns the summaries, the most connected destinations first. The
+// summaries are copies, the table keeps counting.
+func (d destinationTable) list() []domain.DestinationSummary {
+	var list = make([]domain.DestinationSummary, 0, len(d))
+	for _, s := range d {
+		var summary = *s
+		summary.Verdicts = maps.Clone(s.Verdicts)
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Connections != list[j].Connections {
+			return list[i].Connections > list[j].Connections
+		}
+		if list[i].DestinationAddress != list[j].DestinationAddress {
+			return list[i].DestinationAddress < list[j].DestinationAddress
+		}
+		if list[i].DestinationPort != list[j].DestinationPort {
+			return list[i].DestinationPort < list[j].DestinationPort
+		}
+		return list[i].TaskName < list[j].TaskName
+	})
+
+	return list
+}
+
+// destinationOf returns the summary of the connection of the event at the time
+func destinationOf(event domain.ReportEvent, ts time.Time) domain.DestinationSummary {
+	var s = domain.DestinationSummary{
+		TaskName:           event.TaskName,
+		Executable:         event.Executable,
+		Protocol:           event.Protocol,
+		DestinationAddress: event.DestinationAddress,
+		DestinationPort:    event.DestinationPort,
+		Domains:            event.Domains,
+		Country:            event.Country,
+		ASN:                event.ASN,
+		ASOrg:              event.ASOrg,
+		Connections:        1,
+		FirstSeen:          ts,
+		LastSeen:           ts,
+		Policy:             event.Policy,
+	}
+	if event.Policy == domain.EventPolicyStatusBlock {
+		s.Blocked = 1
+	}
+	if event.WouldBlock {
+		s.WouldBlock = 1
+	}
+	if event.Verdict != "" {
+		s.Verdicts = map[string]int{event.Verdict: 1}
+	}
+	if event.Killed {
+		s.Killed = 1
+	}
+
+	return s
+}
+
+// AggregateDestinations returns the events aggregated by the destination and
+// the process, each event is counted as a connection. It summarizes the
+// reports without the aggregated destinations (the reports of the previous
+// versions, the events of a history).
+func AggregateDestinations(events []domain.ReportEvent) []domain.DestinationSummary {
+	var table = make(destinationTable)
+	for _, e := range events {
+		table.add(destinationOf(e, e.Timestamp))
+	}
+
+	return table.list()
+}
+
+// addDestinationDomains sets the domains of the summaries from the events of
+// their destination, the server names are added to the events after the connection
+func addDestinationDomains(destinations []domain.DestinationSummary, events []domain.ReportEvent) {
+	var domains = make(map[string][]string, len(events))
+	for _, e := range events {
+		domains[e.DestinationAddress+":"+strconv.Itoa(int(e.DestinationPort))] = e.Domains
+	}
+
+	for i, s := range destinations {
+		if names, ok := domains[s.DestinationAddress+":"+strconv.Itoa(int(s.DestinationPort))]; ok && len(names) > 0 {
+			destinations[i].Domains = names
+		}
+	}
+}
+
+// mergeDestinations returns the summaries of the reports aggregated
+func mergeDestinations(lists ...[]domain.DestinationSummary) []domain.DestinationSummary {
+	var table = make(destinationTable)
+	for _, list := range lists {
+		for _, s := range list {
+			table.add(s)
+		}
+	}
+
+	return table.list()
+}
+
+// verdicts are the kernel verdicts in the order of the verdict column
+var verdicts = []string{domain.EventVerdictAllowed, domain.EventVerdictBlocked, domain.EventVerdictDelayed}
+
+// formatVerdicts returns the number of the connections of each kernel verdict
+// and of the killed ones (ALLOWED 12, BLOCKED 2, killed 2)
+func formatVerdicts(s domain.DestinationSummary) string {
+	var parts []string
+	for _, verdict := range verdicts {
+		if n := s.Verdicts[verdict]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", verdict, n))
+		}
+	}
+	if s.Killed > 0 {
+		parts = append(parts, fmt.Sprintf("killed %d", s.Killed))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// asOwner returns the autonomous system of the summary as "AS<number> <organization>"
+func asOwner(s domain.DestinationSummary) string {
+	if s.ASN == 0 {
+		return "-"
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("AS%d %s", s.ASN, s.ASOrg))
+}
+
+// printDestinationTable prints the connections aggregated by the destination
+// and the process, with the country and the AS owner columns if the geoip or
+// the asn enrichment stage labeled the destinations
+func printDestinationTable(destinations []domain.DestinationSummary) {
+	var geo bool
+	for _, s := range destinations {
+		if s.Country != "" || s.ASN != 0 {
+			geo = true
+			break
+		}
+	}
+
+	var header = []string{"Comm", "Proto", "Domain", "Destination Addr"}
+	if geo {
+		header = append(header, "Country", "AS Owner")
+	}
+	data := pterm.TableData{
+		append(header, "Connections", "Blocked", "Verdict", "First Seen", "Last Seen", "Policy"),
+	}
+
+	for _, s := range destinations {
+		var domains = "-"
+		if len(s.Domains) > 0 {
+			domains = strings.Join(s.Domains, ", ")
+		}
+
+		var row = []string{
+			s.TaskName,
+			s.Protocol,
+			domains,
+			fmt.Sprintf("%s:%d", s.DestinationAddress, s.DestinationPort),
+		}
+		if geo {
+			var country = "-"
+			if s.Country != "" {
+				country = s.Country
+			}
+			row = append(row, country, asOwner(s))
+		}
+		data = append(data, append(row,
+			strconv.Itoa(s.Connections),
+			strconv.Itoa(s.Blocked),
+			formatVerdicts(s),
+			s.FirstSeen.Format(time.DateTime),
+			s.LastSeen.Format(time.DateTime),
+			s.Policy,
+		))
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
@@ -0,0 +1,82 @@
+package reporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestAggregateDestinations(t *testing.T) {
+	var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var events = []domain.ReportEvent{
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start.Add(time.Minute)},
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, WouldBlock: true, Timestamp: start},
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 80, Policy: domain.EventPolicyStatusBlock, Timestamp: start},
+		{TaskName: "wget", DestinationAddress: "6.6.6.6", DestinationPort: 443, Policy: domain.EventPolicyStatusTarpit, Verdict: domain.EventVerdictDelayed, Timestamp: start},
+		{TaskName: "wget", DestinationAddress: "6.6.6.6", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Verdict: domain.EventVerdictBlocked, Killed: true, Timestamp: start.Add(time.Minute)},
+	}
+
+	var expected = []domain.DestinationSummary{
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Connections: 2, WouldBlock: 1, FirstSeen: start, LastSeen: start.Add(time.Minute), Policy: domain.EventPolicyStatusPass},
+		{TaskName: "wget", DestinationAddress: "6.6.6.6", DestinationPort: 443, Connections: 2, Blocked: 1, Killed: 1, FirstSeen: start, LastSeen: start.Add(time.Minute), Policy: domain.EventPolicyStatusBlock,
+			Verdicts: map[string]int{domain.EventVerdictDelayed: 1, domain.EventVerdictBlocked: 1}},
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 80, Connections: 1, Blocked: 1, FirstSeen: start, LastSeen: start, Policy: domain.EventPolicyStatusBlock},
+	}
+	got := AggregateDestinations(events)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	var verdicts = []string{"-", "BLOCKED 1, DELAYED 1, killed 1", "-"}
+	for i, s := range got {
+		if v := formatVerdicts(s); v != verdicts[i] {
+			t.Errorf("expected the verdicts %q, got %q", verdicts[i], v)
+		}
+	}
+}
+
+func TestAggregateDestinations_Geo(t *testing.T) {
+	var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var events = []domain.ReportEvent{
+		{TaskName: "curl", DestinationAddress: "140.82.114.22", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start},
+		{TaskName: "curl", DestinationAddress: "140.82.114.22", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Country: "US", ASN: 36459, ASOrg: "GITHUB", Timestamp: start},
+	}
+
+	var expected = []domain.DestinationSummary{
+		{TaskName: "curl", DestinationAddress: "140.82.114.22", DestinationPort: 443, Country: "US", ASN: 36459, ASOrg: "GITHUB", Connections: 2, FirstSeen: start, LastSeen: start, Policy: domain.EventPolicyStatusPass},
+	}
+	got := AggregateDestinations(events)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if owner := asOwner(got[0]); owner != "AS36459 GITHUB" {
+		t.Errorf("expected the AS owner AS36459 GITHUB, got %s", owner)
+	}
+}
+
+func TestMergeDestinations(t *testing.T) {
+	var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var shard1 = []domain.DestinationSummary{
+		{TaskName: "npm", DestinationAddress: "104.16.0.1", DestinationPort: 443, Connections: 5, FirstSeen: start, LastSeen: start.Add(time.Minute), Policy: domain.EventPolicyStatusPass,
+			Verdicts: map[string]int{domain.EventVerdictAllowed: 5}},
+	}
+	var shard2 = []domain.DestinationSummary{
+		{TaskName: "npm", DestinationAddress: "104.16.0.1", DestinationPort: 443, Connections: 2, Blocked: 2, FirstSeen: start.Add(-time.Minute), LastSeen: start.Add(2 * time.Minute), Policy: domain.EventPolicyStatusBlock,
+			Verdicts: map[string]int{domain.EventVerdictBlocked: 2}},
+		{TaskName: "pip", DestinationAddress: "151.101.0.223", DestinationPort: 443, Connections: 1, FirstSeen: start, LastSeen: start, Policy: domain.EventPolicyStatusPass},
+	}
+
+	var expected = []domain.DestinationSummary{
+		{TaskName: "npm", DestinationAddress: "104.16.0.1", DestinationPort: 443, Connections: 7, Blocked: 2, FirstSeen: start.Add(-time.Minute), LastSeen: start.Add(2 * time.Minute), Policy: domain.EventPolicyStatusBlock,
+			Verdicts: map[string]int{domain.EventVerdictAllowed: 5, domain.EventVerdictBlocked: 2}},
+		{TaskName: "pip", DestinationAddress: "151.101.0.223", DestinationPort: 443, Connections: 1, FirstSeen: start, LastSeen: start, Policy: domain.EventPolicyStatusPass},
+	}
+	if got := mergeDestinations(shard1, shard2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if v := shard1[0].Verdicts; !reflect.DeepEqual(v, map[string]int{domain.EventVerdictAllowed: 5}) {
+		t.Errorf("expected the verdicts of the shard to be kept, got %v", v)
+	}
+}
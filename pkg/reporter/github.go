@@ -0,0 +1,345 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// commentMarker identifies the comment of kntrl, the comment is updated on reruns
+	commentMarker = "<!-- kntrl-egress-report -->"
+
+	defaultGitHubAPI = "https://api.github.com"
+	// maxCommentViolations is the number of violations listed in the comment
+	maxCommentViolations = 50
+	commentsPerPage      = 100
+)
+
+// GitHubConfig is the configuration of the pull request comment
+type GitHubConfig struct {
+	// APIURL is the GitHub API address (https://api.github.com)
+	APIURL string
+	// Token is the GitHub token, it requires the pull-requests: write permission
+	Token string
+	// Repository is the owner/name of the repository
+	Repository string
+	// PullRequest is the number of the pull request
+	PullRequest int
+	// SHA is the head commit of the pull request
+	SHA string
+}
+
+// GitHubConfigFromEnv returns the configuration of the GitHub Actions environment,
+// the pull request is read from the event payload ($GITHUB_EVENT_PATH)
+func GitHubConfigFromEnv() (GitHubConfig, error) {
+	var cfg = GitHubConfig{
+		APIURL:     os.Getenv("GITHUB_API_URL"),
+		Token:      os.Getenv("GITHUB_TOKEN"),
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		SHA:        os.Getenv("GITHUB_SHA"),
+	}
+
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read github event: %w", err)
+		}
+
+		var event struct {
+			PullRequest struct {
+				Number int `json:"number"`
+				Head   struct {
+					SHA string `json:"sha"`
+				} `json:"head"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return cfg, fmt.Errorf("failed to parse github event: %w", err)
+		}
+
+		cfg.PullRequest = event.PullRequest.Number
+		// GITHUB_SHA is the merge commit on pull_request events
+		if event.PullRequest.Head.SHA != "" {
+			cfg.SHA = event.PullRequest.Head.SHA
+		}
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the required fields
+func (c GitHubConfig) Validate() error {
+	if c.Token == "" {
+		return errors.New("github token is required")
+	}
+	if !strings.Contains(c.Repository, "/") {
+		return fmt.Errorf("invalid github repository: %q", c.Repository)
+	}
+	if c.PullRequest <= 0 {
+		return errors.New("pull request is required, the workflow is not run by a pull request event")
+	}
+
+	return nil
+}
+
+// issueComment is a comment of the GitHub issues API
+type issueComment struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// CommentPR posts the report summary as a comment of the pull request.
+// The comment of a previous run is updated, there's a single comment per pull request.
+func CommentPR(ctx context.Context, cfg GitHubConfig, report domain.Report) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var api = strings.TrimSuffix(cfg.APIURL, "/")
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+
+	var client = &http.Client{Timeout: defaultUploadTimeout}
+	var body = CommentBody(report, cfg.SHA)
+
+	id, err := findComment(ctx, client, api, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to list pull request comments: %w", err)
+	}
+
+	if id != 0 {
+		var endpoint = fmt.Sprintf("%s/repos/%s/issues/comments/%d", api, cfg.Repository, id)
+		if err := githubRequest(ctx, client, http.MethodPatch, endpoint, cfg.Token, issueComment{Body: body}, nil); err != nil {
+			return fmt.Errorf("failed to update pull request comment: %w", err)
+		}
+		logger.Log.Infof("pull request #%d comment updated", cfg.PullRequest)
+		return nil
+	}
+
+	var endpoint = fmt.Sprintf("%s/repos/%s/issues/%d/comments", api, cfg.Repository, cfg.PullRequest)
+	if err := githubRequest(ctx, client, http.MethodPost, endpoint, cfg.Token, issueComment{Body: body}, nil); err != nil {
+		return fmt.Errorf("failed to post pull request comment: %w", err)
+	}
+	logger.Log.Infof("pull request #%d comment posted", cfg.PullRequest)
+
+	return nil
+}
+
+// findComment returns the id of the comment with the marker, 0 if there's none
+func findComment(ctx context.Context, client *http.Client, api string, cfg GitHubConfig) (int64, error) {
+	for page := 1; ; page++ {
+		var endpoint = fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=%d&page=%d", api, cfg.Repository, cfg.PullRequest, commentsPerPage, page)
+
+		var comments []issueComment
+		if err := githubRequest(ctx, client, http.MethodGet, endpoint, cfg.Token, nil, &comments); err != nil {
+			return 0, err
+		}
+
+		for _, c := range comments {
+			if strings.Contains(c.Body, commentMarker) {
+				return c.ID, nil
+			}
+		}
+
+		if len(comments) < commentsPerPage {
+			return 0, nil
+		}
+	}
+}
+
+func githubRequest(ctx context.Context, client *http.Client, method, endpoint, token string, in, out any) error {
+	var reqBody io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("github returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CommentBody renders the report summary and the violations as markdown
+func CommentBody(report domain.Report, sha string) string {
+	var b strings.Builder
+
+	b.WriteString(commentMarker + "\n")
+	b.WriteString("### kntrl egress report\n\n")
+	if sha != "" {
+		fmt.Fprintf(&b, "Commit `%s`, mode `%s`\n\n", shortSHA(sha), report.Mode)
+	} else {
+		fmt.Fprintf(&b, "Mode `%s`\n\n", report.Mode)
+	}
+
+	b.WriteString("| Connections | Pass | Block | Sent | Received |\n| --- | --- | --- | --- | --- |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %s | %s |\n\n", report.Summary.Total, report.Summary.Pass, report.Summary.Block,
+		FormatBytes(report.Summary.BytesSent), FormatBytes(report.Summary.BytesReceived))
+
+	if len(report.Summary.Categories) > 0 {
+		var names = make([]string, 0, len(report.Summary.Categories))
+		for name := range report.Summary.Categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var totals = make([]string, 0, len(names))
+		for _, name := range names {
+			totals = append(totals, fmt.Sprintf("%s: %d", name, report.Summary.Categories[name]))
+		}
+		fmt.Fprintf(&b, "Categories: %s\n\n", strings.Join(totals, ", "))
+	}
+
+	if len(report.Hygiene) > 0 {
+		var rules = make([]string, 0, len(report.Hygiene))
+		for _, s := range report.Hygiene {
+			rules = append(rules, fmt.Sprintf("`%s` (%d runs)", s.Rule, s.IdleRuns))
+		}
+		fmt.Fprintf(&b, "Stale policy rules: %s\n\n", strings.Join(rules, ", "))
+	}
+
+	if len(report.DNS) > 0 {
+		var nxdomain []string
+		for _, q := range report.DNS {
+			if q.Rcode == "NXDOMAIN" {
+				nxdomain = append(nxdomain, "`"+q.Name+"`")
+			}
+		}
+		fmt.Fprintf(&b, "DNS: %d name(s) looked up", len(report.DNS))
+		if len(nxdomain) > 0 {
+			if len(nxdomain) > maxCommentViolations {
+				nxdomain = append(nxdomain[:maxCommentViolations], "...")
+			}
+			fmt.Fprintf(&b, ", not found: %s", strings.Join(nxdomain, ", "))
+		}
+		b.WriteString("\n\n")
+	}
+
+	if len(report.Listeners) > 0 {
+		var listeners = make([]string, 0, len(report.Listeners))
+		for _, l := range report.Listeners {
+			listeners = append(listeners, fmt.Sprintf("`%s` %s `%s`", l.TaskName, l.Protocol, net.JoinHostPort(l.Address, strconv.Itoa(int(l.Port)))))
+		}
+		fmt.Fprintf(&b, "Listeners on a non-loopback address: %s\n\n", strings.Join(listeners, ", "))
+	}
+
+	if len(report.Findings) > 0 {
+		fmt.Fprintf(&b, "#### Findings (%d)\n\n", len(report.Findings))
+		b.WriteString("| Finding | Process | Detail | Destination | Policy |\n| --- | --- | --- | --- | --- |\n")
+		for i, f := range report.Findings {
+			if i == maxCommentViolations {
+				fmt.Fprintf(&b, "\n_%d more finding(s) in the report_\n", len(report.Findings)-maxCommentViolations)
+				break
+			}
+			var destination = fmt.Sprintf("%s:%d", f.DestinationAddress, f.DestinationPort)
+			if len(f.Domains) > 0 {
+				destination = f.Domains[0]
+			}
+			fmt.Fprintf(&b, "| %s | `%s` (%d) | %s | `%s` | %s |\n", f.Kind, f.TaskName, f.ProcessID, findingDetail(f), destination, f.Policy)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(report.Suggestions) > 0 {
+		var suggestions = report.Suggestions
+		if len(suggestions) > maxCommentViolations {
+			suggestions = suggestions[:maxCommentViolations]
+		}
+		fmt.Fprintf(&b, "#### Suggested policy additions (%d)\n\n```yaml\n%s```\n\n", len(report.Suggestions), SuggestedPolicy(suggestions))
+	}
+
+	var violations []domain.ReportEvent
+	for _, e := range report.Events {
+		if e.Policy == domain.EventPolicyStatusBlock || e.Policy == domain.EventPolicyStatusTarpit {
+			violations = append(violations, e)
+		}
+	}
+
+	if report.Summary.Tarpit > 0 {
+		fmt.Fprintf(&b, "%d connection(s) delayed by the tarpit instead of blocked\n\n", report.Summary.Tarpit)
+	}
+	if report.Summary.WouldBlock > 0 {
+		fmt.Fprintf(&b, "%d connection(s) would be blocked in the `trace` mode\n\n", report.Summary.WouldBlock)
+	}
+	if e := report.Escalation; e != nil {
+		fmt.Fprintf(&b, ":rotating_light: escalated from the `%s` mode to the `%s` mode after %d high-severity finding(s)\n\n",
+			e.From, e.To, len(e.Findings))
+	}
+
+	if len(violations) == 0 {
+		b.WriteString("No egress violations :white_check_mark:\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "#### Violations (%d)\n\n", len(violations))
+	b.WriteString("| Process | Destination | Domains | Sent |\n| --- | --- | --- | --- |\n")
+	for i, e := range violations {
+		if i == maxCommentViolations {
+			fmt.Fprintf(&b, "\n_%d more violation(s) in the report_\n", len(violations)-maxCommentViolations)
+			break
+		}
+
+		var process = formatCommand(e)
+		fmt.Fprintf(&b, "| `%s` (%s) | `%s:%s` | %s | %s |\n",
+			process,
+			strconv.FormatUint(uint64(e.ProcessID), 10),
+			e.DestinationAddress,
+			strconv.Itoa(int(e.DestinationPort)),
+			strings.Join(e.Domains, ", "),
+			FormatBytes(e.BytesSent),
+		)
+	}
+
+	fmt.Fprintf(&b, "\n_updated at %s_\n", report.FinishedAt.UTC().Format(time.RFC3339))
+
+	return b.String()
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+
+	return sha
+}
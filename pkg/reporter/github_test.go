@@ -0,0 +1,115 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestCommentPR_UpdatesExisting(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		comments []issueComment
+		posts    int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/kondukto-io/kntrl/issues/7/comments":
+			_ = json.NewEncoder(w).Encode(comments)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/kondukto-io/kntrl/issues/7/comments":
+			var c issueComment
+			_ = json.NewDecoder(r.Body).Decode(&c)
+			c.ID = int64(len(comments) + 1)
+			comments = append(comments, c)
+			posts++
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/kondukto-io/kntrl/issues/comments/"):
+			var c issueComment
+			_ = json.NewDecoder(r.Body).Decode(&c)
+			for i := range comments {
+				if r.URL.Path == fmt.Sprintf("/repos/kondukto-io/kntrl/issues/comments/%d", comments[i].ID) {
+					comments[i].Body = c.Body
+				}
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	// a comment of another user
+	comments = append(comments, issueComment{ID: 1, Body: "lgtm"})
+
+	var cfg = GitHubConfig{APIURL: server.URL, Token: "token", Repository: "kondukto-io/kntrl", PullRequest: 7, SHA: "0123456789abcdef"}
+
+	var report = domain.Report{Summary: domain.ReportSummary{Total: 1, Pass: 1}}
+	if err := CommentPR(context.Background(), cfg, report); err != nil {
+		t.Fatalf("failed to comment: %v", err)
+	}
+
+	report = domain.Report{
+		Summary: domain.ReportSummary{Total: 2, Pass: 1, Block: 1},
+		Events: []domain.ReportEvent{
+			{TaskName: "curl", ProcessID: 42, DestinationAddress: "6.6.6.6", DestinationPort: 443, Domains: []string{"pastebin.com"}, Policy: domain.EventPolicyStatusBlock},
+		},
+	}
+	if err := CommentPR(context.Background(), cfg, report); err != nil {
+		t.Fatalf("failed to comment: %v", err)
+	}
+
+	if posts != 1 || len(comments) != 2 {
+		t.Fatalf("expected a single comment, got %d posts", posts)
+	}
+	if body := comments[1].Body; !strings.Contains(body, "pastebin.com") || !strings.Contains(body, "0123456789ab") {
+		t.Errorf("the comment is not updated: %s", body)
+	}
+	if comments[0].Body != "lgtm" {
+		t.Errorf("unexpected comment update: %s", comments[0].Body)
+	}
+}
+
+func TestGitHubConfigFromEnv(t *testing.T) {
+	var event = filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"pull_request":{"number":7,"head":{"sha":"abc"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GITHUB_EVENT_PATH", event)
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("GITHUB_REPOSITORY", "kondukto-io/kntrl")
+	t.Setenv("GITHUB_SHA", "merge")
+
+	cfg, err := GitHubConfigFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PullRequest != 7 || cfg.SHA != "abc" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.PullRequest = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error without a pull request")
+	}
+}
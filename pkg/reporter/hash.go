@@ -0,0 +1,147 @@
+package reporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// hashSize is the size of a destination hash (128 bits)
+const hashSize = 16
+
+// Hasher replaces the destinations (the addresses and the hostnames) of the
+// events with salted hashes. The hashes of a destination are the same on all
+// the runners of the salt, a central service correlates the destinations of
+// the runners without the raw destinations.
+type Hasher struct {
+	salt []byte
+}
+
+// NewHasher returns the hasher of the salt, shared by the runners of an
+// organization. The salt is required, the hash of an IPv4 address without a
+// salt is reversed by hashing all the addresses.
+func NewHasher(salt string) (*Hasher, error) {
+	if salt == "" {
+		return nil, errors.New("hash salt is required")
+	}
+
+	return &Hasher{salt: []byte(salt)}, nil
+}
+
+// Hash returns the salted hash of the value (HMAC-SHA256, hex encoded), the
+// names are hashed in lowercase without the trailing dot of the PTR records
+func (h *Hasher) Hash(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	var mac = hmac.New(sha256.New, h.salt)
+	mac.Write([]byte(strings.ToLower(strings.TrimSuffix(value, "."))))
+
+	return hex.EncodeToString(mac.Sum(nil)[:hashSize])
+}
+
+// Event returns the event with the hashes of its destinations, the port and
+// the process are kept. The capture file, named after the destination, is removed.
+func (h *Hasher) Event(e domain.ReportEvent) domain.ReportEvent {
+	e.DestinationAddress = h.Hash(e.DestinationAddress)
+	e.ServerName = h.Hash(e.ServerName)
+	e.NAT64 = h.Hash(e.NAT64)
+	e.Capture = ""
+	e.Domains = h.domains(e.Domains)
+
+	return e
+}
+
+// domains returns the hashes of the domain names
+func (h *Hasher) domains(domains []string) []string {
+	if domains == nil {
+		return nil
+	}
+
+	var hashed = make([]string, len(domains))
+	for i, d := range domains {
+		hashed[i] = h.Hash(d)
+	}
+
+	return hashed
+}
+
+// Escalation returns the escalation with the hashes of the destinations of its findings
+func (h *Hasher) Escalation(e domain.Escalation) domain.Escalation {
+	e.Findings = h.findings(e.Findings)
+
+	return e
+}
+
+// findings returns the findings with the hashes of their destinations
+func (h *Hasher) findings(findings []domain.Finding) []domain.Finding {
+	if findings == nil {
+		return nil
+	}
+
+	var hashed = make([]domain.Finding, len(findings))
+	for i, f := range findings {
+		f.DestinationAddress = h.Hash(f.DestinationAddress)
+		f.Domains = h.domains(f.Domains)
+		hashed[i] = f
+	}
+
+	return hashed
+}
+
+// Report returns the report with the hashes of the destinations of the events,
+// of the aggregated destinations, of the allow list entries, of the findings
+// (the escalation included) and of the DNS queries. The policy suggestions
+// are removed.
+func (h *Hasher) Report(r domain.Report) domain.Report {
+	var events = make([]domain.ReportEvent, len(r.Events))
+	for i, e := range r.Events {
+		events[i] = h.Event(e)
+	}
+	r.Events = events
+
+	if r.Destinations != nil {
+		var destinations = make([]domain.DestinationSummary, len(r.Destinations))
+		for i, s := range r.Destinations {
+			s.DestinationAddress = h.Hash(s.DestinationAddress)
+			s.Domains = h.domains(s.Domains)
+			destinations[i] = s
+		}
+		r.Destinations = destinations
+	}
+
+	if r.Allowed != nil {
+		var allowed = make([]domain.AllowEntry, len(r.Allowed))
+		for i, entry := range r.Allowed {
+			entry.Address = h.Hash(entry.Address)
+			entry.Rule = h.Hash(entry.Rule)
+			allowed[i] = entry
+		}
+		r.Allowed = allowed
+	}
+
+	r.Findings = h.findings(r.Findings)
+	if r.Escalation != nil {
+		var escalation = h.Escalation(*r.Escalation)
+		r.Escalation = &escalation
+	}
+
+	if r.DNS != nil {
+		var queries = make([]domain.DNSQuery, len(r.DNS))
+		for i, q := range r.DNS {
+			q.Name = h.Hash(q.Name)
+			queries[i] = q
+		}
+		r.DNS = queries
+	}
+
+	// the suggestions of the hashes can't be pasted into a policy
+	r.Suggestions = nil
+
+	return r
+}
@@ -0,0 +1,71 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestHasher(t *testing.T) {
+	if _, err := NewHasher(""); err == nil {
+		t.Errorf("expected an error without a salt")
+	}
+
+	h, err := NewHasher("org-salt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewHasher("other-salt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event = domain.ReportEvent{
+		TaskName:           "curl",
+		DestinationAddress: "140.82.114.22",
+		DestinationPort:    443,
+		Domains:            []string{"lb-140-82-114-22-iad.github.com."},
+		ServerName:         "GitHub.com",
+		Capture:            "/var/lib/kntrl/captures/kntrl-140.82.114.22-443.pcap",
+	}
+	hashed := h.Event(event)
+
+	if hashed.DestinationAddress == event.DestinationAddress || len(hashed.DestinationAddress) != 2*hashSize {
+		t.Errorf("expected the hash of the address, got %q", hashed.DestinationAddress)
+	}
+	if hashed.DestinationAddress != h.Event(event).DestinationAddress {
+		t.Errorf("expected the same hash of the same salt")
+	}
+	if hashed.DestinationAddress == other.Event(event).DestinationAddress {
+		t.Errorf("expected another hash of another salt")
+	}
+	if hashed.Domains[0] != h.Hash("lb-140-82-114-22-iad.github.com") || hashed.ServerName != h.Hash("github.com") {
+		t.Errorf("expected the names to be normalized before hashing, got %v %q", hashed.Domains, hashed.ServerName)
+	}
+	if event.Domains[0] != "lb-140-82-114-22-iad.github.com." {
+		t.Errorf("expected the domains of the event to be kept, got %v", event.Domains)
+	}
+	if hashed.TaskName != "curl" || hashed.DestinationPort != 443 || hashed.NAT64 != "" {
+		t.Errorf("expected the process and the port to be kept, got %+v", hashed)
+	}
+	if hashed.Capture != "" {
+		t.Errorf("expected the capture file to be removed, got %q", hashed.Capture)
+	}
+
+	var destinations = AggregateDestinations([]domain.ReportEvent{event})
+	report := h.Report(domain.Report{
+		Events:       []domain.ReportEvent{event},
+		Destinations: destinations,
+		Allowed:      []domain.AllowEntry{{Address: "140.82.114.22", Source: domain.AllowSourcePolicy, Rule: "lb-140-82-114-22-iad.github.com."}},
+	})
+	if report.Events[0].DestinationAddress != hashed.DestinationAddress || report.Allowed[0].Address != hashed.DestinationAddress {
+		t.Errorf("expected the hashes of the report destinations, got %+v", report)
+	}
+	if s := report.Destinations[0]; s.DestinationAddress != hashed.DestinationAddress || s.Domains[0] != hashed.Domains[0] ||
+		s.TaskName != "curl" || s.DestinationPort != 443 || s.Connections != 1 {
+		t.Errorf("expected the hashes of the aggregated destinations, got %+v", s)
+	}
+	if destinations[0].DestinationAddress != event.DestinationAddress {
+		t.Errorf("expected the aggregated destinations of the report to be kept, got %+v", destinations[0])
+	}
+}
@@ -0,0 +1,224 @@
+package reporter
+
+import (
+	"net"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// policyRank orders the policies from the least to the most restrictive
+var policyRank = map[string]int{
+	domain.EventPolicyStatusPass:   1,
+	domain.EventPolicyStatusTarpit: 2,
+	domain.EventPolicyStatusBlock:  3,
+}
+
+// ReportOfEvents returns the report of the events written during a run, the
+// report of a run interrupted before its report was written. The first event
+// of a destination (address and port) is kept as the reporter does.
+func ReportOfEvents(events []domain.ReportEvent) domain.Report {
+	var report = domain.Report{Events: make([]domain.ReportEvent, 0, len(events))}
+	var seen = make(map[string]bool)
+	for _, e := range events {
+		if report.StartedAt.IsZero() || e.Timestamp.Before(report.StartedAt) {
+			report.StartedAt = e.Timestamp
+		}
+		if e.Timestamp.After(report.FinishedAt) {
+			report.FinishedAt = e.Timestamp
+		}
+
+		var key = net.JoinHostPort(e.DestinationAddress, strconv.Itoa(int(e.DestinationPort)))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		report.Events = append(report.Events, e)
+	}
+	report.Summary = summarize(report.Events)
+	report.Suggestions = SuggestPolicy(report.Events)
+
+	return report
+}
+
+// FilterReport returns the report with the events and the findings of the
+// process and of the destination, an empty filter matches all. The process is
+// the task name or the executable (its path or its name). The destination is
+// an address, a network (CIDR) or a domain name (a leading dot matches the
+// subdomains only), with an optional port (host:port). The summary and the
+// suggestions are recomputed, the other sections are of the whole run.
+func FilterReport(report domain.Report, process, destination string) domain.Report {
+	if process == "" && destination == "" {
+		return report
+	}
+
+	var match = DestinationMatcher(destination)
+	var events = make([]domain.ReportEvent, 0, len(report.Events))
+	for _, e := range report.Events {
+		if processMatches(process, e.TaskName, e.Executable) && match(e.DestinationAddress, e.DestinationPort, e.Domains) {
+			events = append(events, e)
+		}
+	}
+
+	var findings []domain.Finding
+	for _, f := range report.Findings {
+		if processMatches(process, f.TaskName, f.Executable) && match(f.DestinationAddress, f.DestinationPort, f.Domains) {
+			findings = append(findings, f)
+		}
+	}
+
+	// the reports without the aggregated destinations are aggregated from the events
+	if report.Destinations != nil {
+		var destinations = make([]domain.DestinationSummary, 0, len(report.Destinations))
+		for _, s := range report.Destinations {
+			if processMatches(process, s.TaskName, s.Executable) && match(s.DestinationAddress, s.DestinationPort, s.Domains) {
+				destinations = append(destinations, s)
+			}
+		}
+		report.Destinations = destinations
+	}
+
+	report.Events = events
+	report.Findings = findings
+	report.Summary = summarize(events)
+	report.Suggestions = SuggestPolicy(events)
+
+	return report
+}
+
+// processMatches returns true if the process filter is empty, the task name
+// or the executable
+func processMatches(process, taskName, executable string) bool {
+	if process == "" {
+		return true
+	}
+
+	return process == taskName || process == executable || (executable != "" && process == filepath.Base(executable))
+}
+
+// DestinationMatcher returns the function matching the destination filter: an
+// address, a network (CIDR) or a domain name (a leading dot matches the
+// subdomains only), with an optional port (host:port). An empty filter matches all.
+func DestinationMatcher(destination string) func(addr string, port uint16, domains []string) bool {
+	if destination == "" {
+		return func(string, uint16, []string) bool { return true }
+	}
+
+	var host, wantPort = destination, -1
+	if h, p, err := net.SplitHostPort(destination); err == nil {
+		if n, err := strconv.ParseUint(p, 10, 16); err == nil {
+			host, wantPort = h, int(n)
+		}
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	// the IPv4-mapped IPv6 addresses are reported as IPv4
+	if addr, err := netip.ParseAddr(host); err == nil {
+		host = addr.Unmap().String()
+	}
+	prefix, prefixErr := netip.ParsePrefix(host)
+	prefix = utils.UnmapPrefix(prefix)
+
+	return func(addr string, port uint16, domains []string) bool {
+		if wantPort >= 0 && int(port) != wantPort {
+			return false
+		}
+		if addr == host {
+			return true
+		}
+		if prefixErr == nil {
+			ip, err := netip.ParseAddr(addr)
+			return err == nil && prefix.Contains(ip.Unmap())
+		}
+
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimSuffix(d, "."))
+			if strings.HasPrefix(host, ".") {
+				if strings.HasSuffix(d, host) {
+					return true
+				}
+				continue
+			}
+			if d == host || strings.HasSuffix(d, "."+host) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// DiffReports returns the destinations of the head report added, removed and
+// with another policy since the base report. A destination is its domain name
+// (or its address), its port and its protocol: the rotating addresses of a
+// host are the same destination.
+func DiffReports(base, head domain.Report) domain.RunDiff {
+	var baseDests, headDests = diffEntries(base.Events), diffEntries(head.Events)
+	var diff = domain.RunDiff{
+		Added:   []domain.RunDiffEntry{},
+		Removed: []domain.RunDiffEntry{},
+		Changed: []domain.RunDiffEntry{},
+	}
+
+	for key, h := range headDests {
+		b, ok := baseDests[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, *h)
+		case b.Policy != h.Policy:
+			var changed = *h
+			changed.BasePolicy = b.Policy
+			diff.Changed = append(diff.Changed, changed)
+		}
+	}
+	for key, b := range baseDests {
+		if _, ok := headDests[key]; !ok {
+			diff.Removed = append(diff.Removed, *b)
+		}
+	}
+
+	for _, entries := range [][]domain.RunDiffEntry{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Destination != entries[j].Destination {
+				return entries[i].Destination < entries[j].Destination
+			}
+			if entries[i].Port != entries[j].Port {
+				return entries[i].Port < entries[j].Port
+			}
+			return entries[i].Protocol < entries[j].Protocol
+		})
+	}
+
+	return diff
+}
+
+// diffEntries returns the destinations of the events by name, port and protocol
+func diffEntries(events []domain.ReportEvent) map[string]*domain.RunDiffEntry {
+	var entries = make(map[string]*domain.RunDiffEntry)
+	for _, e := range events {
+		var dest = strings.ToLower(strings.TrimSuffix(domainOf(e), "."))
+		var key = e.Protocol + "/" + net.JoinHostPort(dest, strconv.Itoa(int(e.DestinationPort)))
+
+		entry, ok := entries[key]
+		if !ok {
+			entry = &domain.RunDiffEntry{Destination: dest, Port: e.DestinationPort, Protocol: e.Protocol}
+			entries[key] = entry
+		}
+		if !slices.Contains(entry.Addresses, e.DestinationAddress) {
+			entry.Addresses = append(entry.Addresses, e.DestinationAddress)
+		}
+		if !slices.Contains(entry.Tasks, e.TaskName) {
+			entry.Tasks = append(entry.Tasks, e.TaskName)
+		}
+		if policyRank[e.Policy] > policyRank[entry.Policy] {
+			entry.Policy = e.Policy
+		}
+	}
+
+	return entries
+}
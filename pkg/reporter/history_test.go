@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestFilterReport(t *testing.T) {
+	var report = domain.Report{
+		Events: []domain.ReportEvent{
+			{TaskName: "curl", Executable: "/usr/bin/curl", DestinationAddress: "140.82.112.3", DestinationPort: 443, Domains: []string{"api.github.com."}, Policy: domain.EventPolicyStatusPass},
+			{TaskName: "npm", Executable: "/usr/local/bin/node", DestinationAddress: "104.16.1.35", DestinationPort: 443, Domains: []string{"registry.npmjs.org."}, Policy: domain.EventPolicyStatusPass},
+			{TaskName: "nc", Executable: "/usr/bin/nc", DestinationAddress: "10.0.0.5", DestinationPort: 4444, Policy: domain.EventPolicyStatusBlock},
+		},
+		Findings: []domain.Finding{{Kind: domain.FindingKindConnectionRate, TaskName: "nc", DestinationAddress: "10.0.0.5", DestinationPort: 4444}},
+	}
+
+	for _, tc := range []struct {
+		process, destination string
+		events               int
+	}{
+		{"", "", 3},
+		{"curl", "", 1},
+		{"node", "", 1},
+		{"/usr/bin/nc", "", 1},
+		{"", "github.com", 1},
+		{"", ".github.com", 1},
+		{"", ".npmjs.org:443", 1},
+		{"", ".npmjs.org:80", 0},
+		{"", "10.0.0.0/8", 1},
+		{"", "10.0.0.5:4444", 1},
+		{"", "[::ffff:10.0.0.5]:4444", 1},
+		{"", "::ffff:10.0.0.0/104", 1},
+		{"curl", "registry.npmjs.org", 0},
+	} {
+		filtered := FilterReport(report, tc.process, tc.destination)
+		if len(filtered.Events) != tc.events {
+			t.Errorf("%q %q: expected %d events, got %+v", tc.process, tc.destination, tc.events, filtered.Events)
+		}
+	}
+
+	filtered := FilterReport(report, "nc", "")
+	if len(filtered.Findings) != 1 || filtered.Summary.Block != 1 || len(filtered.Suggestions) != 1 {
+		t.Errorf("unexpected filtered report: %+v", filtered)
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	var base = domain.Report{Events: []domain.ReportEvent{
+		{TaskName: "curl", Protocol: "tcp", DestinationAddress: "140.82.112.3", DestinationPort: 443, Domains: []string{"api.github.com."}, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "npm", Protocol: "tcp", DestinationAddress: "104.16.1.35", DestinationPort: 443, Domains: []string{"registry.npmjs.org."}, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "wget", Protocol: "tcp", DestinationAddress: "5.5.5.5", DestinationPort: 80, Policy: domain.EventPolicyStatusBlock},
+	}}
+	var head = domain.Report{Events: []domain.ReportEvent{
+		// another address of the same host
+		{TaskName: "curl", Protocol: "tcp", DestinationAddress: "140.82.112.4", DestinationPort: 443, Domains: []string{"api.github.com."}, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "npm", Protocol: "tcp", DestinationAddress: "104.16.1.35", DestinationPort: 443, Domains: []string{"registry.npmjs.org."}, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "npm", Protocol: "tcp", DestinationAddress: "104.16.1.36", DestinationPort: 443, Domains: []string{"registry.npmjs.org."}, Policy: domain.EventPolicyStatusBlock},
+		{TaskName: "nc", Protocol: "tcp", DestinationAddress: "10.0.0.5", DestinationPort: 4444, Policy: domain.EventPolicyStatusBlock},
+	}}
+
+	diff := DiffReports(base, head)
+	if len(diff.Added) != 1 || diff.Added[0].Destination != "10.0.0.5" || diff.Added[0].Port != 4444 {
+		t.Errorf("unexpected added destinations: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Destination != "5.5.5.5" {
+		t.Errorf("unexpected removed destinations: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Destination != "registry.npmjs.org" ||
+		diff.Changed[0].Policy != domain.EventPolicyStatusBlock || diff.Changed[0].BasePolicy != domain.EventPolicyStatusPass ||
+		len(diff.Changed[0].Addresses) != 2 {
+		t.Errorf("unexpected changed destinations: %+v", diff.Changed)
+	}
+}
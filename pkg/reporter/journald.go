@@ -0,0 +1,125 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// journalSocket is the socket of the native protocol of the systemd journal
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journald writes the events to the systemd journal with the native protocol,
+// the event fields are the KNTRL_* fields of the entry
+// (journalctl -t kntrl KNTRL_POLICY=block)
+type Journald struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newJournald connects to the journal socket, the default socket if empty
+func newJournald(socket string) (*Journald, error) {
+	if socket == "" || socket == "/" {
+		socket = journalSocket
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the journal [%s]: %w", socket, err)
+	}
+
+	return &Journald{conn: conn}, nil
+}
+
+// Name returns the name of the sink
+func (j *Journald) Name() string {
+	return "journald"
+}
+
+// Write writes the event as a journal entry
+func (j *Journald) Write(event domain.ReportEvent) error {
+	var entry bytes.Buffer
+	field := func(name, value string) {
+		if value != "" {
+			writeJournalField(&entry, name, value)
+		}
+	}
+	field("MESSAGE", summary(event))
+	field("PRIORITY", strconv.Itoa(severity(event)))
+	field("SYSLOG_IDENTIFIER", syslogAppName)
+	field("KNTRL_PID", strconv.FormatUint(uint64(event.ProcessID), 10))
+	field("KNTRL_COMM", event.TaskName)
+	field("KNTRL_EXE", event.Executable)
+	field("KNTRL_PROTO", event.Protocol)
+	field("KNTRL_DADDR", event.DestinationAddress)
+	field("KNTRL_DPORT", strconv.Itoa(int(event.DestinationPort)))
+	field("KNTRL_DOMAINS", strings.Join(event.Domains, ","))
+	field("KNTRL_SERVER_NAME", event.ServerName)
+	field("KNTRL_POLICY", event.Policy)
+	field("KNTRL_CONTAINER", event.Container)
+	field("KNTRL_SANDBOX", event.Sandbox)
+	field("KNTRL_NAT64", event.NAT64)
+	field("KNTRL_TRACE_ID", event.TraceID)
+	field("KNTRL_SPAN_ID", event.SpanID)
+	if event.Excluded {
+		field("KNTRL_EXCLUDED", "true")
+	}
+
+	return j.send(entry.Bytes())
+}
+
+// Escalate writes the escalation of the mode as an alert entry
+func (j *Journald) Escalate(e domain.Escalation) error {
+	var entry bytes.Buffer
+	writeJournalField(&entry, "MESSAGE", escalationSummary(e))
+	writeJournalField(&entry, "PRIORITY", strconv.Itoa(severityAlert))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", syslogAppName)
+	writeJournalField(&entry, "KNTRL_ESCALATION_FROM", e.From)
+	writeJournalField(&entry, "KNTRL_ESCALATION_TO", e.To)
+	writeJournalField(&entry, "KNTRL_FINDINGS", strconv.Itoa(len(e.Findings)))
+
+	return j.send(entry.Bytes())
+}
+
+// send writes the entry to the journal socket
+func (j *Journald) send(entry []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.conn.Write(entry); err != nil {
+		return fmt.Errorf("failed to write event to the journal: %w", err)
+	}
+
+	return nil
+}
+
+// Flush does nothing, the events are written as they are handled
+func (j *Journald) Flush(domain.Report) error {
+	return nil
+}
+
+// Close closes the journal socket
+func (j *Journald) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField writes the field as NAME=value, the values with a newline
+// are written as NAME, the little endian 64 bit length and the value
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	b.WriteString(name + "\n")
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b.Write(size[:])
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,243 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// modeMixed is the mode of a merged report of the shards of different modes
+const modeMixed = "mixed"
+
+// Shard is a report of a job of a matrix build
+type Shard struct {
+	Name   string
+	Report domain.Report
+}
+
+// ReadShard reads the report of the argument of kntrl merge, name=path or the
+// path (the file name without the extension is the name). The report is the
+// JSON document (--output-format=json) or the event lines of the table format.
+func ReadShard(arg string) (Shard, error) {
+	var name, path, ok = strings.Cut(arg, "=")
+	if !ok || name == "" {
+		path = arg
+		name = strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Shard{}, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	report, err := parseReport(data)
+	if err != nil {
+		return Shard{}, fmt.Errorf("failed to parse report [%s]: %w", path, err)
+	}
+
+	return Shard{Name: name, Report: report}, nil
+}
+
+// parseReport parses the JSON document of a report, or the event lines
+func parseReport(data []byte) (domain.Report, error) {
+	var report domain.Report
+	if err := json.Unmarshal(data, &report); err == nil && (report.Events != nil || !report.StartedAt.IsZero()) {
+		return report, nil
+	}
+
+	report = domain.Report{}
+	var scanner = bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var line = bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event domain.ReportEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return domain.Report{}, err
+		}
+		report.Events = append(report.Events, event)
+
+		if report.StartedAt.IsZero() || event.Timestamp.Before(report.StartedAt) {
+			report.StartedAt = event.Timestamp
+		}
+		if event.Timestamp.After(report.FinishedAt) {
+			report.FinishedAt = event.Timestamp
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return domain.Report{}, err
+	}
+	report.Summary = summarize(report.Events)
+
+	return report, nil
+}
+
+// Merge returns the consolidated report of the shards. The events are tagged
+// with the name of their shard and sorted by time, the shards keep their own
+// summary. The rule hits and the timeline are added up, a rule is stale if
+// it's stale in all the shards.
+func Merge(shards []Shard) (domain.Report, error) {
+	if len(shards) == 0 {
+		return domain.Report{}, fmt.Errorf("no reports to merge")
+	}
+
+	var merged = domain.Report{Events: make([]domain.ReportEvent, 0)}
+	var names = make(map[string]bool, len(shards))
+	var rules = make(map[string]*domain.RuleHit)
+	var ruleOrder []string
+	var timeline = make(map[int64]*domain.TimeBucket)
+	var stale = make(map[string]domain.StaleRule)
+	var staleCount = make(map[string]int)
+	var allowed = make(map[domain.AllowEntry]bool)
+	var destinations [][]domain.DestinationSummary
+	var suggested bool
+
+	for i, shard := range shards {
+		if names[shard.Name] {
+			return domain.Report{}, fmt.Errorf("duplicate report name [%s], use name=path", shard.Name)
+		}
+		names[shard.Name] = true
+
+		var r = shard.Report
+		merged.Shards = append(merged.Shards, domain.ReportShard{
+			Name:       shard.Name,
+			StartedAt:  r.StartedAt,
+			FinishedAt: r.FinishedAt,
+			Mode:       r.Mode,
+			Summary:    summarize(r.Events),
+		})
+
+		if merged.StartedAt.IsZero() || (!r.StartedAt.IsZero() && r.StartedAt.Before(merged.StartedAt)) {
+			merged.StartedAt = r.StartedAt
+		}
+		if r.FinishedAt.After(merged.FinishedAt) {
+			merged.FinishedAt = r.FinishedAt
+		}
+		switch {
+		case i == 0:
+			merged.Mode = r.Mode
+		case merged.Mode != r.Mode:
+			merged.Mode = modeMixed
+		}
+
+		for _, e := range r.Events {
+			e.Shard = shard.Name
+			merged.Events = append(merged.Events, e)
+		}
+		// the connections of the same destination and process in the shards are added up
+		if r.Destinations != nil {
+			destinations = append(destinations, r.Destinations)
+		} else {
+			destinations = append(destinations, AggregateDestinations(r.Events))
+		}
+
+		for _, entry := range r.Allowed {
+			if !allowed[entry] {
+				allowed[entry] = true
+				merged.Allowed = append(merged.Allowed, entry)
+			}
+		}
+
+		merged.Diagnostics = append(merged.Diagnostics, r.Diagnostics...)
+		merged.Findings = append(merged.Findings, r.Findings...)
+		// the first escalation of the shards
+		if e := r.Escalation; e != nil && (merged.Escalation == nil || e.Timestamp.Before(merged.Escalation.Timestamp)) {
+			merged.Escalation = e
+		}
+		merged.Listeners = append(merged.Listeners, r.Listeners...)
+		merged.DNS = append(merged.DNS, r.DNS...)
+		suggested = suggested || len(r.Suggestions) > 0
+
+		for _, hit := range r.Rules {
+			if prev, ok := rules[hit.Rule]; ok {
+				prev.Allowed += hit.Allowed
+				prev.Denied += hit.Denied
+				continue
+			}
+			var h = hit
+			rules[hit.Rule] = &h
+			ruleOrder = append(ruleOrder, hit.Rule)
+		}
+
+		for _, b := range r.Timeline {
+			var minute = b.Start.Unix() / 60
+			bucket, ok := timeline[minute]
+			if !ok {
+				bucket = &domain.TimeBucket{Start: b.Start}
+				timeline[minute] = bucket
+			}
+			bucket.Total += b.Total
+			bucket.Pass += b.Pass
+			bucket.Block += b.Block
+			bucket.WouldBlock += b.WouldBlock
+		}
+
+		for _, s := range r.Hygiene {
+			staleCount[s.Rule]++
+			if prev, ok := stale[s.Rule]; !ok || s.IdleRuns < prev.IdleRuns {
+				stale[s.Rule] = s
+			}
+		}
+	}
+
+	sort.SliceStable(merged.Events, func(i, j int) bool {
+		return merged.Events[i].Timestamp.Before(merged.Events[j].Timestamp)
+	})
+	merged.Summary = summarize(merged.Events)
+	merged.Destinations = mergeDestinations(destinations...)
+	// the reports of the hashed destinations have no suggestions
+	if suggested {
+		merged.Suggestions = SuggestPolicy(merged.Events)
+	}
+
+	for _, rule := range ruleOrder {
+		merged.Rules = append(merged.Rules, *rules[rule])
+	}
+
+	for _, bucket := range timeline {
+		merged.Timeline = append(merged.Timeline, *bucket)
+	}
+	sort.Slice(merged.Timeline, func(i, j int) bool { return merged.Timeline[i].Start.Before(merged.Timeline[j].Start) })
+
+	for rule, s := range stale {
+		if staleCount[rule] == len(shards) {
+			merged.Hygiene = append(merged.Hygiene, s)
+		}
+	}
+	sort.Slice(merged.Hygiene, func(i, j int) bool { return merged.Hygiene[i].Rule < merged.Hygiene[j].Rule })
+
+	return merged, nil
+}
+
+// WriteReport writes the report as a JSON or SARIF document
+func WriteReport(w io.Writer, format string, report domain.Report) error {
+	var v any
+	switch format {
+	case FormatJSON:
+		v = report
+	case FormatSARIF:
+		v = toSARIF(report)
+	default:
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
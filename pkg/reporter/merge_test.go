@@ -0,0 +1,109 @@
+package reporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestMerge(t *testing.T) {
+	var start = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	var linux = domain.Report{
+		StartedAt:  start,
+		FinishedAt: start.Add(2 * time.Minute),
+		Mode:       "trace",
+		Events: []domain.ReportEvent{
+			{DestinationAddress: "140.82.114.22", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start.Add(time.Minute)},
+			{DestinationAddress: "10.0.0.1", DestinationPort: 80, Policy: domain.EventPolicyStatusBlock, Timestamp: start.Add(90 * time.Second)},
+		},
+		Rules:    []domain.RuleHit{{Rule: "allowed-hosts=.github.com", Allowed: 1}},
+		Timeline: []domain.TimeBucket{{Start: start.Add(time.Minute), Total: 2, Pass: 1, Block: 1}},
+		Hygiene:  []domain.StaleRule{{Rule: "allowed-ips=1.1.1.1", IdleRuns: 3}, {Rule: "allowed-hosts=.npmjs.org", IdleRuns: 5}},
+	}
+	var macos = domain.Report{
+		StartedAt:  start.Add(-time.Minute),
+		FinishedAt: start.Add(time.Minute),
+		Mode:       "monitor",
+		Events: []domain.ReportEvent{
+			{DestinationAddress: "140.82.114.22", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start},
+		},
+		Rules:    []domain.RuleHit{{Rule: "allowed-hosts=.github.com", Allowed: 1}},
+		Timeline: []domain.TimeBucket{{Start: start.Add(time.Minute), Total: 1, Pass: 1}},
+		Hygiene:  []domain.StaleRule{{Rule: "allowed-ips=1.1.1.1", IdleRuns: 2}},
+	}
+
+	report, err := Merge([]Shard{{Name: "linux", Report: linux}, {Name: "macos", Report: macos}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !report.StartedAt.Equal(macos.StartedAt) || !report.FinishedAt.Equal(linux.FinishedAt) {
+		t.Errorf("unexpected time range %s - %s", report.StartedAt, report.FinishedAt)
+	}
+	if report.Mode != modeMixed {
+		t.Errorf("expected mode %s, got %s", modeMixed, report.Mode)
+	}
+	if report.Summary.Total != 3 || report.Summary.Pass != 2 || report.Summary.Block != 1 {
+		t.Errorf("unexpected summary %+v", report.Summary)
+	}
+	if len(report.Events) != 3 || report.Events[0].Shard != "macos" || report.Events[2].Shard != "linux" {
+		t.Errorf("expected the events of the shards in order, got %+v", report.Events)
+	}
+	if len(report.Shards) != 2 || report.Shards[0].Name != "linux" || report.Shards[0].Summary.Block != 1 {
+		t.Errorf("unexpected shards %+v", report.Shards)
+	}
+	if len(report.Rules) != 1 || report.Rules[0].Allowed != 2 {
+		t.Errorf("expected the rule hits to be added up, got %+v", report.Rules)
+	}
+	if len(report.Timeline) != 1 || report.Timeline[0].Total != 3 {
+		t.Errorf("expected the timeline to be added up, got %+v", report.Timeline)
+	}
+	if len(report.Hygiene) != 1 || report.Hygiene[0].IdleRuns != 2 {
+		t.Errorf("expected the rules stale in all the shards, got %+v", report.Hygiene)
+	}
+
+	if _, err := Merge([]Shard{{Name: "linux", Report: linux}, {Name: "linux", Report: macos}}); err == nil {
+		t.Errorf("expected an error for the duplicate names")
+	}
+}
+
+func TestReadShard(t *testing.T) {
+	var dir = t.TempDir()
+	var ts = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	doc, err := json.Marshal(domain.Report{StartedAt: ts, Mode: "trace", Events: []domain.ReportEvent{{DestinationAddress: "10.0.0.1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.json"), doc, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := json.Marshal(domain.ReportEvent{DestinationAddress: "10.0.0.2", Policy: domain.EventPolicyStatusBlock, Timestamp: ts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kntrl.out"), append(append(line, '\n'), line...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	shard, err := ReadShard(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shard.Name != "report" || shard.Report.Mode != "trace" || len(shard.Report.Events) != 1 {
+		t.Errorf("unexpected shard %+v", shard)
+	}
+
+	shard, err = ReadShard("ubuntu=" + filepath.Join(dir, "kntrl.out"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shard.Name != "ubuntu" || len(shard.Report.Events) != 2 || shard.Report.Summary.Block != 2 || !shard.Report.StartedAt.Equal(ts) {
+		t.Errorf("unexpected shard %+v", shard)
+	}
+}
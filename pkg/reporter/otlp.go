@@ -0,0 +1,500 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	otlpDefaultPort = "4318"
+	otlpScope       = "github.com/kondukto-io/kntrl"
+	// otlpMetric is the counter of the connections by the process, the destination and the policy decision
+	otlpMetric = "kntrl.egress.connections"
+	// otlpHeadersEnv are the headers of the export requests (key1=value1,key2=value2),
+	// the authentication of the hosted backends
+	otlpHeadersEnv = "OTEL_EXPORTER_OTLP_HEADERS"
+
+	otlpInterval = 5 * time.Second
+	// otlpBatchSize is the number of the spans that triggers an export before the interval
+	otlpBatchSize = 512
+	// otlpMaxSpans is the number of the spans kept while the backend is unreachable,
+	// the oldest are dropped
+	otlpMaxSpans = 8192
+	// otlpMaxSeries is the number of the metric series, the rest are counted in the overflow series
+	otlpMaxSeries = 2000
+	otlpTimeout   = 10 * time.Second
+
+	// span kind and status codes of the OTLP protocol
+	otlpSpanKindClient = 3
+	otlpStatusError    = 2
+	// otlpCumulative is the aggregation temporality of the counter
+	otlpCumulative = 2
+)
+
+// OTLP exports the events to an OpenTelemetry collector with the OTLP/HTTP
+// protocol (JSON encoding). Each event is a span of the connection and is
+// counted by the kntrl.egress.connections metric, the events are exported in
+// the background every 5 seconds.
+type OTLP struct {
+	tracesURL  string
+	metricsURL string
+	headers    map[string]string
+	client     *http.Client
+	resource   otlpResource
+	startedAt  time.Time
+
+	mu     sync.Mutex
+	spans  []otlpSpan
+	series map[string]*otlpDataPoint
+	// flush triggers an export of a full batch
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpInstrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt"`
+	count             int64
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpInstrumentationScope `json:"scope"`
+	Spans []otlpSpan               `json:"spans"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpMetricData struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Unit        string  `json:"unit"`
+	Sum         otlpSum `json:"sum"`
+}
+
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpInstrumentationScope `json:"scope"`
+	Metrics []otlpMetricData         `json:"metrics"`
+}
+
+// newOTLP returns the exporter of the collector URL, otlp://host[:4318][/prefix]
+// over HTTP or otlp+https://host[:4318][/prefix] over HTTPS
+func newOTLP(scheme string, u *url.URL) (*OTLP, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid otlp sink [%s]: missing host", u.String())
+	}
+
+	var host = u.Host
+	if u.Port() == "" {
+		host += ":" + otlpDefaultPort
+	}
+	var base = scheme + "://" + host + strings.TrimRight(u.Path, "/")
+
+	headers, err := parseOTLPHeaders(os.Getenv(otlpHeadersEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+
+	var o = &OTLP{
+		tracesURL:  base + "/v1/traces",
+		metricsURL: base + "/v1/metrics",
+		headers:    headers,
+		client:     &http.Client{Timeout: otlpTimeout},
+		resource: otlpResource{Attributes: []otlpAttribute{
+			stringAttribute("service.name", "kntrl"),
+			stringAttribute("host.name", hostname),
+		}},
+		startedAt: time.Now(),
+		series:    make(map[string]*otlpDataPoint),
+		flush:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	o.wg.Add(1)
+	go o.run()
+
+	return o, nil
+}
+
+// Name returns the name of the sink
+func (o *OTLP) Name() string {
+	return "otlp"
+}
+
+// Write adds the span of the event to the batch and counts the connection
+func (o *OTLP) Write(event domain.ReportEvent) error {
+	var span = otlpSpan{
+		TraceID:           event.TraceID,
+		ParentSpanID:      event.SpanID,
+		SpanID:            randomHex(8),
+		Name:              "egress " + event.Protocol + " " + domainOf(event),
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: unixNano(event.Timestamp),
+		EndTimeUnixNano:   unixNano(event.Timestamp),
+		Attributes:        spanAttributes(event),
+	}
+	// the connection is a child of the pipeline span that made it if the trace context is known
+	if span.TraceID == "" {
+		span.TraceID = randomHex(16)
+		span.ParentSpanID = ""
+	}
+	if event.Policy == domain.EventPolicyStatusBlock {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: "blocked by the egress policy"}
+	}
+	if event.Policy == domain.EventPolicyStatusTarpit {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: "delayed by the egress policy (tarpit)"}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.spans) >= otlpMaxSpans {
+		o.spans = o.spans[1:]
+	}
+	o.spans = append(o.spans, span)
+	o.count(event)
+
+	if len(o.spans) >= otlpBatchSize {
+		select {
+		case o.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// count increments the counter of the series of the event, must be called with the lock held
+func (o *OTLP) count(event domain.ReportEvent) {
+	var attributes = []otlpAttribute{
+		stringAttribute("process.executable.name", event.TaskName),
+		stringAttribute("server.address", domainOf(event)),
+		intAttribute("server.port", int64(event.DestinationPort)),
+		stringAttribute("network.transport", event.Protocol),
+		stringAttribute("kntrl.policy", event.Policy),
+	}
+	var key = fmt.Sprintf("%s|%s|%d|%s|%s", event.TaskName, domainOf(event), event.DestinationPort, event.Protocol, event.Policy)
+
+	point, ok := o.series[key]
+	if !ok && len(o.series) >= otlpMaxSeries {
+		key = "overflow"
+		point, ok = o.series[key]
+		attributes = []otlpAttribute{boolAttribute("otel.metric.overflow", true)}
+	}
+	if !ok {
+		point = &otlpDataPoint{Attributes: attributes, StartTimeUnixNano: unixNano(o.startedAt)}
+		o.series[key] = point
+	}
+	point.count++
+}
+
+// run exports the batches until the exporter is closed
+func (o *OTLP) run() {
+	defer o.wg.Done()
+
+	var ticker = time.NewTicker(otlpInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.done:
+			o.export()
+			return
+		case <-ticker.C:
+		case <-o.flush:
+		}
+		o.export()
+	}
+}
+
+// export sends the spans of the batch and the counters, the spans are kept
+// for the next export if the collector is unreachable
+func (o *OTLP) export() {
+	o.mu.Lock()
+	var spans = o.spans
+	o.spans = nil
+	var points = make([]otlpDataPoint, 0, len(o.series))
+	var now = unixNano(time.Now())
+	for _, point := range o.series {
+		p := *point
+		p.TimeUnixNano = now
+		p.AsInt = strconv.FormatInt(point.count, 10)
+		points = append(points, p)
+	}
+	o.mu.Unlock()
+
+	if len(spans) > 0 {
+		if err := o.post(o.tracesURL, o.traces(spans)); err != nil {
+			logger.Log.Errorf("failed to export spans: %v", err)
+			o.requeue(spans)
+		}
+	}
+	if len(points) > 0 {
+		if err := o.post(o.metricsURL, o.metrics(points)); err != nil {
+			logger.Log.Errorf("failed to export metrics: %v", err)
+		}
+	}
+}
+
+// requeue puts the spans of a failed export back in front of the batch
+func (o *OTLP) requeue(spans []otlpSpan) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spans = append(spans, o.spans...)
+	if len(spans) > otlpMaxSpans {
+		spans = spans[len(spans)-otlpMaxSpans:]
+	}
+	o.spans = spans
+}
+
+func (o *OTLP) traces(spans []otlpSpan) otlpTraces {
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: o.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpInstrumentationScope{Name: otlpScope},
+			Spans: spans,
+		}},
+	}}}
+}
+
+func (o *OTLP) metrics(points []otlpDataPoint) otlpMetrics {
+	return otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: o.resource,
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope: otlpInstrumentationScope{Name: otlpScope},
+			Metrics: []otlpMetricData{{
+				Name:        otlpMetric,
+				Description: "Egress connections by process, destination and policy decision",
+				Unit:        "{connection}",
+				Sum: otlpSum{
+					DataPoints:             points,
+					AggregationTemporality: otlpCumulative,
+					IsMonotonic:            true,
+				},
+			}},
+		}},
+	}}}
+}
+
+func (o *OTLP) post(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+// Flush does nothing, the last batch is exported by Close
+func (o *OTLP) Flush(domain.Report) error {
+	return nil
+}
+
+// Close exports the last batch and stops the exporter
+func (o *OTLP) Close() error {
+	close(o.done)
+	o.wg.Wait()
+
+	return nil
+}
+
+// spanAttributes are the process and the destination attributes of the span,
+// the OpenTelemetry semantic conventions where they exist
+func spanAttributes(event domain.ReportEvent) []otlpAttribute {
+	var attributes = []otlpAttribute{
+		intAttribute("process.pid", int64(event.ProcessID)),
+		stringAttribute("process.executable.name", event.TaskName),
+		stringAttribute("network.transport", event.Protocol),
+		stringAttribute("network.peer.address", event.DestinationAddress),
+		intAttribute("network.peer.port", int64(event.DestinationPort)),
+		stringAttribute("server.address", domainOf(event)),
+		stringAttribute("kntrl.policy", event.Policy),
+	}
+
+	optional := func(key, value string) {
+		if value != "" {
+			attributes = append(attributes, stringAttribute(key, value))
+		}
+	}
+	optional("process.executable.path", event.Executable)
+	if len(event.Args) > 0 {
+		optional("process.command_line", formatCommand(event))
+	}
+	optional("tls.server.name", event.ServerName)
+	optional("kntrl.container", event.Container)
+	optional("kntrl.sandbox", event.Sandbox)
+	optional("kntrl.verdict", event.Verdict)
+	if event.Reputation != nil {
+		attributes = append(attributes, intAttribute("kntrl.reputation", int64(event.Reputation.Score)))
+	}
+	if id := event.Identity; id != nil {
+		attributes = append(attributes,
+			intAttribute("process.user.id", int64(id.UID)),
+			intAttribute("process.group.id", int64(id.GID)),
+			intAttribute("kntrl.cgroup_id", int64(id.CgroupID)),
+			intAttribute("kntrl.netns", int64(id.NetNS)),
+		)
+		optional("process.user.name", id.User)
+	}
+	if event.Excluded {
+		attributes = append(attributes, boolAttribute("kntrl.excluded", true))
+	}
+	if event.WouldBlock {
+		attributes = append(attributes, boolAttribute("kntrl.would_block", true))
+	}
+	if event.Killed {
+		attributes = append(attributes, boolAttribute("kntrl.killed", true))
+	}
+
+	return attributes
+}
+
+// parseOTLPHeaders parses the headers of OTEL_EXPORTER_OTLP_HEADERS, the
+// values are URL encoded
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	var headers = make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid %s: %q", otlpHeadersEnv, pair)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q: %w", otlpHeadersEnv, pair, err)
+		}
+		headers[strings.TrimSpace(k)] = decoded
+	}
+
+	return headers, nil
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: &value}}
+}
+
+// intAttribute is encoded as a string, the int64 values of OTLP/JSON are strings
+func intAttribute(key string, value int64) otlpAttribute {
+	var s = strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpAttributeValue{IntValue: &s}}
+}
+
+func boolAttribute(key string, value bool) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttributeValue{BoolValue: &value}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomHex returns n random bytes as hex, the trace and the span ids
+func randomHex(n int) string {
+	var b = make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
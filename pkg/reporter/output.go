@@ -0,0 +1,166 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// Output writes the report in a format to a file or to the standard output
+// ("-"): the events as JSON lines and the tables at the end (table), the
+// access log lines (access-log) or a document at the end (json, sarif).
+type Output struct {
+	path   string
+	format string
+	// verbose prints the events in the table format, see Reporter.SetVerbose
+	verbose bool
+	mu      sync.Mutex
+	file    *os.File
+}
+
+// NewOutput returns the output of the report file in the format
+func NewOutput(path, format string) (*Output, error) {
+	if !IsValidFormat(format) {
+		return nil, fmt.Errorf("invalid output format: %s", format)
+	}
+
+	var o = &Output{path: path, format: format}
+	file, err := o.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report file: %w", err)
+	}
+	o.file = file
+
+	return o, nil
+}
+
+// NewOutputSink returns the output of the --output flag: a report output as
+// format[=path] (the standard output by default) or an event sink URL, see
+// NewSink
+func NewOutputSink(spec string) (Sink, error) {
+	if strings.Contains(spec, "://") {
+		return NewSink(spec)
+	}
+
+	var format, path, ok = strings.Cut(spec, "=")
+	if !ok || path == "" {
+		path = stdoutFileName
+	}
+
+	return NewOutput(path, format)
+}
+
+// Name is the name of the output, its format
+func (o *Output) Name() string {
+	return o.format
+}
+
+// Write writes the line of the event, the documents are written at once when
+// the report is flushed
+func (o *Output) Write(event domain.ReportEvent) error {
+	if isDocumentFormat(o.format) {
+		return nil
+	}
+
+	var line string
+	switch o.format {
+	case FormatAccessLog:
+		line = formatAccessLog(event)
+
+	default:
+		eventData, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		line = string(eventData)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.file.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("failed to write an event to file: %s %w", o.file.Name(), err)
+	}
+
+	return nil
+}
+
+// Flush writes the report document, the tables are printed to the standard
+// output with the table format
+func (o *Output) Flush(report domain.Report) error {
+	switch o.format {
+	case FormatJSON:
+		return o.writeDocument(report)
+
+	case FormatSARIF:
+		return o.writeDocument(toSARIF(report))
+
+	case FormatAccessLog:
+		// lines are written as the events arrive
+
+	default:
+		PrintTable(report, o.verbose)
+	}
+
+	return nil
+}
+
+func (o *Output) writeDocument(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write report to file: %s %w", o.file.Name(), err)
+	}
+
+	return nil
+}
+
+// Close closes the report file
+func (o *Output) Close() error {
+	if o.file == os.Stdout {
+		return nil
+	}
+
+	return o.file.Close()
+}
+
+func (o *Output) open() (*os.File, error) {
+	if o.path == stdoutFileName {
+		return os.Stdout, nil
+	}
+
+	// the document replaces the previous report, events are appended otherwise
+	var flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	if isDocumentFormat(o.format) {
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(o.path, flag, 0666)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat output file: %w", err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(o.path), os.ModePerm); err != nil && !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		file, err = os.OpenFile(o.path, flag, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+
+	return file, nil
+}
@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestNewOutputSink(t *testing.T) {
+	var dir = t.TempDir()
+
+	for spec, want := range map[string]string{
+		"table": FormatTable,
+		"json=" + filepath.Join(dir, "kntrl.json"):     FormatJSON,
+		"sarif=" + filepath.Join(dir, "a/kntrl.sarif"): FormatSARIF,
+		"access-log=-":                    FormatAccessLog,
+		"https://hooks.example.com/kntrl": "webhook",
+	} {
+		sink, err := NewOutputSink(spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", spec, err)
+			continue
+		}
+		if sink.Name() != want {
+			t.Errorf("%s: expected %s, got %s", spec, want, sink.Name())
+		}
+		sink.Close()
+	}
+
+	for _, spec := range []string{"xml", "yaml=kntrl.yaml", "ftp://example.com"} {
+		if _, err := NewOutputSink(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestReporter_Outputs(t *testing.T) {
+	var dir = t.TempDir()
+	var jsonFile, logFile = filepath.Join(dir, "kntrl.json"), filepath.Join(dir, "access.log")
+
+	var outputs []Sink
+	for _, spec := range []string{"json=" + jsonFile, "access-log=" + logFile} {
+		output, err := NewOutputSink(spec)
+		if err != nil {
+			t.Fatalf("failed to create output: %v", err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	report := NewReporterWithOutputs(outputs...)
+	var event = domain.ReportEvent{ProcessID: 1, TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass}
+	report.WriteEvent(event)
+	report.WriteEvent(event)
+	if err := report.Flush(); err != nil {
+		t.Fatalf("failed to flush report: %v", err)
+	}
+	report.Close()
+
+	data, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written domain.Report
+	if err := json.Unmarshal(data, &written); err != nil || len(written.Events) != 1 {
+		t.Errorf("unexpected report: %s (%v)", data, err)
+	}
+
+	data, err = os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "1.1.1.1:443") {
+		t.Errorf("unexpected access log: %s", data)
+	}
+}
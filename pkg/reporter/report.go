@@ -3,56 +3,152 @@ package reporter
 import (
 	"crypto/md5"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pterm/pterm"
 
 	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/clock"
 	"github.com/kondukto-io/kntrl/pkg/logger"
 )
 
+const (
+	// FormatTable writes events as JSON lines and prints a table at the end (default)
+	FormatTable = "table"
+
+	// FormatJSON writes a single machine-readable JSON document at the end
+	FormatJSON = "json"
+
+	// FormatSARIF writes the blocked events as a SARIF log at the end
+	FormatSARIF = "sarif"
+
+	// FormatAccessLog writes events as access log lines
+	FormatAccessLog = "access-log"
+
+	// stdoutFileName writes the report to the standard output
+	stdoutFileName = "-"
+)
+
 // Reporter is a reporter for events
 type Reporter struct {
-	events         []domain.ReportEvent
-	eventsHashMap  map[string]bool
+	mu            sync.Mutex
+	events        []domain.ReportEvent
+	eventsHashMap map[string]bool
+	// cookies are the indexes of the events of the open TCP connections
+	cookies        map[uint64]int
 	Err            error
 	outputFileName string
-	file           *os.File
+	outputs        []Sink
+	mode           string
+	startedAt      time.Time
+	clock          *clock.Clock
+	allowed        func() []domain.AllowEntry
+	diagnostics    func() []domain.Diagnostic
+	traffic        TrafficFunc
+	rules          func() []domain.RuleHit
+	hygiene        []domain.StaleRule
+	escalation     *domain.Escalation
+	findings       func() []domain.Finding
+	listeners      func() []domain.Listener
+	dns            func() []domain.DNSQuery
+	maps           func() []domain.MapStats
+	programs       func() []domain.ProgramStats
+	// timeline are the connections of each minute by the unix minute
+	timeline map[int64]*domain.TimeBucket
+	// destinations are the connections by the destination and the process
+	destinations destinationTable
+	// verbose prints the events of the destinations in the table format
+	verbose bool
 }
 
+// TrafficFunc returns the bytes sent and received to the destination
+type TrafficFunc func(address string, port uint16) (sent, received uint64)
+
 // NewReporter returns a new reporter
 func NewReporter(outputFileName string) *Reporter {
+	return NewReporterWithFormat(outputFileName, FormatTable)
+}
+
+// NewReporterWithFormat returns a new reporter with the given output format.
+// The output file name "-" writes the report to stdout.
+func NewReporterWithFormat(outputFileName, format string) *Reporter {
 	if outputFileName == "" {
 		outputFileName = "/tmp/kntrl.out"
 		logger.Log.Debugf("using the default output file: %s", outputFileName)
 	}
 
-	var report = &Reporter{
-		eventsHashMap:  make(map[string]bool, 0),
-		outputFileName: outputFileName,
-	}
+	var report = NewReporterWithOutputs()
+	report.outputFileName = outputFileName
 
-	file, err := report.openReportFile()
+	output, err := NewOutput(outputFileName, format)
 	if err != nil {
-		report.Err = fmt.Errorf("failed to open report file: %w", err)
+		report.Err = err
 		return report
 	}
-
-	report.file = file
+	report.outputs = append(report.outputs, output)
 
 	return report
 }
 
-// WriteEvent adds an event to the report file
+// NewReporterWithOutputs returns a new reporter writing to the outputs, the
+// report files (Output) or any sink getting the first event of each
+// destination and the report
+func NewReporterWithOutputs(outputs ...Sink) *Reporter {
+	return &Reporter{
+		eventsHashMap: make(map[string]bool, 0),
+		cookies:       make(map[uint64]int),
+		timeline:      make(map[int64]*domain.TimeBucket),
+		destinations:  make(destinationTable),
+		outputs:       outputs,
+		startedAt:     time.Now(),
+	}
+}
+
+// AddOutput adds an output of the report
+func (r *Reporter) AddOutput(output Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outputs = append(r.outputs, output)
+	if o, ok := output.(*Output); ok {
+		o.verbose = r.verbose
+	}
+}
+
+// SetVerbose prints the first connection event of each destination in the
+// table format, the connections are printed aggregated by the destination and
+// the process otherwise
+func (r *Reporter) SetVerbose(verbose bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.verbose = verbose
+	for _, output := range r.outputs {
+		if o, ok := output.(*Output); ok {
+			o.verbose = verbose
+		}
+	}
+}
+
+// WriteEvent adds an event to the report, the first event of a destination
+// is written to the outputs
 func (r *Reporter) WriteEvent(event domain.ReportEvent) {
 	var address = event.DestinationAddress + ":" + fmt.Sprint(event.DestinationPort)
 	var hash = hash(address)
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the repeated connections are counted in the timeline and in the destinations
+	r.count(event)
+
 	if _, ok := r.eventsHashMap[hash]; ok {
 		logger.Log.Debugf("event with address [%s] already exists", address)
 		return
@@ -60,66 +156,773 @@ func (r *Reporter) WriteEvent(event domain.ReportEvent) {
 
 	r.events = append(r.events, event)
 	r.eventsHashMap[hash] = true
+	if event.Cookie != 0 {
+		r.cookies[event.Cookie] = len(r.events) - 1
+	}
 
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		log.Fatalf("failed to marshal: %v", err)
+	for _, output := range r.outputs {
+		if err := output.Write(event); err != nil {
+			logger.Log.Errorf("%v", err)
+		}
 	}
+}
 
-	//println(string(eventData)) //
-	_, err = r.file.WriteString(string(eventData) + "\n")
-	if err != nil {
-		log.Fatalf("failed to write an event to file: %s %v", r.file.Name(), err)
+// CloseEvent sets the duration and the last TCP state of the reported
+// connection, the connections of the duplicate events are ignored
+func (r *Reporter) CloseEvent(cookie uint64, duration time.Duration, state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.cookies[cookie]
+	if !ok {
+		return
 	}
+	delete(r.cookies, cookie)
+
+	r.events[i].DurationMs = duration.Milliseconds()
+	r.events[i].State = state
 }
 
-// Close closes the report file
-func (r *Reporter) Close() {
-	if err := r.file.Close(); err != nil {
-		log.Fatalf("failed to close file: %v", err)
+// AddServerName adds the server name (SNI) of the TLS handshake or the HTTP
+// Host header to the reported event of the destination, the handshake (or
+// the request) follows the connect event.
+// The lines of the access-log and the jsonl outputs are already written.
+func (r *Reporter) AddServerName(address string, port uint16, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.events {
+		var e = &r.events[i]
+		if e.DestinationAddress != address || e.DestinationPort != port {
+			continue
+		}
+		if e.ServerName != "" {
+			return
+		}
+
+		e.ServerName = name
+		// the placeholder of the failed reverse lookups is replaced
+		var domains = make([]string, 0, len(e.Domains)+1)
+		for _, d := range e.Domains {
+			if d != "." && d != name {
+				domains = append(domains, d)
+			}
+		}
+		e.Domains = append(domains, name)
+
+		return
 	}
 }
 
-func (r *Reporter) openReportFile() (*os.File, error) {
-	file, err := os.OpenFile(r.outputFileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to stat output file: %w", err)
+// SetMode sets the tracer mode to be written in the report
+func (r *Reporter) SetMode(mode string) {
+	r.mode = mode
+}
+
+// SetClock sets the time source of the report timestamps
+func (r *Reporter) SetClock(c *clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clock = c
+	r.startedAt = c.Now()
+}
+
+// SetAllowed sets the source of the allow list entries written in the report
+func (r *Reporter) SetAllowed(allowed func() []domain.AllowEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.allowed = allowed
+}
+
+// SetDiagnostics sets the source of the self-diagnostic findings written in the report
+func (r *Reporter) SetDiagnostics(diagnostics func() []domain.Diagnostic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.diagnostics = diagnostics
+}
+
+// SetFindings sets the source of the findings (credential read then egress) written in the report
+func (r *Reporter) SetFindings(findings func() []domain.Finding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.findings = findings
+}
+
+// SetListeners sets the source of the sockets listening on a non-loopback address written in the report
+func (r *Reporter) SetListeners(listeners func() []domain.Listener) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.listeners = listeners
+}
+
+// SetDNS sets the source of the DNS queries written in the report
+func (r *Reporter) SetDNS(dns func() []domain.DNSQuery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dns = dns
+}
+
+// SetMapStats sets the sources of the usage of the eBPF maps and the runs of the programs written in the report
+func (r *Reporter) SetMapStats(maps func() []domain.MapStats, programs func() []domain.ProgramStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maps = maps
+	r.programs = programs
+}
+
+// SetTraffic sets the source of the traffic counters written in the report
+func (r *Reporter) SetTraffic(traffic TrafficFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.traffic = traffic
+}
+
+// SetRules sets the source of the policy rule hit counters written in the report
+func (r *Reporter) SetRules(rules func() []domain.RuleHit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = rules
+}
+
+// SetHygiene sets the stale policy rules written in the report
+func (r *Reporter) SetHygiene(stale []domain.StaleRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hygiene = stale
+}
+
+// SetEscalation sets the escalation of the mode written in the report
+func (r *Reporter) SetEscalation(escalation *domain.Escalation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.escalation = escalation
+}
+
+// Now returns the current time of the report time source
+func (r *Reporter) Now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+
+	return r.clock.Now()
+}
+
+// Events returns a copy of the collected events
+func (r *Reporter) Events() []domain.ReportEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]domain.ReportEvent, len(r.events))
+	copy(events, r.events)
+
+	return events
+}
+
+// count counts the event in the bucket of its minute and in its destination,
+// must be called with the lock held
+func (r *Reporter) count(event domain.ReportEvent) {
+	var ts = event.Timestamp
+	if ts.IsZero() {
+		ts = r.Now()
+	}
+	r.destinations.add(destinationOf(event, ts))
+
+	var bucket = r.bucket(ts)
+	bucket.Total++
+	switch event.Policy {
+	case domain.EventPolicyStatusPass:
+		bucket.Pass++
+	case domain.EventPolicyStatusBlock:
+		bucket.Block++
+	}
+	if event.WouldBlock {
+		bucket.WouldBlock++
+	}
+}
+
+// bucket returns the bucket of the minute of the time, must be called with the lock held
+func (r *Reporter) bucket(ts time.Time) *domain.TimeBucket {
+	var minute = ts.Unix() / 60
+
+	bucket, ok := r.timeline[minute]
+	if !ok {
+		bucket = &domain.TimeBucket{Start: time.Unix(minute*60, 0).UTC()}
+		r.timeline[minute] = bucket
+	}
+
+	return bucket
+}
+
+// Timeline returns the connections of each minute in order
+func (r *Reporter) Timeline() []domain.TimeBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var timeline = make([]domain.TimeBucket, 0, len(r.timeline))
+	for _, bucket := range r.timeline {
+		timeline = append(timeline, *bucket)
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i].Start.Before(timeline[j].Start) })
+
+	return timeline
+}
+
+// RestoreTimeline adds the connections of each minute of a resumed session
+func (r *Reporter) RestoreTimeline(timeline []domain.TimeBucket) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, prev := range timeline {
+		var bucket = r.bucket(prev.Start)
+		bucket.Total += prev.Total
+		bucket.Pass += prev.Pass
+		bucket.Block += prev.Block
+		bucket.WouldBlock += prev.WouldBlock
+	}
+}
+
+// Restore adds the events of a resumed session to the report.
+// The events are not written to the output file again.
+func (r *Reporter) Restore(startedAt time.Time, events []domain.ReportEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.startedAt = startedAt
+	for _, event := range events {
+		var hash = hash(event.DestinationAddress + ":" + fmt.Sprint(event.DestinationPort))
+		if _, ok := r.eventsHashMap[hash]; ok {
+			continue
 		}
+		// the previous connections are counted in the timeline, the restored
+		// event is the first connection of the destination
+		r.destinations.add(destinationOf(event, event.Timestamp))
+
+		r.events = append(r.events, event)
+		r.eventsHashMap[hash] = true
+	}
+}
+
+// Report returns the machine-readable report of the collected events
+func (r *Reporter) Report() domain.Report {
+	var report = domain.Report{
+		StartedAt:  r.startedAt,
+		FinishedAt: r.Now(),
+		Mode:       r.mode,
+		Events:     r.Events(),
+		Timeline:   r.Timeline(),
+	}
+
+	if r.clock != nil {
+		info := r.clock.Info()
+		report.Clock = &info
+	}
+
+	r.mu.Lock()
+	allowed, diagnostics, traffic, rules, findings, listeners := r.allowed, r.diagnostics, r.traffic, r.rules, r.findings, r.listeners
+	maps, programs, dns := r.maps, r.programs, r.dns
+	report.Destinations = r.destinations.list()
+	report.Hygiene = r.hygiene
+	report.Escalation = r.escalation
+	r.mu.Unlock()
+	if allowed != nil {
+		report.Allowed = allowed()
+	}
+	if diagnostics != nil {
+		report.Diagnostics = diagnostics()
+	}
+	if rules != nil {
+		report.Rules = rules()
+	}
+	if findings != nil {
+		report.Findings = findings()
+	}
+	if listeners != nil {
+		report.Listeners = listeners()
+	}
+	if dns != nil {
+		report.DNS = dns()
+	}
+	if maps != nil {
+		report.Maps = maps()
+	}
+	if programs != nil {
+		report.Programs = programs()
+	}
 
-		if err := os.MkdirAll(filepath.Dir(r.outputFileName), os.ModePerm); err != nil && !os.IsExist(err) {
-			return nil, fmt.Errorf("failed to create output directory: %w", err)
+	if traffic != nil {
+		for i, e := range report.Events {
+			report.Events[i].BytesSent, report.Events[i].BytesReceived = traffic(e.DestinationAddress, e.DestinationPort)
 		}
+	}
+	report.Summary = summarize(report.Events)
+	report.Suggestions = SuggestPolicy(report.Events)
+	addDestinationDomains(report.Destinations, report.Events)
 
-		file, err = os.Create(r.outputFileName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create output file: %w", err)
+	return report
+}
+
+// summarize returns the verdict, category and traffic totals of the events
+func summarize(events []domain.ReportEvent) domain.ReportSummary {
+	var summary domain.ReportSummary
+	for _, e := range events {
+		summary.BytesSent += e.BytesSent
+		summary.BytesReceived += e.BytesReceived
+
+		summary.Total++
+		switch e.Policy {
+		case domain.EventPolicyStatusPass:
+			summary.Pass++
+		case domain.EventPolicyStatusBlock:
+			summary.Block++
+		case domain.EventPolicyStatusTarpit:
+			summary.Tarpit++
+		}
+		if e.WouldBlock {
+			summary.WouldBlock++
+		}
+
+		if e.Category != "" {
+			if summary.Categories == nil {
+				summary.Categories = make(map[string]int)
+			}
+			summary.Categories[e.Category]++
 		}
 	}
 
-	return file, nil
+	return summary
+}
+
+// Flush writes the report to the outputs
+func (r *Reporter) Flush() error {
+	var report = r.Report()
+
+	var errs []error
+	for _, output := range r.outputs {
+		if err := output.Flush(report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes the outputs
+func (r *Reporter) Close() {
+	for _, output := range r.outputs {
+		if err := output.Close(); err != nil {
+			logger.Log.Warnf("closing %s output: %s", output.Name(), err)
+		}
+	}
+}
+
+// IsValidFormat returns true if the given output format is supported
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatTable, FormatJSON, FormatSARIF, FormatAccessLog:
+		return true
+	}
+
+	return false
+}
+
+// isDocumentFormat returns true if the report is written as a single document
+func isDocumentFormat(format string) bool {
+	return format == FormatJSON || format == FormatSARIF
 }
 
 func (r *Reporter) PrintReportTable() {
+	PrintTable(r.Report(), r.verbose)
+}
+
+// PrintTable prints the report as tables: the connections aggregated by the
+// destination and the process, followed by the first connection event of
+// each destination with verbose. The allow list is printed if the report has
+// one (an empty allow list included).
+func PrintTable(report domain.Report, verbose bool) {
 	fmt.Print("\n\n")
+	var destinations = report.Destinations
+	if destinations == nil {
+		destinations = AggregateDestinations(report.Events)
+	}
+	printDestinationTable(destinations)
+
+	if verbose {
+		fmt.Print("\n")
+		printEventTable(report.Events)
+	}
+
+	if report.Summary.WouldBlock > 0 {
+		fmt.Printf("\n%d of %d connections would be blocked in the trace mode\n", report.Summary.WouldBlock, report.Summary.Total)
+	}
+
+	if e := report.Escalation; e != nil {
+		fmt.Printf("\nescalated from the %s mode to the %s mode at %s after %d high-severity finding(s)\n",
+			e.From, e.To, e.Timestamp.Format(time.RFC3339), len(e.Findings))
+	}
+
+	if categories := report.Summary.Categories; len(categories) > 0 {
+		fmt.Print("\n")
+		printCategoryTable(categories)
+	}
+
+	if len(report.Timeline) > 0 {
+		fmt.Print("\n")
+		printTimelineTable(report.Timeline)
+	}
+
+	if len(report.Rules) > 0 {
+		fmt.Print("\n")
+		printRuleTable(report.Rules)
+	}
+
+	if len(report.Hygiene) > 0 {
+		fmt.Print("\n")
+		printHygieneTable(report.Hygiene)
+	}
+
+	if len(report.Findings) > 0 {
+		fmt.Print("\n")
+		printFindingTable(report.Findings)
+	}
+
+	if len(report.Listeners) > 0 {
+		fmt.Print("\n")
+		printListenerTable(report.Listeners)
+	}
+
+	if len(report.DNS) > 0 {
+		fmt.Print("\n")
+		printDNSTable(report.DNS)
+	}
+
+	if len(report.Maps) > 0 {
+		fmt.Print("\n")
+		printMapTable(report.Maps)
+	}
+
+	if len(report.Suggestions) > 0 {
+		fmt.Printf("\nsuggested policy additions (--policy-file):\n\n%s", SuggestedPolicy(report.Suggestions))
+	}
+
+	if report.Allowed != nil {
+		fmt.Print("\n")
+		PrintAllowedTable(report.Allowed)
+	}
+}
+
+// printEventTable prints the first connection event of each destination
+func printEventTable(events []domain.ReportEvent) {
 	data := pterm.TableData{
-		{"Pid", "Comm", "Proto", "Domain", "Destination Addr", "Policy"},
+		{"Pid", "Comm", "User", "Parents", "Proto", "Domain", "Destination Addr", "Sent", "Received", "Duration", "State", "Policy", "Verdict"},
 	}
 
-	for _, v := range r.events {
-		res := make([]string, 0, len(v.Domains)+5)
+	for _, v := range events {
+		res := make([]string, 0, len(v.Domains)+10)
 		res = append(res, strconv.FormatUint(uint64(v.ProcessID), 10))
 		res = append(res, v.TaskName)
+		res = append(res, formatUser(v))
+		res = append(res, formatParents(v))
 		res = append(res, v.Protocol)
 		res = append(res, v.Domains...)
 		res = append(res, fmt.Sprintf("%s:%d", v.DestinationAddress, v.DestinationPort))
-		res = append(res, v.Policy)
+		res = append(res, FormatBytes(v.BytesSent))
+		res = append(res, FormatBytes(v.BytesReceived))
+		res = append(res, formatDuration(v))
+		res = append(res, formatState(v))
+		res = append(res, formatPolicy(v))
+		res = append(res, v.Verdict)
 		data = append(data, res)
 	}
 
 	pterm.DefaultTable.WithHasHeader().WithRowSeparator("-").WithHeaderRowSeparator("-").WithData(data).Render()
 }
 
+// PrintAllowedTable prints the allow list entries with their provenance
+func PrintAllowedTable(entries []domain.AllowEntry) {
+	data := pterm.TableData{
+		{"Address", "Source", "Rule", "Added At"},
+	}
+
+	for _, e := range entries {
+		data = append(data, []string{e.Address, e.Source, e.Rule, e.AddedAt.Format(time.RFC3339)})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printCategoryTable prints the event totals of the destination categories
+func printCategoryTable(categories map[string]int) {
+	var names = make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := pterm.TableData{
+		{"Category", "Connections"},
+	}
+	for _, name := range names {
+		data = append(data, []string{name, strconv.Itoa(categories[name])})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// timelineBarWidth is the width of the bar of the busiest minute
+const timelineBarWidth = 40
+
+// printTimelineTable prints the connections of each minute with a bar of the
+// total, the spikes stand out
+func printTimelineTable(timeline []domain.TimeBucket) {
+	var busiest = 1
+	for _, bucket := range timeline {
+		busiest = max(busiest, bucket.Total)
+	}
+
+	data := pterm.TableData{
+		{"Minute", "Connections", "Pass", "Block", "Would Block", ""},
+	}
+	for _, bucket := range timeline {
+		var width = (bucket.Total*timelineBarWidth + busiest - 1) / busiest
+		data = append(data, []string{
+			bucket.Start.Local().Format("15:04"),
+			strconv.Itoa(bucket.Total),
+			strconv.Itoa(bucket.Pass),
+			strconv.Itoa(bucket.Block),
+			strconv.Itoa(bucket.WouldBlock),
+			strings.Repeat("#", width),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printRuleTable prints the connections matched by each policy rule,
+// the rules without a hit are candidates to be removed from the policy
+func printRuleTable(rules []domain.RuleHit) {
+	data := pterm.TableData{
+		{"Rule", "Allowed", "Denied"},
+	}
+	for _, r := range rules {
+		data = append(data, []string{r.Rule, strconv.Itoa(r.Allowed), strconv.Itoa(r.Denied)})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printHygieneTable prints the policy rules without a hit in the last runs
+func printHygieneTable(stale []domain.StaleRule) {
+	data := pterm.TableData{
+		{"Stale Rule", "Idle Runs", "Last Hit"},
+	}
+	for _, s := range stale {
+		var lastHit = "never"
+		if s.LastHit != nil {
+			lastHit = s.LastHit.Format(time.RFC3339)
+		}
+		data = append(data, []string{s.Rule, strconv.Itoa(s.IdleRuns), lastHit})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printFindingTable prints the connections of the processes after the reads
+// of the sensitive files and the anomalies
+func printFindingTable(findings []domain.Finding) {
+	data := pterm.TableData{
+		{"Finding", "Pid", "Task", "Detail", "Destination", "Domains", "Policy"},
+	}
+	for _, f := range findings {
+		data = append(data, []string{
+			f.Kind,
+			strconv.FormatUint(uint64(f.ProcessID), 10),
+			f.TaskName,
+			findingDetail(f),
+			fmt.Sprintf("%s:%d", f.DestinationAddress, f.DestinationPort),
+			strings.Join(f.Domains, ", "),
+			f.Policy,
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// findingDetail returns the files read before the connection or the
+// threshold exceeded by the anomaly
+func findingDetail(f domain.Finding) string {
+	if len(f.Files) > 0 {
+		return strings.Join(f.Files, ", ")
+	}
+
+	return f.Detail
+}
+
+// printListenerTable prints the sockets listening on a non-loopback address
+func printListenerTable(listeners []domain.Listener) {
+	data := pterm.TableData{
+		{"Listener", "Pid", "Task", "Executable", "Opened At"},
+	}
+	for _, l := range listeners {
+		data = append(data, []string{
+			fmt.Sprintf("%s %s", l.Protocol, net.JoinHostPort(l.Address, strconv.Itoa(int(l.Port)))),
+			strconv.FormatUint(uint64(l.ProcessID), 10),
+			l.TaskName,
+			l.Executable,
+			l.Timestamp.Format(time.RFC3339),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printDNSTable prints the names looked up during the run
+func printDNSTable(queries []domain.DNSQuery) {
+	data := pterm.TableData{
+		{"Name", "Type", "Resolver", "Rcode", "Answers", "Count", "Tasks"},
+	}
+	for _, q := range queries {
+		data = append(data, []string{
+			q.Name,
+			q.Type,
+			q.Resolver,
+			q.Rcode,
+			strconv.Itoa(q.Answers),
+			strconv.Itoa(q.Count),
+			strings.Join(q.Tasks, ", "),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// printMapTable prints the usage of the eBPF maps, the maps near their capacity
+// reject or evict the entries
+func printMapTable(maps []domain.MapStats) {
+	data := pterm.TableData{
+		{"Map", "Type", "Entries", "Peak", "Max Entries", "Pressure"},
+	}
+	for _, m := range maps {
+		data = append(data, []string{
+			m.Name,
+			m.Type,
+			strconv.Itoa(m.Entries),
+			strconv.Itoa(m.Peak),
+			strconv.FormatUint(uint64(m.MaxEntries), 10),
+			fmt.Sprintf("%.1f%%", m.Pressure*100),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithHeaderRowSeparator("-").WithData(data).Render()
+}
+
+// formatDuration returns the duration of a closed connection, "-" while it's open
+func formatDuration(e domain.ReportEvent) string {
+	if e.State == "" {
+		return "-"
+	}
+
+	return (time.Duration(e.DurationMs) * time.Millisecond).String()
+}
+
+// formatState returns the last TCP state of a closed connection, "open" while
+// it's open and "-" if the close is not tracked (UDP, kprobe connect probes)
+func formatState(e domain.ReportEvent) string {
+	switch {
+	case e.State != "":
+		return e.State
+	case e.Cookie != 0:
+		return "open"
+	}
+
+	return "-"
+}
+
+// formatPolicy returns the policy status of the event, annotated if the event
+// would be blocked in the trace mode
+// formatCommand returns the executable (the task name if unknown) and the
+// arguments of the process, every node process is "node" by its task name
+func formatCommand(e domain.ReportEvent) string {
+	var command = e.TaskName
+	if e.Executable != "" {
+		command = e.Executable
+	}
+	if len(e.Args) > 1 {
+		command += " " + strings.Join(e.Args[1:], " ")
+	}
+
+	return command
+}
+
+// formatUser returns the user name of the process, the uid if the user is not
+// a user of the host (the users of a container image)
+func formatUser(e domain.ReportEvent) string {
+	if e.Identity == nil {
+		return ""
+	}
+	if e.Identity.User != "" {
+		return e.Identity.User
+	}
+
+	return strconv.FormatUint(uint64(e.Identity.UID), 10)
+}
+
+// tableParents is the number of the closest parents in the table
+const tableParents = 3
+
+// formatParents returns the task names of the closest parents in the spawn
+// order (bash > npm), the full chain is in the JSON report
+func formatParents(e domain.ReportEvent) string {
+	var parents = e.Parents
+	if len(parents) > tableParents {
+		parents = parents[:tableParents]
+	}
+
+	var names = make([]string, 0, len(parents))
+	for i := len(parents) - 1; i >= 0; i-- {
+		names = append(names, parents[i].TaskName)
+	}
+
+	return strings.Join(names, " > ")
+}
+
+func formatPolicy(e domain.ReportEvent) string {
+	if e.WouldBlock {
+		return e.Policy + " (would block)"
+	}
+	if e.Killed {
+		return e.Policy + " (killed)"
+	}
+
+	return e.Policy
+}
+
+// FormatBytes returns the byte count in a human readable form (1.5 MB)
+func FormatBytes(n uint64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	var div, exp = uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
 func hash(text string) string {
 	hasher := md5.New()
 	hasher.Write([]byte(text))
@@ -1,8 +1,13 @@
 package reporter
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/kondukto-io/kntrl/internal/core/domain"
 )
@@ -77,3 +82,327 @@ func TestReporter_WriteEvent(t *testing.T) {
 	report.PrintReportTable()
 	report.Close()
 }
+
+func TestReporter_JSON(t *testing.T) {
+	var outputFileName = "/tmp/d/kntrl.json"
+	defer os.Remove(outputFileName)
+
+	report := NewReporterWithFormat(outputFileName, FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+	report.SetMode(domain.TracerModeTrace)
+
+	report.WriteEvent(domain.ReportEvent{
+		ProcessID:          234,
+		TaskName:           "curl",
+		Protocol:           domain.EventProtocolTCP,
+		DestinationAddress: "1.1.1.1",
+		DestinationPort:    443,
+		Domains:            []string{"one.one.one.one"},
+		Policy:             domain.EventPolicyStatusBlock,
+		Category:           domain.DestinationCategoryCloudProvider,
+	})
+
+	if err := report.Flush(); err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", err)
+	}
+	report.Close()
+
+	data, err := os.ReadFile(outputFileName)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+
+	var out domain.Report
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+
+	if out.Mode != domain.TracerModeTrace {
+		t.Errorf("Expected mode to be '%s', got '%s'", domain.TracerModeTrace, out.Mode)
+	}
+
+	if out.Summary.Total != 1 || out.Summary.Block != 1 {
+		t.Errorf("Expected 1 blocked event, got %+v", out.Summary)
+	}
+
+	if out.Summary.Categories[domain.DestinationCategoryCloudProvider] != 1 {
+		t.Errorf("Expected 1 cloud-provider event, got %+v", out.Summary.Categories)
+	}
+}
+
+func TestReporter_SARIF(t *testing.T) {
+	var outputFileName = "/tmp/d/kntrl.sarif"
+	defer os.Remove(outputFileName)
+
+	report := NewReporterWithFormat(outputFileName, FormatSARIF)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	for i, e := range []domain.ReportEvent{
+		{Policy: domain.EventPolicyStatusPass},
+		{Policy: domain.EventPolicyStatusBlock},
+		{Policy: domain.EventPolicyStatusTarpit},
+		{Policy: domain.EventPolicyStatusPass, WouldBlock: true},
+	} {
+		e.ProcessID = 234
+		e.TaskName = "curl"
+		e.Protocol = domain.EventProtocolTCP
+		e.DestinationAddress = "1.1.1." + fmt.Sprint(i+1)
+		e.DestinationPort = 443
+		report.WriteEvent(e)
+	}
+
+	if err := report.Flush(); err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", err)
+	}
+	report.Close()
+
+	data, err := os.ReadFile(outputFileName)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+
+	var out sarifLog
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+
+	if len(out.Runs) != 1 || len(out.Runs[0].Results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", out.Runs)
+	}
+
+	var expected = []struct{ rule, level string }{
+		{sarifRuleBlockedEgress, sarifLevelError},
+		{sarifRuleTarpittedEgress, sarifLevelWarning},
+		{sarifRuleUnexpectedEgress, sarifLevelWarning},
+	}
+	for i, want := range expected {
+		if got := out.Runs[0].Results[i]; got.RuleID != want.rule || got.Level != want.level {
+			t.Errorf("Expected result %d to be '%s' (%s), got '%s' (%s)", i, want.rule, want.level, got.RuleID, got.Level)
+		}
+	}
+}
+
+func TestReporter_Traffic(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	for _, addr := range []string{"1.1.1.1", "2.2.2.2"} {
+		report.WriteEvent(domain.ReportEvent{DestinationAddress: addr, DestinationPort: 443, Policy: domain.EventPolicyStatusPass})
+	}
+	report.SetTraffic(func(address string, port uint16) (uint64, uint64) {
+		if address == "1.1.1.1" && port == 443 {
+			return 2048, 1_500_000
+		}
+		return 0, 0
+	})
+
+	r := report.Report()
+	if r.Events[0].BytesSent != 2048 || r.Events[0].BytesReceived != 1_500_000 || r.Events[1].BytesSent != 0 {
+		t.Errorf("unexpected event counters: %+v", r.Events)
+	}
+	if r.Summary.BytesSent != 2048 || r.Summary.BytesReceived != 1_500_000 {
+		t.Errorf("unexpected summary: %+v", r.Summary)
+	}
+
+	for n, want := range map[uint64]string{0: "0 B", 999: "999 B", 2048: "2.0 kB", 1_500_000: "1.5 MB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %s, expected %s", n, got, want)
+		}
+	}
+}
+
+func TestReporter_WouldBlock(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+	report.SetMode(domain.TracerModeMonitor)
+
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "140.82.114.22", DestinationPort: 443, Policy: domain.EventPolicyStatusPass})
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "6.6.6.6", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, WouldBlock: true})
+
+	r := report.Report()
+	if r.Summary.Total != 2 || r.Summary.Pass != 2 || r.Summary.Block != 0 || r.Summary.WouldBlock != 1 {
+		t.Errorf("unexpected summary: %+v", r.Summary)
+	}
+
+	if got := formatPolicy(r.Events[1]); got != "pass (would block)" {
+		t.Errorf("unexpected policy column: %s", got)
+	}
+	if got := formatPolicy(r.Events[0]); got != domain.EventPolicyStatusPass {
+		t.Errorf("unexpected policy column: %s", got)
+	}
+}
+
+func TestReporter_Timeline(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// the repeated connections to a destination are counted
+	for i := 0; i < 3; i++ {
+		report.WriteEvent(domain.ReportEvent{DestinationAddress: "104.16.0.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "6.6.6.6", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Timestamp: start.Add(2 * time.Minute)})
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "7.7.7.7", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, WouldBlock: true, Timestamp: start.Add(2*time.Minute + 59*time.Second)})
+
+	// the buckets of a resumed session are merged
+	report.RestoreTimeline([]domain.TimeBucket{{Start: start.Add(time.Minute), Total: 2, Pass: 2}, {Start: start, Total: 1, Block: 1}})
+
+	var expected = []domain.TimeBucket{
+		{Start: start, Total: 4, Pass: 3, Block: 1},
+		{Start: start.Add(time.Minute), Total: 2, Pass: 2},
+		{Start: start.Add(2 * time.Minute), Total: 2, Pass: 1, Block: 1, WouldBlock: 1},
+	}
+	if r := report.Report(); !reflect.DeepEqual(r.Timeline, expected) {
+		t.Errorf("expected %+v, got %+v", expected, r.Timeline)
+	}
+	if r := report.Report(); len(r.Events) != 3 {
+		t.Errorf("expected the events once per destination, got %d", len(r.Events))
+	}
+}
+
+func TestReporter_Destinations(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	report.WriteEvent(domain.ReportEvent{TaskName: "curl", Protocol: "tcp", DestinationAddress: "104.16.0.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start})
+	report.WriteEvent(domain.ReportEvent{TaskName: "wget", Protocol: "tcp", DestinationAddress: "104.16.0.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: start.Add(time.Second)})
+	// the policy of the last connection is kept
+	report.WriteEvent(domain.ReportEvent{TaskName: "curl", Protocol: "tcp", DestinationAddress: "104.16.0.1", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Timestamp: start.Add(time.Minute)})
+	report.AddServerName("104.16.0.1", 443, "registry.npmjs.org")
+
+	var expected = []domain.DestinationSummary{
+		{TaskName: "curl", Protocol: "tcp", DestinationAddress: "104.16.0.1", DestinationPort: 443, Domains: []string{"registry.npmjs.org"}, Connections: 2, Blocked: 1, FirstSeen: start, LastSeen: start.Add(time.Minute), Policy: domain.EventPolicyStatusBlock},
+		{TaskName: "wget", Protocol: "tcp", DestinationAddress: "104.16.0.1", DestinationPort: 443, Domains: []string{"registry.npmjs.org"}, Connections: 1, FirstSeen: start.Add(time.Second), LastSeen: start.Add(time.Second), Policy: domain.EventPolicyStatusPass},
+	}
+	if r := report.Report(); !reflect.DeepEqual(r.Destinations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, r.Destinations)
+	}
+}
+
+func TestReporter_CloseEvent(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "1.1.1.1", DestinationPort: 443, Cookie: 7})
+	// a duplicate event of another connection to the destination
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "1.1.1.1", DestinationPort: 443, Cookie: 8})
+
+	report.CloseEvent(8, time.Second, "ESTABLISHED")
+	if e := report.Events()[0]; e.State != "" || formatState(e) != "open" {
+		t.Errorf("unexpected close of a duplicate event: %+v", e)
+	}
+
+	report.CloseEvent(7, 1500*time.Millisecond, "FIN_WAIT2")
+	e := report.Events()[0]
+	if e.DurationMs != 1500 || e.State != "FIN_WAIT2" || formatDuration(e) != "1.5s" {
+		t.Errorf("unexpected closed event: %+v", e)
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	var testCases = []struct {
+		event    domain.ReportEvent
+		expected string
+	}{
+		{domain.ReportEvent{TaskName: "node"}, "node"},
+		{domain.ReportEvent{TaskName: "node", Executable: "/usr/bin/node"}, "/usr/bin/node"},
+		{domain.ReportEvent{TaskName: "node", Executable: "/usr/bin/node", Args: []string{"node", "node_modules/.bin/jest", "--ci"}}, "/usr/bin/node node_modules/.bin/jest --ci"},
+	}
+
+	for _, tc := range testCases {
+		if got := formatCommand(tc.event); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestFormatParents(t *testing.T) {
+	var e = domain.ReportEvent{Parents: []domain.ProcessAncestor{
+		{TaskName: "npm"}, {TaskName: "sh"}, {TaskName: "bash"}, {TaskName: "Runner.Worker"},
+	}}
+	if got, want := formatParents(e), "bash > sh > npm"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := formatParents(domain.ReportEvent{}); got != "" {
+		t.Errorf("expected no parents, got %q", got)
+	}
+}
+
+func TestFormatUser(t *testing.T) {
+	var tests = []struct {
+		identity *domain.ProcessIdentity
+		want     string
+	}{
+		{&domain.ProcessIdentity{UID: 1001, User: "runner"}, "runner"},
+		{&domain.ProcessIdentity{UID: 1000}, "1000"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := formatUser(domain.ReportEvent{Identity: tt.identity}); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestFormatAccessLog(t *testing.T) {
+	var event = domain.ReportEvent{
+		ProcessID:          2806,
+		TaskName:           ".NET ThreadPool",
+		Protocol:           domain.EventProtocolTCP,
+		DestinationAddress: "140.82.114.22",
+		DestinationPort:    443,
+		Domains:            []string{"lb-140-82-114-22-iad.github.com"},
+		Policy:             domain.EventPolicyStatusPass,
+		Timestamp:          time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	var expected = `[01/Mar/2024:10:00:00 +0000] 2806 ".NET ThreadPool" 140.82.114.22:443 lb-140-82-114-22-iad.github.com pass - -`
+	if got := formatAccessLog(event); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestNewReporterWithFormat_Invalid(t *testing.T) {
+	report := NewReporterWithFormat("/tmp/kntrl.out", "xml")
+	if report.Err == nil {
+		t.Errorf("Expected an error for the invalid format")
+	}
+}
+
+func TestReporter_AddServerName(t *testing.T) {
+	report := NewReporterWithFormat("-", FormatJSON)
+	if report.Err != nil {
+		t.Fatalf("Expected error to be nil, got '%s'", report.Err)
+	}
+
+	report.WriteEvent(domain.ReportEvent{DestinationAddress: "104.16.0.1", DestinationPort: 443, Domains: []string{"."}})
+	report.AddServerName("104.16.0.1", 443, "registry.npmjs.org")
+	// a later handshake of another connection to the destination
+	report.AddServerName("104.16.0.1", 443, "www.npmjs.com")
+	// no event of the destination
+	report.AddServerName("104.16.0.2", 443, "registry.npmjs.org")
+
+	events := report.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected a single event, got %+v", events)
+	}
+	if events[0].ServerName != "registry.npmjs.org" || !slices.Equal(events[0].Domains, []string{"registry.npmjs.org"}) {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+}
@@ -0,0 +1,214 @@
+package reporter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolURI = "https://github.com/kondukto-io/kntrl"
+
+	sarifRuleBlockedEgress    = "kntrl/blocked-egress"
+	sarifRuleTarpittedEgress  = "kntrl/tarpitted-egress"
+	sarifRuleUnexpectedEgress = "kntrl/unexpected-egress"
+	sarifLevelError           = "error"
+	sarifLevelWarning         = "warning"
+
+	// findings are not related to a source file, they are reported
+	// on the workflow file when running in GitHub Actions
+	sarifDefaultLocation = ".github/workflows"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	Help                 sarifMessage       `json:"help"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc"`
+	EndTimeUTC          string `json:"endTimeUtc"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifRules are the rules of the results: the blocked connections are
+// errors, the delayed (tarpit) and the would block (not enforced) ones warnings
+var sarifRules = []sarifRule{
+	{
+		ID:                   sarifRuleBlockedEgress,
+		Name:                 "BlockedEgress",
+		ShortDescription:     sarifMessage{Text: "Egress connection to a destination that is not allowed"},
+		FullDescription:      sarifMessage{Text: "A process in the pipeline connected to a destination that is not allowed by the kntrl policy."},
+		Help:                 sarifMessage{Text: "Review the destination and add it to the allowed hosts or IP addresses if it's expected."},
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevelError},
+	},
+	{
+		ID:                   sarifRuleTarpittedEgress,
+		Name:                 "TarpittedEgress",
+		ShortDescription:     sarifMessage{Text: "Egress connection delayed by the tarpit"},
+		FullDescription:      sarifMessage{Text: "A process in the pipeline connected to a destination that is not allowed by the kntrl policy, the connection was delayed instead of blocked."},
+		Help:                 sarifMessage{Text: "Review the destination and add it to the allowed hosts or IP addresses if it's expected."},
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevelWarning},
+	},
+	{
+		ID:                   sarifRuleUnexpectedEgress,
+		Name:                 "UnexpectedEgress",
+		ShortDescription:     sarifMessage{Text: "Egress connection that would be blocked"},
+		FullDescription:      sarifMessage{Text: "A process in the pipeline connected to a destination that is not allowed by the kntrl policy, the policy was not enforced (monitor mode or a process rule)."},
+		Help:                 sarifMessage{Text: "Review the destination and add it to the allowed hosts or IP addresses before enforcing the policy if it's expected."},
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevelWarning},
+	},
+}
+
+// sarifRuleOf returns the rule, the level and the reason of the violation of the event,
+// empty if the event is not a violation
+func sarifRuleOf(e domain.ReportEvent) (string, string, string) {
+	switch {
+	case e.Policy == domain.EventPolicyStatusBlock:
+		return sarifRuleBlockedEgress, sarifLevelError, "which is not allowed by the policy"
+	case e.Policy == domain.EventPolicyStatusTarpit:
+		return sarifRuleTarpittedEgress, sarifLevelWarning, "which is not allowed by the policy, the connection was delayed"
+	case e.WouldBlock:
+		return sarifRuleUnexpectedEgress, sarifLevelWarning, "which would be blocked by the policy"
+	}
+
+	return "", "", ""
+}
+
+// toSARIF converts the report to a SARIF log. The blocked, the delayed and
+// the would block events are reported as results.
+func toSARIF(report domain.Report) sarifLog {
+	var location = sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: workflowLocation()},
+			Region:           sarifRegion{StartLine: 1},
+		},
+	}
+
+	var results = make([]sarifResult, 0)
+	for _, e := range report.Events {
+		rule, level, reason := sarifRuleOf(e)
+		if rule == "" {
+			continue
+		}
+
+		var text = fmt.Sprintf("%s (pid %d) connected to %s:%d [%s] (%s) %s",
+			e.TaskName, e.ProcessID, e.DestinationAddress, e.DestinationPort, e.Protocol, strings.Join(e.Domains, ", "), reason)
+		if e.Shard != "" {
+			text = fmt.Sprintf("[%s] %s", e.Shard, text)
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    rule,
+			Level:     level,
+			Message:   sarifMessage{Text: text},
+			Locations: []sarifLocation{location},
+			PartialFingerprints: map[string]string{
+				"primaryLocationLineHash": hash(fmt.Sprintf("%s:%s:%d", e.TaskName, e.DestinationAddress, e.DestinationPort)),
+			},
+		})
+	}
+
+	return sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "kntrl",
+						InformationURI: sarifToolURI,
+						Rules:          sarifRules,
+					},
+				},
+				Invocations: []sarifInvocation{
+					{
+						ExecutionSuccessful: true,
+						StartTimeUTC:        report.StartedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+						EndTimeUTC:          report.FinishedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+}
+
+// workflowLocation returns the path of the running GitHub Actions workflow file
+// GITHUB_WORKFLOW_REF: owner/repo/.github/workflows/ci.yml@refs/heads/main
+func workflowLocation() string {
+	ref := os.Getenv("GITHUB_WORKFLOW_REF")
+	if ref == "" {
+		return sarifDefaultLocation
+	}
+
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.Index(ref, sarifDefaultLocation); i >= 0 {
+		return ref[i:]
+	}
+
+	return sarifDefaultLocation
+}
@@ -0,0 +1,105 @@
+package reporter
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// Sink receives the events in real time as they are handled and the report
+// when the run stops. The event sinks of the tracer get each event, the
+// repeated destinations included; the outputs of a Reporter get the first
+// event of a destination.
+type Sink interface {
+	// Name is the name of the sink in the diagnostics (sink/<name>)
+	Name() string
+	Write(event domain.ReportEvent) error
+	// Flush writes the report at the end of the run, the sinks writing the
+	// events as they are handled have nothing to flush
+	Flush(report domain.Report) error
+	Close() error
+}
+
+// EscalationSink is a sink notified of the escalation of the mode besides
+// the events (syslog, journald)
+type EscalationSink interface {
+	Escalate(escalation domain.Escalation) error
+}
+
+// NewSink returns the sink of the URL:
+//
+//	syslog://host[:514]      RFC 5424 messages over UDP
+//	syslog+tcp://host[:514]  RFC 5424 messages over TCP (octet counting)
+//	journald://[socket]      the native protocol of the systemd journal
+//	otlp://host[:4318]       spans and metrics to an OpenTelemetry collector (OTLP/HTTP)
+//	otlp+https://host[:4318] OTLP/HTTP over TLS
+//	http(s)://host/path      the violation webhook, see Webhook
+func NewSink(rawURL string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink [%s]: %w", rawURL, err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "syslog", "syslog+udp":
+		return newSyslog("udp", u)
+	case "syslog+tcp":
+		return newSyslog("tcp", u)
+	case "journald":
+		return newJournald(u.Path)
+	case "otlp", "otlp+http":
+		return newOTLP("http", u)
+	case "otlp+https":
+		return newOTLP("https", u)
+	case "http", "https":
+		return NewWebhook(rawURL)
+	}
+
+	return nil, fmt.Errorf("invalid sink [%s]: unsupported scheme %q", rawURL, u.Scheme)
+}
+
+// severity is the syslog severity of the event, the blocked and the delayed
+// connections are warnings
+func severity(event domain.ReportEvent) int {
+	if event.Policy == domain.EventPolicyStatusBlock || event.Policy == domain.EventPolicyStatusTarpit {
+		return severityWarning
+	}
+
+	return severityInfo
+}
+
+// summary is the human readable message of the event
+func summary(event domain.ReportEvent) string {
+	var message = fmt.Sprintf("%s[%d] -> %s:%d (%s) %s %s",
+		event.TaskName, event.ProcessID, event.DestinationAddress, event.DestinationPort, domainOf(event), event.Protocol, event.Policy)
+	if event.Verdict != "" {
+		message += " " + event.Verdict
+	}
+
+	return message
+}
+
+// escalationSummary is the human readable message of the escalation
+func escalationSummary(e domain.Escalation) string {
+	var kinds []string
+	for _, f := range e.Findings {
+		if !slices.Contains(kinds, f.Kind) {
+			kinds = append(kinds, f.Kind)
+		}
+	}
+
+	return fmt.Sprintf("escalated from the %s mode to the %s mode after %d high-severity finding(s): %s",
+		e.From, e.To, len(e.Findings), strings.Join(kinds, ", "))
+}
+
+// domainOf returns the first domain name of the event, the address if unknown
+func domainOf(event domain.ReportEvent) string {
+	if len(event.Domains) > 0 && event.Domains[0] != "" && event.Domains[0] != "." {
+		return event.Domains[0]
+	}
+
+	return event.DestinationAddress
+}
@@ -0,0 +1,277 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+var sinkEvent = domain.ReportEvent{
+	ProcessID:          2806,
+	TaskName:           "curl",
+	Protocol:           "tcp",
+	DestinationAddress: "140.82.114.22",
+	DestinationPort:    443,
+	Domains:            []string{"github.com"},
+	Policy:             domain.EventPolicyStatusBlock,
+	Timestamp:          time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	for _, rawURL := range []string{"kafka://broker:9092", "syslog://", "syslog://siem?facility=kern"} {
+		if _, err := NewSink(rawURL); err == nil {
+			t.Errorf("expected an error for %s", rawURL)
+		}
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSink("syslog://" + conn.LocalAddr().String() + "?facility=local0")
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(sinkEvent); err != nil {
+		t.Fatalf("failed to write event: %v", err)
+	}
+
+	var buf = make([]byte, 2048)
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	// local0 (16) * 8 + warning (4)
+	var want = regexp.MustCompile(`^<132>1 2024-03-01T10:00:00\.000000Z \S+ kntrl \d+ egress \[kntrl@32473 pid="2806" comm="curl" proto="tcp" daddr="140\.82\.114\.22" dport="443" domains="github\.com" policy="block"\] curl\[2806\] -> 140\.82\.114\.22:443 \(github\.com\) tcp block$`)
+	if msg := string(buf[:n]); !want.MatchString(msg) {
+		t.Errorf("unexpected message: %s", msg)
+	}
+}
+
+func TestSyslog_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var received = make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// octet counting: the length, a space and the message
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return
+		}
+		var msg = make([]byte, size)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return
+		}
+		received <- string(msg)
+	}()
+
+	sink, err := NewSink("syslog+tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer sink.Close()
+
+	var event = sinkEvent
+	event.Policy = domain.EventPolicyStatusPass
+	event.TaskName = `my"task]`
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("failed to write event: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		// daemon (3) * 8 + info (6)
+		if !strings.HasPrefix(msg, "<30>1 ") || !strings.Contains(msg, `comm="my\"task\]"`) {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestJournald(t *testing.T) {
+	var socket = filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSink("journald://" + socket)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(sinkEvent); err != nil {
+		t.Fatalf("failed to write event: %v", err)
+	}
+
+	var buf = make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read entry: %v", err)
+	}
+
+	var entry = string(buf[:n])
+	for _, field := range []string{"PRIORITY=4\n", "SYSLOG_IDENTIFIER=kntrl\n", "KNTRL_DADDR=140.82.114.22\n", "KNTRL_POLICY=block\n"} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("expected %q in the entry: %q", field, entry)
+		}
+	}
+
+	var escalation = domain.Escalation{
+		From:     domain.TracerModeMonitor,
+		To:       domain.TracerModeTrace,
+		Findings: []domain.Finding{{Kind: domain.FindingKindCredentialEgress}, {Kind: domain.FindingKindCredentialEgress}},
+	}
+	if err := sink.(EscalationSink).Escalate(escalation); err != nil {
+		t.Fatalf("failed to write escalation: %v", err)
+	}
+	if n, err = conn.Read(buf); err != nil {
+		t.Fatalf("failed to read entry: %v", err)
+	}
+
+	entry = string(buf[:n])
+	for _, field := range []string{
+		"PRIORITY=1\n",
+		"KNTRL_ESCALATION_TO=trace\n",
+		"MESSAGE=escalated from the monitor mode to the trace mode after 2 high-severity finding(s): credential-read-egress\n",
+	} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("expected %q in the entry: %q", field, entry)
+		}
+	}
+}
+
+func TestWriteJournalField(t *testing.T) {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", "a\nb")
+
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], 3)
+	want.Write(size[:])
+	want.WriteString("a\nb\n")
+
+	if !bytes.Equal(b.Bytes(), want.Bytes()) {
+		t.Errorf("unexpected field: %q", b.String())
+	}
+}
+
+func TestOTLP(t *testing.T) {
+	t.Setenv(otlpHeadersEnv, "authorization=Bearer%20secret")
+
+	var (
+		mu      sync.Mutex
+		traces  []otlpTraces
+		metrics []otlpMetrics
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/otlp/v1/traces":
+			var payload otlpTraces
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode traces: %v", err)
+			}
+			traces = append(traces, payload)
+		case "/otlp/v1/metrics":
+			var payload otlpMetrics
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode metrics: %v", err)
+			}
+			metrics = append(metrics, payload)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewSink("otlp://" + strings.TrimPrefix(server.URL, "http://") + "/otlp/")
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+
+	var traced = sinkEvent
+	traced.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traced.SpanID = "00f067aa0ba902b7"
+	for _, event := range []domain.ReportEvent{sinkEvent, traced} {
+		if err := sink.Write(event); err != nil {
+			t.Fatalf("failed to write event: %v", err)
+		}
+	}
+	// the last batch is exported on close
+	if err := sink.Close(); err != nil {
+		t.Fatalf("failed to close sink: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(traces) != 1 || len(metrics) != 1 {
+		t.Fatalf("expected an export of the traces and the metrics, got %d %d", len(traces), len(metrics))
+	}
+
+	var spans = traces[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if len(spans[0].TraceID) != 32 || spans[0].ParentSpanID != "" || spans[0].Status.Code != otlpStatusError {
+		t.Errorf("unexpected span of the untraced event: %+v", spans[0])
+	}
+	if spans[1].TraceID != traced.TraceID || spans[1].ParentSpanID != traced.SpanID || len(spans[1].SpanID) != 16 {
+		t.Errorf("expected the span to be a child of the pipeline span, got %+v", spans[1])
+	}
+
+	var points = metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Sum.DataPoints
+	if len(points) != 1 || points[0].AsInt != "2" {
+		t.Errorf("expected a series of 2 connections, got %+v", points)
+	}
+}
@@ -0,0 +1,88 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// StreamFormatJSONL writes each event as a JSON line
+const StreamFormatJSONL = "jsonl"
+
+// Stream writes the events in real time for the log processors (jq, Fluent Bit).
+// Unlike the report, each event is written, the repeated destinations included,
+// and the line is written as soon as the event is handled.
+type Stream struct {
+	mu     sync.Mutex
+	file   *os.File
+	format string
+}
+
+// NewStream opens the stream output, "-" writes the events to stdout.
+// The events are appended to an existing file.
+func NewStream(output, format string) (*Stream, error) {
+	if !IsValidStreamFormat(format) {
+		return nil, fmt.Errorf("invalid stream format: %s", format)
+	}
+
+	if output == stdoutFileName {
+		return &Stream{file: os.Stdout, format: format}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create stream directory: %w", err)
+	}
+
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream output: %w", err)
+	}
+
+	return &Stream{file: file, format: format}, nil
+}
+
+// Name returns the name of the sink
+func (s *Stream) Name() string {
+	return "stream"
+}
+
+// Write writes the event as a line
+func (s *Stream) Write(event domain.ReportEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the line is written at once, the readers of a pipe never see a partial line
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event to stream: %s %w", s.file.Name(), err)
+	}
+
+	return nil
+}
+
+// Flush does nothing, the events are written as they are handled
+func (s *Stream) Flush(domain.Report) error {
+	return nil
+}
+
+// Close closes the stream output
+func (s *Stream) Close() error {
+	if s.file == os.Stdout {
+		return nil
+	}
+
+	return s.file.Close()
+}
+
+// IsValidStreamFormat returns true if the given stream format is supported
+func IsValidStreamFormat(format string) bool {
+	return format == StreamFormatJSONL
+}
@@ -0,0 +1,59 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestStream(t *testing.T) {
+	var output = filepath.Join(t.TempDir(), "events", "kntrl.jsonl")
+
+	stream, err := NewStream(output, StreamFormatJSONL)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+
+	// the repeated destination is written again, unlike the report
+	var event = domain.ReportEvent{ProcessID: 42, TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass}
+	for i := 0; i < 2; i++ {
+		if err := stream.Write(event); err != nil {
+			t.Fatalf("failed to write event: %v", err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("failed to close stream: %v", err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines int
+	var scanner = bufio.NewScanner(file)
+	for scanner.Scan() {
+		var got domain.ReportEvent
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("expected a JSON line, got %q: %v", scanner.Text(), err)
+		}
+		if got.DestinationAddress != event.DestinationAddress || got.Policy != event.Policy {
+			t.Errorf("unexpected event: %+v", got)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+}
+
+func TestNewStream_InvalidFormat(t *testing.T) {
+	if _, err := NewStream(filepath.Join(t.TempDir(), "kntrl.log"), "csv"); err == nil {
+		t.Error("expected an error for the invalid format")
+	}
+}
@@ -0,0 +1,251 @@
+package reporter
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	// SuggestionAllowedHosts, SuggestionAllowedIPs and SuggestionAllowedPorts
+	// are the fields of the policy file of the suggestions
+	SuggestionAllowedHosts = "allowed-hosts"
+	SuggestionAllowedIPs   = "allowed-ips"
+	SuggestionAllowedPorts = "allowed-ports"
+)
+
+// suggestionFields is the order of the fields in the policy file
+var suggestionFields = []string{SuggestionAllowedHosts, SuggestionAllowedIPs, SuggestionAllowedPorts}
+
+// webPorts are allowed by a hostname or an address, the destinations on the
+// other ports are suggested as a port rule of the destination and the port
+var webPorts = map[uint16]bool{80: true, 443: true}
+
+// SuggestPolicy returns the entries of the policy file allowing the
+// destinations of the events blocked or out of the policy (would block). The
+// destinations of the deny list, the excluded processes and the sandboxes
+// are not suggested. The hostnames of the same parent domain are suggested
+// as the parent domain (.example.com) and the addresses of the same network
+// are labeled with the range.
+func SuggestPolicy(events []domain.ReportEvent) []domain.PolicySuggestion {
+	var suggestions = make(map[string]*domain.PolicySuggestion)
+	var add = func(field, value string, e domain.ReportEvent) {
+		var key = field + "/" + value
+		s, ok := suggestions[key]
+		if !ok {
+			s = &domain.PolicySuggestion{Field: field, Value: value}
+			suggestions[key] = s
+		}
+		s.Connections++
+		if !slices.Contains(s.Tasks, e.TaskName) {
+			s.Tasks = append(s.Tasks, e.TaskName)
+		}
+		for name, label := range map[string]string{"category": e.Category, "as_org": e.ASOrg, "country": e.Country} {
+			if label == "" {
+				continue
+			}
+			if s.Labels == nil {
+				s.Labels = make(map[string]string)
+			}
+			if _, ok := s.Labels[name]; !ok {
+				s.Labels[name] = label
+			}
+		}
+	}
+
+	for _, e := range events {
+		if !suggested(e) {
+			continue
+		}
+
+		var host string
+		if len(e.Domains) > 0 {
+			host = strings.ToLower(strings.TrimSuffix(e.Domains[0], "."))
+		}
+
+		switch {
+		case !webPorts[e.DestinationPort] && host != "":
+			add(SuggestionAllowedPorts, net.JoinHostPort(host, strconv.Itoa(int(e.DestinationPort))), e)
+		case !webPorts[e.DestinationPort]:
+			add(SuggestionAllowedPorts, net.JoinHostPort(e.DestinationAddress, strconv.Itoa(int(e.DestinationPort))), e)
+		case host != "":
+			add(SuggestionAllowedHosts, host, e)
+		default:
+			add(SuggestionAllowedIPs, e.DestinationAddress, e)
+		}
+	}
+
+	var list = make([]domain.PolicySuggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		list = append(list, *s)
+	}
+	// the labels of a group are the labels of its first name
+	sort.Slice(list, func(i, j int) bool { return list[i].Value < list[j].Value })
+	list = groupHosts(list)
+	labelRanges(list)
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Field != list[j].Field {
+			return slices.Index(suggestionFields, list[i].Field) < slices.Index(suggestionFields, list[j].Field)
+		}
+		// the addresses of a range are listed together, after the others
+		if list[i].Range != list[j].Range {
+			return list[i].Range < list[j].Range
+		}
+		return list[i].Value < list[j].Value
+	})
+
+	return list
+}
+
+// suggested returns true if the destination of the event is out of the policy
+func suggested(e domain.ReportEvent) bool {
+	if e.Denied || e.Self || e.Excluded || e.Sandbox != "" {
+		return false
+	}
+
+	return e.Policy == domain.EventPolicyStatusBlock || e.Policy == domain.EventPolicyStatusTarpit || e.WouldBlock
+}
+
+// groupHosts replaces the hostnames of the same parent domain with the parent
+// domain, the parent of a name of two labels (example.com) is not grouped
+func groupHosts(list []domain.PolicySuggestion) []domain.PolicySuggestion {
+	var children = make(map[string]int)
+	for _, s := range list {
+		if parent, ok := parentDomain(s); ok {
+			children[parent]++
+		}
+	}
+
+	var grouped = make(map[string]*domain.PolicySuggestion)
+	var result = make([]domain.PolicySuggestion, 0, len(list))
+	for _, s := range list {
+		parent, ok := parentDomain(s)
+		if !ok || children[parent] < 2 {
+			result = append(result, s)
+			continue
+		}
+
+		g, ok := grouped[parent]
+		if !ok {
+			g = &domain.PolicySuggestion{Field: SuggestionAllowedHosts, Value: "." + parent}
+			grouped[parent] = g
+		}
+		g.Connections += s.Connections
+		for _, task := range s.Tasks {
+			if !slices.Contains(g.Tasks, task) {
+				g.Tasks = append(g.Tasks, task)
+			}
+		}
+		for name, label := range s.Labels {
+			if g.Labels == nil {
+				g.Labels = make(map[string]string)
+			}
+			if _, ok := g.Labels[name]; !ok {
+				g.Labels[name] = label
+			}
+		}
+	}
+	for _, g := range grouped {
+		result = append(result, *g)
+	}
+
+	return result
+}
+
+// parentDomain returns the parent domain of a hostname suggestion
+func parentDomain(s domain.PolicySuggestion) (string, bool) {
+	if s.Field != SuggestionAllowedHosts {
+		return "", false
+	}
+
+	_, parent, ok := strings.Cut(s.Value, ".")
+	if !ok || !strings.Contains(parent, ".") {
+		return "", false
+	}
+
+	return parent, true
+}
+
+// labelRanges sets the range of the addresses of the same network (/24 for
+// IPv4, /64 for IPv6) suggested together
+func labelRanges(list []domain.PolicySuggestion) {
+	var ranges = make(map[netip.Prefix]int)
+	var prefixes = make([]netip.Prefix, len(list))
+	for i, s := range list {
+		if s.Field != SuggestionAllowedIPs {
+			continue
+		}
+		addr, err := netip.ParseAddr(s.Value)
+		if err != nil {
+			continue
+		}
+
+		var bits = 64
+		if addr.Is4() {
+			bits = 24
+		}
+		prefixes[i], _ = addr.Prefix(bits)
+		ranges[prefixes[i]]++
+	}
+
+	for i, prefix := range prefixes {
+		if prefix.IsValid() && ranges[prefix] > 1 {
+			list[i].Range = prefix.String()
+		}
+	}
+}
+
+// SuggestedPolicy renders the suggestions as the fields of the policy file
+// (--policy-file), an entry is commented with its connections, its tasks and
+// its labels
+func SuggestedPolicy(suggestions []domain.PolicySuggestion) string {
+	var b strings.Builder
+	for _, field := range suggestionFields {
+		var header bool
+		var lastRange string
+		for _, s := range suggestions {
+			if s.Field != field {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "%s:\n", field)
+				header = true
+			}
+			if s.Range != "" && s.Range != lastRange {
+				fmt.Fprintf(&b, "  # %s\n", s.Range)
+			}
+			lastRange = s.Range
+
+			var value = s.Value
+			if field == SuggestionAllowedPorts {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(&b, "  - %s # %s\n", value, suggestionComment(s))
+		}
+	}
+
+	return b.String()
+}
+
+// suggestionComment returns the connections, the tasks and the labels of the suggestion
+func suggestionComment(s domain.PolicySuggestion) string {
+	var comment = fmt.Sprintf("%d connection(s) by %s", s.Connections, strings.Join(s.Tasks, ", "))
+
+	var names = make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		comment += fmt.Sprintf(", %s=%s", name, s.Labels[name])
+	}
+
+	return comment
+}
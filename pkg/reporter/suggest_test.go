@@ -0,0 +1,78 @@
+package reporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestSuggestPolicy(t *testing.T) {
+	var block = func(task, addr string, port uint16, domains ...string) domain.ReportEvent {
+		return domain.ReportEvent{
+			TaskName:           task,
+			DestinationAddress: addr,
+			DestinationPort:    port,
+			Domains:            domains,
+			Policy:             domain.EventPolicyStatusBlock,
+		}
+	}
+
+	var events = []domain.ReportEvent{
+		block("npm", "104.16.1.34", 443, "registry.npmjs.org"),
+		block("npm", "104.16.1.35", 443, "registry.npmjs.org"),
+		block("curl", "104.16.2.1", 443, "static.npmjs.org"),
+		block("pip", "151.101.0.223", 443, "pypi.org"),
+		block("nc", "203.0.113.7", 443),
+		block("nc", "203.0.113.9", 80),
+		block("nc", "198.51.100.1", 443),
+		block("psql", "192.0.2.10", 5432, "db.example.com"),
+		block("ssh", "192.0.2.11", 22),
+		// the deny list, the passed and the excluded connections are not suggested
+		{TaskName: "curl", DestinationAddress: "45.9.148.3", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Denied: true},
+		{TaskName: "git", DestinationAddress: "140.82.112.3", DestinationPort: 443, Domains: []string{"github.com"}, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "dockerd", DestinationAddress: "198.51.100.7", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Excluded: true, WouldBlock: true},
+		// the monitor mode
+		{TaskName: "wget", DestinationAddress: "93.184.216.34", DestinationPort: 443, Domains: []string{"Example.org."}, Policy: domain.EventPolicyStatusPass, WouldBlock: true, Category: "unknown", ASOrg: "EDGECAST"},
+	}
+
+	var got []string
+	for _, s := range SuggestPolicy(events) {
+		got = append(got, s.Field+" "+s.Value+" "+s.Range)
+	}
+	var want = []string{
+		"allowed-hosts .npmjs.org ",
+		"allowed-hosts example.org ",
+		"allowed-hosts pypi.org ",
+		"allowed-ips 198.51.100.1 ",
+		"allowed-ips 203.0.113.7 203.0.113.0/24",
+		"allowed-ips 203.0.113.9 203.0.113.0/24",
+		"allowed-ports 192.0.2.11:22 ",
+		"allowed-ports db.example.com:5432 ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	var npm = SuggestPolicy(events)[0]
+	if npm.Connections != 3 || !reflect.DeepEqual(npm.Tasks, []string{"npm", "curl"}) {
+		t.Errorf("unexpected group %+v", npm)
+	}
+
+	var yaml = SuggestedPolicy(SuggestPolicy(events))
+	for _, line := range []string{
+		"allowed-hosts:\n  - .npmjs.org # 3 connection(s) by npm, curl\n",
+		"  - example.org # 1 connection(s) by wget, as_org=EDGECAST, category=unknown\n",
+		"  # 203.0.113.0/24\n  - 203.0.113.7 # 1 connection(s) by nc\n",
+		"allowed-ports:\n  - \"192.0.2.11:22\" # 1 connection(s) by ssh\n",
+	} {
+		if !strings.Contains(yaml, line) {
+			t.Errorf("expected %q in\n%s", line, yaml)
+		}
+	}
+
+	if s := SuggestPolicy(events[9:12]); len(s) != 0 {
+		t.Errorf("expected no suggestions, got %+v", s)
+	}
+}
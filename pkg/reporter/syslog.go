@@ -0,0 +1,222 @@
+package reporter
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	// defaultSyslogPort is the port of the syslog collectors
+	defaultSyslogPort = "514"
+	// syslogAppName is the APP-NAME of the messages
+	syslogAppName = "kntrl"
+	// syslogMsgID is the MSGID of the connection events
+	syslogMsgID = "egress"
+	// syslogEscalationMsgID is the MSGID of the escalation of the mode
+	syslogEscalationMsgID = "escalation"
+	// syslogSDID is the SD-ID of the event fields, 32473 is the example
+	// private enterprise number (RFC 5612)
+	syslogSDID = "kntrl@32473"
+	// syslogTimeFormat is the RFC 5424 timestamp with microseconds
+	syslogTimeFormat  = "2006-01-02T15:04:05.000000Z07:00"
+	syslogDialTimeout = 5 * time.Second
+)
+
+// the severities of the events (RFC 5424)
+const (
+	severityAlert   = 1
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// syslogFacilities are the facilities of the facility parameter of the sink URL
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3, "auth": 4, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog sends the events as RFC 5424 messages to a syslog collector (the
+// SIEM), the event fields are the structured data of the message
+type Syslog struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	conn     net.Conn
+	facility int
+	hostname string
+}
+
+// newSyslog dials the collector of the URL, the facility is set by the facility
+// parameter (syslog://siem:514?facility=local0, daemon by default)
+func newSyslog(network string, u *url.URL) (*Syslog, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid syslog sink [%s]: host is required", u)
+	}
+
+	var port = u.Port()
+	if port == "" {
+		port = defaultSyslogPort
+	}
+
+	var facility = syslogFacilities["daemon"]
+	if name := u.Query().Get("facility"); name != "" {
+		f, ok := syslogFacilities[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid syslog facility: %s", name)
+		}
+		facility = f
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	var s = &Syslog{
+		network:  network,
+		address:  net.JoinHostPort(u.Hostname(), port),
+		facility: facility,
+		hostname: hostname,
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Syslog) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, syslogDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog [%s]: %w", s.address, err)
+	}
+	s.conn = conn
+
+	return nil
+}
+
+// Name returns the name of the sink
+func (s *Syslog) Name() string {
+	return "syslog"
+}
+
+// Write sends the event, the TCP connection is dialed again once if it's closed
+func (s *Syslog) Write(event domain.ReportEvent) error {
+	return s.send(s.format(event))
+}
+
+// Escalate sends the escalation of the mode as an alert
+func (s *Syslog) Escalate(e domain.Escalation) error {
+	var sd = fmt.Sprintf("[%s from=\"%s\" to=\"%s\" findings=\"%d\"]", syslogSDID, escapeSDParam(e.From), escapeSDParam(e.To), len(e.Findings))
+
+	return s.send(fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.facility*8+severityAlert,
+		e.Timestamp.Format(syslogTimeFormat),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		syslogEscalationMsgID,
+		sd,
+		escalationSummary(e),
+	))
+}
+
+// send sends the message, the TCP connection is dialed again once if it's closed
+func (s *Syslog) send(msg string) error {
+	if s.network == "tcp" {
+		// octet counting framing (RFC 6587)
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		if s.network != "tcp" {
+			return fmt.Errorf("failed to send event to syslog [%s]: %w", s.address, err)
+		}
+
+		s.conn.Close()
+		if err := s.dial(); err != nil {
+			return err
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			return fmt.Errorf("failed to send event to syslog [%s]: %w", s.address, err)
+		}
+	}
+
+	return nil
+}
+
+// format formats the event as an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *Syslog) format(event domain.ReportEvent) string {
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	param := func(name, value string) {
+		if value != "" {
+			sd.WriteString(" " + name + "=\"" + escapeSDParam(value) + "\"")
+		}
+	}
+	param("pid", strconv.FormatUint(uint64(event.ProcessID), 10))
+	param("comm", event.TaskName)
+	param("exe", event.Executable)
+	param("proto", event.Protocol)
+	param("daddr", event.DestinationAddress)
+	param("dport", strconv.Itoa(int(event.DestinationPort)))
+	param("domains", strings.Join(event.Domains, ","))
+	param("server_name", event.ServerName)
+	param("policy", event.Policy)
+	param("container", event.Container)
+	param("sandbox", event.Sandbox)
+	param("nat64", event.NAT64)
+	param("trace_id", event.TraceID)
+	param("span_id", event.SpanID)
+	if event.Excluded {
+		param("excluded", "true")
+	}
+	sd.WriteString("]")
+
+	var timestamp = "-"
+	if !event.Timestamp.IsZero() {
+		timestamp = event.Timestamp.Format(syslogTimeFormat)
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.facility*8+severity(event),
+		timestamp,
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		syslogMsgID,
+		sd.String(),
+		summary(event),
+	)
+}
+
+// Flush does nothing, the events are sent as they are handled
+func (s *Syslog) Flush(domain.Report) error {
+	return nil
+}
+
+// Close closes the connection to the collector
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conn.Close()
+}
+
+// escapeSDParam escapes the characters of a structured data parameter value (", \ and ])
+func escapeSDParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,178 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// uploadPath is the report endpoint of the Kondukto API
+	uploadPath = "/api/v2/kntrl/reports"
+
+	defaultUploadRetries = 3
+	defaultUploadTimeout = 30 * time.Second
+)
+
+// uploadBackoff is the delay of the first retry, doubled on each retry
+var uploadBackoff = 2 * time.Second
+
+// UploadConfig is the configuration of the report upload to the Kondukto platform
+type UploadConfig struct {
+	// URL is the address of the Kondukto platform (https://kondukto.example.com)
+	URL string
+	// Token is the API token
+	Token string
+	// Project is the name or the id of the project the report belongs to
+	Project string
+	// Insecure skips the TLS certificate verification
+	Insecure bool
+	// CACert is the CA certificate file to verify the platform certificate
+	CACert string
+	// Retries is the number of retries of a failed upload (default 3)
+	Retries int
+	// Timeout is the timeout of an upload attempt (default 30s)
+	Timeout time.Duration
+}
+
+// Validate checks the required fields
+func (c UploadConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("kondukto url is required")
+	}
+	if _, err := url.ParseRequestURI(c.URL); err != nil {
+		return fmt.Errorf("invalid kondukto url: %w", err)
+	}
+	if c.Token == "" {
+		return errors.New("kondukto token is required")
+	}
+	if c.Project == "" {
+		return errors.New("kondukto project is required")
+	}
+
+	return nil
+}
+
+// uploadRequest is the body of the report upload
+type uploadRequest struct {
+	Project string        `json:"project"`
+	Report  domain.Report `json:"report"`
+}
+
+// Upload uploads the report to the Kondukto platform. The failed attempts
+// (network errors, 429 and 5xx responses) are retried with a backoff.
+func Upload(ctx context.Context, cfg UploadConfig, report domain.Report) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	client, err := uploadClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(uploadRequest{Project: cfg.Project, Report: report})
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	var retries = cfg.Retries
+	if retries <= 0 {
+		retries = defaultUploadRetries
+	}
+
+	var endpoint = strings.TrimSuffix(cfg.URL, "/") + uploadPath
+	for attempt := 0; ; attempt++ {
+		retry, err := uploadOnce(ctx, client, endpoint, cfg.Token, body)
+		if err == nil {
+			logger.Log.Infof("report uploaded to %s", cfg.URL)
+			return nil
+		}
+
+		if !retry || attempt >= retries {
+			return fmt.Errorf("failed to upload report: %w", err)
+		}
+
+		var backoff = uploadBackoff << attempt
+		logger.Log.Warnf("failed to upload report, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to upload report: %w", ctx.Err())
+		}
+	}
+}
+
+// uploadOnce sends the report, it returns true if the failure is retryable
+func uploadOnce(ctx context.Context, client *http.Client, endpoint, token string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Cookie", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// a certificate error fails on every attempt
+		var certErr *tls.CertificateVerificationError
+		return ctx.Err() == nil && !errors.As(err, &certErr), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("kondukto returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func uploadClient(cfg UploadConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.Insecure,
+	}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	var timeout = cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultUploadTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
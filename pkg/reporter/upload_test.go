@@ -0,0 +1,83 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestUpload_Retry(t *testing.T) {
+	uploadBackoff = time.Millisecond
+
+	var attempts int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Path != uploadPath || r.Header.Get("X-Cookie") != "token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		var req uploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Project != "kntrl" || req.Report.Summary.Total != 1 {
+			http.Error(w, "unexpected body", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var report = domain.Report{Summary: domain.ReportSummary{Total: 1, Pass: 1}}
+	var cfg = UploadConfig{URL: server.URL, Token: "token", Project: "kntrl", Insecure: true}
+
+	if err := Upload(context.Background(), cfg, report); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestUpload_TLSVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var cfg = UploadConfig{URL: server.URL, Token: "token", Project: "kntrl", Retries: 1}
+
+	// the self-signed certificate is not trusted
+	uploadBackoff = time.Millisecond
+	if err := Upload(context.Background(), cfg, domain.Report{}); err == nil {
+		t.Errorf("expected the certificate verification to fail")
+	}
+
+	var caCert = filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.CACert = caCert
+	if err := Upload(context.Background(), cfg, domain.Report{}); err != nil {
+		t.Errorf("expected the CA certificate to be trusted: %v", err)
+	}
+}
+
+func TestUploadConfig_Validate(t *testing.T) {
+	if err := (UploadConfig{URL: "https://kondukto.example.com", Token: "token"}).Validate(); err == nil {
+		t.Errorf("expected an error without a project")
+	}
+}
@@ -0,0 +1,255 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// ViolationBlock is the verdict of a blocked connection
+	ViolationBlock = "block"
+	// ViolationTarpit is the verdict of a connection delayed instead of blocked
+	ViolationTarpit = "tarpit"
+	// ViolationUnexpected is the verdict of a connection out of the policy
+	// that is not blocked (monitor mode, sandboxes)
+	ViolationUnexpected = "unexpected"
+	// ViolationEscalation is the verdict of the escalation of the mode, the
+	// payload has no process and no destination
+	ViolationEscalation = "escalation"
+
+	// webhookQueueSize is the number of the violations waiting to be posted,
+	// the violations are dropped when the queue is full
+	webhookQueueSize = 256
+	// webhookInterval is the interval of the repeated violations of a process to a destination
+	webhookInterval = time.Minute
+	// webhookMaxKeys is the number of the recent violations kept, the expired ones are removed above
+	webhookMaxKeys = 4096
+	webhookTimeout = 10 * time.Second
+	// webhookDrainTimeout is the time to post the queued violations on close
+	webhookDrainTimeout = 5 * time.Second
+)
+
+// Violation is the payload of the violation webhook. The text field is the
+// message of the Slack and Teams incoming webhooks.
+type Violation struct {
+	Text        string    `json:"text"`
+	Verdict     string    `json:"verdict"`
+	Process     string    `json:"process"`
+	Executable  string    `json:"exe,omitempty"`
+	Args        []string  `json:"args,omitempty"`
+	Pid         uint32    `json:"pid"`
+	Destination string    `json:"destination"`
+	Protocol    string    `json:"proto"`
+	Domains     []string  `json:"domains,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Host        string    `json:"host"`
+	TraceID     string    `json:"trace_id,omitempty"`
+	SpanID      string    `json:"span_id,omitempty"`
+	Killed      bool      `json:"killed,omitempty"`
+}
+
+// Webhook posts the policy violations to a URL as they are handled. The
+// violations are posted in the background, the events are never delayed.
+type Webhook struct {
+	url      string
+	client   *http.Client
+	host     string
+	queue    chan Violation
+	done     chan struct{}
+	mu       sync.Mutex
+	postedAt map[string]time.Time
+	closed   bool
+}
+
+// NewWebhook returns the webhook of the URL and starts posting the violations
+func NewWebhook(rawURL string) (*Webhook, error) {
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid violation webhook url: %s", rawURL)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+
+	var w = &Webhook{
+		url:      rawURL,
+		client:   &http.Client{Timeout: webhookTimeout},
+		host:     hostname,
+		queue:    make(chan Violation, webhookQueueSize),
+		done:     make(chan struct{}),
+		postedAt: make(map[string]time.Time),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// Notify queues the violation of the event, the repeated violations of a
+// process to a destination are posted once a minute
+func (w *Webhook) Notify(event domain.ReportEvent, verdict string) {
+	var destination = event.DestinationAddress + ":" + strconv.Itoa(int(event.DestinationPort))
+	var key = verdict + "/" + event.TaskName + "/" + destination
+	var now = time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+	if postedAt, ok := w.postedAt[key]; ok && now.Sub(postedAt) < webhookInterval {
+		return
+	}
+	if len(w.postedAt) >= webhookMaxKeys {
+		for k, postedAt := range w.postedAt {
+			if now.Sub(postedAt) >= webhookInterval {
+				delete(w.postedAt, k)
+			}
+		}
+	}
+	w.postedAt[key] = now
+
+	var v = Violation{
+		Verdict:     verdict,
+		Process:     event.TaskName,
+		Executable:  event.Executable,
+		Args:        event.Args,
+		Pid:         event.ProcessID,
+		Destination: destination,
+		Protocol:    event.Protocol,
+		Domains:     event.Domains,
+		Timestamp:   event.Timestamp,
+		Host:        w.host,
+		TraceID:     event.TraceID,
+		SpanID:      event.SpanID,
+		Killed:      event.Killed,
+	}
+	v.Text = fmt.Sprintf("kntrl: %s connection of %s[%d] to %s (%s) on %s", verdict, v.Process, v.Pid, destination, domainOf(event), w.host)
+	if v.Killed {
+		v.Text += ", the process is killed"
+	}
+
+	select {
+	case w.queue <- v:
+	default:
+		logger.Log.Warnf("violation webhook queue is full, the violation to %s is dropped", destination)
+	}
+}
+
+// Escalate queues the escalation of the mode, it's never throttled
+func (w *Webhook) Escalate(e domain.Escalation) {
+	var v = Violation{
+		Verdict:   ViolationEscalation,
+		Timestamp: e.Timestamp,
+		Host:      w.host,
+	}
+	v.Text = fmt.Sprintf("kntrl: %s on %s", escalationSummary(e), w.host)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.queue <- v:
+	default:
+		logger.Log.Warnf("violation webhook queue is full, the escalation is dropped")
+	}
+}
+
+// run posts the queued violations until the webhook is closed
+func (w *Webhook) run() {
+	defer close(w.done)
+
+	for v := range w.queue {
+		if err := w.post(v); err != nil {
+			logger.Log.Errorf("failed to post violation: %v", err)
+		}
+	}
+}
+
+func (w *Webhook) post(v Violation) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+// Name is the name of the sink
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Write queues the violation of the event when the webhook is a sink: the
+// blocked, the delayed and the would-block connections. The tracer notifies
+// its webhooks with the verdict of its policy instead (Notify).
+func (w *Webhook) Write(event domain.ReportEvent) error {
+	switch {
+	case event.Excluded:
+	case event.Policy == domain.EventPolicyStatusBlock:
+		w.Notify(event, ViolationBlock)
+	case event.Policy == domain.EventPolicyStatusTarpit:
+		w.Notify(event, ViolationTarpit)
+	case event.WouldBlock:
+		w.Notify(event, ViolationUnexpected)
+	}
+
+	return nil
+}
+
+// Flush does nothing, the violations are posted as they are handled
+func (w *Webhook) Flush(domain.Report) error {
+	return nil
+}
+
+// Close posts the queued violations and stops the webhook, the violations
+// not posted in 5 seconds are dropped
+func (w *Webhook) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(webhookDrainTimeout):
+		logger.Log.Warnf("violation webhook is not drained, %d violation(s) dropped", len(w.queue))
+	}
+
+	return nil
+}
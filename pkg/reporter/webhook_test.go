@@ -0,0 +1,146 @@
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestWebhook(t *testing.T) {
+	var received = make(chan Violation, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		var v Violation
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("failed to decode violation: %v", err)
+		}
+		received <- v
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL + "/hooks/kntrl")
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	var event = domain.ReportEvent{
+		ProcessID:          2806,
+		TaskName:           "curl",
+		Protocol:           "tcp",
+		DestinationAddress: "142.251.167.95",
+		DestinationPort:    443,
+		Domains:            []string{"ww-in-f95.1e100.net"},
+		Policy:             domain.EventPolicyStatusBlock,
+		Timestamp:          time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	// the repeated violation is posted once
+	webhook.Notify(event, ViolationBlock)
+	webhook.Notify(event, ViolationBlock)
+	event.DestinationPort = 80
+	webhook.Notify(event, ViolationUnexpected)
+	webhook.Close()
+
+	close(received)
+	var violations []Violation
+	for v := range received {
+		violations = append(violations, v)
+	}
+
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %+v", violations)
+	}
+
+	v := violations[0]
+	if v.Verdict != ViolationBlock || v.Process != "curl" || v.Pid != 2806 || v.Destination != "142.251.167.95:443" || !v.Timestamp.Equal(event.Timestamp) {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+	if !strings.Contains(v.Text, "block connection of curl[2806] to 142.251.167.95:443 (ww-in-f95.1e100.net)") {
+		t.Errorf("unexpected text: %s", v.Text)
+	}
+	if violations[1].Verdict != ViolationUnexpected || violations[1].Destination != "142.251.167.95:80" {
+		t.Errorf("unexpected violation: %+v", violations[1])
+	}
+}
+
+func TestWebhook_Escalate(t *testing.T) {
+	var received = make(chan Violation, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v Violation
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("failed to decode violation: %v", err)
+		}
+		received <- v
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	webhook.Escalate(domain.Escalation{
+		From:      domain.TracerModeMonitor,
+		To:        domain.TracerModeTrace,
+		Findings:  []domain.Finding{{Kind: domain.FindingKindDestinationFanout}},
+		Timestamp: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	})
+	webhook.Close()
+
+	v := <-received
+	if v.Verdict != ViolationEscalation || !strings.Contains(v.Text, "escalated from the monitor mode to the trace mode after 1 high-severity finding(s): destination-fanout") {
+		t.Errorf("unexpected escalation: %+v", v)
+	}
+}
+
+func TestWebhook_Write(t *testing.T) {
+	var received = make(chan Violation, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v Violation
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("failed to decode violation: %v", err)
+		}
+		received <- v
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	for _, event := range []domain.ReportEvent{
+		{TaskName: "curl", DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass},
+		{TaskName: "curl", DestinationAddress: "2.2.2.2", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock},
+		{TaskName: "curl", DestinationAddress: "3.3.3.3", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, WouldBlock: true},
+		{TaskName: "sshd", DestinationAddress: "4.4.4.4", DestinationPort: 22, Policy: domain.EventPolicyStatusBlock, Excluded: true},
+	} {
+		webhook.Write(event)
+	}
+	webhook.Close()
+
+	close(received)
+	var verdicts []string
+	for v := range received {
+		verdicts = append(verdicts, v.Verdict+" "+v.Destination)
+	}
+	if strings.Join(verdicts, ", ") != "block 2.2.2.2:443, unexpected 3.3.3.3:443" {
+		t.Errorf("unexpected violations: %v", verdicts)
+	}
+}
+
+func TestNewWebhook_Invalid(t *testing.T) {
+	for _, rawURL := range []string{"", "hooks.slack.com/services/x", "ftp://example.com/hook"} {
+		if _, err := NewWebhook(rawURL); err == nil {
+			t.Errorf("expected an error for %q", rawURL)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package runstore keeps the reports of the runs in a local directory. The
+// events of a run are appended to its events file as they are handled and
+// its report is written when it stops, the events of a run interrupted
+// before (SIGKILL, OOM) are kept.
+package runstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+)
+
+const (
+	// DefaultDir is the default directory of the store
+	DefaultDir = "/var/lib/kntrl/reports"
+
+	// Latest is the reference of the last run, Previous of the run before it
+	Latest   = "latest"
+	Previous = "previous"
+
+	eventsSuffix = ".events.jsonl"
+	reportSuffix = ".report.json"
+	// idLayout is the layout of the start time of the run in its id, the ids
+	// sort by the start time
+	idLayout = "20060102T150405Z"
+)
+
+// Store is the directory of the runs
+type Store struct {
+	dir string
+}
+
+// Open returns the store of the directory, it's created if it doesn't exist
+func Open(dir string) (*Store, error) {
+	// the events may carry the destinations of private hosts, the files are
+	// readable by root only
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create report store: %w", err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Run is a run being written to the store
+type Run struct {
+	ID string
+
+	dir  string
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Create starts a new run, its id is the start time and the process id
+func (s *Store) Create(startedAt time.Time) (*Run, error) {
+	var id = fmt.Sprintf("%s-%d", startedAt.UTC().Format(idLayout), os.Getpid())
+
+	file, err := os.OpenFile(filepath.Join(s.dir, id+eventsSuffix), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run: %w", err)
+	}
+
+	return &Run{ID: id, dir: s.dir, file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Name is the name of the store in the diagnostics
+func (r *Run) Name() string {
+	return "report-store"
+}
+
+// Write appends the event to the events of the run
+func (r *Run) Write(event domain.ReportEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	if err := r.enc.Encode(event); err != nil {
+		return fmt.Errorf("failed to write event to report store: %w", err)
+	}
+
+	return nil
+}
+
+// Finish writes the report of the run
+func (r *Run) Finish(report domain.Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	var path = filepath.Join(r.dir, r.ID+reportSuffix)
+	var tmp = path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write report to report store: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Close closes the events of the run
+func (r *Run) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
+
+// List returns the runs of the store sorted by the start time
+func (s *Store) List() ([]domain.RunInfo, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs = make([]domain.RunInfo, 0, len(ids))
+	for _, id := range ids {
+		report, complete, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, domain.RunInfo{
+			ID:         id,
+			StartedAt:  report.StartedAt,
+			FinishedAt: report.FinishedAt,
+			Mode:       report.Mode,
+			Summary:    report.Summary,
+			Complete:   complete,
+		})
+	}
+
+	return runs, nil
+}
+
+// Load returns the report of the run. The reference is the id of the run, a
+// prefix of a single id, latest or previous.
+func (s *Store) Load(ref string) (string, domain.Report, error) {
+	id, err := s.resolve(ref)
+	if err != nil {
+		return "", domain.Report{}, err
+	}
+
+	report, _, err := s.load(id)
+
+	return id, report, err
+}
+
+// resolve returns the id of the run reference
+func (s *Store) resolve(ref string) (string, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return "", err
+	}
+
+	switch ref {
+	case Latest, "":
+		if len(ids) > 0 {
+			return ids[len(ids)-1], nil
+		}
+		return "", errors.New("no runs in the report store")
+	case Previous:
+		if len(ids) > 1 {
+			return ids[len(ids)-2], nil
+		}
+		return "", errors.New("no previous run in the report store")
+	}
+
+	var matches []string
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+		if strings.HasPrefix(id, ref) {
+			matches = append(matches, id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("run not found [%s]", ref)
+	case 1:
+		return matches[0], nil
+	}
+
+	return "", fmt.Errorf("ambiguous run [%s]: %s", ref, strings.Join(matches, ", "))
+}
+
+// ids returns the ids of the runs sorted by the start time
+func (s *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report store: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), eventsSuffix); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// load returns the report of the run, rebuilt from its events if the run
+// has no report. It returns true if the report was written by the run.
+func (s *Store) load(id string) (domain.Report, bool, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id+reportSuffix))
+	if err == nil {
+		var report domain.Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return domain.Report{}, false, fmt.Errorf("failed to parse report [%s]: %w", id, err)
+		}
+		return report, true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return domain.Report{}, false, fmt.Errorf("failed to read report [%s]: %w", id, err)
+	}
+
+	events, err := s.events(id)
+	if err != nil {
+		return domain.Report{}, false, err
+	}
+
+	return reporter.ReportOfEvents(events), false, nil
+}
+
+// events reads the events of the run, the last line of an interrupted run
+// may be truncated and is skipped
+func (s *Store) events(id string) ([]domain.ReportEvent, error) {
+	file, err := os.Open(filepath.Join(s.dir, id+eventsSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events [%s]: %w", id, err)
+	}
+	defer file.Close()
+
+	var events []domain.ReportEvent
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var event domain.ReportEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events [%s]: %w", id, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,88 @@
+package runstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestStore(t *testing.T) {
+	var dir = t.TempDir()
+	var now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open the store: %v", err)
+	}
+
+	// a complete run
+	first, err := store.Create(now)
+	if err != nil {
+		t.Fatalf("failed to create the run: %v", err)
+	}
+	var event = domain.ReportEvent{DestinationAddress: "1.1.1.1", DestinationPort: 443, Policy: domain.EventPolicyStatusPass, Timestamp: now}
+	if err := first.Write(event); err != nil {
+		t.Fatalf("failed to write the event: %v", err)
+	}
+	if err := first.Finish(domain.Report{StartedAt: now, Mode: "trace", Events: []domain.ReportEvent{event}, Summary: domain.ReportSummary{Total: 1, Pass: 1}}); err != nil {
+		t.Fatalf("failed to finish the run: %v", err)
+	}
+	first.Close()
+
+	// an interrupted run, the last line is truncated
+	second, err := store.Create(now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create the run: %v", err)
+	}
+	for _, e := range []domain.ReportEvent{
+		{DestinationAddress: "2.2.2.2", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Timestamp: now.Add(time.Hour)},
+		{DestinationAddress: "2.2.2.2", DestinationPort: 443, Policy: domain.EventPolicyStatusBlock, Timestamp: now.Add(2 * time.Hour)},
+	} {
+		if err := second.Write(e); err != nil {
+			t.Fatalf("failed to write the event: %v", err)
+		}
+	}
+	second.Close()
+	f, err := os.OpenFile(filepath.Join(dir, second.ID+eventsSuffix), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"daddr":"3.3.`)
+	f.Close()
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatalf("failed to list the runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != first.ID || runs[1].ID != second.ID {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+	if !runs[0].Complete || runs[0].Mode != "trace" || runs[0].Summary.Pass != 1 {
+		t.Errorf("unexpected complete run: %+v", runs[0])
+	}
+	if runs[1].Complete || runs[1].Summary.Total != 1 || runs[1].Summary.Block != 1 || !runs[1].FinishedAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("unexpected interrupted run: %+v", runs[1])
+	}
+
+	for ref, want := range map[string]string{
+		Latest:             second.ID,
+		Previous:           first.ID,
+		first.ID:           first.ID,
+		"20240301T01":      second.ID,
+		"20240301T000000Z": first.ID,
+	} {
+		id, _, err := store.Load(ref)
+		if err != nil || id != want {
+			t.Errorf("%s: expected %s, got %s (%v)", ref, want, id, err)
+		}
+	}
+
+	for _, ref := range []string{"2024", "2023"} {
+		if _, _, err := store.Load(ref); err == nil {
+			t.Errorf("%s: expected an error", ref)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sandbox
+
+import (
+	"encoding/binary"
+	"syscall"
+)
+
+// Flow is an outgoing connection observed at the network boundary of a sandbox
+type Flow struct {
+	Daddr [4]byte // destination address (network byte order)
+	Dport uint16
+	Proto uint8 // IPPROTO_TCP or IPPROTO_UDP
+}
+
+// parseFlow returns the flow and the source port of the IPv4 packet
+func parseFlow(packet []byte) (Flow, uint16, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return Flow{}, 0, false
+	}
+
+	var ihl = int(packet[0]&0x0f) * 4
+	var proto = packet[9]
+	if ihl < 20 || len(packet) < ihl+4 {
+		return Flow{}, 0, false
+	}
+	if proto != syscall.IPPROTO_TCP && proto != syscall.IPPROTO_UDP {
+		return Flow{}, 0, false
+	}
+
+	var flow = Flow{
+		Dport: binary.BigEndian.Uint16(packet[ihl+2:]),
+		Proto: proto,
+	}
+	copy(flow.Daddr[:], packet[16:20])
+
+	return flow, binary.BigEndian.Uint16(packet[ihl:]), true
+}
@@ -0,0 +1,198 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// snapLen is the capture length, the IP and the transport headers
+	snapLen = 128
+	// readTimeout is the interval of the context checks of the read loop
+	readTimeout = 500 * time.Millisecond
+	// flowTTL is the interval of the repeated reports of a flow (UDP
+	// datagrams, SYN retransmits)
+	flowTTL = time.Minute
+	// maxFlows is the number of the flows kept, the expired flows are removed above
+	maxFlows = 4096
+)
+
+// the ancillary data offsets of the packet filter (linux/filter.h)
+const (
+	skfAdOff      = 0xfffff000
+	skfAdProtocol = 0
+	skfAdPktType  = 4
+)
+
+// filter accepts the first fragments of the outgoing IPv4 UDP datagrams and
+// TCP SYNs (without ACK), the offsets are relative to the IP header of the
+// cooked packets
+var filter = []unix.SockFilter{
+	/* 0 */ {Code: unix.BPF_LD | unix.BPF_H | unix.BPF_ABS, K: skfAdOff + skfAdProtocol},
+	/* 1 */ {Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: 11, K: unix.ETH_P_IP},
+	/* 2 */ {Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: skfAdOff + skfAdPktType},
+	/* 3 */ {Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: 9, K: unix.PACKET_OUTGOING},
+	/* 4 */ {Code: unix.BPF_LD | unix.BPF_H | unix.BPF_ABS, K: 6},
+	/* 5 */ {Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, Jt: 7, Jf: 0, K: 0x1fff},
+	/* 6 */ {Code: unix.BPF_LD | unix.BPF_B | unix.BPF_ABS, K: 9},
+	/* 7 */ {Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 6, Jf: 0, K: unix.IPPROTO_UDP},
+	/* 8 */ {Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: 4, K: unix.IPPROTO_TCP},
+	/* 9 */ {Code: unix.BPF_LDX | unix.BPF_B | unix.BPF_MSH, K: 0},
+	/* 10 */ {Code: unix.BPF_LD | unix.BPF_B | unix.BPF_IND, K: 13},
+	/* 11 */ {Code: unix.BPF_ALU | unix.BPF_AND | unix.BPF_K, K: 0x12},
+	/* 12 */ {Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, Jf: 0, K: 0x02},
+	/* 13 */ {Code: unix.BPF_RET | unix.BPF_K, K: 0},
+	/* 14 */ {Code: unix.BPF_RET | unix.BPF_K, K: snapLen},
+}
+
+// Observer observes the outgoing connections of a sandbox at its network
+// boundary with a packet socket in the network namespace of the sandbox: the
+// TCP SYNs and the UDP datagrams leaving the sandbox (the veth of a gVisor
+// sandbox, the veth bridged to the tap device of a Kata VM)
+type Observer struct {
+	fd    int
+	flows map[flowKey]time.Time
+}
+
+type flowKey struct {
+	proto uint8
+	sport uint16
+	daddr [4]byte
+	dport uint16
+}
+
+// Observe opens the packet socket in the network namespace of the sandbox process
+func Observe(pid uint32) (*Observer, error) {
+	ns, err := os.Open(fmt.Sprintf("%s/%d/ns/net", procDir, pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open network namespace of the sandbox: %w", err)
+	}
+	defer ns.Close()
+
+	type result struct {
+		fd  int
+		err error
+	}
+	var ch = make(chan result, 1)
+
+	go func() {
+		// the thread is not unlocked, it's discarded when the goroutine returns
+		runtime.LockOSThread()
+
+		if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			ch <- result{err: fmt.Errorf("failed to enter network namespace of the sandbox: %w", err)}
+			return
+		}
+
+		// the socket belongs to the namespace it's created in
+		fd, err := listen()
+		ch <- result{fd, err}
+	}()
+
+	r := <-ch
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &Observer{fd: r.fd, flows: make(map[flowKey]time.Time)}, nil
+}
+
+// listen opens a packet socket of the outgoing packets, the outgoing packets
+// are only seen by the sockets of all the protocols
+func listen() (int, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+
+	var prog = unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		unix.Close(fd)
+		return 0, fmt.Errorf("failed to attach packet filter: %w", err)
+	}
+
+	var tv = unix.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return 0, fmt.Errorf("failed to set packet socket timeout: %w", err)
+	}
+
+	return fd, nil
+}
+
+// Run reads the packets until the context is done and calls the handler with
+// each new flow, the repeated packets of a flow are reported once a minute
+func (o *Observer) Run(ctx context.Context, handler func(Flow)) error {
+	var buf = make([]byte, snapLen)
+	for ctx.Err() == nil {
+		n, from, err := unix.Recvfrom(o.fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("failed to read packet: %w", err)
+		}
+
+		if ll, ok := from.(*unix.SockaddrLinklayer); !ok || ll.Pkttype != unix.PACKET_OUTGOING {
+			continue
+		}
+
+		flow, sport, ok := parseFlow(buf[:n])
+		if !ok || !o.isNew(flow, sport, time.Now()) {
+			continue
+		}
+		handler(flow)
+	}
+
+	return nil
+}
+
+// isNew returns true if the flow is not seen in the last minute
+func (o *Observer) isNew(flow Flow, sport uint16, now time.Time) bool {
+	var key = flowKey{proto: flow.Proto, sport: sport, daddr: flow.Daddr, dport: flow.Dport}
+	if seenAt, ok := o.flows[key]; ok && now.Sub(seenAt) < flowTTL {
+		return false
+	}
+
+	if len(o.flows) >= maxFlows {
+		for k, seenAt := range o.flows {
+			if now.Sub(seenAt) >= flowTTL {
+				delete(o.flows, k)
+			}
+		}
+	}
+	o.flows[key] = now
+
+	return true
+}
+
+// Close closes the packet socket
+func (o *Observer) Close() error {
+	return unix.Close(o.fd)
+}
+
+// netnsOf returns the inode of the network namespace file
+func netnsOf(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("failed to read the inode of %s", path)
+	}
+
+	return stat.Ino, nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+)
+
+// Observer is not supported on this platform
+type Observer struct{}
+
+// Observe returns an error, the packet sockets are linux only
+func Observe(pid uint32) (*Observer, error) {
+	return nil, errors.New("the sandbox observation requires linux")
+}
+
+// Run does nothing on this platform
+func (o *Observer) Run(ctx context.Context, handler func(Flow)) error {
+	return nil
+}
+
+// Close does nothing on this platform
+func (o *Observer) Close() error {
+	return nil
+}
+
+func netnsOf(path string) (uint64, error) {
+	return 0, errors.New("network namespaces require linux")
+}
@@ -0,0 +1,114 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+const (
+	// RuntimeGVisor is the gVisor (runsc) sandbox, the syscalls of the guest
+	// processes are handled by the gVisor kernel and its network stack
+	RuntimeGVisor = "gvisor"
+	// RuntimeKata is the Kata Containers VM, the guest processes run on the guest kernel
+	RuntimeKata = "kata"
+)
+
+const procDir = "/proc"
+
+// vmms are the task names of the virtual machine monitors of Kata, the task
+// names are truncated to 15 characters (cloud-hypervisor)
+var vmms = []string{"qemu-system-", "qemu-kvm", "cloud-hyperviso", "firecracker", "dragonball"}
+
+// Sandbox is the process of a sandboxed container runtime. The host probes
+// and the cgroup programs don't see the connections of its guest processes.
+type Sandbox struct {
+	Runtime string
+	Pid     uint32
+	Comm    string
+	// Netns is the inode of the network namespace, the containers of a pod share the sandbox
+	Netns uint64
+}
+
+// Detect returns the sandboxes running on the host, one per network namespace
+func Detect() ([]Sandbox, error) {
+	return detect(procDir)
+}
+
+func detect(root string) ([]Sandbox, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sandboxes []Sandbox
+		seen      = make(map[uint64]bool)
+	)
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		// the processes exited while reading are skipped
+		var base = filepath.Join(root, entry.Name())
+		comm, err := os.ReadFile(filepath.Join(base, "comm"))
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(base, "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		var name = strings.TrimSpace(string(comm))
+		runtime := runtimeOf(name, process.SplitArgs(cmdline))
+		if runtime == "" {
+			continue
+		}
+
+		netns, err := netnsOf(filepath.Join(base, "ns", "net"))
+		if err != nil || seen[netns] {
+			continue
+		}
+		seen[netns] = true
+
+		sandboxes = append(sandboxes, Sandbox{Runtime: runtime, Pid: uint32(pid), Comm: name, Netns: netns})
+	}
+
+	return sandboxes, nil
+}
+
+// runtimeOf returns the sandbox runtime of the process, empty if it's not a sandbox
+func runtimeOf(comm string, args []string) string {
+	var argv0 string
+	if len(args) > 0 {
+		argv0 = filepath.Base(args[0])
+	}
+
+	switch {
+	case comm == "runsc-sandbox" || argv0 == "runsc-sandbox":
+		return RuntimeGVisor
+	case argv0 == "runsc" && slices.Contains(args, "boot"):
+		return RuntimeGVisor
+	case isVMM(comm) && slices.ContainsFunc(args, func(arg string) bool { return strings.Contains(arg, "kata") }):
+		return RuntimeKata
+	}
+
+	return ""
+}
+
+func isVMM(comm string) bool {
+	for _, vmm := range vmms {
+		if strings.HasPrefix(comm, vmm) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeOf(t *testing.T) {
+	tests := []struct {
+		comm string
+		args []string
+		want string
+	}{
+		{"runsc-sandbox", []string{"runsc-sandbox", "--root=/run/containerd/runsc/k8s.io", "boot"}, RuntimeGVisor},
+		{"exe", []string{"/usr/local/bin/runsc", "--network=sandbox", "boot", "--bundle=/run/bundle"}, RuntimeGVisor},
+		{"runsc", []string{"/usr/local/bin/runsc", "gofer"}, ""},
+		{"qemu-system-x86", []string{"/opt/kata/bin/qemu-system-x86_64", "-name", "sandbox-5f2c"}, RuntimeKata},
+		{"cloud-hyperviso", []string{"/opt/kata/bin/cloud-hypervisor", "--api-socket", "/run/vc/vm/5f2c/clh-api.sock"}, RuntimeKata},
+		{"qemu-system-x86", []string{"/usr/bin/qemu-system-x86_64", "-hda", "disk.img"}, ""},
+		{"curl", []string{"curl", "https://github.com"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := runtimeOf(tt.comm, tt.args); got != tt.want {
+			t.Errorf("runtimeOf(%s, %v) = %q, want %q", tt.comm, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	var root = t.TempDir()
+
+	process := func(pid, comm string, args []string, netns string) {
+		var dir = filepath.Join(root, pid)
+		if err := os.MkdirAll(filepath.Join(dir, "ns"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(strings.Join(args, "\x00")+"\x00"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// the namespaces are files of the test, the processes of a namespace share the file
+		if err := os.Link(filepath.Join(root, netns), filepath.Join(dir, "ns", "net")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ns := range []string{"pod-a", "pod-b", "host"} {
+		if err := os.WriteFile(filepath.Join(root, ns), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process("100", "runsc-sandbox", []string{"runsc-sandbox", "boot"}, "pod-a")
+	process("101", "runsc-sandbox", []string{"runsc-sandbox", "boot"}, "pod-a")
+	process("200", "qemu-system-x86", []string{"/opt/kata/bin/qemu-system-x86_64", "-name", "sandbox-5f2c"}, "pod-b")
+	process("300", "sshd", []string{"/usr/sbin/sshd", "-D"}, "host")
+
+	sandboxes, err := detect(root)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+
+	if len(sandboxes) != 2 {
+		t.Fatalf("expected a sandbox per network namespace, got %+v", sandboxes)
+	}
+	if s := sandboxes[0]; s.Runtime != RuntimeGVisor || s.Pid != 100 || s.Comm != "runsc-sandbox" {
+		t.Errorf("unexpected gVisor sandbox: %+v", s)
+	}
+	if s := sandboxes[1]; s.Runtime != RuntimeKata || s.Pid != 200 {
+		t.Errorf("unexpected Kata sandbox: %+v", s)
+	}
+}
+
+func TestParseFlow(t *testing.T) {
+	// IPv4 (ihl 5) UDP 10.0.0.2:40000 -> 8.8.8.8:53
+	var packet = []byte{
+		0x45, 0, 0, 28, 0, 0, 0x40, 0, 64, 17, 0, 0,
+		10, 0, 0, 2,
+		8, 8, 8, 8,
+		0x9c, 0x40, 0, 53, 0, 8, 0, 0,
+	}
+
+	flow, sport, ok := parseFlow(packet)
+	if !ok {
+		t.Fatal("expected a flow")
+	}
+	if flow.Daddr != [4]byte{8, 8, 8, 8} || flow.Dport != 53 || flow.Proto != 17 || sport != 40000 {
+		t.Errorf("unexpected flow: %+v %d", flow, sport)
+	}
+
+	// ICMP and truncated packets
+	packet[9] = 1
+	if _, _, ok := parseFlow(packet); ok {
+		t.Error("expected no flow of an ICMP packet")
+	}
+	if _, _, ok := parseFlow(packet[:22]); ok {
+		t.Error("expected no flow of a truncated packet")
+	}
+}
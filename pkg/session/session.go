@@ -0,0 +1,190 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Tracker keeps the state of the running session and
+// persists it periodically to the state file
+type Tracker struct {
+	mu       sync.Mutex
+	path     string
+	session  domain.Session
+	allowed  func() []domain.AllowEntry
+	timeline func() []domain.TimeBucket
+}
+
+// NewTracker returns a new session tracker
+func NewTracker(path, id, mode string) *Tracker {
+	return &Tracker{
+		path: path,
+		session: domain.Session{
+			ID:        id,
+			Mode:      mode,
+			StartedAt: time.Now(),
+		},
+	}
+}
+
+// DefaultID returns the session id of the CI job if available.
+// A restarted kntrl in the same job resumes the same session.
+func DefaultID() string {
+	if runID := os.Getenv("GITHUB_RUN_ID"); runID != "" {
+		return fmt.Sprintf("%s-%s-%s", runID, os.Getenv("GITHUB_RUN_ATTEMPT"), os.Getenv("GITHUB_JOB"))
+	}
+
+	if jobID := os.Getenv("CI_JOB_ID"); jobID != "" {
+		return jobID
+	}
+
+	return ""
+}
+
+// Resume loads the previous state of the same session.
+// It returns nil if there's no state to resume.
+func (t *Tracker) Resume() (*domain.Session, error) {
+	prev, err := Load(t.path)
+	if err != nil || prev == nil {
+		return nil, err
+	}
+
+	if prev.ID != t.session.ID || prev.Mode != t.session.Mode {
+		logger.Log.Warnf("ignoring the state of another session [%s/%s]", prev.ID, prev.Mode)
+		return nil, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.session.StartedAt = prev.StartedAt
+	t.session.AllowedIPs = prev.AllowedIPs
+
+	return prev, nil
+}
+
+// AddAllowedIP records a dynamic allow list addition
+func (t *Tracker) AddAllowedIP(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.session.AllowedIPs = append(t.session.AllowedIPs, ip)
+}
+
+// RemoveAllowedIP removes a dynamic allow list addition
+func (t *Tracker) RemoveAllowedIP(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var allowed = t.session.AllowedIPs[:0]
+	for _, a := range t.session.AllowedIPs {
+		if a != ip {
+			allowed = append(allowed, a)
+		}
+	}
+	t.session.AllowedIPs = allowed
+}
+
+// SetAllowed sets the source of the allow list entries (provenance) written in the state
+func (t *Tracker) SetAllowed(allowed func() []domain.AllowEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.allowed = allowed
+}
+
+// SetTimeline sets the source of the connections per minute written in the state
+func (t *Tracker) SetTimeline(timeline func() []domain.TimeBucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timeline = timeline
+}
+
+// Save persists the session state with the given events
+func (t *Tracker) Save(events []domain.ReportEvent) error {
+	t.mu.Lock()
+	t.session.Events = events
+	if t.allowed != nil {
+		t.session.Allowed = t.allowed()
+	}
+	if t.timeline != nil {
+		t.session.Timeline = t.timeline()
+	}
+	t.session.UpdatedAt = time.Now()
+	data, err := json.Marshal(t.session)
+	t.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	return writeFile(t.path, data)
+}
+
+// Run saves the session state in every interval until stop is closed
+func (t *Tracker) Run(interval time.Duration, events func() []domain.ReportEvent, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Save(events()); err != nil {
+				logger.Log.Errorf("failed to save session state: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Load reads the session state file
+func Load(path string) (*domain.Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s domain.Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state file: %w", err)
+	}
+
+	return &s, nil
+}
+
+// writeFile replaces the file atomically, a crash while writing
+// must not corrupt the previous state
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close state file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestTracker_Resume(t *testing.T) {
+	var stateFile = filepath.Join(t.TempDir(), "kntrl.state")
+
+	tracker := NewTracker(stateFile, "42", domain.TracerModeTrace)
+	tracker.AddAllowedIP("1.1.1.1")
+	tracker.SetAllowed(func() []domain.AllowEntry {
+		return []domain.AllowEntry{{Address: "1.1.1.1", Source: domain.AllowSourcePolicy}}
+	})
+	if err := tracker.Save([]domain.ReportEvent{{ProcessID: 234, DestinationAddress: "1.1.1.1", DestinationPort: 443}}); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	// same session
+	prev, err := NewTracker(stateFile, "42", domain.TracerModeTrace).Resume()
+	if err != nil {
+		t.Fatalf("failed to resume session: %v", err)
+	}
+	if prev == nil || len(prev.Events) != 1 || len(prev.AllowedIPs) != 1 {
+		t.Errorf("expected 1 event and 1 allowed ip, got %+v", prev)
+	}
+	if prev != nil && (len(prev.Allowed) != 1 || prev.Allowed[0].Source != domain.AllowSourcePolicy) {
+		t.Errorf("expected the provenance of the allowed ip, got %+v", prev.Allowed)
+	}
+
+	// another session
+	prev, err = NewTracker(stateFile, "43", domain.TracerModeTrace).Resume()
+	if err != nil || prev != nil {
+		t.Errorf("expected the state of another session to be ignored, got %+v %v", prev, err)
+	}
+
+	// no state file
+	os.Remove(stateFile)
+	prev, err = NewTracker(stateFile, "42", domain.TracerModeTrace).Resume()
+	if err != nil || prev != nil {
+		t.Errorf("expected nothing to resume, got %+v %v", prev, err)
+	}
+}
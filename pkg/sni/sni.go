@@ -0,0 +1,288 @@
+package sni
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TLSPort and HTTPPort are the destination ports of the sampled
+	// TLS handshakes and plaintext HTTP requests
+	TLSPort  = 443
+	HTTPPort = 80
+
+	// cacheTTL is the lifetime of a server name of a destination,
+	// the CDN addresses are shared by many names
+	cacheTTL = 10 * time.Minute
+	// maxEntries caps the cache, the oldest entries are dropped first
+	maxEntries = 16384
+
+	tlsRecordHandshake   = 0x16
+	tlsClientHello       = 0x01
+	tlsExtServerName     = 0x0000
+	tlsServerNameTypeDNS = 0x00
+)
+
+// Handler is called with the server name of a ClientHello or the Host
+// header of an HTTP request sent to the destination
+type Handler func(address string, port uint16, name string)
+
+// httpMethods are the methods of the sampled HTTP requests
+var httpMethods = []string{"GET ", "POST ", "HEAD ", "PUT ", "DELETE ", "OPTIONS ", "PATCH "}
+
+// Name returns the server name of the TLS ClientHello or the host of the
+// HTTP request at the beginning of the payload
+func Name(payload []byte) (string, bool) {
+	if name, ok := ServerName(payload); ok {
+		return name, true
+	}
+
+	return HTTPHost(payload)
+}
+
+// HTTPHost returns the Host header of the HTTP/1.x request at the beginning
+// of the payload, the port of the host is removed and the addresses are skipped. The headers are expected
+// in the first segment.
+func HTTPHost(payload []byte) (string, bool) {
+	var request = string(payload)
+	if !slices.ContainsFunc(httpMethods, func(m string) bool { return strings.HasPrefix(request, m) }) {
+		return "", false
+	}
+
+	lines := strings.Split(request, "\r\n")
+	if !strings.Contains(lines[0], " HTTP/1.") {
+		return "", false
+	}
+
+	for _, line := range lines[1:] {
+		if line == "" {
+			// the end of the headers
+			break
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "host") {
+			continue
+		}
+
+		var host = strings.TrimSpace(value)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		// an address is not a name of the destination
+		if host == "" || net.ParseIP(host) != nil {
+			return "", false
+		}
+
+		return strings.ToLower(host), true
+	}
+
+	return "", false
+}
+
+// ServerName returns the server name (SNI) of the TLS ClientHello at the
+// beginning of the payload. The ClientHello is expected in the first segment.
+func ServerName(payload []byte) (string, bool) {
+	// record header: type, version, length
+	if len(payload) < 5 || payload[0] != tlsRecordHandshake {
+		return "", false
+	}
+	var record = payload[5:]
+	if n := int(binary.BigEndian.Uint16(payload[3:5])); n < len(record) {
+		record = record[:n]
+	}
+
+	// handshake header: type, length (3 bytes)
+	if len(record) < 4 || record[0] != tlsClientHello {
+		return "", false
+	}
+	var hello = record[4:]
+
+	// client version and random
+	var r = reader(hello)
+	if !r.skip(2 + 32) {
+		return "", false
+	}
+	// session id, cipher suites and compression methods
+	if !r.skipVector(1) || !r.skipVector(2) || !r.skipVector(1) {
+		return "", false
+	}
+
+	extensions, ok := r.vector(2)
+	if !ok {
+		return "", false
+	}
+
+	for len(extensions) >= 4 {
+		var typ = binary.BigEndian.Uint16(extensions)
+		var ext = reader(extensions[2:])
+		data, ok := ext.vector(2)
+		if !ok {
+			return "", false
+		}
+		extensions = ext
+
+		if typ != tlsExtServerName {
+			continue
+		}
+
+		var names = reader(data)
+		list, ok := names.vector(2)
+		if !ok {
+			return "", false
+		}
+		for len(list) >= 3 {
+			var nameType = list[0]
+			var entry = reader(list[1:])
+			name, ok := entry.vector(2)
+			if !ok {
+				return "", false
+			}
+			list = entry
+
+			if nameType == tlsServerNameTypeDNS && len(name) > 0 {
+				return strings.ToLower(string(name)), true
+			}
+		}
+
+		return "", false
+	}
+
+	return "", false
+}
+
+// Packet returns the destination and the TCP payload of an IPv4 packet
+func Packet(packet []byte) (net.IP, uint16, []byte, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 || packet[9] != 6 {
+		return nil, 0, nil, false
+	}
+
+	var ihl = int(packet[0]&0x0f) * 4
+	var total = int(binary.BigEndian.Uint16(packet[2:4]))
+	if total > len(packet) || total < ihl {
+		// the packet is truncated by the capture length
+		total = len(packet)
+	}
+	if ihl < 20 || total < ihl+20 {
+		return nil, 0, nil, false
+	}
+
+	var tcp = packet[ihl:total]
+	var offset = int(tcp[12]>>4) * 4
+	if offset < 20 || offset > len(tcp) {
+		return nil, 0, nil, false
+	}
+
+	return net.IP(packet[16:20]), binary.BigEndian.Uint16(tcp[2:4]), tcp[offset:], true
+}
+
+// reader reads the TLS vectors with a length prefix
+type reader []byte
+
+func (r *reader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+
+	return true
+}
+
+func (r *reader) vector(lenBytes int) ([]byte, bool) {
+	if len(*r) < lenBytes {
+		return nil, false
+	}
+
+	var n int
+	for _, b := range (*r)[:lenBytes] {
+		n = n<<8 | int(b)
+	}
+	*r = (*r)[lenBytes:]
+
+	if len(*r) < n {
+		return nil, false
+	}
+	var v = (*r)[:n]
+	*r = (*r)[n:]
+
+	return v, true
+}
+
+func (r *reader) skipVector(lenBytes int) bool {
+	_, ok := r.vector(lenBytes)
+	return ok
+}
+
+// Cache keeps the last server name of the destinations
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+type entry struct {
+	name   string
+	seenAt time.Time
+}
+
+// NewCache returns an empty cache
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Add records the server name of the destination
+func (c *Cache) Add(address string, port uint16, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var now = c.now()
+	if len(c.entries) >= maxEntries {
+		c.evict(now)
+	}
+
+	c.entries[key(address, port)] = entry{name: name, seenAt: now}
+}
+
+// Lookup returns the last server name of the destination
+func (c *Cache) Lookup(address string, port uint16) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key(address, port)]
+	if !ok || c.now().Sub(e.seenAt) > cacheTTL {
+		return "", false
+	}
+
+	return e.name, true
+}
+
+// evict removes the expired entries, the oldest entry if none expired
+func (c *Cache) evict(now time.Time) {
+	var oldest string
+	var oldestAt time.Time
+	for k, e := range c.entries {
+		if now.Sub(e.seenAt) > cacheTTL {
+			delete(c.entries, k)
+			continue
+		}
+		if oldest == "" || e.seenAt.Before(oldestAt) {
+			oldest, oldestAt = k, e.seenAt
+		}
+	}
+
+	if len(c.entries) >= maxEntries {
+		delete(c.entries, oldest)
+	}
+}
+
+func key(address string, port uint16) string {
+	return net.JoinHostPort(address, strconv.Itoa(int(port)))
+}
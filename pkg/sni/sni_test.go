@@ -0,0 +1,126 @@
+package sni
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// clientHello returns the first record written by a TLS client
+func clientHello(t *testing.T, serverName string) []byte {
+	t.Helper()
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		conn := tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		_ = conn.Handshake()
+	}()
+
+	var buf = make([]byte, 4096)
+	_ = server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read the client hello: %v", err)
+	}
+	client.Close()
+
+	return buf[:n]
+}
+
+func TestServerName(t *testing.T) {
+	hello := clientHello(t, "API.GitHub.com")
+
+	name, ok := ServerName(hello)
+	if !ok || name != "api.github.com" {
+		t.Errorf("expected the server name api.github.com, got %q", name)
+	}
+
+	// truncated in the extensions
+	if _, ok := ServerName(hello[:60]); ok {
+		t.Error("expected no server name of a truncated record")
+	}
+	if _, ok := ServerName([]byte("GET / HTTP/1.1\r\n")); ok {
+		t.Error("expected no server name of a plain text payload")
+	}
+
+	// the client hello without the server name extension
+	if _, ok := ServerName(clientHello(t, "")); ok {
+		t.Error("expected no server name")
+	}
+}
+
+func TestPacket(t *testing.T) {
+	var payload = []byte{0x16, 0x03, 0x01}
+	var packet = make([]byte, 20+20+len(payload))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	packet[9] = 6
+	copy(packet[16:20], net.ParseIP("140.82.114.22").To4())
+	binary.BigEndian.PutUint16(packet[22:24], TLSPort)
+	packet[32] = 5 << 4
+	copy(packet[40:], payload)
+
+	daddr, dport, data, ok := Packet(packet)
+	if !ok {
+		t.Fatal("expected a TCP packet")
+	}
+	if daddr.String() != "140.82.114.22" || dport != TLSPort || string(data) != string(payload) {
+		t.Errorf("unexpected packet: %s:%d %x", daddr, dport, data)
+	}
+
+	// UDP
+	packet[9] = 17
+	if _, _, _, ok := Packet(packet); ok {
+		t.Error("expected no TCP packet")
+	}
+}
+
+func TestCache(t *testing.T) {
+	var now = time.Now()
+	var cache = NewCache()
+	cache.now = func() time.Time { return now }
+
+	cache.Add("140.82.114.22", 443, "api.github.com")
+	if name, ok := cache.Lookup("140.82.114.22", 443); !ok || name != "api.github.com" {
+		t.Errorf("unexpected lookup: %s", name)
+	}
+	if _, ok := cache.Lookup("140.82.114.22", 8443); ok {
+		t.Error("expected no server name of another port")
+	}
+
+	now = now.Add(cacheTTL + time.Second)
+	if _, ok := cache.Lookup("140.82.114.22", 443); ok {
+		t.Error("expected the entry to expire")
+	}
+}
+
+func TestHTTPHost(t *testing.T) {
+	var tests = map[string]struct {
+		payload string
+		host    string
+		ok      bool
+	}{
+		"host":       {"GET /index.html HTTP/1.1\r\nUser-Agent: curl\r\nHost: Example.com\r\n\r\n", "example.com", true},
+		"port":       {"POST /upload HTTP/1.1\r\nhost: example.com:8080\r\n\r\n", "example.com", true},
+		"address":    {"GET / HTTP/1.1\r\nHost: 93.184.216.34:80\r\n\r\n", "", false},
+		"no_host":    {"GET / HTTP/1.0\r\nAccept: */*\r\n\r\n", "", false},
+		"body":       {"PUT / HTTP/1.1\r\n\r\nHost: example.com\r\n", "", false},
+		"not_http":   {"SSH-2.0-OpenSSH_9.6\r\n", "", false},
+		"not_method": {"GETX / HTTP/1.1\r\nHost: example.com\r\n\r\n", "", false},
+	}
+
+	for name, test := range tests {
+		host, ok := HTTPHost([]byte(test.payload))
+		if ok != test.ok || host != test.host {
+			t.Errorf("[%s] expected %q (%v), got %q (%v)", name, test.host, test.ok, host, ok)
+		}
+	}
+
+	if name, ok := Name([]byte("HEAD / HTTP/1.1\r\nHost: example.com\r\n\r\n")); !ok || name != "example.com" {
+		t.Errorf("unexpected name: %q", name)
+	}
+}
@@ -0,0 +1,129 @@
+package sni
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// snapLen is the capture length, a ClientHello fits in the first segment
+	snapLen = 2048
+	// readTimeout is the interval of the context checks of the read loop
+	readTimeout = 500 * time.Millisecond
+)
+
+// the ancillary data offsets of the packet filter (linux/filter.h)
+const (
+	skfAdOff      = 0xfffff000
+	skfAdProtocol = 0
+	skfAdPktType  = 4
+)
+
+// newFilter returns the filter of the first fragments of the outgoing IPv4
+// TCP packets to the ports, the offsets are relative to the IP header of the
+// cooked packets
+func newFilter(ports []uint16) []unix.SockFilter {
+	var (
+		n      = len(ports)
+		drop   = 10 + n
+		accept = drop + 1
+	)
+
+	var filter = []unix.SockFilter{
+		{Code: unix.BPF_LD | unix.BPF_H | unix.BPF_ABS, K: skfAdOff + skfAdProtocol},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: uint8(drop - 2), K: unix.ETH_P_IP},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: skfAdOff + skfAdPktType},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: uint8(drop - 4), K: unix.PACKET_OUTGOING},
+		{Code: unix.BPF_LD | unix.BPF_B | unix.BPF_ABS, K: 9},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: uint8(drop - 6), K: unix.IPPROTO_TCP},
+		{Code: unix.BPF_LD | unix.BPF_H | unix.BPF_ABS, K: 6},
+		{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, Jt: uint8(drop - 8), Jf: 0, K: 0x1fff},
+		{Code: unix.BPF_LDX | unix.BPF_B | unix.BPF_MSH, K: 0},
+		{Code: unix.BPF_LD | unix.BPF_H | unix.BPF_IND, K: 2},
+	}
+	for i, port := range ports {
+		filter = append(filter, unix.SockFilter{
+			Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: uint8(accept - (11 + i)), Jf: 0, K: uint32(port),
+		})
+	}
+
+	return append(filter,
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: 0},
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: snapLen},
+	)
+}
+
+// Sniffer samples the outgoing TLS handshakes and HTTP requests of the host network namespace
+type Sniffer struct {
+	fd int
+}
+
+// Listen opens a packet socket of the outgoing packets to the ports, the
+// outgoing packets are only seen by the sockets of all the protocols
+func Listen(ports ...uint16) (*Sniffer, error) {
+	if len(ports) == 0 {
+		return nil, errors.New("no port to sample")
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+
+	var filter = newFilter(ports)
+	var prog = unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to attach packet filter: %w", err)
+	}
+
+	var tv = unix.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set packet socket timeout: %w", err)
+	}
+
+	return &Sniffer{fd: fd}, nil
+}
+
+// Run reads the packets until the context is done and calls the handler
+// with the server name of each ClientHello and the host of each HTTP request
+func (s *Sniffer) Run(ctx context.Context, handler Handler) error {
+	var buf = make([]byte, snapLen)
+	for ctx.Err() == nil {
+		n, from, err := unix.Recvfrom(s.fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("failed to read packet: %w", err)
+		}
+
+		if ll, ok := from.(*unix.SockaddrLinklayer); !ok || ll.Pkttype != unix.PACKET_OUTGOING {
+			continue
+		}
+
+		daddr, dport, payload, ok := Packet(buf[:n])
+		if !ok {
+			continue
+		}
+		if name, ok := Name(payload); ok {
+			handler(daddr.String(), dport, name)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the packet socket
+func (s *Sniffer) Close() error {
+	return unix.Close(s.fd)
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
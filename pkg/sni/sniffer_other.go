@@ -0,0 +1,26 @@
+//go:build !linux
+
+package sni
+
+import (
+	"context"
+	"errors"
+)
+
+// Sniffer is not supported on this platform
+type Sniffer struct{}
+
+// Listen returns an error, the packet sockets are linux only
+func Listen(...uint16) (*Sniffer, error) {
+	return nil, errors.New("the server name sampling requires linux packet sockets")
+}
+
+// Run does nothing on this platform
+func (s *Sniffer) Run(context.Context, Handler) error {
+	return nil
+}
+
+// Close does nothing on this platform
+func (s *Sniffer) Close() error {
+	return nil
+}
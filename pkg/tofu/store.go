@@ -0,0 +1,162 @@
+package tofu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// Store keeps the destinations trusted on first use.
+// A destination is identified by its port and either its address or
+// one of its domain names, the addresses of CDNs change between runs.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	store   domain.TrustStore
+	seen    map[string]struct{}
+	addrs   map[string]struct{}
+	domains map[string]struct{}
+}
+
+// Load reads the trust store file. An empty store is returned if the file doesn't exist.
+func Load(path string) (*Store, error) {
+	var s = &Store{
+		path:    path,
+		seen:    make(map[string]struct{}),
+		addrs:   make(map[string]struct{}),
+		domains: make(map[string]struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read trust store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.store); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trust store: %w", err)
+	}
+
+	for _, d := range s.store.Destinations {
+		s.index(d)
+	}
+
+	return s, nil
+}
+
+// Empty returns true if no destination is recorded yet (first use)
+func (s *Store) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.store.Destinations) == 0
+}
+
+// Addresses returns the recorded destination addresses
+func (s *Store) Addresses() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var addrs = make([]string, 0, len(s.store.Destinations))
+	for _, d := range s.store.Destinations {
+		addrs = append(addrs, d.Address)
+	}
+
+	return addrs
+}
+
+// Trusted returns true if the destination of the event is recorded
+func (s *Store) Trusted(event domain.ReportEvent) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.addrs[key(event.DestinationAddress, event.DestinationPort)]; ok {
+		return true
+	}
+
+	for _, d := range event.Domains {
+		if !isDomain(d) {
+			continue
+		}
+		if _, ok := s.domains[key(d, event.DestinationPort)]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Record adds the destination of the event into the store
+func (s *Store) Record(event domain.ReportEvent) {
+	var d = domain.TrustedDestination{
+		Protocol: event.Protocol,
+		Address:  event.DestinationAddress,
+		Port:     event.DestinationPort,
+	}
+	for _, name := range event.Domains {
+		if isDomain(name) {
+			d.Domains = append(d.Domains, name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[d.Protocol+"/"+key(d.Address, d.Port)]; ok {
+		return
+	}
+
+	s.store.Destinations = append(s.store.Destinations, d)
+	s.index(d)
+}
+
+// Save persists the store
+func (s *Store) Save() error {
+	s.mu.Lock()
+	if s.store.CreatedAt.IsZero() {
+		s.store.CreatedAt = time.Now()
+	}
+	s.store.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s.store, "", "  ")
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal trust store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create trust store directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write trust store: %w", err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+func (s *Store) index(d domain.TrustedDestination) {
+	s.seen[d.Protocol+"/"+key(d.Address, d.Port)] = struct{}{}
+	s.addrs[key(d.Address, d.Port)] = struct{}{}
+	for _, name := range d.Domains {
+		s.domains[key(name, d.Port)] = struct{}{}
+	}
+}
+
+func key(host string, port uint16) string {
+	return host + ":" + strconv.Itoa(int(port))
+}
+
+// isDomain filters the placeholder of the failed lookups
+func isDomain(name string) bool {
+	return name != "" && name != "."
+}
@@ -0,0 +1,53 @@
+package tofu
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestStore(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "tofu.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load store: %v", err)
+	}
+	if !s.Empty() {
+		t.Fatalf("expected an empty store")
+	}
+
+	s.Record(domain.ReportEvent{Protocol: "tcp", DestinationAddress: "140.82.114.22", DestinationPort: 443, Domains: []string{"github.com"}})
+	s.Record(domain.ReportEvent{Protocol: "tcp", DestinationAddress: "140.82.114.22", DestinationPort: 443, Domains: []string{"github.com"}})
+	s.Record(domain.ReportEvent{Protocol: "udp", DestinationAddress: "1.1.1.1", DestinationPort: 53, Domains: []string{"."}})
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("failed to save store: %v", err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatalf("failed to reload store: %v", err)
+	}
+	if got := len(s.Addresses()); got != 2 {
+		t.Fatalf("expected 2 destinations, got %d", got)
+	}
+
+	var tests = []struct {
+		name  string
+		event domain.ReportEvent
+		want  bool
+	}{
+		{"same address", domain.ReportEvent{DestinationAddress: "140.82.114.22", DestinationPort: 443}, true},
+		{"same domain", domain.ReportEvent{DestinationAddress: "140.82.112.3", DestinationPort: 443, Domains: []string{"github.com"}}, true},
+		{"other port", domain.ReportEvent{DestinationAddress: "140.82.114.22", DestinationPort: 22}, false},
+		{"failed lookup", domain.ReportEvent{DestinationAddress: "8.8.8.8", DestinationPort: 53, Domains: []string{"."}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.Trusted(tt.event); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
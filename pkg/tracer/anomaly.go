@@ -0,0 +1,172 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// AnomalyActionReport reports the connections over the anomaly thresholds
+	AnomalyActionReport = "report"
+	// AnomalyActionBlock blocks the destinations of the connections over the
+	// anomaly thresholds as the deny list, in all the modes but the passive mode
+	AnomalyActionBlock = "block"
+
+	// anomalyWindow is the window of the connection rate
+	anomalyWindow = time.Minute
+)
+
+// anomalies detects the connections to a destination over the maximum rate
+// and the processes connecting to more destinations than the maximum
+type anomalies struct {
+	// maxRate is the maximum connections per destination in the window, 0 disables
+	maxRate int
+	// maxDestinations is the maximum destinations per process, 0 disables
+	maxDestinations int
+
+	mu sync.Mutex
+	// connections are the times of the recent connections of each
+	// destination, up to maxRate+1
+	connections map[string][]time.Time
+	// destinations are the destinations of each process, up to maxDestinations+1
+	destinations map[uint32]map[string]bool
+	pruned       time.Time
+	// reported are the findings by kind and destination or process, an
+	// anomaly is reported once
+	reported map[string]bool
+	findings []domain.Finding
+}
+
+func newAnomalies(maxRate, maxDestinations int) (*anomalies, error) {
+	if maxRate < 0 || maxDestinations < 0 {
+		return nil, errors.New("anomaly thresholds must not be negative")
+	}
+
+	return &anomalies{
+		maxRate:         maxRate,
+		maxDestinations: maxDestinations,
+		connections:     make(map[string][]time.Time),
+		destinations:    make(map[uint32]map[string]bool),
+		reported:        make(map[string]bool),
+	}, nil
+}
+
+// observe records the connection of the event, it returns the kind of the
+// anomaly and the threshold exceeded, an empty kind if none
+func (a *anomalies) observe(event domain.ReportEvent) (string, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var now, addr = event.Timestamp, event.DestinationAddress
+
+	// the destinations without a connection in the window expire
+	if now.Sub(a.pruned) > anomalyWindow {
+		for dest, times := range a.connections {
+			if now.Sub(times[len(times)-1]) > anomalyWindow {
+				delete(a.connections, dest)
+			}
+		}
+		a.pruned = now
+	}
+
+	if a.maxRate > 0 {
+		var times = append(a.connections[addr], now)
+		for len(times) > 0 && now.Sub(times[0]) > anomalyWindow {
+			times = times[1:]
+		}
+		if len(times) > a.maxRate+1 {
+			times = times[len(times)-a.maxRate-1:]
+		}
+		a.connections[addr] = times
+
+		if len(times) > a.maxRate {
+			return domain.FindingKindConnectionRate, fmt.Sprintf("more than %d connections in %s", a.maxRate, anomalyWindow)
+		}
+	}
+
+	if a.maxDestinations > 0 {
+		dests, ok := a.destinations[event.ProcessID]
+		if !ok {
+			dests = make(map[string]bool)
+			a.destinations[event.ProcessID] = dests
+		}
+
+		// the destinations over the maximum are not kept, each of their
+		// connections is an anomaly
+		if !dests[addr] {
+			if len(dests) <= a.maxDestinations {
+				dests[addr] = true
+			}
+			if len(dests) > a.maxDestinations {
+				return domain.FindingKindDestinationFanout, fmt.Sprintf("more than %d destinations", a.maxDestinations)
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// report records the finding of the anomaly of the event, once per
+// destination for the connection rate and once per process for the fan-out
+func (a *anomalies) report(event domain.ReportEvent, kind, detail string) {
+	var key = kind + "/" + event.DestinationAddress
+	if kind == domain.FindingKindDestinationFanout {
+		key = kind + "/" + strconv.FormatUint(uint64(event.ProcessID), 10)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.reported[key] {
+		return
+	}
+	a.reported[key] = true
+	a.findings = append(a.findings, domain.Finding{
+		Kind:               kind,
+		ProcessID:          event.ProcessID,
+		TaskName:           event.TaskName,
+		Executable:         event.Executable,
+		Detail:             detail,
+		DestinationAddress: event.DestinationAddress,
+		DestinationPort:    event.DestinationPort,
+		Domains:            event.Domains,
+		Policy:             event.Policy,
+		Timestamp:          event.Timestamp,
+	})
+	logger.Log.Warnf("anomaly %s [%d]%s: %s -> %s:%d", kind, event.ProcessID, event.TaskName, detail,
+		event.DestinationAddress, event.DestinationPort)
+}
+
+// list returns a copy of the findings
+func (a *anomalies) list() []domain.Finding {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var findings = make([]domain.Finding, len(a.findings))
+	copy(findings, a.findings)
+
+	return findings
+}
+
+// findings returns the findings of the file access, of the anomalies and of
+// the certificates
+func (t *Tracer) findings() []domain.Finding {
+	var findings []domain.Finding
+	if t.fileAccess != nil {
+		findings = append(findings, t.fileAccess.list()...)
+	}
+	if t.anomalies != nil {
+		findings = append(findings, t.anomalies.list()...)
+	}
+	if t.certs != nil {
+		findings = append(findings, t.certs.list()...)
+	}
+
+	return findings
+}
@@ -0,0 +1,86 @@
+package tracer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestAnomalies_ConnectionRate(t *testing.T) {
+	a, err := newAnomalies(3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var event = domain.ReportEvent{ProcessID: 100, TaskName: "beacon", DestinationAddress: "203.0.113.7", DestinationPort: 443}
+
+	for i := 0; i < 3; i++ {
+		event.Timestamp = now.Add(time.Duration(i) * 10 * time.Second)
+		if kind, _ := a.observe(event); kind != "" {
+			t.Fatalf("connection %d: unexpected anomaly %s", i, kind)
+		}
+	}
+
+	event.Timestamp = now.Add(30 * time.Second)
+	kind, detail := a.observe(event)
+	if kind != domain.FindingKindConnectionRate {
+		t.Fatalf("expected a connection rate anomaly, got %q", kind)
+	}
+	a.report(event, kind, detail)
+	a.report(event, kind, detail)
+
+	// the first connections are out of the window
+	event.Timestamp = now.Add(75 * time.Second)
+	if kind, _ := a.observe(event); kind != "" {
+		t.Errorf("expected no anomaly out of the window, got %s", kind)
+	}
+
+	// the other destinations are counted apart
+	if kind, _ := a.observe(domain.ReportEvent{ProcessID: 100, DestinationAddress: "198.51.100.1", Timestamp: now}); kind != "" {
+		t.Errorf("expected no anomaly, got %s", kind)
+	}
+
+	var findings = a.list()
+	if len(findings) != 1 || findings[0].Detail != "more than 3 connections in 1m0s" {
+		t.Errorf("expected 1 finding, got %+v", findings)
+	}
+}
+
+func TestAnomalies_DestinationFanout(t *testing.T) {
+	a, err := newAnomalies(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var observe = func(pid uint32, addr string) string {
+		kind, _ := a.observe(domain.ReportEvent{ProcessID: pid, DestinationAddress: addr, Timestamp: now})
+		return kind
+	}
+
+	for i := 1; i <= 2; i++ {
+		if kind := observe(100, fmt.Sprintf("192.0.2.%d", i)); kind != "" {
+			t.Fatalf("destination %d: unexpected anomaly %s", i, kind)
+		}
+	}
+	if kind := observe(100, "192.0.2.3"); kind != domain.FindingKindDestinationFanout {
+		t.Errorf("expected a fan-out anomaly, got %q", kind)
+	}
+	if kind := observe(100, "192.0.2.4"); kind != domain.FindingKindDestinationFanout {
+		t.Errorf("expected a fan-out anomaly, got %q", kind)
+	}
+	// the known destinations and the other processes are not anomalies
+	if kind := observe(100, "192.0.2.1"); kind != "" {
+		t.Errorf("expected no anomaly for a known destination, got %s", kind)
+	}
+	if kind := observe(200, "192.0.2.3"); kind != "" {
+		t.Errorf("expected no anomaly for another process, got %s", kind)
+	}
+
+	if _, err := newAnomalies(-1, 0); err == nil {
+		t.Errorf("expected an error for a negative threshold")
+	}
+}
@@ -0,0 +1,176 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/pcap"
+)
+
+const (
+	// DefaultCapturePackets is the default number of the packets captured per violating flow
+	DefaultCapturePackets = 10
+
+	// captureSnaplen is the maximum length of a captured packet
+	captureSnaplen = 2048
+	// captureBufferSize is the per-CPU buffer of the captured packets, the
+	// packets of a burst of violations are larger than the other events
+	captureBufferSize = 64 * 4096
+	// captureEventSize is the size of the capture event before the packet
+	captureEventSize = 16
+)
+
+// validateCapture returns an error if the capture of the violating flows is
+// not available with the options: the packets are captured by the egress
+// program, it's not attached in passive mode
+func validateCapture(opts Options) error {
+	if opts.CaptureViolations == "" {
+		return nil
+	}
+
+	switch {
+	case opts.Passive:
+		return errors.New("the capture of the violations is not available in passive mode")
+	case opts.CapturePackets < 0:
+		return fmt.Errorf("invalid capture packets: %d", opts.CapturePackets)
+	}
+
+	return nil
+}
+
+// captures writes the captured packets of the violating flows into a pcap
+// file per destination, the packets of the next runs are appended
+type captures struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func newCaptures(dir string) (*captures, error) {
+	// the packets may carry credentials, the files are readable by root only
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	return &captures{dir: dir}, nil
+}
+
+// path returns the pcap file of the destination
+func (c *captures) path(addr netip.Addr, port uint16) string {
+	return filepath.Join(c.dir, fmt.Sprintf("kntrl-%s-%d.pcap", addr, port))
+}
+
+// write appends the packet into the pcap file, the header is written into a
+// new file. It returns true if the file is new.
+func (c *captures) write(path string, ts time.Time, data []byte, length int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	var w = pcap.NewWriter(f, captureSnaplen)
+	var created = info.Size() == 0
+	if created {
+		if err := w.WriteHeader(); err != nil {
+			return false, err
+		}
+	}
+
+	return created, w.WritePacket(ts, data, length)
+}
+
+// putCaptureConfig enables the capture of the violating flows in the kernel
+func (t *Tracer) putCaptureConfig() error {
+	if t.captures == nil {
+		return nil
+	}
+
+	var value = ebpfman.CaptureConfig{Packets: uint32(t.opts.CapturePackets), Snaplen: captureSnaplen}
+	configMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapCaptureConfig]
+	if err := configMap.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("%w: failed to set capture of the violations: %w", ErrMapUpdate, err)
+	}
+	logger.Log.Infof("capture of the violations: the first %d packets of the violating flows are written into %s",
+		t.opts.CapturePackets, t.captures.dir)
+
+	return nil
+}
+
+// captureFlow starts the capture of the flow of a violation not blocked by the
+// kernel (monitor mode, tarpit), the flows blocked by the kernel are captured
+// from their first packet. It returns the pcap file of the flow.
+func (t *Tracer) captureFlow(daddr ebpfman.IPv4Key, dport uint16) string {
+	var key = ebpfman.PortKey{Addr: daddr, Port: dport}
+	flowsMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapCaptureFlows]
+	err := flowsMap.Update(key, uint32(t.opts.CapturePackets), ebpf.UpdateNoExist)
+	if err != nil && !errors.Is(err, ebpf.ErrKeyExist) {
+		logger.Log.Errorf("failed to update capture flows (map): %v", err)
+	}
+
+	return t.captures.path(netip.AddrFrom4(daddr), dport)
+}
+
+// readCaptureEvents writes the captured packets into the pcap files until the
+// perf reader is closed
+func (t *Tracer) readCaptureEvents(rd *perf.Reader) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read capture event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapCaptureEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.CaptureEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse capture event: %v", err)
+			continue
+		}
+
+		// the sample is padded after the packet
+		var end = captureEventSize + int(event.CapLen)
+		if end > len(record.RawSample) {
+			logger.Log.Debugf("truncated capture event: %d bytes, expected %d", len(record.RawSample), end)
+			continue
+		}
+
+		var path = t.captures.path(netip.AddrFrom4(event.Daddr), event.Dport)
+		created, err := t.captures.write(path, t.report.Now(), record.RawSample[captureEventSize:end], int(event.Len))
+		if err != nil {
+			logger.Log.Errorf("failed to write captured packet: %v", err)
+			continue
+		}
+		if created {
+			logger.Log.Warnf("capture %s:%d: %s", netip.AddrFrom4(event.Daddr), event.Dport, path)
+		}
+	}
+}
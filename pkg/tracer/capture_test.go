@@ -0,0 +1,65 @@
+package tracer
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/pkg/pcap"
+)
+
+func TestCapturesWrite(t *testing.T) {
+	var dir = filepath.Join(t.TempDir(), "captures")
+	c, err := newCaptures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path = c.path(netip.MustParseAddr("203.0.113.7"), 4444)
+	if path != filepath.Join(dir, "kntrl-203.0.113.7-4444.pcap") {
+		t.Errorf("unexpected path: %s", path)
+	}
+
+	var packet = []byte{0x45, 0, 0, 40}
+	for i, expected := range []bool{true, false} {
+		created, err := c.write(path, time.Now(), packet, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created != expected {
+			t.Errorf("[%d] expected created %v, got %v", i, expected, created)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header once, two records of 16 bytes and the packet
+	if expected := int64(pcap.HeaderSize + 2*(16+len(packet))); info.Size() != expected {
+		t.Errorf("expected %d bytes, got %d", expected, info.Size())
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file to be readable by the owner only, got %s", info.Mode().Perm())
+	}
+}
+
+func TestValidateCapture(t *testing.T) {
+	var testCases = map[string]struct {
+		opts  Options
+		valid bool
+	}{
+		"disabled":         {Options{Passive: true, CapturePackets: -1}, true},
+		"trace":            {Options{Mode: ModeTrace, CaptureViolations: "/tmp/captures"}, true},
+		"passive":          {Options{Mode: ModeMonitor, Passive: true, CaptureViolations: "/tmp/captures"}, false},
+		"negative packets": {Options{Mode: ModeTrace, CaptureViolations: "/tmp/captures", CapturePackets: -1}, false},
+	}
+
+	for name, tc := range testCases {
+		if err := validateCapture(tc.opts); (err == nil) != tc.valid {
+			t.Errorf("[%s] expected valid %v, got %v", name, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/certpin"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// certProbeTimeout is the timeout of the probe of a certificate
+	certProbeTimeout = 5 * time.Second
+	// certProbeWorkers is the number of the concurrent probes
+	certProbeWorkers = 4
+	// certProbeQueue is the number of the probes waiting, the destinations
+	// over it are not probed
+	certProbeQueue = 256
+)
+
+// certProbe is the function probing the certificate of a destination, certpin.Probe
+type certProbe func(ctx context.Context, addr string, port uint16, serverName string) (domain.Certificate, error)
+
+// certProbes fingerprints the certificates of the allowed TLS destinations by
+// a probe connection of kntrl, the public keys not seen in the previous runs
+// are reported as findings
+type certProbes struct {
+	store *certpin.Store
+	probe certProbe
+	queue chan domain.ReportEvent
+	wg    sync.WaitGroup
+
+	mu sync.Mutex
+	// seen are the destinations probed in the run by address, host and port
+	seen     map[string]bool
+	closed   bool
+	findings []domain.Finding
+}
+
+func newCertProbes(path string) (*certProbes, error) {
+	store, err := certpin.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certProbes{
+		store: store,
+		probe: certpin.Probe,
+		queue: make(chan domain.ReportEvent, certProbeQueue),
+		seen:  make(map[string]bool),
+	}, nil
+}
+
+// start starts the probes, stop waits for them
+func (c *certProbes) start(now func() time.Time) {
+	for i := 0; i < certProbeWorkers; i++ {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			for event := range c.queue {
+				c.check(event, now())
+			}
+		}()
+	}
+}
+
+// add queues the probe of the destination of the event, once per address,
+// host and port in the run
+func (c *certProbes) add(event domain.ReportEvent) {
+	var key = event.DestinationAddress + "/" + certDestination(event)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	select {
+	case c.queue <- event:
+	default:
+		logger.Log.Debugf("certificate probe queue is full, %s is not probed", key)
+	}
+}
+
+// check probes the certificate of the destination of the event and records it
+func (c *certProbes) check(event domain.ReportEvent, now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), certProbeTimeout)
+	defer cancel()
+
+	var dest = certDestination(event)
+	cert, err := c.probe(ctx, event.DestinationAddress, event.DestinationPort, certServerName(event))
+	if err != nil {
+		logger.Log.Debugf("failed to probe the certificate of %s (%s): %v", dest, event.DestinationAddress, err)
+		return
+	}
+
+	previous, changed := c.store.Observe(dest, cert, now)
+	if !changed {
+		return
+	}
+
+	var detail = fmt.Sprintf("public key %.16s (%s) was %.16s (%s)", cert.SPKI, cert.Issuer,
+		previous.Certificate.SPKI, previous.Certificate.Issuer)
+	logger.Log.Warnf("certificate change %s (%s): %s", dest, event.DestinationAddress, detail)
+
+	c.mu.Lock()
+	c.findings = append(c.findings, domain.Finding{
+		Kind:               domain.FindingKindCertificateChange,
+		ProcessID:          event.ProcessID,
+		TaskName:           event.TaskName,
+		Executable:         event.Executable,
+		Detail:             detail,
+		DestinationAddress: event.DestinationAddress,
+		DestinationPort:    event.DestinationPort,
+		Domains:            event.Domains,
+		Policy:             event.Policy,
+		Timestamp:          now,
+	})
+	c.mu.Unlock()
+}
+
+// stop waits for the queued probes and saves the certificates
+func (c *certProbes) stop(now time.Time) {
+	c.mu.Lock()
+	c.closed = true
+	close(c.queue)
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	if err := c.store.Save(now); err != nil {
+		logger.Log.Errorf("failed to save certificates: %v", err)
+	}
+}
+
+// list returns a copy of the findings
+func (c *certProbes) list() []domain.Finding {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var findings = make([]domain.Finding, len(c.findings))
+	copy(findings, c.findings)
+
+	return findings
+}
+
+// certProbed returns true if the certificate of the destination of the event
+// is probed: the allowed TCP connections on 443 or with a TLS server name
+func certProbed(event domain.ReportEvent) bool {
+	if event.Self || event.Excluded || event.Sandbox != "" || event.Protocol != "tcp" {
+		return false
+	}
+	if event.Verdict != domain.EventVerdictAllowed || event.WouldBlock || event.Denied {
+		return false
+	}
+
+	return event.DestinationPort == 443 || event.ServerName != ""
+}
+
+// certServerName returns the server name of the probe, the TLS server name of
+// the connection or its first domain, empty if the destination has no name
+func certServerName(event domain.ReportEvent) string {
+	if event.ServerName != "" {
+		return event.ServerName
+	}
+	if len(event.Domains) > 0 {
+		return strings.TrimSuffix(event.Domains[0], ".")
+	}
+
+	return ""
+}
+
+// certDestination returns the destination of the certificate, the server name
+// (or the address) and the port
+func certDestination(event domain.ReportEvent) string {
+	var host = strings.ToLower(certServerName(event))
+	if host == "" {
+		host = event.DestinationAddress
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(event.DestinationPort)))
+}
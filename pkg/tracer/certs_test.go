@@ -0,0 +1,89 @@
+package tracer
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestCertProbes(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "certs.json")
+	var event = domain.ReportEvent{
+		ProcessID:          2231,
+		TaskName:           "npm",
+		Protocol:           "tcp",
+		DestinationAddress: "104.16.3.35",
+		DestinationPort:    443,
+		Domains:            []string{"registry.npmjs.org."},
+		Verdict:            domain.EventVerdictAllowed,
+		Policy:             domain.EventPolicyStatusPass,
+	}
+
+	var run = func(spki string) []domain.Finding {
+		c, err := newCertProbes(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var mu sync.Mutex
+		var probes []string
+		c.probe = func(_ context.Context, addr string, port uint16, serverName string) (domain.Certificate, error) {
+			mu.Lock()
+			probes = append(probes, serverName)
+			mu.Unlock()
+			return domain.Certificate{SPKI: spki, Issuer: "CA " + spki}, nil
+		}
+
+		c.start(time.Now)
+		c.add(event)
+		c.add(event)
+		c.stop(time.Now())
+
+		if len(probes) != 1 || probes[0] != "registry.npmjs.org" {
+			t.Errorf("expected a probe of the server name, got %v", probes)
+		}
+
+		return c.list()
+	}
+
+	if findings := run("key-a"); len(findings) != 0 {
+		t.Errorf("expected no finding in the first run, got %+v", findings)
+	}
+	if findings := run("key-a"); len(findings) != 0 {
+		t.Errorf("expected no finding for the same key, got %+v", findings)
+	}
+
+	findings := run("key-b")
+	if len(findings) != 1 {
+		t.Fatalf("expected a finding for the new key, got %+v", findings)
+	}
+	if f := findings[0]; f.Kind != domain.FindingKindCertificateChange || f.DestinationAddress != "104.16.3.35" ||
+		f.Detail != "public key key-b (CA key-b) was key-a (CA key-a)" {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+}
+
+func TestCertProbed(t *testing.T) {
+	var testCases = map[string]struct {
+		event    domain.ReportEvent
+		expected bool
+	}{
+		"allowed":     {domain.ReportEvent{Protocol: "tcp", DestinationPort: 443, Verdict: domain.EventVerdictAllowed}, true},
+		"server name": {domain.ReportEvent{Protocol: "tcp", DestinationPort: 8443, ServerName: "example.com", Verdict: domain.EventVerdictAllowed}, true},
+		"other port":  {domain.ReportEvent{Protocol: "tcp", DestinationPort: 8443, Verdict: domain.EventVerdictAllowed}, false},
+		"udp":         {domain.ReportEvent{Protocol: "udp", DestinationPort: 443, Verdict: domain.EventVerdictAllowed}, false},
+		"blocked":     {domain.ReportEvent{Protocol: "tcp", DestinationPort: 443, Verdict: domain.EventVerdictBlocked}, false},
+		"would block": {domain.ReportEvent{Protocol: "tcp", DestinationPort: 443, Verdict: domain.EventVerdictAllowed, WouldBlock: true}, false},
+		"self":        {domain.ReportEvent{Protocol: "tcp", DestinationPort: 443, Verdict: domain.EventVerdictAllowed, Self: true}, false},
+	}
+
+	for name, tc := range testCases {
+		if got := certProbed(tc.event); got != tc.expected {
+			t.Errorf("[%s] expected %v, got %v", name, tc.expected, got)
+		}
+	}
+}
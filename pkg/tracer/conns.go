@@ -0,0 +1,138 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/perf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// maxConns is the maximum number of the open connections tracked for the close
+// events, the connections opened beyond the limit are reported without a duration
+const maxConns = 65536
+
+// conn is an open TCP connection
+type conn struct {
+	openedUs uint64
+	// reported is true if the connect event is written to the report
+	reported bool
+	// closed is the close event received before the connect event is reported
+	closed *domain.IP4CloseEvent
+}
+
+// connTable correlates the connect and the close events of the TCP connections
+// by the socket cookie. The events are read from two perf buffers, the close
+// event may be read while the connect event is still handled.
+type connTable struct {
+	mu    sync.Mutex
+	conns map[uint64]*conn
+}
+
+func newConnTable() *connTable {
+	return &connTable{conns: make(map[uint64]*conn)}
+}
+
+// open tracks the connection of the connect event
+func (c *connTable) open(cookie, tsUs uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.conns) >= maxConns {
+		logger.Log.Debugf("too many open connections, the close of [%d] is not tracked", cookie)
+		return
+	}
+	c.conns[cookie] = &conn{openedUs: tsUs}
+}
+
+// reported marks the connect event as reported, it returns the close event
+// if the connection is already closed
+func (c *connTable) reported(cookie uint64) (uint64, *domain.IP4CloseEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cn, ok := c.conns[cookie]
+	if !ok {
+		return 0, nil
+	}
+	if cn.closed != nil {
+		delete(c.conns, cookie)
+		return cn.openedUs, cn.closed
+	}
+	cn.reported = true
+
+	return 0, nil
+}
+
+// forget untracks the connection of a connect event not reported
+func (c *connTable) forget(cookie uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.conns, cookie)
+}
+
+// close untracks the connection, it returns false if the connection is not
+// tracked or the connect event is not reported yet
+func (c *connTable) close(event domain.IP4CloseEvent) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cn, ok := c.conns[event.Cookie]
+	if !ok {
+		return 0, false
+	}
+	if !cn.reported {
+		cn.closed = &event
+		return 0, false
+	}
+	delete(c.conns, event.Cookie)
+
+	return cn.openedUs, true
+}
+
+// readCloseEvents reads the close events of the TCP connections until the perf reader is closed
+func (t *Tracer) readCloseEvents(rd *perf.Reader) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read close event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapIPV4ClosedEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.IP4CloseEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse close event: %v", err)
+			continue
+		}
+
+		if openedUs, ok := t.conns.close(event); ok {
+			t.closeEvent(openedUs, event)
+		}
+	}
+}
+
+// closeEvent sets the duration and the last state of the reported connection
+func (t *Tracer) closeEvent(openedUs uint64, event domain.IP4CloseEvent) {
+	var duration time.Duration
+	if event.TsUs > openedUs {
+		duration = time.Duration(event.TsUs-openedUs) * time.Microsecond
+	}
+
+	t.report.CloseEvent(event.Cookie, duration, utils.GetTCPState(event.State))
+}
@@ -0,0 +1,62 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestConnTable(t *testing.T) {
+	var c = newConnTable()
+
+	// the connect event is reported before the close
+	c.open(1, 1000)
+	if openedUs, closed := c.reported(1); closed != nil || openedUs != 0 {
+		t.Fatalf("unexpected close: %v", closed)
+	}
+	openedUs, ok := c.close(domain.IP4CloseEvent{Cookie: 1, TsUs: 5000})
+	if !ok || openedUs != 1000 {
+		t.Errorf("expected the close of the reported connection, got %d %v", openedUs, ok)
+	}
+
+	// the close event is read while the connect event is handled
+	c.open(2, 2000)
+	if _, ok := c.close(domain.IP4CloseEvent{Cookie: 2, TsUs: 3000, State: 2}); ok {
+		t.Error("expected the close to wait for the connect event")
+	}
+	openedUs, closed := c.reported(2)
+	if closed == nil || openedUs != 2000 || closed.State != 2 {
+		t.Errorf("expected the pending close, got %d %v", openedUs, closed)
+	}
+
+	// the connections not tracked (inbound, listeners)
+	if _, ok := c.close(domain.IP4CloseEvent{Cookie: 3}); ok {
+		t.Error("expected an untracked connection")
+	}
+	if len(c.conns) != 0 {
+		t.Errorf("expected no open connection, got %d", len(c.conns))
+	}
+}
+
+func TestIP4EventLayout(t *testing.T) {
+	// the packed structs of bpf/sensor.network.bpf.c
+	if size := binary.Size(domain.IP4Event{}); size != 71 {
+		t.Errorf("unexpected ipv4_event_t size %d", size)
+	}
+
+	var raw = make([]byte, 23)
+	binary.LittleEndian.PutUint64(raw[8:], 42)
+	copy(raw[16:], []byte{1, 1, 1, 1})
+	binary.LittleEndian.PutUint16(raw[20:], 443)
+	raw[22] = 1
+
+	var event domain.IP4CloseEvent
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &event); err != nil {
+		t.Fatalf("failed to parse close event: %v", err)
+	}
+	if event.Cookie != 42 || event.Dport != 443 || event.State != 1 || event.Daddr != [4]byte{1, 1, 1, 1} {
+		t.Errorf("unexpected close event: %+v", event)
+	}
+}
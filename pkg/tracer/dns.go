@@ -0,0 +1,202 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf/perf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// dnsTypes are the names of the common query types
+var dnsTypes = map[uint16]string{
+	1:   "A",
+	2:   "NS",
+	5:   "CNAME",
+	6:   "SOA",
+	12:  "PTR",
+	15:  "MX",
+	16:  "TXT",
+	28:  "AAAA",
+	33:  "SRV",
+	64:  "SVCB",
+	65:  "HTTPS",
+	255: "ANY",
+}
+
+// dnsRcodes are the names of the response codes
+var dnsRcodes = map[uint8]string{
+	0: "NOERROR",
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+// dnsType returns the name of the query type, TYPE<n> if unknown (RFC 3597)
+func dnsType(t uint16) string {
+	if name, ok := dnsTypes[t]; ok {
+		return name
+	}
+
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// dnsRcode returns the name of the response code, RCODE<n> if unknown
+func dnsRcode(rcode uint8) string {
+	if name, ok := dnsRcodes[rcode]; ok {
+		return name
+	}
+
+	return "RCODE" + strconv.Itoa(int(rcode))
+}
+
+// parseQuestion returns the name and the type of the question of a DNS
+// message, the name in the wire format (length prefixed labels) followed by
+// the type. The compressed names are not expected in a question.
+func parseQuestion(b []byte) (string, uint16, bool) {
+	var labels []string
+	var i int
+	for {
+		if i >= len(b) {
+			return "", 0, false
+		}
+		var n = int(b[i])
+		if n == 0 {
+			i++
+			break
+		}
+		if n > 63 || i+1+n > len(b) {
+			return "", 0, false
+		}
+		labels = append(labels, strings.ToLower(string(b[i+1:i+1+n])))
+		i += 1 + n
+	}
+	if i+2 > len(b) {
+		return "", 0, false
+	}
+
+	var name = strings.Join(labels, ".")
+	if name == "" {
+		name = "."
+	}
+
+	return name, binary.BigEndian.Uint16(b[i:]), true
+}
+
+// dnsLog is the names looked up during the run by name, type and resolver
+type dnsLog struct {
+	mu      sync.Mutex
+	queries map[string]*domain.DNSQuery
+}
+
+func newDNSLog() *dnsLog {
+	return &dnsLog{queries: make(map[string]*domain.DNSQuery)}
+}
+
+// add records a response to the query of the task, it returns true if the
+// name is looked up for the first time
+func (l *dnsLog) add(query domain.DNSQuery, task string) bool {
+	var key = query.Name + "/" + query.Type + "@" + query.Resolver
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q, ok := l.queries[key]
+	if !ok {
+		query.FirstSeen = query.LastSeen
+		q = &query
+		l.queries[key] = q
+	}
+	q.Count++
+	q.Rcode, q.Answers, q.LastSeen = query.Rcode, query.Answers, query.LastSeen
+	if !slices.Contains(q.Tasks, task) {
+		q.Tasks = append(q.Tasks, task)
+	}
+
+	return !ok
+}
+
+// list returns a copy of the queries by name
+func (l *dnsLog) list() []domain.DNSQuery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var list = make([]domain.DNSQuery, 0, len(l.queries))
+	for _, q := range l.queries {
+		var query = *q
+		query.Tasks = slices.Clone(q.Tasks)
+		list = append(list, query)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Type < list[j].Type
+	})
+
+	return list
+}
+
+// readDNSEvents records the DNS responses received by the processes until the
+// perf reader is closed, the lookups of kntrl itself (the rdns stage) are not recorded
+func (t *Tracer) readDNSEvents(rd *perf.Reader) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read dns event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapDNSEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.DNSEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse dns event: %v", err)
+			continue
+		}
+
+		if t.self.Is(event.Pid, t.execCache) {
+			continue
+		}
+
+		name, qtype, ok := parseQuestion(event.Question[:])
+		if !ok {
+			logger.Log.Debugf("failed to parse dns question [%d]", event.Pid)
+			continue
+		}
+
+		var taskname = utils.TrimNullBytes(event.Task)
+		var query = domain.DNSQuery{
+			Name:     name,
+			Type:     dnsType(qtype),
+			Resolver: netip.AddrFrom4(event.Resolver).String(),
+			Rcode:    dnsRcode(event.Rcode),
+			Answers:  int(event.Answers),
+			LastSeen: t.report.Now(),
+		}
+		if t.dns.add(query, taskname) {
+			logger.Log.Debugf("dns [%d]%s: %s %s @%s %s (%d answers)", event.Pid, taskname, query.Type, query.Name,
+				query.Resolver, query.Rcode, query.Answers)
+		}
+		metrics.ObserveDNSQuery(query.Type, query.Rcode)
+	}
+}
@@ -0,0 +1,71 @@
+package tracer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestParseQuestion(t *testing.T) {
+	var tests = []struct {
+		name     string
+		question []byte
+		want     string
+		qtype    uint16
+		valid    bool
+	}{
+		{"a record", []byte("\x03api\x06GitHub\x03com\x00\x00\x01\x00\x01"), "api.github.com", 1, true},
+		{"root", []byte("\x00\x00\x02\x00\x01"), ".", 2, true},
+		{"padded", append([]byte("\x07example\x03org\x00\x00\x1c\x00\x01"), make([]byte, 16)...), "example.org", 28, true},
+		{"truncated name", []byte("\x07exam"), "", 0, false},
+		{"no type", []byte("\x03com\x00\x00"), "", 0, false},
+		{"compressed", []byte("\xc0\x0c\x00\x01"), "", 0, false},
+	}
+
+	for _, tt := range tests {
+		name, qtype, ok := parseQuestion(tt.question)
+		if ok != tt.valid || name != tt.want || qtype != tt.qtype {
+			t.Errorf("%s: expected %q %d %v, got %q %d %v", tt.name, tt.want, tt.qtype, tt.valid, name, qtype, ok)
+		}
+	}
+}
+
+func TestDNSTypeAndRcode(t *testing.T) {
+	if got := dnsType(28); got != "AAAA" {
+		t.Errorf("expected AAAA, got %s", got)
+	}
+	if got := dnsType(99); got != "TYPE99" {
+		t.Errorf("expected TYPE99, got %s", got)
+	}
+	if got := dnsRcode(3); got != "NXDOMAIN" {
+		t.Errorf("expected NXDOMAIN, got %s", got)
+	}
+	if got := dnsRcode(9); got != "RCODE9" {
+		t.Errorf("expected RCODE9, got %s", got)
+	}
+}
+
+func TestDNSLog(t *testing.T) {
+	var log = newDNSLog()
+	var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var query = domain.DNSQuery{Name: "github.com", Type: "A", Resolver: "127.0.0.53", Rcode: "NOERROR", Answers: 1, LastSeen: now}
+	if !log.add(query, "curl") {
+		t.Errorf("expected the first lookup")
+	}
+	query.LastSeen = now.Add(time.Minute)
+	if log.add(query, "git") {
+		t.Errorf("expected a repeated lookup")
+	}
+	log.add(domain.DNSQuery{Name: "evil.example", Type: "TXT", Resolver: "127.0.0.53", Rcode: "NXDOMAIN", LastSeen: now}, "sh")
+
+	var list = log.list()
+	if len(list) != 2 || list[0].Name != "evil.example" {
+		t.Fatalf("unexpected queries %+v", list)
+	}
+	if q := list[1]; q.Count != 2 || !q.FirstSeen.Equal(now) || !q.LastSeen.Equal(now.Add(time.Minute)) || !reflect.DeepEqual(q.Tasks, []string{"curl", "git"}) {
+		t.Errorf("unexpected query %+v", q)
+	}
+}
@@ -0,0 +1,139 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+)
+
+const (
+	// DefaultEscalateWindow is the default window of the findings of the escalation
+	DefaultEscalateWindow = 10 * time.Minute
+
+	// escalateInterval is the interval of the checks of the findings
+	escalateInterval = time.Second
+)
+
+// highSeverityFindings are the kinds of the findings counted by the
+// escalation, the connection rate is often a legitimate polling
+var highSeverityFindings = map[string]bool{
+	domain.FindingKindCredentialEgress:  true,
+	domain.FindingKindDestinationFanout: true,
+	domain.FindingKindCertificateChange: true,
+}
+
+// validateEscalation returns an error if the escalation is not available with
+// the options: the monitor mode is escalated, and a source of the
+// high-severity findings is required
+func validateEscalation(opts Options) error {
+	switch {
+	case opts.EscalateFindings == 0:
+		return nil
+	case opts.EscalateFindings < 0 || opts.EscalateWindow < 0:
+		return errors.New("escalation findings and window must not be negative")
+	case opts.Passive || opts.Mode != ModeMonitor:
+		return fmt.Errorf("escalation requires the %s mode, the passive mode excepted", ModeMonitor)
+	case !opts.FileAccess && opts.MaxDestinations == 0 && opts.CertFingerprints == "":
+		return errors.New("escalation requires a source of high-severity findings (file access, max destinations or cert fingerprints)")
+	}
+
+	return nil
+}
+
+// highSeverity returns the high-severity findings of the window ending at now
+func highSeverity(findings []domain.Finding, window time.Duration, now time.Time) []domain.Finding {
+	var result []domain.Finding
+	for _, f := range findings {
+		if highSeverityFindings[f.Kind] && now.Sub(f.Timestamp) <= window {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// watchEscalation escalates the mode once the high-severity findings of the
+// window reach Options.EscalateFindings, until the context is done
+func (t *Tracer) watchEscalation(ctx context.Context) {
+	var ticker = time.NewTicker(escalateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		findings := highSeverity(t.findings(), t.opts.EscalateWindow, t.report.Now())
+		if len(findings) >= t.opts.EscalateFindings {
+			t.escalate(findings)
+			return
+		}
+	}
+}
+
+// escalate switches the monitor mode to the trace mode and notifies the sinks
+// and the webhook. The destinations allowed by the policy so far are added
+// into the allow list first, their connections are not broken.
+func (t *Tracer) escalate(findings []domain.Finding) {
+	if t.monitorFallback.Load() {
+		logger.Log.Warnf("escalation: the enforcement is switched to the %s mode by a full map, the mode is not escalated", ModeMonitor)
+		return
+	}
+
+	t.eventMu.Lock()
+	for _, e := range t.report.Events() {
+		if e.Policy != domain.EventPolicyStatusPass || e.WouldBlock || e.Denied || e.Self || e.Excluded || e.Sandbox != "" {
+			continue
+		}
+		daddr, ok := ebpfman.NewIPv4Key(net.ParseIP(e.DestinationAddress))
+		if !ok {
+			continue
+		}
+		t.allowAddr(daddr, e.DestinationPort, e.DestinationAddress, domain.AllowSourcePolicy, strings.Join(e.Domains, ","))
+	}
+
+	modeMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapMode]
+	if err := modeMap.Put(uint32(0), uint32(domain.TracerModeIndexTrace)); err != nil {
+		t.eventMu.Unlock()
+		logger.Log.Errorf("failed to escalate to the %s mode: %v", ModeTrace, err)
+		return
+	}
+	t.escalated.Store(true)
+	t.eventMu.Unlock()
+
+	var escalation = domain.Escalation{
+		From:      ModeMonitor,
+		To:        ModeTrace,
+		Findings:  findings,
+		Timestamp: t.report.Now(),
+	}
+	t.report.SetEscalation(&escalation)
+	logger.Log.Errorf("escalated to the %s mode after %d high-severity finding(s) in %s: the connections out of the policy are blocked",
+		ModeTrace, len(findings), t.opts.EscalateWindow)
+
+	// the outputs sent out of the runner get the hashes of the destinations
+	var out = escalation
+	if t.hasher != nil {
+		out = t.hasher.Escalation(escalation)
+	}
+	for _, sink := range t.sinks {
+		if s, ok := sink.(reporter.EscalationSink); ok {
+			if err := s.Escalate(out); err != nil {
+				logger.Log.Errorf("%v", err)
+			}
+		}
+	}
+	for _, webhook := range t.webhooks {
+		webhook.Escalate(out)
+	}
+}
@@ -0,0 +1,45 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestHighSeverity(t *testing.T) {
+	var now = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var findings = []domain.Finding{
+		{Kind: domain.FindingKindCredentialEgress, Timestamp: now.Add(-time.Minute)},
+		{Kind: domain.FindingKindConnectionRate, Timestamp: now.Add(-time.Minute)},
+		{Kind: domain.FindingKindDestinationFanout, Timestamp: now.Add(-20 * time.Minute)},
+		{Kind: domain.FindingKindCertificateChange, Timestamp: now},
+	}
+
+	result := highSeverity(findings, 10*time.Minute, now)
+	if len(result) != 2 || result[0].Kind != domain.FindingKindCredentialEgress || result[1].Kind != domain.FindingKindCertificateChange {
+		t.Errorf("expected the high-severity findings of the window, got %+v", result)
+	}
+}
+
+func TestValidateEscalation(t *testing.T) {
+	var testCases = map[string]struct {
+		opts  Options
+		valid bool
+	}{
+		"disabled":     {Options{Mode: ModeTrace}, true},
+		"monitor":      {Options{Mode: ModeMonitor, EscalateFindings: 2, FileAccess: true}, true},
+		"fan-out":      {Options{Mode: ModeMonitor, EscalateFindings: 2, MaxDestinations: 50}, true},
+		"trace":        {Options{Mode: ModeTrace, EscalateFindings: 2, FileAccess: true}, false},
+		"passive":      {Options{Mode: ModeMonitor, Passive: true, EscalateFindings: 2, FileAccess: true}, false},
+		"no findings":  {Options{Mode: ModeMonitor, EscalateFindings: 2, MaxConnectionRate: 100}, false},
+		"negative":     {Options{Mode: ModeMonitor, EscalateFindings: -1, FileAccess: true}, false},
+		"negative win": {Options{Mode: ModeMonitor, EscalateFindings: 1, EscalateWindow: -time.Second, FileAccess: true}, false},
+	}
+
+	for name, tc := range testCases {
+		if err := validateEscalation(tc.opts); (err == nil) != tc.valid {
+			t.Errorf("[%s] expected valid %v, got %v", name, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tracer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+// exclusions are the services never blocked (the critical system services of
+// a persistent runner), matched by the task name or by the cgroup
+type exclusions struct {
+	comms map[string]bool
+	// cgroups are the cgroup v2 paths (/system.slice/sshd.service),
+	// the descendant cgroups are excluded too
+	cgroups []string
+}
+
+func newExclusions(comms, cgroups []string) (*exclusions, error) {
+	var e = &exclusions{comms: make(map[string]bool)}
+	for _, comm := range comms {
+		// the kernel truncates the task names
+		e.comms[ebpfman.NewCommKey(comm).String()] = true
+	}
+
+	for _, cgroup := range cgroups {
+		path := cgroupPath(cgroup)
+		if path == "/" {
+			return nil, fmt.Errorf("the root cgroup can't be excluded: %s", cgroup)
+		}
+		e.cgroups = append(e.cgroups, path)
+	}
+
+	return e, nil
+}
+
+// cgroupPath returns the cgroup v2 path of the cgroup, relative to the root cgroup
+func cgroupPath(cgroup string) string {
+	cgroup = strings.TrimPrefix(filepath.Clean("/"+cgroup), rootCgroup)
+	if cgroup == "" {
+		return "/"
+	}
+
+	return cgroup
+}
+
+// empty returns true if there are no exclusions
+func (e *exclusions) empty() bool {
+	return len(e.comms) == 0 && len(e.cgroups) == 0
+}
+
+// match returns true if the process is excluded
+func (e *exclusions) match(pid uint32, comm string) bool {
+	if e.comms[comm] {
+		return true
+	}
+	if len(e.cgroups) == 0 {
+		return false
+	}
+
+	cgroup, err := process.CgroupOf(pid)
+	if err != nil {
+		return false
+	}
+
+	for _, excluded := range e.cgroups {
+		if cgroup == excluded || strings.HasPrefix(cgroup, excluded+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// putExclusions fills the excluded task name and cgroup maps
+func (t *Tracer) putExclusions(tracing bool) error {
+	if t.exclusions.empty() {
+		return nil
+	}
+
+	commMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapExcludedComm]
+	for comm := range t.exclusions.comms {
+		if err := commMap.Put(ebpfman.NewCommKey(comm), uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update excluded comm (map): %w", ErrMapUpdate, err)
+		}
+	}
+	// the sockets of the excluded tasks are marked by the fentry programs
+	if len(t.exclusions.comms) > 0 && !tracing {
+		logger.Log.Warnf("the running kernel doesn't support fentry programs, --exclude-comm is not enforced (use --exclude-cgroup)")
+	}
+
+	cgroupMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapExcludedCgroup]
+	for _, cgroup := range t.exclusions.cgroups {
+		id, err := ebpfman.CgroupID(filepath.Join(rootCgroup, cgroup))
+		if err != nil {
+			return fmt.Errorf("failed to exclude cgroup [%s]: %w", cgroup, err)
+		}
+		if err := cgroupMap.Put(id, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update excluded cgroup (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	logger.Log.Infof("excluded from the enforcement: comm %v, cgroup %v", sortedKeys(t.exclusions.comms), t.exclusions.cgroups)
+
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var list = make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+
+	return list
+}
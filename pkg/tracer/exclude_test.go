@@ -0,0 +1,34 @@
+package tracer
+
+import "testing"
+
+func TestNewExclusions(t *testing.T) {
+	e, err := newExclusions(
+		[]string{"sshd", "systemd-timesyncd"},
+		[]string{"system.slice/sshd.service", "/sys/fs/cgroup/system.slice/chronyd.service/"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the task names are truncated like the kernel does
+	if !e.match(0, "sshd") || !e.match(0, "systemd-timesyn") {
+		t.Errorf("expected the task names to match: %v", e.comms)
+	}
+	if e.match(0, "curl") {
+		t.Error("expected curl not to match")
+	}
+
+	var want = []string{"/system.slice/sshd.service", "/system.slice/chronyd.service"}
+	if len(e.cgroups) != len(want) || e.cgroups[0] != want[0] || e.cgroups[1] != want[1] {
+		t.Errorf("expected cgroups %v, got %v", want, e.cgroups)
+	}
+
+	if _, err := newExclusions(nil, []string{"/sys/fs/cgroup"}); err == nil {
+		t.Error("expected an error for the root cgroup")
+	}
+
+	if e, _ = newExclusions(nil, nil); !e.empty() {
+		t.Error("expected no exclusions")
+	}
+}
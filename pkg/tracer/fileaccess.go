@@ -0,0 +1,287 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/perf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/process"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+const (
+	// DefaultFileAccessWindow is the time a read of a sensitive file is
+	// correlated with the connections of the process
+	DefaultFileAccessWindow = time.Minute
+
+	// fileAccessSlack is the tolerance of the connections handled before the
+	// read, the events of the reads and of the connections are read from
+	// different perf buffers
+	fileAccessSlack = time.Second
+	// maxFileReads is the number of the reads kept per process
+	maxFileReads = 16
+)
+
+// DefaultSensitiveFiles are the credential files of a runner traced by
+// --file-access: the cloud, registry and git credentials and the credentials
+// of the self-hosted runner
+var DefaultSensitiveFiles = []string{
+	".aws/credentials",
+	".azure/msal_token_cache.json",
+	"gcloud/credentials.db",
+	".kube/config",
+	".docker/config.json",
+	".npmrc",
+	".pypirc",
+	".netrc",
+	".git-credentials",
+	"gh/hosts.yml",
+	".ssh/id_rsa",
+	".ssh/id_ecdsa",
+	".ssh/id_ed25519",
+	".credentials",
+	".credentials_rsaparams",
+}
+
+// sensitiveFile is a sensitive file: the name and the name of its directory,
+// the files of any directory match if empty
+type sensitiveFile struct {
+	pattern string
+	name    string
+	parent  string
+}
+
+// parseSensitiveFile parses the pattern of a sensitive file, the file name
+// with an optional directory (.aws/credentials, ~/.npmrc). The kernel reads
+// the name of the file and of its directory only, the other directories of
+// the path are not matched.
+func parseSensitiveFile(pattern string) (sensitiveFile, error) {
+	var clean = path.Clean(strings.TrimPrefix(strings.TrimSpace(pattern), "~/"))
+	dir, name := path.Split(clean)
+	if name == "" || name == "." || name == "/" {
+		return sensitiveFile{}, fmt.Errorf("invalid sensitive file [%s]", pattern)
+	}
+	if _, ok := ebpfman.NewFileNameKey(name); !ok {
+		return sensitiveFile{}, fmt.Errorf("invalid sensitive file [%s]: the name is longer than %d characters", pattern, ebpfman.FileNameKeySize-1)
+	}
+
+	var f = sensitiveFile{pattern: clean, name: name}
+	if dir = strings.TrimSuffix(dir, "/"); dir != "" {
+		f.parent = path.Base(dir)
+		if _, ok := ebpfman.NewFileNameKey(f.parent); !ok {
+			return sensitiveFile{}, fmt.Errorf("invalid sensitive file [%s]: the directory is longer than %d characters", pattern, ebpfman.FileNameKeySize-1)
+		}
+	}
+
+	return f, nil
+}
+
+// fileRead is a read of a sensitive file
+type fileRead struct {
+	file string
+	at   time.Time
+}
+
+// fileAccess correlates the reads of the sensitive files with the subsequent
+// connections of the same process (credential read then egress)
+type fileAccess struct {
+	window time.Duration
+	files  []sensitiveFile
+
+	mu sync.Mutex
+	// reads are the recent reads of each process
+	reads map[uint32][]fileRead
+	// reported are the findings by pid and destination, a finding is reported once
+	reported map[string]bool
+	findings []domain.Finding
+}
+
+// newFileAccess returns the correlation of the reads of the files of the
+// patterns, DefaultSensitiveFiles if empty
+func newFileAccess(patterns []string, window time.Duration) (*fileAccess, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultSensitiveFiles
+	}
+	if window <= 0 {
+		return nil, errors.New("file access window must be positive")
+	}
+
+	var f = &fileAccess{
+		window:   window,
+		reads:    make(map[uint32][]fileRead),
+		reported: make(map[string]bool),
+	}
+	for _, pattern := range patterns {
+		file, err := parseSensitiveFile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.files = append(f.files, file)
+	}
+
+	return f, nil
+}
+
+// names returns the file names filtered by the kernel
+func (f *fileAccess) names() []string {
+	var seen = make(map[string]bool)
+	var names []string
+	for _, file := range f.files {
+		if !seen[file.name] {
+			seen[file.name] = true
+			names = append(names, file.name)
+		}
+	}
+
+	return names
+}
+
+// record records the read of the file by the process, it returns the pattern
+// of the file and false if the directory doesn't match
+func (f *fileAccess) record(pid uint32, name, parent string, at time.Time) (string, bool) {
+	var pattern string
+	for _, file := range f.files {
+		if file.name == name && (file.parent == "" || file.parent == parent) {
+			pattern = file.pattern
+			break
+		}
+	}
+	if pattern == "" {
+		return "", false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the reads of the processes without a connection expire
+	for p, reads := range f.reads {
+		if f.expired(reads[len(reads)-1], at) {
+			delete(f.reads, p)
+		}
+	}
+
+	var reads = append(f.reads[pid], fileRead{file: pattern, at: at})
+	if len(reads) > maxFileReads {
+		reads = reads[len(reads)-maxFileReads:]
+	}
+	f.reads[pid] = reads
+
+	return pattern, true
+}
+
+func (f *fileAccess) expired(read fileRead, at time.Time) bool {
+	return at.Sub(read.at) > f.window
+}
+
+// correlate returns the files read by the process of the event in the window
+// before the connection, a finding is recorded on the first connection of the
+// process to the destination
+func (f *fileAccess) correlate(event domain.ReportEvent) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var files []string
+	var seen = make(map[string]bool)
+	for _, read := range f.reads[event.ProcessID] {
+		if f.expired(read, event.Timestamp) || read.at.Sub(event.Timestamp) > fileAccessSlack {
+			continue
+		}
+		if !seen[read.file] {
+			seen[read.file] = true
+			files = append(files, read.file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	var key = fmt.Sprintf("%d/%s:%d", event.ProcessID, event.DestinationAddress, event.DestinationPort)
+	if !f.reported[key] {
+		f.reported[key] = true
+		f.findings = append(f.findings, domain.Finding{
+			Kind:               domain.FindingKindCredentialEgress,
+			ProcessID:          event.ProcessID,
+			TaskName:           event.TaskName,
+			Executable:         event.Executable,
+			Files:              files,
+			DestinationAddress: event.DestinationAddress,
+			DestinationPort:    event.DestinationPort,
+			Domains:            event.Domains,
+			Policy:             event.Policy,
+			Timestamp:          event.Timestamp,
+		})
+		logger.Log.Warnf("credential read then egress [%d]%s: %s -> %s:%d", event.ProcessID, event.TaskName,
+			strings.Join(files, ", "), event.DestinationAddress, event.DestinationPort)
+	}
+
+	return files
+}
+
+// list returns a copy of the findings
+func (f *fileAccess) list() []domain.Finding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var findings = make([]domain.Finding, len(f.findings))
+	copy(findings, f.findings)
+
+	return findings
+}
+
+// putSensitiveFiles writes the names of the sensitive files filtered by the kernel
+func (t *Tracer) putSensitiveFiles() error {
+	if t.fileAccess == nil {
+		return nil
+	}
+
+	filesMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapSensitiveFiles]
+	for _, name := range t.fileAccess.names() {
+		key, _ := ebpfman.NewFileNameKey(name)
+		if err := filesMap.Put(key, uint8(1)); err != nil {
+			return fmt.Errorf("%w: failed to update sensitive files (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	return nil
+}
+
+// readFileEvents records the reads of the sensitive files until the perf reader is closed
+func (t *Tracer) readFileEvents(rd *perf.Reader) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read file event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapFileEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.FileEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse file event: %v", err)
+			continue
+		}
+
+		var name, parent = process.CString(event.Name[:]), process.CString(event.Parent[:])
+		if file, ok := t.fileAccess.record(event.Pid, name, parent, t.report.Now()); ok {
+			logger.Log.Debugf("sensitive file read [%d]%s: %s", event.Pid, utils.TrimNullBytes(event.Task), file)
+		}
+	}
+}
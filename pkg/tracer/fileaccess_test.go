@@ -0,0 +1,92 @@
+package tracer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestParseSensitiveFile(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		want    sensitiveFile
+		valid   bool
+	}{
+		{".npmrc", sensitiveFile{pattern: ".npmrc", name: ".npmrc"}, true},
+		{"~/.aws/credentials", sensitiveFile{pattern: ".aws/credentials", name: "credentials", parent: ".aws"}, true},
+		{"/home/runner/.docker/config.json", sensitiveFile{pattern: "/home/runner/.docker/config.json", name: "config.json", parent: ".docker"}, true},
+		{"", sensitiveFile{}, false},
+		{".aws/", sensitiveFile{pattern: ".aws", name: ".aws"}, true},
+		{"application_default_credentials.json", sensitiveFile{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSensitiveFile(tt.pattern)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: unexpected error %v", tt.pattern, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.pattern, tt.want, got)
+		}
+	}
+
+	for _, pattern := range DefaultSensitiveFiles {
+		if _, err := parseSensitiveFile(pattern); err != nil {
+			t.Errorf("default sensitive file: %v", err)
+		}
+	}
+}
+
+func TestFileAccess_Correlate(t *testing.T) {
+	files, err := newFileAccess([]string{".aws/credentials", ".npmrc"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"credentials", ".npmrc"}; !reflect.DeepEqual(files.names(), want) {
+		t.Errorf("expected names %v, got %v", want, files.names())
+	}
+
+	var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, ok := files.record(100, "credentials", "google", now); ok {
+		t.Errorf("expected the credentials of another directory to be ignored")
+	}
+	if file, ok := files.record(100, "credentials", ".aws", now); !ok || file != ".aws/credentials" {
+		t.Errorf("expected .aws/credentials, got %q", file)
+	}
+	files.record(200, ".npmrc", "runner", now.Add(-2*time.Minute))
+
+	var event = domain.ReportEvent{
+		ProcessID:          100,
+		TaskName:           "curl",
+		DestinationAddress: "203.0.113.7",
+		DestinationPort:    443,
+		Policy:             domain.EventPolicyStatusPass,
+		Timestamp:          now.Add(10 * time.Second),
+	}
+	if got := files.correlate(event); !reflect.DeepEqual(got, []string{".aws/credentials"}) {
+		t.Errorf("expected the read of the process, got %v", got)
+	}
+	// the finding of the destination is reported once
+	files.correlate(event)
+
+	// the read is expired
+	if got := files.correlate(domain.ReportEvent{ProcessID: 200, Timestamp: now}); got != nil {
+		t.Errorf("expected no read, got %v", got)
+	}
+	// the connection is before the read
+	if got := files.correlate(domain.ReportEvent{ProcessID: 100, Timestamp: now.Add(-time.Minute)}); got != nil {
+		t.Errorf("expected no read, got %v", got)
+	}
+
+	var findings = files.list()
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %d", len(findings))
+	}
+	if f := findings[0]; f.Kind != domain.FindingKindCredentialEgress || f.TaskName != "curl" || f.DestinationPort != 443 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
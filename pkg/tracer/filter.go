@@ -0,0 +1,142 @@
+package tracer
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+)
+
+// eventFilter are the events not reported (the noisy system daemons), matched
+// by the process id, the task name or the destination. The filtered
+// connections are enforced as the others.
+type eventFilter struct {
+	pids  map[uint32]bool
+	comms map[string]bool
+	dests []string
+	// matchers are the matchers of dests
+	matchers []func(addr string, port uint16, domains []string) bool
+}
+
+func newEventFilter(pids []int, comms, dests []string) (*eventFilter, error) {
+	var f = &eventFilter{pids: make(map[uint32]bool), comms: make(map[string]bool)}
+	for _, pid := range pids {
+		if pid <= 0 {
+			return nil, fmt.Errorf("invalid filtered pid: %d", pid)
+		}
+		f.pids[uint32(pid)] = true
+	}
+
+	for _, comm := range comms {
+		// the kernel truncates the task names
+		f.comms[ebpfman.NewCommKey(comm).String()] = true
+	}
+
+	for _, dest := range dests {
+		if dest == "" {
+			return nil, fmt.Errorf("invalid ignored destination: %q", dest)
+		}
+		f.dests = append(f.dests, dest)
+		f.matchers = append(f.matchers, reporter.DestinationMatcher(dest))
+	}
+
+	return f, nil
+}
+
+// empty returns true if there are no filters
+func (f *eventFilter) empty() bool {
+	return len(f.pids) == 0 && len(f.comms) == 0 && len(f.dests) == 0
+}
+
+// process returns true if the events of the process are not reported
+func (f *eventFilter) process(pid uint32, comm string) bool {
+	return f.pids[pid] || f.comms[comm]
+}
+
+// match returns true if the event is not reported
+func (f *eventFilter) match(event domain.ReportEvent) bool {
+	if f.process(event.ProcessID, event.TaskName) {
+		return true
+	}
+
+	for _, match := range f.matchers {
+		if match(event.DestinationAddress, event.DestinationPort, event.Domains) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// destKeys returns the map keys of the ignored destinations, the IPv4
+// addresses with an optional port. The networks and the domain names are
+// matched in userspace only.
+func (f *eventFilter) destKeys() []ebpfman.PortKey {
+	var keys []ebpfman.PortKey
+	for _, dest := range f.dests {
+		var host, port = dest, uint16(0)
+		if h, p, err := net.SplitHostPort(dest); err == nil {
+			n, err := strconv.ParseUint(p, 10, 16)
+			if err != nil {
+				continue
+			}
+			host, port = h, uint16(n)
+		}
+
+		if key, ok := ebpfman.NewIPv4Key(net.ParseIP(host)); ok {
+			keys = append(keys, ebpfman.PortKey{Addr: key, Port: port})
+		}
+	}
+
+	return keys
+}
+
+// putFilters fills the filtered task name, process and destination maps, the
+// kernel drops the events of the connections it allows. The other connections
+// are sent for the decision of the policy and filtered in userspace. The maps
+// are not written while the TOFU mode records the destinations.
+func (t *Tracer) putFilters() error {
+	if t.filter.empty() || t.recordTrust {
+		return nil
+	}
+
+	commMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapFilteredComm]
+	for comm := range t.filter.comms {
+		if err := commMap.Put(ebpfman.NewCommKey(comm), uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update filtered comm (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	pidMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapFilteredPID]
+	for pid := range t.filter.pids {
+		if err := pidMap.Put(pid, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update filtered pid (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	destMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapIgnoredDest]
+	for _, key := range t.filter.destKeys() {
+		if err := destMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update ignored destination (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	logger.Log.Infof("filtered from the report: pid %v, comm %v, destination %v", sortedPIDs(t.filter.pids), sortedKeys(t.filter.comms), t.filter.dests)
+
+	return nil
+}
+
+func sortedPIDs(m map[uint32]bool) []uint32 {
+	var list = make([]uint32, 0, len(m))
+	for pid := range m {
+		list = append(list, pid)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+
+	return list
+}
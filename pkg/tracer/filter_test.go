@@ -0,0 +1,53 @@
+package tracer
+
+import (
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+)
+
+func TestEventFilter(t *testing.T) {
+	f, err := newEventFilter(
+		[]int{42},
+		[]string{"systemd-timesyncd"},
+		[]string{"169.254.169.254:80", "10.0.0.0/8", ".ubuntu.com", "1.1.1.1"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = map[string]struct {
+		event domain.ReportEvent
+		want  bool
+	}{
+		"pid":            {event: domain.ReportEvent{ProcessID: 42, DestinationAddress: "8.8.8.8", DestinationPort: 443}, want: true},
+		"truncated comm": {event: domain.ReportEvent{TaskName: "systemd-timesyn", DestinationAddress: "8.8.8.8"}, want: true},
+		"address port":   {event: domain.ReportEvent{DestinationAddress: "169.254.169.254", DestinationPort: 80}, want: true},
+		"other port":     {event: domain.ReportEvent{DestinationAddress: "169.254.169.254", DestinationPort: 443}},
+		"cidr":           {event: domain.ReportEvent{DestinationAddress: "10.1.2.3", DestinationPort: 22}, want: true},
+		"subdomain":      {event: domain.ReportEvent{DestinationAddress: "91.189.91.39", Domains: []string{"archive.ubuntu.com"}}, want: true},
+		"other":          {event: domain.ReportEvent{ProcessID: 7, TaskName: "curl", DestinationAddress: "140.82.114.22", DestinationPort: 443}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := f.match(tt.event); got != tt.want {
+				t.Errorf("match(%+v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+
+	// the networks and the domain names are matched in userspace only
+	var keys = f.destKeys()
+	if len(keys) != 2 || keys[0] != (ebpfman.PortKey{Addr: ebpfman.IPv4Key{169, 254, 169, 254}, Port: 80}) || keys[1] != (ebpfman.PortKey{Addr: ebpfman.IPv4Key{1, 1, 1, 1}}) {
+		t.Errorf("unexpected destination keys: %v", keys)
+	}
+
+	if _, err := newEventFilter([]int{0}, nil, nil); err == nil {
+		t.Error("expected an error for the pid 0")
+	}
+	if f, _ = newEventFilter(nil, nil, nil); !f.empty() {
+		t.Error("expected no filters")
+	}
+}
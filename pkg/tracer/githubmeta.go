@@ -0,0 +1,97 @@
+package tracer
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/kondukto-io/kntrl/pkg/ghmeta"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// githubMetaTimeout is the timeout of a request to the meta API
+const githubMetaTimeout = 15 * time.Second
+
+// fetchGithubMeta returns the ranges of the meta API, the ranges of the
+// policy bundle if the API can't be reached
+func fetchGithubMeta(fetcher *ghmeta.Fetcher) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), githubMetaTimeout)
+	defer cancel()
+
+	prefixes, err := fetcher.Fetch(ctx)
+	if err != nil {
+		logger.Log.Warnf("%v, the bundled ranges are used", err)
+		if prefixes, err = fetcher.Bundled(); err != nil {
+			logger.Log.Errorf("failed to load the bundled github meta ranges: %v", err)
+			return nil
+		}
+	}
+
+	return cidrs(prefixes)
+}
+
+// cidrs returns the ranges in CIDR notation
+func cidrs(prefixes []netip.Prefix) []string {
+	var ranges = make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ranges = append(ranges, prefix.String())
+	}
+
+	return ranges
+}
+
+// githubMetaRanges returns the current GitHub meta ranges if the options allow
+// them, they are fetched the first time
+func (t *Tracer) githubMetaRanges(opts Options) []string {
+	if !opts.AllowGithubMeta {
+		return nil
+	}
+	if ranges := t.githubRanges.Load(); ranges != nil {
+		return *ranges
+	}
+
+	var ranges = fetchGithubMeta(t.githubMeta)
+	t.githubRanges.Store(&ranges)
+
+	return ranges
+}
+
+// refreshGithubMeta fetches the GitHub meta ranges again on each interval
+// once they are allowed, the changed ranges are reconciled. The current
+// ranges are kept if the meta API can't be reached.
+func (t *Tracer) refreshGithubMeta(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var current = t.githubRanges.Load()
+		if current == nil {
+			continue
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, githubMetaTimeout)
+		prefixes, err := t.githubMeta.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			logger.Log.Warnf("%v, the current ranges are kept", err)
+			continue
+		}
+
+		var ranges = cidrs(prefixes)
+		if slices.Equal(ranges, *current) {
+			continue
+		}
+
+		t.githubRanges.Store(&ranges)
+		if err := t.reconcile("github meta ranges updated", false); err != nil {
+			logger.Log.Errorf("failed to update the github meta ranges: %v", err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// ruleInventory returns the rules of the policy data in the format of the
+// data.kntrl.hits query and the aliases of the resolved IPs, a resolved IP of
+// an allowed host is counted for the host
+func ruleInventory(data *domain.Data) ([]string, map[string]string) {
+	var (
+		rules   []string
+		aliases = make(map[string]string)
+		seen    = make(map[string]bool)
+	)
+	add := func(rule string) {
+		if seen[rule] {
+			return
+		}
+		seen[rule] = true
+		rules = append(rules, rule)
+	}
+
+	for _, host := range data.AllowedHosts {
+		add("allowed-hosts=" + host)
+	}
+	for _, entry := range data.AllowedSources {
+		switch entry.Source {
+		case domain.AllowSourceDNS:
+			aliases["allowed-ips="+entry.Address] = "allowed-hosts=" + entry.Rule
+		default:
+			add("allowed-ips=" + entry.Address)
+		}
+	}
+	for _, rule := range data.AllowedPorts {
+		if rule.Address == "" {
+			add(fmt.Sprintf("allowed-ports=%d", rule.Port))
+			continue
+		}
+		add(fmt.Sprintf("allowed-ports=%s:%d", rule.Address, rule.Port))
+	}
+	for _, rule := range data.ProcessRules {
+		add(fmt.Sprintf("process-policy=%s:%s:%s", rule.Process, rule.Action, rule.Destination))
+	}
+	for _, host := range data.DeniedHosts {
+		add("denied-hosts=" + host)
+	}
+	for _, ip := range data.DeniedIPs {
+		add("denied-ips=" + ip.String())
+	}
+	if data.AllowGithubMeta {
+		add("allow-github-meta")
+	}
+	if data.AllowLocalIPRanges {
+		add("allow-local-ranges")
+	}
+	if data.MinReputation > 0 {
+		add(fmt.Sprintf("min-reputation=%d", data.MinReputation))
+	}
+
+	return rules, aliases
+}
+
+// hitTable counts the connections matched by each policy rule, the counters
+// are kept by the rule and survive the policy reloads
+type hitTable struct {
+	mu   sync.Mutex
+	hits map[string]*domain.RuleHit
+}
+
+func newHitTable() *hitTable {
+	return &hitTable{hits: make(map[string]*domain.RuleHit)}
+}
+
+// record counts the connection for the matched rules by the policy decision
+func (h *hitTable) record(rules []string, status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, rule := range rules {
+		hit, ok := h.hits[rule]
+		if !ok {
+			hit = &domain.RuleHit{Rule: rule}
+			h.hits[rule] = hit
+		}
+
+		switch status {
+		case domain.EventPolicyStatusPass:
+			hit.Allowed++
+		case domain.EventPolicyStatusBlock:
+			hit.Denied++
+		}
+	}
+}
+
+// list returns the counters of the rules, the rules without a hit are
+// included with zero counters. The hot rules come first.
+func (h *hitTable) list(inventory []string) []domain.RuleHit {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var hits = make([]domain.RuleHit, 0, len(inventory)+len(h.hits))
+	var seen = make(map[string]bool, len(inventory))
+	for _, rule := range inventory {
+		seen[rule] = true
+		if hit, ok := h.hits[rule]; ok {
+			hits = append(hits, *hit)
+			continue
+		}
+		hits = append(hits, domain.RuleHit{Rule: rule})
+	}
+	// the rules removed by a reload are kept if they had hits
+	for rule, hit := range h.hits {
+		if !seen[rule] {
+			hits = append(hits, *hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		ti, tj := hits[i].Allowed+hits[i].Denied, hits[j].Allowed+hits[j].Denied
+		if ti != tj {
+			return ti > tj
+		}
+		return hits[i].Rule < hits[j].Rule
+	})
+
+	return hits
+}
+
+// countHits counts the event for the rules matching it
+func (t *Tracer) countHits(ctx context.Context, rules *ruleset, event domain.ReportEvent) {
+	if rules.hitPolicy == nil {
+		return
+	}
+
+	var start = time.Now()
+	hits, err := rules.hitPolicy.EvalEventSet(ctx, event)
+	t.watchdog.Observe("policy/hits", time.Since(start))
+	if err != nil {
+		logger.Log.Debugf("rule hits eval failed: %v", err)
+		return
+	}
+
+	for i, hit := range hits {
+		if alias, ok := rules.aliases[hit]; ok {
+			hits[i] = alias
+		}
+	}
+
+	t.hits.record(dedupe(hits), event.Policy)
+}
+
+// ruleHits returns the counters of the rules of the current policy
+func (t *Tracer) ruleHits() []domain.RuleHit {
+	return t.hits.list(t.rules.Load().inventory)
+}
+
+// updateRuleHistory records the rule hits of the run and reports the rules
+// without a hit in the last runs
+func (t *Tracer) updateRuleHistory() {
+	t.ruleHistory.Update(t.ruleHits(), t.report.Now())
+	if err := t.ruleHistory.Save(); err != nil {
+		logger.Log.Errorf("failed to save rule history: %v", err)
+	}
+
+	t.report.SetHygiene(t.ruleHistory.Stale(t.opts.HygieneRuns))
+}
+
+// dedupe removes the repeated rules, several resolved IPs of a host may match
+func dedupe(rules []string) []string {
+	var seen = make(map[string]bool, len(rules))
+	var result = rules[:0]
+	for _, rule := range rules {
+		if seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		result = append(result, rule)
+	}
+
+	return result
+}
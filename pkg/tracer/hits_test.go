@@ -0,0 +1,62 @@
+package tracer
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestRuleInventory(t *testing.T) {
+	var data = &domain.Data{
+		AllowedHosts: []string{".github.com", ".github.com"},
+		AllowedIPs:   []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("140.82.114.22")},
+		AllowedSources: []domain.AllowEntry{
+			{Address: "1.1.1.1", Source: domain.AllowSourceStatic, Rule: "allowed-ips"},
+			{Address: "140.82.114.22", Source: domain.AllowSourceDNS, Rule: ".github.com"},
+		},
+		AllowedPorts:       []domain.PortRule{{Port: 22}, {Address: "example.com", Port: 8443}},
+		ProcessRules:       []domain.ProcessRule{{Process: "curl", Action: "allow", Destination: "*.github.com"}},
+		DeniedIPs:          []net.IP{net.ParseIP("6.6.6.6").To4()},
+		AllowLocalIPRanges: true,
+	}
+
+	rules, aliases := ruleInventory(data)
+	var expected = []string{
+		"allowed-hosts=.github.com",
+		"allowed-ips=1.1.1.1",
+		"allowed-ports=22",
+		"allowed-ports=example.com:8443",
+		"process-policy=curl:allow:*.github.com",
+		"denied-ips=6.6.6.6",
+		"allow-local-ranges",
+	}
+	if !slices.Equal(rules, expected) {
+		t.Errorf("expected rules %v, got %v", expected, rules)
+	}
+	if aliases["allowed-ips=140.82.114.22"] != "allowed-hosts=.github.com" {
+		t.Errorf("unexpected aliases: %v", aliases)
+	}
+}
+
+func TestHitTable(t *testing.T) {
+	var table = newHitTable()
+
+	table.record([]string{"allowed-hosts=.github.com"}, domain.EventPolicyStatusPass)
+	table.record([]string{"allowed-hosts=.github.com", "denied-hosts=gist.github.com"}, domain.EventPolicyStatusBlock)
+	table.record([]string{"allowed-hosts=.github.com"}, domain.EventPolicyStatusPass)
+	// a rule removed by a reload
+	table.record([]string{"allowed-ips=1.1.1.1"}, domain.EventPolicyStatusPass)
+
+	hits := table.list([]string{"allow-local-ranges", "denied-hosts=gist.github.com", "allowed-hosts=.github.com"})
+	var expected = []domain.RuleHit{
+		{Rule: "allowed-hosts=.github.com", Allowed: 2, Denied: 1},
+		{Rule: "allowed-ips=1.1.1.1", Allowed: 1},
+		{Rule: "denied-hosts=gist.github.com", Denied: 1},
+		{Rule: "allow-local-ranges"},
+	}
+	if !slices.Equal(hits, expected) {
+		t.Errorf("expected hits %+v, got %+v", expected, hits)
+	}
+}
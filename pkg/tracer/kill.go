@@ -0,0 +1,47 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// validateKill returns an error if the kill on violation is not available with
+// the options: the kernel kills on the verdict of the allow and deny lists,
+// the enforcement must be neither scoped nor softened
+func validateKill(opts Options) error {
+	if !opts.KillOnViolation {
+		return nil
+	}
+
+	switch {
+	case opts.Passive || (opts.Mode != ModeTrace && opts.Mode != ModeTOFU):
+		return fmt.Errorf("kill on violation requires the %s or the %s mode", ModeTrace, ModeTOFU)
+	case opts.CgroupPath != "" || opts.ContainerID != "" || opts.ServiceContainer:
+		return errors.New("kill on violation is not available with a scoped enforcement (cgroup path, container id or service container)")
+	case opts.BlockAction == BlockActionTarpit:
+		return errors.New("kill on violation and the tarpit block action are mutually exclusive")
+	}
+
+	return nil
+}
+
+// putKillConfig enables the kill on violation in the kernel, kntrl itself is never killed
+func (t *Tracer) putKillConfig() error {
+	if !t.opts.KillOnViolation {
+		return nil
+	}
+
+	var value = ebpfman.KillConfig{Enabled: 1, SelfPid: uint32(os.Getpid())}
+	killMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapKillConfig]
+	if err := killMap.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("%w: failed to set kill on violation: %w", ErrMapUpdate, err)
+	}
+	logger.Log.Warnf("kill on violation: the processes connecting to a blocked destination are killed")
+
+	return nil
+}
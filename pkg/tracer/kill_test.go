@@ -0,0 +1,26 @@
+package tracer
+
+import "testing"
+
+func TestValidateKill(t *testing.T) {
+	var tests = []struct {
+		name  string
+		opts  Options
+		valid bool
+	}{
+		{"disabled", Options{Mode: ModeMonitor}, true},
+		{"trace", Options{Mode: ModeTrace, KillOnViolation: true}, true},
+		{"tofu", Options{Mode: ModeTOFU, KillOnViolation: true}, true},
+		{"monitor", Options{Mode: ModeMonitor, KillOnViolation: true}, false},
+		{"passive", Options{Mode: ModeMonitor, Passive: true, KillOnViolation: true}, false},
+		{"container", Options{Mode: ModeTrace, ContainerID: "3f2a", KillOnViolation: true}, false},
+		{"service container", Options{Mode: ModeTrace, ServiceContainer: true, KillOnViolation: true}, false},
+		{"tarpit", Options{Mode: ModeTrace, BlockAction: BlockActionTarpit, KillOnViolation: true}, false},
+	}
+
+	for _, tt := range tests {
+		if err := validateKill(tt.opts); (err == nil) != tt.valid {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
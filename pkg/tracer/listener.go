@@ -0,0 +1,124 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"syscall"
+
+	"github.com/cilium/ebpf/perf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// listenerTable is the sockets listening on a non-loopback address, a socket
+// is reported once per process, protocol, address and port
+type listenerTable struct {
+	mu        sync.Mutex
+	seen      map[string]bool
+	listeners []domain.Listener
+}
+
+func newListenerTable() *listenerTable {
+	return &listenerTable{seen: make(map[string]bool)}
+}
+
+// add adds the listener, it returns false if it's already reported
+func (l *listenerTable) add(listener domain.Listener) bool {
+	var key = fmt.Sprintf("%d/%s/%s:%d", listener.ProcessID, listener.Protocol, listener.Address, listener.Port)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen[key] {
+		return false
+	}
+	l.seen[key] = true
+	l.listeners = append(l.listeners, listener)
+
+	return true
+}
+
+// list returns a copy of the listeners
+func (l *listenerTable) list() []domain.Listener {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var listeners = make([]domain.Listener, len(l.listeners))
+	copy(listeners, l.listeners)
+
+	return listeners
+}
+
+// listenAddr returns the local address of the event, the IPv4-mapped IPv6
+// addresses are unmapped
+func listenAddr(event domain.ListenEvent) (netip.Addr, bool) {
+	switch event.Af {
+	case syscall.AF_INET:
+		return netip.AddrFrom4([4]byte(event.Addr[:4])), true
+	case syscall.AF_INET6:
+		return netip.AddrFrom16(event.Addr).Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// readListenEvents reports the sockets listening on a non-loopback address
+// until the perf reader is closed. kntrl itself (the metrics and the control
+// API) and the excluded processes are not reported.
+func (t *Tracer) readListenEvents(rd *perf.Reader) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read listen event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapListenEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.ListenEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse listen event: %v", err)
+			continue
+		}
+
+		addr, ok := listenAddr(event)
+		if !ok || addr.IsLoopback() {
+			continue
+		}
+
+		var taskname = utils.TrimNullBytes(event.Task)
+		if t.self.Is(event.Pid, t.execCache) || t.exclusions.match(event.Pid, taskname) || t.filter.process(event.Pid, taskname) {
+			continue
+		}
+
+		var listener = domain.Listener{
+			ProcessID: event.Pid,
+			TaskName:  taskname,
+			Protocol:  utils.GetProtocol(event.Proto),
+			Address:   addr.String(),
+			Port:      event.Port,
+			Timestamp: t.report.Now(),
+		}
+		if info, ok := t.execCache.Lookup(event.Pid); ok {
+			listener.Executable = info.Path
+		}
+
+		if t.listeners.add(listener) {
+			logger.Log.Warnf("listener [%d]%s: %s %s", event.Pid, taskname, listener.Protocol,
+				netip.AddrPortFrom(addr, event.Port))
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tracer
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestListenAddr(t *testing.T) {
+	var tests = []struct {
+		af       uint8
+		addr     [16]byte
+		want     string
+		loopback bool
+	}{
+		{syscall.AF_INET, [16]byte{0, 0, 0, 0}, "0.0.0.0", false},
+		{syscall.AF_INET, [16]byte{127, 0, 0, 1}, "127.0.0.1", true},
+		{syscall.AF_INET, [16]byte{10, 1, 0, 4}, "10.1.0.4", false},
+		{syscall.AF_INET6, [16]byte{}, "::", false},
+		{syscall.AF_INET6, [16]byte{15: 1}, "::1", true},
+		{syscall.AF_INET6, [16]byte{10: 0xff, 11: 0xff, 12: 127, 15: 1}, "127.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		addr, ok := listenAddr(domain.ListenEvent{Af: tt.af, Addr: tt.addr})
+		if !ok {
+			t.Errorf("%s: unexpected family", tt.want)
+			continue
+		}
+		if addr.String() != tt.want || addr.IsLoopback() != tt.loopback {
+			t.Errorf("expected %s (loopback %v), got %s", tt.want, tt.loopback, addr)
+		}
+	}
+
+	if _, ok := listenAddr(domain.ListenEvent{Af: syscall.AF_UNIX}); ok {
+		t.Errorf("expected an error for a unix socket")
+	}
+}
+
+func TestListenerTable(t *testing.T) {
+	var table = newListenerTable()
+	var listener = domain.Listener{ProcessID: 42, TaskName: "nc", Protocol: "tcp", Address: "0.0.0.0", Port: 4444}
+
+	if !table.add(listener) {
+		t.Errorf("expected the listener to be added")
+	}
+	if table.add(listener) {
+		t.Errorf("expected the listener to be reported once")
+	}
+	listener.Protocol = "udp"
+	if !table.add(listener) {
+		t.Errorf("expected the udp listener to be added")
+	}
+
+	if got := table.list(); len(got) != 2 {
+		t.Errorf("expected 2 listeners, got %d", len(got))
+	}
+}
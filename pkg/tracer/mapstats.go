@@ -0,0 +1,176 @@
+package tracer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+)
+
+// DefaultMapStatsInterval is the interval of the samples of the eBPF maps
+const DefaultMapStatsInterval = 15 * time.Second
+
+// sampledMapTypes are the maps with a variable number of entries, the arrays
+// are always full and the perf event arrays and the socket storages have no
+// entries to count
+var sampledMapTypes = map[ebpf.MapType]bool{
+	ebpf.Hash:    true,
+	ebpf.LRUHash: true,
+	ebpf.LPMTrie: true,
+}
+
+// mapStats are the samples of the eBPF maps and the programs over the run
+type mapStats struct {
+	mu       sync.Mutex
+	maps     map[string]*domain.MapStats
+	programs map[string]domain.ProgramStats
+}
+
+func newMapStats() *mapStats {
+	return &mapStats{
+		maps:     make(map[string]*domain.MapStats),
+		programs: make(map[string]domain.ProgramStats),
+	}
+}
+
+// observe records a sample of the entries of the map
+func (s *mapStats) observe(name string, typ ebpf.MapType, maxEntries uint32, entries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.maps[name]
+	if !ok {
+		stats = &domain.MapStats{Name: name, Type: typ.String(), MaxEntries: maxEntries}
+		s.maps[name] = stats
+	}
+	stats.Entries = entries
+	stats.Peak = max(stats.Peak, entries)
+	if maxEntries > 0 {
+		stats.Pressure = float64(stats.Peak) / float64(maxEntries)
+	}
+}
+
+// observeProgram records the runs of the program
+func (s *mapStats) observeProgram(name string, runs uint64, runtime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.programs[name] = domain.ProgramStats{Name: name, Runs: runs, RuntimeNs: uint64(runtime.Nanoseconds())}
+}
+
+// list returns the samples of the maps by name
+func (s *mapStats) list() []domain.MapStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list = make([]domain.MapStats, 0, len(s.maps))
+	for _, stats := range s.maps {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+// listPrograms returns the runs of the programs by name, nil if not counted
+func (s *mapStats) listPrograms() []domain.ProgramStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.programs) == 0 {
+		return nil
+	}
+
+	var list = make([]domain.ProgramStats, 0, len(s.programs))
+	for _, stats := range s.programs {
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+// sampleMaps samples the maps and the programs in every interval until the
+// context is done. The programs are counted with Options.ProgramStats only,
+// the kernel stats slow the programs down.
+func (t *Tracer) sampleMaps(ctx context.Context, interval time.Duration) {
+	var stats io.Closer
+	if t.opts.ProgramStats {
+		var err error
+		if stats, err = enableProgramStats(); err != nil {
+			logger.Log.Warnf("failed to enable the eBPF program stats: %v", err)
+		} else {
+			defer stats.Close()
+		}
+	}
+
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for name, m := range t.ebpfClient.Collection.Maps {
+			if !sampledMapTypes[m.Type()] {
+				continue
+			}
+
+			n, err := countEntries(m)
+			if err != nil {
+				logger.Log.Debugf("failed to count map entries [%s]: %v", name, err)
+				continue
+			}
+			t.mapStats.observe(name, m.Type(), m.MaxEntries(), n)
+			metrics.ObserveMap(name, n, m.MaxEntries())
+		}
+
+		if stats != nil {
+			t.samplePrograms()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// samplePrograms records the runs of the programs counted by the kernel
+func (t *Tracer) samplePrograms() {
+	for name, p := range t.ebpfClient.Collection.Programs {
+		info, err := p.Info()
+		if err != nil {
+			logger.Log.Debugf("failed to read program info [%s]: %v", name, err)
+			continue
+		}
+
+		runs, ok := info.RunCount()
+		if !ok {
+			continue
+		}
+		runtime, _ := info.Runtime()
+		t.mapStats.observeProgram(name, runs, runtime)
+		metrics.ObserveProgram(name, runs, runtime)
+	}
+}
+
+// countEntries returns the number of the entries of the map
+func countEntries(m *ebpf.Map) (int, error) {
+	var (
+		key, value []byte
+		n          int
+	)
+
+	it := m.Iterate()
+	for it.Next(&key, &value) {
+		n++
+	}
+
+	return n, it.Err()
+}
@@ -0,0 +1,36 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestMapStats(t *testing.T) {
+	var stats = newMapStats()
+
+	stats.observe("allowed_ip_map", ebpf.Hash, 1024, 200)
+	stats.observe("allowed_ip_map", ebpf.Hash, 1024, 256)
+	stats.observe("allowed_ip_map", ebpf.Hash, 1024, 100)
+	stats.observe("deny_map", ebpf.Hash, 0, 3)
+
+	var list = stats.list()
+	if len(list) != 2 || list[0].Name != "allowed_ip_map" || list[1].Name != "deny_map" {
+		t.Fatalf("unexpected maps %+v", list)
+	}
+	if m := list[0]; m.Entries != 100 || m.Peak != 256 || m.Pressure != 0.25 || m.Type != "Hash" {
+		t.Errorf("unexpected stats %+v", m)
+	}
+	if m := list[1]; m.Pressure != 0 {
+		t.Errorf("expected no pressure without the max entries, got %v", m.Pressure)
+	}
+
+	if programs := stats.listPrograms(); programs != nil {
+		t.Errorf("expected no programs, got %+v", programs)
+	}
+	stats.observeProgram("kprobe__tcp_v4_connect", 10, 2*time.Microsecond)
+	if programs := stats.listPrograms(); len(programs) != 1 || programs[0].Runs != 10 || programs[0].RuntimeNs != 2000 {
+		t.Errorf("unexpected programs %+v", programs)
+	}
+}
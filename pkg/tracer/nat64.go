@@ -0,0 +1,31 @@
+package tracer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+)
+
+// nat64DiscoveryTimeout is the timeout of the NAT64 prefix discovery on start
+const nat64DiscoveryTimeout = 2 * time.Second
+
+// putNAT64Prefix writes the NAT64 prefix into the kernel map, the IPv6
+// connections to the prefix are reported and enforced as IPv4 connections
+func (t *Tracer) putNAT64Prefix() error {
+	if !t.nat64.IsValid() {
+		return nil
+	}
+
+	var value = ebpfman.NAT64Prefix{Prefix: nat64.Bytes(t.nat64), Enabled: 1}
+	nat64Map := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapNAT64Prefix]
+	if err := nat64Map.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("%w: failed to set nat64 prefix: %w", ErrMapUpdate, err)
+	}
+	logger.Log.Debugf("nat64 prefix: %s", t.nat64)
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// OverflowRejectNew rejects the new entries of a full map
+	OverflowRejectNew = "reject-new"
+	// OverflowEvictLRU evicts the least recently used entry added at runtime
+	OverflowEvictLRU = "evict-lru"
+	// OverflowMonitor switches the enforcement to the monitor mode, the deny list
+	// is still enforced and its new entries are rejected
+	OverflowMonitor = "switch-to-monitor"
+)
+
+// errMapFull is returned by the writes to a full map
+var errMapFull = errors.New("the map is full")
+
+// isValidOverflow returns true if the overflow strategy is supported
+func isValidOverflow(strategy string) bool {
+	switch strategy {
+	case OverflowRejectNew, OverflowEvictLRU, OverflowMonitor:
+		return true
+	}
+
+	return false
+}
+
+// lruTable keeps the last use of the map entries added at runtime,
+// the entries of the static policy are not tracked
+type lruTable struct {
+	mu      sync.Mutex
+	entries map[string]map[any]lruEntry
+}
+
+type lruEntry struct {
+	address string
+	usedAt  time.Time
+}
+
+func newLRUTable() *lruTable {
+	return &lruTable{entries: make(map[string]map[any]lruEntry)}
+}
+
+// add records the entry of the map
+func (l *lruTable) add(mapName string, key any, address string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, ok := l.entries[mapName]
+	if !ok {
+		entries = make(map[any]lruEntry)
+		l.entries[mapName] = entries
+	}
+	entries[key] = lruEntry{address: address, usedAt: now}
+}
+
+// touch updates the last use of the entry, the untracked keys are ignored
+func (l *lruTable) touch(mapName string, key any, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[mapName][key]; ok {
+		e.usedAt = now
+		l.entries[mapName][key] = e
+	}
+}
+
+// remove forgets the entry of the map
+func (l *lruTable) remove(mapName string, key any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries[mapName], key)
+}
+
+// oldest removes and returns the least recently used entry of the map
+func (l *lruTable) oldest(mapName string) (any, string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		oldest any
+		entry  lruEntry
+		found  bool
+	)
+	for key, e := range l.entries[mapName] {
+		if !found || e.usedAt.Before(entry.usedAt) {
+			oldest, entry, found = key, e, true
+		}
+	}
+	if found {
+		delete(l.entries[mapName], oldest)
+	}
+
+	return oldest, entry.address, found
+}
+
+// putKey puts the key into the map of the allow or deny list. The entries
+// added at runtime (with an address) are tracked for the evict-lru strategy.
+// When the map is full, the finding is recorded and the overflow strategy
+// is applied instead of failing the run.
+func (t *Tracer) putKey(m *ebpf.Map, mapName string, key any, address string) error {
+	err := m.Put(key, uint32(1))
+	if err == nil {
+		t.track(mapName, key, address)
+		return nil
+	}
+	if !errors.Is(err, syscall.E2BIG) {
+		return err
+	}
+
+	var action = t.opts.MapOverflow
+	if action == OverflowMonitor && mapName == domain.EBPFCollectionMapDeny {
+		// the deny list is enforced in the monitor mode too
+		action = OverflowRejectNew
+	}
+	t.watchdog.MapFull(mapName, m.MaxEntries(), action)
+
+	switch action {
+	case OverflowEvictLRU:
+		evicted, evictedAddress, ok := t.lru.oldest(mapName)
+		if !ok {
+			break
+		}
+		if err := m.Delete(evicted); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+		t.evicted(mapName, evictedAddress)
+
+		if err := m.Put(key, uint32(1)); err != nil {
+			return err
+		}
+		t.track(mapName, key, address)
+		return nil
+
+	case OverflowMonitor:
+		t.switchToMonitor(mapName)
+	}
+
+	return fmt.Errorf("%w: %s (%d entries)", errMapFull, mapName, m.MaxEntries())
+}
+
+// track records the use of a runtime entry, the entries of the static policy
+// are not tracked even if they are added again by the policy evaluation
+func (t *Tracer) track(mapName string, key any, address string) {
+	if address == "" || t.static.Load().has(mapName, key) {
+		return
+	}
+
+	t.lru.add(mapName, key, address, t.now())
+}
+
+// evicted removes the provenance of the evicted entry
+func (t *Tracer) evicted(mapName, address string) {
+	logger.Log.Warnf("[%s] evicted from the full map [%s]", address, mapName)
+
+	if mapName == domain.EBPFCollectionMapDeny {
+		return
+	}
+
+	t.allowed.remove(address)
+	if t.tracker != nil {
+		t.tracker.RemoveAllowedIP(address)
+	}
+}
+
+// switchToMonitor stops the enforcement of the allow list, the connections
+// are not blocked anymore. The mode is switched once.
+func (t *Tracer) switchToMonitor(mapName string) {
+	if !t.monitorFallback.CompareAndSwap(false, true) {
+		return
+	}
+
+	modeMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapMode]
+	if err := modeMap.Put(uint32(0), uint32(domain.TracerModeIndexMonitor)); err != nil {
+		logger.Log.Errorf("failed to switch to the monitor mode: %v", err)
+		return
+	}
+
+	logger.Log.Errorf("the map [%s] is full, switched to the %s mode: the connections are not blocked anymore", mapName, ModeMonitor)
+}
+
+// enforcing returns true if the policy is enforced by the kernel
+func (t *Tracer) enforcing() bool {
+	return (t.kernelMode != ModeMonitor || t.escalated.Load()) && !t.monitorFallback.Load()
+}
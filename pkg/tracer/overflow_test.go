@@ -0,0 +1,64 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+)
+
+func TestLRUTable(t *testing.T) {
+	var (
+		l    = newLRUTable()
+		now  = time.Now()
+		a    = ebpfman.IPv4Key{10, 0, 0, 1}
+		b    = ebpfman.IPv4Key{10, 0, 0, 2}
+		port = ebpfman.PortKey{Addr: a, Port: 443}
+	)
+
+	l.add(domain.EBPFCollectionMapAllowedIP, a, "10.0.0.1", now)
+	l.add(domain.EBPFCollectionMapAllowedIP, b, "10.0.0.2", now.Add(time.Second))
+	l.add(domain.EBPFCollectionMapAllowedPort, port, "10.0.0.1:443", now.Add(-time.Hour))
+
+	// the first entry is used again, the second one is the least recently used
+	l.touch(domain.EBPFCollectionMapAllowedIP, a, now.Add(2*time.Second))
+	key, address, ok := l.oldest(domain.EBPFCollectionMapAllowedIP)
+	if !ok || key != b || address != "10.0.0.2" {
+		t.Errorf("expected the second entry, got %v %s %v", key, address, ok)
+	}
+
+	l.remove(domain.EBPFCollectionMapAllowedIP, a)
+	if _, _, ok := l.oldest(domain.EBPFCollectionMapAllowedIP); ok {
+		t.Error("expected no entry")
+	}
+
+	// the maps are tracked separately
+	if key, _, ok := l.oldest(domain.EBPFCollectionMapAllowedPort); !ok || key != port {
+		t.Errorf("expected the port entry, got %v %v", key, ok)
+	}
+	if _, _, ok := l.oldest(domain.EBPFCollectionMapDeny); ok {
+		t.Error("expected no deny entry")
+	}
+}
+
+func TestStaticKeysHas(t *testing.T) {
+	var (
+		a = ebpfman.IPv4Key{10, 0, 0, 1}
+		s = &staticKeys{
+			allowed: map[ebpfman.IPv4Key]AllowEntry{a: {Address: "10.0.0.1"}},
+			ports:   map[ebpfman.PortKey]AllowEntry{},
+			denied:  map[ebpfman.IPv4Key]struct{}{},
+		}
+	)
+
+	if !s.has(domain.EBPFCollectionMapAllowedIP, a) {
+		t.Error("expected the allowed IP")
+	}
+	if s.has(domain.EBPFCollectionMapDeny, a) || s.has(domain.EBPFCollectionMapAllowedPort, ebpfman.PortKey{Addr: a, Port: 443}) {
+		t.Error("expected the key of the other maps to be missing")
+	}
+	if (*staticKeys)(nil).has(domain.EBPFCollectionMapAllowedIP, a) {
+		t.Error("expected no key without a policy")
+	}
+}
@@ -0,0 +1,202 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// PinPath is the directory of the pinned enforcement in the BPF filesystem
+	PinPath = "/sys/fs/bpf/kntrl"
+
+	pinLinksDir = "links"
+	pinMapsDir  = "maps"
+)
+
+// stateMaps are the maps of the enforcement state, the allow and the deny
+// lists with the entries added at runtime (the exceptions, the tarpit). With
+// --pin, they are taken over from the previous kntrl, the other maps are
+// filled from the options.
+var stateMaps = []string{
+	domain.EBPFCollectionMapAllowedIP,
+	domain.EBPFCollectionMapAllowedPort,
+	domain.EBPFCollectionMapAllowedHost,
+	domain.EBPFCollectionMapAllowedRange,
+	domain.EBPFCollectionMapDeny,
+	domain.EBPFCollectionMapTarpit,
+}
+
+// pinnedMaps returns the state maps pinned under the directory by a previous
+// kntrl that are compatible with their spec, they replace the maps of the
+// collection. The incompatible maps (of another kntrl version) are replaced
+// by new maps when the maps are pinned.
+func pinnedMaps(spec *ebpf.CollectionSpec, pinPath string) (map[string]*ebpf.Map, error) {
+	var maps = make(map[string]*ebpf.Map)
+	for _, name := range stateMaps {
+		ms, ok := spec.Maps[name]
+		if !ok {
+			continue
+		}
+
+		var path = filepath.Join(pinPath, pinMapsDir, name)
+		m, err := ebpf.LoadPinnedMap(path, nil)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			closeMaps(maps)
+			return nil, fmt.Errorf("failed to load the pinned map [%s]: %w", path, err)
+		}
+
+		if err := ms.Compatible(m); err != nil {
+			logger.Log.Warnf("the pinned map [%s] is not taken over: %v", path, err)
+			m.Close()
+			continue
+		}
+		maps[name] = m
+	}
+
+	return maps, nil
+}
+
+// closeMaps closes the maps
+func closeMaps(maps map[string]*ebpf.Map) {
+	for _, m := range maps {
+		m.Close()
+	}
+}
+
+// attachPinned attaches the cgroup program of the enforcement through its
+// pinned link. The link pinned by a previous kntrl on the same cgroup is taken
+// over: its program is replaced atomically by the program of this kntrl, the
+// maps of which are filled, and the traffic is never unfiltered. The link of
+// another cgroup is removed once the new link is attached.
+func attachPinned(name string, prg *ebpf.Program, cgroup string) (link.Link, error) {
+	id, err := ebpfman.CgroupID(cgroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cgroup [%s]: %w", cgroup, err)
+	}
+
+	var path = filepath.Join(PinPath, pinLinksDir, name)
+	pinned, err := link.LoadPinnedLink(path, nil)
+	switch {
+	case err == nil:
+		if info, err := pinned.Info(); err == nil && info.Cgroup() != nil && info.Cgroup().CgroupId == id {
+			if err := pinned.Update(prg); err != nil {
+				pinned.Close()
+				return nil, fmt.Errorf("failed to take over the pinned link [%s]: %w", path, err)
+			}
+			logger.Log.Infof("took over the pinned enforcement [%s]", path)
+			return pinned, nil
+		}
+
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to load the pinned link [%s]: %w", path, err)
+	}
+
+	l, err := link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroup,
+		Attach:  ebpf.AttachCGroupInetEgress,
+		Program: prg,
+	})
+	if pinned != nil {
+		// the previous enforcement is kept if the new one can't be attached
+		if err == nil {
+			if err := pinned.Unpin(); err != nil {
+				logger.Log.Warnf("failed to remove the pinned link [%s]: %v", path, err)
+			}
+		}
+		pinned.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to create the pin directory: %w", err)
+	}
+	if err := l.Pin(path); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to pin the link [%s]: %w", path, err)
+	}
+	logger.Log.Infof("pinned the enforcement [%s]", path)
+
+	return l, nil
+}
+
+// pinMaps pins the maps of the programs, the maps pinned by a previous kntrl
+// are replaced but the state maps taken over, which are pinned already. The
+// event buffers are not pinned.
+func (t *Tracer) pinMaps() error {
+	var dir = filepath.Join(PinPath, pinMapsDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create the pin directory: %w", err)
+	}
+
+	for name, m := range t.ebpfClient.Collection.Maps {
+		if _, ok := t.pinnedMaps[name]; ok || m.Type() == ebpf.PerfEventArray {
+			continue
+		}
+
+		var path = filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove the pinned map [%s]: %w", path, err)
+		}
+		if err := m.Pin(path); err != nil {
+			return fmt.Errorf("failed to pin the map [%s]: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Detach removes the enforcement pinned under PinPath: the pinned links are
+// detached (the links of a running kntrl when it stops) and the pinned maps
+// released. It returns the removed paths, nothing is removed with dryRun.
+func Detach(dryRun bool) ([]string, error) {
+	return detach(PinPath, dryRun)
+}
+
+// detach removes the pinned objects of the directory
+func detach(pinPath string, dryRun bool) ([]string, error) {
+	var removed = []string{}
+	// the links first: the enforcement is detached, then its maps are released
+	for _, dir := range []string{pinLinksDir, pinMapsDir} {
+		dir = filepath.Join(pinPath, dir)
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to read the pinned objects: %w", err)
+		}
+
+		for _, entry := range entries {
+			var path = filepath.Join(dir, entry.Name())
+			if !dryRun {
+				if err := os.Remove(path); err != nil {
+					return removed, fmt.Errorf("failed to remove [%s]: %w", path, err)
+				}
+			}
+			removed = append(removed, path)
+		}
+		if !dryRun {
+			_ = os.Remove(dir)
+		}
+	}
+	if !dryRun {
+		_ = os.Remove(pinPath)
+	}
+
+	return removed, nil
+}
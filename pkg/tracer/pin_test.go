@@ -0,0 +1,118 @@
+package tracer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestDetach(t *testing.T) {
+	var dir = filepath.Join(t.TempDir(), "kntrl")
+	for _, path := range []string{"links/egress", "maps/deny_map"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the links are removed before the maps
+	var want = []string{filepath.Join(dir, "links/egress"), filepath.Join(dir, "maps/deny_map")}
+	removed, err := detach(dir, true)
+	if err != nil || !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected %v, got %v (%v)", want, removed, err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected nothing removed on dry run: %v", err)
+	}
+
+	if removed, err = detach(dir, false); err != nil || !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected %v, got %v (%v)", want, removed, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the pin directory removed, got %v", err)
+	}
+
+	if removed, err = detach(dir, false); err != nil || len(removed) != 0 {
+		t.Errorf("expected nothing to detach, got %v (%v)", removed, err)
+	}
+}
+
+func TestPinnedStatus(t *testing.T) {
+	if _, err := pinnedStatus(filepath.Join(t.TempDir(), "kntrl")); !errors.Is(err, ErrNotPinned) {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+}
+
+// stateSpec returns the spec of the allow and the deny maps
+func stateSpec(denyEntries uint32) *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{Maps: map[string]*ebpf.MapSpec{
+		domain.EBPFCollectionMapAllowedIP: {Name: "allowed_ip_map", Type: ebpf.Hash, KeySize: 4, ValueSize: 4, MaxEntries: 16},
+		domain.EBPFCollectionMapDeny:      {Name: "deny_map", Type: ebpf.Hash, KeySize: 4, ValueSize: 4, MaxEntries: denyEntries},
+	}}
+}
+
+func TestPinnedMaps(t *testing.T) {
+	previous, err := ebpf.NewCollection(stateSpec(16))
+	if err != nil {
+		t.Skipf("failed to create the maps: %v", err)
+	}
+	defer previous.Close()
+
+	dir, err := os.MkdirTemp(filepath.Dir(PinPath), "kntrl-test-")
+	if err != nil {
+		t.Skipf("no bpf filesystem: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, pinMapsDir), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entry added at runtime by the previous kntrl
+	var key = [4]byte{140, 82, 112, 4}
+	for name, m := range previous.Maps {
+		if err := m.Put(key, uint32(1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Pin(filepath.Join(dir, pinMapsDir, name)); err != nil {
+			t.Skipf("failed to pin the map: %v", err)
+		}
+	}
+
+	// the deny map of the new spec is incompatible, it's not taken over
+	var spec = stateSpec(32)
+	maps, err := pinnedMaps(spec, dir)
+	if err != nil {
+		t.Fatalf("failed to load the pinned maps: %v", err)
+	}
+	defer closeMaps(maps)
+	if _, ok := maps[domain.EBPFCollectionMapAllowedIP]; !ok || len(maps) != 1 {
+		t.Fatalf("expected the allowed ip map taken over, got %v", maps)
+	}
+
+	coll, err := ebpf.NewCollectionWithOptions(spec, ebpf.CollectionOptions{MapReplacements: maps})
+	if err != nil {
+		t.Fatalf("failed to load the collection: %v", err)
+	}
+	defer coll.Close()
+
+	var value uint32
+	if err := coll.Maps[domain.EBPFCollectionMapAllowedIP].Lookup(key, &value); err != nil || value != 1 {
+		t.Errorf("expected the entry of the previous kntrl kept, got %d (%v)", value, err)
+	}
+	if err := coll.Maps[domain.EBPFCollectionMapDeny].Lookup(key, &value); !errors.Is(err, ebpf.ErrKeyNotExist) {
+		t.Errorf("expected a new deny map, got %v", err)
+	}
+
+	if maps, err := pinnedMaps(spec, t.TempDir()); err != nil || len(maps) != 0 {
+		t.Errorf("expected no pinned maps, got %v (%v)", maps, err)
+	}
+}
@@ -0,0 +1,19 @@
+package tracer
+
+import (
+	"io"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// checkPlatform returns an error if the tracer can't run on this platform
+func checkPlatform() error {
+	return nil
+}
+
+// enableProgramStats enables the run counts and the runtime of the eBPF
+// programs until the closer is closed (Linux 5.8+)
+func enableProgramStats() (io.Closer, error) {
+	return ebpf.EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+}
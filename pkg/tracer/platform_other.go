@@ -0,0 +1,20 @@
+//go:build !linux
+
+package tracer
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// checkPlatform returns an error if the tracer can't run on this platform,
+// the eBPF programs are supported on linux only
+func checkPlatform() error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// enableProgramStats returns an error, the eBPF programs are supported on linux only
+func enableProgramStats() (io.Closer, error) {
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
@@ -0,0 +1,70 @@
+package tracer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+// AllowEntry is the provenance of an allow list entry
+type AllowEntry = domain.AllowEntry
+
+// allowTable is the userspace shadow of the allow list maps,
+// it keeps why each destination is allowed
+type allowTable struct {
+	mu      sync.Mutex
+	entries map[string]AllowEntry
+}
+
+func newAllowTable() *allowTable {
+	return &allowTable{entries: make(map[string]AllowEntry)}
+}
+
+// add records the entry, the first provenance of an address is kept
+func (a *allowTable) add(entry AllowEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.entries[entry.Address]; ok {
+		return
+	}
+	a.entries[entry.Address] = entry
+}
+
+// has returns true if the address has an entry
+func (a *allowTable) has(address string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, ok := a.entries[address]
+	return ok
+}
+
+// remove deletes the entry of the address
+func (a *allowTable) remove(address string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.entries, address)
+}
+
+// list returns the entries in the order they are added
+func (a *allowTable) list() []AllowEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var entries = make([]AllowEntry, 0, len(a.entries))
+	for _, e := range a.entries {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AddedAt.Equal(entries[j].AddedAt) {
+			return entries[i].Address < entries[j].Address
+		}
+		return entries[i].AddedAt.Before(entries[j].AddedAt)
+	})
+
+	return entries
+}
@@ -0,0 +1,31 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestAllowTable(t *testing.T) {
+	var now = time.Now()
+	var table = newAllowTable()
+
+	table.add(AllowEntry{Address: "1.1.1.1", Source: domain.AllowSourcePolicy, Rule: "one.one.one.one", AddedAt: now.Add(time.Second)})
+	table.add(AllowEntry{Address: "140.82.114.22", Source: domain.AllowSourceDNS, Rule: ".github.com", AddedAt: now})
+	// the first provenance is kept
+	table.add(AllowEntry{Address: "1.1.1.1", Source: domain.AllowSourceRuntime, AddedAt: now.Add(2 * time.Second)})
+
+	entries := table.list()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Address != "140.82.114.22" || entries[1].Source != domain.AllowSourcePolicy {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	table.remove("1.1.1.1")
+	if entries = table.list(); len(entries) != 1 {
+		t.Errorf("expected the entry to be removed, got %+v", entries)
+	}
+}
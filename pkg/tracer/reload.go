@@ -0,0 +1,592 @@
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/kondukto-io/kntrl/bundle"
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/parser"
+	"github.com/kondukto-io/kntrl/pkg/policy"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// policyReloadDelay groups the events of a file write, the editors write in several steps
+const policyReloadDelay = 200 * time.Millisecond
+
+// ruleset is the compiled policy, it is swapped as a whole on reload
+type ruleset struct {
+	policy     *policy.Policy
+	denyPolicy *policy.Policy
+	// hitPolicy returns the rules matching an event,
+	// the rule hits are counted for the inventory
+	hitPolicy *policy.Policy
+	inventory []string
+	aliases   map[string]string
+	// portScoped is true if there are port rules,
+	// the dynamic allow list additions are port scoped then
+	portScoped bool
+}
+
+// compile builds the policy data and the rego policies of the options, the
+// GitHub meta ranges are used if the options allow them
+func compile(opts Options, githubRanges []string) (*domain.Data, *ruleset, error) {
+	processRules, err := parser.ParseProcessRules(opts.ProcessRules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := parser.ToDataJson(
+		strings.Join(opts.AllowedHosts, ","),
+		strings.Join(opts.AllowedIPs, ","),
+		opts.AllowGithubMeta,
+		opts.AllowLocalRanges,
+		!opts.NoDefaultAllow,
+		!opts.NoMetadataEndpoints,
+	)
+	data.ProcessRules = processRules
+	if data.AllowGithubMeta {
+		data.GithubMetaRanges = githubRanges
+	}
+	data.AllowedPorts, err = parser.ParsePortRules(opts.AllowedPorts)
+	if err != nil {
+		return nil, nil, err
+	}
+	data.DeniedHosts, data.DeniedIPs = parser.ToDenyList(
+		strings.Join(opts.DeniedHosts, ","),
+		strings.Join(opts.DeniedIPs, ","),
+	)
+	data.MinReputation = opts.MinReputation
+
+	dataObj, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error converting dataobj: %w", err)
+	}
+
+	p, err := policy.New(bundle.Bundle, dataObj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("policy init error: %w", err)
+	}
+
+	p.AddQuery("data.kntrl.policy")
+
+	var rules = &ruleset{
+		policy:     p,
+		portScoped: len(data.AllowedPorts) > 0,
+	}
+
+	// the deny list is evaluated in all modes
+	if len(data.DeniedHosts) > 0 || len(data.DeniedIPs) > 0 || data.MinReputation > 0 {
+		rules.denyPolicy, err = policy.New(bundle.Bundle, dataObj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("policy init error: %w", err)
+		}
+		rules.denyPolicy.AddQuery("data.kntrl.denied")
+	}
+
+	rules.hitPolicy, err = policy.New(bundle.Bundle, dataObj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("policy init error: %w", err)
+	}
+	rules.hitPolicy.AddQuery("data.kntrl.hits")
+	rules.inventory, rules.aliases = ruleInventory(data)
+
+	return data, rules, nil
+}
+
+// withPolicyFile returns the options merged with the policy file,
+// the slices of the given options are not modified
+func withPolicyFile(opts Options) (Options, error) {
+	if opts.PolicyFile == "" {
+		return opts, nil
+	}
+
+	pf, err := policy.LoadFile(opts.PolicyFile)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.AllowedHosts = append(slices.Clip(opts.AllowedHosts), pf.AllowedHosts...)
+	opts.AllowedIPs = append(slices.Clip(opts.AllowedIPs), pf.AllowedIPs...)
+	// hosts takes both the addresses and the hostnames, as --hosts
+	hosts, err := utils.ParseHosts(strings.Join(pf.Hosts, ","))
+	if err != nil {
+		return opts, fmt.Errorf("policy file [%s]: %w", opts.PolicyFile, err)
+	}
+	for _, h := range hosts {
+		if h.Kind == utils.HostName {
+			opts.AllowedHosts = append(opts.AllowedHosts, h.Name)
+		} else {
+			opts.AllowedIPs = append(opts.AllowedIPs, h.String())
+		}
+	}
+	opts.AllowedPorts = append(slices.Clip(opts.AllowedPorts), pf.AllowedPorts...)
+	opts.DeniedHosts = append(slices.Clip(opts.DeniedHosts), pf.DeniedHosts...)
+	opts.DeniedIPs = append(slices.Clip(opts.DeniedIPs), pf.DeniedIPs...)
+	opts.ProcessRules = append(slices.Clip(opts.ProcessRules), pf.ProcessPolicy...)
+
+	return opts, nil
+}
+
+// runtimeRules are the hosts allowed and the destinations denied on the
+// control API (kntrl allow, kntrl deny), they are kept on reload
+type runtimeRules struct {
+	mu           sync.Mutex
+	allowedHosts []string
+	deniedHosts  []string
+	deniedIPs    []string
+}
+
+// apply returns the options with the runtime rules,
+// the slices of the given options are not modified
+func (r *runtimeRules) apply(opts Options) Options {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	opts.AllowedHosts = append(slices.Clip(opts.AllowedHosts), r.allowedHosts...)
+	opts.DeniedHosts = append(slices.Clip(opts.DeniedHosts), r.deniedHosts...)
+	opts.DeniedIPs = append(slices.Clip(opts.DeniedIPs), r.deniedIPs...)
+
+	return opts
+}
+
+// add adds the values not in the list and returns them
+func (r *runtimeRules) add(list *[]string, values []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var added []string
+	for _, v := range values {
+		if !slices.Contains(*list, v) {
+			*list = append(*list, v)
+			added = append(added, v)
+		}
+	}
+
+	return added
+}
+
+// remove removes the values from the list, it returns false if none was in the list
+func (r *runtimeRules) remove(list *[]string, values []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n = len(*list)
+	*list = slices.DeleteFunc(*list, func(v string) bool { return slices.Contains(values, v) })
+
+	return len(*list) < n
+}
+
+// staticKeys are the map entries of the policy: the allowed IPs (resolved hosts
+// included), the port rules, the denied IPs and the allowed hostnames of the DNS
+// answers. The dynamic entries are not included.
+type staticKeys struct {
+	allowed map[ebpfman.IPv4Key]AllowEntry
+	ports   map[ebpfman.PortKey]AllowEntry
+	denied  map[ebpfman.IPv4Key]struct{}
+	hosts   map[ebpfman.HostKey]struct{}
+	ranges  map[ebpfman.RangeKey]struct{}
+}
+
+// has returns true if the key of the map is an entry of the policy
+func (s *staticKeys) has(mapName string, key any) bool {
+	if s == nil {
+		return false
+	}
+
+	var ok bool
+	switch k := key.(type) {
+	case ebpfman.IPv4Key:
+		if mapName == domain.EBPFCollectionMapDeny {
+			_, ok = s.denied[k]
+		} else {
+			_, ok = s.allowed[k]
+		}
+	case ebpfman.PortKey:
+		_, ok = s.ports[k]
+	}
+
+	return ok
+}
+
+// newStaticKeys returns the map entries of the policy data, the port rule hostnames are resolved
+func newStaticKeys(data *domain.Data) *staticKeys {
+	var keys = &staticKeys{
+		allowed: make(map[ebpfman.IPv4Key]AllowEntry),
+		ports:   make(map[ebpfman.PortKey]AllowEntry),
+		denied:  make(map[ebpfman.IPv4Key]struct{}),
+		hosts:   make(map[ebpfman.HostKey]struct{}),
+		ranges:  make(map[ebpfman.RangeKey]struct{}),
+	}
+
+	var sources = make(map[string]AllowEntry, len(data.AllowedSources))
+	for _, entry := range data.AllowedSources {
+		sources[entry.Address] = entry
+	}
+
+	for _, ip := range data.AllowedIPs {
+		key, ok := ebpfman.NewIPv4Key(ip)
+		if !ok {
+			continue
+		}
+
+		entry, ok := sources[key.String()]
+		if !ok {
+			entry = AllowEntry{Address: key.String(), Source: domain.AllowSourceStatic, Rule: "allowed-ips"}
+		}
+		keys.allowed[key] = entry
+	}
+
+	for _, rule := range data.AllowedPorts {
+		resolvePortRule(rule, keys.ports)
+	}
+
+	for _, ip := range data.DeniedIPs {
+		if key, ok := ebpfman.NewIPv4Key(ip); ok {
+			keys.denied[key] = struct{}{}
+		}
+	}
+
+	for _, host := range data.AllowedHosts {
+		if key, ok := ebpfman.NewHostKey(host); ok {
+			keys.hosts[key] = struct{}{}
+		}
+	}
+
+	// the IPv6 ranges are matched by the policy evaluation only
+	for _, cidr := range data.GithubMetaRanges {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+		if key, ok := ebpfman.NewRangeKey(prefix); ok {
+			keys.ranges[key] = struct{}{}
+		}
+	}
+
+	return keys
+}
+
+// resolvePortRule adds the map entries of the port rule, the hostnames are resolved.
+// Other than IPv4 addresses are ignored.
+func resolvePortRule(rule domain.PortRule, keys map[ebpfman.PortKey]AllowEntry) {
+	if rule.Port == 0 {
+		return
+	}
+
+	var port = strconv.Itoa(int(rule.Port))
+	if rule.Address == "" {
+		keys[ebpfman.PortKey{Port: rule.Port}] = AllowEntry{Address: net.JoinHostPort("*", port), Source: domain.AllowSourceStatic, Rule: "allowed-ports"}
+		return
+	}
+
+	var source, ruleName = domain.AllowSourceStatic, "allowed-ports"
+	var ips = []net.IP{net.ParseIP(rule.Address)}
+	if ips[0] == nil {
+		source, ruleName = domain.AllowSourceDNS, net.JoinHostPort(rule.Address, port)
+		resolved, err := net.LookupIP(strings.TrimPrefix(rule.Address, "."))
+		if err != nil {
+			logger.Log.Warnf("failed to resolve port rule host [%s]: %v", rule.Address, err)
+			return
+		}
+		ips = resolved
+	}
+
+	for _, ip := range ips {
+		addr, ok := ebpfman.NewIPv4Key(ip)
+		if !ok {
+			continue
+		}
+		keys[ebpfman.PortKey{Addr: addr, Port: rule.Port}] = AllowEntry{Address: net.JoinHostPort(addr.String(), port), Source: source, Rule: ruleName}
+	}
+}
+
+// keysDiff is the difference of the map entries of two policies
+type keysDiff struct {
+	allowAdded, allowRemoved []ebpfman.IPv4Key
+	portAdded, portRemoved   []ebpfman.PortKey
+	denyAdded, denyRemoved   []ebpfman.IPv4Key
+	hostAdded, hostRemoved   []ebpfman.HostKey
+	rangeAdded, rangeRemoved []ebpfman.RangeKey
+}
+
+func (s *staticKeys) diff(next *staticKeys) keysDiff {
+	var d keysDiff
+	d.allowAdded, d.allowRemoved = diffKeys(s.allowed, next.allowed)
+	d.portAdded, d.portRemoved = diffKeys(s.ports, next.ports)
+	d.denyAdded, d.denyRemoved = diffKeys(s.denied, next.denied)
+	d.hostAdded, d.hostRemoved = diffKeys(s.hosts, next.hosts)
+	d.rangeAdded, d.rangeRemoved = diffKeys(s.ranges, next.ranges)
+
+	return d
+}
+
+// empty returns true if the policies have the same map entries
+func (d keysDiff) empty() bool {
+	return len(d.allowAdded)+len(d.allowRemoved)+len(d.portAdded)+len(d.portRemoved)+len(d.denyAdded)+len(d.denyRemoved)+len(d.hostAdded)+len(d.hostRemoved)+len(d.rangeAdded)+len(d.rangeRemoved) == 0
+}
+
+// diffKeys returns the keys of next not in prev and the keys of prev not in next, sorted
+func diffKeys[K interface {
+	comparable
+	fmt.Stringer
+}, V any](prev, next map[K]V) ([]K, []K) {
+	var added, removed []K
+	for k := range next {
+		if _, ok := prev[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	sort.Slice(added, func(i, j int) bool { return added[i].String() < added[j].String() })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].String() < removed[j].String() })
+
+	return added, removed
+}
+
+// Reload re-reads the policy file and reconciles the allow and deny maps without
+// restarting the tracer. The entries added at runtime (policy decisions, verdicts,
+// kntrl allow) are kept. The current policy is kept if the policy file is invalid.
+func (t *Tracer) Reload() error {
+	if t.opts.PolicyFile == "" {
+		return errors.New("no policy file to reload")
+	}
+	if t.opts.Passive {
+		return errPassive
+	}
+
+	if err := t.reconcile(fmt.Sprintf("policy [%s] reloaded", t.opts.PolicyFile), true); err != nil {
+		return fmt.Errorf("failed to reload policy: %w", err)
+	}
+
+	return nil
+}
+
+// reconcile compiles the policy of the options and the policy file again, the
+// allowed hosts are resolved again, and reconciles the allow and deny maps.
+// The earlier addresses of the allowed hosts are kept, see keepResolved. The
+// changes are logged with the message, if there are any unless always.
+func (t *Tracer) reconcile(message string, always bool) error {
+	t.reloadMu.Lock()
+	defer t.reloadMu.Unlock()
+
+	opts, err := withPolicyFile(t.opts)
+	if err != nil {
+		return err
+	}
+	opts = t.runtime.apply(opts)
+
+	data, rules, err := compile(opts, t.githubMetaRanges(opts))
+	if err != nil {
+		return err
+	}
+
+	var prev, next = t.static.Load(), newStaticKeys(data)
+	next.keepResolved(prev)
+	var d = prev.diff(next)
+	if d.empty() && !always {
+		return nil
+	}
+
+	// the new entries are added before the policy is swapped and the stale
+	// entries are removed after it, the common entries are never missing
+	for _, key := range d.allowAdded {
+		if err := t.putKey(t.allowedIPMap, domain.EBPFCollectionMapAllowedIP, key, ""); err != nil {
+			return fmt.Errorf("%w: allow ip: %w", ErrMapUpdate, err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapAllowedIP, key)
+		t.addAllowed(next.allowed[key])
+	}
+	for _, key := range d.portAdded {
+		if err := t.putKey(t.portMap, domain.EBPFCollectionMapAllowedPort, key, ""); err != nil {
+			return fmt.Errorf("%w: allow port: %w", ErrMapUpdate, err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapAllowedPort, key)
+		t.addAllowed(next.ports[key])
+	}
+	for _, key := range d.denyAdded {
+		if err := t.putKey(t.denyMap, domain.EBPFCollectionMapDeny, key, ""); err != nil {
+			return fmt.Errorf("%w: deny ip: %w", ErrMapUpdate, err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapDeny, key)
+	}
+	for _, key := range d.hostAdded {
+		if err := t.hostMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: allow host: %w", ErrMapUpdate, err)
+		}
+	}
+	for _, key := range d.rangeAdded {
+		if err := t.rangeMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: allow range: %w", ErrMapUpdate, err)
+		}
+	}
+
+	t.rules.Store(rules)
+	t.static.Store(next)
+
+	for _, key := range d.allowRemoved {
+		if err := t.allowedIPMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow list (map): %v", err)
+		}
+		t.allowed.remove(prev.allowed[key].Address)
+	}
+	for _, key := range d.portRemoved {
+		if err := t.portMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow port list (map): %v", err)
+		}
+		t.allowed.remove(prev.ports[key].Address)
+	}
+	for _, key := range d.denyRemoved {
+		if err := t.denyMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update deny list (map): %v", err)
+		}
+	}
+	// the addresses of the earlier DNS answers stay in the allow list
+	for _, key := range d.hostRemoved {
+		if err := t.hostMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow host list (map): %v", err)
+		}
+	}
+	for _, key := range d.rangeRemoved {
+		if err := t.rangeMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow range list (map): %v", err)
+		}
+	}
+
+	logger.Log.WithFields(
+		logrus.Fields{
+			"allow_added":   addresses(d.allowAdded, next.allowed),
+			"allow_removed": addresses(d.allowRemoved, prev.allowed),
+			"port_added":    addresses(d.portAdded, next.ports),
+			"port_removed":  addresses(d.portRemoved, prev.ports),
+			"deny_added":    d.denyAdded,
+			"deny_removed":  d.denyRemoved,
+			"host_added":    d.hostAdded,
+			"host_removed":  d.hostRemoved,
+			// the ranges of the meta API are counted, they are thousands
+			"range_added":   len(d.rangeAdded),
+			"range_removed": len(d.rangeRemoved),
+		}).Info(message)
+
+	return nil
+}
+
+// keepResolved keeps the earlier addresses of the hosts still allowed: a host
+// resolves to other addresses of its pool over time, the connections to the
+// earlier addresses are not cut, nor on a failed lookup. They are removed
+// with the host.
+func (s *staticKeys) keepResolved(prev *staticKeys) {
+	for key, entry := range prev.allowed {
+		if _, ok := s.allowed[key]; ok || entry.Source != domain.AllowSourceDNS {
+			continue
+		}
+		host, ok := ebpfman.NewHostKey(entry.Rule)
+		if !ok {
+			continue
+		}
+		if _, ok := s.hosts[host]; ok {
+			s.allowed[key] = entry
+		}
+	}
+}
+
+// addresses returns the allow list addresses of the keys
+func addresses[K comparable](keys []K, entries map[K]AllowEntry) []string {
+	var list = make([]string, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, entries[key].Address)
+	}
+
+	return list
+}
+
+// newPolicyWatcher watches the directory of the policy file,
+// the editors and the config management tools replace the file
+func newPolicyWatcher(path string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch policy file: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch policy file: %w", err)
+	}
+
+	return watcher, nil
+}
+
+// watchPolicy reloads the policy when the policy file changes until the context is cancelled
+func (t *Tracer) watchPolicy(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	var path = filepath.Clean(t.opts.PolicyFile)
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || event.Op == fsnotify.Chmod {
+				continue
+			}
+			reload = time.After(policyReloadDelay)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Log.Warnf("policy file watcher: %v", err)
+
+		case <-reload:
+			reload = nil
+			if err := t.Reload(); err != nil {
+				logger.Log.Errorf("%v", err)
+			}
+		}
+	}
+}
+
+// refreshHosts resolves the allowed hosts again on each interval, the new
+// addresses are allowed before the first connection to them
+func (t *Tracer) refreshHosts(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.reconcile("allowed hosts resolved", false); err != nil {
+				logger.Log.Errorf("failed to resolve the allowed hosts: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package tracer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+)
+
+func TestWithPolicyFile(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("allowed-ips: [2.2.2.2]\ndenied-hosts: [pastebin.com]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var allowedIPs = make([]string, 1, 4)
+	allowedIPs[0] = "1.1.1.1"
+
+	opts, err := withPolicyFile(Options{AllowedIPs: allowedIPs, PolicyFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.AllowedIPs) != 2 || opts.AllowedIPs[1] != "2.2.2.2" || opts.DeniedHosts[0] != "pastebin.com" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	// the options of the flags are kept for the next reload
+	if allowedIPs[:2][1] != "" {
+		t.Errorf("the given options are modified: %v", allowedIPs[:2])
+	}
+}
+
+func TestStaticKeysDiff(t *testing.T) {
+	var prev = newStaticKeys(&domain.Data{
+		AllowedIPs:   []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")},
+		AllowedPorts: []domain.PortRule{{Address: "1.1.1.1", Port: 443}},
+		DeniedIPs:    []net.IP{net.ParseIP("6.6.6.6")},
+	})
+	var next = newStaticKeys(&domain.Data{
+		AllowedIPs:     []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("9.9.9.9")},
+		AllowedSources: []AllowEntry{{Address: "9.9.9.9", Source: domain.AllowSourceDNS, Rule: "dns.quad9.net"}},
+		AllowedPorts:   []domain.PortRule{{Port: 53}},
+		DeniedIPs:      []net.IP{net.ParseIP("6.6.6.6"), net.ParseIP("7.7.7.7")},
+	})
+
+	var d = prev.diff(next)
+	if d.empty() {
+		t.Fatal("expected a difference")
+	}
+
+	var key = func(ip string) ebpfman.IPv4Key {
+		k, _ := ebpfman.NewIPv4Key(net.ParseIP(ip))
+		return k
+	}
+
+	if len(d.allowAdded) != 1 || d.allowAdded[0] != key("9.9.9.9") {
+		t.Errorf("unexpected added ips: %v", d.allowAdded)
+	}
+	if len(d.allowRemoved) != 1 || d.allowRemoved[0] != key("8.8.8.8") {
+		t.Errorf("unexpected removed ips: %v", d.allowRemoved)
+	}
+	if got := addresses(d.portAdded, next.ports); len(got) != 1 || got[0] != "*:53" {
+		t.Errorf("unexpected added ports: %v", got)
+	}
+	if got := addresses(d.portRemoved, prev.ports); len(got) != 1 || got[0] != "1.1.1.1:443" {
+		t.Errorf("unexpected removed ports: %v", got)
+	}
+	if len(d.denyAdded) != 1 || d.denyAdded[0] != key("7.7.7.7") || len(d.denyRemoved) != 0 {
+		t.Errorf("unexpected deny diff: %v %v", d.denyAdded, d.denyRemoved)
+	}
+
+	// the provenance of the resolved hosts is kept
+	if entry := next.allowed[key("9.9.9.9")]; entry.Source != domain.AllowSourceDNS {
+		t.Errorf("unexpected provenance: %+v", entry)
+	}
+	if entry := next.allowed[key("1.1.1.1")]; entry.Source != domain.AllowSourceStatic {
+		t.Errorf("unexpected provenance: %+v", entry)
+	}
+
+	if d = next.diff(next); !d.empty() {
+		t.Errorf("expected no difference, got %+v", d)
+	}
+}
+
+func TestStaticKeys_KeepResolved(t *testing.T) {
+	var key = func(ip string) ebpfman.IPv4Key {
+		k, _ := ebpfman.NewIPv4Key(net.ParseIP(ip))
+		return k
+	}
+
+	var prev = newStaticKeys(&domain.Data{
+		AllowedIPs:   []net.IP{net.ParseIP("140.82.114.22"), net.ParseIP("104.16.0.1"), net.ParseIP("1.1.1.1")},
+		AllowedHosts: []string{".github.com", "registry.npmjs.org"},
+		AllowedSources: []AllowEntry{
+			{Address: "140.82.114.22", Source: domain.AllowSourceDNS, Rule: ".github.com"},
+			{Address: "104.16.0.1", Source: domain.AllowSourceDNS, Rule: "registry.npmjs.org"},
+		},
+	})
+	// github.com rotated to another address, the npm registry is removed
+	var next = newStaticKeys(&domain.Data{
+		AllowedIPs:     []net.IP{net.ParseIP("140.82.112.3")},
+		AllowedHosts:   []string{".github.com"},
+		AllowedSources: []AllowEntry{{Address: "140.82.112.3", Source: domain.AllowSourceDNS, Rule: ".github.com"}},
+	})
+	next.keepResolved(prev)
+
+	if _, ok := next.allowed[key("140.82.114.22")]; !ok {
+		t.Error("expected the earlier address of github.com to be kept")
+	}
+	if _, ok := next.allowed[key("140.82.112.3")]; !ok {
+		t.Error("expected the new address of github.com")
+	}
+	if _, ok := next.allowed[key("104.16.0.1")]; ok {
+		t.Error("expected the address of the removed host to be dropped")
+	}
+	if _, ok := next.allowed[key("1.1.1.1")]; ok {
+		t.Error("expected the removed static address to be dropped")
+	}
+
+	var d = prev.diff(next)
+	if len(d.allowAdded) != 1 || len(d.allowRemoved) != 2 {
+		t.Errorf("unexpected diff: %+v", d)
+	}
+}
+
+func TestStaticKeysDiff_Hosts(t *testing.T) {
+	var prev = newStaticKeys(&domain.Data{AllowedHosts: []string{".github.com", "registry.npmjs.org"}})
+	var next = newStaticKeys(&domain.Data{AllowedHosts: []string{"github.com", "pypi.org", "10.0.0.1"}})
+
+	var d = prev.diff(next)
+	if len(d.hostAdded) != 1 || d.hostAdded[0].String() != "pypi.org" {
+		t.Errorf("unexpected added hosts: %v", d.hostAdded)
+	}
+	if len(d.hostRemoved) != 1 || d.hostRemoved[0].String() != "registry.npmjs.org" {
+		t.Errorf("unexpected removed hosts: %v", d.hostRemoved)
+	}
+}
+
+func TestStaticKeysDiff_Ranges(t *testing.T) {
+	var prev = newStaticKeys(&domain.Data{GithubMetaRanges: []string{"4.148.0.0/16", "140.82.112.0/20", "2a0a:a440::/29"}})
+	var next = newStaticKeys(&domain.Data{GithubMetaRanges: []string{"4.148.0.0/16", "192.30.252.0/22", "invalid"}})
+
+	// the IPv6 ranges are not in the kernel map
+	if len(prev.ranges) != 2 {
+		t.Errorf("unexpected ranges: %v", prev.ranges)
+	}
+
+	var d = prev.diff(next)
+	if len(d.rangeAdded) != 1 || d.rangeAdded[0].String() != "192.30.252.0/22" {
+		t.Errorf("unexpected added ranges: %v", d.rangeAdded)
+	}
+	if len(d.rangeRemoved) != 1 || d.rangeRemoved[0].String() != "140.82.112.0/20" {
+		t.Errorf("unexpected removed ranges: %v", d.rangeRemoved)
+	}
+}
+
+func TestWithPolicyFile_Hosts(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("hosts: [1.1.1.1, .github.com, \"2001:db8::1\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := withPolicyFile(Options{PolicyFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.AllowedHosts) != 1 || opts.AllowedHosts[0] != ".github.com" {
+		t.Errorf("unexpected allowed hosts: %v", opts.AllowedHosts)
+	}
+	if len(opts.AllowedIPs) != 2 || opts.AllowedIPs[0] != "1.1.1.1" || opts.AllowedIPs[1] != "2001:db8::1" {
+		t.Errorf("unexpected allowed ips: %v", opts.AllowedIPs)
+	}
+}
+
+func TestRuntimeRules(t *testing.T) {
+	var r runtimeRules
+
+	if added := r.add(&r.deniedHosts, []string{"pastebin.com", "pastebin.com"}); len(added) != 1 {
+		t.Errorf("unexpected added hosts: %v", added)
+	}
+	if added := r.add(&r.deniedHosts, []string{"pastebin.com"}); len(added) != 0 {
+		t.Errorf("a denied host is added again: %v", added)
+	}
+	r.add(&r.allowedHosts, []string{"registry.npmjs.org"})
+
+	var allowedHosts = make([]string, 1, 4)
+	allowedHosts[0] = "github.com"
+	opts := r.apply(Options{AllowedHosts: allowedHosts})
+	if len(opts.AllowedHosts) != 2 || opts.AllowedHosts[1] != "registry.npmjs.org" || opts.DeniedHosts[0] != "pastebin.com" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if allowedHosts[:2][1] != "" {
+		t.Errorf("the given options are modified: %v", allowedHosts[:2])
+	}
+
+	if !r.remove(&r.allowedHosts, []string{"registry.npmjs.org"}) || r.remove(&r.allowedHosts, []string{"registry.npmjs.org"}) {
+		t.Errorf("unexpected removal: %v", r.allowedHosts)
+	}
+}
+
+func TestHostName(t *testing.T) {
+	var tests = map[string]struct {
+		address string
+		want    string
+		ok      bool
+	}{
+		"host":       {address: " Example.COM", want: "example.com", ok: true},
+		"subdomains": {address: ".github.com", want: ".github.com", ok: true},
+		"ip":         {address: "1.2.3.4"},
+		"port":       {address: "example.com:443"},
+		"any port":   {address: "*:443"},
+		"space":      {address: "example com"},
+		"empty":      {address: ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := hostName(tt.address)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("hostName(%q) = %q, %v, want %q, %v", tt.address, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,853 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/sirupsen/logrus"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/metrics"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+	"github.com/kondukto-io/kntrl/pkg/parser"
+	"github.com/kondukto-io/kntrl/pkg/policy"
+	"github.com/kondukto-io/kntrl/pkg/process"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+	"github.com/kondukto-io/kntrl/pkg/sni"
+	"github.com/kondukto-io/kntrl/pkg/utils"
+)
+
+// Start attaches the programs and traces the connections until the context is cancelled.
+// It doesn't block, use Wait to wait until the tracer stops and the report is written.
+func (t *Tracer) Start(ctx context.Context) error {
+	t.mu.Lock()
+	if t.started {
+		t.mu.Unlock()
+		return errors.New("tracer is already started")
+	}
+	t.started = true
+	t.mu.Unlock()
+
+	ipV4Events, err := t.newReader(domain.EBPFCollectionMapIPV4Events)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	closedEvents, err := t.newReader(domain.EBPFCollectionMapIPV4ClosedEvents)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	execEvents, err := t.newReader(domain.EBPFCollectionMapExecEvents)
+	if err != nil {
+		return t.fail(err)
+	}
+
+	go readExecEvents(execEvents, t.execCache, t.opts.TraceContext)
+
+	listenEvents, err := t.newReader(domain.EBPFCollectionMapListenEvents)
+	if err != nil {
+		return t.fail(err)
+	}
+	go t.readListenEvents(listenEvents)
+
+	dnsEvents, err := t.newReader(domain.EBPFCollectionMapDNSEvents)
+	if err != nil {
+		return t.fail(err)
+	}
+	go t.readDNSEvents(dnsEvents)
+
+	if t.fileAccess != nil {
+		fileEvents, err := t.newReader(domain.EBPFCollectionMapFileEvents)
+		if err != nil {
+			return t.fail(err)
+		}
+		go t.readFileEvents(fileEvents)
+	}
+
+	if t.captures != nil {
+		captureEvents, err := t.newReaderSize(domain.EBPFCollectionMapCaptureEvents, captureBufferSize)
+		if err != nil {
+			return t.fail(err)
+		}
+		go t.readCaptureEvents(captureEvents)
+	}
+	go t.readCloseEvents(closedEvents)
+
+	if err := t.attach(); err != nil {
+		return t.fail(fmt.Errorf("%w: %w", ErrAttach, err))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// stop reading the events when the context is cancelled
+	go func() {
+		<-ctx.Done()
+
+		if err := ipV4Events.Close(); err != nil {
+			logger.Log.Warnf("closing perf reader: %s", err)
+		}
+	}()
+
+	if t.opts.MetricsAddr != "" {
+		metrics.Serve(ctx, t.opts.MetricsAddr)
+	}
+
+	if t.opts.MapStatsInterval > 0 {
+		go t.sampleMaps(ctx, t.opts.MapStatsInterval)
+	}
+
+	if t.opts.ControlSocket != "" {
+		if err := control.Serve(ctx, t.opts.ControlSocket, t); err != nil {
+			cancel()
+			return t.fail(err)
+		}
+	}
+
+	if t.opts.ControlRemote != nil {
+		if err := control.ServeRemote(ctx, *t.opts.ControlRemote, t); err != nil {
+			cancel()
+			return t.fail(err)
+		}
+	}
+
+	if t.certs != nil {
+		t.certs.start(t.report.Now)
+	}
+
+	if t.opts.EscalateFindings > 0 {
+		go t.watchEscalation(ctx)
+	}
+
+	if t.serverNames != nil {
+		var ports []uint16
+		if t.opts.SNI {
+			ports = append(ports, sni.TLSPort)
+		}
+		if t.opts.HTTPHost {
+			ports = append(ports, sni.HTTPPort)
+		}
+
+		sniffer, err := sni.Listen(ports...)
+		if err != nil {
+			cancel()
+			return t.fail(err)
+		}
+		go t.sampleServerNames(ctx, sniffer)
+	}
+
+	go t.watchSandboxes(ctx)
+
+	if t.jobs != nil {
+		go t.jobs.watch(ctx)
+	}
+
+	if t.opts.PolicyFile != "" && !t.opts.Passive {
+		watcher, err := newPolicyWatcher(t.opts.PolicyFile)
+		if err != nil {
+			cancel()
+			return t.fail(err)
+		}
+		go t.watchPolicy(ctx, watcher)
+	}
+
+	if t.opts.ResolveInterval > 0 && !t.opts.Passive {
+		go t.refreshHosts(ctx, t.opts.ResolveInterval)
+	}
+
+	if t.opts.GithubMetaInterval > 0 && !t.opts.Passive {
+		go t.refreshGithubMeta(ctx, t.opts.GithubMetaInterval)
+	}
+
+	var stopSession = make(chan struct{})
+	if t.tracker != nil {
+		go t.tracker.Run(t.opts.StateInterval, t.report.Events, stopSession)
+	}
+
+	go func() {
+		defer close(t.done)
+		defer cancel()
+
+		t.run(ctx, ipV4Events)
+		// the events waiting for a lookup are handled before the report is written
+		t.pending.Wait()
+
+		if t.tracker != nil {
+			close(stopSession)
+			if err := t.tracker.Save(t.report.Events()); err != nil {
+				logger.Log.Errorf("failed to save session state: %v", err)
+			}
+		}
+
+		if t.recordTrust {
+			if err := t.trustStore.Save(); err != nil {
+				logger.Log.Errorf("failed to save trust store: %v", err)
+			}
+		}
+
+		if t.ruleHistory != nil {
+			t.updateRuleHistory()
+		}
+
+		if t.certs != nil {
+			t.certs.stop(t.report.Now())
+		}
+
+		if t.verdictCache != nil {
+			t.exportVerdictCache()
+		}
+
+		if err := t.report.Flush(); err != nil {
+			t.setErr(err)
+		}
+
+		if len(t.sinks) > 0 {
+			var report = t.report.Report()
+			if t.hasher != nil {
+				report = t.hasher.Report(report)
+			}
+			for _, sink := range t.sinks {
+				if err := sink.Flush(report); err != nil {
+					logger.Log.Errorf("%v", err)
+				}
+			}
+		}
+
+		if t.stored != nil {
+			if err := t.stored.Finish(t.report.Report()); err != nil {
+				logger.Log.Errorf("%v", err)
+			} else {
+				logger.Log.Infof("report of the run [%s] is kept in %s", t.stored.ID, t.opts.ReportStore)
+			}
+		}
+
+		// the context is done, the upload has its own deadline
+		if t.opts.Upload != nil {
+			var report = t.report.Report()
+			if t.hasher != nil {
+				report = t.hasher.Report(report)
+			}
+			if err := reporter.Upload(context.Background(), *t.opts.Upload, report); err != nil {
+				logger.Log.Errorf("%v", err)
+			}
+		}
+
+		if t.opts.GitHubComment != nil {
+			if err := reporter.CommentPR(context.Background(), *t.opts.GitHubComment, t.report.Report()); err != nil {
+				logger.Log.Errorf("%v", err)
+			}
+		}
+
+		close(t.events)
+		t.subscribers.close()
+		t.close()
+	}()
+
+	return nil
+}
+
+// run reads the connection events until the perf reader is closed
+func (t *Tracer) run(ctx context.Context, ipV4Events *perf.Reader) {
+	for {
+		record, err := ipV4Events.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read perf event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			logger.Log.Warnf("perf buffer is full, %d event(s) lost", record.LostSamples)
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapIPV4Events, record.LostSamples)
+			t.lost.Add(record.LostSamples)
+			continue
+		}
+
+		var event domain.IP4Event
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Printf("failed to parse perf event: %b", err)
+			continue
+		}
+
+		// kntrl's own egress (sinks, DNS lookups) is excluded before the
+		// lookup, otherwise every lookup would generate a new event
+		isSelf := t.self.Is(event.Pid, t.execCache)
+		if isSelf && !t.opts.ReportSelf {
+			continue
+		}
+
+		// the probes are host-wide, the events out of the enforcement scope are dropped
+		if !t.inScope(event.Pid) {
+			continue
+		}
+
+		// the close event may be read while the event is handled
+		if event.Cookie != 0 {
+			t.conns.open(event.Cookie, event.TsUs)
+		}
+
+		t.dispatch(ctx, event, isSelf, "")
+	}
+}
+
+// dispatch handles the event once the destination is resolved by the
+// enrichment pipeline, the reader is never blocked by a lookup. The events of
+// a destination are handled in order.
+func (t *Tracer) dispatch(ctx context.Context, event domain.IP4Event, isSelf bool, sandboxRuntime string) {
+	var seenAt = t.report.Now()
+	var daddr = ebpfman.IPv4Key(event.Daddr)
+
+	t.pending.Add(1)
+	t.pipeline.Prefetch(daddr.IP().String(), func() {
+		defer t.pending.Done()
+
+		t.handleEvent(ctx, event, isSelf, sandboxRuntime, seenAt)
+		t.handled.Add(1)
+	})
+}
+
+// handleEvent evaluates the policy of the connection event and reports it.
+// The events of the sandboxes observed at their network boundary are not
+// enforced, sandboxRuntime is empty for the events of the probes. seenAt is
+// the time the event was read, the timestamp of the report event.
+func (t *Tracer) handleEvent(ctx context.Context, event domain.IP4Event, isSelf bool, sandboxRuntime string, seenAt time.Time) {
+	// the events of the probes and of the sandbox observers are handled one by one
+	t.eventMu.Lock()
+	defer t.eventMu.Unlock()
+
+	var daddr = ebpfman.IPv4Key(event.Daddr)
+	domainAddress := daddr.IP()
+
+	// evaluate policy
+	var policyStatus = domain.EventPolicyStatusPass
+	taskname := utils.TrimNullBytes(event.Task)
+
+	protocol := utils.GetProtocol(event.Proto)
+
+	var reportEvent = domain.ReportEvent{
+		ProcessID:          event.Pid,
+		TaskName:           taskname,
+		Protocol:           protocol,
+		DestinationAddress: domainAddress.String(),
+		DestinationPort:    event.Dport,
+		Policy:             policyStatus,
+		Timestamp:          seenAt,
+		Self:               isSelf,
+		Cookie:             event.Cookie,
+		Sandbox:            sandboxRuntime,
+		Killed:             event.Killed != 0,
+	}
+
+	if reportEvent.Killed {
+		logger.Log.Warnf("killed [%d]%s: connection to %s:%d", event.Pid, taskname, domainAddress, event.Dport)
+	}
+
+	if info, ok := t.execCache.Lookup(event.Pid); ok {
+		reportEvent.Executable = info.Path
+		reportEvent.Args = info.Args
+	}
+
+	// the user and the namespaces of the process, the observed connections of
+	// a sandbox have the ones of the sandbox runtime
+	if reportEvent.Sandbox == "" {
+		reportEvent.Identity = &domain.ProcessIdentity{
+			UID:      event.Uid,
+			GID:      event.Gid,
+			User:     t.users.Name(event.Uid),
+			CgroupID: event.Cgroup,
+			NetNS:    event.NetNS,
+		}
+	}
+
+	// the parents show the build step or the test runner of the connection,
+	// the sandbox runtime of the observed connections has no parent of interest
+	if reportEvent.Sandbox == "" {
+		for _, parent := range t.execCache.Ancestors(event.Pid, event.Ppid) {
+			reportEvent.Parents = append(reportEvent.Parents, domain.ProcessAncestor{
+				ProcessID:  parent.Pid,
+				TaskName:   parent.Comm,
+				Executable: parent.Path,
+				Args:       parent.Args,
+			})
+		}
+	}
+
+	// the connection to a NAT64 address is reported as the connection to the
+	// embedded IPv4 address, the policy rules of the IPv4 address match
+	if event.Af == syscall.AF_INET6 && t.nat64.IsValid() {
+		reportEvent.NAT64 = nat64.Embed(t.nat64, netip.AddrFrom4(event.Daddr)).String()
+	}
+
+	// the observed connections of a sandbox have the pid of the sandbox runtime
+	if t.opts.TraceContext && reportEvent.Sandbox == "" {
+		if tc, ok := process.TraceContextOf(event.Pid, t.execCache); ok {
+			reportEvent.TraceID = tc.TraceID
+			reportEvent.SpanID = tc.SpanID
+		}
+	}
+
+	// the server name of an earlier TLS connection (or the host of an earlier
+	// HTTP request) to the destination, the reverse lookup of the CDN addresses often fails
+	if t.serverNames != nil {
+		if name, ok := t.serverNames.Lookup(reportEvent.DestinationAddress, reportEvent.DestinationPort); ok {
+			reportEvent.ServerName = name
+			reportEvent.Domains = append(reportEvent.Domains, name)
+		}
+	}
+
+	// the domain names are resolved by the rdns stage
+	t.pipeline.Run(ctx, &reportEvent)
+
+	// the access to the instance credentials is reported whatever the stages
+	if parser.IsMetadataEndpoint(reportEvent.DestinationAddress) {
+		reportEvent.Category = domain.DestinationCategoryMetadata
+	}
+
+	// the excluded services are passed by the kernel
+	reportEvent.Excluded = t.exclusions.match(event.Pid, taskname)
+
+	// the verdict of the kernel at the connect call, updated by the decision
+	// of the event below
+	var verdict = kernelVerdict(event.Verdict)
+
+	// policy logic, the rules are swapped on reload
+	var rules = t.rules.Load()
+	switch {
+	case t.opts.Passive || reportEvent.Excluded || reportEvent.Sandbox != "":
+		// nothing is enforced, the event is reported as observed. The deny
+		// list is counted in the passive mode, as a would block
+		verdict = domain.EventVerdictAllowed
+		reportEvent.Denied = t.simulates(reportEvent) && t.isDenied(ctx, rules.denyPolicy, reportEvent)
+	case t.isDenied(ctx, rules.denyPolicy, reportEvent):
+		policyStatus = domain.EventPolicyStatusBlock
+		reportEvent.Policy = policyStatus
+		reportEvent.Denied = true
+		verdict = domain.EventVerdictBlocked
+		t.denyAddr(daddr)
+	case t.recordTrust:
+		t.trustStore.Record(reportEvent)
+	case t.enforcing():
+		var start = time.Now()
+		result, err := rules.policy.EvalEvent(ctx, reportEvent)
+		t.watchdog.Observe("policy", time.Since(start))
+		if err != nil {
+			logger.Log.Debugf("policy eval failed: %v", err)
+		}
+		if !result && t.trustStore != nil {
+			result = t.trustStore.Trusted(reportEvent)
+		}
+		if result {
+			policyStatus = domain.EventPolicyStatusPass
+			verdict = domain.EventVerdictAllowed
+			t.allowAddr(daddr, event.Dport, reportEvent.DestinationAddress, domain.AllowSourcePolicy, strings.Join(reportEvent.Domains, ","))
+			if t.verdictCache != nil {
+				t.verdictCache.Record(reportEvent, seenAt)
+			}
+		} else if event.Verdict == domain.KernelVerdictAllowed {
+			// the process rules are enforced in userspace only, the address is
+			// allowed for all processes in the kernel: the connection went out
+			policyStatus = domain.EventPolicyStatusPass
+			reportEvent.WouldBlock = true
+		} else if t.opts.BlockAction == BlockActionTarpit {
+			policyStatus = domain.EventPolicyStatusTarpit
+			verdict = domain.EventVerdictDelayed
+			t.tarpitAddr(daddr)
+		} else {
+			policyStatus = domain.EventPolicyStatusBlock
+		}
+		reportEvent.Policy = policyStatus
+	}
+	reportEvent.Verdict = verdict
+
+	// the policy of the trace mode is evaluated for a preview of the enforcement
+	if t.simulates(reportEvent) && reportEvent.Policy != domain.EventPolicyStatusBlock {
+		reportEvent.WouldBlock = t.wouldBlock(ctx, rules, reportEvent)
+	}
+
+	// the connections over the anomaly thresholds, the block action denies
+	// the destination as the deny list
+	if t.anomalies != nil && !reportEvent.Self && !reportEvent.Excluded && reportEvent.Sandbox == "" {
+		if kind, detail := t.anomalies.observe(reportEvent); kind != "" {
+			reportEvent.Anomaly = kind
+			if t.opts.AnomalyAction == AnomalyActionBlock && !t.opts.Passive && reportEvent.Policy != domain.EventPolicyStatusBlock {
+				policyStatus = t.applyVerdict(VerdictDeny, daddr, reportEvent)
+				reportEvent.Policy = policyStatus
+				reportEvent.Verdict = domain.EventVerdictBlocked
+			}
+			t.anomalies.report(reportEvent, kind, detail)
+			metrics.ObserveAnomaly(kind)
+		}
+	}
+
+	// the embedding application may override the decision
+	if t.opts.VerdictFunc != nil && !reportEvent.Excluded && reportEvent.Sandbox == "" {
+		var start = time.Now()
+		v := t.opts.VerdictFunc(reportEvent)
+		t.watchdog.Observe("verdict", time.Since(start))
+
+		policyStatus = t.applyVerdict(v, daddr, reportEvent)
+		reportEvent.Policy = policyStatus
+		if !t.opts.Passive {
+			switch v {
+			case VerdictAllow:
+				reportEvent.Verdict = domain.EventVerdictAllowed
+			case VerdictDeny:
+				reportEvent.Verdict = domain.EventVerdictBlocked
+			}
+		}
+	}
+
+	if !t.opts.Passive && !reportEvent.Excluded && reportEvent.Sandbox == "" {
+		t.countHits(ctx, rules, reportEvent)
+	}
+
+	// the packets of the violating flows are captured by the egress program
+	if t.captures != nil && !reportEvent.Self && !reportEvent.Excluded && reportEvent.Sandbox == "" &&
+		(reportEvent.Policy == domain.EventPolicyStatusBlock || reportEvent.Policy == domain.EventPolicyStatusTarpit || reportEvent.WouldBlock) {
+		reportEvent.Capture = t.captureFlow(daddr, event.Dport)
+	}
+
+	// the certificates of the allowed TLS destinations, probed by kntrl
+	if t.certs != nil && certProbed(reportEvent) {
+		t.certs.add(reportEvent)
+	}
+
+	// the connection of a process after its read of a credential file
+	if t.fileAccess != nil && reportEvent.Sandbox == "" {
+		reportEvent.CredentialReads = t.fileAccess.correlate(reportEvent)
+	}
+
+	// the filtered events are enforced, they are not reported
+	if t.filter.match(reportEvent) {
+		if reportEvent.Cookie != 0 {
+			t.conns.forget(reportEvent.Cookie)
+		}
+		logger.Log.Debugf("[%d]%s -> %s:%d filtered", event.Pid, taskname, domainAddress, event.Dport)
+		return
+	}
+
+	// the outputs sent out of the runner get the hashes of the destinations
+	var outEvent = reportEvent
+	if t.hasher != nil {
+		outEvent = t.hasher.Event(reportEvent)
+	}
+
+	if len(t.webhooks) > 0 && !reportEvent.Excluded {
+		if verdict, ok := t.violation(ctx, rules, reportEvent); ok {
+			for _, webhook := range t.webhooks {
+				webhook.Notify(outEvent, verdict)
+			}
+		}
+	}
+
+	// report
+	var start = time.Now()
+	t.report.WriteEvent(reportEvent)
+	t.watchdog.Observe("sink/report", time.Since(start))
+
+	if t.stored != nil {
+		var start = time.Now()
+		if err := t.stored.Write(reportEvent); err != nil {
+			logger.Log.Errorf("%v", err)
+		}
+		t.watchdog.Observe("sink/"+t.stored.Name(), time.Since(start))
+	}
+
+	for _, sink := range t.sinks {
+		var start = time.Now()
+		if err := sink.Write(outEvent); err != nil {
+			logger.Log.Errorf("%v", err)
+		}
+		t.watchdog.Observe("sink/"+sink.Name(), time.Since(start))
+	}
+
+	if reportEvent.Cookie != 0 {
+		if openedUs, closed := t.conns.reported(reportEvent.Cookie); closed != nil {
+			t.closeEvent(openedUs, *closed)
+		}
+	}
+	metrics.ObserveEvent(outEvent)
+
+	select {
+	case t.events <- reportEvent:
+	default:
+	}
+	t.subscribers.publish(reportEvent)
+
+	logger.Log.Infof("[%d]%s -> %s:%d (%s) [%s]| %s %s",
+		event.Pid,
+		taskname,
+		domainAddress,
+		event.Dport,
+		reportEvent.Domains,
+		protocol,
+		reportEvent.Verdict,
+		policyStatus,
+	)
+}
+
+// violation returns the violation verdict of the event: the blocked
+// connections and the connections out of the policy that are not enforced
+// (monitor mode, sandboxes, the process rules of the addresses allowed in the
+// kernel). Nothing is unexpected while the TOFU mode records.
+func (t *Tracer) violation(ctx context.Context, rules *ruleset, event domain.ReportEvent) (string, bool) {
+	if event.Policy == domain.EventPolicyStatusBlock {
+		return reporter.ViolationBlock, true
+	}
+	if event.Policy == domain.EventPolicyStatusTarpit {
+		return reporter.ViolationTarpit, true
+	}
+	if t.simulates(event) || event.WouldBlock {
+		return reporter.ViolationUnexpected, event.WouldBlock
+	}
+	if t.recordTrust || (t.enforcing() && event.Sandbox == "") {
+		return "", false
+	}
+
+	var start = time.Now()
+	result, err := rules.policy.EvalEvent(ctx, event)
+	t.watchdog.Observe("policy", time.Since(start))
+	if err != nil {
+		logger.Log.Debugf("policy eval failed: %v", err)
+		return "", false
+	}
+	if !result && t.trustStore != nil {
+		result = t.trustStore.Trusted(event)
+	}
+
+	return reporter.ViolationUnexpected, !result
+}
+
+// simulates returns true if the policy is not enforced for the event in the
+// monitor modes, the TOFU recording excepted: the trace mode would enforce it
+func (t *Tracer) simulates(event domain.ReportEvent) bool {
+	return (t.opts.Passive || !t.enforcing()) && !t.recordTrust && !event.Excluded && event.Sandbox == ""
+}
+
+// wouldBlock returns true if the event would be blocked in the trace mode, the
+// deny list is evaluated in the passive mode only, it's enforced otherwise
+func (t *Tracer) wouldBlock(ctx context.Context, rules *ruleset, event domain.ReportEvent) bool {
+	if event.Denied {
+		return true
+	}
+
+	var start = time.Now()
+	result, err := rules.policy.EvalEvent(ctx, event)
+	t.watchdog.Observe("policy", time.Since(start))
+	if err != nil {
+		logger.Log.Debugf("policy eval failed: %v", err)
+	}
+	if !result && t.trustStore != nil {
+		result = t.trustStore.Trusted(event)
+	}
+
+	return !result
+}
+
+// isDenied returns true if the destination is in the deny list
+func (t *Tracer) isDenied(ctx context.Context, denyPolicy *policy.Policy, event domain.ReportEvent) bool {
+	if denyPolicy == nil {
+		return false
+	}
+
+	var start = time.Now()
+	denied, err := denyPolicy.EvalEvent(ctx, event)
+	t.watchdog.Observe("policy/deny", time.Since(start))
+	if err != nil {
+		logger.Log.Debugf("deny policy eval failed: %v", err)
+	}
+
+	return denied
+}
+
+// attach links the loaded programs
+func (t *Tracer) attach() error {
+	if t.opts.Pin {
+		if err := t.pinMaps(); err != nil {
+			return err
+		}
+	}
+
+	for name, spec := range t.ebpfClient.Spec.Programs {
+		prg := t.ebpfClient.Collection.Programs[name]
+		logger.Log.WithFields(
+			logrus.Fields{
+				"name":    name,
+				"program": prg,
+			}).Debug("loaded program(s):")
+
+		switch spec.Type {
+		case ebpf.Kprobe:
+			// link Krobe
+			logger.Log.Infof("linking Kprobe [%s]", utils.ParseProgramName(prg))
+			l, err := link.Kprobe(spec.AttachTo, prg, nil)
+			if err != nil {
+				return err
+			}
+			t.links = append(t.links, l)
+			t.addAttached(name, spec.Type, spec.AttachTo)
+
+		case ebpf.Tracing:
+			logger.Log.Infof("linking tracing [%s]", utils.ParseProgramName(prg))
+			l, err := link.AttachTracing(link.TracingOptions{
+				Program: prg,
+			})
+			if err != nil {
+				return err
+			}
+			t.links = append(t.links, l)
+			t.addAttached(name, spec.Type, spec.AttachTo)
+
+		case ebpf.TracePoint:
+			logger.Log.Infof("linking tracepoint [%s]", utils.ParseProgramName(prg))
+			group, tp, err := utils.ParseTracepoint(spec.SectionName)
+			if err != nil {
+				return err
+			}
+			l, err := link.Tracepoint(group, tp, prg, nil)
+			if err != nil {
+				return err
+			}
+			t.links = append(t.links, l)
+			t.addAttached(name, spec.Type, group+"/"+tp)
+
+		case ebpf.CGroupSKB:
+			// the programs are attached to the job containers as they are found
+			if t.jobs != nil {
+				t.jobs.programs = append(t.jobs.programs, prg)
+				t.addAttached(name, spec.Type, "job containers")
+				continue
+			}
+
+			logger.Log.Infof("linking CGroupSKB [%s] to the cgroup [%s]", utils.ParseProgramName(prg), t.scope)
+			if t.opts.Pin {
+				l, err := attachPinned(name, prg, filepath.Join(rootCgroup, t.scope))
+				if err != nil {
+					return err
+				}
+				t.links = append(t.links, l)
+				t.addAttached(name, spec.Type, t.scope)
+				continue
+			}
+
+			cgroup, err := os.Open(filepath.Join(rootCgroup, t.scope))
+			if err != nil {
+				return err
+			}
+			l, err := link.AttachCgroup(link.CgroupOptions{
+				Path:    cgroup.Name(),
+				Attach:  ebpf.AttachCGroupInetEgress,
+				Program: prg,
+			})
+			cgroup.Close()
+			if err != nil {
+				return err
+			}
+			t.links = append(t.links, l)
+			t.addAttached(name, spec.Type, t.scope)
+
+		default:
+			logger.Log.Warnf("ebpf program unrecognized: %v", prg)
+		}
+	}
+
+	return nil
+}
+
+// newReader opens a perf reader of the given map
+func (t *Tracer) newReader(mapName string) (*perf.Reader, error) {
+	return t.newReaderSize(mapName, 4096)
+}
+
+// newReaderSize opens a perf reader of the given map with the per-CPU buffer size
+func (t *Tracer) newReaderSize(mapName string, size int) (*perf.Reader, error) {
+	rd, err := perf.NewReader(t.ebpfClient.Collection.Maps[mapName], size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", mapName, err)
+	}
+	t.readers = append(t.readers, rd)
+
+	return rd, nil
+}
+
+// fail releases the resources of a tracer that failed to start
+func (t *Tracer) fail(err error) error {
+	t.close()
+	t.setErr(err)
+	close(t.events)
+	t.subscribers.close()
+	close(t.done)
+
+	return err
+}
+
+func (t *Tracer) setErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// readExecEvents fills the exec cache until the perf reader is closed, the
+// trace context is read on the exec as the short lived processes are gone
+// before their connections are handled
+func readExecEvents(rd *perf.Reader, cache *process.Cache, traceContext bool) {
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			logger.Log.Errorf("failed to read exec event: %v", err)
+			continue
+		}
+
+		if record.LostSamples > 0 {
+			metrics.ObserveLostSamples(domain.EBPFCollectionMapExecEvents, record.LostSamples)
+			continue
+		}
+
+		var event domain.ExecEvent
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			logger.Log.Debugf("failed to parse exec event: %v", err)
+			continue
+		}
+
+		var info = process.Info{
+			Pid:  event.Pid,
+			PPid: event.Ppid,
+			Comm: utils.TrimNullBytes(event.Task),
+			Path: process.CString(event.Path[:]),
+			Args: process.SplitArgs(event.Args[:]),
+		}
+		// the process is running on the exec, its executable is resolved
+		if !filepath.IsAbs(info.Path) {
+			if exe, err := process.ExePath(event.Pid); err == nil {
+				info.Path = exe
+			}
+		}
+		if traceContext {
+			info.TraceParent, _ = process.TraceParentOf(event.Pid)
+		}
+		cache.Add(info)
+	}
+}
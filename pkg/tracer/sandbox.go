@@ -0,0 +1,130 @@
+package tracer
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/sandbox"
+)
+
+// sandboxInterval is the interval of the detection of the sandboxed runtimes
+const sandboxInterval = 10 * time.Second
+
+const (
+	// sandboxObserved is the diagnostic action of an observed sandbox
+	sandboxObserved = "observe"
+	// sandboxNotObserved is the diagnostic action of a sandbox without observation
+	sandboxNotObserved = "none"
+)
+
+// sandboxGaps are the reasons of the visibility gaps of the sandbox runtimes
+var sandboxGaps = map[string]string{
+	sandbox.RuntimeGVisor: "handles the syscalls of its processes in the gVisor kernel",
+	sandbox.RuntimeKata:   "runs its processes on the guest kernel of the VM",
+}
+
+// watchSandboxes detects the sandboxed runtimes (gVisor, Kata) in the scope
+// until the context is done. The host probes and the cgroup programs don't
+// see the connections of their processes, each sandbox is reported as a
+// diagnostic and observed at its network boundary with Options.SandboxObserve.
+func (t *Tracer) watchSandboxes(ctx context.Context) {
+	var observed = make(map[uint64]context.CancelFunc)
+	defer func() {
+		for _, cancel := range observed {
+			cancel()
+		}
+	}()
+
+	var ticker = time.NewTicker(sandboxInterval)
+	defer ticker.Stop()
+
+	for {
+		t.detectSandboxes(ctx, observed)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// detectSandboxes reports the new sandboxes and stops the observation of the
+// exited ones, the sandboxes are kept by their network namespace
+func (t *Tracer) detectSandboxes(ctx context.Context, observed map[uint64]context.CancelFunc) {
+	sandboxes, err := sandbox.Detect()
+	if err != nil {
+		logger.Log.Debugf("sandbox detection failed: %v", err)
+		return
+	}
+
+	var running = make(map[uint64]bool, len(sandboxes))
+	for _, s := range sandboxes {
+		running[s.Netns] = true
+		if _, ok := observed[s.Netns]; ok || !t.inScope(s.Pid) {
+			continue
+		}
+
+		var action = sandboxNotObserved
+		// the observation is stopped when the sandbox exits, the socket keeps the namespace
+		sandboxCtx, cancel := context.WithCancel(ctx)
+		observed[s.Netns] = cancel
+
+		if t.opts.SandboxObserve {
+			observer, err := sandbox.Observe(s.Pid)
+			if err != nil {
+				logger.Log.Errorf("failed to observe the %s sandbox [%s:%d]: %v", s.Runtime, s.Comm, s.Pid, err)
+			} else {
+				action = sandboxObserved
+				go t.observeSandbox(sandboxCtx, s, observer)
+			}
+		}
+		t.watchdog.Sandbox(s.Runtime, s.Pid, action)
+
+		if action == sandboxObserved {
+			logger.Log.Warnf("the %s sandbox [%s:%d] %s, the host probes don't see its connections: they are observed at the network boundary of the sandbox and reported without enforcement",
+				s.Runtime, s.Comm, s.Pid, sandboxGaps[s.Runtime])
+			continue
+		}
+		logger.Log.Warnf("the %s sandbox [%s:%d] %s, the host probes don't see its connections: they are neither reported nor enforced (see --sandbox-observe)",
+			s.Runtime, s.Comm, s.Pid, sandboxGaps[s.Runtime])
+	}
+
+	for netns, cancel := range observed {
+		if !running[netns] {
+			cancel()
+			delete(observed, netns)
+		}
+	}
+}
+
+// observeSandbox reports the connections of the sandbox observed at its network
+// boundary until the context is done. The connections are attributed to the
+// sandbox process, the guest processes are not known.
+func (t *Tracer) observeSandbox(ctx context.Context, s sandbox.Sandbox, observer *sandbox.Observer) {
+	defer observer.Close()
+
+	var task [16]byte
+	copy(task[:], s.Comm)
+
+	err := observer.Run(ctx, func(flow sandbox.Flow) {
+		var event = domain.IP4Event{
+			Event: domain.Event{
+				Pid:   s.Pid,
+				Af:    syscall.AF_INET,
+				Task:  task,
+				Proto: flow.Proto,
+			},
+			Daddr: flow.Daddr,
+			Dport: flow.Dport,
+		}
+
+		t.dispatch(ctx, event, false, s.Runtime)
+	})
+	if err != nil {
+		logger.Log.Errorf("observation of the %s sandbox [%s:%d] stopped: %v", s.Runtime, s.Comm, s.Pid, err)
+	}
+}
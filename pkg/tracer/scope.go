@@ -0,0 +1,123 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+// resolveScope returns the cgroup of the enforcement (relative to the root
+// cgroup) of the cgroup path or the container id, "/" for the whole host
+func resolveScope(opts Options) (string, error) {
+	if opts.CgroupPath != "" && opts.ContainerID != "" {
+		return "", errors.New("cgroup path and container id are mutually exclusive")
+	}
+	if opts.ServiceContainer && (opts.CgroupPath != "" || opts.ContainerID != "") {
+		return "", errors.New("service container and cgroup path or container id are mutually exclusive")
+	}
+
+	var scope = "/"
+	switch {
+	case opts.CgroupPath != "":
+		scope = cgroupPath(opts.CgroupPath)
+
+	case opts.ContainerID != "":
+		path, err := process.ContainerCgroup(rootCgroup, opts.ContainerID)
+		if err != nil {
+			return "", err
+		}
+		scope = path
+	}
+
+	if scope == "/" {
+		return scope, nil
+	}
+
+	info, err := os.Stat(filepath.Join(rootCgroup, scope))
+	if err != nil {
+		return "", fmt.Errorf("invalid cgroup [%s]: %w", scope, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid cgroup [%s]: not a directory", scope)
+	}
+	logger.Log.Infof("the enforcement is scoped to the cgroup [%s]", scope)
+
+	return scope, nil
+}
+
+// inScope returns true if the process is in the cgroup of the enforcement (a
+// job container in the service container mode). The processes exited before
+// the lookup are in scope, their cgroup is unknown.
+func (t *Tracer) inScope(pid uint32) bool {
+	if t.scope == "/" && t.jobs == nil {
+		return true
+	}
+
+	cgroup, err := process.CgroupOf(pid)
+	if err != nil {
+		return true
+	}
+
+	if t.jobs != nil {
+		return t.jobs.contains(cgroup)
+	}
+
+	return cgroup == t.scope || strings.HasPrefix(cgroup, t.scope+"/")
+}
+
+// maxCgroupLevel is the depth of the cgroups matched by the eBPF programs
+// (MAX_CGROUP_LEVEL)
+const maxCgroupLevel = 8
+
+// putScope enables the enforcement scope in the kernel, the events of the
+// tasks out of the scope cgroup (the job containers in the service container
+// mode, added as they are found) are dropped there. A scope deeper than the
+// cgroup levels read by the programs is matched in userspace only.
+func (t *Tracer) putScope() error {
+	if t.scope == "/" && t.jobs == nil {
+		return nil
+	}
+
+	configMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapScopeConfig]
+	scopeMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapScopeCgroup]
+	if t.jobs != nil {
+		t.jobs.config, t.jobs.cgroups = configMap, scopeMap
+	} else {
+		if cgroupDepth(t.scope) > maxCgroupLevel {
+			logger.Log.Warnf("the cgroup [%s] is deeper than %d levels, the events out of the scope are dropped in userspace", t.scope, maxCgroupLevel)
+			return nil
+		}
+
+		id, err := ebpfman.CgroupID(filepath.Join(rootCgroup, t.scope))
+		if err != nil {
+			return fmt.Errorf("failed to read the cgroup of the scope [%s]: %w", t.scope, err)
+		}
+		if err := scopeMap.Put(id, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update scope cgroup (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	if err := configMap.Put(uint32(0), uint32(1)); err != nil {
+		return fmt.Errorf("%w: failed to enable scope (map): %w", ErrMapUpdate, err)
+	}
+
+	return nil
+}
+
+// cgroupDepth returns the level of the cgroup (relative to the root cgroup),
+// 0 for the root cgroup
+func cgroupDepth(cgroup string) int {
+	cgroup = strings.Trim(cgroup, "/")
+	if cgroup == "" {
+		return 0
+	}
+
+	return strings.Count(cgroup, "/") + 1
+}
@@ -0,0 +1,65 @@
+package tracer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+)
+
+func TestResolveScope(t *testing.T) {
+	if scope, err := resolveScope(Options{}); err != nil || scope != "/" {
+		t.Errorf("expected the whole host, got %s (%v)", scope, err)
+	}
+
+	if _, err := resolveScope(Options{CgroupPath: "system.slice/runner.service", ContainerID: "abc"}); err == nil {
+		t.Error("expected an error for both the cgroup path and the container id")
+	}
+
+	if _, err := resolveScope(Options{CgroupPath: "/kntrl-test/does-not-exist"}); err == nil {
+		t.Error("expected an error for a missing cgroup")
+	}
+
+	var tracer = &Tracer{scope: "/"}
+	if !tracer.inScope(1) {
+		t.Error("expected all processes to be in scope of the whole host")
+	}
+}
+
+func TestCgroupDepth(t *testing.T) {
+	for cgroup, want := range map[string]int{
+		"/":                                    0,
+		"/kntrl/run-42":                        2,
+		"/system.slice/actions-runner.service": 2,
+		"/kubepods.slice/kubepods-burstable.slice/cri-containerd-abc.scope": 3,
+	} {
+		if got := cgroupDepth(cgroup); got != want {
+			t.Errorf("cgroupDepth(%s) = %d, expected %d", cgroup, got, want)
+		}
+	}
+}
+
+func TestPutScope_Errors(t *testing.T) {
+	// the cgroup of the scope is an option error, not a map update failure
+	var tracer = &Tracer{scope: "/kntrl-test/does-not-exist", ebpfClient: &ebpfman.EBPF{Collection: &ebpf.Collection{}}}
+	if err := tracer.putScope(); err == nil || errors.Is(err, ErrMapUpdate) {
+		t.Errorf("expected an error of the cgroup not wrapping ErrMapUpdate, got %v", err)
+	}
+
+	// the values of the map are smaller than the config value
+	configMap, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Array, KeySize: 4, ValueSize: 1, MaxEntries: 1})
+	if err != nil {
+		t.Skipf("failed to create the map: %v", err)
+	}
+	defer configMap.Close()
+
+	tracer = &Tracer{scope: "/", jobs: &jobScope{}, ebpfClient: &ebpfman.EBPF{Collection: &ebpf.Collection{Maps: map[string]*ebpf.Map{
+		domain.EBPFCollectionMapScopeConfig: configMap,
+	}}}}
+	if err := tracer.putScope(); !errors.Is(err, ErrMapUpdate) {
+		t.Errorf("expected ErrMapUpdate, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package tracer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// putSelf sets kntrl itself in the kernel, the events of its connections are
+// dropped there unless they are reported (ReportSelf). Its child processes
+// are excluded in userspace.
+func (t *Tracer) putSelf() error {
+	if t.opts.ReportSelf {
+		return nil
+	}
+
+	var value = ebpfman.SelfConfig{Pid: t.self.Pid()}
+	if cgroup := t.self.Cgroup(); cgroup != "" {
+		id, err := ebpfman.CgroupID(filepath.Join(rootCgroup, cgroup))
+		if err != nil {
+			logger.Log.Warnf("failed to read the cgroup of kntrl [%s], its connections are excluded by the pid: %v", cgroup, err)
+		}
+		value.CgroupID = id
+	}
+
+	selfMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapSelf]
+	if err := selfMap.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("%w: failed to set self (map): %w", ErrMapUpdate, err)
+	}
+
+	return nil
+}
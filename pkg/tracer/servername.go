@@ -0,0 +1,25 @@
+package tracer
+
+import (
+	"context"
+
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/sni"
+)
+
+// sampleServerNames reads the server names of the outgoing TLS handshakes and
+// the hosts of the HTTP requests until the context is done. The handshake
+// (or the request) follows the connect event, the
+// server name is added to the reported event of the connection and it's
+// used by the policy for the later connections to the destination.
+func (t *Tracer) sampleServerNames(ctx context.Context, sniffer *sni.Sniffer) {
+	defer sniffer.Close()
+
+	err := sniffer.Run(ctx, func(address string, port uint16, name string) {
+		t.serverNames.Add(address, port, name)
+		t.report.AddServerName(address, port, name)
+	})
+	if err != nil {
+		logger.Log.Errorf("server name sampling stopped: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/kondukto-io/kntrl/pkg/docker"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/process"
+)
+
+// jobInterval is the interval of the detection of the job containers
+const jobInterval = 10 * time.Second
+
+// jobScope is the enforcement scope of the service container mode, the cgroups
+// of the job containers. The containers of the job start along with kntrl, the
+// cgroup programs are attached to each of them as it's found.
+type jobScope struct {
+	client *docker.Client
+	// selfID is the id of the container of kntrl
+	selfID string
+	// programs are the cgroup programs, attached to the job containers
+	programs []*ebpf.Program
+	// config and cgroups are the maps of the scope in the kernel, nil if the
+	// scope is matched in userspace only
+	config  *ebpf.Map
+	cgroups *ebpf.Map
+
+	mu         sync.RWMutex
+	containers map[string]jobContainer
+}
+
+// jobContainer is a job container in the scope
+type jobContainer struct {
+	name   string
+	cgroup string
+	id     uint64
+	links  []link.Link
+}
+
+// newJobScope returns the scope of the containers of the job found through the
+// Docker socket, the containers on the networks of the container of kntrl
+func newJobScope(socket string) (*jobScope, error) {
+	selfID, err := docker.SelfID()
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof("running as the service container [%.12s], the enforcement is scoped to the job containers", selfID)
+
+	return &jobScope{
+		client:     docker.NewClient(socket),
+		selfID:     selfID,
+		containers: make(map[string]jobContainer),
+	}, nil
+}
+
+// contains returns true if the cgroup is the cgroup of a job container or its descendant
+func (j *jobScope) contains(cgroup string) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	for _, c := range j.containers {
+		if cgroup == c.cgroup || strings.HasPrefix(cgroup, c.cgroup+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch detects the job containers until the context is done, the programs
+// are detached from the stopped containers
+func (j *jobScope) watch(ctx context.Context) {
+	defer j.close()
+
+	var ticker = time.NewTicker(jobInterval)
+	defer ticker.Stop()
+
+	for {
+		j.detect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// detect adds the new job containers to the scope and removes the stopped ones
+func (j *jobScope) detect(ctx context.Context) {
+	containers, err := j.client.Containers(ctx)
+	if err != nil {
+		logger.Log.Errorf("failed to detect the job containers: %v", err)
+		return
+	}
+
+	job, err := docker.JobContainers(containers, j.selfID)
+	if err != nil {
+		logger.Log.Errorf("failed to detect the job containers: %v", err)
+		return
+	}
+
+	var running = make(map[string]bool, len(job))
+	for _, c := range job {
+		running[c.ID] = true
+
+		j.mu.RLock()
+		_, ok := j.containers[c.ID]
+		j.mu.RUnlock()
+		if ok {
+			continue
+		}
+
+		// the cgroup is created when the container starts
+		cgroup, err := process.ContainerCgroup(rootCgroup, c.ID)
+		if err != nil {
+			logger.Log.Debugf("job container [%s]: %v", c.Name, err)
+			continue
+		}
+
+		links, err := j.attach(cgroup)
+		if err != nil {
+			logger.Log.Errorf("failed to attach to the job container [%s]: %v", c.Name, err)
+			continue
+		}
+		id := j.putCgroup(cgroup)
+
+		j.mu.Lock()
+		j.containers[c.ID] = jobContainer{name: c.Name, cgroup: cgroup, id: id, links: links}
+		j.mu.Unlock()
+		logger.Log.Infof("the enforcement is scoped to the job container [%s] (cgroup [%s])", c.Name, cgroup)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, c := range j.containers {
+		if running[id] {
+			continue
+		}
+		closeLinks(c.links)
+		j.deleteCgroup(c.id)
+		delete(j.containers, id)
+		logger.Log.Infof("the job container [%s] stopped", c.name)
+	}
+}
+
+// attach links the cgroup programs to the cgroup
+func (j *jobScope) attach(cgroup string) ([]link.Link, error) {
+	var links []link.Link
+	for _, prg := range j.programs {
+		l, err := link.AttachCgroup(link.CgroupOptions{
+			Path:    filepath.Join(rootCgroup, cgroup),
+			Attach:  ebpf.AttachCGroupInetEgress,
+			Program: prg,
+		})
+		if err != nil {
+			closeLinks(links)
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	return links, nil
+}
+
+// putCgroup adds the cgroup of a job container to the scope in the kernel and
+// returns its id. The scope is matched in userspace only from then if the
+// cgroup can't be matched by the programs.
+func (j *jobScope) putCgroup(cgroup string) uint64 {
+	if j.cgroups == nil {
+		return 0
+	}
+
+	var err = fmt.Errorf("deeper than %d levels", maxCgroupLevel)
+	var id uint64
+	if cgroupDepth(cgroup) <= maxCgroupLevel {
+		if id, err = ebpfman.CgroupID(filepath.Join(rootCgroup, cgroup)); err == nil {
+			err = j.cgroups.Put(id, uint32(1))
+		}
+	}
+	if err == nil {
+		return id
+	}
+
+	logger.Log.Warnf("failed to add the cgroup [%s] to the scope (map), the events out of the scope are dropped in userspace: %v", cgroup, err)
+	if err := j.config.Put(uint32(0), uint32(0)); err != nil {
+		logger.Log.Errorf("failed to disable scope (map): %v", err)
+	}
+	j.config, j.cgroups = nil, nil
+
+	return 0
+}
+
+// deleteCgroup removes the cgroup of a stopped job container from the scope in the kernel
+func (j *jobScope) deleteCgroup(id uint64) {
+	if j.cgroups == nil || id == 0 {
+		return
+	}
+	if err := j.cgroups.Delete(id); err != nil {
+		logger.Log.Warnf("failed to remove the cgroup from the scope (map): %v", err)
+	}
+}
+
+// close detaches the programs from the job containers
+func (j *jobScope) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for id, c := range j.containers {
+		closeLinks(c.links)
+		delete(j.containers, id)
+	}
+}
+
+func closeLinks(links []link.Link) {
+	for _, l := range links {
+		if err := l.Close(); err != nil {
+			logger.Log.Warnf("closing link: %s", err)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package tracer
+
+import "github.com/cilium/ebpf"
+
+// Spec returns the collection spec of the embedded eBPF object of the GOARCH,
+// the programs are verified by the tests of pkg/bpftest without the tracer
+func Spec() (*ebpf.CollectionSpec, error) {
+	return loadBpf()
+}
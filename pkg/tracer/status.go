@@ -0,0 +1,187 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// ErrNotPinned is returned by PinnedStatus if no enforcement is pinned
+var ErrNotPinned = errors.New("no pinned enforcement")
+
+// allowMaps are the maps of the allow list in the kernel
+var allowMaps = []string{
+	domain.EBPFCollectionMapAllowedIP,
+	domain.EBPFCollectionMapAllowedRange,
+	domain.EBPFCollectionMapAllowedHost,
+}
+
+// addAttached records the attached program
+func (t *Tracer) addAttached(name string, typ ebpf.ProgramType, target string) {
+	t.attached = append(t.attached, domain.AttachedProgram{Name: name, Type: typ.String(), Target: target})
+}
+
+// Status returns the mode, the map entries, the attached programs and the
+// event counters of the tracer
+func (t *Tracer) Status() domain.Status {
+	var report = t.report.Report()
+	var stats = t.Stats()
+
+	var maps []*ebpf.Map
+	for _, name := range allowMaps {
+		maps = append(maps, t.ebpfClient.Collection.Maps[name])
+	}
+
+	return domain.Status{
+		Source:        domain.StatusSourceDaemon,
+		Mode:          report.Mode,
+		Enforcing:     !t.opts.Passive && t.enforcing(),
+		Scope:         t.scope,
+		Pinned:        t.opts.Pin,
+		StartedAt:     report.StartedAt,
+		UptimeSeconds: int64(t.now().Sub(report.StartedAt).Seconds()),
+		AllowEntries:  countMaps(maps...),
+		DenyEntries:   countMaps(t.denyMap),
+		Programs:      t.attached,
+		Events: &domain.StatusEvents{
+			Handled:    stats.Handled,
+			Lost:       stats.Lost,
+			Pass:       report.Summary.Pass,
+			Block:      report.Summary.Block,
+			WouldBlock: report.Summary.WouldBlock,
+		},
+	}
+}
+
+// PinnedStatus returns the status of the enforcement pinned under PinPath by
+// kntrl run --pin, ErrNotPinned if none is pinned
+func PinnedStatus() (*domain.Status, error) {
+	return pinnedStatus(PinPath)
+}
+
+// pinnedStatus reads the status of the pinned objects of the directory
+func pinnedStatus(pinPath string) (*domain.Status, error) {
+	var status = &domain.Status{
+		Source:   domain.StatusSourcePinned,
+		Mode:     ModeMonitor,
+		Pinned:   true,
+		Programs: []domain.AttachedProgram{},
+	}
+
+	entries, err := os.ReadDir(filepath.Join(pinPath, pinLinksDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read the pinned links: %w", err)
+	}
+	for _, entry := range entries {
+		var path = filepath.Join(pinPath, pinLinksDir, entry.Name())
+		program, err := pinnedProgram(path)
+		if err != nil {
+			return nil, err
+		}
+		status.Programs = append(status.Programs, program)
+
+		// the links are pinned as they are attached
+		if fi, err := entry.Info(); err == nil && (status.StartedAt.IsZero() || fi.ModTime().Before(status.StartedAt)) {
+			status.StartedAt = fi.ModTime()
+		}
+	}
+
+	var names = []string{domain.EBPFCollectionMapDeny, domain.EBPFCollectionMapMode}
+	var maps = make(map[string]*ebpf.Map)
+	for _, name := range append(names, allowMaps...) {
+		m, err := ebpf.LoadPinnedMap(filepath.Join(pinPath, pinMapsDir, name), &ebpf.LoadPinOptions{ReadOnly: true})
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the pinned map [%s]: %w", name, err)
+		}
+		defer m.Close()
+		maps[name] = m
+	}
+
+	if len(status.Programs) == 0 && len(maps) == 0 {
+		return nil, ErrNotPinned
+	}
+
+	if m, ok := maps[domain.EBPFCollectionMapMode]; ok {
+		var mode uint32
+		if err := m.Lookup(uint32(0), &mode); err != nil {
+			return nil, fmt.Errorf("failed to read the pinned mode: %w", err)
+		}
+		if mode == domain.TracerModeIndexTrace {
+			status.Mode = ModeTrace
+		}
+	}
+	// the maps are kept without a link until the next kntrl takes them over
+	status.Enforcing = status.Mode == ModeTrace && len(status.Programs) > 0
+
+	status.AllowEntries = countMaps(maps[domain.EBPFCollectionMapAllowedIP], maps[domain.EBPFCollectionMapAllowedRange], maps[domain.EBPFCollectionMapAllowedHost])
+	status.DenyEntries = countMaps(maps[domain.EBPFCollectionMapDeny])
+	if !status.StartedAt.IsZero() {
+		status.UptimeSeconds = int64(time.Since(status.StartedAt).Seconds())
+	}
+
+	return status, nil
+}
+
+// pinnedProgram returns the program attached by the pinned link, the link is
+// pinned with the name of its program
+func pinnedProgram(path string) (domain.AttachedProgram, error) {
+	var program = domain.AttachedProgram{Name: filepath.Base(path)}
+
+	l, err := link.LoadPinnedLink(path, nil)
+	if err != nil {
+		return program, fmt.Errorf("failed to load the pinned link [%s]: %w", path, err)
+	}
+	defer l.Close()
+
+	info, err := l.Info()
+	if err != nil {
+		return program, fmt.Errorf("failed to read the pinned link [%s]: %w", path, err)
+	}
+	if cgroup := info.Cgroup(); cgroup != nil {
+		program.Target = fmt.Sprintf("cgroup id %d", cgroup.CgroupId)
+	}
+
+	prg, err := ebpf.NewProgramFromID(info.Program)
+	if err != nil {
+		logger.Log.Debugf("failed to load the program of the pinned link [%s]: %v", path, err)
+		return program, nil
+	}
+	defer prg.Close()
+
+	if prgInfo, err := prg.Info(); err == nil {
+		program.Type = prgInfo.Type.String()
+	}
+
+	return program, nil
+}
+
+// countMaps returns the total of the entries of the maps, the nil maps and
+// the maps failed to be read are skipped
+func countMaps(maps ...*ebpf.Map) int {
+	var total int
+	for _, m := range maps {
+		if m == nil {
+			continue
+		}
+
+		n, err := countEntries(m)
+		if err != nil {
+			logger.Log.Debugf("failed to count map entries [%s]: %v", m, err)
+			continue
+		}
+		total += n
+	}
+
+	return total
+}
@@ -0,0 +1,62 @@
+package tracer
+
+import "sync"
+
+// subscriberBuffer is the event buffer of a subscriber
+const subscriberBuffer = 256
+
+// subscribers fans out the events to the subscribers of the live events
+type subscribers struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newSubscribers() *subscribers {
+	return &subscribers{subs: make(map[chan Event]struct{})}
+}
+
+// subscribe returns a new subscription and the function to cancel it
+func (s *subscribers) subscribe() (<-chan Event, func()) {
+	var ch = make(chan Event, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if _, ok := s.subs[ch]; ok {
+				delete(s.subs, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// publish sends the event to the subscribers, the events are dropped for a slow subscriber
+func (s *subscribers) publish(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// close ends the subscriptions
+func (s *subscribers) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
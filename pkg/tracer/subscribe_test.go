@@ -0,0 +1,35 @@
+package tracer
+
+import "testing"
+
+func TestSubscribers(t *testing.T) {
+	var s = newSubscribers()
+
+	events, cancel := s.subscribe()
+	s.publish(Event{DestinationAddress: "1.1.1.1"})
+
+	if e := <-events; e.DestinationAddress != "1.1.1.1" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Errorf("expected the subscription to be closed")
+	}
+
+	// the slow subscribers don't block the publisher
+	events, _ = s.subscribe()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		s.publish(Event{})
+	}
+	s.close()
+
+	var n int
+	for range events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected %d buffered events, got %d", subscriberBuffer, n)
+	}
+}
@@ -0,0 +1,47 @@
+package tracer
+
+import (
+	"fmt"
+	"time"
+
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+const (
+	// BlockActionDrop drops the packets of the blocked connections
+	BlockActionDrop = "drop"
+	// BlockActionTarpit delays the packets of the connections out of the policy
+	// instead of dropping them (soft block), the deny list is still dropped
+	BlockActionTarpit = "tarpit"
+
+	// DefaultTarpitDelay is the default delay of the packets of the tarpit destinations
+	DefaultTarpitDelay = time.Second
+	// maxTarpitDelay is the horizon of the fq qdisc, the packets scheduled
+	// later are dropped by the qdisc
+	maxTarpitDelay = 10 * time.Second
+)
+
+// validateBlockAction returns an error if the block action or the delay of the
+// tarpit is not supported
+func validateBlockAction(action string, delay time.Duration) error {
+	switch action {
+	case BlockActionDrop:
+		return nil
+	case BlockActionTarpit:
+		if delay <= 0 || delay > maxTarpitDelay {
+			return fmt.Errorf("invalid tarpit delay: %s, must be between 0 and %s", delay, maxTarpitDelay)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("invalid block action: %s", action)
+}
+
+// tarpitAddr adds the destination into the tarpit list, its packets pass
+// with the delay instead of being dropped
+func (t *Tracer) tarpitAddr(daddr ebpfman.IPv4Key) {
+	if err := t.tarpitMap.Put(daddr, uint64(t.opts.TarpitDelay.Nanoseconds())); err != nil {
+		logger.Log.Errorf("failed to update tarpit list (map): %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateBlockAction(t *testing.T) {
+	var tests = []struct {
+		action string
+		delay  time.Duration
+		valid  bool
+	}{
+		{BlockActionDrop, 0, true},
+		{BlockActionTarpit, DefaultTarpitDelay, true},
+		{BlockActionTarpit, maxTarpitDelay, true},
+		{BlockActionTarpit, 0, false},
+		{BlockActionTarpit, maxTarpitDelay + time.Second, false},
+		{"reject", 0, false},
+	}
+
+	for _, tt := range tests {
+		err := validateBlockAction(tt.action, tt.delay)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateBlockAction(%q, %s): unexpected error %v", tt.action, tt.delay, err)
+		}
+	}
+}
@@ -0,0 +1,1382 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/rlimit"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/clock"
+	"github.com/kondukto-io/kntrl/pkg/control"
+	"github.com/kondukto-io/kntrl/pkg/diag"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/enrich"
+	"github.com/kondukto-io/kntrl/pkg/ghmeta"
+	"github.com/kondukto-io/kntrl/pkg/hygiene"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+	"github.com/kondukto-io/kntrl/pkg/nat64"
+	"github.com/kondukto-io/kntrl/pkg/process"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+	"github.com/kondukto-io/kntrl/pkg/runstore"
+	"github.com/kondukto-io/kntrl/pkg/session"
+	"github.com/kondukto-io/kntrl/pkg/sni"
+	"github.com/kondukto-io/kntrl/pkg/tofu"
+	"github.com/kondukto-io/kntrl/pkg/verdictcache"
+)
+
+// Event is a connection event with the policy verdict
+type Event = domain.ReportEvent
+
+// Report is the machine-readable report of the events
+type Report = domain.Report
+
+const (
+	// ModeMonitor reports the connections without blocking
+	ModeMonitor = domain.TracerModeMonitor
+	// ModeTrace blocks the connections not allowed by the policy
+	ModeTrace = domain.TracerModeTrace
+	// ModeTOFU records the destinations on the first run and traces with them on the subsequent runs
+	ModeTOFU = domain.TracerModeTOFU
+	// ModeSimulate evaluates the policy of ModeTrace in the passive mode, the
+	// connections it would block are reported without being blocked
+	ModeSimulate = domain.TracerModeSimulate
+)
+
+// errPassive is returned by the allow list updates in passive mode
+var errPassive = errors.New("passive mode: the allow and deny lists are read-only")
+
+// ErrUnsupportedPlatform is returned by New on the platforms other than linux,
+// a remote tracer is managed by the control API clients instead (see control.Endpoint)
+var ErrUnsupportedPlatform = errors.New("the tracer requires linux")
+
+// The failures of the eBPF programs wrap these errors, they are matched with
+// errors.Is by the embedding application
+var (
+	// ErrLoadBPF is returned by New if the programs or the maps are not loaded
+	ErrLoadBPF = errors.New("failed to load the eBPF programs")
+	// ErrMapUpdate is returned by New and by the allow and deny list updates
+	// if the maps are not written
+	ErrMapUpdate = errors.New("failed to update the eBPF maps")
+	// ErrAttach is returned by Start if the programs are not attached
+	ErrAttach = errors.New("failed to attach the eBPF programs")
+)
+
+const (
+	rootCgroup    = "/sys/fs/cgroup"
+	execCacheSize = 4096
+	eventsBuffer  = 1024
+)
+
+// Options are the options of the tracer
+type Options struct {
+	// Mode is one of ModeMonitor, ModeTrace, ModeTOFU or ModeSimulate
+	Mode string
+	// Passive observes the connections only, the enforcement programs are not attached
+	// and the maps are not written. It requires ModeMonitor.
+	Passive bool
+	// CgroupPath scopes the enforcement to the cgroup (cgroup v2 path) and the
+	// reported events to its processes, the whole host if empty
+	CgroupPath string
+	// ContainerID scopes the enforcement to the cgroup of the Docker or containerd container
+	ContainerID string
+	// Pin pins the enforcement (the cgroup link and the maps) under PinPath:
+	// it stays attached when kntrl stops and a restarted kntrl takes it over
+	// without a window of unfiltered traffic, Detach removes it
+	Pin bool
+	// ServiceContainer scopes the enforcement to the containers of the CI job,
+	// kntrl runs as a service container of the job. The job containers are the
+	// containers on the networks of its container, found through DockerSocket.
+	ServiceContainer bool
+	DockerSocket     string
+	// ExcludeComms and ExcludeCgroups are the services never blocked, the deny list
+	// included (sshd, /system.slice/sshd.service). The cgroups are cgroup v2 paths.
+	ExcludeComms   []string
+	ExcludeCgroups []string
+	// FilterPIDs, FilterComms and IgnoreDests are the events not reported, by
+	// the process id, the task name and the destination (address, CIDR or
+	// domain name, with an optional port). The connections are enforced.
+	FilterPIDs  []int
+	FilterComms []string
+	IgnoreDests []string
+	// AllowedHosts and AllowedIPs are the destinations allowed by the policy
+	AllowedHosts []string
+	AllowedIPs   []string
+	// AllowLocalRanges allows the local IP ranges
+	AllowLocalRanges bool
+	// AllowGithubMeta allows the GitHub meta IP ranges
+	AllowGithubMeta bool
+	// GithubMetaURL is the meta API of the ranges (default https://api.github.com/meta)
+	// and GithubMetaSections are its sections (default actions, web and api)
+	GithubMetaURL      string
+	GithubMetaSections []string
+	// GithubMetaInterval is the refresh interval of the ranges, disabled if 0
+	GithubMetaInterval time.Duration
+	// ResolveInterval is the interval of resolving the allowed hosts again,
+	// disabled if 0
+	ResolveInterval time.Duration
+	// NoMetadataEndpoints doesn't allow the cloud metadata endpoints by default
+	NoMetadataEndpoints bool
+	// NoDefaultAllow doesn't allow the built-in defaults: the nameservers of
+	// /etc/resolv.conf, the loopback and the cloud metadata addresses
+	NoDefaultAllow bool
+	// ProcessRules are the per-process rules as process:action:destination
+	ProcessRules []string
+	// AllowedPorts are the port rules as port, address:port or host:port
+	AllowedPorts []string
+	// DeniedHosts and DeniedIPs are blocked in all modes
+	DeniedHosts []string
+	DeniedIPs   []string
+	// MinReputation blocks the destinations with a lower reputation score in
+	// all modes (the reputation enrichment stage), disabled if 0
+	MinReputation int
+
+	// OutputFileName is the report file, "-" for stdout
+	OutputFileName string
+	// OutputFormat is the report format (table, json, sarif or access-log)
+	OutputFormat string
+	// Outputs are the report outputs (format[=path], the standard output by
+	// default) and the event sinks (URLs, see reporter.NewSink). OutputFormat
+	// and OutputFileName are the report output if empty.
+	Outputs []string
+	// VerboseReport prints the first connection event of each destination
+	// after the connections aggregated by the destination and the process in
+	// the table report
+	VerboseReport bool
+	// StreamOutput writes each event in real time in StreamFormat (default jsonl),
+	// "-" for stdout, disabled if empty. It's independent of the report.
+	StreamOutput string
+	StreamFormat string
+	// ViolationWebhook is the URL the blocked and the unexpected connections
+	// are posted to, disabled if empty
+	ViolationWebhook string
+	// Sinks are the URLs of the event sinks (syslog://host:514, syslog+tcp://host:514,
+	// journald://, otlp://collector:4318), see reporter.NewSink
+	Sinks []string
+	// ReportStore is the directory the events and the report of the run are
+	// kept in for kntrl report, disabled if empty. The events are written as
+	// the report, the destinations are not hashed.
+	ReportStore string
+	// HashSalt replaces the destinations of the events sent out of the runner
+	// (the sinks, the event stream, the webhook, the metrics and the upload)
+	// with salted hashes, see reporter.Hasher. Disabled if empty.
+	HashSalt string
+	// TimeSource is the time source of the report timestamps (system or ntp://host[:port])
+	TimeSource string
+	// ReportSelf reports kntrl's own egress instead of excluding it
+	ReportSelf bool
+	// SNI samples the server names of the outgoing TLS handshakes, the
+	// server name is added to the domain names of the destination
+	SNI bool
+	// HTTPHost samples the Host header of the outgoing plaintext HTTP requests like SNI
+	HTTPHost bool
+	// TraceContext attaches the W3C trace context (TRACEPARENT) of the process
+	// environment to the events
+	TraceContext bool
+	// SandboxObserve observes the connections of the sandboxed runtimes (gVisor,
+	// Kata) at their network boundary, the host probes don't see them. The
+	// connections are reported without enforcement.
+	SandboxObserve bool
+
+	// TOFUStore is the trusted destinations file of the TOFU mode
+	TOFUStore string
+	// HygieneStore keeps the rule hits over the runs, the rules without a hit
+	// in HygieneRuns runs (default 5) are reported as stale, disabled if empty
+	HygieneStore string
+	HygieneRuns  int
+	// VerdictCache is the file or the http(s) URL of the verdict cache: the
+	// destinations allowed by their domain names are exported at the end and
+	// imported by the next job, disabled if empty
+	VerdictCache string
+	// VerdictCacheMaxAge is the lifetime of the cached verdicts (default 24h)
+	VerdictCacheMaxAge time.Duration
+	// StateFile persists the session state to resume after a restart, disabled if empty
+	StateFile string
+	// StateInterval is the session state save interval
+	StateInterval time.Duration
+	// SessionID is the session id to resume, defaults to the CI job id
+	SessionID string
+
+	// MapOverflow is the strategy when the allow or deny map is full: one of
+	// OverflowRejectNew (default), OverflowEvictLRU or OverflowMonitor
+	MapOverflow string
+
+	// BlockAction is the action on the connections out of the policy in the
+	// trace mode: BlockActionDrop (default) or BlockActionTarpit
+	BlockAction string
+	// TarpitDelay is the delay of the packets of the tarpit destinations, the
+	// fq qdisc of the egress interface enforces it
+	TarpitDelay time.Duration
+	// KillOnViolation kills the processes connecting to the destinations
+	// blocked by the kernel (bpf_send_signal), the trace mode only
+	KillOnViolation bool
+
+	// FileAccess traces the reads of the sensitive files and correlates them
+	// with the subsequent connections of the same process
+	FileAccess bool
+	// SensitiveFiles are the files traced by FileAccess, the file name with an
+	// optional directory (.aws/credentials), defaults to DefaultSensitiveFiles
+	SensitiveFiles []string
+	// FileAccessWindow is the time a read is correlated with the connections,
+	// defaults to DefaultFileAccessWindow
+	FileAccessWindow time.Duration
+
+	// MaxConnectionRate is the maximum connections to a destination per
+	// minute, the connections over it are anomalies. 0 disables it.
+	MaxConnectionRate int
+	// MaxDestinations is the maximum destinations of a process, the
+	// connections to the other destinations are anomalies. 0 disables it.
+	MaxDestinations int
+	// AnomalyAction is the action on the anomalies: AnomalyActionReport
+	// (default) or AnomalyActionBlock
+	AnomalyAction string
+
+	// EscalateFindings is the number of the high-severity findings in
+	// EscalateWindow switching the monitor mode to the trace mode, the sinks
+	// and the webhook are notified. 0 disables the escalation.
+	EscalateFindings int
+	// EscalateWindow is the window of the findings of the escalation,
+	// defaults to DefaultEscalateWindow
+	EscalateWindow time.Duration
+
+	// CertFingerprints is the file of the certificates of the allowed TLS
+	// destinations over the runs, probed by kntrl: a public key not seen in the
+	// previous runs is reported as a finding. Empty disables the probes.
+	CertFingerprints string
+
+	// CaptureViolations is the directory of the pcap files of the violating
+	// flows, empty disables the capture. The egress packets are captured, in
+	// all the modes but the passive mode.
+	CaptureViolations string
+	// CapturePackets is the number of the packets captured per violating
+	// flow, defaults to DefaultCapturePackets
+	CapturePackets int
+
+	// MapStatsInterval is the interval of the samples of the entries of the
+	// eBPF maps (metrics and report), 0 disables the samples
+	MapStatsInterval time.Duration
+	// ProgramStats counts the runs and the runtime of the eBPF programs in
+	// the samples of the maps, the kernel stats slow the programs down
+	ProgramStats bool
+
+	// NAT64Prefix is the /96 NAT64 prefix of the network: the IPv6 connections
+	// to the prefix are handled as the connections to the embedded IPv4
+	// address. nat64.Auto (default) detects the prefix (RFC 7050) and falls
+	// back to 64:ff9b::/96, nat64.Disabled disables it.
+	NAT64Prefix string
+
+	// SlowThreshold is the latency of a slow component of the event pipeline, defaults to 500ms
+	SlowThreshold time.Duration
+
+	// VerdictFunc overrides or augments the policy decisions, see Verdict
+	VerdictFunc VerdictFunc
+
+	// Enrichment is the enrichment pipeline of the events, defaults to enrich.Default (rdns)
+	Enrichment *enrich.Pipeline
+
+	// Upload uploads the report to the Kondukto platform when the tracer stops, disabled if nil
+	Upload *reporter.UploadConfig
+	// GitHubComment posts the report summary as a pull request comment when the tracer stops, disabled if nil
+	GitHubComment *reporter.GitHubConfig
+	// ControlSocket serves the control API (live events, report, allow list) on the unix socket, disabled if empty
+	ControlSocket string
+	// ControlRemote serves the control API on a TCP address for the remote clients, disabled if nil
+	ControlRemote *control.RemoteConfig
+	// PolicyFile is merged with the policy above, the allow and deny lists
+	// are reconciled when it changes (see Reload), disabled if empty
+	PolicyFile string
+	// MetricsAddr exposes Prometheus metrics on the given address, disabled if empty
+	MetricsAddr string
+}
+
+// Tracer traces the TCP/UDP connections and enforces the policy
+type Tracer struct {
+	opts Options
+
+	rules      atomic.Pointer[ruleset]
+	ebpfClient *ebpfman.EBPF
+	report     *reporter.Reporter
+	// hasher hashes the destinations of the outputs, nil if disabled
+	hasher *reporter.Hasher
+	// sinks receive the events in real time (the live event stream, syslog, journald, OTLP)
+	sinks []reporter.Sink
+	// stored is the run of the report store, nil if disabled
+	stored *runstore.Run
+	// webhooks post the policy violations
+	webhooks   []*reporter.Webhook
+	trustStore *tofu.Store
+	tracker    *session.Tracker
+	execCache  *process.Cache
+	self       *process.Self
+	users      *process.Users
+	pipeline   *enrich.Pipeline
+	watchdog   *diag.Watchdog
+
+	// kernelMode is the mode of the eBPF programs, the TOFU mode
+	// monitors on the first run and traces on the subsequent runs
+	kernelMode   string
+	recordTrust  bool
+	allowedIPMap *ebpf.Map
+	denyMap      *ebpf.Map
+	portMap      *ebpf.Map
+	// tarpitMap is the delay of the soft blocked destinations
+	tarpitMap *ebpf.Map
+	// hostMap is the allowed hostnames of the DNS answers
+	hostMap *ebpf.Map
+	// rangeMap is the allowed IPv4 ranges (the GitHub meta ranges)
+	rangeMap   *ebpf.Map
+	allowed    *allowTable
+	exclusions *exclusions
+	// filter are the events not reported
+	filter *eventFilter
+	// scope is the cgroup of the enforcement, "/" for the whole host
+	scope string
+	// jobs are the job containers of the service container mode, nil otherwise
+	jobs *jobScope
+	// static are the map entries of the policy, reconciled on reload
+	static   atomic.Pointer[staticKeys]
+	reloadMu sync.Mutex
+	// runtime are the hosts allowed and the destinations denied at runtime
+	runtime runtimeRules
+	// githubMeta fetches the GitHub meta ranges, githubRanges are the current
+	// ranges, nil until they are allowed
+	githubMeta   *ghmeta.Fetcher
+	githubRanges atomic.Pointer[[]string]
+
+	links   []link.Link
+	readers []*perf.Reader
+	// attached are the attached programs, see Status
+	attached []domain.AttachedProgram
+	// pinnedMaps are the names of the state maps taken over with --pin
+	pinnedMaps map[string]struct{}
+
+	events      chan Event
+	subscribers *subscribers
+	// conns correlates the connect and the close events
+	conns *connTable
+	// hits count the connections matched by each policy rule
+	hits *hitTable
+	// lru is the last use of the runtime map entries, see Options.MapOverflow
+	lru *lruTable
+	// nat64 is the NAT64 prefix, the zero prefix if disabled
+	nat64 netip.Prefix
+	// monitorFallback is set when the enforcement is switched to the monitor
+	// mode by a full map
+	monitorFallback atomic.Bool
+	// escalated is set when the monitor mode is switched to the trace mode
+	// by the high-severity findings, see Options.EscalateFindings
+	escalated atomic.Bool
+	// serverNames are the sampled TLS server names and HTTP hosts, nil if disabled
+	serverNames *sni.Cache
+	// ruleHistory is the rule hit history of the policy hygiene, nil if disabled
+	ruleHistory *hygiene.Store
+	// verdictCache is the verdict cache of the next job, nil if disabled
+	verdictCache *verdictcache.Cache
+	// fileAccess correlates the reads of the sensitive files with the
+	// connections, nil if disabled
+	fileAccess *fileAccess
+	// anomalies detects the connections over the anomaly thresholds, nil if disabled
+	anomalies *anomalies
+	// captures are the pcap files of the violating flows, nil if disabled
+	captures *captures
+	// certs fingerprints the certificates of the TLS destinations, nil if disabled
+	certs *certProbes
+	// listeners are the sockets listening on a non-loopback address
+	listeners *listenerTable
+	// dns is the log of the DNS queries of the processes
+	dns *dnsLog
+	// mapStats are the samples of the maps, see Options.MapStatsInterval
+	mapStats *mapStats
+	// eventMu serializes the events of the probes and the sandbox observers
+	eventMu sync.Mutex
+	// handled and lost count the connection events, see Stats
+	handled atomic.Uint64
+	// pending counts the events waiting for the lookup of their destination
+	pending sync.WaitGroup
+	lost    atomic.Uint64
+	done    chan struct{}
+	started bool
+	mu      sync.Mutex
+	err     error
+}
+
+// New loads the eBPF programs and the policy. The programs are attached by Start.
+func New(opts Options) (*Tracer, error) {
+	if err := checkPlatform(); err != nil {
+		return nil, err
+	}
+
+	if opts.Mode != ModeMonitor && opts.Mode != ModeTrace && opts.Mode != ModeTOFU && opts.Mode != ModeSimulate {
+		return nil, fmt.Errorf("invalid mode: %s", opts.Mode)
+	}
+
+	// the simulate mode is the passive monitor mode with the policy required,
+	// the enforcement programs are not attached
+	var simulate = opts.Mode == ModeSimulate
+	if simulate {
+		opts.Mode, opts.Passive = ModeMonitor, true
+	}
+
+	// the passive mode doesn't attach the cgroup programs
+	if err := ebpfman.CheckPrivileges(!opts.Passive); err != nil {
+		return nil, err
+	}
+
+	if opts.Pin && (opts.Passive || opts.ServiceContainer) {
+		return nil, errors.New("pin is not available in passive mode or with a service container")
+	}
+
+	if opts.OutputFormat == "" {
+		opts.OutputFormat = reporter.FormatTable
+	}
+	if !reporter.IsValidFormat(opts.OutputFormat) {
+		return nil, fmt.Errorf("invalid output format: %s", opts.OutputFormat)
+	}
+
+	if opts.MapOverflow == "" {
+		opts.MapOverflow = OverflowRejectNew
+	}
+	if !isValidOverflow(opts.MapOverflow) {
+		return nil, fmt.Errorf("invalid map overflow strategy: %s", opts.MapOverflow)
+	}
+
+	if opts.MinReputation < 0 || opts.MinReputation > 100 {
+		return nil, fmt.Errorf("invalid min reputation: %d, must be between 0 and 100", opts.MinReputation)
+	}
+	if opts.MinReputation > 0 && (opts.Enrichment == nil || !slices.Contains(opts.Enrichment.Names(), enrich.StageReputation)) {
+		return nil, fmt.Errorf("min reputation requires the %s enrichment stage", enrich.StageReputation)
+	}
+
+	if opts.BlockAction == "" {
+		opts.BlockAction = BlockActionDrop
+	}
+	if opts.BlockAction == BlockActionTarpit && opts.TarpitDelay == 0 {
+		opts.TarpitDelay = DefaultTarpitDelay
+	}
+	if err := validateBlockAction(opts.BlockAction, opts.TarpitDelay); err != nil {
+		return nil, err
+	}
+	if err := validateKill(opts); err != nil {
+		return nil, err
+	}
+	if err := validateCapture(opts); err != nil {
+		return nil, err
+	}
+	if err := validateEscalation(opts); err != nil {
+		return nil, err
+	}
+	if opts.EscalateFindings > 0 && opts.EscalateWindow == 0 {
+		opts.EscalateWindow = DefaultEscalateWindow
+	}
+	if opts.CaptureViolations != "" && opts.CapturePackets == 0 {
+		opts.CapturePackets = DefaultCapturePackets
+	}
+	if opts.FileAccess && opts.FileAccessWindow == 0 {
+		opts.FileAccessWindow = DefaultFileAccessWindow
+	}
+	if opts.AnomalyAction == "" {
+		opts.AnomalyAction = AnomalyActionReport
+	}
+	if opts.AnomalyAction != AnomalyActionReport && opts.AnomalyAction != AnomalyActionBlock {
+		return nil, fmt.Errorf("invalid anomaly action: %s", opts.AnomalyAction)
+	}
+	if opts.BlockAction == BlockActionTarpit {
+		logger.Log.Warnf("tarpit: the packets are delayed by the fq qdisc of the egress interface (tc qdisc replace dev eth0 root fq), they pass without a delay on the other qdiscs")
+	}
+
+	if opts.StreamOutput != "" {
+		if opts.StreamFormat == "" {
+			opts.StreamFormat = reporter.StreamFormatJSONL
+		}
+		if !reporter.IsValidStreamFormat(opts.StreamFormat) {
+			return nil, fmt.Errorf("invalid stream format: %s", opts.StreamFormat)
+		}
+		if opts.StreamOutput == "-" && stdoutOutputs(opts) > 0 {
+			return nil, errors.New("the report and the event stream can't be both written to stdout")
+		}
+	}
+	if stdoutOutputs(opts) > 1 {
+		return nil, errors.New("only one report output can be written to stdout")
+	}
+
+	// the options are kept without the policy file, it is merged again on reload
+	effective, err := withPolicyFile(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Passive && opts.Mode != ModeMonitor {
+		return nil, fmt.Errorf("passive mode requires the %s mode", ModeMonitor)
+	}
+
+	// the rules are not counted in passive mode, all of them would be idle
+	if opts.Passive && opts.HygieneStore != "" {
+		return nil, errors.New("the policy hygiene is not available in passive mode")
+	}
+
+	// the policy is not enforced in passive mode, it's evaluated in simulate mode
+	if (!opts.Passive || simulate) && len(effective.AllowedHosts) == 0 && len(effective.AllowedIPs) == 0 {
+		return nil, errors.New("no allowed hostname or IP addresses provided")
+	}
+
+	if opts.Upload != nil {
+		if err := opts.Upload.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ControlRemote != nil {
+		if err := opts.ControlRemote.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.GitHubComment != nil {
+		if err := opts.GitHubComment.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the time source is queried before the programs are attached,
+	// in trace mode the query might be blocked otherwise
+	timeSource, err := clock.New(opts.TimeSource)
+	if err != nil {
+		return nil, err
+	}
+
+	var githubMeta = ghmeta.NewFetcher(opts.GithubMetaURL, opts.GithubMetaSections)
+	var githubRanges []string
+	if effective.AllowGithubMeta {
+		githubRanges = fetchGithubMeta(githubMeta)
+	}
+
+	data, rules, err := compile(effective, githubRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := newExclusions(opts.ExcludeComms, opts.ExcludeCgroups)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newEventFilter(opts.FilterPIDs, opts.FilterComms, opts.IgnoreDests)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := resolveScope(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files *fileAccess
+	if opts.FileAccess {
+		if files, err = newFileAccess(opts.SensitiveFiles, opts.FileAccessWindow); err != nil {
+			return nil, err
+		}
+	}
+
+	var anomalies *anomalies
+	if opts.MaxConnectionRate != 0 || opts.MaxDestinations != 0 {
+		if anomalies, err = newAnomalies(opts.MaxConnectionRate, opts.MaxDestinations); err != nil {
+			return nil, err
+		}
+	}
+
+	var capture *captures
+	if opts.CaptureViolations != "" {
+		if capture, err = newCaptures(opts.CaptureViolations); err != nil {
+			return nil, err
+		}
+	}
+
+	var jobs *jobScope
+	if opts.ServiceContainer {
+		if jobs, err = newJobScope(opts.DockerSocket); err != nil {
+			return nil, err
+		}
+	}
+
+	discoverCtx, cancel := context.WithTimeout(context.Background(), nat64DiscoveryTimeout)
+	nat64Prefix, err := nat64.Parse(discoverCtx, opts.NAT64Prefix)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	var t = &Tracer{
+		opts:        opts,
+		kernelMode:  opts.Mode,
+		execCache:   process.NewCache(execCacheSize),
+		self:        process.NewSelf(),
+		users:       process.NewUsers(),
+		pipeline:    opts.Enrichment,
+		allowed:     newAllowTable(),
+		conns:       newConnTable(),
+		hits:        newHitTable(),
+		lru:         newLRUTable(),
+		nat64:       nat64Prefix,
+		exclusions:  excluded,
+		filter:      filter,
+		scope:       scope,
+		jobs:        jobs,
+		events:      make(chan Event, eventsBuffer),
+		subscribers: newSubscribers(),
+		githubMeta:  githubMeta,
+		fileAccess:  files,
+		anomalies:   anomalies,
+		captures:    capture,
+		listeners:   newListenerTable(),
+		dns:         newDNSLog(),
+		mapStats:    newMapStats(),
+		done:        make(chan struct{}),
+	}
+	t.rules.Store(rules)
+	// the processes of the scope cgroup (the command of kntrl run) are
+	// traced, even as the children of kntrl
+	t.self.Exempt(scope)
+	if effective.AllowGithubMeta {
+		t.githubRanges.Store(&githubRanges)
+	}
+
+	if opts.SNI || opts.HTTPHost {
+		t.serverNames = sni.NewCache()
+	}
+
+	if opts.HygieneStore != "" {
+		t.ruleHistory, err = hygiene.Load(opts.HygieneStore)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CertFingerprints != "" {
+		if t.certs, err = newCertProbes(opts.CertFingerprints); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.pipeline == nil {
+		t.pipeline = enrich.Default()
+	}
+	t.watchdog = diag.NewWatchdog(opts.SlowThreshold)
+	t.pipeline.SetObserver(t.watchdog.Observe)
+
+	// in TOFU mode, the first run monitors and records the destinations,
+	// the subsequent runs trace with the recorded destinations
+	if opts.Mode == ModeTOFU {
+		t.trustStore, err = tofu.Load(opts.TOFUStore)
+		if err != nil {
+			return nil, err
+		}
+
+		t.kernelMode = ModeTrace
+		if t.trustStore.Empty() {
+			t.kernelMode = ModeMonitor
+			t.recordTrust = true
+			logger.Log.Infof("tofu: no trusted destinations, recording the destinations of this run")
+		}
+	}
+
+	if err := t.load(data); err != nil {
+		t.close()
+		return nil, err
+	}
+
+	if len(opts.Outputs) == 0 {
+		t.report = reporter.NewReporterWithFormat(opts.OutputFileName, opts.OutputFormat)
+	} else {
+		t.report = reporter.NewReporterWithOutputs()
+	}
+	if t.report.Err != nil {
+		t.close()
+		return nil, fmt.Errorf("failed to create reporter: %w", t.report.Err)
+	}
+	for _, spec := range opts.Outputs {
+		output, err := reporter.NewOutputSink(spec)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.addSink(output)
+	}
+	t.report.SetMode(opts.Mode)
+	switch {
+	case simulate:
+		t.report.SetMode(domain.TracerModeSimulate)
+	case opts.Passive:
+		t.report.SetMode(domain.TracerModePassive)
+	}
+	t.report.SetVerbose(opts.VerboseReport)
+	t.report.SetClock(timeSource)
+	t.report.SetAllowed(t.allowed.list)
+	t.report.SetDiagnostics(t.watchdog.Findings)
+	t.report.SetTraffic(t.traffic)
+	t.report.SetRules(t.ruleHits)
+	if t.fileAccess != nil || t.anomalies != nil || t.certs != nil {
+		t.report.SetFindings(t.findings)
+	}
+	t.report.SetListeners(t.listeners.list)
+	t.report.SetDNS(t.dns.list)
+	if opts.MapStatsInterval > 0 {
+		t.report.SetMapStats(t.mapStats.list, t.mapStats.listPrograms)
+	}
+
+	if opts.HashSalt != "" {
+		if t.hasher, err = reporter.NewHasher(opts.HashSalt); err != nil {
+			t.close()
+			return nil, err
+		}
+	}
+
+	if opts.StreamOutput != "" {
+		stream, err := reporter.NewStream(opts.StreamOutput, opts.StreamFormat)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.sinks = append(t.sinks, stream)
+	}
+
+	if opts.ViolationWebhook != "" {
+		webhook, err := reporter.NewWebhook(opts.ViolationWebhook)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.addSink(webhook)
+	}
+
+	for _, rawURL := range opts.Sinks {
+		sink, err := reporter.NewSink(rawURL)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.addSink(sink)
+	}
+
+	if opts.ReportStore != "" {
+		store, err := runstore.Open(opts.ReportStore)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		if t.stored, err = store.Create(time.Now()); err != nil {
+			t.close()
+			return nil, err
+		}
+	}
+
+	if err := t.resumeSession(); err != nil {
+		t.close()
+		return nil, err
+	}
+
+	if opts.VerdictCache != "" {
+		t.verdictCache = verdictcache.New(verdictcache.NewBackend(opts.VerdictCache), opts.VerdictCacheMaxAge)
+		t.importVerdictCache()
+	}
+
+	return t, nil
+}
+
+// load loads the eBPF programs and fills the mode and the allow list maps.
+// $BPF_CLANG and $BPF_CFLAGS are set by the Makefile.
+//
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target=amd64,arm64 -cc $BPF_CLANG -cflags $BPF_CFLAGS bpf ../../bpf/sensor.network.bpf.c -- -I $BPF_HEADERS
+func (t *Tracer) load(data *domain.Data) error {
+	// check the kernel before loading, verifier errors are cryptic
+	if err := ebpfman.CheckArch(); err != nil {
+		return err
+	}
+	kernelFeatures := ebpfman.ProbeFeatures()
+	var requireFeatures = kernelFeatures.Require
+	if t.opts.Passive {
+		requireFeatures = kernelFeatures.RequirePassive
+	}
+	if err := requireFeatures(); err != nil {
+		return err
+	}
+
+	// allocate memory
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadBPF, err)
+	}
+
+	// the object of the GOARCH is embedded by the generated bpf2go bindings (bpf_bpfel_x86.go, bpf_bpfel_arm64.go)
+	spec, err := loadBpf()
+	if err != nil {
+		return fmt.Errorf("%w: failed to load ebpf spec: %w", ErrLoadBPF, err)
+	}
+	ebpfman.SelectPrograms(spec, kernelFeatures)
+	if t.opts.Passive {
+		ebpfman.PassivePrograms(spec)
+	}
+	if t.fileAccess == nil {
+		ebpfman.RemoveFileAccessPrograms(spec)
+	}
+
+	// the enforcement state of the previous kntrl is taken over with --pin:
+	// the entries added at runtime are kept across the restart
+	var replacements map[string]*ebpf.Map
+	if t.opts.Pin {
+		if replacements, err = pinnedMaps(spec, PinPath); err != nil {
+			return fmt.Errorf("%w: %w", ErrLoadBPF, err)
+		}
+		defer closeMaps(replacements)
+	}
+
+	t.ebpfClient = ebpfman.New()
+	if err := t.ebpfClient.LoadSpecWithMaps(spec, replacements); err != nil {
+		return fmt.Errorf("%w: %w", ErrLoadBPF, err)
+	}
+	t.pinnedMaps = make(map[string]struct{}, len(replacements))
+	for name := range replacements {
+		t.pinnedMaps[name] = struct{}{}
+	}
+	if len(replacements) > 0 {
+		logger.Log.Infof("took over %d pinned map(s) of the enforcement state", len(replacements))
+	}
+
+	t.allowedIPMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedIP]
+	t.portMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedPort]
+	t.denyMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapDeny]
+	t.tarpitMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapTarpit]
+	t.hostMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedHost]
+	t.rangeMap = t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapAllowedRange]
+	t.static.Store(newStaticKeys(data))
+
+	// the traffic counters are attached to the send and receive functions
+	if !kernelFeatures.Tracing || !kernelFeatures.BTF {
+		logger.Log.Warnf("the running kernel doesn't support fentry programs, the bytes sent and received are not counted")
+	}
+
+	return t.putMaps(kernelFeatures.Tracing && kernelFeatures.BTF)
+}
+
+// putMaps fills the maps of the loaded programs: the mode, the scope and the
+// allow and deny lists, the maps are not written in passive mode. tracing is
+// true if the kernel supports the fentry programs. The failed map updates
+// wrap ErrMapUpdate, the errors of the options (a missing cgroup) don't.
+func (t *Tracer) putMaps(tracing bool) error {
+	// the NAT64 connections are observed in passive mode too
+	if err := t.putNAT64Prefix(); err != nil {
+		return err
+	}
+	// the reads of the sensitive files are observed in passive mode too
+	if err := t.putSensitiveFiles(); err != nil {
+		return err
+	}
+	if err := t.putSelf(); err != nil {
+		return err
+	}
+	// the events out of the scope are dropped in passive mode too
+	if err := t.putScope(); err != nil {
+		return err
+	}
+
+	// the maps are not written in passive mode, the mode map defaults to monitor
+	if t.opts.Passive {
+		logger.Log.Infof("passive mode: the connections are observed without enforcement")
+		return nil
+	}
+
+	// set mode for filtering
+	var modeIndex = uint32(domain.TracerModeIndexMonitor)
+	if t.kernelMode == ModeTrace {
+		modeIndex = uint32(domain.TracerModeIndexTrace)
+	}
+	modeMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapMode]
+	if err := modeMap.Put(uint32(0), modeIndex); err != nil {
+		return fmt.Errorf("%w: failed to set mode: %w", ErrMapUpdate, err)
+	}
+
+	if err := t.putExclusions(tracing); err != nil {
+		return err
+	}
+	if err := t.putFilters(); err != nil {
+		return err
+	}
+
+	for key, entry := range t.static.Load().allowed {
+		if err := t.allowedIPMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update allow ip (map): %w", ErrMapUpdate, err)
+		}
+		t.addAllowed(entry)
+	}
+
+	for key, entry := range t.static.Load().ports {
+		if err := t.portMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update allow port (map): %w", ErrMapUpdate, err)
+		}
+		t.addAllowed(entry)
+	}
+
+	for key := range t.static.Load().denied {
+		if err := t.denyMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update deny ip (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	// the addresses of the DNS answers of the allowed hosts are allowed by the kernel
+	for key := range t.static.Load().hosts {
+		if err := t.hostMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update allow host (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	for key := range t.static.Load().ranges {
+		if err := t.rangeMap.Put(key, uint32(1)); err != nil {
+			return fmt.Errorf("%w: failed to update allow range (map): %w", ErrMapUpdate, err)
+		}
+	}
+
+	if t.trustStore != nil && !t.recordTrust {
+		for _, ipstr := range t.trustStore.Addresses() {
+			if err := t.putAllowedIP(net.ParseIP(ipstr)); err != nil {
+				return fmt.Errorf("%w: failed to update trusted ip (map): %w", ErrMapUpdate, err)
+			}
+			t.addAllowed(AllowEntry{Address: ipstr, Source: domain.AllowSourceTOFU, Rule: t.opts.TOFUStore})
+		}
+	}
+
+	if err := t.putCaptureConfig(); err != nil {
+		return err
+	}
+
+	// the kill is enabled once the allow lists are filled
+	return t.putKillConfig()
+}
+
+// resumeSession restores the report and the dynamic allow list additions
+// of the previous run of the same session
+func (t *Tracer) resumeSession() error {
+	if t.opts.StateFile == "" {
+		return nil
+	}
+
+	var sessionID = t.opts.SessionID
+	if sessionID == "" {
+		sessionID = session.DefaultID()
+	}
+
+	t.tracker = session.NewTracker(t.opts.StateFile, sessionID, t.opts.Mode)
+	t.tracker.SetAllowed(t.allowed.list)
+	t.tracker.SetTimeline(t.report.Timeline)
+	prev, err := t.tracker.Resume()
+	if err != nil {
+		return fmt.Errorf("failed to resume session: %w", err)
+	}
+
+	if prev == nil {
+		return nil
+	}
+
+	t.report.Restore(prev.StartedAt, prev.Events)
+	t.report.RestoreTimeline(prev.Timeline)
+	// the provenance of the restored entries is kept
+	for _, entry := range prev.Allowed {
+		t.allowed.add(entry)
+	}
+	for _, ipstr := range prev.AllowedIPs {
+		if err := t.putAllowEntry(ipstr); err != nil {
+			return fmt.Errorf("%w: failed to restore allow list: %w", ErrMapUpdate, err)
+		}
+	}
+
+	logger.Log.Infof("resumed session [%s] with %d event(s) and %d allowed ip(s)", sessionID, len(prev.Events), len(prev.AllowedIPs))
+
+	return nil
+}
+
+// Events returns the channel of the connection events. The channel is closed
+// when the tracer stops, events are dropped if the channel is not consumed.
+func (t *Tracer) Events() <-chan Event {
+	return t.events
+}
+
+// Allow adds the IP address into the allow list
+func (t *Tracer) Allow(ip net.IP) error {
+	if t.opts.Passive {
+		return errPassive
+	}
+
+	if ip.To4() == nil {
+		return fmt.Errorf("not an IPv4 address: %s", ip)
+	}
+
+	if err := t.putAllowedIP(ip); err != nil {
+		return fmt.Errorf("%w: allow list: %w", ErrMapUpdate, err)
+	}
+
+	t.addAllowed(AllowEntry{Address: ip.String(), Source: domain.AllowSourceRuntime, Rule: "api"})
+
+	if t.tracker != nil {
+		t.tracker.AddAllowedIP(ip.String())
+	}
+
+	return nil
+}
+
+// Import adds the allow list entries exported by another kntrl and returns
+// the number of added entries. The entries already allowed are skipped,
+// the entries without a source are runtime exceptions (kntrl allow add).
+func (t *Tracer) Import(entries []AllowEntry) (int, error) {
+	if t.opts.Passive {
+		return 0, errPassive
+	}
+
+	var imported int
+	var hosts []string
+	for _, entry := range entries {
+		if t.allowed.has(entry.Address) {
+			continue
+		}
+		if host, ok := hostName(entry.Address); ok {
+			hosts = append(hosts, host)
+			continue
+		}
+
+		var source = domain.AllowSourceImport
+		if entry.Source == "" {
+			source = domain.AllowSourceRuntime
+		}
+
+		t.addAllowed(AllowEntry{Address: entry.Address, Source: source, Rule: entry.Rule})
+		if err := t.putAllowEntry(entry.Address); err != nil {
+			t.allowed.remove(entry.Address)
+			return imported, fmt.Errorf("failed to import [%s]: %w", entry.Address, err)
+		}
+
+		if t.tracker != nil {
+			t.tracker.AddAllowedIP(entry.Address)
+		}
+		imported++
+	}
+
+	if len(hosts) > 0 {
+		n, err := t.allowHosts(hosts)
+		imported += n
+		if err != nil {
+			return imported, err
+		}
+	}
+
+	logger.Log.Infof("imported %d allow list entries", imported)
+
+	return imported, nil
+}
+
+// allowHosts adds the hosts into the allowed hosts and returns the number of
+// added hosts. The hosts are resolved and their DNS answers are allowed as
+// the hosts of the policy.
+func (t *Tracer) allowHosts(hosts []string) (int, error) {
+	var added = t.runtime.add(&t.runtime.allowedHosts, hosts)
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if err := t.reconcile(fmt.Sprintf("[%s] added into allowed hosts", strings.Join(added, ", ")), true); err != nil {
+		t.runtime.remove(&t.runtime.allowedHosts, added)
+		return 0, fmt.Errorf("failed to allow hosts: %w", err)
+	}
+
+	return len(added), nil
+}
+
+// Deny adds the IPv4 addresses and the hosts into the deny list and returns the
+// number of added entries. The addresses of the hosts are resolved, the deny
+// list is enforced in all modes and takes precedence over the allow list.
+func (t *Tracer) Deny(destinations []string) (int, error) {
+	if t.opts.Passive {
+		return 0, errPassive
+	}
+
+	var ips, hosts []string
+	for _, d := range destinations {
+		if ip := net.ParseIP(strings.TrimSpace(d)); ip != nil {
+			if ip.To4() == nil {
+				return 0, fmt.Errorf("not an IPv4 address: %s", d)
+			}
+			ips = append(ips, ip.String())
+			continue
+		}
+
+		host, ok := hostName(d)
+		if !ok {
+			return 0, fmt.Errorf("invalid destination: %s", d)
+		}
+		hosts = append(hosts, host)
+	}
+
+	var addedIPs = t.runtime.add(&t.runtime.deniedIPs, ips)
+	var addedHosts = t.runtime.add(&t.runtime.deniedHosts, hosts)
+	var added = append(slices.Clip(addedIPs), addedHosts...)
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if err := t.reconcile(fmt.Sprintf("[%s] added into deny list", strings.Join(added, ", ")), true); err != nil {
+		t.runtime.remove(&t.runtime.deniedIPs, addedIPs)
+		t.runtime.remove(&t.runtime.deniedHosts, addedHosts)
+		return 0, fmt.Errorf("failed to deny: %w", err)
+	}
+
+	return len(added), nil
+}
+
+// hostName returns the lower case hostname of the address,
+// it returns false for an address, an address:port or an invalid name
+func hostName(address string) (string, bool) {
+	var host = strings.ToLower(strings.TrimSpace(address))
+	if strings.ContainsAny(host, ":/ \t") {
+		return "", false
+	}
+	if _, ok := ebpfman.NewHostKey(host); !ok {
+		return "", false
+	}
+
+	return host, true
+}
+
+// putAllowEntry puts the address of an allow list entry into the allow list maps,
+// the port scoped entries are address:port (*:port for any address)
+func (t *Tracer) putAllowEntry(address string) error {
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		if host == "*" {
+			host = ""
+		}
+		return t.putPortRule(domain.PortRule{Address: host, Port: parsePort(port)})
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("invalid address: %s", address)
+	}
+
+	return t.putAllowedIP(ip)
+}
+
+// Subscribe returns the live events and the function to end the subscription.
+// Unlike Events, there can be many subscribers, the events are dropped for a slow subscriber.
+func (t *Tracer) Subscribe() (<-chan Event, func()) {
+	return t.subscribers.subscribe()
+}
+
+// Remove removes the address (address:port for a port scoped entry) or the host
+// allowed at runtime from the allow list
+func (t *Tracer) Remove(address string) error {
+	if t.opts.Passive {
+		return errPassive
+	}
+
+	// the hosts allowed at runtime are removed with the addresses of their DNS answers
+	if host, ok := hostName(address); ok {
+		if !t.runtime.remove(&t.runtime.allowedHosts, []string{host}) {
+			return fmt.Errorf("not an allowed host: %s", address)
+		}
+		return t.reconcile(fmt.Sprintf("[%s] removed from allowed hosts", host), true)
+	}
+
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		var key = ebpfman.PortKey{Port: parsePort(port)}
+		if host != "*" {
+			addr, ok := ebpfman.NewIPv4Key(net.ParseIP(host))
+			if !ok {
+				return fmt.Errorf("not an IPv4 address: %s", host)
+			}
+			key.Addr = addr
+		}
+
+		if err := t.portMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("%w: allow port list: %w", ErrMapUpdate, err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapAllowedPort, key)
+	} else {
+		key, ok := ebpfman.NewIPv4Key(net.ParseIP(address))
+		if !ok {
+			return fmt.Errorf("not an IPv4 address: %s", address)
+		}
+
+		if err := t.allowedIPMap.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("%w: allow list: %w", ErrMapUpdate, err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapAllowedIP, key)
+	}
+
+	t.allowed.remove(address)
+	if t.tracker != nil {
+		t.tracker.RemoveAllowedIP(address)
+	}
+	logger.Log.Infof("[%s] removed from allowed list", address)
+
+	return nil
+}
+
+// Allowed returns the allow list entries with their provenance
+func (t *Tracer) Allowed() []AllowEntry {
+	return t.allowed.list()
+}
+
+// Report returns the report of the events so far
+func (t *Tracer) Report() Report {
+	return t.report.Report()
+}
+
+// now returns the current time of the report time source
+func (t *Tracer) now() time.Time {
+	if t.report == nil {
+		return time.Now()
+	}
+
+	return t.report.Now()
+}
+
+// Stats are the counters of the connection events
+type Stats struct {
+	// Handled is the number of the connection events evaluated and reported
+	Handled uint64
+	// Lost is the number of the connection events lost because the perf buffer was full
+	Lost uint64
+}
+
+// Stats returns the counters of the connection events since the tracer started
+func (t *Tracer) Stats() Stats {
+	return Stats{
+		Handled: t.handled.Load(),
+		Lost:    t.lost.Load(),
+	}
+}
+
+// Wait waits until the tracer stops and returns the error stopped the tracer
+func (t *Tracer) Wait() error {
+	<-t.done
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.err
+}
+
+// putAllowedIP puts the IPv4 address into the allow list map, other addresses are ignored
+func (t *Tracer) putAllowedIP(ip net.IP) error {
+	key, ok := ebpfman.NewIPv4Key(ip)
+	if !ok {
+		return nil
+	}
+
+	return t.putKey(t.allowedIPMap, domain.EBPFCollectionMapAllowedIP, key, ip.String())
+}
+
+// putPortRule puts the port rule into the allowed port map, the hostnames are resolved.
+// Other than IPv4 addresses are ignored.
+func (t *Tracer) putPortRule(rule domain.PortRule) error {
+	var keys = make(map[ebpfman.PortKey]AllowEntry)
+	resolvePortRule(rule, keys)
+
+	for key, entry := range keys {
+		if err := t.putKey(t.portMap, domain.EBPFCollectionMapAllowedPort, key, entry.Address); err != nil {
+			return err
+		}
+		t.addAllowed(entry)
+	}
+
+	return nil
+}
+
+func parsePort(port string) uint16 {
+	p, _ := strconv.ParseUint(port, 10, 16)
+	return uint16(p)
+}
+
+// addSink adds the output of --output or --sink: the report outputs write the
+// report, the webhooks are notified of the violations by the policy and the
+// other sinks get each event
+func (t *Tracer) addSink(sink reporter.Sink) {
+	switch s := sink.(type) {
+	case *reporter.Output:
+		t.report.AddOutput(s)
+	case *reporter.Webhook:
+		t.webhooks = append(t.webhooks, s)
+	default:
+		t.sinks = append(t.sinks, s)
+	}
+}
+
+// stdoutOutputs returns the number of the report outputs written to stdout
+func stdoutOutputs(opts Options) int {
+	if len(opts.Outputs) == 0 {
+		if opts.OutputFileName == "-" {
+			return 1
+		}
+		return 0
+	}
+
+	var n int
+	for _, spec := range opts.Outputs {
+		if strings.Contains(spec, "://") {
+			continue
+		}
+		if _, path, _ := strings.Cut(spec, "="); path == "" || path == "-" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// close detaches the programs and releases the resources
+func (t *Tracer) close() {
+	for _, rd := range t.readers {
+		if err := rd.Close(); err != nil {
+			logger.Log.Warnf("closing perf reader: %s", err)
+		}
+	}
+
+	for _, l := range t.links {
+		if err := l.Close(); err != nil {
+			logger.Log.Warnf("closing link: %s", err)
+		}
+	}
+	if t.opts.Pin && t.started {
+		logger.Log.Infof("the enforcement stays pinned under [%s], kntrl detach removes it", PinPath)
+	}
+
+	if t.report != nil && t.report.Err == nil {
+		t.report.Close()
+	}
+
+	for _, webhook := range t.webhooks {
+		webhook.Close()
+	}
+
+	for _, sink := range t.sinks {
+		if err := sink.Close(); err != nil {
+			logger.Log.Warnf("closing %s sink: %s", sink.Name(), err)
+		}
+	}
+
+	if t.stored != nil {
+		if err := t.stored.Close(); err != nil {
+			logger.Log.Warnf("closing report store: %s", err)
+		}
+	}
+
+	if t.ebpfClient != nil && t.ebpfClient.Collection != nil {
+		t.ebpfClient.Clean()
+	}
+}
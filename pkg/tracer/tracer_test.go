@@ -0,0 +1,54 @@
+package tracer
+
+import (
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestNew_InvalidOptions(t *testing.T) {
+	var tests = []struct {
+		name string
+		opts Options
+	}{
+		{"invalid mode", Options{Mode: "block", AllowedHosts: []string{"example.com"}}},
+		{"invalid format", Options{Mode: ModeTrace, OutputFormat: "xml", AllowedHosts: []string{"example.com"}}},
+		{"no allowed destinations", Options{Mode: ModeTrace}},
+		{"passive trace", Options{Mode: ModeTrace, Passive: true, AllowedHosts: []string{"example.com"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(tt.opts); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestTracer_Simulates(t *testing.T) {
+	var event = domain.ReportEvent{DestinationAddress: "6.6.6.6", DestinationPort: 443}
+
+	// the overflow action switched the trace mode to the monitor mode
+	var fallback = &Tracer{kernelMode: ModeTrace}
+	fallback.monitorFallback.Store(true)
+
+	var tests = []struct {
+		name     string
+		tracer   *Tracer
+		event    domain.ReportEvent
+		expected bool
+	}{
+		{"monitor", &Tracer{kernelMode: ModeMonitor}, event, true},
+		{"passive", &Tracer{kernelMode: ModeMonitor, opts: Options{Passive: true}}, event, true},
+		{"switched to monitor", fallback, event, true},
+		{"trace", &Tracer{kernelMode: ModeTrace}, event, false},
+		{"tofu recording", &Tracer{kernelMode: ModeMonitor, recordTrust: true}, event, false},
+		{"excluded", &Tracer{kernelMode: ModeMonitor}, domain.ReportEvent{Excluded: true}, false},
+		{"sandbox", &Tracer{kernelMode: ModeMonitor}, domain.ReportEvent{Sandbox: "gvisor"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tracer.simulates(tt.event); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package tracer
+
+import (
+	"errors"
+	"net"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// traffic returns the bytes sent and received to the destination, the
+// counters of the kernel are zero if the running kernel doesn't support
+// fentry programs
+func (t *Tracer) traffic(address string, port uint16) (uint64, uint64) {
+	trafficMap := t.ebpfClient.Collection.Maps[domain.EBPFCollectionMapTraffic]
+	if trafficMap == nil {
+		return 0, 0
+	}
+
+	addr, ok := ebpfman.NewIPv4Key(net.ParseIP(address))
+	if !ok {
+		return 0, 0
+	}
+
+	var value ebpfman.Traffic
+	if err := trafficMap.Lookup(ebpfman.PortKey{Addr: addr, Port: port}, &value); err != nil {
+		if !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Debugf("failed to read the traffic of [%s:%d]: %v", address, port, err)
+		}
+		return 0, 0
+	}
+
+	return value.Sent, value.Received
+}
@@ -0,0 +1,114 @@
+package tracer
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// Verdict is the decision of a VerdictFunc
+type Verdict int
+
+const (
+	// VerdictDefault keeps the decision of the policy
+	VerdictDefault Verdict = iota
+	// VerdictAllow allows the destination, it is added into the allow list
+	VerdictAllow
+	// VerdictDeny blocks the destination in all modes, it is added into the deny list
+	VerdictDeny
+)
+
+// VerdictFunc is called for each event after the policy evaluation,
+// the Policy field of the event is the decision of the policy.
+// It is called from the event loop and must not block.
+type VerdictFunc func(Event) Verdict
+
+// kernelVerdict returns the event verdict of the verdict of the kernel
+func kernelVerdict(v uint8) string {
+	switch v {
+	case domain.KernelVerdictAllowed:
+		return domain.EventVerdictAllowed
+	case domain.KernelVerdictDelayed:
+		return domain.EventVerdictDelayed
+	}
+
+	return domain.EventVerdictBlocked
+}
+
+// applyVerdict applies the verdict to the allow and deny maps and returns the policy status
+// The verdicts are not applied in passive mode.
+func (t *Tracer) applyVerdict(v Verdict, daddr ebpfman.IPv4Key, event domain.ReportEvent) string {
+	if t.opts.Passive {
+		return event.Policy
+	}
+
+	switch v {
+	case VerdictAllow:
+		if err := t.denyMap.Delete(daddr); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update deny list (map): %v", err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapDeny, daddr)
+		t.allowAddr(daddr, event.DestinationPort, event.DestinationAddress, domain.AllowSourceRuntime, "verdict")
+		return domain.EventPolicyStatusPass
+
+	case VerdictDeny:
+		if err := t.allowedIPMap.Delete(daddr); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow list (map): %v", err)
+		}
+		if err := t.portMap.Delete(ebpfman.PortKey{Addr: daddr, Port: event.DestinationPort}); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			logger.Log.Errorf("failed to update allow port list (map): %v", err)
+		}
+		t.lru.remove(domain.EBPFCollectionMapAllowedIP, daddr)
+		t.lru.remove(domain.EBPFCollectionMapAllowedPort, ebpfman.PortKey{Addr: daddr, Port: event.DestinationPort})
+		t.allowed.remove(event.DestinationAddress)
+		t.allowed.remove(net.JoinHostPort(event.DestinationAddress, strconv.Itoa(int(event.DestinationPort))))
+		t.denyAddr(daddr)
+		return domain.EventPolicyStatusBlock
+	}
+
+	return event.Policy
+}
+
+// allowAddr adds the destination into the allow list. If there are port rules,
+// the destination is allowed on the given port only. The source and the rule are
+// recorded as the provenance of the entry.
+func (t *Tracer) allowAddr(daddr ebpfman.IPv4Key, dport uint16, addr, source, rule string) {
+	if t.rules.Load().portScoped {
+		addr = net.JoinHostPort(addr, strconv.Itoa(int(dport)))
+		if err := t.putKey(t.portMap, domain.EBPFCollectionMapAllowedPort, ebpfman.PortKey{Addr: daddr, Port: dport}, addr); err != nil {
+			logger.Log.Errorf("failed to update allow port list (map): %v", err)
+			return
+		}
+	} else if err := t.putKey(t.allowedIPMap, domain.EBPFCollectionMapAllowedIP, daddr, addr); err != nil {
+		logger.Log.Errorf("failed to update allow list (map): %v", err)
+		return
+	}
+	logger.Log.Infof("[%s] added into allowed list (%s)", addr, source)
+
+	t.addAllowed(AllowEntry{Address: addr, Source: source, Rule: rule})
+
+	if t.tracker != nil {
+		t.tracker.AddAllowedIP(addr)
+	}
+}
+
+// addAllowed records the provenance of an allow list entry
+func (t *Tracer) addAllowed(entry AllowEntry) {
+	if entry.AddedAt.IsZero() {
+		entry.AddedAt = t.now()
+	}
+	t.allowed.add(entry)
+}
+
+// denyAddr adds the destination into the deny list
+func (t *Tracer) denyAddr(daddr ebpfman.IPv4Key) {
+	if err := t.putKey(t.denyMap, domain.EBPFCollectionMapDeny, daddr, daddr.String()); err != nil {
+		logger.Log.Errorf("failed to update deny list (map): %v", err)
+	}
+}
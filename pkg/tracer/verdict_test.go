@@ -0,0 +1,49 @@
+package tracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/reporter"
+)
+
+func TestKernelVerdict(t *testing.T) {
+	var tests = map[uint8]string{
+		domain.KernelVerdictBlocked: domain.EventVerdictBlocked,
+		domain.KernelVerdictAllowed: domain.EventVerdictAllowed,
+		domain.KernelVerdictDelayed: domain.EventVerdictDelayed,
+		// an unknown verdict of a newer probe
+		7: domain.EventVerdictBlocked,
+	}
+
+	for v, want := range tests {
+		if got := kernelVerdict(v); got != want {
+			t.Errorf("kernelVerdict(%d): expected %s, got %s", v, want, got)
+		}
+	}
+}
+
+func TestViolation_Enforcing(t *testing.T) {
+	var tracer = &Tracer{kernelMode: ModeTrace}
+
+	var tests = []struct {
+		name     string
+		event    domain.ReportEvent
+		kind     string
+		expected bool
+	}{
+		{"blocked", domain.ReportEvent{Policy: domain.EventPolicyStatusBlock, Verdict: domain.EventVerdictBlocked}, reporter.ViolationBlock, true},
+		{"tarpitted", domain.ReportEvent{Policy: domain.EventPolicyStatusTarpit, Verdict: domain.EventVerdictDelayed}, reporter.ViolationTarpit, true},
+		{"passed", domain.ReportEvent{Policy: domain.EventPolicyStatusPass, Verdict: domain.EventVerdictAllowed}, "", false},
+		// out of the process rules, the address is allowed in the kernel for another process
+		{"passed by the kernel", domain.ReportEvent{Policy: domain.EventPolicyStatusPass, Verdict: domain.EventVerdictAllowed, WouldBlock: true}, reporter.ViolationUnexpected, true},
+	}
+
+	for _, tt := range tests {
+		kind, ok := tracer.violation(context.Background(), nil, tt.event)
+		if kind != tt.kind || ok != tt.expected {
+			t.Errorf("%s: expected (%q, %t), got (%q, %t)", tt.name, tt.kind, tt.expected, kind, ok)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package tracer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	ebpfman "github.com/kondukto-io/kntrl/pkg/ebpf"
+	"github.com/kondukto-io/kntrl/pkg/logger"
+)
+
+// verdictCacheTimeout is the timeout of the import and the export of the verdict cache
+const verdictCacheTimeout = 30 * time.Second
+
+// importVerdictCache imports the verdict cache of the previous job. The domain
+// names of the destinations are cached by the enrichment pipeline, and the
+// destinations still allowed by the policy are added into the allow list. A
+// missing or invalid cache is not an error, the job starts cold.
+func (t *Tracer) importVerdictCache() {
+	ctx, cancel := context.WithTimeout(context.Background(), verdictCacheTimeout)
+	defer cancel()
+
+	entries, err := t.verdictCache.Load(ctx, t.report.Now())
+	if err != nil {
+		logger.Log.Warnf("%v", err)
+		return
+	}
+
+	var (
+		rules   = t.rules.Load()
+		allowed int
+	)
+	for _, entry := range entries {
+		key, ok := ebpfman.NewIPv4Key(net.ParseIP(entry.Address))
+		if !ok {
+			continue
+		}
+
+		// the policy may have changed since the verdict, it decides again
+		var event = domain.ReportEvent{
+			Protocol:           entry.Protocol,
+			DestinationAddress: entry.Address,
+			DestinationPort:    entry.Port,
+			Domains:            entry.Domains,
+			Policy:             domain.EventPolicyStatusPass,
+		}
+		if _, denied := t.static.Load().denied[key]; denied || t.isDenied(ctx, rules.denyPolicy, event) {
+			t.verdictCache.Forget(entry.Address)
+			continue
+		}
+
+		if !t.opts.Passive && t.enforcing() {
+			result, err := rules.policy.EvalEvent(ctx, event)
+			if err != nil || !result {
+				t.verdictCache.Forget(entry.Address)
+				continue
+			}
+			t.allowAddr(key, entry.Port, entry.Address, domain.AllowSourceCache, strings.Join(entry.Domains, ","))
+			allowed++
+		}
+		t.pipeline.Warm(entry.Address, entry.Domains)
+	}
+
+	logger.Log.Infof("verdict cache: imported %d destination(s), %d allowed", len(entries), allowed)
+}
+
+// exportVerdictCache exports the verdict cache for the next job
+func (t *Tracer) exportVerdictCache() {
+	ctx, cancel := context.WithTimeout(context.Background(), verdictCacheTimeout)
+	defer cancel()
+
+	if err := t.verdictCache.Save(ctx, t.report.Now()); err != nil {
+		logger.Log.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// HostKind is the kind of a host entry
+type HostKind uint8
+
+const (
+	// HostIPv4 is an IPv4 address
+	HostIPv4 HostKind = iota + 1
+	// HostIPv6 is an IPv6 address
+	HostIPv6
+	// HostName is a hostname to resolve later (example.com, .github.com)
+	HostName
+)
+
+// Host is a protocol-tagged host entry of the --hosts flag
+type Host struct {
+	Kind HostKind
+	// IP is the 4-byte IPv4 or the 16-byte IPv6 address
+	IP net.IP
+	// Name is the hostname
+	Name string
+}
+
+// String returns the address or the hostname
+func (h Host) String() string {
+	if h.Kind == HostName {
+		return h.Name
+	}
+
+	return h.IP.String()
+}
+
+// ParseHosts parses the comma separated IP addresses and hostnames
+// (192.168.0.100, 2001:db8::1, example.com, .github.com). The CIDR ranges
+// and the other entries that are neither an address nor a hostname are
+// rejected instead of being resolved as a hostname.
+func ParseHosts(hosts string) ([]Host, error) {
+	var hl []Host
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		// [2001:db8::1] is accepted for IPv6
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(h, "["), "]"))
+		switch {
+		case ip == nil && strings.ContainsAny(h, "/:[]"):
+			return nil, fmt.Errorf("invalid host [%s]: not an address nor a hostname (CIDR ranges are not supported)", h)
+		case ip == nil:
+			hl = append(hl, Host{Kind: HostName, Name: strings.ToLower(h)})
+		case ip.To4() != nil:
+			hl = append(hl, Host{Kind: HostIPv4, IP: ip.To4()})
+		default:
+			hl = append(hl, Host{Kind: HostIPv6, IP: ip.To16()})
+		}
+	}
+
+	return hl, nil
+}
+
+// UnmapPrefix returns the IPv4 network of an IPv4-mapped IPv6 network
+// (::ffff:10.0.0.0/104 is 10.0.0.0/8), the other networks are returned as is
+func UnmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if !prefix.IsValid() || !prefix.Addr().Is4In6() || prefix.Bits() < 96 {
+		return prefix
+	}
+
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+}
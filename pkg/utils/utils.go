@@ -4,22 +4,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
-	"os/user"
 	"regexp"
 	"strings"
 
 	"github.com/cilium/ebpf"
 )
 
-func IsRoot() bool {
-	u, err := user.Current()
-	if err != nil {
-		return false
-	}
-
-	return u.Uid == "0"
-}
-
 // parse eBPF program name from *ebpf.Program struct
 func ParseProgramName(e *ebpf.Program) string {
 	input := e.String()
@@ -38,6 +28,17 @@ func ParseProgramName(e *ebpf.Program) string {
 	return "(notparsed)" + input
 }
 
+// ParseTracepoint returns the group and the name of the tracepoint
+// from the ELF section name (tracepoint/<group>/<name>)
+func ParseTracepoint(section string) (string, string, error) {
+	parts := strings.Split(section, "/")
+	if len(parts) != 3 || (parts[0] != "tracepoint" && parts[0] != "tp") {
+		return "", "", fmt.Errorf("invalid tracepoint section: %s", section)
+	}
+
+	return parts[1], parts[2], nil
+}
+
 // returns the given protock name
 // TODO: find better alternative
 func GetProtocol(p uint8) string {
@@ -55,6 +56,31 @@ func GetProtocol(p uint8) string {
 	return "-"
 }
 
+// tcpStates are the TCP states of the kernel (include/net/tcp_states.h)
+var tcpStates = map[uint8]string{
+	1:  "ESTABLISHED",
+	2:  "SYN_SENT",
+	3:  "SYN_RECV",
+	4:  "FIN_WAIT1",
+	5:  "FIN_WAIT2",
+	6:  "TIME_WAIT",
+	7:  "CLOSE",
+	8:  "CLOSE_WAIT",
+	9:  "LAST_ACK",
+	10: "LISTEN",
+	11: "CLOSING",
+	12: "NEW_SYN_RECV",
+}
+
+// GetTCPState returns the name of the TCP state
+func GetTCPState(state uint8) string {
+	if name, ok := tcpStates[state]; ok {
+		return name
+	}
+
+	return "-"
+}
+
 // trim NULL bytes (in the event.Comm)
 func TrimNullBytes(p [16]uint8) string {
 	var comm string
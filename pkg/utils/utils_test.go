@@ -2,52 +2,81 @@ package utils
 
 import (
 	"net"
+	"net/netip"
 	"reflect"
 	"testing"
 )
 
-func TestParseAllowedIP(t *testing.T) {
-	// Mocking localIPRanges for testing
-	var expIPs []net.IP
-	{
-		expIPs = append(expIPs, net.ParseIP(githubMetaIPAddress))
-		expIPs = append(expIPs, net.ParseIP(azureIPAddress))
-	}
-
-	var expRanges []string
-	{
-		expRanges = append(expRanges, localIPRanges...)
-	}
-
+func TestParseHosts(t *testing.T) {
 	testCases := []struct {
-		input          string
-		expectedIPs    []net.IP
-		expectedRanges []string
+		input    string
+		expected []Host
+		err      bool
 	}{
 		{
-			input:          "192.168.0.1,192.168.0.2,10.0.0.0/24",
-			expectedIPs:    []net.IP{net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.2")},
-			expectedRanges: []string{"192.168.1.0/24", "10.0.0.0/8", "10.0.0.0/24"},
+			input: "192.168.0.1, example.com,.github.com",
+			expected: []Host{
+				{Kind: HostIPv4, IP: net.IP{192, 168, 0, 1}},
+				{Kind: HostName, Name: "example.com"},
+				{Kind: HostName, Name: ".github.com"},
+			},
+		},
+		{
+			input: "2001:db8::1,[2001:db8::2],::ffff:10.0.0.1",
+			expected: []Host{
+				{Kind: HostIPv6, IP: net.ParseIP("2001:db8::1")},
+				{Kind: HostIPv6, IP: net.ParseIP("2001:db8::2")},
+				{Kind: HostIPv4, IP: net.IP{10, 0, 0, 1}},
+			},
 		},
 		{
-			input:          "192.168.0.1/24,8.8.8.8",
-			expectedIPs:    []net.IP{net.ParseIP("8.8.8.8")},
-			expectedRanges: []string{"192.168.1.0/24", "10.0.0.0/8"},
+			input:    "Example.COM,,",
+			expected: []Host{{Kind: HostName, Name: "example.com"}},
 		},
-		// Add more test cases as needed
+		{input: "10.0.0.0/24", err: true},
+		{input: "2001:db8::/32", err: true},
+		{input: "example.com:443", err: true},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.input, func(t *testing.T) {
-			ips, ranges := ParseAllowedIP(tc.input)
-
-			if !reflect.DeepEqual(ips, tc.expectedIPs) {
-				t.Errorf("unexpected IPs, expected: %v, got: %v", tc.expectedIPs, ips)
+			hosts, err := ParseHosts(tc.input)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
 			}
 
-			if !reflect.DeepEqual(ranges, tc.expectedRanges) {
-				t.Errorf("unexpected ranges, expected: %v, got: %v", tc.expectedRanges, ranges)
+			if !reflect.DeepEqual(hosts, tc.expected) {
+				t.Errorf("unexpected hosts, expected: %v, got: %v", tc.expected, hosts)
 			}
 		})
 	}
 }
+
+func TestUnmapPrefix(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"::ffff:10.0.0.0/104", "10.0.0.0/8"},
+		{"::ffff:1.2.3.4/128", "1.2.3.4/32"},
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"2001:db8::/32", "2001:db8::/32"},
+	}
+
+	for _, tc := range testCases {
+		if got := UnmapPrefix(netip.MustParsePrefix(tc.input)); got.String() != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func TestParseTracepoint(t *testing.T) {
+	group, name, err := ParseTracepoint("tracepoint/sock/inet_sock_set_state")
+	if err != nil || group != "sock" || name != "inet_sock_set_state" {
+		t.Errorf("unexpected tracepoint: %s/%s (%v)", group, name, err)
+	}
+
+	if _, _, err := ParseTracepoint("kprobe/tcp_connect"); err == nil {
+		t.Error("expected an error for a kprobe section")
+	}
+}
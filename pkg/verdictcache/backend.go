@@ -0,0 +1,121 @@
+package verdictcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// TokenEnv is the bearer token of the HTTP backend
+	TokenEnv = "KNTRL_VERDICT_CACHE_TOKEN"
+
+	httpTimeout = 30 * time.Second
+	// maxSize is the size limit of a downloaded cache
+	maxSize = 16 << 20
+)
+
+// Backend persists the verdict cache between the jobs
+type Backend interface {
+	// Load returns the stored cache, nil if there's none
+	Load(ctx context.Context) ([]byte, error)
+	Save(ctx context.Context, data []byte) error
+}
+
+// NewBackend returns the backend of the location: a file (kept by the cache
+// of the CI) or an http(s) URL (GET and PUT, e.g. a presigned object URL)
+func NewBackend(location string) Backend {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return &httpBackend{
+			url:    location,
+			token:  os.Getenv(TokenEnv),
+			client: &http.Client{Timeout: httpTimeout},
+		}
+	}
+
+	return fileBackend(location)
+}
+
+// fileBackend is the path of the cache file
+type fileBackend string
+
+func (f fileBackend) Load(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+func (f fileBackend) Save(_ context.Context, data []byte) error {
+	var path = string(f)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+type httpBackend struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (h *httpBackend) Load(ctx context.Context) ([]byte, error) {
+	resp, err := h.do(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	case http.StatusNotFound:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("verdict cache server returned %s", resp.Status)
+}
+
+func (h *httpBackend) Save(ctx context.Context, data []byte) error {
+	resp, err := h.do(ctx, http.MethodPut, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("verdict cache server returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (h *httpBackend) do(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	return h.client.Do(req)
+}
@@ -0,0 +1,147 @@
+// Package verdictcache keeps the destinations allowed by their domain names
+// over the jobs of an ephemeral runner. The cache of the previous job is
+// imported at the start, the destinations still allowed by the policy don't
+// wait for the reverse lookup and are not blocked before it.
+package verdictcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+const (
+	// DefaultMaxAge is the lifetime of an entry, the addresses of the CDNs change
+	DefaultMaxAge = 24 * time.Hour
+	// maxEntries is the size limit of the cache, the recent entries are kept
+	maxEntries = 4096
+)
+
+// Cache is the verdict cache of a job
+type Cache struct {
+	backend Backend
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]domain.VerdictCacheEntry
+}
+
+// New returns the cache of the backend, the entries older than maxAge are dropped
+func New(backend Backend, maxAge time.Duration) *Cache {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	return &Cache{
+		backend: backend,
+		maxAge:  maxAge,
+		entries: make(map[string]domain.VerdictCacheEntry),
+	}
+}
+
+// Load imports the cache of the previous job and returns its fresh entries
+func (c *Cache) Load(ctx context.Context, now time.Time) ([]domain.VerdictCacheEntry, error) {
+	data, err := c.backend.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verdict cache: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var cache domain.VerdictCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal verdict cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var fresh []domain.VerdictCacheEntry
+	for _, entry := range cache.Entries {
+		if now.Sub(entry.LearnedAt) > c.maxAge || len(entry.Domains) == 0 {
+			continue
+		}
+		c.entries[key(entry)] = entry
+		fresh = append(fresh, entry)
+	}
+
+	return fresh, nil
+}
+
+// Record records the destination of an event allowed by its domain names
+func (c *Cache) Record(event domain.ReportEvent, now time.Time) {
+	var entry = domain.VerdictCacheEntry{
+		Protocol:  event.Protocol,
+		Address:   event.DestinationAddress,
+		Port:      event.DestinationPort,
+		LearnedAt: now,
+	}
+	for _, name := range event.Domains {
+		// the placeholder of the failed lookups
+		if name != "" && name != "." {
+			entry.Domains = append(entry.Domains, name)
+		}
+	}
+	if len(entry.Domains) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key(entry)] = entry
+}
+
+// Forget removes the entries of the address, e.g. an imported destination
+// no longer allowed by the policy
+func (c *Cache) Forget(address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, entry := range c.entries {
+		if entry.Address == address {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Save exports the fresh entries for the next job
+func (c *Cache) Save(ctx context.Context, now time.Time) error {
+	c.mu.Lock()
+	var entries = make([]domain.VerdictCacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		if now.Sub(entry.LearnedAt) <= c.maxAge {
+			entries = append(entries, entry)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LearnedAt.After(entries[j].LearnedAt)
+	})
+	if len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+	}
+
+	data, err := json.MarshalIndent(domain.VerdictCache{ExportedAt: now, Entries: entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal verdict cache: %w", err)
+	}
+
+	if err := c.backend.Save(ctx, data); err != nil {
+		return fmt.Errorf("failed to write verdict cache: %w", err)
+	}
+
+	return nil
+}
+
+func key(entry domain.VerdictCacheEntry) string {
+	return entry.Protocol + "/" + entry.Address + ":" + strconv.Itoa(int(entry.Port))
+}
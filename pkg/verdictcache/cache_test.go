@@ -0,0 +1,98 @@
+package verdictcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+)
+
+func TestCache(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		path = filepath.Join(t.TempDir(), "verdicts.json")
+		now  = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	c := New(NewBackend(path), time.Hour)
+	if entries, err := c.Load(ctx, now); err != nil || entries != nil {
+		t.Fatalf("expected an empty cache, got %v %v", entries, err)
+	}
+
+	c.Record(domain.ReportEvent{Protocol: "tcp", DestinationAddress: "140.82.114.22", DestinationPort: 443, Domains: []string{"github.com"}}, now)
+	c.Record(domain.ReportEvent{Protocol: "tcp", DestinationAddress: "151.101.1.194", DestinationPort: 443, Domains: []string{"registry.npmjs.org"}}, now.Add(-2*time.Hour))
+	// the failed lookups are not cached
+	c.Record(domain.ReportEvent{Protocol: "tcp", DestinationAddress: "10.0.0.1", DestinationPort: 443, Domains: []string{"."}}, now)
+	if err := c.Save(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired entries are dropped
+	next := New(NewBackend(path), time.Hour)
+	entries, err := next.Load(ctx, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Address != "140.82.114.22" || entries[0].Domains[0] != "github.com" {
+		t.Fatalf("expected the github.com entry, got %+v", entries)
+	}
+
+	// the forgotten entries are not exported again
+	next.Forget("140.82.114.22")
+	if err := next.Save(ctx, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := New(NewBackend(path), time.Hour).Load(ctx, now.Add(time.Minute)); len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v", entries)
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		stored []byte
+	)
+	t.Setenv(TokenEnv, "secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(stored)
+		case http.MethodPut:
+			stored, _ = io.ReadAll(r.Body)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		ctx     = context.Background()
+		backend = NewBackend(server.URL + "/kntrl/verdicts.json")
+	)
+	// a cache miss of the first job
+	if data, err := backend.Load(ctx); err != nil || data != nil {
+		t.Fatalf("expected no cache, got %q %v", data, err)
+	}
+
+	if err := backend.Save(ctx, []byte(`{"entries":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := backend.Load(ctx); err != nil || string(data) != `{"entries":[]}` {
+		t.Errorf("expected the stored cache, got %q %v", data, err)
+	}
+}
@@ -0,0 +1,192 @@
+package workspace
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kondukto-io/kntrl/internal/core/domain"
+	"github.com/kondukto-io/kntrl/pkg/parser"
+)
+
+// policyFileNames are the names of the repository policy file
+var policyFileNames = []string{".kntrl.yaml", ".kntrl.yml"}
+
+// Guardrails restrict what the repository policy may loosen
+type Guardrails struct {
+	// Scope is the list of domains the repository may allow (subdomains included).
+	// Any domain except the wildcards and the top level domains is allowed if empty.
+	Scope []string
+	// AllowLocalRanges, AllowGithubMeta and AllowMetadataEndpoints are the values
+	// given by the flags, the repository policy may disable but not enable them
+	AllowLocalRanges       bool
+	AllowGithubMeta        bool
+	AllowMetadataEndpoints bool
+}
+
+// DefaultDir returns the checked-out repository of the CI job, or the working directory
+func DefaultDir() string {
+	for _, env := range []string{"GITHUB_WORKSPACE", "CI_PROJECT_DIR"} {
+		if dir := os.Getenv(env); dir != "" {
+			return dir
+		}
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+
+	return dir
+}
+
+// Discover reads the repository policy in the given directory, the policy
+// must be signed by one of the keys (see Verify), without keys it's always
+// unsigned. It returns nil if there's no policy file.
+func Discover(dir string, keys []ed25519.PublicKey) (*domain.RepoPolicy, string, error) {
+	for _, name := range policyFileNames {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, path, fmt.Errorf("failed to read repository policy: %w", err)
+		}
+
+		if err := Verify(path, data, keys); err != nil {
+			return nil, path, err
+		}
+
+		var rp domain.RepoPolicy
+		if err := yaml.Unmarshal(data, &rp); err != nil {
+			return nil, path, fmt.Errorf("failed to parse repository policy [%s]: %w", path, err)
+		}
+
+		return &rp, path, nil
+	}
+
+	return nil, "", nil
+}
+
+// Restrict returns the part of the repository policy that is allowed by the guardrails
+// and the reasons of the rejected entries
+func Restrict(rp *domain.RepoPolicy, g Guardrails) (domain.RepoPolicy, []string) {
+	var accepted domain.RepoPolicy
+	var rejected []string
+
+	for _, host := range rp.AllowedHosts {
+		host = strings.TrimSpace(host)
+		if err := g.checkHost(host); err != nil {
+			rejected = append(rejected, fmt.Sprintf("allowed-hosts [%s]: %s", host, err))
+			continue
+		}
+		accepted.AllowedHosts = append(accepted.AllowedHosts, host)
+	}
+
+	for _, ip := range rp.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if err := checkIP(ip); err != nil {
+			rejected = append(rejected, fmt.Sprintf("allowed-ips [%s]: %s", ip, err))
+			continue
+		}
+		accepted.AllowedIPs = append(accepted.AllowedIPs, ip)
+	}
+
+	if rp.AllowLocalRanges != nil {
+		if *rp.AllowLocalRanges && !g.AllowLocalRanges {
+			rejected = append(rejected, "allow-local-ranges: disabled by the organization policy")
+		} else {
+			accepted.AllowLocalRanges = rp.AllowLocalRanges
+		}
+	}
+
+	if rp.AllowGithubMeta != nil {
+		if *rp.AllowGithubMeta && !g.AllowGithubMeta {
+			rejected = append(rejected, "allow-github-meta: disabled by the organization policy")
+		} else {
+			accepted.AllowGithubMeta = rp.AllowGithubMeta
+		}
+	}
+
+	if rp.AllowMetadataEndpoints != nil {
+		if *rp.AllowMetadataEndpoints && !g.AllowMetadataEndpoints {
+			rejected = append(rejected, "allow-metadata-endpoints: disabled by the organization policy")
+		} else {
+			accepted.AllowMetadataEndpoints = rp.AllowMetadataEndpoints
+		}
+	}
+
+	for _, rule := range rp.ProcessPolicy {
+		rules, err := parser.ParseProcessRules([]string{rule})
+		if err != nil {
+			rejected = append(rejected, fmt.Sprintf("process-policy [%s]: %s", rule, err))
+			continue
+		}
+
+		// deny rules can only tighten the policy
+		if len(rules) == 1 && rules[0].Action == domain.ProcessRuleActionAllow {
+			if err := g.checkDestination(rules[0].Destination); err != nil {
+				rejected = append(rejected, fmt.Sprintf("process-policy [%s]: %s", rule, err))
+				continue
+			}
+		}
+		accepted.ProcessPolicy = append(accepted.ProcessPolicy, rule)
+	}
+
+	return accepted, rejected
+}
+
+// checkHost rejects the wildcards, the top level domains and the domains out of the scope
+func (g Guardrails) checkHost(host string) error {
+	name := strings.TrimPrefix(host, ".")
+	if name == "" || strings.Contains(name, "*") {
+		return fmt.Errorf("wildcards are not allowed")
+	}
+
+	if len(strings.Split(name, ".")) < 2 {
+		return fmt.Errorf("top level domains are not allowed")
+	}
+
+	if len(g.Scope) == 0 {
+		return nil
+	}
+
+	for _, scope := range g.Scope {
+		scope = strings.TrimPrefix(scope, ".")
+		if name == scope || strings.HasSuffix(name, "."+scope) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("out of the allowed scope")
+}
+
+// checkDestination checks the destination of an allow rule,
+// a single IP address or a domain pattern (*.example.com)
+func (g Guardrails) checkDestination(dest string) error {
+	if net.ParseIP(dest) != nil {
+		return checkIP(dest)
+	}
+
+	return g.checkHost(strings.TrimPrefix(dest, "*."))
+}
+
+// checkIP accepts single addresses only, ranges are managed by the organization policy
+func checkIP(ip string) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return fmt.Errorf("not an IP address (ranges are not allowed)")
+	}
+
+	if addr.IsUnspecified() {
+		return fmt.Errorf("unspecified address is not allowed")
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPolicy = `
+allowed-hosts:
+  - api.example.com
+  - .example.org
+  - "*"
+  - .com
+allowed-ips:
+  - 10.0.0.10
+  - 10.0.0.0/8
+allow-local-ranges: true
+allow-github-meta: false
+allow-metadata-endpoints: true
+process-policy:
+  - "curl:deny:*"
+  - "wget:allow:*"
+  - "npm:allow:*.example.com"
+`
+
+// writeSignedPolicy writes the policy and its signature, it returns the key
+func writeSignedPolicy(t *testing.T, path, policy string) ed25519.PublicKey {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(policy)))
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+signatureExt, []byte(sig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return pub
+}
+
+func TestDiscover(t *testing.T) {
+	var dir = t.TempDir()
+
+	rp, _, err := Discover(dir, nil)
+	if err != nil || rp != nil {
+		t.Fatalf("expected no policy, got %v (%v)", rp, err)
+	}
+
+	key := writeSignedPolicy(t, filepath.Join(dir, ".kntrl.yaml"), testPolicy)
+
+	rp, path, err := Discover(dir, []ed25519.PublicKey{key})
+	if err != nil {
+		t.Fatalf("failed to discover policy: %v", err)
+	}
+	if path != filepath.Join(dir, ".kntrl.yaml") || len(rp.AllowedHosts) != 4 {
+		t.Fatalf("unexpected policy [%s]: %+v", path, rp)
+	}
+}
+
+func TestDiscover_NoKeys(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".kntrl.yaml"), []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unsigned policy is never merged, even without the approved keys
+	if rp, _, err := Discover(dir, nil); !errors.Is(err, ErrUnsigned) || rp != nil {
+		t.Fatalf("expected unsigned policy error, got %v (%v)", rp, err)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	var dir = t.TempDir()
+	key := writeSignedPolicy(t, filepath.Join(dir, ".kntrl.yml"), testPolicy)
+
+	rp, _, err := Discover(dir, []ed25519.PublicKey{key})
+	if err != nil {
+		t.Fatalf("failed to discover policy: %v", err)
+	}
+
+	accepted, rejected := Restrict(rp, Guardrails{Scope: []string{"example.com"}})
+
+	if want := []string{"api.example.com"}; !reflect.DeepEqual(accepted.AllowedHosts, want) {
+		t.Errorf("expected hosts %v, got %v", want, accepted.AllowedHosts)
+	}
+	if want := []string{"10.0.0.10"}; !reflect.DeepEqual(accepted.AllowedIPs, want) {
+		t.Errorf("expected ips %v, got %v", want, accepted.AllowedIPs)
+	}
+	if accepted.AllowLocalRanges != nil {
+		t.Errorf("expected allow-local-ranges to be rejected")
+	}
+	if accepted.AllowGithubMeta == nil || *accepted.AllowGithubMeta {
+		t.Errorf("expected allow-github-meta to be disabled")
+	}
+	if accepted.AllowMetadataEndpoints != nil {
+		t.Errorf("expected allow-metadata-endpoints to be rejected")
+	}
+	if want := []string{"curl:deny:*", "npm:allow:*.example.com"}; !reflect.DeepEqual(accepted.ProcessPolicy, want) {
+		t.Errorf("expected process rules %v, got %v", want, accepted.ProcessPolicy)
+	}
+	if len(rejected) != 7 {
+		t.Errorf("expected 7 rejected entries, got %d: %v", len(rejected), rejected)
+	}
+}
@@ -0,0 +1,92 @@
+package workspace
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signatureExt is the extension of the detached signature file (.kntrl.yaml.sig)
+const signatureExt = ".sig"
+
+// ErrUnsigned is returned if the repository policy is not signed by an approved key
+var ErrUnsigned = errors.New("repository policy is not signed by an approved key")
+
+// LoadKeys reads the approved ed25519 public keys, PEM (PKIX) or base64 encoded
+func LoadKeys(paths []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy key: %w", err)
+		}
+
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policy key [%s]: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Verify verifies the detached signature (base64 encoded ed25519 signature
+// of the policy file, in the file with the .sig extension) of the policy
+func Verify(path string, data []byte, keys []ed25519.PublicKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: no approved key", ErrUnsigned)
+	}
+
+	encoded, err := os.ReadFile(path + signatureExt)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s not found", ErrUnsigned, path+signatureExt)
+		}
+		return fmt.Errorf("failed to read policy signature: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("failed to decode policy signature: %w", err)
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+
+	return ErrUnsigned
+}
+
+func parseKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 public key")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key size")
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,89 @@
+package workspace
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover_Signed(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = t.TempDir()
+	var path = filepath.Join(dir, ".kntrl.yaml")
+	if err := os.WriteFile(path, []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Discover(dir, []ed25519.PublicKey{pub}); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("expected unsigned policy error, got %v", err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(testPolicy)))
+	if err := os.WriteFile(path+signatureExt, []byte(sig+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Discover(dir, []ed25519.PublicKey{other}); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected the signature of another key to be rejected, got %v", err)
+	}
+
+	rp, _, err := Discover(dir, []ed25519.PublicKey{other, pub})
+	if err != nil || rp == nil {
+		t.Fatalf("expected the signed policy, got %v", err)
+	}
+
+	// the policy is modified after signing
+	if err := os.WriteFile(path, []byte(testPolicy+"  - evil.example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Discover(dir, []ed25519.PublicKey{pub}); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected the modified policy to be rejected, got %v", err)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = t.TempDir()
+	var pemFile = filepath.Join(dir, "key.pem")
+	var b64File = filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b64File, []byte(base64.StdEncoding.EncodeToString(pub)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeys([]string{pemFile, b64File})
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	for _, key := range keys {
+		if !key.Equal(pub) {
+			t.Errorf("unexpected key %x", key)
+		}
+	}
+}